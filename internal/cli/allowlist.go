@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ErrCommandNotAllowed is returned when a strict allowlist refuses to
+// wrap a command.
+var ErrCommandNotAllowed = errors.New("command not on the allowlist")
+
+// CommandAllowlist limits which downstream commands wrap and setup put
+// behind contextgate, a guardrail against wrapping arbitrary binaries in
+// shared setups. The zero value allows every command.
+type CommandAllowlist struct {
+	// Commands are command names or path.Match globs (e.g. "npx", "uvx",
+	// "mcp-server-*"). Entries are matched against the command's base
+	// name, or against its full path when they contain a slash.
+	Commands []string
+
+	// Strict refuses unlisted commands; otherwise they are wrapped with
+	// a warning.
+	Strict bool
+}
+
+// Allows reports whether command is on the list, or the list is empty.
+func (a CommandAllowlist) Allows(command string) bool {
+	if len(a.Commands) == 0 {
+		return true
+	}
+	base := filepath.Base(command)
+	for _, entry := range a.Commands {
+		target := base
+		if strings.Contains(entry, "/") {
+			target = filepath.ToSlash(command)
+		}
+		if entry == target {
+			return true
+		}
+		if ok, _ := path.Match(entry, target); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Check returns an error wrapping ErrCommandNotAllowed for an unlisted
+// command when the list is strict, and otherwise writes a warning about
+// it to w.
+func (a CommandAllowlist) Check(command string, w io.Writer) error {
+	if a.Allows(command) {
+		return nil
+	}
+	if a.Strict {
+		return fmt.Errorf("%q: %w (%s)", command, ErrCommandNotAllowed, strings.Join(a.Commands, ", "))
+	}
+	fmt.Fprintf(w, "Warning: %q is not on the command allowlist (%s)\n", command, strings.Join(a.Commands, ", "))
+	return nil
+}
+
+// parseFlag applies args[i] if it is one of the allowlist options shared
+// by wrap and setup: --allow-commands list and --strict-commands.
+func (a *CommandAllowlist) parseFlag(args []string, i int) {
+	switch args[i] {
+	case "--allow-commands":
+		if i+1 < len(args) {
+			for _, c := range strings.Split(args[i+1], ",") {
+				if c = strings.TrimSpace(c); c != "" {
+					a.Commands = append(a.Commands, c)
+				}
+			}
+		}
+	case "--strict-commands":
+		a.Strict = true
+	}
+}
@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestCommandAllowlist_Allows(t *testing.T) {
+	allow := CommandAllowlist{Commands: []string{"npx", "mcp-server-*", "/opt/mcp/*"}}
+	for cmd, want := range map[string]bool{
+		"npx":                 true,
+		"/usr/local/bin/npx":  true,
+		"mcp-server-github":   true,
+		"/opt/mcp/fs":         true,
+		"/usr/bin/fs":         false,
+		"bash":                false,
+		"/tmp/npx-lookalike":  false,
+		"./mcp-server-sqlite": true,
+		"python":              false,
+	} {
+		if got := allow.Allows(cmd); got != want {
+			t.Errorf("Allows(%q) = %v, want %v", cmd, got, want)
+		}
+	}
+	if !(CommandAllowlist{}).Allows("anything") {
+		t.Error("empty allowlist should allow every command")
+	}
+}
+
+func TestRunWrap_Allowlist(t *testing.T) {
+	t.Setenv("PATH", t.TempDir()) // no claude CLI: an allowed command gets past the check and stops there
+
+	err := RunWrap([]string{"fs", "--allow-commands", "npx,uvx", "--strict-commands", "--", "bash", "-c", "evil"})
+	if !errors.Is(err, ErrCommandNotAllowed) {
+		t.Errorf("disallowed command: err = %v, want ErrCommandNotAllowed", err)
+	}
+
+	err = RunWrap([]string{"fs", "--allow-commands", "npx,uvx", "--strict-commands", "--", "npx", "-y", "server-filesystem"})
+	if err == nil || errors.Is(err, ErrCommandNotAllowed) {
+		t.Errorf("allowed command: err = %v, want the missing claude CLI", err)
+	}
+
+	// Without --strict-commands an unlisted command is only warned about
+	err = RunWrap([]string{"fs", "--allow-commands", "npx", "--", "bash"})
+	if errors.Is(err, ErrCommandNotAllowed) {
+		t.Errorf("warn mode refused the command: %v", err)
+	}
+}
+
+func TestWrapConfigFile_Allowlist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mcp.json")
+	config := `{"mcpServers":{
+		"fs":{"command":"npx","args":["-y","server-filesystem"]},
+		"shell":{"command":"/bin/bash","args":["-c","server.sh"]}
+	}}`
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := WrapConfigFile(path, "/usr/bin/contextgate", ":9000", "cursor",
+		CommandAllowlist{Commands: []string{"npx"}, Strict: true})
+	if err != nil || n != 1 {
+		t.Fatalf("WrapConfigFile = %d, %v; want 1 wrapped", n, err)
+	}
+
+	servers, err := ReadServersFromConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range servers {
+		switch s.Name {
+		case "fs":
+			if s.Command != "/usr/bin/contextgate" || !slices.Contains(s.Args, "npx") {
+				t.Errorf("allowed server not wrapped: %+v", s)
+			}
+		case "shell":
+			if s.Command != "/bin/bash" {
+				t.Errorf("disallowed server wrapped: %+v", s)
+			}
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/contextgate/contextgate/internal/store"
+)
+
+// RunDB checks or repairs the message database.
+//
+// Usage: contextgate db check|repair [--db path]
+func RunDB(args []string, defaultPath string) error {
+	if len(args) == 0 {
+		return printDBUsage()
+	}
+
+	fs := flag.NewFlagSet("db "+args[0], flag.ContinueOnError)
+	dbPath := fs.String("db", defaultPath, "SQLite database path")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "check":
+		return runDBCheck(*dbPath)
+	case "repair":
+		return runDBRepair(*dbPath)
+	default:
+		return printDBUsage()
+	}
+}
+
+func runDBCheck(path string) error {
+	problems, err := store.CheckIntegrity(path)
+	if err != nil {
+		return fmt.Errorf("%w\nThe database may be damaged; try 'contextgate db repair'", err)
+	}
+	if len(problems) == 0 {
+		fmt.Printf("%s: ok\n", path)
+		return nil
+	}
+
+	fmt.Printf("%s: %d problem(s) found\n\n", path, len(problems))
+	for _, p := range problems {
+		fmt.Printf("  %s\n", p)
+	}
+	fmt.Println()
+	fmt.Println("Run 'contextgate db repair' to rebuild the database from its readable rows.")
+	return fmt.Errorf("integrity check failed")
+}
+
+func runDBRepair(path string) error {
+	fmt.Printf("Repairing %s (make sure no contextgate proxy is using it)...\n\n", path)
+
+	res, err := store.Repair(path)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range res.Tables {
+		if t.Err != nil {
+			fmt.Printf("  %-16s %d rows recovered, stopped early: %v\n", t.Name, t.Rows, t.Err)
+		} else {
+			fmt.Printf("  %-16s %d rows\n", t.Name, t.Rows)
+		}
+	}
+	for _, w := range res.Warnings {
+		fmt.Printf("  warning: %s\n", w)
+	}
+
+	fmt.Printf("\nDone! The original database was kept at %s\n", res.Backup)
+	return nil
+}
+
+func printDBUsage() error {
+	fmt.Fprintln(os.Stderr, "Usage: contextgate db check|repair [--db path]")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "  check   Run SQLite's integrity check on the message database")
+	fmt.Fprintln(os.Stderr, "  repair  Rebuild the database from every readable row, keeping the original as a backup")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Options:")
+	fmt.Fprintln(os.Stderr, "  --db path  Database to operate on (default ~/.contextgate/contextgate.db)")
+	return fmt.Errorf("missing arguments")
+}
@@ -2,6 +2,7 @@ package cli
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -106,7 +107,9 @@ func ReadServersFromConfig(path string) ([]MCPServerEntry, error) {
 }
 
 // WrapConfigFile reads a config file, wraps each server with contextgate, and writes it back.
-func WrapConfigFile(path string, gateBinary string, dashPort string) (int, error) {
+// clientID, if set, is passed as --client-id so policy rules can tell the clients apart.
+// Servers whose command allow refuses are left unwrapped.
+func WrapConfigFile(path string, gateBinary string, dashPort string, clientID string, allow CommandAllowlist) (int, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return 0, err
@@ -134,8 +137,17 @@ func WrapConfigFile(path string, gateBinary string, dashPort string) (int, error
 			continue
 		}
 
-		// Build new args: --dashboard :PORT -- original_command original_args...
-		newArgs := []string{"--dashboard", dashPort, "--", s.Command}
+		if err := allow.Check(s.Command, os.Stdout); err != nil {
+			fmt.Printf("  Not wrapping %s: %v\n", name, err)
+			continue
+		}
+
+		// Build new args: --dashboard :PORT [--client-id ID] -- original_command original_args...
+		newArgs := []string{"--dashboard", dashPort}
+		if clientID != "" {
+			newArgs = append(newArgs, "--client-id", clientID)
+		}
+		newArgs = append(newArgs, "--", s.Command)
 		newArgs = append(newArgs, s.Args...)
 
 		s.Command = gateBinary
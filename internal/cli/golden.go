@@ -0,0 +1,553 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/contextgate/contextgate/internal/proxy"
+	"github.com/contextgate/contextgate/internal/store"
+)
+
+const (
+	// goldenMaxMessages caps how many stored messages a recording reads.
+	goldenMaxMessages = 100000
+
+	// goldenMaxLine matches the proxy's limit on a single message.
+	goldenMaxLine = 10 * 1024 * 1024
+
+	defaultGoldenTimeout = 10 * time.Second
+)
+
+// GoldenSession is a recorded session kept as the expected behavior of a
+// server: the host's messages in order, each request with the reply the
+// server gave.
+type GoldenSession struct {
+	SessionID string   `json:"session_id,omitempty"`
+	Command   string   `json:"command,omitempty"`
+	Args      []string `json:"args,omitempty"`
+
+	// Ignore lists JSON Pointers into responses (e.g. /result/_meta/time)
+	// left out of comparisons. A * segment matches any key or index.
+	Ignore []string `json:"ignore,omitempty"`
+
+	Steps []GoldenStep `json:"steps"`
+}
+
+// GoldenStep is one host message and, for requests, the server's reply.
+type GoldenStep struct {
+	Method   string          `json:"method,omitempty"`
+	Request  json.RawMessage `json:"request"`
+	Response json.RawMessage `json:"response,omitempty"`
+}
+
+// GoldenDiff lists how the reply to one step differs from the golden one.
+type GoldenDiff struct {
+	Step    int      `json:"step"` // 1-based
+	Method  string   `json:"method,omitempty"`
+	Changes []string `json:"changes"`
+}
+
+// BuildGolden turns a session's stored messages, oldest first, into a
+// golden session. Blocked host messages never reached the server and are
+// left out, as are payloads that are no longer valid JSON (truncated or
+// binary), which are reported as warnings.
+func BuildGolden(session *store.Session, entries []store.LogEntry) (*GoldenSession, []string) {
+	g := &GoldenSession{SessionID: session.ID, Command: session.Command, Args: session.Args}
+	var warnings []string
+
+	replies := make(map[string]json.RawMessage)
+	for _, e := range entries {
+		if e.Direction == string(proxy.DirServerToHost) && (e.Kind == string(proxy.KindResponse) || e.Kind == string(proxy.KindError)) {
+			if _, seen := replies[e.MsgID]; !seen && json.Valid([]byte(e.Payload)) {
+				replies[e.MsgID] = json.RawMessage(e.Payload)
+			}
+		}
+	}
+
+	for _, e := range entries {
+		if e.Direction != string(proxy.DirHostToServer) || e.Blocked {
+			continue
+		}
+		if e.Kind != string(proxy.KindRequest) && e.Kind != string(proxy.KindNotification) {
+			continue
+		}
+		if !json.Valid([]byte(e.Payload)) {
+			warnings = append(warnings, fmt.Sprintf("skipped %s message %d: stored payload is not valid JSON", e.Method, e.ID))
+			continue
+		}
+		step := GoldenStep{Method: e.Method, Request: json.RawMessage(e.Payload)}
+		if e.Kind == string(proxy.KindRequest) {
+			step.Response = replies[e.MsgID]
+		}
+		g.Steps = append(g.Steps, step)
+	}
+	return g, warnings
+}
+
+// ReplayGolden sends the golden host messages to a server over w, one at
+// a time, and collects its reply to each request from r. Requests the
+// server sends back are refused, and a request not answered within
+// timeout gets a nil reply.
+func ReplayGolden(ctx context.Context, w io.Writer, r io.Reader, steps []GoldenStep, timeout time.Duration) ([]json.RawMessage, error) {
+	type reply struct {
+		id  string
+		raw json.RawMessage
+	}
+	replies := make(chan reply)
+	early := make(map[string]json.RawMessage) // replies that overtook their request's turn
+	readErr := make(chan error, 1)
+
+	var mu sync.Mutex // the refusals below share w with the replay
+	writeLine := func(line []byte) error {
+		mu.Lock()
+		defer mu.Unlock()
+		_, err := fmt.Fprintf(w, "%s\n", line)
+		return err
+	}
+
+	go func() {
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, 64*1024), goldenMaxLine)
+		for scanner.Scan() {
+			line := slices.Clone(scanner.Bytes())
+			msg, err := proxy.ParseMessage(line)
+			if err != nil {
+				continue
+			}
+			switch msg.Kind() {
+			case proxy.KindRequest:
+				// The host that answered these isn't here
+				writeLine(fmt.Appendf(nil, `{"jsonrpc":"2.0","id":%s,"error":{"code":-32601,"message":"not available during golden replay"}}`, msg.ID))
+			case proxy.KindResponse, proxy.KindError:
+				select {
+				case replies <- reply{id: string(msg.ID), raw: line}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+		readErr <- scanner.Err()
+	}()
+
+	got := make([]json.RawMessage, len(steps))
+	for i, step := range steps {
+		if err := writeLine(step.Request); err != nil {
+			return got, fmt.Errorf("step %d: write: %w", i+1, err)
+		}
+		var req proxy.JSONRPCMessage
+		if json.Unmarshal(step.Request, &req) != nil || len(req.ID) == 0 {
+			continue // notification
+		}
+
+		id := string(req.ID)
+		if raw, ok := early[id]; ok {
+			got[i] = raw
+			delete(early, id)
+			continue
+		}
+		deadline := time.NewTimer(timeout)
+	wait:
+		for {
+			select {
+			case rep := <-replies:
+				if rep.id == id {
+					got[i] = rep.raw
+					break wait
+				}
+				early[rep.id] = rep.raw
+			case err := <-readErr:
+				deadline.Stop()
+				if err == nil {
+					err = io.ErrUnexpectedEOF
+				}
+				return got, fmt.Errorf("step %d: server output ended: %w", i+1, err)
+			case <-deadline.C:
+				break wait
+			case <-ctx.Done():
+				deadline.Stop()
+				return got, ctx.Err()
+			}
+		}
+		deadline.Stop()
+	}
+	return got, nil
+}
+
+// CompareGolden compares the replies of a replay with the golden ones,
+// skipping the ignored paths, and returns the steps that changed.
+func CompareGolden(steps []GoldenStep, got []json.RawMessage, ignore []string) []GoldenDiff {
+	var diffs []GoldenDiff
+	for i, step := range steps {
+		if step.Response == nil {
+			continue
+		}
+		d := GoldenDiff{Step: i + 1, Method: step.Method}
+		if i >= len(got) || got[i] == nil {
+			d.Changes = []string{"no response"}
+			diffs = append(diffs, d)
+			continue
+		}
+
+		var want, have any
+		json.Unmarshal(step.Response, &want)
+		if err := json.Unmarshal(got[i], &have); err != nil {
+			d.Changes = []string{"response is not valid JSON"}
+			diffs = append(diffs, d)
+			continue
+		}
+		for _, p := range ignore {
+			tokens := pointerTokens(p)
+			want = removePath(want, tokens)
+			have = removePath(have, tokens)
+		}
+		diffJSON("", want, have, &d.Changes)
+		if len(d.Changes) > 0 {
+			diffs = append(diffs, d)
+		}
+	}
+	return diffs
+}
+
+// pointerTokens splits a JSON Pointer into its unescaped segments.
+func pointerTokens(ptr string) []string {
+	if ptr == "" || ptr == "/" {
+		return nil
+	}
+	tokens := strings.Split(strings.TrimPrefix(ptr, "/"), "/")
+	for i, t := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(t)
+	}
+	return tokens
+}
+
+// removePath deletes the value at tokens from doc, where a "*" token
+// matches every key or index. Array elements are nulled rather than
+// removed so later indexes still line up.
+func removePath(doc any, tokens []string) any {
+	if len(tokens) == 0 {
+		return nil
+	}
+	tok, rest := tokens[0], tokens[1:]
+	switch node := doc.(type) {
+	case map[string]any:
+		for k, v := range node {
+			if tok != "*" && tok != k {
+				continue
+			}
+			if len(rest) == 0 {
+				delete(node, k)
+			} else {
+				node[k] = removePath(v, rest)
+			}
+		}
+	case []any:
+		for i, v := range node {
+			if tok != "*" && tok != strconv.Itoa(i) {
+				continue
+			}
+			if len(rest) == 0 {
+				node[i] = nil
+			} else {
+				node[i] = removePath(v, rest)
+			}
+		}
+	}
+	return doc
+}
+
+// diffJSON appends a line for every difference between want and got,
+// each prefixed with its JSON Pointer.
+func diffJSON(path string, want, got any, out *[]string) {
+	switch w := want.(type) {
+	case map[string]any:
+		g, ok := got.(map[string]any)
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(w)+len(g))
+		for k := range w {
+			keys = append(keys, k)
+		}
+		for k := range g {
+			if _, ok := w[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		slices.Sort(keys)
+		for _, k := range keys {
+			p := path + "/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(k)
+			wv, inWant := w[k]
+			gv, inGot := g[k]
+			switch {
+			case !inGot:
+				*out = append(*out, fmt.Sprintf("%s: removed (was %s)", p, formatJSON(wv)))
+			case !inWant:
+				*out = append(*out, fmt.Sprintf("%s: added %s", p, formatJSON(gv)))
+			default:
+				diffJSON(p, wv, gv, out)
+			}
+		}
+		return
+	case []any:
+		g, ok := got.([]any)
+		if !ok {
+			break
+		}
+		for i := range max(len(w), len(g)) {
+			p := path + "/" + strconv.Itoa(i)
+			switch {
+			case i >= len(g):
+				*out = append(*out, fmt.Sprintf("%s: removed (was %s)", p, formatJSON(w[i])))
+			case i >= len(w):
+				*out = append(*out, fmt.Sprintf("%s: added %s", p, formatJSON(g[i])))
+			default:
+				diffJSON(p, w[i], g[i], out)
+			}
+		}
+		return
+	}
+	if !reflect.DeepEqual(want, got) {
+		if path == "" {
+			path = "/"
+		}
+		*out = append(*out, fmt.Sprintf("%s: %s → %s", path, formatJSON(want), formatJSON(got)))
+	}
+}
+
+// formatJSON renders v compactly for a report line.
+func formatJSON(v any) string {
+	const limit = 80
+	data, _ := json.Marshal(v)
+	if len(data) > limit {
+		return string(data[:limit]) + "…"
+	}
+	return string(data)
+}
+
+// WriteGoldenReport prints each changed step with its differences and a
+// summary of how many of the compared replies changed.
+func WriteGoldenReport(w io.Writer, steps []GoldenStep, diffs []GoldenDiff) {
+	compared := 0
+	for _, s := range steps {
+		if s.Response != nil {
+			compared++
+		}
+	}
+	for _, d := range diffs {
+		fmt.Fprintf(w, "CHANGED  step %d (%s)\n", d.Step, d.Method)
+		for _, c := range d.Changes {
+			fmt.Fprintf(w, "           %s\n", c)
+		}
+	}
+	fmt.Fprintf(w, "\n%d of %d responses changed\n", len(diffs), compared)
+}
+
+// RunGolden records a session as golden or compares a server against one.
+//
+// Usage:
+//
+//	contextgate golden record --session id [--db path] [--ignore ptrs] [-o file]
+//	contextgate golden compare [--ignore ptrs] [--timeout dur] [--json] <golden.json> [-- command args...]
+func RunGolden(args []string, defaultDBPath string) error {
+	if len(args) == 0 {
+		return printGoldenUsage()
+	}
+	switch args[0] {
+	case "record":
+		return runGoldenRecord(args[1:], defaultDBPath)
+	case "compare":
+		return runGoldenCompare(args[1:])
+	default:
+		return printGoldenUsage()
+	}
+}
+
+func runGoldenRecord(args []string, defaultDBPath string) error {
+	fs := flag.NewFlagSet("golden record", flag.ContinueOnError)
+	dbPath := fs.String("db", defaultDBPath, "SQLite database path")
+	sessionID := fs.String("session", "", "session to record")
+	ignore := fs.String("ignore", "", "comma-separated JSON Pointers to leave out of comparisons")
+	out := fs.String("o", "", "write the golden session to this file instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *sessionID == "" {
+		return printGoldenUsage()
+	}
+	ignored, err := parseIgnorePaths(*ignore)
+	if err != nil {
+		return err
+	}
+
+	st, err := store.NewSQLiteStore(*dbPath, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+	// Encrypted payloads are read with the key from CONTEXTGATE_DB_KEY
+	key, err := store.LoadPayloadKey("")
+	if err != nil {
+		return err
+	}
+	if key != nil {
+		if st.Cipher, err = store.NewPayloadCipher(key); err != nil {
+			return err
+		}
+	}
+
+	ctx := context.Background()
+	session, err := st.GetSession(ctx, *sessionID)
+	if err != nil {
+		return fmt.Errorf("session %s: %w", *sessionID, err)
+	}
+	entries, err := st.Query(ctx, store.QueryFilter{SessionID: *sessionID, Limit: goldenMaxMessages})
+	if err != nil {
+		return err
+	}
+	slices.Reverse(entries) // oldest first
+
+	g, warnings := BuildGolden(session, entries)
+	for _, w := range warnings {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", w)
+	}
+	if len(g.Steps) == 0 {
+		return fmt.Errorf("session %s has no host messages to replay", *sessionID)
+	}
+	g.Ignore = ignored
+
+	data, err := json.MarshalIndent(g, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if *out == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(*out, data, 0644); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Recorded %d messages from session %s to %s\n", len(g.Steps), *sessionID, *out)
+	return nil
+}
+
+func runGoldenCompare(args []string) error {
+	fs := flag.NewFlagSet("golden compare", flag.ContinueOnError)
+	ignore := fs.String("ignore", "", "comma-separated JSON Pointers to leave out, in addition to the golden file's")
+	timeout := fs.Duration("timeout", defaultGoldenTimeout, "how long to wait for each response")
+	asJSON := fs.Bool("json", false, "print the differences as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return printGoldenUsage()
+	}
+	extra, err := parseIgnorePaths(*ignore)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("read golden session: %w", err)
+	}
+	var g GoldenSession
+	if err := json.Unmarshal(data, &g); err != nil {
+		return fmt.Errorf("parse golden session: %w", err)
+	}
+
+	// The server under test defaults to the recorded command
+	command := append([]string{g.Command}, g.Args...)
+	if rest := fs.Args()[1:]; len(rest) > 0 {
+		if rest[0] == "--" {
+			rest = rest[1:]
+		}
+		command = rest
+	}
+	if len(command) == 0 || command[0] == "" {
+		return fmt.Errorf("no server command: give one after --")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("start server: %w", err)
+	}
+
+	got, replayErr := ReplayGolden(ctx, stdin, stdout, g.Steps, *timeout)
+	stdin.Close()
+	cancel()
+	cmd.Wait()
+	if replayErr != nil {
+		fmt.Fprintf(os.Stderr, "warning: replay stopped early: %v\n", replayErr)
+	}
+
+	diffs := CompareGolden(g.Steps, got, append(g.Ignore, extra...))
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if diffs == nil {
+			diffs = []GoldenDiff{}
+		}
+		enc.Encode(diffs)
+	} else {
+		WriteGoldenReport(os.Stdout, g.Steps, diffs)
+	}
+	if len(diffs) > 0 {
+		return fmt.Errorf("%d response(s) differ from the golden session", len(diffs))
+	}
+	return nil
+}
+
+// parseIgnorePaths splits a comma-separated list of JSON Pointers.
+func parseIgnorePaths(list string) ([]string, error) {
+	var paths []string
+	for _, p := range strings.Split(list, ",") {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+		if !strings.HasPrefix(p, "/") {
+			return nil, fmt.Errorf("ignore path %q must start with /", p)
+		}
+		paths = append(paths, p)
+	}
+	return paths, nil
+}
+
+func printGoldenUsage() error {
+	fmt.Fprintln(os.Stderr, "Usage:")
+	fmt.Fprintln(os.Stderr, "  contextgate golden record --session <id> [--db path] [--ignore ptrs] [-o file]")
+	fmt.Fprintln(os.Stderr, "  contextgate golden compare [--ignore ptrs] [--timeout dur] [--json] <golden.json> [-- command args...]")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "  record   Save a logged session's host messages and the server's replies as a golden session")
+	fmt.Fprintln(os.Stderr, "  compare  Replay the host messages against a server (default: the recorded command)")
+	fmt.Fprintln(os.Stderr, "           and report every response that differs")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Options:")
+	fmt.Fprintln(os.Stderr, "  --ignore ptrs  Comma-separated JSON Pointers into responses to leave out, e.g.")
+	fmt.Fprintln(os.Stderr, "                 /result/_meta/timestamp,/result/content/*/id (* matches any key or index)")
+	return fmt.Errorf("missing arguments")
+}
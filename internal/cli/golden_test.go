@@ -0,0 +1,162 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/contextgate/contextgate/internal/store"
+)
+
+func goldenStep(method, req, resp string) GoldenStep {
+	s := GoldenStep{Method: method, Request: json.RawMessage(req)}
+	if resp != "" {
+		s.Response = json.RawMessage(resp)
+	}
+	return s
+}
+
+func TestCompareGolden_IgnoresVolatileField(t *testing.T) {
+	steps := []GoldenStep{
+		goldenStep("initialize", `{"jsonrpc":"2.0","id":1,"method":"initialize"}`,
+			`{"jsonrpc":"2.0","id":1,"result":{"serverInfo":{"name":"fs","version":"1.0"}}}`),
+		goldenStep("notifications/initialized", `{"jsonrpc":"2.0","method":"notifications/initialized"}`, ""),
+		goldenStep("tools/call", `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"stat"}}`,
+			`{"jsonrpc":"2.0","id":2,"result":{"content":[{"type":"text","text":"4 KB","generated_at":"2026-01-01T00:00:00Z"}]}}`),
+	}
+	got := []json.RawMessage{
+		json.RawMessage(`{"jsonrpc":"2.0","id":1,"result":{"serverInfo":{"name":"fs","version":"1.0"}}}`),
+		nil,
+		json.RawMessage(`{"jsonrpc":"2.0","id":2,"result":{"content":[{"type":"text","text":"4 KB","generated_at":"2026-10-16T09:30:00Z"}]}}`),
+	}
+
+	if diffs := CompareGolden(steps, got, []string{"/result/content/*/generated_at"}); len(diffs) != 0 {
+		t.Errorf("ignored field reported as changed: %+v", diffs)
+	}
+
+	diffs := CompareGolden(steps, got, nil)
+	if len(diffs) != 1 || diffs[0].Step != 3 || diffs[0].Method != "tools/call" {
+		t.Fatalf("diffs = %+v, want only step 3", diffs)
+	}
+	if want := `/result/content/0/generated_at: "2026-01-01T00:00:00Z" → "2026-10-16T09:30:00Z"`; len(diffs[0].Changes) != 1 || diffs[0].Changes[0] != want {
+		t.Errorf("changes = %q, want [%q]", diffs[0].Changes, want)
+	}
+}
+
+func TestCompareGolden_ReportsChanges(t *testing.T) {
+	steps := []GoldenStep{
+		goldenStep("tools/list", `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`,
+			`{"jsonrpc":"2.0","id":1,"result":{"tools":[{"name":"read"},{"name":"write"}],"nextCursor":"a"}}`),
+		goldenStep("tools/call", `{"jsonrpc":"2.0","id":2,"method":"tools/call"}`,
+			`{"jsonrpc":"2.0","id":2,"result":{}}`),
+	}
+	got := []json.RawMessage{
+		json.RawMessage(`{"jsonrpc":"2.0","id":1,"result":{"tools":[{"name":"read"}],"extra":true}}`),
+		nil, // never answered
+	}
+
+	diffs := CompareGolden(steps, got, nil)
+	if len(diffs) != 2 {
+		t.Fatalf("got %d diffs, want 2: %+v", len(diffs), diffs)
+	}
+	want := []string{
+		`/result/extra: added true`,
+		`/result/nextCursor: removed (was "a")`,
+		`/result/tools/1: removed (was {"name":"write"})`,
+	}
+	if strings.Join(diffs[0].Changes, "\n") != strings.Join(want, "\n") {
+		t.Errorf("changes = %q\nwant %q", diffs[0].Changes, want)
+	}
+	if len(diffs[1].Changes) != 1 || diffs[1].Changes[0] != "no response" {
+		t.Errorf("unanswered step changes = %q", diffs[1].Changes)
+	}
+}
+
+func TestBuildGolden(t *testing.T) {
+	entries := []store.LogEntry{
+		{ID: 1, Direction: "host_to_server", Kind: "request", Method: "initialize", MsgID: "1", Payload: `{"jsonrpc":"2.0","id":1,"method":"initialize"}`},
+		{ID: 2, Direction: "server_to_host", Kind: "response", MsgID: "1", Payload: `{"jsonrpc":"2.0","id":1,"result":{}}`},
+		{ID: 3, Direction: "host_to_server", Kind: "notification", Method: "notifications/initialized", Payload: `{"jsonrpc":"2.0","method":"notifications/initialized"}`},
+		{ID: 4, Direction: "host_to_server", Kind: "request", Method: "tools/call", MsgID: "2", Blocked: true, Payload: `{"jsonrpc":"2.0","id":2,"method":"tools/call"}`},
+		{ID: 5, Direction: "host_to_server", Kind: "request", Method: "tools/call", MsgID: "3", Payload: `{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"x":"aaa…[truncated, 9000 bytes]`},
+	}
+
+	g, warnings := BuildGolden(&store.Session{ID: "s1", Command: "server", Args: []string{"-v"}}, entries)
+	if g.Command != "server" || len(g.Args) != 1 {
+		t.Errorf("command = %q %q", g.Command, g.Args)
+	}
+	if len(g.Steps) != 2 || g.Steps[0].Method != "initialize" || g.Steps[1].Method != "notifications/initialized" {
+		t.Fatalf("steps = %+v, want initialize and its notification", g.Steps)
+	}
+	if string(g.Steps[0].Response) != `{"jsonrpc":"2.0","id":1,"result":{}}` || g.Steps[1].Response != nil {
+		t.Errorf("responses = %s, %s", g.Steps[0].Response, g.Steps[1].Response)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "message 5") {
+		t.Errorf("warnings = %q, want one for the truncated payload", warnings)
+	}
+}
+
+// fakeServer answers each request on in with a result echoing its method,
+// first asking the host for its roots when told to.
+func fakeServer(t *testing.T, in io.Reader, out io.Writer) {
+	t.Helper()
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		var msg struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		json.Unmarshal(scanner.Bytes(), &msg)
+		if msg.ID == nil || msg.Method == "" {
+			continue // a notification or our own request's answer
+		}
+		if msg.Method == "tools/call" {
+			fmt.Fprintln(out, `{"jsonrpc":"2.0","id":"srv-1","method":"roots/list"}`)
+		}
+		fmt.Fprintf(out, `{"jsonrpc":"2.0","id":%s,"result":{"method":%q}}`+"\n", msg.ID, msg.Method)
+	}
+}
+
+func TestReplayGolden(t *testing.T) {
+	steps := []GoldenStep{
+		goldenStep("initialize", `{"jsonrpc":"2.0","id":1,"method":"initialize"}`, `{}`),
+		goldenStep("notifications/initialized", `{"jsonrpc":"2.0","method":"notifications/initialized"}`, ""),
+		goldenStep("tools/call", `{"jsonrpc":"2.0","id":2,"method":"tools/call"}`, `{}`),
+	}
+	// OS pipes, which buffer like a real server's stdio
+	toServer, serverIn, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromServer, serverOut, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		fakeServer(t, toServer, serverOut)
+		serverOut.Close()
+	}()
+	defer serverIn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	got, err := ReplayGolden(ctx, serverIn, fromServer, steps, time.Second)
+	if err != nil {
+		t.Fatalf("ReplayGolden: %v", err)
+	}
+	if string(got[0]) != `{"jsonrpc":"2.0","id":1,"result":{"method":"initialize"}}` {
+		t.Errorf("initialize reply = %s", got[0])
+	}
+	if got[1] != nil {
+		t.Errorf("notification got a reply: %s", got[1])
+	}
+	if string(got[2]) != `{"jsonrpc":"2.0","id":2,"result":{"method":"tools/call"}}` {
+		t.Errorf("tools/call reply = %s", got[2])
+	}
+}
@@ -0,0 +1,206 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/contextgate/contextgate/internal/policy"
+)
+
+// RunPolicy exports or imports a shareable policy bundle.
+//
+// Usage:
+//
+//	contextgate policy export [--policy file | --policy-csv file] [--prune-*] [-o file]
+//	contextgate policy import [--to path] <file|->
+//	contextgate policy test [--policy file | --policy-csv file] <spec.yaml>
+//	contextgate policy diff <old.yaml> <new.yaml>
+func RunPolicy(args []string, installedPath string) error {
+	if len(args) == 0 {
+		return printPolicyUsage()
+	}
+
+	switch args[0] {
+	case "export":
+		return runPolicyExport(args[1:], installedPath)
+	case "import":
+		return runPolicyImport(args[1:], installedPath)
+	case "test":
+		return runPolicyTest(args[1:], installedPath)
+	case "diff":
+		return runPolicyDiff(args[1:])
+	default:
+		return printPolicyUsage()
+	}
+}
+
+func runPolicyExport(args []string, installedPath string) error {
+	fs := flag.NewFlagSet("policy export", flag.ContinueOnError)
+	policyPath := fs.String("policy", "", "policy YAML file to export (default: the installed policy)")
+	policyCSV := fs.String("policy-csv", "", "CSV/TSV rules file to export")
+	pruneUnused := fs.Int("prune-unused", 0, "pruning default: tools unused in the last N sessions")
+	pruneKeepTop := fs.Int("prune-keep-top", 0, "pruning default: keep only the top K most-used tools")
+	pruneKeep := fs.String("prune-keep", "", "pruning default: comma-separated tools or globs never pruned")
+	out := fs.String("o", "", "write the bundle to this file instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var cfg *policy.Config
+	var err error
+	switch {
+	case *policyPath != "" && *policyCSV != "":
+		return fmt.Errorf("--policy and --policy-csv are mutually exclusive")
+	case *policyCSV != "":
+		cfg, err = policy.LoadCSV(*policyCSV)
+	case *policyPath != "":
+		cfg, err = policy.Load(*policyPath)
+	default:
+		cfg, err = policy.Load(installedPath)
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("no policy installed at %s; pass --policy or --policy-csv", installedPath)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	// Flags given here become the bundle's pruning defaults
+	if *pruneUnused > 0 {
+		cfg.Prune.UnusedSessions = *pruneUnused
+	}
+	if *pruneKeepTop > 0 {
+		cfg.Prune.KeepTop = *pruneKeepTop
+	}
+	if *pruneKeep != "" {
+		cfg.Prune.AlwaysKeep = nil
+		for _, item := range strings.Split(*pruneKeep, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				cfg.Prune.AlwaysKeep = append(cfg.Prune.AlwaysKeep, item)
+			}
+		}
+	}
+
+	data, err := cfg.Marshal()
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(*out, data, 0644); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d rules to %s\n", len(cfg.Rules), *out)
+	return nil
+}
+
+func runPolicyImport(args []string, installedPath string) error {
+	fs := flag.NewFlagSet("policy import", flag.ContinueOnError)
+	to := fs.String("to", installedPath, "where to install the policy")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return printPolicyUsage()
+	}
+
+	var data []byte
+	var err error
+	if src := fs.Arg(0); src == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(src)
+	}
+	if err != nil {
+		return fmt.Errorf("read policy bundle: %w", err)
+	}
+
+	cfg, err := policy.Install(data, *to)
+	if err != nil {
+		return fmt.Errorf("policy not installed: %w", err)
+	}
+	fmt.Printf("Installed %d rules to %s\n", len(cfg.Rules), *to)
+	if *to == installedPath {
+		fmt.Println("It is used by every proxy started without -policy, -policy-inline or -policy-csv.")
+	}
+	return nil
+}
+
+func runPolicyDiff(args []string) error {
+	if len(args) != 2 {
+		return printPolicyUsage()
+	}
+	old, err := policy.Load(args[0])
+	if err != nil {
+		return fmt.Errorf("%s: %w", args[0], err)
+	}
+	new, err := policy.Load(args[1])
+	if err != nil {
+		return fmt.Errorf("%s: %w", args[1], err)
+	}
+	writePolicyDiff(os.Stdout, policy.DiffConfigs(old, new))
+	return nil
+}
+
+// writePolicyDiff prints d as a review-friendly list: + for added rules,
+// - for removed ones and ~ for changed rules and scrubber settings.
+func writePolicyDiff(w io.Writer, d *policy.Diff) {
+	if d.Empty() {
+		fmt.Fprintln(w, "No rule or scrubber changes.")
+		return
+	}
+	if len(d.Added) > 0 || len(d.Removed) > 0 || len(d.Changed) > 0 || d.Reordered {
+		fmt.Fprintln(w, "Rules:")
+		for _, r := range d.Added {
+			fmt.Fprintf(w, "  + %s (%s)\n", r.Name, r.Action)
+		}
+		for _, r := range d.Removed {
+			fmt.Fprintf(w, "  - %s (%s)\n", r.Name, r.Action)
+		}
+		for _, rc := range d.Changed {
+			fmt.Fprintf(w, "  ~ %s\n", rc.Name)
+			writeFieldChanges(w, "      ", rc.Changes)
+		}
+		if d.Reordered {
+			fmt.Fprintln(w, "  rules kept in both files are in a different order")
+		}
+	}
+	if len(d.Scrubber) > 0 {
+		fmt.Fprintln(w, "Scrubber:")
+		writeFieldChanges(w, "  ~ ", d.Scrubber)
+	}
+}
+
+func writeFieldChanges(w io.Writer, indent string, changes []policy.FieldChange) {
+	for _, c := range changes {
+		fmt.Fprintf(w, "%s%s: %s -> %s\n", indent, c.Field, orUnset(c.Old), orUnset(c.New))
+	}
+}
+
+func orUnset(v string) string {
+	if v == "" {
+		return "(unset)"
+	}
+	return v
+}
+
+func printPolicyUsage() error {
+	fmt.Fprintln(os.Stderr, "Usage: contextgate policy export|import|test|diff")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "  export [--policy file | --policy-csv file] [-o file]")
+	fmt.Fprintln(os.Stderr, "      Write the policy (rules, scrubber, pruning defaults) as one YAML bundle.")
+	fmt.Fprintln(os.Stderr, "      --prune-unused, --prune-keep-top and --prune-keep set the pruning defaults.")
+	fmt.Fprintln(os.Stderr, "  import [--to path] <file|->")
+	fmt.Fprintln(os.Stderr, "      Validate a bundle and install it (default ~/.contextgate/policy.yaml).")
+	fmt.Fprintln(os.Stderr, "  test [--policy file | --policy-csv file] <spec.yaml>")
+	fmt.Fprintln(os.Stderr, "      Run a spec's messages through the policy and check the expected decisions.")
+	fmt.Fprintln(os.Stderr, "  diff <old.yaml> <new.yaml>")
+	fmt.Fprintln(os.Stderr, "      List rules added, removed or changed (by name) and scrubber changes.")
+	return fmt.Errorf("missing arguments")
+}
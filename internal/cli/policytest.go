@@ -0,0 +1,259 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/contextgate/contextgate/internal/policy"
+	"github.com/contextgate/contextgate/internal/proxy"
+)
+
+// Outcomes a policy test case can expect, besides the policy actions
+// require_approval, quarantine and audit.
+const (
+	OutcomeAllow = "allow" // forwarded with no rule action
+	OutcomeDeny  = "deny"  // blocked with an error to the sender
+	OutcomeDrop  = "drop"  // discarded silently
+)
+
+// PolicySpec is a policy regression spec: messages and the decisions the
+// policy must make about them.
+type PolicySpec struct {
+	// Policy is the policy file under test, relative to the spec file.
+	// The --policy flag overrides it.
+	Policy string       `yaml:"policy,omitempty"`
+	Cases  []PolicyCase `yaml:"cases"`
+}
+
+// PolicyCase is one message and its expected outcome.
+type PolicyCase struct {
+	Name string `yaml:"name"`
+
+	// Direction is host_to_server (the default) or server_to_host.
+	Direction string `yaml:"direction,omitempty"`
+
+	// Message is the JSON-RPC message, either as a JSON string (e.g. a
+	// line copied from a captured session) or as a YAML mapping.
+	Message any `yaml:"message"`
+
+	Expect PolicyExpect `yaml:"expect"`
+}
+
+// PolicyExpect is what a case must produce. Rules and Reason are only
+// checked when set.
+type PolicyExpect struct {
+	// Action is allow, deny, drop, require_approval, quarantine or audit.
+	Action string `yaml:"action"`
+
+	// Rules lists the names of every rule that must match, in policy order.
+	Rules []string `yaml:"rules,omitempty"`
+
+	// Reason must be contained in the error a denied message is answered with.
+	Reason string `yaml:"reason,omitempty"`
+}
+
+// CaseResult is the outcome of running one case.
+type CaseResult struct {
+	Name  string
+	Diffs []string // empty when the case passed
+}
+
+// Passed reports whether the case produced what it expected.
+func (r CaseResult) Passed() bool {
+	return len(r.Diffs) == 0
+}
+
+// LoadPolicySpec reads a spec file.
+func LoadPolicySpec(path string) (*PolicySpec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read spec: %w", err)
+	}
+	var spec PolicySpec
+	if err := yaml.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("parse spec: %w", err)
+	}
+	if len(spec.Cases) == 0 {
+		return nil, fmt.Errorf("spec %s has no cases", path)
+	}
+	for i, c := range spec.Cases {
+		switch c.Expect.Action {
+		case OutcomeAllow, OutcomeDeny, OutcomeDrop, string(policy.ActionRequireApproval), string(policy.ActionAudit),
+			string(policy.ActionQuarantine):
+		default:
+			return nil, fmt.Errorf("case %d (%s): unknown expected action %q", i+1, c.Name, c.Expect.Action)
+		}
+		switch proxy.Direction(c.Direction) {
+		case "", proxy.DirHostToServer, proxy.DirServerToHost:
+		default:
+			return nil, fmt.Errorf("case %d (%s): unknown direction %q", i+1, c.Name, c.Direction)
+		}
+	}
+	if spec.Policy != "" && !filepath.IsAbs(spec.Policy) {
+		spec.Policy = filepath.Join(filepath.Dir(path), spec.Policy)
+	}
+	return &spec, nil
+}
+
+// RunPolicySpec runs every case through the policy stage of the proxy's
+// interceptor chain and compares the outcome with the expectation.
+func RunPolicySpec(cfg *policy.Config, spec *PolicySpec) []CaseResult {
+	chain := proxy.NewInterceptorChain(proxy.NewPolicyInterceptor(policy.NewEngine(cfg)))
+
+	results := make([]CaseResult, 0, len(spec.Cases))
+	for i, c := range spec.Cases {
+		name := c.Name
+		if name == "" {
+			name = fmt.Sprintf("case %d", i+1)
+		}
+		results = append(results, CaseResult{Name: name, Diffs: runPolicyCase(chain, c)})
+	}
+	return results
+}
+
+func runPolicyCase(chain *proxy.InterceptorChain, c PolicyCase) []string {
+	raw, err := caseMessage(c.Message)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	dir := proxy.Direction(c.Direction)
+	if dir == "" {
+		dir = proxy.DirHostToServer
+	}
+	parsed, parseErr := proxy.ParseMessage(raw)
+	if parseErr != nil {
+		return []string{fmt.Sprintf("message is not JSON-RPC: %v", parseErr)}
+	}
+	msg := &proxy.InterceptedMessage{
+		Timestamp: time.Now(),
+		SessionID: "policy-test",
+		Direction: dir,
+		RawBytes:  raw,
+		Parsed:    parsed,
+	}
+
+	out, blockErr := chain.Process(context.Background(), msg)
+	action := OutcomeAllow
+	switch {
+	case blockErr != nil:
+		action = OutcomeDeny
+	case out == nil:
+		action = OutcomeDrop
+	default:
+		if a, _ := msg.Metadata[proxy.MetaKeyPolicyAction].(string); a != "" {
+			action = a
+		}
+	}
+	rules, _ := msg.Metadata[proxy.MetaKeyMatchedRules].([]string)
+
+	var diffs []string
+	if action != c.Expect.Action {
+		diffs = append(diffs, fmt.Sprintf("action: got %s, want %s", action, c.Expect.Action))
+	}
+	if c.Expect.Rules != nil && !slices.Equal(rules, c.Expect.Rules) {
+		diffs = append(diffs, fmt.Sprintf("rules: got %s, want %s", formatList(rules), formatList(c.Expect.Rules)))
+	}
+	if c.Expect.Reason != "" {
+		reason := ""
+		if blockErr != nil {
+			reason = blockErr.Error()
+		}
+		if !strings.Contains(reason, c.Expect.Reason) {
+			diffs = append(diffs, fmt.Sprintf("reason: got %q, want it to contain %q", reason, c.Expect.Reason))
+		}
+	}
+	return diffs
+}
+
+// caseMessage encodes a case's message as a single JSON line.
+func caseMessage(m any) ([]byte, error) {
+	switch v := m.(type) {
+	case nil:
+		return nil, fmt.Errorf("case has no message")
+	case string:
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, []byte(v)); err != nil {
+			return nil, fmt.Errorf("message is not valid JSON: %w", err)
+		}
+		return buf.Bytes(), nil
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("encode message: %w", err)
+		}
+		return data, nil
+	}
+}
+
+func formatList(items []string) string {
+	return "[" + strings.Join(items, ", ") + "]"
+}
+
+// WritePolicyReport prints one line per case, with the differences of
+// failed cases, and a summary. It returns the number of failures.
+func WritePolicyReport(w io.Writer, results []CaseResult) int {
+	failed := 0
+	for _, r := range results {
+		if r.Passed() {
+			fmt.Fprintf(w, "PASS  %s\n", r.Name)
+			continue
+		}
+		failed++
+		fmt.Fprintf(w, "FAIL  %s\n", r.Name)
+		for _, d := range r.Diffs {
+			fmt.Fprintf(w, "        %s\n", d)
+		}
+	}
+	fmt.Fprintf(w, "\n%d passed, %d failed\n", len(results)-failed, failed)
+	return failed
+}
+
+func runPolicyTest(args []string, installedPath string) error {
+	fs := flag.NewFlagSet("policy test", flag.ContinueOnError)
+	policyPath := fs.String("policy", "", "policy YAML file to test (default: the spec's policy, then the installed policy)")
+	policyCSV := fs.String("policy-csv", "", "CSV/TSV rules file to test")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return printPolicyUsage()
+	}
+
+	spec, err := LoadPolicySpec(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	var cfg *policy.Config
+	switch {
+	case *policyPath != "" && *policyCSV != "":
+		return fmt.Errorf("--policy and --policy-csv are mutually exclusive")
+	case *policyCSV != "":
+		cfg, err = policy.LoadCSV(*policyCSV)
+	case *policyPath != "":
+		cfg, err = policy.Load(*policyPath)
+	case spec.Policy != "":
+		cfg, err = policy.Load(spec.Policy)
+	default:
+		cfg, err = policy.Load(installedPath)
+	}
+	if err != nil {
+		return err
+	}
+
+	if failed := WritePolicyReport(os.Stdout, RunPolicySpec(cfg, spec)); failed > 0 {
+		return fmt.Errorf("%d policy test case(s) failed", failed)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/contextgate/contextgate/internal/policy"
+)
+
+const testPolicy = `
+version: "1"
+rules:
+  - name: no-shell
+    action: deny
+    methods: ["tools/call"]
+    tools: ["run_shell"]
+    message: "shell access is disabled"
+  - name: review-deletes
+    action: require_approval
+    methods: ["tools/call"]
+    tools: ["delete_file"]
+  - name: audit-calls
+    action: audit
+    methods: ["tools/call"]
+`
+
+// writeSpec writes the test policy and a spec with the given cases next
+// to each other and returns the spec path.
+func writeSpec(t *testing.T, cases string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "policy.yaml"), []byte(testPolicy), 0644); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "spec.yaml")
+	if err := os.WriteFile(path, []byte("policy: policy.yaml\ncases:\n"+cases), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func runSpec(t *testing.T, path string) []CaseResult {
+	t.Helper()
+	spec, err := LoadPolicySpec(path)
+	if err != nil {
+		t.Fatalf("LoadPolicySpec: %v", err)
+	}
+	cfg, err := policy.Load(spec.Policy)
+	if err != nil {
+		t.Fatalf("load policy: %v", err)
+	}
+	return RunPolicySpec(cfg, spec)
+}
+
+func TestRunPolicySpec_Passing(t *testing.T) {
+	path := writeSpec(t, `
+  - name: shell is denied
+    message: '{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"run_shell"}}'
+    expect:
+      action: deny
+      rules: [no-shell, audit-calls]
+      reason: disabled
+  - name: deletes need approval
+    message:
+      jsonrpc: "2.0"
+      id: 2
+      method: tools/call
+      params: {name: delete_file, arguments: {path: /tmp/x}}
+    expect: {action: require_approval}
+  - name: reads are audited
+    message: {jsonrpc: "2.0", id: 3, method: tools/call, params: {name: read_file}}
+    expect: {action: audit, rules: [audit-calls]}
+  - name: listing is allowed
+    message: {jsonrpc: "2.0", id: 4, method: tools/list}
+    expect: {action: allow, rules: []}
+  - name: responses are allowed
+    direction: server_to_host
+    message: {jsonrpc: "2.0", id: 4, result: {}}
+    expect: {action: allow}
+`)
+	results := runSpec(t, path)
+	var out bytes.Buffer
+	if failed := WritePolicyReport(&out, results); failed != 0 {
+		t.Fatalf("%d cases failed:\n%s", failed, out.String())
+	}
+	if !strings.Contains(out.String(), "5 passed, 0 failed") {
+		t.Errorf("summary missing:\n%s", out.String())
+	}
+}
+
+func TestRunPolicySpec_Failing(t *testing.T) {
+	path := writeSpec(t, `
+  - name: shell expected allowed
+    message: {jsonrpc: "2.0", id: 1, method: tools/call, params: {name: run_shell}}
+    expect: {action: allow, reason: "not permitted"}
+  - name: wrong rules
+    message: {jsonrpc: "2.0", id: 2, method: tools/call, params: {name: read_file}}
+    expect: {action: audit, rules: [read-audit]}
+  - name: still passes
+    message: {jsonrpc: "2.0", id: 3, method: tools/list}
+    expect: {action: allow}
+`)
+	results := runSpec(t, path)
+	var out bytes.Buffer
+	if failed := WritePolicyReport(&out, results); failed != 2 {
+		t.Fatalf("failed = %d, want 2:\n%s", failed, out.String())
+	}
+
+	report := out.String()
+	for _, want := range []string{
+		"FAIL  shell expected allowed",
+		"action: got deny, want allow",
+		`reason: got "shell access is disabled", want it to contain "not permitted"`,
+		"FAIL  wrong rules",
+		"rules: got [audit-calls], want [read-audit]",
+		"PASS  still passes",
+		"1 passed, 2 failed",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report missing %q:\n%s", want, report)
+		}
+	}
+}
+
+func TestLoadPolicySpec_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"unknown action":    "  - {name: x, message: {}, expect: {action: block}}\n",
+		"unknown direction": "  - {name: x, direction: sideways, message: {}, expect: {action: allow}}\n",
+		"no cases":          "",
+	}
+	for name, cases := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := LoadPolicySpec(writeSpec(t, cases)); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
@@ -10,7 +10,14 @@ import (
 )
 
 // RunSetup runs the interactive setup wizard.
-func RunSetup() error {
+//
+// Usage: contextgate setup [--allow-commands list [--strict-commands]]
+func RunSetup(args []string) error {
+	var allow CommandAllowlist
+	for i := range args {
+		allow.parseFlag(args, i)
+	}
+
 	fmt.Println("ContextGate Setup")
 	fmt.Println("=================")
 	fmt.Println()
@@ -53,11 +60,11 @@ func RunSetup() error {
 				fmt.Printf("  Error: %v\n\n", err)
 			}
 		case "claude-desktop":
-			if err := setupConfigFile(reader, c, gateBinary); err != nil {
+			if err := setupConfigFile(reader, c, gateBinary, allow); err != nil {
 				fmt.Printf("  Error: %v\n\n", err)
 			}
 		case "cursor":
-			if err := setupConfigFile(reader, c, gateBinary); err != nil {
+			if err := setupConfigFile(reader, c, gateBinary, allow); err != nil {
 				fmt.Printf("  Error: %v\n\n", err)
 			}
 		}
@@ -135,6 +142,7 @@ func addDemoServer(gateBinary string) error {
 		"--",
 		gateBinary,
 		"--dashboard", ":9000",
+		"--client-id", "claude-code",
 		"--", "npx", "-y", "@modelcontextprotocol/server-filesystem", home,
 	)
 	cmd.Stdout = os.Stdout
@@ -147,7 +155,7 @@ func addDemoServer(gateBinary string) error {
 	return nil
 }
 
-func setupConfigFile(reader *bufio.Reader, client MCPClient, gateBinary string) error {
+func setupConfigFile(reader *bufio.Reader, client MCPClient, gateBinary string, allow CommandAllowlist) error {
 	fmt.Printf("--- %s ---\n", client.Name)
 	fmt.Println()
 
@@ -175,9 +183,15 @@ func setupConfigFile(reader *bufio.Reader, client MCPClient, gateBinary string)
 	unwrapped := 0
 	for i, s := range servers {
 		wrapped := ""
-		if isContextGateWrapped(s.Command, s.Args) {
+		switch {
+		case isContextGateWrapped(s.Command, s.Args):
 			wrapped = " (already wrapped)"
-		} else {
+		case !allow.Allows(s.Command):
+			wrapped = " (not on the command allowlist)"
+			if !allow.Strict {
+				unwrapped++
+			}
+		default:
 			unwrapped++
 		}
 		fmt.Printf("    %d. %s → %s %s%s\n", i+1, s.Name, s.Command, strings.Join(s.Args, " "), wrapped)
@@ -214,7 +228,7 @@ func setupConfigFile(reader *bufio.Reader, client MCPClient, gateBinary string)
 		}
 	}
 
-	count, err := WrapConfigFile(client.ConfigPath, gateBinary, port)
+	count, err := WrapConfigFile(client.ConfigPath, gateBinary, port, client.Kind, allow)
 	if err != nil {
 		return fmt.Errorf("failed to wrap config: %w", err)
 	}
@@ -9,7 +9,7 @@ import (
 
 // RunWrap registers an MCP server wrapped with contextgate into Claude Code.
 //
-// Usage: contextgate wrap <name> [--scope user|project] -- <command> [args...]
+// Usage: contextgate wrap <name> [--scope user|project] [--allow-commands list [--strict-commands]] -- <command> [args...]
 func RunWrap(args []string) error {
 	if len(args) == 0 {
 		return printWrapUsage()
@@ -20,6 +20,7 @@ func RunWrap(args []string) error {
 
 	// Parse optional --scope
 	scope := "user"
+	var allow CommandAllowlist
 	var cmdArgs []string
 	foundSep := false
 	for i, a := range rest {
@@ -31,12 +32,17 @@ func RunWrap(args []string) error {
 		if a == "--scope" && i+1 < len(rest) {
 			scope = rest[i+1]
 		}
+		allow.parseFlag(rest, i)
 	}
 
 	if !foundSep || len(cmdArgs) == 0 {
 		return printWrapUsage()
 	}
 
+	if err := allow.Check(cmdArgs[0], os.Stderr); err != nil {
+		return err
+	}
+
 	// Check claude CLI is available
 	if _, err := exec.LookPath("claude"); err != nil {
 		return fmt.Errorf("'claude' CLI not found in PATH. Install Claude Code first: https://docs.anthropic.com/en/docs/claude-code")
@@ -44,7 +50,7 @@ func RunWrap(args []string) error {
 
 	gateBinary := SelfPath()
 
-	// Build: claude mcp add --transport stdio --scope <scope> <name> -- contextgate --dashboard :9000 -- <command> <args...>
+	// Build: claude mcp add --transport stdio --scope <scope> <name> -- contextgate --dashboard :9000 --client-id claude-code -- <command> <args...>
 	claudeArgs := []string{
 		"mcp", "add",
 		"--transport", "stdio",
@@ -53,6 +59,7 @@ func RunWrap(args []string) error {
 		"--",
 		gateBinary,
 		"--dashboard", ":9000",
+		"--client-id", "claude-code",
 		"--",
 	}
 	claudeArgs = append(claudeArgs, cmdArgs...)
@@ -74,7 +81,7 @@ func RunWrap(args []string) error {
 }
 
 func printWrapUsage() error {
-	fmt.Fprintln(os.Stderr, "Usage: contextgate wrap <name> [--scope user|project] -- <command> [args...]")
+	fmt.Fprintln(os.Stderr, "Usage: contextgate wrap <name> [--scope user|project] [--allow-commands list] -- <command> [args...]")
 	fmt.Fprintln(os.Stderr, "")
 	fmt.Fprintln(os.Stderr, "Registers an MCP server in Claude Code, wrapped with ContextGate.")
 	fmt.Fprintln(os.Stderr, "")
@@ -85,5 +92,7 @@ func printWrapUsage() error {
 	fmt.Fprintln(os.Stderr, "Options:")
 	fmt.Fprintln(os.Stderr, "  --scope user     Available in all projects (default)")
 	fmt.Fprintln(os.Stderr, "  --scope project  Only for this project")
+	fmt.Fprintln(os.Stderr, "  --allow-commands list  Comma-separated commands or globs that may be wrapped (default: any)")
+	fmt.Fprintln(os.Stderr, "  --strict-commands      Refuse commands not on the allowlist instead of warning")
 	return fmt.Errorf("missing arguments")
 }
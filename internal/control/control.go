@@ -0,0 +1,232 @@
+// Package control serves a local control API for a running proxy over a
+// Unix domain socket, so scripts and other tools can query and steer it
+// without the HTTP dashboard.
+//
+// The protocol is line-delimited JSON: each line a client writes is one
+// request, answered by one response line.
+//
+//	{"cmd": "stats"}
+//	{"ok": true, "result": {"session_id": "...", "paused": false, ...}}
+//
+//	{"cmd": "approve", "id": "apr-3"}
+//	{"ok": false, "error": "approval request \"apr-3\" not found or already resolved"}
+//
+// Commands are stats, approvals, approve, deny, cancel (each of the last
+// three taking the approval "id"), pause and resume.
+package control
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/contextgate/contextgate/internal/proxy"
+	"github.com/contextgate/contextgate/internal/store"
+)
+
+// maxRequestBytes bounds a single request line.
+const maxRequestBytes = 64 << 10
+
+// Pauser is the part of the proxy the pause and resume commands drive.
+type Pauser interface {
+	Pause() bool
+	Resume() bool
+	Paused() bool
+}
+
+// Request is one control command.
+type Request struct {
+	Cmd string `json:"cmd"`
+	ID  string `json:"id,omitempty"` // approval ID for approve, deny and cancel
+}
+
+// Response answers a Request. Result is set when OK is true, Error otherwise.
+type Response struct {
+	OK     bool   `json:"ok"`
+	Result any    `json:"result,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// StatsResult is the result of the stats command.
+type StatsResult struct {
+	SessionID        string       `json:"session_id"`
+	Paused           bool         `json:"paused"`
+	PendingApprovals int          `json:"pending_approvals"`
+	Messages         *store.Stats `json:"messages"`
+}
+
+// Server serves the control API on a Unix socket.
+type Server struct {
+	// Approvals, if set, enables the approval commands.
+	Approvals *proxy.ApprovalManager
+
+	// Proxy, if set, enables pause and resume.
+	Proxy Pauser
+
+	path      string
+	store     store.Store
+	sessionID string
+	logger    *slog.Logger
+}
+
+// NewServer creates a control server that will listen on the socket at
+// path and report stats for sessionID from s.
+func NewServer(path string, s store.Store, sessionID string, logger *slog.Logger) *Server {
+	return &Server{path: path, store: s, sessionID: sessionID, logger: logger}
+}
+
+// Start listens on the socket and serves connections until ctx is
+// cancelled, then removes the socket file. A stale socket left by an
+// earlier run is replaced; any other file at the path is an error.
+func (s *Server) Start(ctx context.Context) error {
+	if fi, err := os.Lstat(s.path); err == nil {
+		if fi.Mode().Type() != fs.ModeSocket {
+			return fmt.Errorf("control socket %s: file exists and is not a socket", s.path)
+		}
+		os.Remove(s.path)
+	}
+
+	ln, err := net.Listen("unix", s.path)
+	if err != nil {
+		return fmt.Errorf("control socket: %w", err)
+	}
+	// Anyone who can connect can approve requests
+	if err := os.Chmod(s.path, 0o600); err != nil {
+		ln.Close()
+		return fmt.Errorf("control socket: %w", err)
+	}
+	s.logger.Info("control socket listening", "path", s.path)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	conns := make(map[net.Conn]struct{})
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+		mu.Lock()
+		for c := range conns {
+			c.Close()
+		}
+		mu.Unlock()
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			wg.Wait()
+			if ctx.Err() != nil {
+				return nil // the listener removes the socket file on close
+			}
+			return fmt.Errorf("control socket: %w", err)
+		}
+		mu.Lock()
+		conns[conn] = struct{}{}
+		mu.Unlock()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.serveConn(ctx, conn)
+			mu.Lock()
+			delete(conns, conn)
+			mu.Unlock()
+		}()
+	}
+}
+
+// serveConn answers requests on conn until the client disconnects.
+func (s *Server) serveConn(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, 4096), maxRequestBytes)
+	enc := json.NewEncoder(conn)
+	for scanner.Scan() {
+		var req Request
+		var resp Response
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			resp = Response{Error: "invalid request: " + err.Error()}
+		} else {
+			resp = s.Handle(ctx, req)
+		}
+		if err := enc.Encode(resp); err != nil {
+			return
+		}
+	}
+	if err := scanner.Err(); err != nil && !errors.Is(err, net.ErrClosed) {
+		s.logger.Debug("control connection ended", "error", err)
+	}
+}
+
+// Handle runs one command.
+func (s *Server) Handle(ctx context.Context, req Request) Response {
+	result, err := s.handle(ctx, req)
+	if err != nil {
+		return Response{Error: err.Error()}
+	}
+	return Response{OK: true, Result: result}
+}
+
+func (s *Server) handle(ctx context.Context, req Request) (any, error) {
+	switch req.Cmd {
+	case "stats":
+		stats, err := s.store.Stats(ctx, s.sessionID)
+		if err != nil {
+			return nil, err
+		}
+		res := StatsResult{SessionID: s.sessionID, Messages: stats}
+		if s.Proxy != nil {
+			res.Paused = s.Proxy.Paused()
+		}
+		if s.Approvals != nil {
+			res.PendingApprovals = s.Approvals.PendingCount()
+		}
+		return res, nil
+
+	case "approvals":
+		if s.Approvals == nil {
+			return []*proxy.ApprovalRequest{}, nil
+		}
+		return s.Approvals.Pending(), nil
+
+	case "approve", "deny", "cancel":
+		if s.Approvals == nil {
+			return nil, errors.New("approval not enabled")
+		}
+		if req.ID == "" {
+			return nil, fmt.Errorf("%s: id is required", req.Cmd)
+		}
+		var err error
+		switch req.Cmd {
+		case "approve":
+			err = s.Approvals.Resolve(req.ID, true)
+		case "deny":
+			err = s.Approvals.Resolve(req.ID, false)
+		default:
+			err = s.Approvals.Cancel(req.ID)
+		}
+		if err != nil {
+			return nil, err
+		}
+		s.logger.Info("approval resolved over control socket", "id", req.ID, "command", req.Cmd)
+		return map[string]string{"id": req.ID}, nil
+
+	case "pause", "resume":
+		if s.Proxy == nil {
+			return nil, errors.New("pause not supported")
+		}
+		changed := false
+		if req.Cmd == "pause" {
+			changed = s.Proxy.Pause()
+		} else {
+			changed = s.Proxy.Resume()
+		}
+		return map[string]bool{"paused": s.Proxy.Paused(), "changed": changed}, nil
+	}
+	return nil, fmt.Errorf("unknown command %q", req.Cmd)
+}
@@ -0,0 +1,152 @@
+package control
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/contextgate/contextgate/internal/proxy"
+	"github.com/contextgate/contextgate/internal/store"
+)
+
+type fakePauser struct{ paused atomic.Bool }
+
+func (f *fakePauser) Pause() bool  { return !f.paused.Swap(true) }
+func (f *fakePauser) Resume() bool { return f.paused.Swap(false) }
+func (f *fakePauser) Paused() bool { return f.paused.Load() }
+
+// startServer runs a control server on a fresh socket and returns a
+// function issuing one request over a shared connection.
+func startServer(t *testing.T, srv *Server) func(req string) Response {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- srv.Start(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Start: %v", err)
+		}
+		if _, err := os.Stat(srv.path); !os.IsNotExist(err) {
+			t.Errorf("socket file left behind: %v", err)
+		}
+	})
+
+	var conn net.Conn
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		var err error
+		if conn, err = net.Dial("unix", srv.path); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("dial: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	r := bufio.NewReader(conn)
+	return func(req string) Response {
+		t.Helper()
+		if _, err := conn.Write([]byte(req + "\n")); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		line, err := r.ReadBytes('\n')
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		var resp Response
+		if err := json.Unmarshal(line, &resp); err != nil {
+			t.Fatalf("decode %q: %v", line, err)
+		}
+		return resp
+	}
+}
+
+func TestControlSocket(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	dir := t.TempDir()
+	st, err := store.NewSQLiteStore(filepath.Join(dir, "test.db"), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	for i := range 3 {
+		st.LogMessage(context.Background(), &store.LogEntry{Timestamp: time.Now(), SessionID: "s1",
+			Direction: "host_to_server", Kind: "request", Method: "tools/call", MsgID: string(rune('1' + i)), Payload: "{}"})
+	}
+	st.Flush()
+
+	srv := NewServer(filepath.Join(dir, "control.sock"), st, "s1", logger)
+	srv.Approvals = proxy.NewApprovalManager(10 * time.Second)
+	pauser := &fakePauser{}
+	srv.Proxy = pauser
+	call := startServer(t, srv)
+
+	resp := call(`{"cmd":"stats"}`)
+	var stats StatsResult
+	raw, _ := json.Marshal(resp.Result)
+	json.Unmarshal(raw, &stats)
+	if !resp.OK || stats.SessionID != "s1" || stats.Messages.TotalMessages != 3 {
+		t.Errorf("stats = %+v (%s)", resp, raw)
+	}
+
+	decision := srv.Approvals.Submit(&proxy.ApprovalRequest{SessionID: "s1", Method: "tools/call", ToolName: "delete_file"})
+	var pending []proxy.ApprovalRequest
+	raw, _ = json.Marshal(call(`{"cmd":"approvals"}`).Result)
+	json.Unmarshal(raw, &pending)
+	if len(pending) != 1 {
+		t.Fatalf("approvals = %s, want one", raw)
+	}
+
+	if resp := call(`{"cmd":"approve","id":"` + pending[0].ID + `"}`); !resp.OK {
+		t.Fatalf("approve: %+v", resp)
+	}
+	select {
+	case d := <-decision:
+		if d != proxy.DecisionApproved {
+			t.Errorf("decision = %v, want approved", d)
+		}
+	default:
+		t.Error("approval was not resolved")
+	}
+	if resp := call(`{"cmd":"approve","id":"` + pending[0].ID + `"}`); resp.OK || resp.Error == "" {
+		t.Errorf("approving twice: %+v", resp)
+	}
+
+	if resp := call(`{"cmd":"pause"}`); !resp.OK || !pauser.Paused() {
+		t.Errorf("pause: %+v", resp)
+	}
+	if resp := call(`{"cmd":"resume"}`); !resp.OK || pauser.Paused() {
+		t.Errorf("resume: %+v", resp)
+	}
+
+	if resp := call(`{"cmd":"reboot"}`); resp.OK {
+		t.Errorf("unknown command accepted: %+v", resp)
+	}
+	if resp := call(`not json`); resp.OK {
+		t.Errorf("malformed request accepted: %+v", resp)
+	}
+}
+
+func TestControlSocket_RefusesNonSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "control.sock")
+	os.WriteFile(path, []byte("keep me"), 0o600)
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+
+	err := NewServer(path, nil, "s1", logger).Start(context.Background())
+	if err == nil {
+		t.Fatal("Start replaced a regular file")
+	}
+	if b, _ := os.ReadFile(path); string(b) != "keep me" {
+		t.Error("regular file was modified")
+	}
+}
@@ -0,0 +1,70 @@
+package dashboard
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// requireAdmin guards a destructive endpoint. It is only served when
+// AdminToken is set, to requests presenting it as a bearer token.
+func (s *Server) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.AdminToken == "" {
+			http.Error(w, "admin endpoints are disabled; start with -dashboard-admin-token", http.StatusForbidden)
+			return
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.AdminToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="contextgate"`)
+			http.Error(w, "invalid admin token", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// clearResult reports what an admin clear deleted.
+type clearResult struct {
+	Scope    string `json:"scope"`
+	Messages int64  `json:"messages_deleted"`
+	Tools    int64  `json:"tools_deleted"`
+}
+
+// handleAdminClear permanently deletes logged messages (with approval
+// records), the tool registry, or both, as chosen by ?scope=.
+func (s *Server) handleAdminClear(w http.ResponseWriter, r *http.Request) {
+	scope := r.URL.Query().Get("scope")
+	clearMessages := scope == "messages" || scope == "all"
+	clearTools := scope == "tools" || scope == "all"
+	if !clearMessages && !clearTools {
+		http.Error(w, "scope must be messages, tools or all", http.StatusBadRequest)
+		return
+	}
+
+	st := s.storeFor(r)
+	result := clearResult{Scope: scope}
+	var err error
+	if clearMessages {
+		if result.Messages, err = st.ClearMessages(r.Context()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	if clearTools {
+		if result.Tools, err = st.ClearToolRegistry(r.Context()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	s.logger.Warn("dashboard cleared stored data",
+		"scope", scope,
+		"messages_deleted", result.Messages,
+		"tools_deleted", result.Tools,
+		"remote", r.RemoteAddr,
+	)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
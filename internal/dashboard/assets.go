@@ -0,0 +1,73 @@
+package dashboard
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+)
+
+// SetAssetsDir serves static files and templates from dir where it has
+// them, falling back to the embedded ones. dir mirrors the embedded
+// layout: static/style.css, templates/index.html,
+// templates/partials/stats.html and so on. Templates are parsed again,
+// so a broken override is reported here rather than on first use.
+func (s *Server) SetAssetsDir(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("dashboard assets: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("dashboard assets: %s is not a directory", dir)
+	}
+	assets := overlayFS{upper: os.DirFS(dir), lower: embeddedAssets}
+	tmpl, err := parseTemplates(assets)
+	if err != nil {
+		return fmt.Errorf("dashboard assets: %w", err)
+	}
+	s.assets, s.tmpl = assets, tmpl
+	return nil
+}
+
+// overlayFS serves files from upper, falling back to lower for those
+// upper doesn't have. Directories list the entries of both.
+type overlayFS struct {
+	upper, lower fs.FS
+}
+
+func (o overlayFS) Open(name string) (fs.File, error) {
+	f, err := o.upper.Open(name)
+	if err != nil {
+		return o.lower.Open(name)
+	}
+	info, err := f.Stat()
+	if err != nil || !info.IsDir() {
+		return f, err
+	}
+	// A directory in both is opened from lower; ReadDir merges them
+	if lf, err := o.lower.Open(name); err == nil {
+		f.Close()
+		return lf, nil
+	}
+	return f, nil
+}
+
+func (o overlayFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	upper, uerr := fs.ReadDir(o.upper, name)
+	lower, lerr := fs.ReadDir(o.lower, name)
+	if uerr != nil && lerr != nil {
+		return nil, lerr
+	}
+	seen := make(map[string]bool, len(upper))
+	entries := upper
+	for _, e := range upper {
+		seen[e.Name()] = true
+	}
+	for _, e := range lower {
+		if !seen[e.Name()] {
+			entries = append(entries, e)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
@@ -0,0 +1,86 @@
+package dashboard
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeAsset(t *testing.T, dir, name, content string) {
+	t.Helper()
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSetAssetsDir_Overlay(t *testing.T) {
+	srv, _ := newTestServer(t)
+	dir := t.TempDir()
+	writeAsset(t, dir, "static/style.css", "body { background: hotpink; }")
+	writeAsset(t, dir, "static/logo.svg", "<svg></svg>")
+	writeAsset(t, dir, "templates/partials/tool_conflicts.html", `{{define "tool_conflicts.html"}}<p>custom conflicts</p>{{end}}`)
+	if err := srv.SetAssetsDir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(path string) string {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		srv.routes().ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != 200 {
+			t.Fatalf("GET %s: status %d", path, rec.Code)
+		}
+		return rec.Body.String()
+	}
+
+	// Overridden and added files come from the directory
+	if body := get("/static/style.css"); body != "body { background: hotpink; }" {
+		t.Errorf("style.css = %q, want the override", body)
+	}
+	if body := get("/static/logo.svg"); body != "<svg></svg>" {
+		t.Errorf("logo.svg = %q", body)
+	}
+	if body := get("/partials/tool-conflicts"); !strings.Contains(body, "custom conflicts") {
+		t.Errorf("tool conflicts partial = %q, want the override", body)
+	}
+
+	// Everything else is still embedded
+	embedded, err := embeddedAssets.ReadFile("static/sse.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body := get("/static/sse.js"); body != string(embedded) {
+		t.Error("sse.js not served from the embedded assets")
+	}
+	if body := get("/partials/tool-timeline"); strings.Contains(body, "custom") || body == "" {
+		t.Errorf("tool timeline partial = %q, want the embedded template", body)
+	}
+	if body := get("/"); !strings.Contains(body, "ContextGate") {
+		t.Error("index page not rendered from the embedded template")
+	}
+}
+
+func TestSetAssetsDir_Errors(t *testing.T) {
+	srv, _ := newTestServer(t)
+	if err := srv.SetAssetsDir(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("expected a missing directory to be rejected")
+	}
+
+	dir := t.TempDir()
+	writeAsset(t, dir, "templates/partials/stats.html", `{{define "stats.html"}}{{.Broken`)
+	if err := srv.SetAssetsDir(dir); err == nil {
+		t.Error("expected a broken template to be rejected")
+	}
+	// The server keeps its working templates
+	rec := httptest.NewRecorder()
+	srv.routes().ServeHTTP(rec, httptest.NewRequest("GET", "/partials/stats", nil))
+	if rec.Code != 200 {
+		t.Errorf("stats partial status = %d after a failed override", rec.Code)
+	}
+}
@@ -0,0 +1,181 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/contextgate/contextgate/internal/policy"
+	"github.com/contextgate/contextgate/internal/store"
+)
+
+// redactionMarker matches the scrubber's [REDACTED:label] replacements.
+var redactionMarker = regexp.MustCompile(`\[REDACTED:([A-Za-z0-9_.-]+)\]`)
+
+// explainedRule is one policy rule that matched a message.
+type explainedRule struct {
+	Name     string `json:"name"`
+	Action   string `json:"action,omitempty"`  // from the running policy; empty if the rule is gone
+	Message  string `json:"message,omitempty"` // the rule's custom message, if any
+	Deciding bool   `json:"deciding"`
+}
+
+// explanation reconstructs why a stored message was handled the way it
+// was, from the columns recorded when it was logged.
+type explanation struct {
+	ID          int64           `json:"id"`
+	Direction   string          `json:"direction"`
+	Method      string          `json:"method"`
+	ToolName    string          `json:"tool_name,omitempty"`
+	Action      string          `json:"action"` // deny, quarantine, require_approval, audit or allow
+	Blocked     bool            `json:"blocked"`
+	Summary     string          `json:"summary"`
+	Rules       []explainedRule `json:"rules,omitempty"`
+	Precedence  string          `json:"precedence,omitempty"`
+	ScrubCount  int             `json:"scrub_count"`
+	ScrubLabels []string        `json:"scrub_labels,omitempty"`
+	ToolsPruned int             `json:"tools_pruned"`
+	Notes       []string        `json:"notes,omitempty"`
+}
+
+// handleExplainMessage returns a human-readable account of the policy,
+// scrubbing and pruning decisions behind a stored message.
+func (s *Server) handleExplainMessage(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	entry, err := s.storeFor(r).GetMessage(r.Context(), id)
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	var cfg *policy.Config
+	if s.Policy != nil {
+		cfg = s.Policy.Config()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(explainEntry(entry, cfg))
+}
+
+// explainEntry builds the explanation for e. Rule actions and messages
+// come from cfg, the policy running now, which may differ from the one
+// that handled the message; with no cfg only rule names are reported.
+func explainEntry(e *store.LogEntry, cfg *policy.Config) explanation {
+	ex := explanation{
+		ID:          e.ID,
+		Direction:   e.Direction,
+		Method:      e.Method,
+		ToolName:    e.ToolName,
+		Action:      e.PolicyAction,
+		Blocked:     e.Blocked,
+		ScrubCount:  e.ScrubCount,
+		ToolsPruned: e.ToolsPruned,
+	}
+	if ex.Action == "" {
+		ex.Action = "allow"
+	}
+
+	rules := make(map[string]policy.Rule)
+	if cfg != nil {
+		for _, r := range cfg.Rules {
+			if _, dup := rules[r.Name]; !dup {
+				rules[r.Name] = r
+			}
+		}
+	}
+	decider := ""
+	for _, name := range e.MatchedRules {
+		er := explainedRule{Name: name}
+		if r, ok := rules[name]; ok {
+			er.Action = string(r.Action)
+			er.Message = r.Message
+			// The first rule with the winning action decides
+			if decider == "" && ex.Action != "audit" && er.Action == ex.Action {
+				decider = name
+				er.Deciding = true
+			}
+		}
+		ex.Rules = append(ex.Rules, er)
+	}
+	if len(e.MatchedRules) > 1 {
+		ex.Precedence = precedenceText(ex.Rules, ex.Action, decider)
+	}
+
+	ex.Summary = summarize(e, ex.Action, decider)
+
+	if e.ScrubCount > 0 {
+		for _, m := range redactionMarker.FindAllStringSubmatch(e.Payload, -1) {
+			if !slices.Contains(ex.ScrubLabels, m[1]) {
+				ex.ScrubLabels = append(ex.ScrubLabels, m[1])
+			}
+		}
+		ex.Notes = append(ex.Notes, fmt.Sprintf("The scrubber redacted %d value(s) before the message was forwarded.", e.ScrubCount))
+	}
+	if e.ToolsPruned > 0 {
+		ex.Notes = append(ex.Notes, fmt.Sprintf("%d rarely used tool(s) were pruned from this tools/list response.", e.ToolsPruned))
+	}
+	if e.Unrecognized {
+		ex.Notes = append(ex.Notes, "The method is not a standard MCP method; it was flagged for audit and still forwarded.")
+	}
+	if cfg == nil && len(e.MatchedRules) > 0 {
+		ex.Notes = append(ex.Notes, "No policy is loaded, so the matched rules' actions are unknown.")
+	}
+	return ex
+}
+
+// summarize states in one sentence what happened to the message and why.
+func summarize(e *store.LogEntry, action, decider string) string {
+	by := ""
+	if decider != "" {
+		by = fmt.Sprintf(" by rule %q", decider)
+	}
+	switch action {
+	case string(policy.ActionDeny):
+		return "Blocked" + by + ": a deny rule matched."
+	case string(policy.ActionQuarantine):
+		return "Quarantined" + by + ": the response was forwarded with its result replaced by a stub."
+	case string(policy.ActionRequireApproval):
+		if e.Blocked {
+			return "Held for approval" + by + " and not approved: a reviewer denied or cancelled it, or the request timed out."
+		}
+		return "Held for approval" + by + " and forwarded once a reviewer approved it."
+	case string(policy.ActionAudit):
+		return fmt.Sprintf("Forwarded and flagged for audit by %d matching rule(s).", len(e.MatchedRules))
+	}
+	if e.Blocked {
+		return "Blocked by a check outside the policy rules, such as the call budget, the exfiltration limit or a duplicate request ID."
+	}
+	return "Forwarded: no policy rule matched."
+}
+
+// precedenceText explains how the deciding action was chosen among
+// several matching rules.
+func precedenceText(rules []explainedRule, action, decider string) string {
+	order := make([]string, len(policy.Precedence))
+	for i, a := range policy.Precedence {
+		order[i] = string(a)
+	}
+	text := fmt.Sprintf("%d rules matched. Actions rank %s; the strongest matched action applies, and among rules with that action the first in the policy decides.",
+		len(rules), strings.Join(order, " > "))
+	if decider == "" {
+		return text
+	}
+
+	var outranked []string
+	for _, r := range rules {
+		if r.Name != decider && r.Action != "" && r.Action != action {
+			outranked = append(outranked, fmt.Sprintf("%s (%s)", r.Name, r.Action))
+		}
+	}
+	if len(outranked) > 0 {
+		text += fmt.Sprintf(" %q (%s) outranked %s.", decider, action, strings.Join(outranked, ", "))
+	}
+	return text
+}
@@ -0,0 +1,109 @@
+package dashboard
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/contextgate/contextgate/internal/policy"
+	"github.com/contextgate/contextgate/internal/store"
+)
+
+func TestExplainMessage_DenyPrecedence(t *testing.T) {
+	srv, st := newTestServer(t)
+	cfg := &policy.Config{Rules: []policy.Rule{
+		{Name: "audit-all-tools", Action: policy.ActionAudit, Methods: []string{"tools/call"}},
+		{Name: "approve-shell", Action: policy.ActionRequireApproval, Tools: []string{"run_shell"}},
+		{Name: "block-shell", Action: policy.ActionDeny, Tools: []string{"run_shell"}, Message: "shell access is disabled"},
+		{Name: "block-shell-again", Action: policy.ActionDeny, Tools: []string{"run_shell"}},
+	}}
+	if err := cfg.Compile(); err != nil {
+		t.Fatal(err)
+	}
+	srv.Policy = policy.NewEngine(cfg)
+
+	st.LogMessage(context.Background(), &store.LogEntry{
+		Timestamp: time.Now(), SessionID: "s1", Direction: "host_to_server", Kind: "request",
+		Method: "tools/call", ToolName: "run_shell", MsgID: "1", Blocked: true, PolicyAction: "deny",
+		MatchedRules: []string{"audit-all-tools", "approve-shell", "block-shell", "block-shell-again"},
+		Payload:      `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"run_shell"}}`,
+	})
+	st.Flush()
+
+	rec := httptest.NewRecorder()
+	srv.routes().ServeHTTP(rec, httptest.NewRequest("GET", "/api/messages/1/explain", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var ex explanation
+	if err := json.Unmarshal(rec.Body.Bytes(), &ex); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	if ex.Action != "deny" || !ex.Blocked {
+		t.Errorf("action = %q, blocked = %v", ex.Action, ex.Blocked)
+	}
+	var deciding []string
+	for _, r := range ex.Rules {
+		if r.Deciding {
+			deciding = append(deciding, r.Name)
+		}
+	}
+	if len(ex.Rules) != 4 || len(deciding) != 1 || deciding[0] != "block-shell" {
+		t.Fatalf("rules = %+v, want block-shell alone deciding", ex.Rules)
+	}
+	if ex.Rules[2].Message != "shell access is disabled" || ex.Rules[1].Action != "require_approval" {
+		t.Errorf("rule details = %+v", ex.Rules)
+	}
+	if !strings.Contains(ex.Summary, `"block-shell"`) {
+		t.Errorf("summary = %q", ex.Summary)
+	}
+	for _, want := range []string{"deny > quarantine > require_approval > audit", "audit-all-tools (audit)", "approve-shell (require_approval)"} {
+		if !strings.Contains(ex.Precedence, want) {
+			t.Errorf("precedence = %q, missing %q", ex.Precedence, want)
+		}
+	}
+	if strings.Contains(ex.Precedence, "block-shell-again (") {
+		t.Errorf("a rule with the same action was reported as outranked: %q", ex.Precedence)
+	}
+}
+
+func TestExplainMessage_ScrubbedAndPruned(t *testing.T) {
+	srv, st := newTestServer(t)
+	ctx := context.Background()
+	st.LogMessage(ctx, &store.LogEntry{
+		Timestamp: time.Now(), SessionID: "s1", Direction: "server_to_host", Kind: "response", MsgID: "1", ScrubCount: 3,
+		Payload: `{"jsonrpc":"2.0","id":1,"result":{"text":"[REDACTED:email] [REDACTED:aws_key] [REDACTED:email]"}}`,
+	})
+	st.LogMessage(ctx, &store.LogEntry{
+		Timestamp: time.Now(), SessionID: "s1", Direction: "server_to_host", Kind: "response", MsgID: "2", ToolsPruned: 4,
+		Payload: `{"jsonrpc":"2.0","id":2,"result":{"tools":[]}}`,
+	})
+	st.Flush()
+
+	explain := func(id string) explanation {
+		rec := httptest.NewRecorder()
+		srv.routes().ServeHTTP(rec, httptest.NewRequest("GET", "/api/messages/"+id+"/explain", nil))
+		var ex explanation
+		json.Unmarshal(rec.Body.Bytes(), &ex)
+		return ex
+	}
+
+	ex := explain("1")
+	if ex.Action != "allow" || ex.ScrubCount != 3 || strings.Join(ex.ScrubLabels, ",") != "email,aws_key" {
+		t.Errorf("scrubbed message: %+v", ex)
+	}
+	if ex := explain("2"); ex.ToolsPruned != 4 || len(ex.Notes) != 1 || !strings.Contains(ex.Notes[0], "pruned") {
+		t.Errorf("pruned response: %+v", ex)
+	}
+
+	rec := httptest.NewRecorder()
+	srv.routes().ServeHTTP(rec, httptest.NewRequest("GET", "/api/messages/99/explain", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("missing message: status %d, want 404", rec.Code)
+	}
+}
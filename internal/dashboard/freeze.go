@@ -0,0 +1,55 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// maxFreezeBytes caps the body of a freeze request.
+const maxFreezeBytes = 4 << 10
+
+// freezeRequest switches the manual change freeze on or off.
+type freezeRequest struct {
+	Frozen  bool   `json:"frozen"`
+	Message string `json:"message"`
+}
+
+// handleFreezeState reports whether a change freeze is in effect.
+func (s *Server) handleFreezeState(w http.ResponseWriter, r *http.Request) {
+	if s.Freeze == nil {
+		http.Error(w, "freeze not enabled", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.Freeze.State())
+}
+
+// handleFreeze switches the manual freeze on or off and returns the
+// resulting state. A scheduled window stays in effect either way.
+func (s *Server) handleFreeze(w http.ResponseWriter, r *http.Request) {
+	if s.Freeze == nil {
+		http.Error(w, "freeze not enabled", http.StatusNotFound)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxFreezeBytes)
+
+	var req freezeRequest
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid freeze request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	} else {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "invalid freeze request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.Frozen = r.PostFormValue("frozen") == "true" || r.PostFormValue("frozen") == "on"
+		req.Message = r.PostFormValue("message")
+	}
+
+	s.Freeze.Set(req.Frozen, req.Message)
+	s.logger.Info("change freeze switched from the dashboard", "frozen", req.Frozen, "message", req.Message)
+	s.handleFreezeState(w, r)
+}
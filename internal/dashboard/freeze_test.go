@@ -0,0 +1,54 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/contextgate/contextgate/internal/policy"
+	"github.com/contextgate/contextgate/internal/proxy"
+)
+
+func TestFreezeAPI(t *testing.T) {
+	srv, _ := newTestServer(t)
+	post := func(body string) proxy.FreezeState {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/api/freeze", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		srv.routes().ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+		}
+		var st proxy.FreezeState
+		if err := json.Unmarshal(rec.Body.Bytes(), &st); err != nil {
+			t.Fatal(err)
+		}
+		return st
+	}
+
+	rec := httptest.NewRecorder()
+	srv.routes().ServeHTTP(rec, httptest.NewRequest("POST", "/api/freeze", strings.NewReader(`{"frozen":true}`)))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status without a freeze = %d, want 404", rec.Code)
+	}
+
+	srv.Freeze = proxy.NewFreezeInterceptor(policy.Freeze{})
+	if st := post(`{"frozen":true,"message":"release in progress"}`); !st.Frozen || st.Message != "release in progress" {
+		t.Errorf("state = %+v", st)
+	}
+	if st := srv.Freeze.State(); !st.Frozen {
+		t.Error("freeze not applied")
+	}
+	if st := post(`{"frozen":false}`); st.Frozen {
+		t.Errorf("state = %+v, want unfrozen", st)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.routes().ServeHTTP(rec, httptest.NewRequest("GET", "/api/freeze", nil))
+	if !strings.Contains(rec.Body.String(), `"frozen":false`) {
+		t.Errorf("GET /api/freeze = %s", rec.Body)
+	}
+}
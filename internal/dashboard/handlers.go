@@ -3,8 +3,10 @@ package dashboard
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
@@ -19,14 +21,22 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	messages, err := s.store.Query(r.Context(), store.QueryFilter{Limit: 100})
+	q := r.URL.Query()
+	sessionID := q.Get("session_id")
+	messages, err := s.storeFor(r).Query(r.Context(), store.QueryFilter{
+		SessionID: sessionID,
+		Direction: q.Get("direction"),
+		Kind:      q.Get("kind"),
+		Tag:       q.Get("tag"),
+		Limit:     100,
+	})
 	if err != nil {
 		s.logger.Error("query messages", "error", err)
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
 	}
 
-	stats, err := s.store.Stats(r.Context(), "")
+	stats, err := s.storeFor(r).Stats(r.Context(), "")
 	if err != nil {
 		s.logger.Error("query stats", "error", err)
 		stats = &store.Stats{MethodCounts: make(map[string]int)}
@@ -36,8 +46,9 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
 	}
 
 	data := map[string]any{
-		"Messages": messages,
-		"Stats":    stats,
+		"Messages":  messages,
+		"Stats":     stats,
+		"SessionID": sessionID,
 	}
 
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
@@ -55,7 +66,7 @@ func (s *Server) handleMessageDetail(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	entry, err := s.store.GetMessage(r.Context(), id)
+	entry, err := s.storeFor(r).GetMessage(r.Context(), id)
 	if err != nil {
 		http.Error(w, "not found", http.StatusNotFound)
 		return
@@ -67,7 +78,71 @@ func (s *Server) handleMessageDetail(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// maxAnnotationBytes bounds the body of an annotate request.
+const maxAnnotationBytes = 64 << 10
+
+// annotation is the JSON body of an annotate request.
+type annotation struct {
+	Note string   `json:"note"`
+	Tags []string `json:"tags"`
+}
+
+// handleAnnotateMessage replaces a message's note and tags. It takes a
+// JSON body, answered with the updated message as JSON, or form values
+// with comma-separated tags from the detail panel, answered with the
+// re-rendered panel.
+func (s *Server) handleAnnotateMessage(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxAnnotationBytes)
+
+	var a annotation
+	isJSON := strings.HasPrefix(r.Header.Get("Content-Type"), "application/json")
+	if isJSON {
+		if err := json.NewDecoder(r.Body).Decode(&a); err != nil {
+			http.Error(w, "invalid annotation: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	} else {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "invalid annotation: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		a.Note = r.PostFormValue("note")
+		a.Tags = strings.Split(r.PostFormValue("tags"), ",")
+	}
+
+	st := s.storeFor(r)
+	if err := st.AnnotateMessage(r.Context(), id, a.Note, a.Tags); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	entry, err := st.GetMessage(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if isJSON {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(entry)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := s.tmpl.ExecuteTemplate(w, "message_detail.html", entry); err != nil {
+		s.logger.Error("render detail", "error", err)
+	}
+}
+
 // handleSSE streams live message and approval events to the browser.
+// With ?session_id= only that session's events are sent.
 func (s *Server) handleSSE(w http.ResponseWriter, r *http.Request) {
 	flusher, ok := w.(http.Flusher)
 	if !ok {
@@ -86,16 +161,35 @@ func (s *Server) handleSSE(w http.ResponseWriter, r *http.Request) {
 	approvalCh, approvalUnsub := s.eventBus.SubscribeApprovals(subID + "-approval")
 	defer approvalUnsub()
 
+	// Send headers now so the client sees the stream open before any event
+	flusher.Flush()
+
+	// Heartbeat comments keep idle connections from being closed by
+	// browsers and intermediaries; clients ignore them
+	var heartbeat <-chan time.Time
+	if s.SSEHeartbeat > 0 {
+		ticker := time.NewTicker(s.SSEHeartbeat)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+
 	ctx := r.Context()
+	sessionID := r.URL.Query().Get("session_id")
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
+		case <-heartbeat:
+			fmt.Fprintf(w, ": ping\n\n")
+			flusher.Flush()
 		case entry, ok := <-ch:
 			if !ok {
 				return
 			}
+			if sessionID != "" && entry.SessionID != sessionID {
+				continue
+			}
 
 			// Render message row HTML fragment
 			var buf bytes.Buffer
@@ -116,6 +210,9 @@ func (s *Server) handleSSE(w http.ResponseWriter, r *http.Request) {
 			if !ok {
 				return
 			}
+			if sessionID != "" && approval.Request.SessionID != sessionID {
+				continue
+			}
 
 			// Render approval modal HTML fragment
 			var buf bytes.Buffer
@@ -136,7 +233,7 @@ func (s *Server) handleSSE(w http.ResponseWriter, r *http.Request) {
 
 // handleStatsPartial serves the stats bar as an HTMX partial.
 func (s *Server) handleStatsPartial(w http.ResponseWriter, r *http.Request) {
-	stats, err := s.store.Stats(r.Context(), "")
+	stats, err := s.storeFor(r).Stats(r.Context(), "")
 	if err != nil {
 		s.logger.Error("query stats", "error", err)
 		stats = &store.Stats{MethodCounts: make(map[string]int)}
@@ -146,6 +243,7 @@ func (s *Server) handleStatsPartial(w http.ResponseWriter, r *http.Request) {
 	if s.approvalMgr != nil {
 		stats.ApprovalPending = s.approvalMgr.PendingCount()
 	}
+	stats.LiveDropped = s.eventBus.DroppedCount()
 
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	if err := s.tmpl.ExecuteTemplate(w, "stats.html", stats); err != nil {
@@ -153,6 +251,23 @@ func (s *Server) handleStatsPartial(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// argFilters collects "arg.<name>=<value>" query parameters into
+// tools/call argument filters.
+func argFilters(q url.Values) map[string]string {
+	var args map[string]string
+	for key, values := range q {
+		name, ok := strings.CutPrefix(key, "arg.")
+		if !ok || name == "" || len(values) == 0 {
+			continue
+		}
+		if args == nil {
+			args = make(map[string]string)
+		}
+		args[name] = values[0]
+	}
+	return args
+}
+
 // handleAPIMessages returns messages as JSON.
 func (s *Server) handleAPIMessages(w http.ResponseWriter, r *http.Request) {
 	q := r.URL.Query()
@@ -161,6 +276,8 @@ func (s *Server) handleAPIMessages(w http.ResponseWriter, r *http.Request) {
 		Direction: q.Get("direction"),
 		Method:    q.Get("method"),
 		Kind:      q.Get("kind"),
+		Tag:       q.Get("tag"),
+		Args:      argFilters(q),
 	}
 	if limitStr := q.Get("limit"); limitStr != "" {
 		filter.Limit, _ = strconv.Atoi(limitStr)
@@ -169,7 +286,7 @@ func (s *Server) handleAPIMessages(w http.ResponseWriter, r *http.Request) {
 		filter.Offset, _ = strconv.Atoi(offsetStr)
 	}
 
-	messages, err := s.store.Query(r.Context(), filter)
+	messages, err := s.storeFor(r).Query(r.Context(), filter)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -182,11 +299,12 @@ func (s *Server) handleAPIMessages(w http.ResponseWriter, r *http.Request) {
 // handleAPIStats returns stats as JSON.
 func (s *Server) handleAPIStats(w http.ResponseWriter, r *http.Request) {
 	sessionID := r.URL.Query().Get("session_id")
-	stats, err := s.store.Stats(r.Context(), sessionID)
+	stats, err := s.storeFor(r).Stats(r.Context(), sessionID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	stats.LiveDropped = s.eventBus.DroppedCount()
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(stats)
@@ -222,6 +340,22 @@ func (s *Server) handleDeny(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(`<div class="approval-resolved">Denied</div>`))
 }
 
+// handleCancelApproval withdraws a pending approval request without a
+// decision; the interceptor answers it according to its cancel mode.
+func (s *Server) handleCancelApproval(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if s.approvalMgr == nil {
+		http.Error(w, "approval not enabled", http.StatusNotFound)
+		return
+	}
+	if err := s.approvalMgr.Cancel(id); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(`<div class="approval-resolved">Cancelled</div>`))
+}
+
 // handlePendingApprovals returns pending approval requests as JSON.
 func (s *Server) handlePendingApprovals(w http.ResponseWriter, r *http.Request) {
 	if s.approvalMgr == nil {
@@ -237,7 +371,7 @@ func (s *Server) handlePendingApprovals(w http.ResponseWriter, r *http.Request)
 // handleToolAnalytics returns tool analytics as JSON.
 func (s *Server) handleToolAnalytics(w http.ResponseWriter, r *http.Request) {
 	sessionID := r.URL.Query().Get("session_id")
-	analytics, err := s.store.GetToolAnalytics(r.Context(), sessionID)
+	analytics, err := s.storeFor(r).GetToolAnalytics(r.Context(), sessionID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -250,7 +384,7 @@ func (s *Server) handleToolAnalytics(w http.ResponseWriter, r *http.Request) {
 // handleToolAnalyticsPartial serves the tool analytics section as an HTMX partial.
 func (s *Server) handleToolAnalyticsPartial(w http.ResponseWriter, r *http.Request) {
 	sessionID := r.URL.Query().Get("session_id")
-	analytics, err := s.store.GetToolAnalytics(r.Context(), sessionID)
+	analytics, err := s.storeFor(r).GetToolAnalytics(r.Context(), sessionID)
 	if err != nil {
 		s.logger.Error("query tool analytics", "error", err)
 		analytics = &store.ToolAnalyticsSummary{}
@@ -262,6 +396,285 @@ func (s *Server) handleToolAnalyticsPartial(w http.ResponseWriter, r *http.Reque
 	}
 }
 
+// handleBlockedLeaderboard returns the most-blocked tools and rules as JSON.
+func (s *Server) handleBlockedLeaderboard(w http.ResponseWriter, r *http.Request) {
+	stats, err := s.storeFor(r).BlockedLeaderboard(r.Context(), r.URL.Query().Get("session_id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if stats == nil {
+		stats = []store.BlockedStat{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// handleBlockedLeaderboardPartial serves the blocked leaderboard as an HTMX partial.
+func (s *Server) handleBlockedLeaderboardPartial(w http.ResponseWriter, r *http.Request) {
+	stats, err := s.storeFor(r).BlockedLeaderboard(r.Context(), r.URL.Query().Get("session_id"))
+	if err != nil {
+		s.logger.Error("query blocked leaderboard", "error", err)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := s.tmpl.ExecuteTemplate(w, "blocked_leaderboard.html", stats); err != nil {
+		s.logger.Error("render blocked leaderboard", "error", err)
+	}
+}
+
+// handleUnrecognizedMethods returns counts of methods outside the known set as JSON.
+func (s *Server) handleUnrecognizedMethods(w http.ResponseWriter, r *http.Request) {
+	stats, err := s.storeFor(r).UnrecognizedMethods(r.Context(), r.URL.Query().Get("session_id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if stats == nil {
+		stats = []store.MethodStat{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// handleUnrecognizedMethodsPartial serves the unrecognized methods as an HTMX partial.
+func (s *Server) handleUnrecognizedMethodsPartial(w http.ResponseWriter, r *http.Request) {
+	stats, err := s.storeFor(r).UnrecognizedMethods(r.Context(), r.URL.Query().Get("session_id"))
+	if err != nil {
+		s.logger.Error("query unrecognized methods", "error", err)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := s.tmpl.ExecuteTemplate(w, "unrecognized_methods.html", stats); err != nil {
+		s.logger.Error("render unrecognized methods", "error", err)
+	}
+}
+
+// handleToolConflicts returns tool names listed more than once as JSON.
+func (s *Server) handleToolConflicts(w http.ResponseWriter, r *http.Request) {
+	conflicts, err := s.storeFor(r).ToolConflicts(r.Context(), r.URL.Query().Get("session_id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if conflicts == nil {
+		conflicts = []store.ToolConflict{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(conflicts)
+}
+
+// handleToolConflictsPartial serves the duplicate tool names as an HTMX partial.
+func (s *Server) handleToolConflictsPartial(w http.ResponseWriter, r *http.Request) {
+	conflicts, err := s.storeFor(r).ToolConflicts(r.Context(), r.URL.Query().Get("session_id"))
+	if err != nil {
+		s.logger.Error("query tool conflicts", "error", err)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := s.tmpl.ExecuteTemplate(w, "tool_conflicts.html", conflicts); err != nil {
+		s.logger.Error("render tool conflicts", "error", err)
+	}
+}
+
+// handleToolArgUsage returns a tool's declared and used argument keys as JSON.
+func (s *Server) handleToolArgUsage(w http.ResponseWriter, r *http.Request) {
+	usage, err := s.storeFor(r).GetToolArgUsage(r.Context(), r.URL.Query().Get("session_id"), r.PathValue("name"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(usage)
+}
+
+// handleToolArgsPartial serves argument usage for every called tool as an
+// HTMX partial.
+func (s *Server) handleToolArgsPartial(w http.ResponseWriter, r *http.Request) {
+	st := s.storeFor(r)
+	var usages []*store.ToolArgUsage
+	analytics, err := st.GetToolAnalytics(r.Context(), "")
+	if err != nil {
+		s.logger.Error("query tool analytics", "error", err)
+		analytics = &store.ToolAnalyticsSummary{}
+	}
+	for _, t := range analytics.Tools {
+		if t.CallCount == 0 {
+			continue
+		}
+		usage, err := st.GetToolArgUsage(r.Context(), "", t.ToolName)
+		if err != nil {
+			s.logger.Error("query tool argument usage", "error", err, "tool", t.ToolName)
+			continue
+		}
+		usages = append(usages, usage)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := s.tmpl.ExecuteTemplate(w, "tool_args.html", usages); err != nil {
+		s.logger.Error("render tool argument usage", "error", err)
+	}
+}
+
+// handleToolTimeline returns when each tool first appeared in a session as JSON.
+func (s *Server) handleToolTimeline(w http.ResponseWriter, r *http.Request) {
+	timeline, err := s.storeFor(r).ToolTimeline(r.Context(), r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if timeline == nil {
+		timeline = []store.ToolTimelineEntry{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(timeline)
+}
+
+// handleToolTimelinePartial serves the tool timeline as an HTMX partial.
+func (s *Server) handleToolTimelinePartial(w http.ResponseWriter, r *http.Request) {
+	timeline, err := s.storeFor(r).ToolTimeline(r.Context(), r.URL.Query().Get("session_id"))
+	if err != nil {
+		s.logger.Error("query tool timeline", "error", err)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := s.tmpl.ExecuteTemplate(w, "tool_timeline.html", timeline); err != nil {
+		s.logger.Error("render tool timeline", "error", err)
+	}
+}
+
+// handleSessionReport returns per-tool and per-method policy outcome counts for a session.
+func (s *Server) handleSessionReport(w http.ResponseWriter, r *http.Request) {
+	report, err := s.storeFor(r).SessionReport(r.Context(), r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// sessionDetail is the composite document served by handleSessionDetail.
+type sessionDetail struct {
+	Session   *store.Session              `json:"session"`
+	Stats     *store.Stats                `json:"stats"`
+	Tools     *store.ToolAnalyticsSummary `json:"tools"`
+	Approvals []store.ApprovalRecord      `json:"approvals"`
+	Protocol  *protocolInfo               `json:"protocol"`
+}
+
+// protocolInfo describes the handshake negotiated by a session's initialize exchange.
+type protocolInfo struct {
+	ProtocolVersion string          `json:"protocol_version,omitempty"`
+	ClientInfo      json.RawMessage `json:"client_info,omitempty"`
+	ServerInfo      json.RawMessage `json:"server_info,omitempty"`
+	Capabilities    json.RawMessage `json:"capabilities,omitempty"`
+}
+
+// handleSessionDetail returns a session's metadata, stats, tool analytics,
+// approvals and negotiated protocol in a single JSON document.
+func (s *Server) handleSessionDetail(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := r.PathValue("id")
+	st := s.storeFor(r)
+
+	session, err := st.GetSession(ctx, id)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	detail := sessionDetail{Session: session}
+	if detail.Stats, err = st.Stats(ctx, id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if detail.Tools, err = st.GetToolAnalytics(ctx, id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if detail.Approvals, err = st.GetApprovals(ctx, id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if detail.Approvals == nil {
+		detail.Approvals = []store.ApprovalRecord{}
+	}
+	if detail.Protocol, err = s.sessionProtocol(r, id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(detail)
+}
+
+// sessionProtocol reconstructs the negotiated protocol from the session's
+// initialize request and its response. Returns nil if no handshake was logged.
+func (s *Server) sessionProtocol(r *http.Request, sessionID string) (*protocolInfo, error) {
+	reqs, err := s.storeFor(r).Query(r.Context(), store.QueryFilter{
+		SessionID: sessionID,
+		Direction: "host_to_server",
+		Method:    "initialize",
+		Kind:      "request",
+		Limit:     1,
+	})
+	if err != nil || len(reqs) == 0 {
+		return nil, err
+	}
+
+	info := &protocolInfo{}
+	var req struct {
+		Params struct {
+			ProtocolVersion string          `json:"protocolVersion"`
+			ClientInfo      json.RawMessage `json:"clientInfo"`
+		} `json:"params"`
+	}
+	if json.Unmarshal([]byte(reqs[0].Payload), &req) == nil {
+		info.ProtocolVersion = req.Params.ProtocolVersion
+		info.ClientInfo = req.Params.ClientInfo
+	}
+
+	resps, err := s.storeFor(r).Query(r.Context(), store.QueryFilter{
+		SessionID: sessionID,
+		Direction: "server_to_host",
+		Kind:      "response",
+		MsgID:     reqs[0].MsgID,
+		Limit:     1,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resps) > 0 {
+		var resp struct {
+			Result struct {
+				ProtocolVersion string          `json:"protocolVersion"`
+				ServerInfo      json.RawMessage `json:"serverInfo"`
+				Capabilities    json.RawMessage `json:"capabilities"`
+			} `json:"result"`
+		}
+		if json.Unmarshal([]byte(resps[0].Payload), &resp) == nil {
+			// The server's answer is the version actually in effect
+			if resp.Result.ProtocolVersion != "" {
+				info.ProtocolVersion = resp.Result.ProtocolVersion
+			}
+			info.ServerInfo = resp.Result.ServerInfo
+			info.Capabilities = resp.Result.Capabilities
+		}
+	}
+	return info, nil
+}
+
 // prettyJSON formats a JSON string for display.
 func prettyJSON(s string) string {
 	var buf bytes.Buffer
@@ -0,0 +1,691 @@
+package dashboard
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/contextgate/contextgate/internal/eventbus"
+	"github.com/contextgate/contextgate/internal/proxy"
+	"github.com/contextgate/contextgate/internal/store"
+)
+
+func newTestServer(t *testing.T) (*Server, *store.SQLiteStore) {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	st, err := store.NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"), logger)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	t.Cleanup(func() { st.Close() })
+
+	srv, err := NewServer(":0", st, eventbus.New(10), nil, nil, nil, logger)
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	return srv, st
+}
+
+func TestSessionDetail(t *testing.T) {
+	srv, st := newTestServer(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	st.CreateSession(ctx, &store.Session{ID: "sess-1", StartedAt: now, Command: "node", Args: []string{"server.js"}})
+	entries := []*store.LogEntry{
+		{
+			Direction: "host_to_server", Kind: "request", Method: "initialize", MsgID: "1",
+			Payload: `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05","clientInfo":{"name":"test-host"}}}`,
+		},
+		{
+			Direction: "server_to_host", Kind: "response", MsgID: "1",
+			Payload: `{"jsonrpc":"2.0","id":1,"result":{"protocolVersion":"2025-03-26","serverInfo":{"name":"test-server"},"capabilities":{"tools":{}}}}`,
+		},
+		{
+			Direction: "host_to_server", Kind: "request", Method: "tools/call", MsgID: "2", ToolName: "read_file",
+			Payload: `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"read_file"}}`,
+		},
+	}
+	for i, e := range entries {
+		e.SessionID = "sess-1"
+		e.Timestamp = now.Add(time.Duration(i) * time.Millisecond)
+		st.LogMessage(ctx, e)
+	}
+	st.LogApproval(ctx, &store.ApprovalRecord{ID: "appr-1", SessionID: "sess-1", Timestamp: now, ToolName: "read_file", Decision: "approved"})
+	st.Flush()
+
+	rec := httptest.NewRecorder()
+	srv.routes().ServeHTTP(rec, httptest.NewRequest("GET", "/api/sessions/sess-1", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	for _, key := range []string{"session", "stats", "tools", "approvals", "protocol"} {
+		if v, ok := doc[key]; !ok || string(v) == "null" {
+			t.Errorf("missing section %q", key)
+		}
+	}
+
+	var detail struct {
+		Session   store.Session          `json:"session"`
+		Approvals []store.ApprovalRecord `json:"approvals"`
+		Protocol  struct {
+			ProtocolVersion string `json:"protocol_version"`
+			ClientInfo      struct {
+				Name string `json:"name"`
+			} `json:"client_info"`
+			ServerInfo struct {
+				Name string `json:"name"`
+			} `json:"server_info"`
+			Capabilities map[string]json.RawMessage `json:"capabilities"`
+		} `json:"protocol"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &detail)
+
+	if detail.Session.Command != "node" {
+		t.Errorf("session command = %q, want %q", detail.Session.Command, "node")
+	}
+	if len(detail.Approvals) != 1 {
+		t.Errorf("got %d approvals, want 1", len(detail.Approvals))
+	}
+	if detail.Protocol.ProtocolVersion != "2025-03-26" {
+		t.Errorf("protocol version = %q, want server's %q", detail.Protocol.ProtocolVersion, "2025-03-26")
+	}
+	if detail.Protocol.ClientInfo.Name != "test-host" || detail.Protocol.ServerInfo.Name != "test-server" {
+		t.Errorf("client/server info = %q/%q", detail.Protocol.ClientInfo.Name, detail.Protocol.ServerInfo.Name)
+	}
+	if _, ok := detail.Protocol.Capabilities["tools"]; !ok {
+		t.Errorf("capabilities missing tools: %v", detail.Protocol.Capabilities)
+	}
+}
+
+func TestSessionDetail_NotFound(t *testing.T) {
+	srv, _ := newTestServer(t)
+
+	rec := httptest.NewRecorder()
+	srv.routes().ServeHTTP(rec, httptest.NewRequest("GET", "/api/sessions/missing", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", rec.Code)
+	}
+}
+
+func TestUnrecognizedMethods(t *testing.T) {
+	srv, st := newTestServer(t)
+	ctx := context.Background()
+
+	st.LogMessage(ctx, &store.LogEntry{Timestamp: time.Now(), SessionID: "s1", Direction: "host_to_server", Kind: "request",
+		Method: "tools/teleport", Payload: `{}`, Audit: true, Unrecognized: true})
+	st.LogMessage(ctx, &store.LogEntry{Timestamp: time.Now(), SessionID: "s1", Direction: "host_to_server", Kind: "request",
+		Method: "tools/call", Payload: `{}`})
+	st.Flush()
+
+	rec := httptest.NewRecorder()
+	srv.routes().ServeHTTP(rec, httptest.NewRequest("GET", "/api/methods/unrecognized?session_id=s1", nil))
+	var stats []store.MethodStat
+	if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(stats) != 1 || stats[0].Method != "tools/teleport" || stats[0].Count != 1 {
+		t.Errorf("stats = %+v, want tools/teleport once", stats)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.routes().ServeHTTP(rec, httptest.NewRequest("GET", "/partials/unrecognized-methods", nil))
+	if !strings.Contains(rec.Body.String(), "tools/teleport") || strings.Contains(rec.Body.String(), "tools/call") {
+		t.Errorf("partial should list only the unrecognized method:\n%s", rec.Body.String())
+	}
+}
+
+func TestAnnotateMessage(t *testing.T) {
+	srv, st := newTestServer(t)
+	ctx := context.Background()
+	for _, method := range []string{"tools/call", "tools/list"} {
+		st.LogMessage(ctx, &store.LogEntry{Timestamp: time.Now(), SessionID: "s1", Direction: "host_to_server", Kind: "request",
+			Method: method, Payload: `{}`})
+	}
+	st.Flush()
+
+	req := httptest.NewRequest("POST", "/api/messages/1/annotate", strings.NewReader(`{"note":"check this","tags":["suspicious"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	srv.routes().ServeHTTP(rec, req)
+	var entry store.LogEntry
+	if err := json.Unmarshal(rec.Body.Bytes(), &entry); err != nil {
+		t.Fatalf("decode: %v (status %d)", err, rec.Code)
+	}
+	if entry.Note != "check this" || len(entry.Tags) != 1 || entry.Tags[0] != "suspicious" {
+		t.Errorf("annotated entry = %+v", entry)
+	}
+
+	// The detail panel posts a form and gets the panel back
+	req = httptest.NewRequest("POST", "/api/messages/2/annotate", strings.NewReader("tags=review,+later&note=second"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec = httptest.NewRecorder()
+	srv.routes().ServeHTTP(rec, req)
+	if body := rec.Body.String(); !strings.Contains(body, "Message #2") || !strings.Contains(body, "later") {
+		t.Errorf("form annotate should render the detail panel:\n%s", body)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.routes().ServeHTTP(rec, httptest.NewRequest("GET", "/api/messages?tag=suspicious", nil))
+	var tagged []store.LogEntry
+	json.Unmarshal(rec.Body.Bytes(), &tagged)
+	if len(tagged) != 1 || tagged[0].ID != 1 {
+		t.Errorf("tag filter = %+v, want message 1", tagged)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.routes().ServeHTTP(rec, httptest.NewRequest("GET", "/?tag=review", nil))
+	if body := rec.Body.String(); !strings.Contains(body, "tools/list") || strings.Contains(body, "tools/call") {
+		t.Errorf("index should show only the tagged message:\n%s", body)
+	}
+
+	req = httptest.NewRequest("POST", "/api/messages/99/annotate", strings.NewReader(`{"note":"x"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec = httptest.NewRecorder()
+	srv.routes().ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("missing message: status = %d, want 404", rec.Code)
+	}
+}
+
+func TestAdminClear(t *testing.T) {
+	srv, st := newTestServer(t)
+	ctx := context.Background()
+	st.LogMessage(ctx, &store.LogEntry{Timestamp: time.Now(), SessionID: "s1", Direction: "host_to_server", Kind: "request",
+		Method: "tools/call", Payload: `{}`})
+	st.RegisterTools(ctx, "s1", []store.ToolRecord{{ToolName: "search"}})
+	st.Flush()
+
+	clear := func(scope, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/admin/clear?scope="+scope, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		srv.routes().ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Disabled without a configured token
+	if rec := clear("all", "anything"); rec.Code != http.StatusForbidden {
+		t.Errorf("no admin token: status = %d, want 403", rec.Code)
+	}
+
+	srv.AdminToken = "s3cret"
+	if rec := clear("all", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("missing token: status = %d, want 401", rec.Code)
+	}
+	if rec := clear("all", "wrong"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("wrong token: status = %d, want 401", rec.Code)
+	}
+	if rec := clear("sessions", "s3cret"); rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown scope: status = %d, want 400", rec.Code)
+	}
+	if entries, _ := st.Query(ctx, store.QueryFilter{}); len(entries) != 1 {
+		t.Fatal("rejected requests deleted data")
+	}
+
+	rec := clear("messages", "s3cret")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"messages_deleted":1`) {
+		t.Fatalf("clear messages: %d %s", rec.Code, rec.Body.String())
+	}
+	rec = httptest.NewRecorder()
+	srv.routes().ServeHTTP(rec, httptest.NewRequest("GET", "/api/messages", nil))
+	if body := strings.TrimSpace(rec.Body.String()); body != "null" && body != "[]" {
+		t.Errorf("messages after clear: %s", body)
+	}
+	if analytics, _ := st.GetToolAnalytics(ctx, ""); analytics.TotalAvailable != 1 {
+		t.Error("scope=messages cleared the tool registry")
+	}
+
+	if rec := clear("tools", "s3cret"); rec.Code != http.StatusOK {
+		t.Fatalf("clear tools: %d %s", rec.Code, rec.Body.String())
+	}
+	rec = httptest.NewRecorder()
+	srv.routes().ServeHTTP(rec, httptest.NewRequest("GET", "/api/tools/analytics", nil))
+	var summary store.ToolAnalyticsSummary
+	json.Unmarshal(rec.Body.Bytes(), &summary)
+	if summary.TotalAvailable != 0 || len(summary.Tools) != 0 {
+		t.Errorf("tools after clear: %+v", summary)
+	}
+}
+
+func TestToolConflicts(t *testing.T) {
+	srv, st := newTestServer(t)
+	st.RecordToolConflicts(context.Background(), "s1", []store.ToolConflict{{ToolName: "search", Copies: 2}})
+
+	rec := httptest.NewRecorder()
+	srv.routes().ServeHTTP(rec, httptest.NewRequest("GET", "/api/tools/conflicts?session_id=s1", nil))
+	var conflicts []store.ToolConflict
+	if err := json.Unmarshal(rec.Body.Bytes(), &conflicts); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(conflicts) != 1 || conflicts[0].ToolName != "search" || conflicts[0].Copies != 2 {
+		t.Errorf("conflicts = %+v, want search x2", conflicts)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.routes().ServeHTTP(rec, httptest.NewRequest("GET", "/partials/tool-conflicts", nil))
+	if !strings.Contains(rec.Body.String(), "search") {
+		t.Errorf("partial should list the duplicate:\n%s", rec.Body.String())
+	}
+}
+
+func TestMetrics_InterceptorLatency(t *testing.T) {
+	srv, _ := newTestServer(t)
+	srv.Latency = proxy.NewInterceptorLatency()
+	srv.Latency.Observe("PolicyInterceptor", 2*time.Millisecond)
+	srv.Latency.Observe("PolicyInterceptor", 4*time.Millisecond)
+
+	rec := httptest.NewRecorder()
+	srv.routes().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	for _, want := range []string{
+		"# TYPE contextgate_interceptor_duration_seconds histogram\n",
+		`contextgate_interceptor_duration_seconds_bucket{interceptor="PolicyInterceptor",le="0.001"} 0` + "\n",
+		`contextgate_interceptor_duration_seconds_bucket{interceptor="PolicyInterceptor",le="0.005"} 2` + "\n",
+		`contextgate_interceptor_duration_seconds_count{interceptor="PolicyInterceptor"} 2` + "\n",
+		`contextgate_interceptor_duration_seconds_sum{interceptor="PolicyInterceptor"} 0.006` + "\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics missing %q:\n%s", want, body)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	srv.routes().ServeHTTP(rec, httptest.NewRequest("GET", "/api/debug/interceptors", nil))
+	var rows []interceptorLatency
+	if err := json.Unmarshal(rec.Body.Bytes(), &rows); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(rows) != 1 || rows[0].Count != 2 || rows[0].AvgMs != 3 || rows[0].MaxMs != 4 {
+		t.Errorf("rows = %+v, want PolicyInterceptor with 2 calls, avg 3ms, max 4ms", rows)
+	}
+}
+
+func TestToolArgUsage(t *testing.T) {
+	srv, st := newTestServer(t)
+	ctx := context.Background()
+	st.RegisterTools(ctx, "s1", []store.ToolRecord{{ToolName: "read_file", InputProperties: []string{"path", "encoding"}}})
+	for i, args := range []string{`{"path":"/a"}`, `{"path":"/b","recursive":true}`} {
+		st.LogMessage(ctx, &store.LogEntry{Timestamp: time.Now(), SessionID: "s1", Direction: "host_to_server", Kind: "request",
+			Method: "tools/call", ToolName: "read_file", MsgID: fmt.Sprint(i), Payload: `{}`, Arguments: json.RawMessage(args)})
+	}
+	st.Flush()
+
+	rec := httptest.NewRecorder()
+	srv.routes().ServeHTTP(rec, httptest.NewRequest("GET", "/api/tools/read_file/args", nil))
+	var usage store.ToolArgUsage
+	if err := json.Unmarshal(rec.Body.Bytes(), &usage); err != nil {
+		t.Fatalf("decode: %v (%s)", err, rec.Body)
+	}
+	if usage.Calls != 2 || len(usage.Args) != 2 ||
+		len(usage.Undocumented) != 1 || usage.Undocumented[0] != "recursive" ||
+		len(usage.Unused) != 1 || usage.Unused[0] != "encoding" {
+		t.Errorf("usage = %+v, want recursive undocumented and encoding unused", usage)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.routes().ServeHTTP(rec, httptest.NewRequest("GET", "/partials/tool-args", nil))
+	body := rec.Body.String()
+	for _, want := range []string{"read_file", "path (2)", "recursive", "encoding"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("partial missing %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestMetrics_MessageHistograms(t *testing.T) {
+	srv, _ := newTestServer(t)
+	srv.Messages = proxy.NewMessageMetrics([]float64{100, 1000}, []time.Duration{100 * time.Millisecond})
+	for _, size := range []int{50, 500, 5000} {
+		srv.Messages.Observe(&proxy.InterceptedMessage{Direction: proxy.DirHostToServer, RawBytes: make([]byte, size)})
+	}
+	srv.Messages.Observe(&proxy.InterceptedMessage{Direction: proxy.DirServerToHost, RawBytes: make([]byte, 80),
+		Metadata: map[string]any{proxy.MetaKeyLatencyMs: float64(40)}})
+
+	rec := httptest.NewRecorder()
+	srv.routes().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	host, server := string(proxy.DirHostToServer), string(proxy.DirServerToHost)
+	for _, want := range []string{
+		"# TYPE contextgate_message_size_bytes histogram\n",
+		`contextgate_message_size_bytes_bucket{direction="` + host + `",le="100"} 1` + "\n",
+		`contextgate_message_size_bytes_bucket{direction="` + host + `",le="1000"} 2` + "\n",
+		`contextgate_message_size_bytes_bucket{direction="` + host + `",le="+Inf"} 3` + "\n",
+		`contextgate_message_size_bytes_sum{direction="` + host + `"} 5550` + "\n",
+		`contextgate_message_size_bytes_bucket{direction="` + server + `",le="100"} 1` + "\n",
+		"# TYPE contextgate_response_latency_seconds histogram\n",
+		`contextgate_response_latency_seconds_bucket{direction="` + server + `",le="0.1"} 1` + "\n",
+		`contextgate_response_latency_seconds_count{direction="` + server + `"} 1` + "\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics missing %q:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, `contextgate_response_latency_seconds_count{direction="`+host+`"}`) {
+		t.Error("requests counted in the response latency histogram")
+	}
+}
+
+func TestScrubberProfile(t *testing.T) {
+	srv, _ := newTestServer(t)
+	rec := httptest.NewRecorder()
+	srv.routes().ServeHTTP(rec, httptest.NewRequest("GET", "/api/debug/scrubber", nil))
+	if body := strings.TrimSpace(rec.Body.String()); body != "[]" {
+		t.Errorf("without profiling = %s, want []", body)
+	}
+
+	scrubber, err := proxy.NewScrubberInterceptor(true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scrubber.Profile = proxy.NewScrubProfile()
+	scrubber.Profile.Observe("email", 3*time.Millisecond, 3000, 1)
+	scrubber.Profile.Observe("ssn", time.Millisecond, 3000, 0)
+	srv.scrubber = scrubber
+
+	rec = httptest.NewRecorder()
+	srv.routes().ServeHTTP(rec, httptest.NewRequest("GET", "/api/debug/scrubber", nil))
+	var rows []scrubPatternTiming
+	if err := json.Unmarshal(rec.Body.Bytes(), &rows); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(rows) != 2 || rows[0].Pattern != "email" || rows[0].TotalMs != 3 || rows[0].MBPerSec != 1 ||
+		rows[0].ShareOfScrub != 0.75 || rows[1].Pattern != "ssn" {
+		t.Errorf("rows = %+v, want email (3ms, 1 MB/s, 75%%) then ssn", rows)
+	}
+}
+
+func TestMetrics_EventBusDrops(t *testing.T) {
+	srv, _ := newTestServer(t)
+
+	_, unsub := srv.eventBus.SubscribeSize("slow", 1)
+	defer unsub()
+	for i := 0; i < 4; i++ {
+		srv.eventBus.Publish(&store.LogEntry{Method: "ping"})
+	}
+
+	rec := httptest.NewRecorder()
+	srv.routes().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{"contextgate_eventbus_dropped_total 3\n", "contextgate_eventbus_subscribers 1\n"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics missing %q:\n%s", want, body)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	srv.routes().ServeHTTP(rec, httptest.NewRequest("GET", "/api/stats", nil))
+	var stats store.Stats
+	json.Unmarshal(rec.Body.Bytes(), &stats)
+	if stats.LiveDropped != 3 {
+		t.Errorf("stats live_dropped = %d, want 3", stats.LiveDropped)
+	}
+}
+
+func TestBlockedLeaderboardPartial(t *testing.T) {
+	srv, st := newTestServer(t)
+	ctx := context.Background()
+
+	st.LogMessage(ctx, &store.LogEntry{Timestamp: time.Now(), SessionID: "s1", Direction: "host_to_server", Kind: "request",
+		Method: "tools/call", ToolName: "run_shell", Payload: `{}`, Blocked: true, MatchedRules: []string{"block-shell"}})
+	st.Flush()
+
+	rec := httptest.NewRecorder()
+	srv.routes().ServeHTTP(rec, httptest.NewRequest("GET", "/partials/blocked-leaderboard", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, "run_shell") || !strings.Contains(body, "block-shell") {
+		t.Errorf("leaderboard missing blocked tool/rule:\n%s", body)
+	}
+}
+
+func TestSSE_Heartbeat(t *testing.T) {
+	srv, _ := newTestServer(t)
+	srv.SSEHeartbeat = 20 * time.Millisecond
+
+	ts := httptest.NewServer(srv.routes())
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", ts.URL+"/events", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET /events: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// No traffic at all: the stream must still carry heartbeat comments,
+	// each a complete SSE frame
+	rd := bufio.NewReader(resp.Body)
+	for i := 0; i < 2; i++ {
+		line, err := rd.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read heartbeat %d: %v", i, err)
+		}
+		if line != ": ping\n" {
+			t.Fatalf("line = %q, want heartbeat comment", line)
+		}
+		if blank, _ := rd.ReadString('\n'); blank != "\n" {
+			t.Fatalf("heartbeat not terminated by blank line: %q", blank)
+		}
+	}
+}
+
+func TestSSE_SessionFilter(t *testing.T) {
+	srv, _ := newTestServer(t)
+	ts := httptest.NewServer(srv.routes())
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", ts.URL+"/events?session_id=s1", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET /events: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// The stream is open, so the subscription exists
+	srv.eventBus.Publish(&store.LogEntry{ID: 1, SessionID: "s1", Kind: "request", Method: "tools/first"})
+	srv.eventBus.Publish(&store.LogEntry{ID: 2, SessionID: "s2", Kind: "request", Method: "tools/other"})
+	srv.eventBus.Publish(&store.LogEntry{ID: 3, SessionID: "s1", Kind: "request", Method: "tools/second"})
+
+	var stream strings.Builder
+	rd := bufio.NewReader(resp.Body)
+	for events := 0; events < 2; {
+		line, err := rd.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read: %v\n%s", err, stream.String())
+		}
+		stream.WriteString(line)
+		if line == "event: message\n" {
+			events++
+		}
+	}
+	// Read the rest of the second event
+	for {
+		line, err := rd.ReadString('\n')
+		if err != nil || line == "\n" {
+			break
+		}
+		stream.WriteString(line)
+	}
+
+	got := stream.String()
+	if !strings.Contains(got, "tools/first") || !strings.Contains(got, "tools/second") {
+		t.Errorf("stream is missing s1's events:\n%s", got)
+	}
+	if strings.Contains(got, "tools/other") {
+		t.Errorf("stream includes another session's event:\n%s", got)
+	}
+}
+
+func TestToolTimeline(t *testing.T) {
+	srv, st := newTestServer(t)
+	ctx := context.Background()
+
+	st.RegisterTools(ctx, "s1", []store.ToolRecord{{ToolName: "read_file"}})
+	st.RegisterTools(ctx, "s1", []store.ToolRecord{{ToolName: "late_tool", MidSession: true}})
+
+	rec := httptest.NewRecorder()
+	srv.routes().ServeHTTP(rec, httptest.NewRequest("GET", "/api/sessions/s1/tools/timeline", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	var timeline []store.ToolTimelineEntry
+	if err := json.Unmarshal(rec.Body.Bytes(), &timeline); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(timeline) != 2 || timeline[1].ToolName != "late_tool" || !timeline[1].AddedMidSession {
+		t.Errorf("timeline = %+v, want late_tool flagged last", timeline)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.routes().ServeHTTP(rec, httptest.NewRequest("GET", "/partials/tool-timeline?session_id=s1", nil))
+	if body := rec.Body.String(); !strings.Contains(body, "late_tool") || !strings.Contains(body, "Added mid-session") {
+		t.Errorf("partial missing flagged tool:\n%s", body)
+	}
+}
+
+func TestSessionDBSelection(t *testing.T) {
+	srv, current := newTestServer(t)
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+
+	dir := t.TempDir()
+	other, err := store.NewSQLiteStore(store.SessionDBPath(dir, "other"), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.LogMessage(ctx, &store.LogEntry{Timestamp: time.Now(), SessionID: "other", Direction: "host_to_server",
+		Kind: "request", Method: "tools/list", Payload: "{}"})
+	other.Close()
+	current.LogMessage(ctx, &store.LogEntry{Timestamp: time.Now(), SessionID: "current", Direction: "host_to_server",
+		Kind: "request", Method: "initialize", Payload: "{}"})
+	current.Flush()
+
+	srv.SessionDBs = store.NewSessionDBs(dir, logger)
+	t.Cleanup(func() { srv.SessionDBs.Close() })
+	handler := srv.selectDB(srv.routes())
+
+	get := func(url string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", url, nil))
+		return rec
+	}
+	methods := func(rec *httptest.ResponseRecorder) []string {
+		var msgs []store.LogEntry
+		if err := json.Unmarshal(rec.Body.Bytes(), &msgs); err != nil {
+			t.Fatalf("decode: %v\n%s", err, rec.Body)
+		}
+		var out []string
+		for _, m := range msgs {
+			out = append(out, m.Method)
+		}
+		return out
+	}
+
+	if got := methods(get("/api/messages")); len(got) != 1 || got[0] != "initialize" {
+		t.Errorf("default store served %v", got)
+	}
+	if got := methods(get("/api/messages?db=other")); len(got) != 1 || got[0] != "tools/list" {
+		t.Errorf("?db=other served %v", got)
+	}
+	if rec := get("/api/messages?db=nope"); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown db: status %d, want 404", rec.Code)
+	}
+	if got := methods(get("/api/messages?db=all")); len(got) != 1 || got[0] != "tools/list" {
+		t.Errorf("?db=all served %v", got)
+	}
+
+	var dbs []store.SessionDB
+	json.Unmarshal(get("/api/session-dbs").Body.Bytes(), &dbs)
+	if len(dbs) != 1 || dbs[0].SessionID != "other" {
+		t.Errorf("session-dbs = %+v", dbs)
+	}
+}
+
+func TestCancelApproval(t *testing.T) {
+	srv, _ := newTestServer(t)
+	srv.approvalMgr = proxy.NewApprovalManager(10 * time.Second)
+	done := srv.approvalMgr.Submit(&proxy.ApprovalRequest{SessionID: "sess-1", Method: "tools/call", ToolName: "delete_file"})
+
+	pending := func() []proxy.ApprovalRequest {
+		rec := httptest.NewRecorder()
+		srv.routes().ServeHTTP(rec, httptest.NewRequest("GET", "/api/approvals/pending", nil))
+		var reqs []proxy.ApprovalRequest
+		if err := json.Unmarshal(rec.Body.Bytes(), &reqs); err != nil {
+			t.Fatalf("decode: %v\n%s", err, rec.Body)
+		}
+		return reqs
+	}
+	reqs := pending()
+	if len(reqs) != 1 {
+		t.Fatalf("got %d pending approvals, want 1", len(reqs))
+	}
+
+	rec := httptest.NewRecorder()
+	srv.routes().ServeHTTP(rec, httptest.NewRequest("POST", "/api/approvals/"+reqs[0].ID+"/cancel", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "Cancelled") {
+		t.Fatalf("cancel: status %d, body %q", rec.Code, rec.Body)
+	}
+	select {
+	case d := <-done:
+		if d != proxy.DecisionCancelled {
+			t.Errorf("decision = %v, want cancelled", d)
+		}
+	default:
+		t.Error("waiting interceptor was not resolved")
+	}
+	if reqs := pending(); len(reqs) != 0 {
+		t.Errorf("cancelled request still pending: %+v", reqs)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.routes().ServeHTTP(rec, httptest.NewRequest("POST", "/api/approvals/"+reqs[0].ID+"/cancel", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("second cancel: status %d, want 404", rec.Code)
+	}
+}
+
+func TestAPIMessagesArgumentFilter(t *testing.T) {
+	srv, st := newTestServer(t)
+	ctx := context.Background()
+	for i, path := range []string{"/etc/passwd", "/tmp/x"} {
+		st.LogMessage(ctx, &store.LogEntry{Timestamp: time.Now(), SessionID: "s1", Direction: "host_to_server", Kind: "request",
+			Method: "tools/call", MsgID: fmt.Sprint(i), Payload: "{}", Arguments: json.RawMessage(`{"path":"` + path + `"}`)})
+	}
+	st.Flush()
+
+	rec := httptest.NewRecorder()
+	srv.routes().ServeHTTP(rec, httptest.NewRequest("GET", "/api/messages?arg.path=/etc/*", nil))
+	var msgs []store.LogEntry
+	if err := json.Unmarshal(rec.Body.Bytes(), &msgs); err != nil {
+		t.Fatalf("decode: %v\n%s", err, rec.Body)
+	}
+	if len(msgs) != 1 || msgs[0].MsgID != "0" {
+		t.Errorf("arg.path=/etc/* returned %+v, want message 0", msgs)
+	}
+}
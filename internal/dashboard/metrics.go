@@ -0,0 +1,141 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/contextgate/contextgate/internal/proxy"
+)
+
+// writeMetric writes one metric in the Prometheus text exposition format.
+func writeMetric(w io.Writer, name, typ, help string, value any) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, typ)
+	fmt.Fprintf(w, "%s %v\n", name, value)
+}
+
+// handleMetrics serves live proxy counters in Prometheus text format.
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	writeMetric(w, "contextgate_eventbus_subscribers", "gauge",
+		"Active live-view subscribers.", s.eventBus.SubscriberCount())
+	writeMetric(w, "contextgate_eventbus_dropped_total", "counter",
+		"Events dropped because a live-view subscriber fell behind.", s.eventBus.DroppedCount())
+
+	if s.approvalMgr != nil {
+		writeMetric(w, "contextgate_approvals_pending", "gauge",
+			"Approval requests awaiting a decision.", s.approvalMgr.PendingCount())
+	}
+
+	if s.Latency != nil {
+		writeLatencyHistogram(w, s.Latency.Snapshot())
+	}
+
+	if s.Messages != nil {
+		writeHistogram(w, "contextgate_message_size_bytes",
+			"Size of each message passing through the proxy.", s.Messages.SizeBuckets(), s.Messages.Sizes())
+		writeHistogram(w, "contextgate_response_latency_seconds",
+			"Time from a request to its response.", s.Messages.LatencyBuckets(), s.Messages.Latencies())
+	}
+}
+
+// writeHistogram writes histograms labelled by message direction.
+func writeHistogram(w io.Writer, name, help string, bounds []float64, snaps []proxy.HistogramSnapshot) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s histogram\n", name)
+	for _, snap := range snaps {
+		for i, le := range bounds {
+			fmt.Fprintf(w, "%s_bucket{direction=%q,le=\"%g\"} %d\n", name, snap.Label, le, snap.Buckets[i])
+		}
+		fmt.Fprintf(w, "%s_bucket{direction=%q,le=\"+Inf\"} %d\n", name, snap.Label, snap.Count)
+		fmt.Fprintf(w, "%s_sum{direction=%q} %g\n", name, snap.Label, snap.Sum)
+		fmt.Fprintf(w, "%s_count{direction=%q} %d\n", name, snap.Label, snap.Count)
+	}
+}
+
+// writeLatencyHistogram writes per-interceptor processing times as a
+// Prometheus histogram labelled by interceptor.
+func writeLatencyHistogram(w io.Writer, snaps []proxy.LatencySnapshot) {
+	const name = "contextgate_interceptor_duration_seconds"
+	fmt.Fprintf(w, "# HELP %s Time each interceptor spends processing a message.\n", name)
+	fmt.Fprintf(w, "# TYPE %s histogram\n", name)
+	for _, snap := range snaps {
+		for i, le := range proxy.LatencyBuckets {
+			fmt.Fprintf(w, "%s_bucket{interceptor=%q,le=\"%g\"} %d\n", name, snap.Interceptor, le.Seconds(), snap.Buckets[i])
+		}
+		fmt.Fprintf(w, "%s_bucket{interceptor=%q,le=\"+Inf\"} %d\n", name, snap.Interceptor, snap.Count)
+		fmt.Fprintf(w, "%s_sum{interceptor=%q} %g\n", name, snap.Interceptor, snap.Sum.Seconds())
+		fmt.Fprintf(w, "%s_count{interceptor=%q} %d\n", name, snap.Interceptor, snap.Count)
+	}
+}
+
+// interceptorLatency is one row of the interceptor latency debug view.
+type interceptorLatency struct {
+	Interceptor string  `json:"interceptor"`
+	Count       uint64  `json:"count"`
+	AvgMs       float64 `json:"avg_ms"`
+	MaxMs       float64 `json:"max_ms"`
+	TotalMs     float64 `json:"total_ms"`
+}
+
+// handleInterceptorLatency returns average and worst-case processing times
+// per interceptor, for finding the one slowing the proxy down.
+func (s *Server) handleInterceptorLatency(w http.ResponseWriter, r *http.Request) {
+	rows := []interceptorLatency{}
+	if s.Latency != nil {
+		for _, snap := range s.Latency.Snapshot() {
+			rows = append(rows, interceptorLatency{
+				Interceptor: snap.Interceptor,
+				Count:       snap.Count,
+				AvgMs:       float64(snap.Avg()) / 1e6,
+				MaxMs:       float64(snap.Max) / 1e6,
+				TotalMs:     float64(snap.Sum) / 1e6,
+			})
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rows)
+}
+
+// scrubPatternTiming is one row of the scrubber profile debug view.
+type scrubPatternTiming struct {
+	Pattern      string  `json:"pattern"`
+	Calls        uint64  `json:"calls"`
+	Matches      uint64  `json:"matches"`
+	TotalMs      float64 `json:"total_ms"`
+	MBPerSec     float64 `json:"mb_per_sec"`
+	ShareOfScrub float64 `json:"share_of_scrub"` // fraction of all pattern time
+}
+
+// handleScrubberProfile returns per-pattern scrub times, slowest first,
+// when the proxy runs with -scrub-profile.
+func (s *Server) handleScrubberProfile(w http.ResponseWriter, r *http.Request) {
+	rows := []scrubPatternTiming{}
+	if s.scrubber != nil && s.scrubber.Profile != nil {
+		snaps := s.scrubber.Profile.Snapshot()
+		var total float64
+		for _, snap := range snaps {
+			total += float64(snap.Duration)
+		}
+		for _, snap := range snaps {
+			row := scrubPatternTiming{
+				Pattern:  snap.Pattern,
+				Calls:    snap.Calls,
+				Matches:  snap.Matches,
+				TotalMs:  float64(snap.Duration) / 1e6,
+				MBPerSec: snap.Throughput() / 1e6,
+			}
+			if total > 0 {
+				row.ShareOfScrub = float64(snap.Duration) / total
+			}
+			rows = append(rows, row)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rows)
+}
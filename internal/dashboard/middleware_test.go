@@ -0,0 +1,99 @@
+package dashboard
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAccessLog(t *testing.T) {
+	srv, _ := newTestServer(t)
+	var logs bytes.Buffer
+	srv.logger = slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	h := srv.accessLog(srv.routes())
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/api/sessions/missing", nil))
+
+	var line map[string]any
+	if err := json.Unmarshal(logs.Bytes(), &line); err != nil {
+		t.Fatalf("expected one JSON log line, got %q: %v", logs.String(), err)
+	}
+	if line["msg"] != "dashboard request" || line["level"] != "DEBUG" {
+		t.Errorf("unexpected log record: %v", line)
+	}
+	if line["method"] != "GET" || line["path"] != "/api/sessions/missing" {
+		t.Errorf("method/path = %v %v", line["method"], line["path"])
+	}
+	if line["status"] != float64(http.StatusNotFound) {
+		t.Errorf("status = %v, want 404", line["status"])
+	}
+	if line["bytes"] != float64(rec.Body.Len()) {
+		t.Errorf("bytes = %v, want %d", line["bytes"], rec.Body.Len())
+	}
+	if _, ok := line["duration"]; !ok {
+		t.Error("missing duration")
+	}
+}
+
+func TestAccessLog_KeepsFlusher(t *testing.T) {
+	rec := &statusRecorder{ResponseWriter: httptest.NewRecorder()}
+	if _, ok := http.ResponseWriter(rec).(http.Flusher); !ok {
+		t.Fatal("recorder must implement http.Flusher for SSE")
+	}
+}
+
+func TestCORS(t *testing.T) {
+	srv, _ := newTestServer(t)
+	h := srv.cors(srv.routes())
+
+	get := func(path, origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Origin", origin)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Not configured: no CORS headers
+	if got := get("/api/stats", "https://ui.example.com").Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("unconfigured Allow-Origin = %q, want none", got)
+	}
+
+	srv.CORSOrigins = []string{"https://ui.example.com"}
+	rec := get("/api/stats", "https://ui.example.com")
+	if rec.Code != http.StatusOK || rec.Header().Get("Access-Control-Allow-Origin") != "https://ui.example.com" {
+		t.Errorf("allowed origin: status %d, Allow-Origin %q", rec.Code, rec.Header().Get("Access-Control-Allow-Origin"))
+	}
+	if got := get("/api/stats", "https://evil.example.com").Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("other origin Allow-Origin = %q, want none", got)
+	}
+	if got := get("/partials/stats", "https://ui.example.com").Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("partial Allow-Origin = %q, want none (API only)", got)
+	}
+
+	// Preflight
+	req := httptest.NewRequest("OPTIONS", "/api/approve/abc", nil)
+	req.Header.Set("Origin", "https://ui.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	req.Header.Set("Access-Control-Request-Headers", "Content-Type")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("preflight status = %d, want 204", rec.Code)
+	}
+	if rec.Header().Get("Access-Control-Allow-Origin") != "https://ui.example.com" ||
+		!strings.Contains(rec.Header().Get("Access-Control-Allow-Methods"), "POST") ||
+		rec.Header().Get("Access-Control-Allow-Headers") != "Content-Type" {
+		t.Errorf("preflight headers = %v", rec.Header())
+	}
+
+	srv.CORSOrigins = []string{"*"}
+	if got := get("/api/stats", "https://anywhere.example").Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("wildcard Allow-Origin = %q, want *", got)
+	}
+}
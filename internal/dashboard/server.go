@@ -2,39 +2,104 @@ package dashboard
 
 import (
 	"context"
+	"crypto/tls"
 	"embed"
 	"fmt"
 	"html/template"
 	"io/fs"
 	"log/slog"
+	"net"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/contextgate/contextgate/internal/eventbus"
+	"github.com/contextgate/contextgate/internal/policy"
 	"github.com/contextgate/contextgate/internal/proxy"
 	"github.com/contextgate/contextgate/internal/store"
 )
 
-//go:embed static
-var staticFS embed.FS
+// embeddedAssets holds the static/ and templates/ trees built into the
+// binary; see SetAssetsDir to override them.
+//
+//go:embed static templates
+var embeddedAssets embed.FS
 
-//go:embed templates
-var templateFS embed.FS
+// DefaultSSEHeartbeat is the default interval between SSE keep-alive comments.
+const DefaultSSEHeartbeat = 15 * time.Second
 
 // Server is the HTMX dashboard HTTP server.
 type Server struct {
-	store          store.Store
-	eventBus       *eventbus.EventBus
-	approvalMgr    *proxy.ApprovalManager
-	scrubber       *proxy.ScrubberInterceptor
-	toolAnalytics  *proxy.ToolAnalyticsInterceptor
-	logger         *slog.Logger
-	tmpl           *template.Template
-	addr           string
+	// TLSCertFile and TLSKeyFile serve the dashboard over HTTPS when both
+	// are set. TLSSelfSigned serves HTTPS with a generated certificate.
+	TLSCertFile   string
+	TLSKeyFile    string
+	TLSSelfSigned bool
+
+	// SSEHeartbeat is the interval between keep-alive comments on the live
+	// event stream (0 disables them).
+	SSEHeartbeat time.Duration
+
+	// Latency, if set, exposes per-interceptor processing times on
+	// /metrics and /api/debug/interceptors.
+	Latency *proxy.InterceptorLatency
+
+	// Messages, if set, exposes message size and response latency
+	// histograms on /metrics.
+	Messages *proxy.MessageMetrics
+
+	// SessionDBs, if set, lets read endpoints serve another session's
+	// database with ?db=<session-id> (see -db-per-session).
+	SessionDBs *store.SessionDBs
+
+	// CORSOrigins lists the origins allowed to call the JSON API from a
+	// browser ("*" for any). Empty means same-origin only.
+	CORSOrigins []string
+
+	// AdminToken enables the destructive /api/admin/ endpoints for
+	// requests carrying it as a bearer token. Empty disables them.
+	AdminToken string
+
+	// Policy, if set, lets /api/messages/{id}/explain report the action
+	// and message of each rule a message matched.
+	Policy *policy.Engine
+
+	// Freeze, if set, is switched on and off with POST /api/freeze.
+	Freeze *proxy.FreezeInterceptor
+
+	store         store.Store
+	eventBus      *eventbus.EventBus
+	approvalMgr   *proxy.ApprovalManager
+	scrubber      *proxy.ScrubberInterceptor
+	toolAnalytics *proxy.ToolAnalyticsInterceptor
+	logger        *slog.Logger
+	assets        fs.FS // static/ and templates/
+	tmpl          *template.Template
+	addr          string
 }
 
 func NewServer(addr string, s store.Store, eb *eventbus.EventBus, approvalMgr *proxy.ApprovalManager, scrubber *proxy.ScrubberInterceptor, toolAnalytics *proxy.ToolAnalyticsInterceptor, logger *slog.Logger) (*Server, error) {
+	tmpl, err := parseTemplates(embeddedAssets)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Server{
+		store:         s,
+		eventBus:      eb,
+		approvalMgr:   approvalMgr,
+		scrubber:      scrubber,
+		toolAnalytics: toolAnalytics,
+		logger:        logger,
+		assets:        embeddedAssets,
+		tmpl:          tmpl,
+		addr:          addr,
+		SSEHeartbeat:  DefaultSSEHeartbeat,
+	}, nil
+}
+
+// parseTemplates parses the page and partial templates in fsys.
+func parseTemplates(fsys fs.FS) (*template.Template, error) {
 	funcMap := template.FuncMap{
 		"formatTime": func(t time.Time) string {
 			return t.Format("15:04:05.000")
@@ -80,32 +145,22 @@ func NewServer(addr string, s store.Store, eb *eventbus.EventBus, approvalMgr *p
 		},
 	}
 
-	tmpl, err := template.New("").Funcs(funcMap).ParseFS(templateFS,
+	tmpl, err := template.New("").Funcs(funcMap).ParseFS(fsys,
 		"templates/*.html",
 		"templates/partials/*.html",
 	)
 	if err != nil {
 		return nil, fmt.Errorf("parse templates: %w", err)
 	}
-
-	return &Server{
-		store:         s,
-		eventBus:      eb,
-		approvalMgr:   approvalMgr,
-		scrubber:      scrubber,
-		toolAnalytics: toolAnalytics,
-		logger:        logger,
-		tmpl:          tmpl,
-		addr:          addr,
-	}, nil
+	return tmpl, nil
 }
 
-// Start starts the HTTP server. Blocks until context is cancelled.
-func (s *Server) Start(ctx context.Context) error {
+// routes builds the HTTP handler with all dashboard routes registered.
+func (s *Server) routes() *http.ServeMux {
 	mux := http.NewServeMux()
 
 	// Static assets
-	staticSub, _ := fs.Sub(staticFS, "static")
+	staticSub, _ := fs.Sub(s.assets, "static")
 	mux.Handle("GET /static/", http.StripPrefix("/static/", http.FileServer(http.FS(staticSub))))
 
 	// Pages
@@ -118,23 +173,85 @@ func (s *Server) Start(ctx context.Context) error {
 	// HTMX partials
 	mux.HandleFunc("GET /partials/stats", s.handleStatsPartial)
 	mux.HandleFunc("GET /partials/tool-analytics", s.handleToolAnalyticsPartial)
+	mux.HandleFunc("GET /partials/blocked-leaderboard", s.handleBlockedLeaderboardPartial)
+	mux.HandleFunc("GET /partials/unrecognized-methods", s.handleUnrecognizedMethodsPartial)
+	mux.HandleFunc("GET /partials/tool-conflicts", s.handleToolConflictsPartial)
+	mux.HandleFunc("GET /partials/tool-timeline", s.handleToolTimelinePartial)
+	mux.HandleFunc("GET /partials/tool-args", s.handleToolArgsPartial)
 
 	// JSON API
 	mux.HandleFunc("GET /api/messages", s.handleAPIMessages)
+	mux.HandleFunc("POST /api/messages/{id}/annotate", s.handleAnnotateMessage)
+	mux.HandleFunc("GET /api/messages/{id}/explain", s.handleExplainMessage)
 	mux.HandleFunc("GET /api/stats", s.handleAPIStats)
 	mux.HandleFunc("GET /api/tools/analytics", s.handleToolAnalytics)
+	mux.HandleFunc("GET /api/tools/conflicts", s.handleToolConflicts)
+	mux.HandleFunc("GET /api/tools/{name}/args", s.handleToolArgUsage)
+	mux.HandleFunc("GET /api/blocked/leaderboard", s.handleBlockedLeaderboard)
+	mux.HandleFunc("GET /api/methods/unrecognized", s.handleUnrecognizedMethods)
+	mux.HandleFunc("GET /api/sessions/{id}", s.handleSessionDetail)
+	mux.HandleFunc("GET /api/sessions/{id}/report", s.handleSessionReport)
+	mux.HandleFunc("GET /api/sessions/{id}/tools/timeline", s.handleToolTimeline)
+	mux.HandleFunc("POST /api/policy/simulate", s.handlePolicySimulate)
+	mux.HandleFunc("GET /api/session-dbs", s.handleSessionDBs)
+	mux.HandleFunc("GET /api/freeze", s.handleFreezeState)
+	mux.HandleFunc("POST /api/freeze", s.handleFreeze)
+
+	// Metrics
+	mux.HandleFunc("GET /metrics", s.handleMetrics)
+	mux.HandleFunc("GET /api/debug/interceptors", s.handleInterceptorLatency)
+	mux.HandleFunc("GET /api/debug/scrubber", s.handleScrubberProfile)
 
 	// Approval API
 	mux.HandleFunc("POST /api/approve/{id}", s.handleApprove)
 	mux.HandleFunc("POST /api/deny/{id}", s.handleDeny)
 	mux.HandleFunc("GET /api/approvals/pending", s.handlePendingApprovals)
+	mux.HandleFunc("POST /api/approvals/{id}/cancel", s.handleCancelApproval)
+
+	// Admin (destructive, token required)
+	mux.HandleFunc("POST /api/admin/clear", s.requireAdmin(s.handleAdminClear))
+
+	return mux
+}
+
+// URL returns the local address the dashboard can be opened at.
+func (s *Server) URL() string {
+	scheme := "http"
+	if s.tlsEnabled() {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://localhost%s", scheme, s.addr)
+}
 
+// Start starts the HTTP server. Blocks until context is cancelled.
+func (s *Server) Start(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	s.logger.Info("dashboard starting", "url", s.URL())
+	return s.serve(ctx, ln)
+}
+
+// serve runs the dashboard on ln until ctx is cancelled.
+func (s *Server) serve(ctx context.Context, ln net.Listener) error {
 	server := &http.Server{
-		Addr:              s.addr,
-		Handler:           mux,
+		Handler:           s.accessLog(s.cors(s.selectDB(s.routes()))),
 		ReadHeaderTimeout: 10 * time.Second,
 	}
 
+	if s.tlsEnabled() {
+		cfg, err := s.tlsConfig()
+		if err != nil {
+			ln.Close()
+			return err
+		}
+		server.TLSConfig = cfg
+		// A non-nil empty map keeps net/http from negotiating HTTP/2
+		server.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+		ln = tls.NewListener(ln, cfg)
+	}
+
 	go func() {
 		<-ctx.Done()
 		shutCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -142,8 +259,7 @@ func (s *Server) Start(ctx context.Context) error {
 		server.Shutdown(shutCtx)
 	}()
 
-	s.logger.Info("dashboard starting", "url", fmt.Sprintf("http://localhost%s", s.addr))
-	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	if err := server.Serve(ln); err != nil && err != http.ErrServerClosed {
 		return err
 	}
 	return nil
@@ -0,0 +1,77 @@
+package dashboard
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/contextgate/contextgate/internal/store"
+)
+
+type storeCtxKey struct{}
+
+// allSessionDBs is the ?db= value that combines every session database.
+const allSessionDBs = "all"
+
+// selectDB serves requests carrying ?db=<session-id> from that session's
+// own database when SessionDBs is set, and ?db=all from all of them,
+// read-only. Requests without it use the proxy's store.
+func (s *Server) selectDB(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.URL.Query().Get("db")
+		if id == "" || s.SessionDBs == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if id == allSessionDBs {
+			all, err := s.SessionDBs.OpenAll()
+			if err != nil {
+				s.logger.Error("open session databases", "error", err)
+				http.Error(w, "internal error", http.StatusInternalServerError)
+				return
+			}
+			defer all.Close()
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), storeCtxKey{}, store.Store(all))))
+			return
+		}
+		st, err := s.SessionDBs.Open(id)
+		if errors.Is(err, store.ErrUnknownSessionDB) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			s.logger.Error("open session database", "session", id, "error", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), storeCtxKey{}, store.Store(st))))
+	})
+}
+
+// storeFor returns the store a request reads from.
+func (s *Server) storeFor(r *http.Request) store.Store {
+	if st, ok := r.Context().Value(storeCtxKey{}).(store.Store); ok {
+		return st
+	}
+	return s.store
+}
+
+// handleSessionDBs lists the per-session databases that can be selected
+// with ?db=.
+func (s *Server) handleSessionDBs(w http.ResponseWriter, r *http.Request) {
+	dbs := []store.SessionDB{}
+	if s.SessionDBs != nil {
+		found, err := s.SessionDBs.List()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if found != nil {
+			dbs = found
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dbs)
+}
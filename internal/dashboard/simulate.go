@@ -0,0 +1,144 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/contextgate/contextgate/internal/policy"
+	"github.com/contextgate/contextgate/internal/store"
+)
+
+const (
+	maxSimulatePolicyBytes = 1 << 20
+	defaultSimulateLimit   = 1000
+)
+
+// simulatedMessage is what a candidate policy would have done to one
+// stored message, next to what actually happened.
+type simulatedMessage struct {
+	ID             int64    `json:"id"`
+	Seq            int64    `json:"seq"`
+	Method         string   `json:"method"`
+	ToolName       string   `json:"tool_name,omitempty"`
+	Action         string   `json:"action"` // deny, require_approval, audit or allow
+	Rule           string   `json:"rule,omitempty"`
+	MatchedRules   []string `json:"matched_rules,omitempty"`
+	RecordedAction string   `json:"recorded_action"`
+	Changed        bool     `json:"changed"`
+}
+
+type simulationResult struct {
+	SessionID string             `json:"session_id"`
+	Evaluated int                `json:"evaluated"`
+	Actions   map[string]int     `json:"actions"`
+	Changed   int                `json:"changed"`
+	Messages  []simulatedMessage `json:"messages"`
+}
+
+// handlePolicySimulate evaluates the policy YAML in the request body
+// against a session's stored host→server messages and reports what it
+// would have done. Nothing is enforced and the running policy is untouched.
+func (s *Server) handlePolicySimulate(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	sessionID := q.Get("session_id")
+	if sessionID == "" {
+		http.Error(w, "session_id is required", http.StatusBadRequest)
+		return
+	}
+	limit := defaultSimulateLimit
+	if n, err := strconv.Atoi(q.Get("limit")); err == nil && n > 0 {
+		limit = n
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxSimulatePolicyBytes))
+	if err != nil {
+		http.Error(w, "read policy: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	cfg, err := policy.LoadBytes(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	engine := policy.NewEngine(cfg)
+
+	var client string
+	if session, err := s.storeFor(r).GetSession(r.Context(), sessionID); err == nil {
+		client = session.Client
+	}
+	entries, err := s.storeFor(r).Query(r.Context(), store.QueryFilter{
+		SessionID: sessionID,
+		Direction: "host_to_server",
+		Limit:     limit,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	slices.Reverse(entries) // oldest first
+
+	result := simulationResult{
+		SessionID: sessionID,
+		Actions:   make(map[string]int),
+		Messages:  make([]simulatedMessage, 0, len(entries)),
+	}
+	for _, e := range entries {
+		m := simulateEntry(engine, e, client)
+		result.Actions[m.Action]++
+		if m.Changed {
+			result.Changed++
+		}
+		result.Messages = append(result.Messages, m)
+	}
+	result.Evaluated = len(result.Messages)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// simulateEntry runs one stored message, sent by client, through engine.
+func simulateEntry(engine *policy.Engine, e store.LogEntry, client string) simulatedMessage {
+	var payload struct {
+		Params json.RawMessage `json:"params"`
+	}
+	json.Unmarshal([]byte(e.Payload), &payload)
+
+	res := engine.EvaluateInput(policy.Input{
+		Direction: e.Direction,
+		Method:    e.Method,
+		ToolName:  e.ToolName,
+		Payload:   e.Payload,
+		Params:    payload.Params,
+		Client:    client,
+	})
+
+	m := simulatedMessage{
+		ID:             e.ID,
+		Seq:            e.Seq,
+		Method:         e.Method,
+		ToolName:       e.ToolName,
+		Action:         actionOrAllow(string(res.Action)),
+		MatchedRules:   res.MatchedRules,
+		RecordedAction: actionOrAllow(e.PolicyAction),
+	}
+	switch res.Action {
+	case policy.ActionDeny:
+		m.Rule = res.DenyRule
+	case policy.ActionRequireApproval:
+		m.Rule = res.ApprovalRule
+	case policy.ActionQuarantine:
+		m.Rule = res.QuarantineRule
+	}
+	m.Changed = m.Action != m.RecordedAction
+	return m
+}
+
+func actionOrAllow(action string) string {
+	if action == "" {
+		return "allow"
+	}
+	return action
+}
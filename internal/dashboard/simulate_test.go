@@ -0,0 +1,99 @@
+package dashboard
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/contextgate/contextgate/internal/store"
+)
+
+func TestPolicySimulate(t *testing.T) {
+	srv, st := newTestServer(t)
+	ctx := context.Background()
+
+	call := func(seq int64, tool, args, recorded string) *store.LogEntry {
+		return &store.LogEntry{
+			Seq: seq, Timestamp: time.Now(), SessionID: "s1", Direction: "host_to_server", Kind: "request",
+			Method: "tools/call", ToolName: tool, PolicyAction: recorded,
+			Payload: `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"` + tool + `","arguments":` + args + `}}`,
+		}
+	}
+	for _, e := range []*store.LogEntry{
+		call(1, "read_file", `{"path":"/tmp/a"}`, ""),
+		call(2, "delete_file", `{"path":"/tmp/a"}`, "audit"),
+		call(3, "git_push", `{"options":{"force":true}}`, ""),
+		// Responses and other sessions are not evaluated
+		{Seq: 4, Timestamp: time.Now(), SessionID: "s1", Direction: "server_to_host", Kind: "response", Payload: `{"jsonrpc":"2.0","id":1,"result":{}}`},
+		call(5, "delete_file", `{}`, ""),
+	} {
+		if e.Seq == 5 {
+			e.SessionID = "s2"
+		}
+		st.LogMessage(ctx, e)
+	}
+	st.Flush()
+
+	policyYAML := `
+rules:
+  - name: no-delete
+    action: deny
+    tools: ["delete_file"]
+  - name: no-force
+    action: require_approval
+    args:
+      - pointer: /arguments/options/force
+        equals: true
+`
+	rec := httptest.NewRecorder()
+	srv.routes().ServeHTTP(rec, httptest.NewRequest("POST", "/api/policy/simulate?session_id=s1", strings.NewReader(policyYAML)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var res simulationResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if res.Evaluated != 3 || len(res.Messages) != 3 {
+		t.Fatalf("evaluated %d messages, want 3: %+v", res.Evaluated, res.Messages)
+	}
+
+	want := []struct{ tool, action, rule, recorded string }{
+		{"read_file", "allow", "", "allow"},
+		{"delete_file", "deny", "no-delete", "audit"},
+		{"git_push", "require_approval", "no-force", "allow"},
+	}
+	for i, w := range want {
+		m := res.Messages[i]
+		if m.ToolName != w.tool || m.Action != w.action || m.Rule != w.rule || m.RecordedAction != w.recorded {
+			t.Errorf("message %d = %+v, want %+v", i, m, w)
+		}
+	}
+	if res.Changed != 2 {
+		t.Errorf("changed = %d, want 2", res.Changed)
+	}
+	if res.Actions["deny"] != 1 || res.Actions["require_approval"] != 1 || res.Actions["allow"] != 1 {
+		t.Errorf("actions = %v", res.Actions)
+	}
+}
+
+func TestPolicySimulate_BadRequests(t *testing.T) {
+	srv, _ := newTestServer(t)
+
+	for _, tc := range []struct{ url, body string }{
+		{"/api/policy/simulate", "rules: []"},
+		{"/api/policy/simulate?session_id=s1", "rules: ["},
+		{"/api/policy/simulate?session_id=s1", "rules:\n  - name: r\n    action: deny\n    patterns: ['[bad']\n"},
+	} {
+		rec := httptest.NewRecorder()
+		srv.routes().ServeHTTP(rec, httptest.NewRequest("POST", tc.url, strings.NewReader(tc.body)))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s with %q: status = %d, want 400", tc.url, tc.body, rec.Code)
+		}
+	}
+}
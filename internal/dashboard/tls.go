@@ -0,0 +1,73 @@
+package dashboard
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"time"
+)
+
+// tlsEnabled reports whether the dashboard should be served over HTTPS.
+func (s *Server) tlsEnabled() bool {
+	return s.TLSSelfSigned || (s.TLSCertFile != "" && s.TLSKeyFile != "")
+}
+
+// tlsConfig builds the TLS configuration from the configured cert/key pair,
+// or from a freshly generated self-signed certificate.
+func (s *Server) tlsConfig() (*tls.Config, error) {
+	var cert tls.Certificate
+	var err error
+	if s.TLSCertFile != "" && s.TLSKeyFile != "" {
+		cert, err = tls.LoadX509KeyPair(s.TLSCertFile, s.TLSKeyFile)
+	} else {
+		cert, err = selfSignedCert()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load tls certificate: %w", err)
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+		// HTTP/1.1 only — SSE flushing is unreliable through HTTP/2 framing
+		NextProtos: []string{"http/1.1"},
+	}, nil
+}
+
+// selfSignedCert generates an in-memory certificate valid for localhost.
+// Browsers will warn about it; it only protects against passive snooping.
+func selfSignedCert() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"ContextGate"}, CommonName: "localhost"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(365 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
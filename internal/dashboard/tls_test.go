@@ -0,0 +1,131 @@
+package dashboard
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/contextgate/contextgate/internal/store"
+)
+
+// startTLS serves srv on a random local port and returns its base URL.
+func startTLS(t *testing.T, srv *Server) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- srv.serve(ctx, ln) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("serve: %v", err)
+		}
+	})
+	return "https://" + ln.Addr().String()
+}
+
+// writeCertPair writes a generated certificate and key as PEM files.
+func writeCertPair(t *testing.T) (certFile, keyFile string, pool *x509.CertPool) {
+	t.Helper()
+	cert, err := selfSignedCert()
+	if err != nil {
+		t.Fatalf("generate cert: %v", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+
+	dir := t.TempDir()
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0o600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0o600)
+
+	leaf, _ := x509.ParseCertificate(cert.Certificate[0])
+	pool = x509.NewCertPool()
+	pool.AddCert(leaf)
+	return certFile, keyFile, pool
+}
+
+func TestServeTLS_CertFiles(t *testing.T) {
+	srv, _ := newTestServer(t)
+	certFile, keyFile, pool := writeCertPair(t)
+	srv.TLSCertFile = certFile
+	srv.TLSKeyFile = keyFile
+
+	if !strings.HasPrefix(srv.URL(), "https://") {
+		t.Errorf("URL = %q, want https scheme", srv.URL())
+	}
+
+	base := startTLS(t, srv)
+	client := &http.Client{
+		Timeout:   5 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}, ForceAttemptHTTP2: true},
+	}
+	resp, err := client.Get(base + "/api/stats")
+	if err != nil {
+		t.Fatalf("GET over TLS: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want 200", resp.StatusCode)
+	}
+	if resp.TLS == nil {
+		t.Error("response not served over TLS")
+	}
+	if resp.ProtoMajor != 1 {
+		t.Errorf("proto = %s, want HTTP/1.x (HTTP/2 disabled for SSE)", resp.Proto)
+	}
+}
+
+func TestServeTLS_SelfSignedSSE(t *testing.T) {
+	srv, _ := newTestServer(t)
+	srv.TLSSelfSigned = true
+	base := startTLS(t, srv)
+
+	client := &http.Client{
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Keep publishing until the subscriber is attached and the event arrives
+	go func() {
+		for ctx.Err() == nil {
+			srv.eventBus.Publish(&store.LogEntry{Timestamp: time.Now(), Direction: "host_to_server", Kind: "request", Method: "ping"})
+			time.Sleep(20 * time.Millisecond)
+		}
+	}()
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", base+"/events", nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("GET /events over TLS: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("content-type = %q, want text/event-stream", ct)
+	}
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil {
+		t.Fatalf("read event: %v", err)
+	}
+	if line != "event: message\n" {
+		t.Errorf("first line = %q, want SSE event", line)
+	}
+}
@@ -2,6 +2,7 @@ package eventbus
 
 import (
 	"sync"
+	"sync/atomic"
 
 	"github.com/contextgate/contextgate/internal/store"
 )
@@ -11,12 +12,20 @@ const defaultBufSize = 256
 // EventBus implements fan-out pub/sub for log entries.
 // Each subscriber gets a buffered channel. If a subscriber
 // is slow, entries are dropped for that subscriber (the
-// dashboard can query the store for missed entries).
+// dashboard can query the store for missed entries). Drops
+// are counted per subscriber and in aggregate.
 type EventBus struct {
 	mu           sync.RWMutex
-	subscribers  map[string]chan *store.LogEntry
+	subscribers  map[string]*subscriber
 	approvalSubs map[string]chan *store.ApprovalEvent
 	bufSize      int
+
+	dropped atomic.Uint64 // total across all subscribers, including departed ones
+}
+
+type subscriber struct {
+	ch      chan *store.LogEntry
+	dropped atomic.Uint64
 }
 
 func New(bufSize int) *EventBus {
@@ -24,7 +33,7 @@ func New(bufSize int) *EventBus {
 		bufSize = defaultBufSize
 	}
 	return &EventBus{
-		subscribers:  make(map[string]chan *store.LogEntry),
+		subscribers:  make(map[string]*subscriber),
 		approvalSubs: make(map[string]chan *store.ApprovalEvent),
 		bufSize:      bufSize,
 	}
@@ -33,10 +42,19 @@ func New(bufSize int) *EventBus {
 // Subscribe creates a new subscription. Returns the channel and
 // an unsubscribe function that must be called when done.
 func (eb *EventBus) Subscribe(id string) (<-chan *store.LogEntry, func()) {
-	ch := make(chan *store.LogEntry, eb.bufSize)
+	return eb.SubscribeSize(id, eb.bufSize)
+}
+
+// SubscribeSize is like Subscribe but with its own buffer size instead
+// of the bus default. Non-positive sizes use the default.
+func (eb *EventBus) SubscribeSize(id string, bufSize int) (<-chan *store.LogEntry, func()) {
+	if bufSize <= 0 {
+		bufSize = eb.bufSize
+	}
+	ch := make(chan *store.LogEntry, bufSize)
 
 	eb.mu.Lock()
-	eb.subscribers[id] = ch
+	eb.subscribers[id] = &subscriber{ch: ch}
 	eb.mu.Unlock()
 
 	unsub := func() {
@@ -54,10 +72,12 @@ func (eb *EventBus) Publish(entry *store.LogEntry) {
 	eb.mu.RLock()
 	defer eb.mu.RUnlock()
 
-	for _, ch := range eb.subscribers {
+	for _, sub := range eb.subscribers {
 		select {
-		case ch <- entry:
+		case sub.ch <- entry:
 		default:
+			sub.dropped.Add(1)
+			eb.dropped.Add(1)
 		}
 	}
 }
@@ -98,3 +118,20 @@ func (eb *EventBus) SubscriberCount() int {
 	defer eb.mu.RUnlock()
 	return len(eb.subscribers)
 }
+
+// Dropped returns how many entries a subscriber has missed because its
+// buffer was full, or 0 if there is no such subscriber.
+func (eb *EventBus) Dropped(id string) uint64 {
+	eb.mu.RLock()
+	defer eb.mu.RUnlock()
+	if sub, ok := eb.subscribers[id]; ok {
+		return sub.dropped.Load()
+	}
+	return 0
+}
+
+// DroppedCount returns the total number of entries dropped for slow
+// subscribers since the bus was created.
+func (eb *EventBus) DroppedCount() uint64 {
+	return eb.dropped.Load()
+}
@@ -84,3 +84,37 @@ func TestSlowSubscriberDoesNotBlock(t *testing.T) {
 		t.Fatal("timed out")
 	}
 }
+
+func TestDroppedCount(t *testing.T) {
+	eb := New(10)
+
+	_, unsubSlow := eb.SubscribeSize("slow", 2)
+	fast, unsubFast := eb.Subscribe("fast")
+	defer unsubFast()
+
+	for i := 0; i < 5; i++ {
+		eb.Publish(&store.LogEntry{Method: "ping"})
+	}
+
+	if got := eb.Dropped("slow"); got != 3 {
+		t.Errorf("slow dropped = %d, want 3", got)
+	}
+	if got := eb.Dropped("fast"); got != 0 {
+		t.Errorf("fast dropped = %d, want 0", got)
+	}
+	if len(fast) != 5 {
+		t.Errorf("fast received %d, want 5", len(fast))
+	}
+	if got := eb.DroppedCount(); got != 3 {
+		t.Errorf("DroppedCount = %d, want 3", got)
+	}
+
+	// Aggregate survives the subscriber leaving
+	unsubSlow()
+	if got := eb.DroppedCount(); got != 3 {
+		t.Errorf("DroppedCount after unsubscribe = %d, want 3", got)
+	}
+	if got := eb.Dropped("slow"); got != 0 {
+		t.Errorf("Dropped for departed subscriber = %d, want 0", got)
+	}
+}
@@ -0,0 +1,142 @@
+package inspect
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/contextgate/contextgate/internal/eventbus"
+	"github.com/contextgate/contextgate/internal/proxy"
+)
+
+// DefaultRefresh is how often the screen is redrawn when something changed.
+const DefaultRefresh = 250 * time.Millisecond
+
+// Inspector drives a Model from the proxy's event bus and approval
+// manager and draws it on a terminal. The terminal must not be the
+// proxy's stdin/stdout, which carry MCP traffic; main opens /dev/tty.
+type Inspector struct {
+	Model     *Model
+	Bus       *eventbus.EventBus
+	Approvals *proxy.ApprovalManager // nil disables approve/deny
+	In        io.Reader              // keyboard input, one command per line
+	Out       io.Writer
+
+	// Refresh is the redraw interval (default DefaultRefresh).
+	Refresh time.Duration
+}
+
+// Run draws the inspector until ctx is cancelled or the user quits. A
+// quit cancels only the inspector; the caller decides what happens to
+// the proxy.
+func (in *Inspector) Run(ctx context.Context) error {
+	refresh := in.Refresh
+	if refresh <= 0 {
+		refresh = DefaultRefresh
+	}
+
+	msgs, unsub := in.Bus.Subscribe("inspect")
+	defer unsub()
+
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		sc := bufio.NewScanner(in.In)
+		for sc.Scan() {
+			select {
+			case lines <- sc.Text():
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(refresh)
+	defer ticker.Stop()
+
+	dirty := true
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case e, ok := <-msgs:
+			if !ok {
+				return nil
+			}
+			in.Model.AddMessage(e)
+			dirty = true
+		case line, ok := <-lines:
+			if !ok {
+				lines = nil // input closed; keep displaying
+				continue
+			}
+			if in.handle(line) {
+				return nil
+			}
+			dirty = true
+		case <-ticker.C:
+			if in.Approvals != nil {
+				in.Model.SetPending(in.Approvals.Pending())
+			}
+			if dirty {
+				Render(in.Out, in.Model.Snapshot())
+				dirty = false
+			}
+		}
+	}
+}
+
+// handle applies one line of input and reports whether the user quit.
+func (in *Inspector) handle(line string) bool {
+	cmd, err := ParseCommand(line)
+	if err != nil {
+		in.Model.SetStatus("%v", err)
+		return false
+	}
+
+	switch cmd.Action {
+	case ActionQuit:
+		return true
+	case ActionApprove, ActionDeny:
+		if in.Approvals == nil {
+			in.Model.SetStatus("approvals are not enabled")
+			return false
+		}
+		req, err := in.Model.Target(cmd)
+		if err != nil {
+			in.Model.SetStatus("%v", err)
+			return false
+		}
+		approve := cmd.Action == ActionApprove
+		if err := in.Approvals.Resolve(req.ID, approve); err != nil {
+			in.Model.SetStatus("%v", err)
+			return false
+		}
+		verb := "denied"
+		if approve {
+			verb = "approved"
+		}
+		in.Model.SetStatus("%s %s", verb, req.ID)
+		in.Model.SetPending(in.Approvals.Pending())
+	default:
+		in.Model.SetStatus("")
+	}
+	return false
+}
+
+// LogWriter returns a writer that shows each line written to it in the
+// model's log pane, so the proxy's logs don't scroll the screen away.
+func (m *Model) LogWriter() io.Writer {
+	return logWriter{m}
+}
+
+type logWriter struct{ m *Model }
+
+func (w logWriter) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		w.m.AddLog(line)
+	}
+	return len(p), nil
+}
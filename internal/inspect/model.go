@@ -0,0 +1,202 @@
+// Package inspect is a terminal front-end to a running proxy: live
+// messages, running totals and pending approvals, with approve/deny
+// commands typed at the keyboard. It is the in-process alternative to
+// the dashboard for users who don't want a browser.
+package inspect
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/contextgate/contextgate/internal/proxy"
+	"github.com/contextgate/contextgate/internal/store"
+)
+
+// Stats are running totals over every message seen since start.
+type Stats struct {
+	Total        int
+	HostToServer int
+	ServerToHost int
+	Blocked      int
+	Audited      int
+	Errors       int
+	Tokens       int
+}
+
+// Model is the state behind the inspector's screen. Rendering only reads
+// it; all updates go through its methods, which are safe for concurrent
+// use.
+type Model struct {
+	mu        sync.Mutex
+	maxRecent int
+	maxLogs   int
+	recent    []*store.LogEntry // oldest first
+	logs      []string          // oldest first
+	stats     Stats
+	pending   []*proxy.ApprovalRequest // oldest first
+	status    string                   // result of the last command
+}
+
+// NewModel creates a model that keeps the last maxRecent messages.
+func NewModel(maxRecent int) *Model {
+	if maxRecent <= 0 {
+		maxRecent = 20
+	}
+	return &Model{maxRecent: maxRecent, maxLogs: 5}
+}
+
+// AddMessage records a message published on the event bus.
+func (m *Model) AddMessage(e *store.LogEntry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.stats.Total++
+	switch e.Direction {
+	case string(proxy.DirHostToServer):
+		m.stats.HostToServer++
+	case string(proxy.DirServerToHost):
+		m.stats.ServerToHost++
+	}
+	if e.Blocked {
+		m.stats.Blocked++
+	}
+	if e.Audit {
+		m.stats.Audited++
+	}
+	if e.Kind == string(proxy.KindError) {
+		m.stats.Errors++
+	}
+	m.stats.Tokens += e.TokenEstimate
+
+	m.recent = append(m.recent, e)
+	if over := len(m.recent) - m.maxRecent; over > 0 {
+		m.recent = append(m.recent[:0:0], m.recent[over:]...)
+	}
+}
+
+// SetPending replaces the pending approvals with the manager's current
+// list. The manager has no event for resolved or timed-out requests, so
+// the list is polled rather than tracked.
+func (m *Model) SetPending(reqs []*proxy.ApprovalRequest) {
+	sorted := append([]*proxy.ApprovalRequest(nil), reqs...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if !sorted[i].Timestamp.Equal(sorted[j].Timestamp) {
+			return sorted[i].Timestamp.Before(sorted[j].Timestamp)
+		}
+		return sorted[i].ID < sorted[j].ID
+	})
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pending = sorted
+}
+
+// AddLog records a proxy log line, keeping only the last few.
+func (m *Model) AddLog(line string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.logs = append(m.logs, line)
+	if over := len(m.logs) - m.maxLogs; over > 0 {
+		m.logs = append(m.logs[:0:0], m.logs[over:]...)
+	}
+}
+
+// SetStatus sets the line shown under the command prompt.
+func (m *Model) SetStatus(format string, args ...any) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.status = fmt.Sprintf(format, args...)
+}
+
+// Snapshot is a copy of the model for rendering.
+type Snapshot struct {
+	Recent  []*store.LogEntry
+	Logs    []string
+	Stats   Stats
+	Pending []*proxy.ApprovalRequest
+	Status  string
+}
+
+// Snapshot returns a copy of the current state.
+func (m *Model) Snapshot() Snapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return Snapshot{
+		Recent:  append([]*store.LogEntry(nil), m.recent...),
+		Logs:    append([]string(nil), m.logs...),
+		Stats:   m.stats,
+		Pending: append([]*proxy.ApprovalRequest(nil), m.pending...),
+		Status:  m.status,
+	}
+}
+
+// Action is what a typed command asks for.
+type Action int
+
+const (
+	ActionNone Action = iota
+	ActionApprove
+	ActionDeny
+	ActionQuit
+)
+
+// Command is a parsed line of keyboard input.
+type Command struct {
+	Action Action
+	Index  int // 1-based position in the pending list
+}
+
+// ParseCommand reads one line of input: "a [n]" approves and "d [n]"
+// denies the nth pending request (default the oldest), "q" quits, and an
+// empty line just redraws.
+func ParseCommand(line string) (Command, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return Command{}, nil
+	}
+
+	var cmd Command
+	switch strings.ToLower(fields[0]) {
+	case "a", "approve":
+		cmd.Action = ActionApprove
+	case "d", "deny":
+		cmd.Action = ActionDeny
+	case "q", "quit":
+		if len(fields) > 1 {
+			return Command{}, fmt.Errorf("quit takes no arguments")
+		}
+		return Command{Action: ActionQuit}, nil
+	default:
+		return Command{}, fmt.Errorf("unknown command %q (a [n], d [n], q)", fields[0])
+	}
+
+	cmd.Index = 1
+	switch len(fields) {
+	case 1:
+	case 2:
+		n, err := strconv.Atoi(fields[1])
+		if err != nil || n < 1 {
+			return Command{}, fmt.Errorf("invalid request number %q", fields[1])
+		}
+		cmd.Index = n
+	default:
+		return Command{}, fmt.Errorf("too many arguments")
+	}
+	return cmd, nil
+}
+
+// Target returns the pending request an approve or deny command refers to.
+func (m *Model) Target(cmd Command) (*proxy.ApprovalRequest, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.pending) == 0 {
+		return nil, fmt.Errorf("no pending approvals")
+	}
+	if cmd.Index < 1 || cmd.Index > len(m.pending) {
+		return nil, fmt.Errorf("no pending approval #%d (1-%d)", cmd.Index, len(m.pending))
+	}
+	return m.pending[cmd.Index-1], nil
+}
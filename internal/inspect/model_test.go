@@ -0,0 +1,126 @@
+package inspect
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/contextgate/contextgate/internal/proxy"
+	"github.com/contextgate/contextgate/internal/store"
+)
+
+func TestModel_AddMessageStats(t *testing.T) {
+	m := NewModel(10)
+	m.AddMessage(&store.LogEntry{Direction: "host_to_server", Kind: "request", Method: "tools/call", TokenEstimate: 12})
+	m.AddMessage(&store.LogEntry{Direction: "host_to_server", Kind: "request", Method: "tools/call", Blocked: true, Audit: true})
+	m.AddMessage(&store.LogEntry{Direction: "server_to_host", Kind: "error", TokenEstimate: 3})
+
+	want := Stats{Total: 3, HostToServer: 2, ServerToHost: 1, Blocked: 1, Audited: 1, Errors: 1, Tokens: 15}
+	if got := m.Snapshot().Stats; got != want {
+		t.Errorf("stats = %+v, want %+v", got, want)
+	}
+}
+
+func TestModel_RecentIsBounded(t *testing.T) {
+	m := NewModel(3)
+	for i := 1; i <= 5; i++ {
+		m.AddMessage(&store.LogEntry{MsgID: fmt.Sprint(i)})
+	}
+	snap := m.Snapshot()
+	if len(snap.Recent) != 3 || snap.Recent[0].MsgID != "3" || snap.Recent[2].MsgID != "5" {
+		t.Errorf("recent = %v, want messages 3-5", snap.Recent)
+	}
+	if snap.Stats.Total != 5 {
+		t.Errorf("total = %d, want all messages counted", snap.Stats.Total)
+	}
+}
+
+func TestModel_PendingOldestFirst(t *testing.T) {
+	m := NewModel(0)
+	now := time.Now()
+	m.SetPending([]*proxy.ApprovalRequest{
+		{ID: "apr-2", Timestamp: now},
+		{ID: "apr-1", Timestamp: now.Add(-time.Second)},
+	})
+
+	first, err := m.Target(Command{Action: ActionApprove, Index: 1})
+	if err != nil || first.ID != "apr-1" {
+		t.Fatalf("#1 = %v, %v; want apr-1", first, err)
+	}
+	if _, err := m.Target(Command{Action: ActionDeny, Index: 3}); err == nil {
+		t.Error("expected an error for an out-of-range request")
+	}
+
+	m.SetPending(nil)
+	if _, err := m.Target(Command{Action: ActionApprove, Index: 1}); err == nil {
+		t.Error("expected an error with nothing pending")
+	}
+}
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    Command
+		wantErr bool
+	}{
+		{"", Command{}, false},
+		{"a", Command{Action: ActionApprove, Index: 1}, false},
+		{"d 2", Command{Action: ActionDeny, Index: 2}, false},
+		{"  Approve 3 ", Command{Action: ActionApprove, Index: 3}, false},
+		{"q", Command{Action: ActionQuit}, false},
+		{"a 0", Command{}, true},
+		{"d x", Command{}, true},
+		{"a 1 2", Command{}, true},
+		{"z", Command{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseCommand(tt.line)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseCommand(%q) = %+v, %v; want %+v, err=%v", tt.line, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestModel_LogWriterKeepsLastLines(t *testing.T) {
+	m := NewModel(0)
+	w := m.LogWriter()
+	for i := 1; i <= 7; i++ {
+		fmt.Fprintf(w, "line %d\n", i)
+	}
+	logs := m.Snapshot().Logs
+	if len(logs) != 5 || logs[0] != "line 3" || logs[4] != "line 7" {
+		t.Errorf("logs = %q", logs)
+	}
+}
+
+func TestInspector_ApproveResolvesRequest(t *testing.T) {
+	mgr := proxy.NewApprovalManager(time.Minute)
+	first := mgr.Submit(&proxy.ApprovalRequest{ToolName: "delete_file", Timestamp: time.Now()})
+	second := mgr.Submit(&proxy.ApprovalRequest{ToolName: "send_email", Timestamp: time.Now().Add(time.Millisecond)})
+
+	in := &Inspector{Model: NewModel(0), Approvals: mgr}
+	in.Model.SetPending(mgr.Pending())
+
+	if quit := in.handle("d 2"); quit {
+		t.Fatal("deny should not quit")
+	}
+	if d := <-second; d != proxy.DecisionDenied {
+		t.Errorf("second request got %v, want denied", d)
+	}
+	in.handle("a")
+	if d := <-first; d != proxy.DecisionApproved {
+		t.Errorf("first request got %v, want approved", d)
+	}
+	if snap := in.Model.Snapshot(); len(snap.Pending) != 0 || !strings.Contains(snap.Status, "approved") {
+		t.Errorf("after both: pending=%d status=%q", len(snap.Pending), snap.Status)
+	}
+
+	in.handle("a")
+	if status := in.Model.Snapshot().Status; !strings.Contains(status, "no pending") {
+		t.Errorf("status = %q, want a nothing-pending message", status)
+	}
+	if !in.handle("q") {
+		t.Error("q should quit")
+	}
+}
@@ -0,0 +1,98 @@
+package inspect
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/contextgate/contextgate/internal/proxy"
+	"github.com/contextgate/contextgate/internal/store"
+)
+
+// ANSI sequences: clear the screen and home the cursor, and styles.
+const (
+	clearScreen = "\x1b[H\x1b[2J"
+	bold        = "\x1b[1m"
+	dim         = "\x1b[2m"
+	red         = "\x1b[31m"
+	yellow      = "\x1b[33m"
+	reset       = "\x1b[0m"
+)
+
+// lineWidth caps rendered lines so rows don't wrap on narrow terminals.
+const lineWidth = 100
+
+// Render draws a full screen for snap.
+func Render(w io.Writer, snap Snapshot) {
+	var b strings.Builder
+	b.WriteString(clearScreen)
+
+	s := snap.Stats
+	fmt.Fprintf(&b, "%scontextgate inspect%s  %d messages (%d →server, %d →host)  %d blocked  %d audited  %d errors  ~%d tokens\n\n",
+		bold, reset, s.Total, s.HostToServer, s.ServerToHost, s.Blocked, s.Audited, s.Errors, s.Tokens)
+
+	fmt.Fprintf(&b, "%sPending approvals%s\n", bold, reset)
+	if len(snap.Pending) == 0 {
+		fmt.Fprintf(&b, "  %snone%s\n", dim, reset)
+	}
+	for i, req := range snap.Pending {
+		target := req.ToolName
+		if target == "" {
+			target = req.Method
+		}
+		fmt.Fprintf(&b, "  %s%d.%s %s  %s  rule %s  %s\n", yellow, i+1, reset,
+			req.Timestamp.Format("15:04:05"), target, req.RuleName, clip(req.Payload, 50))
+	}
+
+	fmt.Fprintf(&b, "\n%sRecent messages%s\n", bold, reset)
+	for _, e := range snap.Recent {
+		b.WriteString(messageLine(e))
+		b.WriteString("\n")
+	}
+
+	if len(snap.Logs) > 0 {
+		fmt.Fprintf(&b, "\n%sLog%s\n", bold, reset)
+		for _, l := range snap.Logs {
+			fmt.Fprintf(&b, "%s%s%s\n", dim, clip(l, lineWidth), reset)
+		}
+	}
+
+	fmt.Fprintf(&b, "\n%sa [n] approve  d [n] deny  q quit%s\n", dim, reset)
+	if snap.Status != "" {
+		b.WriteString(snap.Status + "\n")
+	}
+	b.WriteString("> ")
+	io.WriteString(w, b.String())
+}
+
+func messageLine(e *store.LogEntry) string {
+	arrow := "→"
+	if e.Direction == string(proxy.DirServerToHost) {
+		arrow = "←"
+	}
+	name := e.Method
+	if e.ToolName != "" {
+		name += " " + e.ToolName
+	}
+	if name == "" {
+		name = e.Kind
+	}
+	line := fmt.Sprintf("  %s %s %-12s %s", e.Timestamp.Format("15:04:05.000"), arrow, e.Kind, name)
+	if e.MsgID != "" {
+		line += " #" + e.MsgID
+	}
+	if e.Blocked {
+		return red + clip(line+" [blocked]", lineWidth) + reset
+	}
+	return clip(line, lineWidth)
+}
+
+// clip shortens s to n runes, flattening newlines.
+func clip(s string, n int) string {
+	s = strings.ReplaceAll(s, "\n", " ")
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
@@ -0,0 +1,219 @@
+package policy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BlocklistRulePrefix prefixes the names of rules generated from a blocklist.
+const BlocklistRulePrefix = "blocklist:"
+
+// DefaultBlocklistRefresh is how often a blocklist is re-fetched by default.
+const DefaultBlocklistRefresh = 5 * time.Minute
+
+// MaxBlocklistBytes caps the size of a blocklist. A larger list is
+// rejected rather than read into memory, and the last good list stays in
+// effect.
+const MaxBlocklistBytes = 4 << 20
+
+// ParseBlocklist converts a blocklist into deny rules for tools/call. Each
+// line holds a tool name, or a /regex/ matched against the call payload.
+// Blank lines and lines starting with # are ignored.
+func ParseBlocklist(r io.Reader) ([]Rule, error) {
+	var rules []Rule
+	sc := bufio.NewScanner(r)
+	line := 0
+	for sc.Scan() {
+		line++
+		entry := strings.TrimSpace(sc.Text())
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+
+		rule := Rule{
+			Name:    BlocklistRulePrefix + entry,
+			Action:  ActionDeny,
+			Methods: []string{"tools/call"},
+		}
+		if len(entry) > 2 && strings.HasPrefix(entry, "/") && strings.HasSuffix(entry, "/") {
+			pattern := entry[1 : len(entry)-1]
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("blocklist line %d: %w", line, err)
+			}
+			rule.Patterns = []string{pattern}
+			rule.compiledPatterns = []*regexp.Regexp{re}
+		} else {
+			rule.Tools = []string{entry}
+		}
+		rules = append(rules, rule)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read blocklist: %w", err)
+	}
+	return rules, nil
+}
+
+// Merge returns a copy of base with extra rules appended. base is not
+// modified, so it can be merged again with a newer list.
+func Merge(base *Config, extra []Rule) *Config {
+	merged := *base
+	merged.Rules = append(slices.Clip(base.Rules), extra...)
+	return &merged
+}
+
+// Blocklist fetches deny entries from a URL or file and merges them into
+// a policy engine. The last list fetched successfully stays in effect
+// when a refresh fails.
+type Blocklist struct {
+	URL      string
+	File     string
+	Interval time.Duration // defaults to DefaultBlocklistRefresh
+	Client   *http.Client  // defaults to a client with a 30s timeout
+
+	logger *slog.Logger
+
+	mu           sync.Mutex
+	rules        []Rule
+	etag         string
+	lastModified string
+}
+
+// NewBlocklist creates a blocklist fetcher. Set exactly one of url and file.
+func NewBlocklist(url, file string, logger *slog.Logger) *Blocklist {
+	return &Blocklist{URL: url, File: file, logger: logger}
+}
+
+// Fetch loads the current list. For URLs, conditional requests are used
+// and a 304 answer returns the cached list.
+func (b *Blocklist) Fetch(ctx context.Context) ([]Rule, error) {
+	if b.File != "" {
+		f, err := os.Open(b.File)
+		if err != nil {
+			return nil, fmt.Errorf("read blocklist: %w", err)
+		}
+		defer f.Close()
+		return b.store(parseLimited(f))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("fetch blocklist: %w", err)
+	}
+	b.mu.Lock()
+	if b.etag != "" {
+		req.Header.Set("If-None-Match", b.etag)
+	}
+	if b.lastModified != "" {
+		req.Header.Set("If-Modified-Since", b.lastModified)
+	}
+	b.mu.Unlock()
+
+	client := b.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch blocklist: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		return b.rules, nil
+	case http.StatusOK:
+	default:
+		return nil, fmt.Errorf("fetch blocklist: unexpected status %s", resp.Status)
+	}
+
+	rules, err := b.store(parseLimited(resp.Body))
+	if err != nil {
+		return nil, err
+	}
+	b.mu.Lock()
+	b.etag = resp.Header.Get("ETag")
+	b.lastModified = resp.Header.Get("Last-Modified")
+	b.mu.Unlock()
+	return rules, nil
+}
+
+// parseLimited parses a blocklist of at most MaxBlocklistBytes.
+func parseLimited(r io.Reader) ([]Rule, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxBlocklistBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("read blocklist: %w", err)
+	}
+	if len(data) > MaxBlocklistBytes {
+		return nil, fmt.Errorf("read blocklist: larger than %d bytes", MaxBlocklistBytes)
+	}
+	return ParseBlocklist(bytes.NewReader(data))
+}
+
+// store caches a successfully parsed list.
+func (b *Blocklist) store(rules []Rule, err error) ([]Rule, error) {
+	if err != nil {
+		return nil, err
+	}
+	b.mu.Lock()
+	b.rules = rules
+	b.mu.Unlock()
+	return rules, nil
+}
+
+// Apply fetches the list once and swaps base plus the list into engine.
+// On failure the engine is left unchanged.
+func (b *Blocklist) Apply(ctx context.Context, engine *Engine, base *Config) error {
+	rules, err := b.Fetch(ctx)
+	if err != nil {
+		return err
+	}
+	engine.Swap(Merge(base, rules))
+	return nil
+}
+
+// Rules returns the list fetched last, or nil if none has been.
+func (b *Blocklist) Rules() []Rule {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.rules
+}
+
+// Run refreshes the list every Interval until ctx is cancelled, passing
+// each list fetched to apply. With a Reloader, apply is its SetBlocklist,
+// so refreshes and policy reloads don't undo each other.
+func (b *Blocklist) Run(ctx context.Context, apply func(rules []Rule)) {
+	interval := b.Interval
+	if interval <= 0 {
+		interval = DefaultBlocklistRefresh
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			rules, err := b.Fetch(ctx)
+			if err != nil {
+				b.logger.Warn("blocklist refresh failed, keeping last list", "error", err)
+				continue
+			}
+			apply(rules)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package policy
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestParseBlocklist(t *testing.T) {
+	rules, err := ParseBlocklist(strings.NewReader(`
+# dangerous tools
+run_shell
+
+/rm\s+-rf/
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 2 {
+		t.Fatalf("got %d rules, want 2", len(rules))
+	}
+	if rules[0].Name != "blocklist:run_shell" || rules[0].Action != ActionDeny || rules[0].Tools[0] != "run_shell" {
+		t.Errorf("tool rule = %+v", rules[0])
+	}
+	if len(rules[1].Tools) != 0 || len(rules[1].compiledPatterns) != 1 {
+		t.Errorf("pattern rule = %+v, want one compiled pattern and no tools", rules[1])
+	}
+
+	if _, err := ParseBlocklist(strings.NewReader("/([/\n")); err == nil {
+		t.Error("expected error for invalid regex")
+	}
+}
+
+func TestBlocklist_MergeAndBlock(t *testing.T) {
+	var body atomic.Value
+	body.Store("run_shell\n")
+	var fail atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		io.WriteString(w, body.Load().(string))
+	}))
+	defer srv.Close()
+
+	base := &Config{Rules: []Rule{{Name: "audit-all", Action: ActionAudit, Methods: []string{"tools/call"}}}}
+	engine := NewEngine(base)
+	bl := NewBlocklist(srv.URL, "", slog.New(slog.NewTextHandler(io.Discard, nil)))
+	ctx := context.Background()
+
+	if r := engine.Evaluate("host_to_server", "tools/call", "run_shell", "{}"); r.Action != ActionAudit {
+		t.Fatalf("before merge: action = %q, want audit", r.Action)
+	}
+
+	if err := bl.Apply(ctx, engine, base); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	r := engine.Evaluate("host_to_server", "tools/call", "run_shell", "{}")
+	if r.Action != ActionDeny || r.DenyRule != "blocklist:run_shell" {
+		t.Errorf("after merge: action = %q rule = %q, want deny by blocklist:run_shell", r.Action, r.DenyRule)
+	}
+	if len(base.Rules) != 1 {
+		t.Errorf("base config modified: %d rules", len(base.Rules))
+	}
+
+	// A failed refresh keeps the last list in effect
+	fail.Store(true)
+	if err := bl.Apply(ctx, engine, base); err == nil {
+		t.Error("expected error from failing feed")
+	}
+	if r := engine.Evaluate("host_to_server", "tools/call", "run_shell", "{}"); r.Action != ActionDeny {
+		t.Errorf("after failed refresh: action = %q, want deny", r.Action)
+	}
+
+	// A successful refresh replaces the list rather than accumulating
+	fail.Store(false)
+	body.Store("delete_repo\n")
+	if err := bl.Apply(ctx, engine, base); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if r := engine.Evaluate("host_to_server", "tools/call", "run_shell", "{}"); r.Action == ActionDeny {
+		t.Error("run_shell still denied after it left the list")
+	}
+	if r := engine.Evaluate("host_to_server", "tools/call", "delete_repo", "{}"); r.Action != ActionDeny {
+		t.Errorf("delete_repo: action = %q, want deny", r.Action)
+	}
+}
+
+func TestBlocklist_NotModified(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		io.WriteString(w, "run_shell\n")
+	}))
+	defer srv.Close()
+
+	bl := NewBlocklist(srv.URL, "", nil)
+	for i := 0; i < 2; i++ {
+		rules, err := bl.Fetch(context.Background())
+		if err != nil {
+			t.Fatalf("fetch %d: %v", i, err)
+		}
+		if len(rules) != 1 || rules[0].Tools[0] != "run_shell" {
+			t.Fatalf("fetch %d: rules = %+v, want cached run_shell", i, rules)
+		}
+	}
+	if requests.Load() != 2 {
+		t.Errorf("requests = %d, want 2", requests.Load())
+	}
+}
+
+func TestBlocklist_TooLarge(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "run_shell\n")
+		io.WriteString(w, strings.Repeat("# padding\n", MaxBlocklistBytes/10+1))
+	}))
+	defer srv.Close()
+
+	bl := NewBlocklist(srv.URL, "", nil)
+	bl.rules = []Rule{{Name: BlocklistRulePrefix + "delete_file"}}
+	if _, err := bl.Fetch(context.Background()); err == nil {
+		t.Fatal("expected an error for an oversized blocklist")
+	}
+	if rules := bl.Rules(); len(rules) != 1 || rules[0].Name != BlocklistRulePrefix+"delete_file" {
+		t.Errorf("rules = %+v, want the last good list kept", rules)
+	}
+}
+
+func TestBlocklist_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	os.WriteFile(path, []byte("run_shell\n"), 0644)
+
+	engine := NewEngine(&Config{})
+	if err := NewBlocklist("", path, nil).Apply(context.Background(), engine, &Config{}); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if r := engine.Evaluate("host_to_server", "tools/call", "run_shell", "{}"); r.Action != ActionDeny {
+		t.Errorf("action = %q, want deny", r.Action)
+	}
+}
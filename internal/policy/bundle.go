@@ -0,0 +1,62 @@
+package policy
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// bundleHeader starts every exported policy so a shared file says what it
+// is and how to use it.
+const bundleHeader = "# ContextGate policy bundle: rules, scrubber and pruning defaults.\n" +
+	"# Install with: contextgate policy import <file>\n"
+
+// Marshal serializes the config back to policy YAML. The result loads
+// with LoadBytes into an equivalent config.
+func (c *Config) Marshal() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(bundleHeader)
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(c); err != nil {
+		return nil, fmt.Errorf("encode policy YAML: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("encode policy YAML: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Install validates policy YAML and writes it to path, replacing any
+// policy already there. Nothing is written if the policy doesn't load.
+func Install(data []byte, path string) (*Config, error) {
+	cfg, err := LoadBytes(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("install policy: %w", err)
+	}
+	// Write next to the target and rename so a running proxy never reads
+	// a half-written file
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".policy-*.yaml")
+	if err != nil {
+		return nil, fmt.Errorf("install policy: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return nil, fmt.Errorf("install policy: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, fmt.Errorf("install policy: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return nil, fmt.Errorf("install policy: %w", err)
+	}
+	return cfg, nil
+}
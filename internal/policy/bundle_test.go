@@ -0,0 +1,123 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const bundlePolicy = `
+version: "1"
+rules:
+  - name: block-env
+    action: deny
+    methods: ["tools/call"]
+    tools: ["write_file"]
+    patterns: ['\.env']
+    message: "No env files"
+  - name: no-force
+    action: require_approval
+    tools: ["git_push"]
+    args:
+      - pointer: /arguments/force
+        equals: true
+  - name: drop-progress
+    action: deny
+    direction: server_to_host
+    methods: ["notifications/progress"]
+    deny_mode: drop
+scrubber:
+  enabled: true
+  preserve_length: true
+  fill_char: "#"
+  custom_patterns:
+    - name: internal_token
+      pattern: 'ctx_[A-Za-z0-9]{32,}'
+      label: internal_token
+log_redaction:
+  auth_login: ["password"]
+pipeline: [policy, scrub, logging]
+prune:
+  unused_sessions: 3
+  always_keep: ["mcp__fs__*"]
+`
+
+func TestMarshal_RoundTrip(t *testing.T) {
+	cfg, err := LoadBytes([]byte(bundlePolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	exported, err := cfg.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	again, err := LoadBytes(exported)
+	if err != nil {
+		t.Fatalf("exported bundle doesn't load: %v\n%s", err, exported)
+	}
+	if !reflect.DeepEqual(again.Scrubber, cfg.Scrubber) || !reflect.DeepEqual(again.Prune, cfg.Prune) ||
+		!reflect.DeepEqual(again.LogRedaction, cfg.LogRedaction) || !reflect.DeepEqual(again.Pipeline, cfg.Pipeline) {
+		t.Errorf("settings changed in round trip:\n%s", exported)
+	}
+	if len(again.Rules) != 3 {
+		t.Fatalf("got %d rules, want 3", len(again.Rules))
+	}
+	for i, r := range again.Rules {
+		orig := cfg.Rules[i]
+		if r.Name != orig.Name || r.Action != orig.Action || r.Message != orig.Message || r.DenyMode != orig.DenyMode ||
+			!reflect.DeepEqual(r.Patterns, orig.Patterns) || !reflect.DeepEqual(r.Tools, orig.Tools) || len(r.Args) != len(orig.Args) {
+			t.Errorf("rule %d: got %+v, want %+v", i, r, orig)
+		}
+	}
+
+	// The rules still behave the same
+	engine := NewEngine(again)
+	if res := engine.EvaluateInput(Input{Direction: "host_to_server", Method: "tools/call", ToolName: "git_push",
+		Params: []byte(`{"name":"git_push","arguments":{"force":true}}`)}); res.Action != ActionRequireApproval {
+		t.Errorf("args rule lost: %+v", res)
+	}
+
+	// Exporting again is stable
+	twice, _ := again.Marshal()
+	if string(twice) != string(exported) {
+		t.Errorf("second export differs:\n%s\nvs\n%s", exported, twice)
+	}
+	if !strings.HasPrefix(string(exported), "# ContextGate policy bundle") {
+		t.Error("bundle header missing")
+	}
+}
+
+func TestInstall(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "policy.yaml")
+	cfg, err := Install([]byte(bundlePolicy), path)
+	if err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	if len(cfg.Rules) != 3 {
+		t.Errorf("got %d rules", len(cfg.Rules))
+	}
+	loaded, err := Load(path)
+	if err != nil || loaded.Prune.UnusedSessions != 3 {
+		t.Fatalf("installed policy: %+v, %v", loaded, err)
+	}
+}
+
+func TestInstall_InvalidLeavesExisting(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "policy.yaml")
+	os.WriteFile(path, []byte(bundlePolicy), 0644)
+
+	bad := "rules:\n  - name: broken\n    action: deny\n    patterns: ['[unclosed']\n"
+	if _, err := Install([]byte(bad), path); err == nil {
+		t.Fatal("expected an invalid policy to be rejected")
+	}
+	if data, _ := os.ReadFile(path); string(data) != bundlePolicy {
+		t.Error("existing policy was overwritten")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("left files behind: %v", entries)
+	}
+}
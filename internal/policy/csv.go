@@ -0,0 +1,100 @@
+package policy
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// csvListSep separates multiple values inside a methods or tools cell.
+const csvListSep = "|"
+
+// LoadCSV reads rules from a CSV file, or a TSV file when the extension
+// is .tsv. See ParseCSV for the format.
+func LoadCSV(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("read policy csv: %w", err)
+	}
+	defer f.Close()
+
+	comma := ','
+	if strings.EqualFold(filepath.Ext(path), ".tsv") {
+		comma = '\t'
+	}
+	return ParseCSV(f, comma)
+}
+
+// ParseCSV converts spreadsheet rows into a compiled policy. Each row is
+//
+//	name, action, methods, tools, pattern[, message]
+//
+// where methods and tools hold "|"-separated values and pattern is a
+// single regex (it may itself contain "|"). Empty cells mean "any". A
+// first row starting with "name" is treated as a header and skipped.
+func ParseCSV(r io.Reader, comma rune) (*Config, error) {
+	cr := csv.NewReader(r)
+	cr.Comma = comma
+	cr.Comment = '#'
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("parse policy csv: %w", err)
+	}
+
+	cfg := &Config{Version: "1"}
+	for i, rec := range records {
+		line := i + 1
+		if i == 0 && len(rec) > 0 && strings.EqualFold(strings.TrimSpace(rec[0]), "name") {
+			continue
+		}
+		if len(rec) < 2 || len(rec) > 6 {
+			return nil, fmt.Errorf("policy csv line %d: expected 2-6 columns, got %d", line, len(rec))
+		}
+		for len(rec) < 6 {
+			rec = append(rec, "")
+		}
+
+		rule := Rule{
+			Name:    strings.TrimSpace(rec[0]),
+			Action:  Action(strings.TrimSpace(rec[1])),
+			Methods: splitList(rec[2]),
+			Tools:   splitList(rec[3]),
+			Message: strings.TrimSpace(rec[5]),
+		}
+		if p := strings.TrimSpace(rec[4]); p != "" {
+			rule.Patterns = []string{p}
+		}
+
+		if rule.Name == "" {
+			return nil, fmt.Errorf("policy csv line %d: rule name is required", line)
+		}
+		switch rule.Action {
+		case ActionDeny, ActionRequireApproval, ActionAudit, ActionQuarantine:
+		default:
+			return nil, fmt.Errorf("policy csv line %d: rule %q has unknown action %q", line, rule.Name, rule.Action)
+		}
+		cfg.Rules = append(cfg.Rules, rule)
+	}
+
+	if err := cfg.Compile(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// splitList splits a "|"-separated cell, dropping empty entries.
+func splitList(cell string) []string {
+	var out []string
+	for _, v := range strings.Split(cell, csvListSep) {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
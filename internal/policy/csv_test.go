@@ -0,0 +1,97 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const sampleCSV = `name,action,methods,tools,pattern,message
+protect-env-files,deny,tools/call,write_file|filesystem_write,\.env,
+block-shell,deny,tools/call,run_shell | execute_command,,Shell access is disabled.
+approve-deletions,require_approval,tools/call,delete_file,"rm (-rf|-fr)",
+audit-all-tools,audit,tools/call,,,
+`
+
+const sampleYAML = `
+version: "1"
+rules:
+  - name: protect-env-files
+    action: deny
+    methods: ["tools/call"]
+    tools: ["write_file", "filesystem_write"]
+    patterns: ['\.env']
+  - name: block-shell
+    action: deny
+    methods: ["tools/call"]
+    tools: ["run_shell", "execute_command"]
+    message: "Shell access is disabled."
+  - name: approve-deletions
+    action: require_approval
+    methods: ["tools/call"]
+    tools: ["delete_file"]
+    patterns: ['rm (-rf|-fr)']
+  - name: audit-all-tools
+    action: audit
+    methods: ["tools/call"]
+`
+
+// exportedRules strips compiled state so rules can be compared.
+func exportedRules(rules []Rule) []Rule {
+	out := make([]Rule, len(rules))
+	for i, r := range rules {
+		r.compiledPatterns = nil
+		out[i] = r
+	}
+	return out
+}
+
+func TestParseCSV_MatchesYAML(t *testing.T) {
+	fromCSV, err := ParseCSV(strings.NewReader(sampleCSV), ',')
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromYAML, err := LoadBytes([]byte(sampleYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := exportedRules(fromCSV.Rules), exportedRules(fromYAML.Rules); !reflect.DeepEqual(got, want) {
+		t.Fatalf("CSV rules differ from YAML:\n got %+v\nwant %+v", got, want)
+	}
+
+	// Compiled patterns behave the same
+	e := NewEngine(fromCSV)
+	if r := e.Evaluate("host_to_server", "tools/call", "delete_file", `{"cmd":"rm -fr /"}`); r.Action != ActionRequireApproval {
+		t.Fatalf("expected require_approval, got %q", r.Action)
+	}
+}
+
+func TestLoadCSV_TSV(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.tsv")
+	os.WriteFile(path, []byte("block-shell\tdeny\ttools/call\trun_shell\n"), 0644)
+
+	cfg, err := LoadCSV(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Rules) != 1 || cfg.Rules[0].Name != "block-shell" || cfg.Rules[0].Tools[0] != "run_shell" {
+		t.Fatalf("unexpected rules: %+v", cfg.Rules)
+	}
+}
+
+func TestParseCSV_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"unknown action": "r1,block,tools/call,,\n",
+		"missing name":   ",deny,tools/call,,\n",
+		"bad regex":      "r1,deny,tools/call,,[unclosed\n",
+		"too few cols":   "r1\n",
+	}
+	for name, in := range tests {
+		if _, err := ParseCSV(strings.NewReader(in), ','); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
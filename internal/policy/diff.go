@@ -0,0 +1,219 @@
+package policy
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// FieldChange is one setting whose value differs between two configs.
+// Old or New is empty when the setting is only set on one side.
+type FieldChange struct {
+	Field string
+	Old   string
+	New   string
+}
+
+// RuleChange lists how a rule present in both configs under the same name
+// changed.
+type RuleChange struct {
+	Name    string
+	Changes []FieldChange
+}
+
+// Diff is the semantic difference between two policy configs. Rules are
+// matched by name; when names repeat, only the first rule with each name
+// is compared.
+type Diff struct {
+	Added   []Rule
+	Removed []Rule
+	Changed []RuleChange
+
+	// Reordered is set when rules present in both configs appear in a
+	// different order, which can change which rule decides a message.
+	Reordered bool
+
+	Scrubber []FieldChange
+}
+
+// Empty reports whether the configs have the same rules and scrubber
+// settings.
+func (d *Diff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0 &&
+		!d.Reordered && len(d.Scrubber) == 0
+}
+
+// DiffConfigs compares the rules and scrubber settings of old and new.
+func DiffConfigs(old, new *Config) *Diff {
+	d := &Diff{}
+	oldRules := firstByName(old.Rules)
+	newRules := firstByName(new.Rules)
+
+	var oldOrder, newOrder []string
+	for _, r := range uniqueRules(old.Rules) {
+		n, ok := newRules[r.Name]
+		if !ok {
+			d.Removed = append(d.Removed, r)
+			continue
+		}
+		oldOrder = append(oldOrder, r.Name)
+		if changes := diffRule(r, n); len(changes) > 0 {
+			d.Changed = append(d.Changed, RuleChange{Name: r.Name, Changes: changes})
+		}
+	}
+	for _, r := range uniqueRules(new.Rules) {
+		if _, ok := oldRules[r.Name]; !ok {
+			d.Added = append(d.Added, r)
+			continue
+		}
+		newOrder = append(newOrder, r.Name)
+	}
+	d.Reordered = !slices.Equal(oldOrder, newOrder)
+
+	d.Scrubber = diffScrubber(old.Scrubber, new.Scrubber)
+	return d
+}
+
+// uniqueRules returns the first rule with each name, in policy order.
+func uniqueRules(rules []Rule) []Rule {
+	seen := make(map[string]bool, len(rules))
+	var out []Rule
+	for _, r := range rules {
+		if !seen[r.Name] {
+			seen[r.Name] = true
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+func firstByName(rules []Rule) map[string]Rule {
+	m := make(map[string]Rule, len(rules))
+	for _, r := range uniqueRules(rules) {
+		m[r.Name] = r
+	}
+	return m
+}
+
+// diffRule compares the settings of two rules, using their YAML keys as
+// field names.
+func diffRule(a, b Rule) []FieldChange {
+	var c changes
+	c.add("action", string(a.Action), string(b.Action))
+	c.add("methods", list(a.Methods), list(b.Methods))
+	c.add("tools", list(a.Tools), list(b.Tools))
+	c.add("direction", a.Direction, b.Direction)
+	c.add("client", a.Client, b.Client)
+	c.add("patterns", list(a.Patterns), list(b.Patterns))
+	c.add("args", argList(a.Args), argList(b.Args))
+	c.add("error_codes", intList(a.ErrorCodes), intList(b.ErrorCodes))
+	c.add("message", a.Message, b.Message)
+	c.add("deny_mode", string(a.DenyMode), string(b.DenyMode))
+	return c
+}
+
+// diffScrubber compares scrubber settings. Custom patterns and context
+// hints are compared by name, as custom_patterns.<name> and
+// context.<name>.
+func diffScrubber(a, b ScrubberConfig) []FieldChange {
+	var c changes
+	c.add("enabled", strconv.FormatBool(a.Enabled), strconv.FormatBool(b.Enabled))
+	c.add("redact_approvals", strconv.FormatBool(a.RedactApprovals), strconv.FormatBool(b.RedactApprovals))
+	c.add("preserve_length", strconv.FormatBool(a.PreserveLength), strconv.FormatBool(b.PreserveLength))
+	c.add("fill_char", a.FillChar, b.FillChar)
+	c.add("max_depth", strconv.Itoa(a.MaxDepth), strconv.Itoa(b.MaxDepth))
+
+	oldPatterns := make(map[string]string)
+	newPatterns := make(map[string]string)
+	for _, p := range a.CustomPatterns {
+		oldPatterns[p.Name] = customPattern(p)
+	}
+	for _, p := range b.CustomPatterns {
+		newPatterns[p.Name] = customPattern(p)
+	}
+	c.addMap("custom_patterns", oldPatterns, newPatterns)
+
+	oldContext := make(map[string]string)
+	newContext := make(map[string]string)
+	for name, pc := range a.Context {
+		oldContext[name] = patternContext(pc)
+	}
+	for name, pc := range b.Context {
+		newContext[name] = patternContext(pc)
+	}
+	c.addMap("context", oldContext, newContext)
+	return c
+}
+
+type changes []FieldChange
+
+func (c *changes) add(field, old, new string) {
+	if old != new {
+		*c = append(*c, FieldChange{Field: field, Old: old, New: new})
+	}
+}
+
+// addMap adds a change for each key whose value differs, in key order.
+func (c *changes) addMap(field string, old, new map[string]string) {
+	keys := make([]string, 0, len(old)+len(new))
+	for k := range old {
+		keys = append(keys, k)
+	}
+	for k := range new {
+		if _, ok := old[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		c.add(field+"."+k, old[k], new[k])
+	}
+}
+
+func list(items []string) string {
+	if len(items) == 0 {
+		return ""
+	}
+	return "[" + strings.Join(items, ", ") + "]"
+}
+
+func intList(codes []int) string {
+	items := make([]string, len(codes))
+	for i, code := range codes {
+		items[i] = strconv.Itoa(code)
+	}
+	return list(items)
+}
+
+func argList(args []ArgMatch) string {
+	items := make([]string, len(args))
+	for i, a := range args {
+		switch {
+		case a.Pattern != "":
+			items[i] = fmt.Sprintf("%s ~ %s", a.Pointer, a.Pattern)
+		default:
+			items[i] = fmt.Sprintf("%s = %v", a.Pointer, a.Equals)
+		}
+	}
+	return list(items)
+}
+
+func customPattern(p CustomPattern) string {
+	s := fmt.Sprintf("%s (label %s)", p.Pattern, p.Label)
+	if ctx := patternContext(p.PatternContext); ctx != "" {
+		s += " " + ctx
+	}
+	return s
+}
+
+func patternContext(pc PatternContext) string {
+	if len(pc.RequireContext) == 0 {
+		return ""
+	}
+	s := "near " + list(pc.RequireContext)
+	if pc.ContextWindow > 0 {
+		s += fmt.Sprintf(" within %d chars", pc.ContextWindow)
+	}
+	return s
+}
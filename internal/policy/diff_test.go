@@ -0,0 +1,88 @@
+package policy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffConfigs(t *testing.T) {
+	old, err := LoadBytes([]byte(`
+rules:
+  - name: block-shell
+    action: deny
+    tools: ["run_shell"]
+  - name: approve-delete
+    action: require_approval
+    tools: ["delete_file"]
+  - name: audit-reads
+    action: audit
+    tools: ["read_file"]
+scrubber:
+  enabled: true
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	new, err := LoadBytes([]byte(`
+rules:
+  - name: block-shell
+    action: deny
+    tools: ["run_shell"]
+  - name: approve-delete
+    action: deny
+    tools: ["delete_file", "rmdir"]
+  - name: block-ssh-keys
+    action: deny
+    patterns: ['id_rsa']
+scrubber:
+  enabled: true
+  custom_patterns:
+    - name: employee_id
+      pattern: 'EMP-\d{6}'
+      label: employee_id
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := DiffConfigs(old, new)
+	if len(d.Added) != 1 || d.Added[0].Name != "block-ssh-keys" {
+		t.Errorf("added = %v, want block-ssh-keys", d.Added)
+	}
+	if len(d.Removed) != 1 || d.Removed[0].Name != "audit-reads" {
+		t.Errorf("removed = %v, want audit-reads", d.Removed)
+	}
+	wantChanged := []RuleChange{{
+		Name: "approve-delete",
+		Changes: []FieldChange{
+			{Field: "action", Old: "require_approval", New: "deny"},
+			{Field: "tools", Old: "[delete_file]", New: "[delete_file, rmdir]"},
+		},
+	}}
+	if !reflect.DeepEqual(d.Changed, wantChanged) {
+		t.Errorf("changed = %+v, want %+v", d.Changed, wantChanged)
+	}
+	if d.Reordered {
+		t.Error("rules reported as reordered")
+	}
+	wantScrubber := []FieldChange{{Field: "custom_patterns.employee_id", New: `EMP-\d{6} (label employee_id)`}}
+	if !reflect.DeepEqual(d.Scrubber, wantScrubber) {
+		t.Errorf("scrubber = %+v, want %+v", d.Scrubber, wantScrubber)
+	}
+	if d.Empty() {
+		t.Error("diff reported as empty")
+	}
+}
+
+func TestDiffConfigs_OrderAndNoChange(t *testing.T) {
+	a := &Config{Rules: []Rule{{Name: "one", Action: ActionDeny}, {Name: "two", Action: ActionAudit}}}
+	if d := DiffConfigs(a, a); !d.Empty() {
+		t.Errorf("diff of a config with itself = %+v, want empty", d)
+	}
+
+	b := &Config{Rules: []Rule{{Name: "two", Action: ActionAudit}, {Name: "one", Action: ActionDeny}}}
+	d := DiffConfigs(a, b)
+	if !d.Reordered || len(d.Added)+len(d.Removed)+len(d.Changed) != 0 {
+		t.Errorf("diff = %+v, want only a reorder", d)
+	}
+}
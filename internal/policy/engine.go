@@ -1,45 +1,121 @@
 package policy
 
+import (
+	"encoding/json"
+	"regexp"
+	"slices"
+	"strings"
+	"sync/atomic"
+)
+
 // MatchResult holds the outcome of evaluating all rules against a message.
 type MatchResult struct {
-	Action       Action
-	MatchedRules []string
-	DenyRule     string
-	ApprovalRule string
+	Action         Action
+	MatchedRules   []string
+	DenyRule       string
+	ApprovalRule   string
+	QuarantineRule string
+	Message        string   // custom message of the deciding deny/quarantine/approval rule, if any
+	DenyMode       DenyMode // how the deciding deny rule answers the sender
+
+	patterns []*regexp.Regexp // of the matched rules, for RedactPatterns
+}
+
+// PatternRedaction replaces text matched by a rule pattern in RedactPatterns.
+const PatternRedaction = "[REDACTED:policy]"
+
+// RedactPatterns replaces every match of the matched rules' patterns in
+// payload and returns the result with the number of replacements.
+func (r MatchResult) RedactPatterns(payload string) (string, int) {
+	n := 0
+	for _, re := range r.patterns {
+		payload = re.ReplaceAllStringFunc(payload, func(string) string {
+			n++
+			return PatternRedaction
+		})
+	}
+	return payload, n
 }
 
-// Engine evaluates rules against messages.
+// Engine evaluates rules against messages. Its config can be replaced
+// at runtime with Swap.
 type Engine struct {
-	config *Config
+	config atomic.Pointer[Config]
 }
 
 // NewEngine creates a policy evaluation engine.
 func NewEngine(cfg *Config) *Engine {
-	return &Engine{config: cfg}
+	e := &Engine{}
+	e.config.Store(cfg)
+	return e
+}
+
+// Config returns the config currently in effect.
+func (e *Engine) Config() *Config {
+	return e.config.Load()
 }
 
+// Swap atomically replaces the config. Evaluations already in progress
+// finish against the previous one. cfg must be compiled.
+func (e *Engine) Swap(cfg *Config) {
+	e.config.Store(cfg)
+}
+
+// Input describes the message being evaluated.
+type Input struct {
+	Direction string
+	Method    string
+	ToolName  string
+	Payload   string
+	ErrorCode *int            // set for JSON-RPC error responses
+	Params    json.RawMessage // message params, for rules with args
+	Client    string          // host application of the session, if known
+}
+
+// Precedence lists the actions from strongest to weakest. When rules
+// with different actions match, the strongest decides; among rules with
+// the same action, the first in the policy does.
+var Precedence = []Action{ActionDeny, ActionQuarantine, ActionRequireApproval, ActionAudit}
+
 // Evaluate checks all rules against the given message attributes.
-// Priority: deny > require_approval > audit.
+// Priority: deny > quarantine > require_approval > audit.
 func (e *Engine) Evaluate(direction, method, toolName, payload string) MatchResult {
+	return e.EvaluateInput(Input{Direction: direction, Method: method, ToolName: toolName, Payload: payload})
+}
+
+// EvaluateInput is like Evaluate but can also match on the error code of
+// an error response.
+func (e *Engine) EvaluateInput(in Input) MatchResult {
 	var result MatchResult
+	params := &paramsDoc{raw: in.Params}
 
-	for _, rule := range e.config.Rules {
-		if !ruleMatches(&rule, direction, method, toolName, payload) {
+	for _, rule := range e.config.Load().Rules {
+		if !ruleMatches(&rule, in, params) {
 			continue
 		}
 
 		result.MatchedRules = append(result.MatchedRules, rule.Name)
+		result.patterns = append(result.patterns, rule.compiledPatterns...)
 
 		switch rule.Action {
 		case ActionDeny:
 			if result.Action != ActionDeny {
 				result.Action = ActionDeny
 				result.DenyRule = rule.Name
+				result.Message = rule.Message
+				result.DenyMode = rule.DenyMode
+			}
+		case ActionQuarantine:
+			if result.Action != ActionDeny && result.Action != ActionQuarantine {
+				result.Action = ActionQuarantine
+				result.QuarantineRule = rule.Name
+				result.Message = rule.Message
 			}
 		case ActionRequireApproval:
-			if result.Action != ActionDeny {
+			if result.Action != ActionDeny && result.Action != ActionQuarantine {
 				result.Action = ActionRequireApproval
 				result.ApprovalRule = rule.Name
+				result.Message = rule.Message
 			}
 		case ActionAudit:
 			if result.Action == "" {
@@ -51,28 +127,52 @@ func (e *Engine) Evaluate(direction, method, toolName, payload string) MatchResu
 	return result
 }
 
-func ruleMatches(rule *Rule, direction, method, toolName, payload string) bool {
-	if rule.Direction != "" && rule.Direction != direction {
+func ruleMatches(rule *Rule, in Input, params *paramsDoc) bool {
+	if rule.Direction != "" && rule.Direction != in.Direction {
+		return false
+	}
+
+	// A client-scoped rule never matches while the client is unknown
+	if rule.Client != "" && !strings.EqualFold(rule.Client, in.Client) {
 		return false
 	}
 
-	if len(rule.Methods) > 0 && !contains(rule.Methods, method) {
+	if len(rule.Methods) > 0 && !contains(rule.Methods, in.Method) {
 		return false
 	}
 
 	if len(rule.Tools) > 0 {
-		if toolName == "" || !contains(rule.Tools, toolName) {
+		if in.ToolName == "" || !contains(rule.Tools, in.ToolName) {
+			return false
+		}
+	}
+
+	if len(rule.ErrorCodes) > 0 {
+		if in.ErrorCode == nil || !slices.Contains(rule.ErrorCodes, *in.ErrorCode) {
 			return false
 		}
 	}
 
 	// All patterns must match (AND semantics)
 	for _, re := range rule.compiledPatterns {
-		if !re.MatchString(payload) {
+		if !re.MatchString(in.Payload) {
 			return false
 		}
 	}
 
+	// Likewise every argument match
+	if len(rule.Args) > 0 {
+		doc, ok := params.get()
+		if !ok {
+			return false
+		}
+		for i := range rule.Args {
+			if !rule.Args[i].matches(doc) {
+				return false
+			}
+		}
+	}
+
 	return true
 }
 
@@ -0,0 +1,32 @@
+package policy
+
+// FlagRulePrefix prefixes the names of rules generated from the
+// -deny-tools, -require-approval-tools and -audit-tools flags.
+const FlagRulePrefix = "flag:"
+
+// ToolRules converts lists of tool names into rules for tools/call, one
+// per non-empty list, so the common cases need no policy file. The rules
+// are named after their action, e.g. flag:require_approval, and are
+// meant to be merged into a loaded policy.
+func ToolRules(deny, requireApproval, audit []string) []Rule {
+	var rules []Rule
+	for _, r := range []struct {
+		action Action
+		tools  []string
+	}{
+		{ActionDeny, deny},
+		{ActionRequireApproval, requireApproval},
+		{ActionAudit, audit},
+	} {
+		if len(r.tools) == 0 {
+			continue
+		}
+		rules = append(rules, Rule{
+			Name:    FlagRulePrefix + string(r.action),
+			Action:  r.action,
+			Methods: []string{"tools/call"},
+			Tools:   r.tools,
+		})
+	}
+	return rules
+}
@@ -0,0 +1,57 @@
+package policy
+
+import "testing"
+
+func TestToolRules(t *testing.T) {
+	if rules := ToolRules(nil, nil, nil); rules != nil {
+		t.Fatalf("rules = %+v, want none", rules)
+	}
+
+	rules := ToolRules([]string{"delete_repo"}, []string{"write_file", "run_shell"}, []string{"read_file"})
+	engine := NewEngine(&Config{Version: "1", Rules: rules})
+	for _, tc := range []struct {
+		method, tool string
+		want         Action
+		rule         string
+	}{
+		{"tools/call", "delete_repo", ActionDeny, "flag:deny"},
+		{"tools/call", "run_shell", ActionRequireApproval, "flag:require_approval"},
+		{"tools/call", "read_file", ActionAudit, "flag:audit"},
+		{"tools/call", "list_dir", "", ""},
+		{"resources/read", "run_shell", "", ""},
+	} {
+		res := engine.Evaluate("host_to_server", tc.method, tc.tool, `{}`)
+		if res.Action != tc.want {
+			t.Errorf("%s %s: action = %q, want %q", tc.method, tc.tool, res.Action, tc.want)
+		}
+		if tc.rule != "" && (len(res.MatchedRules) != 1 || res.MatchedRules[0] != tc.rule) {
+			t.Errorf("%s %s: matched %v, want %s", tc.method, tc.tool, res.MatchedRules, tc.rule)
+		}
+	}
+}
+
+func TestToolRules_ComposeWithPolicy(t *testing.T) {
+	cfg, err := LoadBytes([]byte(`
+version: "1"
+rules:
+  - name: no-shell
+    action: deny
+    methods: [tools/call]
+    tools: [run_shell]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine := NewEngine(Merge(cfg, ToolRules(nil, []string{"run_shell", "write_file"}, nil)))
+
+	// The file's deny outranks the flag's approval
+	if res := engine.Evaluate("host_to_server", "tools/call", "run_shell", `{}`); res.Action != ActionDeny || res.DenyRule != "no-shell" {
+		t.Errorf("run_shell: %+v, want denied by no-shell", res)
+	}
+	if res := engine.Evaluate("host_to_server", "tools/call", "write_file", `{}`); res.Action != ActionRequireApproval || res.ApprovalRule != "flag:require_approval" {
+		t.Errorf("write_file: %+v, want held by flag:require_approval", res)
+	}
+	if len(cfg.Rules) != 1 {
+		t.Errorf("merge modified the loaded policy: %d rules", len(cfg.Rules))
+	}
+}
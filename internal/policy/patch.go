@@ -0,0 +1,282 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// RewriteRule rewrites server→host messages with an RFC 6902 JSON Patch.
+// A response is matched by the method (and for tools/call, the tool) of
+// the request it answers; a server notification or request by its own
+// method. Paths address the whole JSON-RPC message, e.g. /result/metadata.
+type RewriteRule struct {
+	Name    string    `yaml:"name"`
+	Methods []string  `yaml:"methods,omitempty"`
+	Tools   []string  `yaml:"tools,omitempty"`
+	Patch   []PatchOp `yaml:"patch"`
+}
+
+// Matches reports whether the rule applies to a message for method and,
+// when the rule names tools, tool.
+func (r *RewriteRule) Matches(method, tool string) bool {
+	if len(r.Methods) > 0 && !contains(r.Methods, method) {
+		return false
+	}
+	if len(r.Tools) > 0 && !contains(r.Tools, tool) {
+		return false
+	}
+	return true
+}
+
+// PatchOp is one RFC 6902 operation.
+type PatchOp struct {
+	Op    string `yaml:"op"`
+	Path  string `yaml:"path"`
+	From  string `yaml:"from,omitempty"`
+	Value any    `yaml:"value,omitempty"`
+
+	value any // Value as DecodeJSON would produce it
+}
+
+// DecodeJSON decodes data into v keeping numbers as json.Number, so ids
+// and other large integers survive a patch unchanged. Documents passed to
+// ApplyPatch should be decoded with it.
+func DecodeJSON(data []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if dec.More() {
+		return fmt.Errorf("unexpected data after JSON value")
+	}
+	return nil
+}
+
+func (o *PatchOp) compile() error {
+	switch o.Op {
+	case "add", "replace", "test":
+		data, err := json.Marshal(o.Value)
+		if err != nil {
+			return fmt.Errorf("%s %q value: %w", o.Op, o.Path, err)
+		}
+		if err := DecodeJSON(data, &o.value); err != nil {
+			return fmt.Errorf("%s %q value: %w", o.Op, o.Path, err)
+		}
+	case "remove":
+	case "move", "copy":
+		if _, err := splitPointer(o.From); err != nil {
+			return fmt.Errorf("%s from: %w", o.Op, err)
+		}
+	default:
+		return fmt.Errorf("unknown patch op %q", o.Op)
+	}
+	if _, err := splitPointer(o.Path); err != nil {
+		return fmt.Errorf("%s path: %w", o.Op, err)
+	}
+	return nil
+}
+
+// ApplyPatch applies ops in order to a document decoded with DecodeJSON
+// and returns the result. doc may be modified even when an error is
+// returned, so callers that need a fallback should keep the original.
+func ApplyPatch(doc any, ops []PatchOp) (any, error) {
+	for _, op := range ops {
+		path, err := splitPointer(op.Path)
+		if err != nil {
+			return nil, err
+		}
+		switch op.Op {
+		case "add":
+			doc, err = patchAdd(doc, path, op.value)
+		case "remove":
+			doc, _, err = patchRemove(doc, path)
+		case "replace":
+			if _, ok := resolvePointer(doc, op.Path); !ok {
+				return nil, fmt.Errorf("replace %q: path not found", op.Path)
+			}
+			if len(path) == 0 {
+				doc = op.value
+				break
+			}
+			if doc, _, err = patchRemove(doc, path); err == nil {
+				doc, err = patchAdd(doc, path, op.value)
+			}
+		case "move", "copy":
+			from, _ := splitPointer(op.From)
+			var v any
+			if op.Op == "move" {
+				if strings.HasPrefix(op.Path+"/", op.From+"/") && op.Path != op.From {
+					return nil, fmt.Errorf("move %q: cannot move into own child %q", op.From, op.Path)
+				}
+				doc, v, err = patchRemove(doc, from)
+			} else {
+				var ok bool
+				if v, ok = resolvePointer(doc, op.From); !ok {
+					err = fmt.Errorf("path not found")
+				}
+				v = deepCopy(v)
+			}
+			if err == nil {
+				doc, err = patchAdd(doc, path, v)
+			}
+		case "test":
+			v, ok := resolvePointer(doc, op.Path)
+			if !ok || !reflect.DeepEqual(v, op.value) {
+				return nil, fmt.Errorf("test %q failed", op.Path)
+			}
+		default:
+			return nil, fmt.Errorf("unknown patch op %q", op.Op)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s %q: %w", op.Op, op.Path, err)
+		}
+	}
+	return doc, nil
+}
+
+// splitPointer splits an RFC 6901 pointer into unescaped tokens.
+func splitPointer(ptr string) ([]string, error) {
+	if ptr == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(ptr, "/") {
+		return nil, fmt.Errorf("pointer %q must be empty or start with /", ptr)
+	}
+	toks := strings.Split(ptr[1:], "/")
+	for i, tok := range toks {
+		toks[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(tok)
+	}
+	return toks, nil
+}
+
+// arrayIndex parses an array index token for an array of length n. With
+// allowEnd, n itself (or "-") is accepted as the position after the end.
+func arrayIndex(tok string, n int, allowEnd bool) (int, error) {
+	if allowEnd && tok == "-" {
+		return n, nil
+	}
+	i, err := strconv.Atoi(tok)
+	if err != nil || i < 0 || (len(tok) > 1 && tok[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", tok)
+	}
+	if i > n || (i == n && !allowEnd) {
+		return 0, fmt.Errorf("array index %d out of range", i)
+	}
+	return i, nil
+}
+
+// patchAdd sets the value at path, inserting into arrays, and returns the
+// updated node.
+func patchAdd(node any, path []string, val any) (any, error) {
+	if len(path) == 0 {
+		return val, nil
+	}
+	tok, rest := path[0], path[1:]
+	switch n := node.(type) {
+	case map[string]any:
+		if len(rest) == 0 {
+			n[tok] = val
+			return n, nil
+		}
+		child, ok := n[tok]
+		if !ok {
+			return nil, fmt.Errorf("path not found")
+		}
+		child, err := patchAdd(child, rest, val)
+		if err != nil {
+			return nil, err
+		}
+		n[tok] = child
+		return n, nil
+	case []any:
+		if len(rest) == 0 {
+			i, err := arrayIndex(tok, len(n), true)
+			if err != nil {
+				return nil, err
+			}
+			n = append(n, nil)
+			copy(n[i+1:], n[i:])
+			n[i] = val
+			return n, nil
+		}
+		i, err := arrayIndex(tok, len(n), false)
+		if err != nil {
+			return nil, err
+		}
+		child, err := patchAdd(n[i], rest, val)
+		if err != nil {
+			return nil, err
+		}
+		n[i] = child
+		return n, nil
+	default:
+		return nil, fmt.Errorf("path not found")
+	}
+}
+
+// patchRemove deletes the value at path and returns the updated node and
+// the removed value.
+func patchRemove(node any, path []string) (any, any, error) {
+	if len(path) == 0 {
+		return nil, nil, fmt.Errorf("cannot remove the whole document")
+	}
+	tok, rest := path[0], path[1:]
+	switch n := node.(type) {
+	case map[string]any:
+		child, ok := n[tok]
+		if !ok {
+			return nil, nil, fmt.Errorf("path not found")
+		}
+		if len(rest) == 0 {
+			delete(n, tok)
+			return n, child, nil
+		}
+		child, removed, err := patchRemove(child, rest)
+		if err != nil {
+			return nil, nil, err
+		}
+		n[tok] = child
+		return n, removed, nil
+	case []any:
+		i, err := arrayIndex(tok, len(n), false)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(rest) == 0 {
+			removed := n[i]
+			return append(n[:i], n[i+1:]...), removed, nil
+		}
+		child, removed, err := patchRemove(n[i], rest)
+		if err != nil {
+			return nil, nil, err
+		}
+		n[i] = child
+		return n, removed, nil
+	default:
+		return nil, nil, fmt.Errorf("path not found")
+	}
+}
+
+func deepCopy(v any) any {
+	switch n := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(n))
+		for k, c := range n {
+			out[k] = deepCopy(c)
+		}
+		return out
+	case []any:
+		out := make([]any, len(n))
+		for i, c := range n {
+			out[i] = deepCopy(c)
+		}
+		return out
+	default:
+		return v
+	}
+}
@@ -0,0 +1,121 @@
+package policy
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func mustCompileRewrites(t *testing.T, src string) []RewriteRule {
+	t.Helper()
+	cfg, err := LoadBytes([]byte(src))
+	if err != nil {
+		t.Fatalf("LoadBytes: %v", err)
+	}
+	return cfg.Rewrites
+}
+
+func applyJSON(t *testing.T, doc string, ops []PatchOp) (string, error) {
+	t.Helper()
+	var v any
+	if err := DecodeJSON([]byte(doc), &v); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	out, err := ApplyPatch(v, ops)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(out)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	return string(data), nil
+}
+
+func TestApplyPatch(t *testing.T) {
+	rules := mustCompileRewrites(t, `
+version: "1"
+rules: []
+rewrites:
+  - name: all
+    patch:
+      - op: remove
+        path: /meta
+      - op: replace
+        path: /items/0
+        value: {"name": "first"}
+      - op: add
+        path: /items/-
+        value: 3
+      - op: copy
+        from: /items/1
+        path: /copied
+      - op: move
+        from: /a~1b
+        path: /moved
+      - op: test
+        path: /id
+        value: 12345678901234567890
+`)
+	got, err := applyJSON(t, `{"id":12345678901234567890,"meta":{"big":true},"items":[1,2],"a/b":"x"}`, rules[0].Patch)
+	if err != nil {
+		t.Fatalf("ApplyPatch: %v", err)
+	}
+	want := `{"copied":2,"id":12345678901234567890,"items":[{"name":"first"},2,3],"moved":"x"}`
+	if got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestApplyPatch_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		op   string
+	}{
+		{"remove missing", `{op: remove, path: /nope}`},
+		{"replace missing", `{op: replace, path: /nope, value: 1}`},
+		{"index out of range", `{op: add, path: /items/5, value: 1}`},
+		{"leading zero index", `{op: remove, path: /items/01}`},
+		{"add under missing parent", `{op: add, path: /a/b, value: 1}`},
+		{"failed test", `{op: test, path: /items/0, value: 2}`},
+		{"move into own child", `{op: move, from: /items, path: /items/0}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := mustCompileRewrites(t, "version: \"1\"\nrewrites:\n  - name: r\n    patch: ["+tt.op+"]\n")
+			if _, err := applyJSON(t, `{"items":[1,2]}`, rules[0].Patch); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestCompile_InvalidRewrite(t *testing.T) {
+	tests := map[string]string{
+		"unknown op":    `[{op: merge, path: /a}]`,
+		"relative path": `[{op: remove, path: a}]`,
+		"relative from": `[{op: move, from: a, path: /b}]`,
+		"empty patch":   `[]`,
+	}
+	for name, patch := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := LoadBytes([]byte("version: \"1\"\nrewrites:\n  - name: bad\n    patch: " + patch + "\n"))
+			if err == nil || !strings.Contains(err.Error(), `rewrite "bad"`) {
+				t.Errorf("err = %v, want a rewrite error", err)
+			}
+		})
+	}
+}
+
+func TestRewriteRule_Matches(t *testing.T) {
+	r := RewriteRule{Methods: []string{"tools/call"}, Tools: []string{"search"}}
+	if !r.Matches("tools/call", "search") {
+		t.Error("expected match")
+	}
+	if r.Matches("tools/call", "fetch") || r.Matches("resources/read", "search") {
+		t.Error("unexpected match")
+	}
+	if !(&RewriteRule{}).Matches("anything", "") {
+		t.Error("rule without filters should match everything")
+	}
+}
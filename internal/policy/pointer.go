@@ -0,0 +1,116 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ArgMatch tests a single value inside a message's params, addressed by a
+// JSON Pointer (RFC 6901) such as /arguments/options/force. Equals compares
+// the value exactly; Pattern is a regex matched against a string value, or
+// against the JSON encoding of any other value. With neither set, the
+// value only has to exist. A missing target never matches.
+type ArgMatch struct {
+	Pointer string `yaml:"pointer"`
+	Equals  any    `yaml:"equals,omitempty"`
+	Pattern string `yaml:"pattern,omitempty"`
+
+	equals   any // Equals as it would decode from JSON
+	compiled *regexp.Regexp
+}
+
+func (a *ArgMatch) compile() error {
+	if a.Pointer != "" && !strings.HasPrefix(a.Pointer, "/") {
+		return fmt.Errorf("pointer %q must be empty or start with /", a.Pointer)
+	}
+	if a.Equals != nil {
+		// Normalize so YAML's ints compare equal to JSON's float64s
+		data, err := json.Marshal(a.Equals)
+		if err != nil {
+			return fmt.Errorf("pointer %q equals: %w", a.Pointer, err)
+		}
+		if err := json.Unmarshal(data, &a.equals); err != nil {
+			return fmt.Errorf("pointer %q equals: %w", a.Pointer, err)
+		}
+	}
+	if a.Pattern != "" {
+		re, err := regexp.Compile(a.Pattern)
+		if err != nil {
+			return fmt.Errorf("pointer %q pattern %q: %w", a.Pointer, a.Pattern, err)
+		}
+		a.compiled = re
+	}
+	return nil
+}
+
+// matches reports whether the pointer's target in doc satisfies the match.
+func (a *ArgMatch) matches(doc any) bool {
+	v, ok := resolvePointer(doc, a.Pointer)
+	if !ok {
+		return false
+	}
+	if a.equals != nil && !reflect.DeepEqual(v, a.equals) {
+		return false
+	}
+	if a.compiled != nil {
+		s, isString := v.(string)
+		if !isString {
+			data, _ := json.Marshal(v)
+			s = string(data)
+		}
+		if !a.compiled.MatchString(s) {
+			return false
+		}
+	}
+	return true
+}
+
+// resolvePointer looks up an RFC 6901 JSON Pointer in a decoded JSON
+// document. The empty pointer refers to the whole document.
+func resolvePointer(doc any, ptr string) (any, bool) {
+	if ptr == "" {
+		return doc, true
+	}
+	cur := doc
+	for _, tok := range strings.Split(ptr[1:], "/") {
+		tok = strings.NewReplacer("~1", "/", "~0", "~").Replace(tok)
+		switch node := cur.(type) {
+		case map[string]any:
+			v, ok := node[tok]
+			if !ok {
+				return nil, false
+			}
+			cur = v
+		case []any:
+			i, err := strconv.Atoi(tok)
+			if err != nil || i < 0 || i >= len(node) || (len(tok) > 1 && tok[0] == '0') {
+				return nil, false
+			}
+			cur = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// paramsDoc decodes a message's params on first use, so messages are
+// only parsed when a rule actually has argument matches.
+type paramsDoc struct {
+	raw     json.RawMessage
+	val     any
+	ok      bool
+	decoded bool
+}
+
+func (d *paramsDoc) get() (any, bool) {
+	if !d.decoded {
+		d.decoded = true
+		d.ok = len(d.raw) > 0 && json.Unmarshal(d.raw, &d.val) == nil
+	}
+	return d.val, d.ok
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"regexp"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -16,16 +17,34 @@ const (
 	ActionDeny            Action = "deny"
 	ActionRequireApproval Action = "require_approval"
 	ActionAudit           Action = "audit"
+
+	// ActionQuarantine forwards a server→host response with its result
+	// replaced by a stub, so the agent gets a valid reply without the
+	// content.
+	ActionQuarantine Action = "quarantine"
+)
+
+// DenyMode controls how a denied message is answered.
+type DenyMode string
+
+const (
+	DenyModeError DenyMode = "error" // reply to the sender with a JSON-RPC error (default)
+	DenyModeDrop  DenyMode = "drop"  // discard the message without replying
 )
 
 // Rule represents a single policy rule.
 type Rule struct {
-	Name      string   `yaml:"name"`
-	Action    Action   `yaml:"action"`
-	Methods   []string `yaml:"methods"`
-	Tools     []string `yaml:"tools"`
-	Direction string   `yaml:"direction,omitempty"`
-	Patterns  []string `yaml:"patterns"`
+	Name       string     `yaml:"name"`
+	Action     Action     `yaml:"action"`
+	Methods    []string   `yaml:"methods,omitempty"`
+	Tools      []string   `yaml:"tools,omitempty"`
+	Direction  string     `yaml:"direction,omitempty"`
+	Client     string     `yaml:"client,omitempty"` // host application, e.g. claude-code or cursor (case-insensitive)
+	Patterns   []string   `yaml:"patterns,omitempty"`
+	Args       []ArgMatch `yaml:"args,omitempty"`        // values in params, addressed by JSON Pointer
+	ErrorCodes []int      `yaml:"error_codes,omitempty"` // match error responses with these JSON-RPC codes
+	Message    string     `yaml:"message,omitempty"`     // shown to the agent instead of the default block error
+	DenyMode   DenyMode   `yaml:"deny_mode,omitempty"`   // error (default) or drop
 
 	compiledPatterns []*regexp.Regexp
 }
@@ -35,19 +54,156 @@ type Config struct {
 	Version  string         `yaml:"version"`
 	Rules    []Rule         `yaml:"rules"`
 	Scrubber ScrubberConfig `yaml:"scrubber"`
+
+	// LogRedaction maps tool names to argument keys that are blanked in
+	// the message log. Forwarded traffic is not changed.
+	LogRedaction map[string][]string `yaml:"log_redaction,omitempty"`
+
+	// RedactDenied replaces the text matched by rule patterns in the
+	// logged copy of a denied message, so the log doesn't keep the secret
+	// the rule caught.
+	RedactDenied bool `yaml:"redact_denied,omitempty"`
+
+	// StorePayload lists methods whose payloads are only sampled in the
+	// message log; the rest of each entry is always kept.
+	StorePayload []PayloadStorage `yaml:"store_payload,omitempty"`
+
+	// Pipeline lists the interceptors to run, in order. Empty means the
+	// default order.
+	Pipeline []string `yaml:"pipeline,omitempty"`
+
+	// Prune holds tool pruning defaults, used where the matching -prune
+	// flags are not given.
+	Prune PruneDefaults `yaml:"prune,omitempty"`
+
+	// Rewrites patch server→host messages before they reach the host.
+	Rewrites []RewriteRule `yaml:"rewrites,omitempty"`
+
+	// ExfilLimit caps how much tool output a session may read.
+	ExfilLimit *ExfilLimit `yaml:"exfil_limit,omitempty"`
+
+	// EchoGuard catches secrets from a server being sent back to it.
+	EchoGuard *EchoGuard `yaml:"echo_guard,omitempty"`
+
+	// Freeze schedules change freezes that block mutating tool calls.
+	Freeze *Freeze `yaml:"freeze,omitempty"`
+}
+
+// ExfilLimit limits the cumulative size of tools/call results returned
+// to a session, so many small reads can't add up to a bulk export. Once a
+// session has received MaxBytes within Window, further calls to the
+// limited tools are denied or held for approval.
+type ExfilLimit struct {
+	MaxBytes int64         `yaml:"max_bytes"`
+	Window   time.Duration `yaml:"window,omitempty"`  // e.g. 10m; zero counts the whole session
+	Tools    []string      `yaml:"tools,omitempty"`   // tools counted and limited; empty means all
+	Action   Action        `yaml:"action,omitempty"`  // deny (default) or require_approval
+	Message  string        `yaml:"message,omitempty"` // shown to the agent instead of the default error
+}
+
+// EchoGuard remembers hashes of the secrets each session receives from
+// the server and acts on host→server messages that carry one back, a
+// round trip that catches a server coaxing the agent into returning what
+// it read. Secrets are the values matched by the scrubber patterns named
+// in Patterns, whether or not scrubbing is enabled.
+type EchoGuard struct {
+	Patterns []string      `yaml:"patterns,omitempty"` // scrubber pattern names; default those labelled api_key
+	TTL      time.Duration `yaml:"ttl,omitempty"`      // how long a secret is remembered; zero means the whole session
+	Action   Action        `yaml:"action,omitempty"`   // deny (default), require_approval or audit
+	Message  string        `yaml:"message,omitempty"`  // shown to the agent instead of the default error
+}
+
+// Freeze configures change freezes, during which every tools/call is
+// denied, whatever the rules say, unless the tool is known not to mutate
+// anything. A freeze is on within one of Windows or while switched on at
+// runtime.
+type Freeze struct {
+	Windows []FreezeWindow `yaml:"windows,omitempty"`
+	Allow   []string       `yaml:"allow,omitempty"`   // tools (names or path.Match globs) still callable
+	Message string         `yaml:"message,omitempty"` // shown to the agent instead of the default error
+
+	// TrustReadOnlyHints also lets through tools the server annotates
+	// with readOnlyHint. Servers are not obliged to tell the truth.
+	TrustReadOnlyHints bool `yaml:"trust_read_only_hints,omitempty"`
+}
+
+// FreezeWindow is a scheduled freeze from Start until End, written as
+// RFC 3339 timestamps.
+type FreezeWindow struct {
+	Start time.Time `yaml:"start"`
+	End   time.Time `yaml:"end"`
+}
+
+// Contains reports whether t falls within the window.
+func (w FreezeWindow) Contains(t time.Time) bool {
+	return !t.Before(w.Start) && t.Before(w.End)
+}
+
+// PayloadStorage keeps only metadata in the message log for messages with
+// one of Methods, along with the responses to such requests. Direction,
+// if set, is that of the request or notification. Sample is the fraction
+// of payloads still stored (0 stores none). Payloads of blocked, audited,
+// scrubbed or rule-matched messages are always stored.
+type PayloadStorage struct {
+	Methods   []string `yaml:"methods"`
+	Direction string   `yaml:"direction,omitempty"`
+	Sample    float64  `yaml:"sample,omitempty"`
+}
+
+// PruneDefaults mirrors the -prune-unused, -prune-keep-top and -prune-keep
+// flags so a shared policy can carry them.
+type PruneDefaults struct {
+	UnusedSessions int      `yaml:"unused_sessions,omitempty"`
+	KeepTop        int      `yaml:"keep_top,omitempty"`
+	AlwaysKeep     []string `yaml:"always_keep,omitempty"`
 }
 
 // ScrubberConfig controls PII scrubbing behavior.
 type ScrubberConfig struct {
-	Enabled        bool            `yaml:"enabled"`
-	CustomPatterns []CustomPattern `yaml:"custom_patterns"`
+	Enabled         bool            `yaml:"enabled"`
+	RedactApprovals bool            `yaml:"redact_approvals"`
+	CustomPatterns  []CustomPattern `yaml:"custom_patterns,omitempty"`
+
+	// PreserveLength masks matches character-for-character with FillChar
+	// (default "*") instead of inserting a [REDACTED:label] marker.
+	PreserveLength bool   `yaml:"preserve_length"`
+	FillChar       string `yaml:"fill_char"`
+
+	// Context adds hint keywords to built-in patterns, keyed by pattern
+	// name (e.g. ssn, ipv4), so they only fire near those words.
+	Context map[string]PatternContext `yaml:"context,omitempty"`
+
+	// MaxDepth bounds how deeply nested JSON is walked value by value
+	// (0 = the scrubber's default). Deeper subtrees are scrubbed as text.
+	MaxDepth int `yaml:"max_depth,omitempty"`
+}
+
+// PatternContext restricts a scrubbing pattern to matches that have one
+// of the RequireContext keywords (case-insensitive, whole words) within
+// ContextWindow characters on either side. An empty list redacts every
+// match.
+type PatternContext struct {
+	RequireContext []string `yaml:"require_context,omitempty"`
+	ContextWindow  int      `yaml:"context_window,omitempty"`
 }
 
 // CustomPattern allows users to define additional scrubbing patterns.
 type CustomPattern struct {
-	Name    string `yaml:"name"`
-	Pattern string `yaml:"pattern"`
-	Label   string `yaml:"label"`
+	Name           string `yaml:"name"`
+	Pattern        string `yaml:"pattern"`
+	Label          string `yaml:"label"`
+	PatternContext `yaml:",inline"`
+
+	compiled *regexp.Regexp
+}
+
+// Regexp returns the compiled pattern, reusing the one built by
+// Config.Compile when available.
+func (p CustomPattern) Regexp() (*regexp.Regexp, error) {
+	if p.compiled != nil {
+		return p.compiled, nil
+	}
+	return regexp.Compile(p.Pattern)
 }
 
 // Load reads and parses a policy YAML file.
@@ -56,7 +212,11 @@ func Load(path string) (*Config, error) {
 	if err != nil {
 		return nil, fmt.Errorf("read policy file: %w", err)
 	}
+	return LoadBytes(data)
+}
 
+// LoadBytes parses and compiles policy YAML held in memory.
+func LoadBytes(data []byte) (*Config, error) {
 	var cfg Config
 	if err := yaml.Unmarshal(data, &cfg); err != nil {
 		return nil, fmt.Errorf("parse policy YAML: %w", err)
@@ -68,10 +228,19 @@ func Load(path string) (*Config, error) {
 	return &cfg, nil
 }
 
-// Compile pre-compiles all regex patterns in all rules.
+// Compile pre-compiles all regex patterns in rules and custom scrubber
+// patterns.
 func (c *Config) Compile() error {
 	for i := range c.Rules {
 		r := &c.Rules[i]
+		if r.Action == ActionQuarantine && r.Direction == "host_to_server" {
+			return fmt.Errorf("rule %q: quarantine only applies to server_to_host responses", r.Name)
+		}
+		switch r.DenyMode {
+		case "", DenyModeError, DenyModeDrop:
+		default:
+			return fmt.Errorf("rule %q: unknown deny_mode %q (want %s or %s)", r.Name, r.DenyMode, DenyModeError, DenyModeDrop)
+		}
 		for _, p := range r.Patterns {
 			re, err := regexp.Compile(p)
 			if err != nil {
@@ -79,6 +248,87 @@ func (c *Config) Compile() error {
 			}
 			r.compiledPatterns = append(r.compiledPatterns, re)
 		}
+		for j := range r.Args {
+			if err := r.Args[j].compile(); err != nil {
+				return fmt.Errorf("rule %q args: %w", r.Name, err)
+			}
+		}
+	}
+	for i := range c.Scrubber.CustomPatterns {
+		cp := &c.Scrubber.CustomPatterns[i]
+		re, err := regexp.Compile(cp.Pattern)
+		if err != nil {
+			return fmt.Errorf("scrubber pattern %q: %w", cp.Name, err)
+		}
+		cp.compiled = re
+		if cp.ContextWindow < 0 {
+			return fmt.Errorf("scrubber pattern %q: context_window must not be negative", cp.Name)
+		}
+	}
+	if c.Scrubber.MaxDepth < 0 {
+		return fmt.Errorf("scrubber: max_depth must not be negative")
+	}
+	for name, pc := range c.Scrubber.Context {
+		if pc.ContextWindow < 0 {
+			return fmt.Errorf("scrubber context %q: context_window must not be negative", name)
+		}
+	}
+	if l := c.ExfilLimit; l != nil {
+		if l.MaxBytes <= 0 {
+			return fmt.Errorf("exfil_limit: max_bytes must be positive")
+		}
+		if l.Window < 0 {
+			return fmt.Errorf("exfil_limit: window must not be negative")
+		}
+		switch l.Action {
+		case "", ActionDeny, ActionRequireApproval:
+		default:
+			return fmt.Errorf("exfil_limit: unknown action %q (want %s or %s)", l.Action, ActionDeny, ActionRequireApproval)
+		}
+	}
+	if f := c.Freeze; f != nil {
+		for i, w := range f.Windows {
+			if w.Start.IsZero() || w.End.IsZero() {
+				return fmt.Errorf("freeze: windows[%d]: start and end are required", i)
+			}
+			if !w.End.After(w.Start) {
+				return fmt.Errorf("freeze: windows[%d]: end must be after start", i)
+			}
+		}
+	}
+	if g := c.EchoGuard; g != nil {
+		if g.TTL < 0 {
+			return fmt.Errorf("echo_guard: ttl must not be negative")
+		}
+		switch g.Action {
+		case "", ActionDeny, ActionRequireApproval, ActionAudit:
+		default:
+			return fmt.Errorf("echo_guard: unknown action %q (want %s, %s or %s)", g.Action, ActionDeny, ActionRequireApproval, ActionAudit)
+		}
+	}
+	for i, ps := range c.StorePayload {
+		if len(ps.Methods) == 0 {
+			return fmt.Errorf("store_payload[%d]: methods is empty", i)
+		}
+		switch ps.Direction {
+		case "", "host_to_server", "server_to_host":
+		default:
+			return fmt.Errorf("store_payload[%d]: unknown direction %q", i, ps.Direction)
+		}
+		if ps.Sample < 0 || ps.Sample > 1 {
+			return fmt.Errorf("store_payload[%d]: sample must be between 0 and 1", i)
+		}
+	}
+	for i := range c.Rewrites {
+		rw := &c.Rewrites[i]
+		if len(rw.Patch) == 0 {
+			return fmt.Errorf("rewrite %q: patch is empty", rw.Name)
+		}
+		for j := range rw.Patch {
+			if err := rw.Patch[j].compile(); err != nil {
+				return fmt.Errorf("rewrite %q: %w", rw.Name, err)
+			}
+		}
 	}
 	return nil
 }
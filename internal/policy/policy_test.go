@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestLoad_ValidYAML(t *testing.T) {
@@ -62,6 +64,37 @@ rules:
 	}
 }
 
+func TestLoadBytes_InvalidScrubberPattern(t *testing.T) {
+	_, err := LoadBytes([]byte(`
+scrubber:
+  enabled: true
+  custom_patterns:
+    - name: internal_token
+      pattern: 'ctx_[A-Za-z0-9{32,}'
+      label: internal_token
+`))
+	if err == nil || !strings.Contains(err.Error(), "internal_token") {
+		t.Fatalf("err = %v, want an error naming the bad pattern", err)
+	}
+}
+
+func TestCustomPattern_ReusesCompiled(t *testing.T) {
+	cfg, err := LoadBytes([]byte(`
+scrubber:
+  custom_patterns:
+    - name: tok
+      pattern: 'tok_[a-z]+'
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, _ := cfg.Scrubber.CustomPatterns[0].Regexp()
+	b, _ := cfg.Scrubber.CustomPatterns[0].Regexp()
+	if a == nil || a != b {
+		t.Error("expected the compiled regex to be reused")
+	}
+}
+
 func TestEngine_DenyMatchesMethod(t *testing.T) {
 	cfg := &Config{
 		Rules: []Rule{
@@ -192,6 +225,36 @@ func TestEngine_DirectionFilter(t *testing.T) {
 	}
 }
 
+func TestEngine_ClientFilter(t *testing.T) {
+	cfg, err := LoadBytes([]byte(`
+rules:
+  - name: cursor-no-shell
+    action: deny
+    client: cursor
+    tools: ["run_shell"]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := NewEngine(cfg)
+	call := Input{Direction: "host_to_server", Method: "tools/call", ToolName: "run_shell"}
+
+	for _, tt := range []struct {
+		client string
+		want   Action
+	}{
+		{"cursor", ActionDeny},
+		{"Cursor", ActionDeny},
+		{"claude-code", ""},
+		{"", ""}, // unknown client
+	} {
+		call.Client = tt.client
+		if got := e.EvaluateInput(call).Action; got != tt.want {
+			t.Errorf("client %q: action = %q, want %q", tt.client, got, tt.want)
+		}
+	}
+}
+
 func TestEngine_NoMatch(t *testing.T) {
 	cfg := &Config{
 		Rules: []Rule{
@@ -260,3 +323,380 @@ scrubber:
 		t.Fatalf("expected label 'internal_token', got %q", cfg.Scrubber.CustomPatterns[0].Label)
 	}
 }
+
+func TestLoad_LogRedaction(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "policy.yaml")
+	os.WriteFile(path, []byte(`
+version: "1"
+rules: []
+log_redaction:
+  auth_login: ["password", "otp"]
+`), 0644)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := cfg.LogRedaction["auth_login"]
+	if len(keys) != 2 || keys[0] != "password" || keys[1] != "otp" {
+		t.Fatalf("expected [password otp] for auth_login, got %v", keys)
+	}
+}
+
+func TestLoadBytes(t *testing.T) {
+	cfg, err := LoadBytes([]byte(`
+version: "1"
+rules:
+  - name: block-shell
+    action: deny
+    methods: ["tools/call"]
+    tools: ["run_shell"]
+    patterns:
+      - 'rm -rf'
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Rules) != 1 || cfg.Rules[0].Name != "block-shell" {
+		t.Fatalf("unexpected rules: %+v", cfg.Rules)
+	}
+	if len(cfg.Rules[0].compiledPatterns) != 1 {
+		t.Fatal("expected patterns to be compiled")
+	}
+}
+
+func TestLoadBytes_Invalid(t *testing.T) {
+	if _, err := LoadBytes([]byte("rules: [")); err == nil {
+		t.Fatal("expected error for invalid YAML")
+	}
+	if _, err := LoadBytes([]byte(`
+rules:
+  - name: bad
+    action: deny
+    patterns: ["[unclosed"]
+`)); err == nil {
+		t.Fatal("expected error for invalid regex")
+	}
+}
+
+func TestLoadBytes_ScrubberPreserveLength(t *testing.T) {
+	cfg, err := LoadBytes([]byte(`
+scrubber:
+  enabled: true
+  preserve_length: true
+  fill_char: "#"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.Scrubber.PreserveLength || cfg.Scrubber.FillChar != "#" {
+		t.Fatalf("unexpected scrubber config: %+v", cfg.Scrubber)
+	}
+}
+
+func TestEngine_MessageFromDecidingRule(t *testing.T) {
+	cfg := &Config{
+		Rules: []Rule{
+			{Name: "approve-delete", Action: ActionRequireApproval, Tools: []string{"delete_file"}, Message: "needs review"},
+			{Name: "block-delete", Action: ActionDeny, Tools: []string{"delete_file"}, Message: "never delete"},
+			{Name: "block-shell", Action: ActionDeny, Tools: []string{"run_shell"}},
+		},
+	}
+	cfg.Compile()
+	e := NewEngine(cfg)
+
+	if r := e.Evaluate("host_to_server", "tools/call", "delete_file", `{}`); r.Message != "never delete" {
+		t.Fatalf("expected deny rule's message, got %q", r.Message)
+	}
+	if r := e.Evaluate("host_to_server", "tools/call", "run_shell", `{}`); r.Message != "" {
+		t.Fatalf("expected no message for rule without one, got %q", r.Message)
+	}
+}
+
+func TestEngine_ErrorCodes(t *testing.T) {
+	cfg, err := LoadBytes([]byte(`
+rules:
+  - name: audit-rate-limits
+    action: audit
+    direction: server_to_host
+    error_codes: [-32029]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := NewEngine(cfg)
+
+	code := -32029
+	if r := e.EvaluateInput(Input{Direction: "server_to_host", ErrorCode: &code}); r.Action != ActionAudit {
+		t.Fatalf("expected audit for error code %d, got %q", code, r.Action)
+	}
+
+	other := -32600
+	if r := e.EvaluateInput(Input{Direction: "server_to_host", ErrorCode: &other}); len(r.MatchedRules) != 0 {
+		t.Fatalf("expected no match for error code %d, got %v", other, r.MatchedRules)
+	}
+
+	// Non-error messages never match an error_codes rule
+	if r := e.Evaluate("server_to_host", "", "", `{"result":{}}`); len(r.MatchedRules) != 0 {
+		t.Fatalf("expected no match without an error code, got %v", r.MatchedRules)
+	}
+}
+
+func TestEngine_ArgsPointer(t *testing.T) {
+	cfg, err := LoadBytes([]byte(`
+rules:
+  - name: deny-force
+    action: deny
+    methods: ["tools/call"]
+    args:
+      - pointer: /arguments/options/force
+        equals: true
+  - name: audit-prod-branch
+    action: audit
+    args:
+      - pointer: /arguments/branch
+        pattern: '^prod'
+  - name: audit-depth
+    action: audit
+    args:
+      - pointer: /arguments/depth
+        equals: 3
+  - name: audit-escaped
+    action: audit
+    args:
+      - pointer: /arguments/a~1b/0
+        equals: x
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := NewEngine(cfg)
+
+	eval := func(params string) MatchResult {
+		return e.EvaluateInput(Input{Direction: "host_to_server", Method: "tools/call", Params: json.RawMessage(params)})
+	}
+
+	tests := []struct {
+		name   string
+		params string
+		want   []string
+	}{
+		{"boolean true", `{"arguments":{"options":{"force":true}}}`, []string{"deny-force"}},
+		{"boolean false", `{"arguments":{"options":{"force":false}}}`, nil},
+		{"string is not a boolean", `{"arguments":{"options":{"force":"true"}}}`, nil},
+		{"missing target", `{"arguments":{"options":{}}}`, nil},
+		{"missing parent", `{"arguments":{}}`, nil},
+		{"string pattern", `{"arguments":{"branch":"prod-eu"}}`, []string{"audit-prod-branch"}},
+		{"string pattern miss", `{"arguments":{"branch":"dev"}}`, nil},
+		{"number from yaml int", `{"arguments":{"depth":3}}`, []string{"audit-depth"}},
+		{"escaped key and index", `{"arguments":{"a/b":["x"]}}`, []string{"audit-escaped"}},
+		{"no params", ``, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := eval(tt.params).MatchedRules
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("matched %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadBytes_InvalidArgs(t *testing.T) {
+	for _, doc := range []string{
+		"rules:\n  - name: r\n    action: audit\n    args:\n      - pointer: arguments/force\n",
+		"rules:\n  - name: r\n    action: audit\n    args:\n      - pointer: /arguments/x\n        pattern: '[bad'\n",
+	} {
+		if _, err := LoadBytes([]byte(doc)); err == nil || !strings.Contains(err.Error(), `rule "r"`) {
+			t.Errorf("err = %v, want an error naming the rule", err)
+		}
+	}
+}
+
+func TestResolvePointer(t *testing.T) {
+	var doc any
+	json.Unmarshal([]byte(`{"a":{"b":[10,{"c~d":true}]},"":1}`), &doc)
+
+	tests := []struct {
+		ptr  string
+		want any
+		ok   bool
+	}{
+		{"/a/b/0", 10.0, true},
+		{"/a/b/1/c~0d", true, true},
+		{"/", 1.0, true},
+		{"/a/b/2", nil, false},
+		{"/a/b/01", nil, false},
+		{"/a/x", nil, false},
+		{"/a/b/0/deeper", nil, false},
+	}
+	for _, tt := range tests {
+		got, ok := resolvePointer(doc, tt.ptr)
+		if ok != tt.ok || (ok && got != tt.want) {
+			t.Errorf("resolvePointer(%q) = %v, %v; want %v, %v", tt.ptr, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestEngine_DenyMode(t *testing.T) {
+	cfg, err := LoadBytes([]byte(`
+rules:
+  - name: drop-progress
+    action: deny
+    methods: ["notifications/progress"]
+    deny_mode: drop
+  - name: block-shell
+    action: deny
+    tools: ["run_shell"]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := NewEngine(cfg)
+
+	if r := e.Evaluate("host_to_server", "notifications/progress", "", `{}`); r.DenyMode != DenyModeDrop {
+		t.Errorf("deny mode = %q, want drop", r.DenyMode)
+	}
+	if r := e.Evaluate("host_to_server", "tools/call", "run_shell", `{}`); r.Action != ActionDeny || r.DenyMode != "" {
+		t.Errorf("result = %+v, want deny with the default mode", r)
+	}
+
+	if _, err := LoadBytes([]byte("rules:\n  - name: r\n    action: deny\n    deny_mode: silent\n")); err == nil || !strings.Contains(err.Error(), "deny_mode") {
+		t.Errorf("err = %v, want an unknown deny_mode error", err)
+	}
+}
+
+func TestLoadBytes_ExfilLimit(t *testing.T) {
+	cfg, err := LoadBytes([]byte(`
+exfil_limit:
+  max_bytes: 1048576
+  window: 10m
+  tools: ["read_file"]
+  action: require_approval
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := cfg.ExfilLimit
+	if l == nil || l.MaxBytes != 1<<20 || l.Window != 10*time.Minute || l.Action != ActionRequireApproval {
+		t.Fatalf("exfil_limit = %+v", l)
+	}
+
+	for _, doc := range []string{
+		"exfil_limit:\n  max_bytes: 0\n",
+		"exfil_limit:\n  max_bytes: 10\n  window: -1m\n",
+		"exfil_limit:\n  max_bytes: 10\n  action: audit\n",
+	} {
+		if _, err := LoadBytes([]byte(doc)); err == nil || !strings.Contains(err.Error(), "exfil_limit") {
+			t.Errorf("LoadBytes(%q) err = %v, want an exfil_limit error", doc, err)
+		}
+	}
+}
+
+func TestLoadBytes_EchoGuard(t *testing.T) {
+	cfg, err := LoadBytes([]byte(`
+echo_guard:
+  patterns: ["openai_key", "internal_token"]
+  ttl: 1h
+  action: audit
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := cfg.EchoGuard
+	if g == nil || len(g.Patterns) != 2 || g.TTL != time.Hour || g.Action != ActionAudit {
+		t.Fatalf("echo_guard = %+v", g)
+	}
+
+	for _, doc := range []string{
+		"echo_guard:\n  ttl: -1m\n",
+		"echo_guard:\n  action: quarantine\n",
+	} {
+		if _, err := LoadBytes([]byte(doc)); err == nil || !strings.Contains(err.Error(), "echo_guard") {
+			t.Errorf("LoadBytes(%q) err = %v, want an echo_guard error", doc, err)
+		}
+	}
+}
+
+func TestLoadBytes_Freeze(t *testing.T) {
+	cfg, err := LoadBytes([]byte(`
+freeze:
+  message: "Release freeze"
+  allow: ["read_*"]
+  windows:
+    - start: 2026-12-20T00:00:00Z
+      end: 2027-01-04T00:00:00Z
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := cfg.Freeze
+	if f == nil || len(f.Windows) != 1 || f.Message != "Release freeze" {
+		t.Fatalf("freeze = %+v", f)
+	}
+	w := f.Windows[0]
+	if !w.Contains(time.Date(2026, 12, 24, 12, 0, 0, 0, time.UTC)) || w.Contains(w.End) || w.Contains(w.Start.Add(-time.Second)) {
+		t.Errorf("window %+v contains the wrong times", w)
+	}
+
+	for _, doc := range []string{
+		"freeze:\n  windows:\n    - start: 2026-12-20T00:00:00Z\n",
+		"freeze:\n  windows:\n    - start: 2026-12-20T00:00:00Z\n      end: 2026-12-19T00:00:00Z\n",
+	} {
+		if _, err := LoadBytes([]byte(doc)); err == nil || !strings.Contains(err.Error(), "freeze") {
+			t.Errorf("LoadBytes(%q) err = %v, want a freeze error", doc, err)
+		}
+	}
+}
+
+func TestEngine_QuarantinePriority(t *testing.T) {
+	cfg, err := LoadBytes([]byte(`
+rules:
+  - name: audit-results
+    action: audit
+    direction: server_to_host
+  - name: review-results
+    action: require_approval
+    direction: server_to_host
+  - name: withhold-keys
+    action: quarantine
+    patterns: ['PRIVATE KEY']
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := NewEngine(cfg)
+
+	r := e.Evaluate("server_to_host", "", "", `{"result":"PRIVATE KEY"}`)
+	if r.Action != ActionQuarantine || r.QuarantineRule != "withhold-keys" {
+		t.Errorf("result = %+v, want quarantine over require_approval", r)
+	}
+	if r := e.Evaluate("server_to_host", "", "", `{"result":"ok"}`); r.Action != ActionRequireApproval {
+		t.Errorf("result = %+v, want require_approval", r)
+	}
+
+	if _, err := LoadBytes([]byte("rules:\n  - name: q\n    action: quarantine\n    direction: host_to_server\n")); err == nil {
+		t.Error("quarantine on host_to_server should be rejected")
+	}
+}
+
+func TestLoadStorePayload(t *testing.T) {
+	cfg, err := LoadBytes([]byte("version: \"1\"\nstore_payload:\n  - methods: [resources/read]\n    direction: server_to_host\n    sample: 0.25\n"))
+	if err != nil {
+		t.Fatalf("LoadBytes: %v", err)
+	}
+	if len(cfg.StorePayload) != 1 || cfg.StorePayload[0].Sample != 0.25 || cfg.StorePayload[0].Methods[0] != "resources/read" {
+		t.Errorf("store_payload = %+v", cfg.StorePayload)
+	}
+
+	for _, bad := range []string{
+		"  - methods: []\n",
+		"  - methods: [a]\n    sample: 1.5\n",
+		"  - methods: [a]\n    direction: sideways\n",
+	} {
+		if _, err := LoadBytes([]byte("version: \"1\"\nstore_payload:\n" + bad)); err == nil || !strings.Contains(err.Error(), "store_payload[0]") {
+			t.Errorf("%q: err = %v, want a store_payload error", bad, err)
+		}
+	}
+}
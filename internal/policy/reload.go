@@ -0,0 +1,90 @@
+package policy
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+// Reloader re-reads a policy from where it was loaded and swaps it into
+// a running engine. Only the rules, and whatever OnReload applies, take
+// effect: the other sections are read once at startup. Rules merged in
+// from Blocklist stay in effect across reloads.
+type Reloader struct {
+	Load      func() (*Config, error)
+	Blocklist *Blocklist // optional
+
+	// OnReload, if set, applies other parts of a newly loaded policy
+	// before its rules are swapped in. An error abandons the reload.
+	OnReload func(cfg *Config) error
+
+	engine *Engine
+	logger *slog.Logger
+
+	mu   sync.Mutex
+	base *Config
+}
+
+// NewReloader creates a reloader for engine, whose policy was loaded as
+// base by load.
+func NewReloader(engine *Engine, base *Config, load func() (*Config, error), logger *slog.Logger) *Reloader {
+	return &Reloader{Load: load, engine: engine, logger: logger, base: base}
+}
+
+// Base returns the policy as last loaded, without blocklist rules.
+func (r *Reloader) Base() *Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.base
+}
+
+// Reload loads the policy again and swaps it into the engine. On error
+// the engine keeps the policy it had.
+func (r *Reloader) Reload() (*Config, error) {
+	cfg, err := r.Load()
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.OnReload != nil {
+		if err := r.OnReload(cfg); err != nil {
+			return nil, err
+		}
+	}
+	r.base = cfg
+	if r.Blocklist != nil {
+		r.engine.Swap(Merge(cfg, r.Blocklist.Rules()))
+	} else {
+		r.engine.Swap(cfg)
+	}
+	return cfg, nil
+}
+
+// SetBlocklist swaps the policy as last loaded, merged with rules, into
+// the engine. It holds the same lock as Reload, so a refresh can't swap
+// an older policy back in over a reload.
+func (r *Reloader) SetBlocklist(rules []Rule) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.engine.Swap(Merge(r.base, rules))
+}
+
+// Run reloads the policy each time a signal arrives, until ctx is
+// cancelled. Failed reloads are logged and leave the policy unchanged.
+func (r *Reloader) Run(ctx context.Context, signals <-chan os.Signal) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case sig := <-signals:
+			cfg, err := r.Reload()
+			if err != nil {
+				r.logger.Error("policy reload failed, keeping current policy", "signal", sig, "error", err)
+				continue
+			}
+			r.logger.Info("policy reloaded", "signal", sig, "rules", len(cfg.Rules))
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestReloader_Signal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	// Replace the file atomically: the reload started by the second of
+	// each pair of signals may still be reading it
+	write := func(yaml string) {
+		t.Helper()
+		tmp := path + ".tmp"
+		if err := os.WriteFile(tmp, []byte(yaml), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(`
+rules:
+  - name: block-shell
+    action: deny
+    tools: ["run_shell"]
+`)
+	load := func() (*Config, error) { return Load(path) }
+	cfg, err := load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine := NewEngine(cfg)
+	r := NewReloader(engine, cfg, load, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	signals := make(chan os.Signal)
+	go r.Run(ctx, signals)
+
+	action := func(tool string) Action {
+		return engine.Evaluate("host_to_server", "tools/call", tool, "{}").Action
+	}
+	if action("delete_file") != "" {
+		t.Fatal("delete_file matched before the reload")
+	}
+
+	write(`
+rules:
+  - name: block-delete
+    action: deny
+    tools: ["delete_file"]
+`)
+	signals <- syscall.SIGHUP
+	signals <- syscall.SIGHUP // returns once the first reload is done
+	if action("delete_file") != ActionDeny || action("run_shell") != "" {
+		t.Errorf("after reload: delete_file = %q, run_shell = %q", action("delete_file"), action("run_shell"))
+	}
+
+	// A broken file leaves the last good policy in effect
+	write(`
+rules:
+  - name: broken
+    action: deny
+    patterns: ["(["]
+`)
+	signals <- syscall.SIGHUP
+	signals <- syscall.SIGHUP
+	if action("delete_file") != ActionDeny {
+		t.Error("failed reload replaced the policy")
+	}
+	if got := r.Base().Rules[0].Name; got != "block-delete" {
+		t.Errorf("base rule = %q, want block-delete", got)
+	}
+}
+
+func TestReloader_KeepsBlocklist(t *testing.T) {
+	dir := t.TempDir()
+	listPath := filepath.Join(dir, "blocklist.txt")
+	os.WriteFile(listPath, []byte("run_shell\n"), 0o600)
+
+	base := &Config{}
+	engine := NewEngine(base)
+	bl := NewBlocklist("", listPath, nil)
+	if err := bl.Apply(context.Background(), engine, base); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded := &Config{Rules: []Rule{{Name: "audit-calls", Action: ActionAudit, Methods: []string{"tools/call"}}}}
+	reloaded.Compile()
+	r := NewReloader(engine, base, func() (*Config, error) { return reloaded, nil }, nil)
+	r.Blocklist = bl
+	if _, err := r.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := engine.Evaluate("host_to_server", "tools/call", "run_shell", "{}"); got.Action != ActionDeny {
+		t.Errorf("run_shell = %q, want the blocklist to still deny it", got.Action)
+	}
+	if got := engine.Evaluate("host_to_server", "tools/call", "read_file", "{}"); got.Action != ActionAudit {
+		t.Errorf("read_file = %q, want the reloaded audit rule", got.Action)
+	}
+
+	// Blocklist refreshes merge into the reloaded policy, not the original
+	os.WriteFile(listPath, []byte("delete_file\n"), 0o600)
+	bl.Interval = 10 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go bl.Run(ctx, r.SetBlocklist)
+	deadline := time.Now().Add(2 * time.Second)
+	for engine.Evaluate("host_to_server", "tools/call", "delete_file", "{}").Action != ActionDeny {
+		if time.Now().After(deadline) {
+			t.Fatal("blocklist refresh not applied")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := engine.Evaluate("host_to_server", "tools/call", "read_file", "{}"); got.Action != ActionAudit {
+		t.Errorf("after refresh read_file = %q, want audit", got.Action)
+	}
+}
+
+func TestReloader_OnReload(t *testing.T) {
+	base := &Config{}
+	engine := NewEngine(base)
+	next := &Config{Rules: []Rule{{Name: "audit-calls", Action: ActionAudit, Methods: []string{"tools/call"}}}}
+	next.Compile()
+	r := NewReloader(engine, base, func() (*Config, error) { return next, nil }, nil)
+
+	var applied *Config
+	r.OnReload = func(cfg *Config) error {
+		applied = cfg
+		return errors.New("unknown scrubber pattern")
+	}
+	if _, err := r.Reload(); err == nil {
+		t.Fatal("Reload succeeded despite OnReload failing")
+	}
+	if applied != next {
+		t.Error("OnReload not given the new policy")
+	}
+	if engine.Config() != base || r.Base() != base {
+		t.Error("rules swapped in after OnReload failed")
+	}
+
+	r.OnReload = func(*Config) error { return nil }
+	if _, err := r.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if engine.Config() != next {
+		t.Error("rules not swapped in")
+	}
+}
+
+func TestReloader_BlocklistRace(t *testing.T) {
+	engine := NewEngine(&Config{})
+	var version atomic.Int64
+	load := func() (*Config, error) {
+		cfg := &Config{Rules: []Rule{{Name: fmt.Sprintf("v%d", version.Add(1)), Action: ActionAudit, Methods: []string{"tools/call"}}}}
+		cfg.Compile()
+		return cfg, nil
+	}
+	r := NewReloader(engine, &Config{}, load, nil)
+	blocked := []Rule{{Name: "blocklist:run_shell", Action: ActionDeny, Methods: []string{"tools/call"}, Tools: []string{"run_shell"}}}
+	r.Blocklist = &Blocklist{rules: blocked}
+
+	// Refreshes racing reloads, as from -blocklist-refresh and SIGHUP
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for range 200 {
+			if _, err := r.Reload(); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for range 200 {
+			r.SetBlocklist(blocked)
+		}
+	}()
+	wg.Wait()
+
+	// Whichever finished last, the engine holds the newest policy
+	rules := engine.Config().Rules
+	if len(rules) != 2 || rules[0].Name != "v200" || rules[1].Name != "blocklist:run_shell" {
+		names := make([]string, len(rules))
+		for i, rule := range rules {
+			names[i] = rule.Name
+		}
+		t.Errorf("rules = %v, want [v200 blocklist:run_shell]", names)
+	}
+}
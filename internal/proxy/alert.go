@@ -0,0 +1,202 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/contextgate/contextgate/internal/policy"
+)
+
+// BlockAlert describes a blocked message, as delivered to an AlertSink.
+type BlockAlert struct {
+	Time      time.Time `json:"time"`
+	SessionID string    `json:"session_id"`
+	Direction string    `json:"direction"`
+	Method    string    `json:"method,omitempty"`
+	MsgID     string    `json:"msg_id,omitempty"`
+	Tool      string    `json:"tool,omitempty"`
+	Rule      string    `json:"rule,omitempty"`
+	Reason    string    `json:"reason"`
+}
+
+// NewBlockAlert builds the alert for a message blocked with err.
+func NewBlockAlert(msg *InterceptedMessage, err error) *BlockAlert {
+	a := &BlockAlert{
+		Time:      msg.Timestamp,
+		SessionID: msg.SessionID,
+		Direction: string(msg.Direction),
+		Method:    msg.Parsed.Method,
+		MsgID:     string(msg.Parsed.ID),
+		Reason:    err.Error(),
+	}
+	if a.Method == "tools/call" {
+		a.Tool = policy.ExtractToolName(msg.Parsed.Params)
+	}
+	if rule, ok := msg.Metadata[MetaKeyPolicyRule].(string); ok {
+		a.Rule = rule
+	}
+	return a
+}
+
+// AlertSink delivers block alerts somewhere outside the proxy.
+type AlertSink interface {
+	Send(ctx context.Context, alert *BlockAlert) error
+}
+
+// WriterAlertSink writes each alert as a line of JSON.
+type WriterAlertSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewWriterAlertSink(w io.Writer) *WriterAlertSink {
+	return &WriterAlertSink{w: w}
+}
+
+func (s *WriterAlertSink) Send(_ context.Context, alert *BlockAlert) error {
+	data, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(append(data, '\n'))
+	return err
+}
+
+// Webhook POSTs JSON documents to a URL. Any 2xx status is success.
+type Webhook struct {
+	URL    string
+	Client *http.Client // defaults to a client with a 10s timeout
+}
+
+// Post sends v as the JSON request body.
+func (h *Webhook) Post(ctx context.Context, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := h.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook %s: %s", h.URL, resp.Status)
+	}
+	return nil
+}
+
+// WebhookAlertSink posts each alert to a webhook.
+type WebhookAlertSink struct {
+	Webhook
+}
+
+func (s *WebhookAlertSink) Send(ctx context.Context, alert *BlockAlert) error {
+	return s.Post(ctx, alert)
+}
+
+// OpenAlertSink returns the sink for a -block-alert target: "stderr", an
+// http(s) URL, or a file path that alerts are appended to. The closer
+// releases the file, if one was opened.
+func OpenAlertSink(target string) (AlertSink, io.Closer, error) {
+	switch {
+	case target == "stderr":
+		return NewWriterAlertSink(os.Stderr), io.NopCloser(nil), nil
+	case strings.HasPrefix(target, "http://"), strings.HasPrefix(target, "https://"):
+		return &WebhookAlertSink{Webhook{URL: target}}, io.NopCloser(nil), nil
+	default:
+		f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return nil, nil, fmt.Errorf("open alert file: %w", err)
+		}
+		return NewWriterAlertSink(f), f, nil
+	}
+}
+
+// alertQueueSize bounds the alerts waiting for a slow sink.
+const alertQueueSize = 64
+
+// alertFlushTimeout bounds delivering the alerts still queued when Run's
+// context is done.
+const alertFlushTimeout = 5 * time.Second
+
+// BlockAlerter sends an alert to its sink for every blocked message. Its
+// OnBlock method is meant for InterceptorChain.OnBlock. Delivery happens
+// on Run's goroutine so a slow webhook never holds up the proxy; alerts
+// that don't fit in the queue are dropped with a warning.
+type BlockAlerter struct {
+	sink   AlertSink
+	logger *slog.Logger
+	queue  chan *BlockAlert
+}
+
+func NewBlockAlerter(sink AlertSink, logger *slog.Logger) *BlockAlerter {
+	return &BlockAlerter{
+		sink:   sink,
+		logger: logger,
+		queue:  make(chan *BlockAlert, alertQueueSize),
+	}
+}
+
+// OnBlock queues an alert for a blocked message.
+func (a *BlockAlerter) OnBlock(_ context.Context, msg *InterceptedMessage, err error) {
+	alert := NewBlockAlert(msg, err)
+	select {
+	case a.queue <- alert:
+	default:
+		a.logger.Warn("block alert queue full, dropping alert", "session", alert.SessionID, "method", alert.Method)
+	}
+}
+
+// Run delivers queued alerts until ctx is done, then delivers what is
+// left. Cancelling ctx doesn't abort an alert already being sent.
+func (a *BlockAlerter) Run(ctx context.Context) {
+	sendCtx := context.WithoutCancel(ctx)
+	for {
+		select {
+		case alert := <-a.queue:
+			a.send(sendCtx, alert)
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(sendCtx, alertFlushTimeout)
+			defer cancel()
+			for flushCtx.Err() == nil {
+				select {
+				case alert := <-a.queue:
+					a.send(flushCtx, alert)
+				default:
+					return
+				}
+			}
+			if n := len(a.queue); n > 0 {
+				a.logger.Warn("block alerts not delivered at shutdown", "alerts", n)
+			}
+			return
+		}
+	}
+}
+
+func (a *BlockAlerter) send(ctx context.Context, alert *BlockAlert) {
+	if err := a.sink.Send(ctx, alert); err != nil {
+		a.logger.Warn("block alert failed", "error", err)
+	}
+}
@@ -0,0 +1,157 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/contextgate/contextgate/internal/policy"
+)
+
+type recordingSink struct {
+	alerts chan *BlockAlert
+}
+
+func (s *recordingSink) Send(_ context.Context, alert *BlockAlert) error {
+	s.alerts <- alert
+	return nil
+}
+
+func TestBlockAlerter_PolicyDeny(t *testing.T) {
+	cfg, err := policy.LoadBytes([]byte(`
+rules:
+  - name: block-shell
+    action: deny
+    tools: ["run_shell"]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink := &recordingSink{alerts: make(chan *BlockAlert, 1)}
+	alerter := NewBlockAlerter(sink, testLogger())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go alerter.Run(ctx)
+
+	chain := NewInterceptorChain(NewPolicyInterceptor(policy.NewEngine(cfg)))
+	chain.OnBlock = alerter.OnBlock
+
+	msg := parsedMessage(t, DirHostToServer, `{"jsonrpc":"2.0","id":7,"method":"tools/call","params":{"name":"run_shell"}}`)
+	msg.SessionID = "s1"
+	if _, err := chain.Process(ctx, msg); err == nil {
+		t.Fatal("expected the call to be blocked")
+	}
+
+	select {
+	case a := <-sink.alerts:
+		if a.SessionID != "s1" || a.Tool != "run_shell" || a.Rule != "block-shell" || a.MsgID != "7" ||
+			!strings.Contains(a.Reason, "block-shell") {
+			t.Errorf("alert = %+v", a)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no alert sent")
+	}
+
+	// Forwarded messages don't alert
+	chain.Process(ctx, parsedMessage(t, DirHostToServer, `{"jsonrpc":"2.0","id":8,"method":"tools/call","params":{"name":"read_file"}}`))
+	select {
+	case a := <-sink.alerts:
+		t.Errorf("unexpected alert %+v", a)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// liveContextSink records alerts sent with a context that is still live.
+type liveContextSink struct {
+	sent []*BlockAlert
+}
+
+func (s *liveContextSink) Send(ctx context.Context, alert *BlockAlert) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.sent = append(s.sent, alert)
+	return nil
+}
+
+func TestBlockAlerter_DeliversQueuedOnCancel(t *testing.T) {
+	sink := &liveContextSink{}
+	alerter := NewBlockAlerter(sink, testLogger())
+	for _, id := range []string{"1", "2", "3"} {
+		alerter.OnBlock(context.Background(), parsedMessage(t, DirHostToServer, `{"jsonrpc":"2.0","id":`+id+`,"method":"tools/call"}`), errors.New("denied"))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	alerter.Run(ctx)
+	if len(sink.sent) != 3 {
+		t.Errorf("delivered %d alerts at shutdown, want 3", len(sink.sent))
+	}
+}
+
+func TestWebhookAlertSink(t *testing.T) {
+	got := make(chan BlockAlert, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("content type = %q", ct)
+		}
+		var a BlockAlert
+		json.NewDecoder(r.Body).Decode(&a)
+		got <- a
+	}))
+	defer srv.Close()
+
+	sink, closer, err := OpenAlertSink(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closer.Close()
+	if err := sink.Send(context.Background(), &BlockAlert{SessionID: "s1", Reason: "budget"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if a := <-got; a.SessionID != "s1" || a.Reason != "budget" {
+		t.Errorf("posted alert = %+v", a)
+	}
+
+	failing := &WebhookAlertSink{Webhook{URL: srv.URL + "/missing"}}
+	if err := failing.Send(context.Background(), &BlockAlert{}); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("err = %v, want a 404 error", err)
+	}
+}
+
+func TestFileAlertSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "alerts.jsonl")
+	sink, closer, err := OpenAlertSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := parsedMessage(t, DirHostToServer, `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"read_file"}}`)
+	for range 2 {
+		if err := sink.Send(context.Background(), NewBlockAlert(msg, errors.New("tool call budget exhausted"))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	closer.Close()
+
+	data, _ := os.ReadFile(path)
+	lines := bytes.Split(bytes.TrimSpace(data), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("file has %d lines, want 2:\n%s", len(lines), data)
+	}
+	var a BlockAlert
+	if err := json.Unmarshal(lines[0], &a); err != nil || a.Tool != "read_file" || a.Reason != "tool call budget exhausted" {
+		t.Errorf("alert = %+v, err = %v", a, err)
+	}
+}
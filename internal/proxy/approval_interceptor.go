@@ -13,10 +13,11 @@ import (
 type ApprovalDecision int
 
 const (
-	DecisionPending  ApprovalDecision = iota
+	DecisionPending ApprovalDecision = iota
 	DecisionApproved
 	DecisionDenied
 	DecisionTimeout
+	DecisionCancelled
 )
 
 func (d ApprovalDecision) String() string {
@@ -27,23 +28,36 @@ func (d ApprovalDecision) String() string {
 		return "denied"
 	case DecisionTimeout:
 		return "timeout"
+	case DecisionCancelled:
+		return "cancelled"
 	default:
 		return "pending"
 	}
 }
 
+// Reason codes recording how an approval request was resolved, kept
+// alongside the decision in the audit trail.
+const (
+	ReasonManual        = "manual"         // a reviewer approved or denied it
+	ReasonTimeout       = "timeout"        // no decision before the approval timeout
+	ReasonWithdrawn     = "withdrawn"      // a reviewer withdrew it
+	ReasonHostCancelled = "host_cancelled" // the host cancelled the request
+	ReasonShutdown      = "shutdown"       // denied because contextgate was shutting down
+)
+
 // ApprovalRequest represents a pending approval request.
 type ApprovalRequest struct {
-	ID        string    `json:"id"`
-	Timestamp time.Time `json:"timestamp"`
-	SessionID string    `json:"session_id"`
-	Direction string    `json:"direction"`
-	Method    string    `json:"method"`
-	ToolName  string    `json:"tool_name"`
-	RuleName  string    `json:"rule_name"`
-	Payload   string    `json:"payload"`
-	Decision  string    `json:"decision"`
+	ID        string     `json:"id"`
+	Timestamp time.Time  `json:"timestamp"`
+	SessionID string     `json:"session_id"`
+	Direction string     `json:"direction"`
+	Method    string     `json:"method"`
+	ToolName  string     `json:"tool_name"`
+	RuleName  string     `json:"rule_name"`
+	Payload   string     `json:"payload"`
+	Decision  string     `json:"decision"`
 	DecidedAt *time.Time `json:"decided_at,omitempty"`
+	Reason    string     `json:"reason,omitempty"` // how it was resolved, one of the Reason codes
 
 	done chan ApprovalDecision
 }
@@ -55,9 +69,17 @@ type ApprovalManager struct {
 	pending map[string]*ApprovalRequest
 	timeout time.Duration
 	nextID  int
+	now     func() time.Time
+
+	draining bool          // set by Drain; new requests are denied at once
+	idle     chan struct{} // closed once nothing is pending while draining
 
 	// OnRequest is called when a new approval is submitted.
 	OnRequest func(req *ApprovalRequest)
+
+	// OnResolve is called once a request is approved, denied, cancelled
+	// or times out, after its Decision and DecidedAt are set.
+	OnResolve func(req *ApprovalRequest)
 }
 
 func NewApprovalManager(timeout time.Duration) *ApprovalManager {
@@ -67,6 +89,7 @@ func NewApprovalManager(timeout time.Duration) *ApprovalManager {
 	return &ApprovalManager{
 		pending: make(map[string]*ApprovalRequest),
 		timeout: timeout,
+		now:     time.Now,
 	}
 }
 
@@ -79,11 +102,16 @@ func (am *ApprovalManager) Submit(req *ApprovalRequest) <-chan ApprovalDecision
 	req.Decision = "pending"
 	req.done = make(chan ApprovalDecision, 1)
 	am.pending[req.ID] = req
+	draining := am.draining
 	am.mu.Unlock()
 
 	if am.OnRequest != nil {
 		am.OnRequest(req)
 	}
+	if draining {
+		am.settle(req.ID, DecisionDenied, ReasonShutdown)
+		return req.done
+	}
 
 	// Timeout goroutine
 	go func() {
@@ -92,17 +120,22 @@ func (am *ApprovalManager) Submit(req *ApprovalRequest) <-chan ApprovalDecision
 		<-timer.C
 
 		am.mu.Lock()
-		if _, exists := am.pending[req.ID]; exists {
-			now := time.Now()
+		_, exists := am.pending[req.ID]
+		if exists {
+			now := am.now()
 			req.Decision = DecisionTimeout.String()
 			req.DecidedAt = &now
-			delete(am.pending, req.ID)
+			req.Reason = ReasonTimeout
+			am.remove(req.ID)
 			select {
 			case req.done <- DecisionTimeout:
 			default:
 			}
 		}
 		am.mu.Unlock()
+		if exists {
+			am.resolved(req)
+		}
 	}()
 
 	return req.done
@@ -110,34 +143,96 @@ func (am *ApprovalManager) Submit(req *ApprovalRequest) <-chan ApprovalDecision
 
 // Resolve marks a pending request as approved or denied.
 func (am *ApprovalManager) Resolve(id string, approved bool) error {
-	am.mu.Lock()
-	defer am.mu.Unlock()
+	decision := DecisionDenied
+	if approved {
+		decision = DecisionApproved
+	}
+	return am.settle(id, decision, ReasonManual)
+}
 
+// Cancel withdraws a pending request without approving or denying it, as
+// a reviewer does from the dashboard.
+func (am *ApprovalManager) Cancel(id string) error {
+	return am.cancel(id, ReasonWithdrawn)
+}
+
+// cancel withdraws a pending request, recording reason as the cause.
+func (am *ApprovalManager) cancel(id, reason string) error {
+	return am.settle(id, DecisionCancelled, reason)
+}
+
+// settle decides a pending request and wakes the interceptor waiting on it.
+func (am *ApprovalManager) settle(id string, decision ApprovalDecision, reason string) error {
+	am.mu.Lock()
 	req, exists := am.pending[id]
 	if !exists {
+		am.mu.Unlock()
 		return fmt.Errorf("approval request %q not found or already resolved", id)
 	}
 
-	now := time.Now()
+	now := am.now()
 	req.DecidedAt = &now
-	if approved {
-		req.Decision = DecisionApproved.String()
-	} else {
-		req.Decision = DecisionDenied.String()
+	req.Decision = decision.String()
+	req.Reason = reason
+	am.remove(id)
+	select {
+	case req.done <- decision:
+	default:
 	}
+	am.mu.Unlock()
+
+	am.resolved(req)
+	return nil
+}
 
+// remove drops a request from the pending set. Must be called with mu
+// held.
+func (am *ApprovalManager) remove(id string) {
 	delete(am.pending, id)
+	if am.idle != nil && len(am.pending) == 0 {
+		close(am.idle)
+		am.idle = nil
+	}
+}
 
-	decision := DecisionDenied
-	if approved {
-		decision = DecisionApproved
+// Drain prepares for shutdown. It stops taking new requests, denying
+// each as it arrives, and waits up to grace for the pending ones to be
+// decided. Those still pending are then denied with ReasonShutdown and
+// returned, so the host is answered rather than left waiting.
+func (am *ApprovalManager) Drain(grace time.Duration) []*ApprovalRequest {
+	am.mu.Lock()
+	am.draining = true
+	var idle chan struct{}
+	if len(am.pending) > 0 && grace > 0 {
+		if am.idle == nil {
+			am.idle = make(chan struct{})
+		}
+		idle = am.idle
 	}
-	select {
-	case req.done <- decision:
-	default:
+	am.mu.Unlock()
+
+	if idle != nil {
+		timer := time.NewTimer(grace)
+		select {
+		case <-idle:
+		case <-timer.C:
+		}
+		timer.Stop()
 	}
 
-	return nil
+	var denied []*ApprovalRequest
+	for _, req := range am.Pending() {
+		if am.settle(req.ID, DecisionDenied, ReasonShutdown) == nil {
+			denied = append(denied, req)
+		}
+	}
+	return denied
+}
+
+func (am *ApprovalManager) resolved(req *ApprovalRequest) {
+	if am.OnResolve != nil {
+		am.OnResolve(req)
+	}
 }
 
 // Pending returns all pending approval requests.
@@ -159,9 +254,39 @@ func (am *ApprovalManager) PendingCount() int {
 	return len(am.pending)
 }
 
+// ChangedToolRule is the rule name recorded for approvals required
+// because a tool changed mid-session.
+const ChangedToolRule = "tool-changed"
+
+// ChangedTools reports tools a server added or modified mid-session. It
+// is implemented by ToolAnalyticsInterceptor.
+type ChangedTools interface {
+	ToolChanged(sessionID, tool string) bool
+	AcknowledgeTool(sessionID, tool string)
+}
+
 // ApprovalInterceptor blocks messages that require human approval.
 type ApprovalInterceptor struct {
 	manager *ApprovalManager
+
+	// Redactor, when set, scrubs the payload shown to the reviewer.
+	// The forwarded message is never modified.
+	Redactor *ScrubberInterceptor
+
+	// RedactArgs maps tool names to tools/call argument keys whose values
+	// are blanked in the payload shown to the reviewer and recorded with
+	// the decision, as LoggingInterceptor.RedactArgs does for the log.
+	RedactArgs map[string][]string
+
+	// CancelMode controls how a request cancelled by a reviewer is
+	// answered: DenyModeError (the default) blocks it with a "cancelled"
+	// reason, DenyModeDrop discards it without replying.
+	CancelMode policy.DenyMode
+
+	// ChangedTools, if set, holds the next call to a tool the server
+	// added or modified mid-session for approval, whatever the policy
+	// says. Once a call is approved, later calls go through.
+	ChangedTools ChangedTools
 }
 
 func NewApprovalInterceptor(manager *ApprovalManager) *ApprovalInterceptor {
@@ -169,6 +294,15 @@ func NewApprovalInterceptor(manager *ApprovalManager) *ApprovalInterceptor {
 }
 
 func (a *ApprovalInterceptor) Intercept(ctx context.Context, msg *InterceptedMessage) ([]byte, error) {
+	toolName := ""
+	if msg.Parsed.Method == "tools/call" {
+		toolName = policy.ExtractToolName(msg.Parsed.Params)
+	}
+	changed := a.changedTool(msg, toolName)
+	if changed {
+		requireApproval(msg, ChangedToolRule, "")
+		msg.Metadata[MetaKeyAudit] = true
+	}
 	if msg.Metadata == nil {
 		return msg.RawBytes, nil
 	}
@@ -179,9 +313,13 @@ func (a *ApprovalInterceptor) Intercept(ctx context.Context, msg *InterceptedMes
 	}
 
 	ruleName, _ := msg.Metadata[MetaKeyPolicyRule].(string)
-	toolName := ""
-	if msg.Parsed.Method == "tools/call" {
-		toolName = policy.ExtractToolName(msg.Parsed.Params)
+
+	payload := msg.RawBytes
+	if keys := a.RedactArgs[toolName]; len(keys) > 0 {
+		payload = redactToolArgs(payload, keys)
+	}
+	if a.Redactor != nil {
+		payload, _ = a.Redactor.Redact(payload)
 	}
 
 	req := &ApprovalRequest{
@@ -191,7 +329,7 @@ func (a *ApprovalInterceptor) Intercept(ctx context.Context, msg *InterceptedMes
 		Method:    msg.Parsed.Method,
 		ToolName:  toolName,
 		RuleName:  ruleName,
-		Payload:   string(msg.RawBytes),
+		Payload:   string(payload),
 	}
 
 	ch := a.manager.Submit(req)
@@ -200,15 +338,41 @@ func (a *ApprovalInterceptor) Intercept(ctx context.Context, msg *InterceptedMes
 	case decision := <-ch:
 		switch decision {
 		case DecisionApproved:
+			if changed {
+				a.ChangedTools.AcknowledgeTool(msg.SessionID, toolName)
+			}
 			return msg.RawBytes, nil
 		case DecisionDenied:
+			if req.Reason == ReasonShutdown {
+				return nil, fmt.Errorf("approval abandoned: contextgate is shutting down (rule: %s)", ruleName)
+			}
+			if custom, _ := msg.Metadata[MetaKeyPolicyMsg].(string); custom != "" {
+				return nil, fmt.Errorf("denied by human review: %s", custom)
+			}
 			return nil, fmt.Errorf("denied by human review (rule: %s)", ruleName)
 		case DecisionTimeout:
 			return nil, fmt.Errorf("approval timed out (rule: %s)", ruleName)
+		case DecisionCancelled:
+			if a.CancelMode == policy.DenyModeDrop {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("approval cancelled (rule: %s)", ruleName)
 		default:
 			return nil, fmt.Errorf("unexpected approval decision")
 		}
+	case <-msg.Cancelled:
+		// The sender gave up on the request; it expects no response
+		a.manager.cancel(req.ID, ReasonHostCancelled)
+		return nil, nil
 	case <-ctx.Done():
 		return nil, fmt.Errorf("context cancelled while awaiting approval")
 	}
 }
+
+// changedTool reports whether msg is a host call to a tool that changed
+// mid-session.
+func (a *ApprovalInterceptor) changedTool(msg *InterceptedMessage, toolName string) bool {
+	return a.ChangedTools != nil && toolName != "" &&
+		msg.Direction == DirHostToServer && msg.Parsed.Kind() == KindRequest &&
+		a.ChangedTools.ToolChanged(msg.SessionID, toolName)
+}
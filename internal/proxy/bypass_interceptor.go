@@ -0,0 +1,43 @@
+package proxy
+
+import "context"
+
+// MetaKeyBypass, when set to true by an interceptor, has the chain
+// forward the message without running the interceptors after it.
+const MetaKeyBypass = "bypass"
+
+// BypassInterceptor lets low-value, high-frequency requests and
+// notifications (ping, notifications/progress) skip the rest of the
+// chain. It should run first; everything after it, including policy and
+// scrubbing, is skipped for the listed methods.
+type BypassInterceptor struct {
+	methods map[string]bool
+
+	// Log, if set, records bypassed messages (normally the logging
+	// interceptor, which the bypass would otherwise skip).
+	Log Interceptor
+}
+
+// NewBypassInterceptor creates an interceptor that bypasses the given methods.
+func NewBypassInterceptor(methods ...string) *BypassInterceptor {
+	b := &BypassInterceptor{methods: make(map[string]bool, len(methods))}
+	for _, m := range methods {
+		b.methods[m] = true
+	}
+	return b
+}
+
+func (b *BypassInterceptor) Intercept(ctx context.Context, msg *InterceptedMessage) ([]byte, error) {
+	if msg.ParseErr != nil || !b.methods[msg.Parsed.Method] {
+		return msg.RawBytes, nil
+	}
+
+	if msg.Metadata == nil {
+		msg.Metadata = make(map[string]any)
+	}
+	msg.Metadata[MetaKeyBypass] = true
+	if b.Log != nil {
+		b.Log.Intercept(ctx, msg)
+	}
+	return msg.RawBytes, nil
+}
@@ -0,0 +1,56 @@
+package proxy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/contextgate/contextgate/internal/eventbus"
+)
+
+func TestBypassInterceptor(t *testing.T) {
+	tests := []struct {
+		name       string
+		raw        string
+		log        bool
+		wantLater  bool
+		wantLogged bool
+	}{
+		{"bypassed and logged", `{"jsonrpc":"2.0","id":1,"method":"ping"}`, true, false, true},
+		{"bypassed unlogged", `{"jsonrpc":"2.0","id":1,"method":"ping"}`, false, false, false},
+		{"bypassed notification", `{"jsonrpc":"2.0","method":"notifications/progress","params":{"progress":1}}`, true, false, true},
+		{"other method", `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`, true, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := &mockLogStore{}
+			li := NewLoggingInterceptor(st, eventbus.New(10))
+			bypass := NewBypassInterceptor("ping", "notifications/progress")
+			if tt.log {
+				bypass.Log = li
+			}
+			later := namedInterceptor("policy")
+
+			msg := &InterceptedMessage{
+				Timestamp: time.Now(),
+				Direction: DirHostToServer,
+				RawBytes:  []byte(tt.raw),
+			}
+			msg.Parsed, msg.ParseErr = ParseMessage(msg.RawBytes)
+
+			out, err := NewInterceptorChain(bypass, later, li).Process(context.Background(), msg)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(out) != tt.raw {
+				t.Errorf("forwarded %s, want %s", out, tt.raw)
+			}
+			if _, ran := msg.Metadata["order"]; ran != tt.wantLater {
+				t.Errorf("later interceptor ran = %v, want %v", ran, tt.wantLater)
+			}
+			if logged := len(st.entries) == 1; logged != tt.wantLogged {
+				t.Errorf("logged %d entries, want logged = %v", len(st.entries), tt.wantLogged)
+			}
+		})
+	}
+}
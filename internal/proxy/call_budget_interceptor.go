@@ -0,0 +1,46 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// CallBudgetInterceptor caps the total number of tools/call requests a
+// session may make. Calls beyond the budget are blocked. Unlike a rate
+// limit, the budget never refills.
+type CallBudgetInterceptor struct {
+	max int
+
+	mu     sync.Mutex
+	counts map[string]int // session ID → calls allowed so far
+}
+
+// NewCallBudgetInterceptor creates a budget of max calls per session.
+func NewCallBudgetInterceptor(max int) *CallBudgetInterceptor {
+	return &CallBudgetInterceptor{
+		max:    max,
+		counts: make(map[string]int),
+	}
+}
+
+func (b *CallBudgetInterceptor) Intercept(_ context.Context, msg *InterceptedMessage) ([]byte, error) {
+	if msg.Direction != DirHostToServer || msg.Parsed.Method != "tools/call" {
+		return msg.RawBytes, nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.counts[msg.SessionID] >= b.max {
+		return nil, fmt.Errorf("tool call budget exhausted: this session is limited to %d tools/call requests", b.max)
+	}
+	b.counts[msg.SessionID]++
+	return msg.RawBytes, nil
+}
+
+// Used returns how many calls a session has made against its budget.
+func (b *CallBudgetInterceptor) Used(sessionID string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.counts[sessionID]
+}
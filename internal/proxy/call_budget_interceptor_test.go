@@ -0,0 +1,71 @@
+package proxy
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func budgetMsg(sessionID, method string) *InterceptedMessage {
+	raw := []byte(`{"jsonrpc":"2.0","id":1,"method":"` + method + `","params":{"name":"read_file"}}`)
+	parsed, _ := ParseMessage(raw)
+	return &InterceptedMessage{
+		Timestamp: time.Now(),
+		SessionID: sessionID,
+		Direction: DirHostToServer,
+		RawBytes:  raw,
+		Parsed:    parsed,
+	}
+}
+
+func TestCallBudget_BlocksPastLimit(t *testing.T) {
+	b := NewCallBudgetInterceptor(3)
+	ctx := context.Background()
+
+	for i := 1; i <= 3; i++ {
+		if _, err := b.Intercept(ctx, budgetMsg("s1", "tools/call")); err != nil {
+			t.Fatalf("call %d: unexpected error: %v", i, err)
+		}
+	}
+	_, err := b.Intercept(ctx, budgetMsg("s1", "tools/call"))
+	if err == nil || !strings.Contains(err.Error(), "budget exhausted") {
+		t.Fatalf("call 4: err = %v, want budget error", err)
+	}
+	if b.Used("s1") != 3 {
+		t.Errorf("used = %d, want 3 (blocked calls don't count)", b.Used("s1"))
+	}
+
+	// Other methods and other sessions are unaffected
+	if _, err := b.Intercept(ctx, budgetMsg("s1", "tools/list")); err != nil {
+		t.Errorf("tools/list blocked: %v", err)
+	}
+	if _, err := b.Intercept(ctx, budgetMsg("s2", "tools/call")); err != nil {
+		t.Errorf("other session blocked: %v", err)
+	}
+}
+
+func TestCallBudget_Concurrent(t *testing.T) {
+	b := NewCallBudgetInterceptor(50)
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	allowed := 0
+	var wg sync.WaitGroup
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := b.Intercept(ctx, budgetMsg("s1", "tools/call")); err == nil {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if allowed != 50 {
+		t.Errorf("allowed %d calls, want exactly 50", allowed)
+	}
+}
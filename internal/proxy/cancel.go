@@ -0,0 +1,71 @@
+package proxy
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// MethodCancelled is the notification either side sends to cancel one of
+// its own requests.
+const MethodCancelled = "notifications/cancelled"
+
+// cancelledKey returns the key of the request a notifications/cancelled
+// message refers to. The request travelled the same way as the
+// notification.
+func cancelledKey(dir Direction, parsed JSONRPCMessage) (corrKey, bool) {
+	if parsed.Method != MethodCancelled || parsed.ID != nil {
+		return corrKey{}, false
+	}
+	var params struct {
+		RequestID json.RawMessage `json:"requestId"`
+	}
+	if err := json.Unmarshal(parsed.Params, &params); err != nil || len(params.RequestID) == 0 {
+		return corrKey{}, false
+	}
+	return corrKey{dir: dir, id: string(params.RequestID)}, true
+}
+
+// cancelWatch hands each request a channel that is closed if its sender
+// cancels it before the interceptor chain has finished with it, so an
+// interceptor that holds the request (approval) can give up. Requests are
+// registered as they are read, ahead of processing, so a cancellation
+// read while an earlier message is still in the chain is not missed.
+type cancelWatch struct {
+	mu      sync.Mutex
+	pending map[corrKey]chan struct{}
+}
+
+func newCancelWatch() *cancelWatch {
+	return &cancelWatch{pending: make(map[corrKey]chan struct{})}
+}
+
+// watch registers a request and returns its cancellation channel.
+func (w *cancelWatch) watch(key corrKey) chan struct{} {
+	ch := make(chan struct{})
+	w.mu.Lock()
+	w.pending[key] = ch
+	w.mu.Unlock()
+	return ch
+}
+
+// cancel closes the channel of the request with key, if it is registered.
+func (w *cancelWatch) cancel(key corrKey) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	ch, ok := w.pending[key]
+	if ok {
+		close(ch)
+		delete(w.pending, key)
+	}
+	return ok
+}
+
+// forget unregisters a request once the chain is done with it. ch guards
+// against removing a later request that reused the ID.
+func (w *cancelWatch) forget(key corrKey, ch chan struct{}) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.pending[key] == ch {
+		delete(w.pending, key)
+	}
+}
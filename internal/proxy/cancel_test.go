@@ -0,0 +1,143 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/contextgate/contextgate/internal/policy"
+)
+
+func cancelLine(id int) string {
+	return fmt.Sprintf(`{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":%d,"reason":"user aborted"}}`+"\n", id)
+}
+
+func TestCancelledKey(t *testing.T) {
+	msg, _ := ParseMessage([]byte(cancelLine(7)))
+	key, ok := cancelledKey(DirHostToServer, msg)
+	if !ok || key != hostKey("7") {
+		t.Errorf("cancelledKey = %v, %v; want %v", key, ok, hostKey("7"))
+	}
+
+	msg, _ = ParseMessage([]byte(`{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":"abc"}}`))
+	if key, _ := cancelledKey(DirServerToHost, msg); key != serverKey(`"abc"`) {
+		t.Errorf("string id key = %v", key)
+	}
+
+	for _, raw := range []string{
+		`{"jsonrpc":"2.0","method":"notifications/cancelled","params":{}}`,
+		`{"jsonrpc":"2.0","method":"notifications/progress","params":{"requestId":1}}`,
+	} {
+		msg, _ := ParseMessage([]byte(raw))
+		if _, ok := cancelledKey(DirHostToServer, msg); ok {
+			t.Errorf("cancelledKey(%s) matched", raw)
+		}
+	}
+}
+
+func TestApproval_CancelledBySender(t *testing.T) {
+	mgr := NewApprovalManager(10 * time.Second)
+	ai := NewApprovalInterceptor(mgr)
+
+	msg := makeApprovalMsg()
+	cancelled := make(chan struct{})
+	msg.Cancelled = cancelled
+
+	var submitted *ApprovalRequest
+	mgr.OnRequest = func(req *ApprovalRequest) {
+		submitted = req
+		close(cancelled)
+	}
+
+	result, err := ai.Intercept(context.Background(), msg)
+	if result != nil || err != nil {
+		t.Fatalf("Intercept = %s, %v; want the message dropped", result, err)
+	}
+	if n := mgr.PendingCount(); n != 0 {
+		t.Errorf("%d approvals still pending", n)
+	}
+	if submitted.Decision != "cancelled" {
+		t.Errorf("decision = %q, want cancelled", submitted.Decision)
+	}
+}
+
+func TestProxy_CancelWithdrawsApproval(t *testing.T) {
+	mgr := NewApprovalManager(10 * time.Second)
+	requireApproval := InterceptorFunc(func(_ context.Context, msg *InterceptedMessage) ([]byte, error) {
+		if msg.Parsed.Method == "tools/call" {
+			msg.Metadata = map[string]any{MetaKeyPolicyAction: string(policy.ActionRequireApproval)}
+		}
+		return msg.RawBytes, nil
+	})
+	chain := NewInterceptorChain(requireApproval, NewApprovalInterceptor(mgr))
+	p := NewProxy(Config{Command: "test"}, chain, testLogger())
+	down := &syncBuffer{}
+	p.downStdin = down
+
+	hostR, hostW := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		p.pipeMessages(context.Background(), hostR, down, DirHostToServer)
+		close(done)
+	}()
+
+	go hostW.Write([]byte(toolsCallLine(5)))
+	waitFor(t, func() bool { return mgr.PendingCount() == 1 })
+
+	go hostW.Write([]byte(cancelLine(5)))
+	waitFor(t, func() bool { return mgr.PendingCount() == 0 })
+
+	// The cancellation itself is forwarded; the cancelled call never is
+	waitFor(t, func() bool { return down.lineCount() == 1 })
+	if got := down.String(); strings.Contains(got, "tools/call") || !strings.Contains(got, "notifications/cancelled") {
+		t.Errorf("downstream got %q", got)
+	}
+
+	hostW.Close()
+	<-done
+}
+
+func TestProxy_CancelReleasesInflightSlot(t *testing.T) {
+	p := NewProxy(Config{Command: "test", MaxInflight: 1}, NewInterceptorChain(), testLogger())
+	down := &syncBuffer{}
+	p.downStdin = down
+
+	hostR, hostW := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		p.pipeMessages(context.Background(), hostR, down, DirHostToServer)
+		close(done)
+	}()
+
+	go hostW.Write([]byte(toolsCallLine(1)))
+	waitFor(t, func() bool { return down.lineCount() == 1 })
+	go hostW.Write([]byte(toolsCallLine(2)))
+	time.Sleep(30 * time.Millisecond)
+	if n := down.lineCount(); n != 1 {
+		t.Fatalf("expected the second request to be queued, %d lines forwarded", n)
+	}
+
+	// Cancelling the first request frees its slot without a response
+	go hostW.Write([]byte(cancelLine(1)))
+	waitFor(t, func() bool { return down.lineCount() == 3 })
+
+	var ids []string
+	for _, line := range strings.Split(strings.TrimSpace(down.String()), "\n") {
+		var m struct{ ID json.RawMessage }
+		json.Unmarshal([]byte(line), &m)
+		ids = append(ids, string(m.ID))
+	}
+	if ids[1] != "2" {
+		t.Errorf("forwarded ids = %v, want request 2 before the cancellation", ids)
+	}
+	if n := p.tracker.inflight(); n != 1 {
+		t.Errorf("inflight = %d, want 1", n)
+	}
+
+	hostW.Close()
+	<-done
+}
@@ -22,12 +22,12 @@ func buildTestChain(rules []policy.Rule, scrubEnabled bool, approvalTimeout time
 	engine := policy.NewEngine(cfg)
 
 	policyInt := NewPolicyInterceptor(engine)
-	scrubber := NewScrubberInterceptor(scrubEnabled, nil)
+	scrubber, _ := NewScrubberInterceptor(scrubEnabled, nil)
 	mgr := NewApprovalManager(approvalTimeout)
 	approvalInt := NewApprovalInterceptor(mgr)
 
 	// Use a no-op logging interceptor (no store/eventbus needed)
-	chain := NewInterceptorChain(policyInt, scrubber, approvalInt, &noopInterceptor{})
+	chain := NewInterceptorChain(policyInt, OnlyDirection(DirServerToHost, scrubber), approvalInt, &noopInterceptor{})
 	return chain, mgr
 }
 
@@ -41,7 +41,7 @@ func buildFullChain(rules []policy.Rule, scrubEnabled bool, approvalTimeout time
 	engine := policy.NewEngine(cfg)
 
 	policyInt := NewPolicyInterceptor(engine)
-	scrubber := NewScrubberInterceptor(scrubEnabled, nil)
+	scrubber, _ := NewScrubberInterceptor(scrubEnabled, nil)
 	mgr := NewApprovalManager(approvalTimeout)
 	approvalInt := NewApprovalInterceptor(mgr)
 
@@ -49,7 +49,7 @@ func buildFullChain(rules []policy.Rule, scrubEnabled bool, approvalTimeout time
 	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
 	toolAnalytics := NewToolAnalyticsInterceptor(ms, logger, pruneCfg)
 
-	chain := NewInterceptorChain(policyInt, scrubber, approvalInt, toolAnalytics, &noopInterceptor{})
+	chain := NewInterceptorChain(policyInt, OnlyDirection(DirServerToHost, scrubber), approvalInt, toolAnalytics, &noopInterceptor{})
 	return chain, mgr, ms
 }
 
@@ -352,14 +352,14 @@ func TestFullChain_ToolsListPruning(t *testing.T) {
 	engine := policy.NewEngine(cfg)
 
 	policyInt := NewPolicyInterceptor(engine)
-	scrubber := NewScrubberInterceptor(false, nil)
+	scrubber, _ := NewScrubberInterceptor(false, nil)
 	mgr := NewApprovalManager(10 * time.Second)
 	approvalInt := NewApprovalInterceptor(mgr)
 
 	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
 	toolAnalytics := NewToolAnalyticsInterceptor(ms, logger, PruneConfig{UnusedSessions: 3})
 
-	chain := NewInterceptorChain(policyInt, scrubber, approvalInt, toolAnalytics, &noopInterceptor{})
+	chain := NewInterceptorChain(policyInt, OnlyDirection(DirServerToHost, scrubber), approvalInt, toolAnalytics, &noopInterceptor{})
 	ctx := context.Background()
 
 	// Send tools/list request
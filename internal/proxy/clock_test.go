@@ -0,0 +1,101 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a settable time source for the now hooks.
+type fakeClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{t: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = c.t.Add(d)
+}
+
+func TestPipeMessages_TimestampsFromClock(t *testing.T) {
+	clock := newFakeClock()
+	var got []*InterceptedMessage
+	record := InterceptorFunc(func(_ context.Context, msg *InterceptedMessage) ([]byte, error) {
+		got = append(got, msg)
+		clock.Advance(-time.Second) // the host's clock steps back mid-session
+		return msg.RawBytes, nil
+	})
+
+	p := NewProxy(Config{SessionID: "test"}, NewInterceptorChain(record), testLogger())
+	p.now = clock.Now
+	src := strings.NewReader(toolsCallLine(1) + toolsCallLine(2))
+	if err := p.pipeMessages(context.Background(), src, io.Discard, DirHostToServer); err != nil {
+		t.Fatalf("pipeMessages: %v", err)
+	}
+
+	if len(got) != 2 {
+		t.Fatalf("intercepted %d messages, want 2", len(got))
+	}
+	start := newFakeClock().Now()
+	if !got[0].Timestamp.Equal(start) || !got[1].Timestamp.Equal(start.Add(-time.Second)) {
+		t.Errorf("timestamps = %v, %v; want the fake clock's", got[0].Timestamp, got[1].Timestamp)
+	}
+	if got[1].Seq <= got[0].Seq {
+		t.Errorf("seq went backwards with the clock: %d after %d", got[1].Seq, got[0].Seq)
+	}
+}
+
+func TestApprovalManager_DecidedAtFromClock(t *testing.T) {
+	clock := newFakeClock()
+	mgr := NewApprovalManager(10 * time.Second)
+	mgr.now = clock.Now
+
+	req := &ApprovalRequest{ToolName: "delete_file"}
+	mgr.Submit(req)
+	clock.Advance(time.Minute)
+	if err := mgr.Resolve(req.ID, true); err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+
+	if req.DecidedAt == nil || !req.DecidedAt.Equal(clock.Now()) {
+		t.Errorf("DecidedAt = %v, want %v", req.DecidedAt, clock.Now())
+	}
+}
+
+func TestToolAnalytics_ExpiresPendingByClock(t *testing.T) {
+	clock := newFakeClock()
+	ta := NewToolAnalyticsInterceptor(newMockToolStore(), testLogger(), PruneConfig{})
+	ta.now = clock.Now
+
+	req := makeToolsListRequest("1")
+	req.Timestamp = clock.Now()
+	ta.Intercept(context.Background(), req)
+
+	pending := func() bool { return ta.pending.has(corrKey{DirHostToServer, "1"}) }
+
+	clock.Advance(pendingTTL - time.Second)
+	ta.expirePending()
+	if !pending() {
+		t.Fatal("request expired before its TTL")
+	}
+
+	clock.Advance(2 * time.Second)
+	ta.expirePending()
+	if pending() {
+		t.Error("request still pending after its TTL")
+	}
+}
@@ -0,0 +1,277 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// MetaKeyLatencyMs holds, for a response, the milliseconds since its
+// request was forwarded.
+const MetaKeyLatencyMs = "latency_ms"
+
+// MetaKeyRequestTool holds, for a response, the tool its tools/call
+// request called.
+const MetaKeyRequestTool = "request_tool"
+
+// errBusy is returned when the in-flight limit is reached and queueing is disabled.
+var errBusy = errors.New("server busy: too many in-flight requests")
+
+// corrKey identifies an outstanding request. JSON-RPC IDs are only unique
+// per sender, so the host and the server may use the same ID at once;
+// the direction the request travelled keeps them apart.
+type corrKey struct {
+	dir Direction // direction of the request
+	id  string
+}
+
+// requestKey returns the correlation key for a request.
+func requestKey(msg *InterceptedMessage) corrKey {
+	return corrKey{dir: msg.Direction, id: string(msg.Parsed.ID)}
+}
+
+// responseKey returns the key of the request a response answers, which
+// travelled the opposite way.
+func responseKey(msg *InterceptedMessage) corrKey {
+	return corrKey{dir: msg.Direction.Reverse(), id: string(msg.Parsed.ID)}
+}
+
+// pendingTTL is how long a request an interceptor remembers waits for its
+// response before it is forgotten, such as one blocked later in the chain.
+const pendingTTL = 5 * time.Minute
+
+// callCorrelator remembers the requests an interceptor cares about, each
+// with a value V, until their responses pass through it. Requests still
+// unanswered after pendingTTL are forgotten. It is safe for concurrent use.
+type callCorrelator[V any] struct {
+	mu      sync.Mutex
+	pending map[callKey]pendingCall[V]
+}
+
+// callKey identifies a remembered request. Interceptors may see several
+// sessions, whose IDs are independent.
+type callKey struct {
+	session string
+	key     corrKey
+}
+
+type pendingCall[V any] struct {
+	value V
+	sent  time.Time
+}
+
+func newCallCorrelator[V any]() *callCorrelator[V] {
+	return &callCorrelator[V]{pending: make(map[callKey]pendingCall[V])}
+}
+
+// remember records msg, a request, with value, forgetting requests that
+// were sent more than pendingTTL before it.
+func (c *callCorrelator[V]) remember(msg *InterceptedMessage, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.expireLocked(msg.Timestamp)
+	c.pending[callKey{session: msg.SessionID, key: requestKey(msg)}] = pendingCall[V]{value: value, sent: msg.Timestamp}
+}
+
+// answer forgets the request msg, a response, answers and returns its
+// value, or false if it wasn't remembered.
+func (c *callCorrelator[V]) answer(msg *InterceptedMessage) (V, bool) {
+	key := callKey{session: msg.SessionID, key: responseKey(msg)}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	call, ok := c.pending[key]
+	delete(c.pending, key)
+	return call.value, ok
+}
+
+// expire forgets requests sent more than pendingTTL before now.
+func (c *callCorrelator[V]) expire(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.expireLocked(now)
+}
+
+func (c *callCorrelator[V]) expireLocked(now time.Time) {
+	cutoff := now.Add(-pendingTTL)
+	for key, call := range c.pending {
+		if call.sent.Before(cutoff) {
+			delete(c.pending, key)
+		}
+	}
+}
+
+// requestTracker correlates requests in both directions with their
+// responses and optionally caps how many host→server requests may be
+// outstanding at once.
+type requestTracker struct {
+	mu       sync.Mutex
+	pending  map[corrKey]trackedRequest
+	timedOut map[corrKey]time.Time // expired requests whose late responses are dropped
+	slots    chan struct{}         // nil when unlimited
+	freed    chan struct{}         // signalled when a slot is released
+}
+
+// trackedRequest is an outstanding request: when it was sent and, for
+// tools/call, the tool it called.
+type trackedRequest struct {
+	sent time.Time
+	tool string
+}
+
+func newRequestTracker(maxInflight int) *requestTracker {
+	t := &requestTracker{pending: make(map[corrKey]trackedRequest), timedOut: make(map[corrKey]time.Time)}
+	if maxInflight > 0 {
+		t.slots = make(chan struct{}, maxInflight)
+		t.freed = make(chan struct{}, 1)
+	}
+	return t
+}
+
+// begin records a request. When the in-flight limit is reached for a
+// host→server request it waits for a slot (wait=true) or returns errBusy.
+// Server→host requests are tracked but never limited.
+func (t *requestTracker) begin(ctx context.Context, key corrKey, req trackedRequest, wait bool) error {
+	t.mu.Lock()
+	if _, exists := t.pending[key]; exists {
+		// Already holds a slot — don't take a second one for a reused ID
+		t.pending[key] = req
+		t.mu.Unlock()
+		return nil
+	}
+	t.mu.Unlock()
+
+	if t.limited(key) {
+		if wait {
+			select {
+			case t.slots <- struct{}{}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		} else {
+			select {
+			case t.slots <- struct{}{}:
+			default:
+				return errBusy
+			}
+		}
+	}
+
+	t.mu.Lock()
+	t.pending[key] = req
+	// A reused ID's response belongs to the new request
+	delete(t.timedOut, key)
+	t.mu.Unlock()
+	return nil
+}
+
+// outstanding reports whether a request with key is awaiting its response.
+func (t *requestTracker) outstanding(key corrKey) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, ok := t.pending[key]
+	return ok
+}
+
+// finish marks a request as answered and releases its slot. It returns
+// the request, or false if it wasn't outstanding.
+func (t *requestTracker) finish(key corrKey) (trackedRequest, bool) {
+	t.mu.Lock()
+	req, ok := t.pending[key]
+	if ok {
+		delete(t.pending, key)
+	}
+	t.mu.Unlock()
+
+	if ok && t.limited(key) {
+		t.release()
+	}
+	return req, ok
+}
+
+// abandon forgets every outstanding request, releasing their slots, and
+// returns the keys of those that travelled in dir.
+func (t *requestTracker) abandon(dir Direction) []corrKey {
+	t.mu.Lock()
+	all := make([]corrKey, 0, len(t.pending))
+	for key := range t.pending {
+		all = append(all, key)
+	}
+	t.mu.Unlock()
+
+	var keys []corrKey
+	for _, key := range all {
+		if _, ok := t.finish(key); ok && key.dir == dir {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// expire finishes the requests that travelled in dir and were sent
+// before cutoff, remembering them so that a late response can be
+// recognized, and returns their keys. Requests expired more than
+// pendingTTL before cutoff are no longer remembered.
+func (t *requestTracker) expire(dir Direction, cutoff time.Time) []corrKey {
+	t.mu.Lock()
+	for key, at := range t.timedOut {
+		if at.Before(cutoff.Add(-pendingTTL)) {
+			delete(t.timedOut, key)
+		}
+	}
+	// Moved in one step, so a response arriving meanwhile is either
+	// answered or recognized as late
+	var keys []corrKey
+	for key, req := range t.pending {
+		if key.dir == dir && req.sent.Before(cutoff) {
+			delete(t.pending, key)
+			t.timedOut[key] = cutoff
+			keys = append(keys, key)
+		}
+	}
+	t.mu.Unlock()
+
+	for _, key := range keys {
+		if t.limited(key) {
+			t.release()
+		}
+	}
+	return keys
+}
+
+// late reports whether a response with key answers a request that
+// already expired, and forgets that request.
+func (t *requestTracker) late(key corrKey) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, ok := t.timedOut[key]
+	delete(t.timedOut, key)
+	return ok
+}
+
+// release frees a slot and signals released.
+func (t *requestTracker) release() {
+	<-t.slots
+	select {
+	case t.freed <- struct{}{}:
+	default:
+	}
+}
+
+// released returns a channel that receives after a slot is released, so
+// that requests queued while every slot was taken can be retried.
+func (t *requestTracker) released() <-chan struct{} {
+	return t.freed
+}
+
+// limited reports whether requests with this key count against the limit.
+func (t *requestTracker) limited(key corrKey) bool {
+	return t.slots != nil && key.dir == DirHostToServer
+}
+
+// inflight returns the number of outstanding requests.
+func (t *requestTracker) inflight() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.pending)
+}
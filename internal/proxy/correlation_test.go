@@ -0,0 +1,429 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer is a bytes.Buffer safe for concurrent use.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) Close() error { return nil }
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func (b *syncBuffer) lineCount() int {
+	s := strings.TrimSpace(b.String())
+	if s == "" {
+		return 0
+	}
+	return len(strings.Split(s, "\n"))
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("condition not met before deadline")
+}
+
+func toolsCallLine(id int) string {
+	return fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":"tools/call","params":{"name":"read_file"}}`+"\n", id)
+}
+
+func hostKey(id string) corrKey   { return corrKey{dir: DirHostToServer, id: id} }
+func serverKey(id string) corrKey { return corrKey{dir: DirServerToHost, id: id} }
+
+// has reports whether a request with key is remembered, in any session.
+func (c *callCorrelator[V]) has(key corrKey) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k := range c.pending {
+		if k.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRequestTracker_LimitAndRelease(t *testing.T) {
+	tr := newRequestTracker(1)
+	ctx := context.Background()
+
+	if err := tr.begin(ctx, hostKey("1"), trackedRequest{sent: time.Now()}, false); err != nil {
+		t.Fatalf("first begin: %v", err)
+	}
+	if err := tr.begin(ctx, hostKey("2"), trackedRequest{sent: time.Now()}, false); err != errBusy {
+		t.Fatalf("second begin = %v, want errBusy", err)
+	}
+	if _, ok := tr.finish(hostKey("1")); !ok {
+		t.Fatal("expected request 1 to be outstanding")
+	}
+	if err := tr.begin(ctx, hostKey("2"), trackedRequest{sent: time.Now()}, false); err != nil {
+		t.Fatalf("begin after release: %v", err)
+	}
+	if _, ok := tr.finish(hostKey("unknown")); ok {
+		t.Fatal("expected unknown ID not to be outstanding")
+	}
+}
+
+func TestRequestTracker_ReusedIDHoldsOneSlot(t *testing.T) {
+	tr := newRequestTracker(2)
+	ctx := context.Background()
+
+	tr.begin(ctx, hostKey("1"), trackedRequest{sent: time.Now()}, false)
+	tr.begin(ctx, hostKey("1"), trackedRequest{sent: time.Now()}, false)
+	if err := tr.begin(ctx, hostKey("2"), trackedRequest{sent: time.Now()}, false); err != nil {
+		t.Fatalf("expected a free slot, got %v", err)
+	}
+	if tr.inflight() != 2 {
+		t.Fatalf("inflight = %d, want 2", tr.inflight())
+	}
+}
+
+func TestRequestTracker_WaitHonorsContext(t *testing.T) {
+	tr := newRequestTracker(1)
+	tr.begin(context.Background(), hostKey("1"), trackedRequest{sent: time.Now()}, true)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := tr.begin(ctx, hostKey("2"), trackedRequest{sent: time.Now()}, true); err != context.DeadlineExceeded {
+		t.Fatalf("begin = %v, want deadline exceeded", err)
+	}
+}
+
+func TestProxy_MaxInflightQueues(t *testing.T) {
+	p := NewProxy(Config{Command: "test", MaxInflight: 2}, NewInterceptorChain(), testLogger())
+	down := &syncBuffer{}
+	p.downStdin = down
+
+	hostR, hostW := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		p.pipeMessages(context.Background(), hostR, down, DirHostToServer)
+		close(done)
+	}()
+
+	for id := 1; id <= 3; id++ {
+		go hostW.Write([]byte(toolsCallLine(id)))
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	waitFor(t, func() bool { return down.lineCount() == 2 })
+	time.Sleep(30 * time.Millisecond)
+	if n := down.lineCount(); n != 2 {
+		t.Fatalf("expected third request to be queued, %d forwarded", n)
+	}
+
+	var hostOut bytes.Buffer
+	p.pipeMessages(context.Background(), strings.NewReader(`{"jsonrpc":"2.0","id":1,"result":{}}`+"\n"), &hostOut, DirServerToHost)
+
+	waitFor(t, func() bool { return down.lineCount() == 3 })
+
+	hostW.Close()
+	<-done
+}
+
+func TestProxy_MaxInflightForwardsHostResponses(t *testing.T) {
+	p := NewProxy(Config{Command: "test", MaxInflight: 1}, NewInterceptorChain(), testLogger())
+	down := &syncBuffer{}
+	hostOut := &syncBuffer{}
+	p.downStdin = down
+	p.hostOut = hostOut
+	ctx := context.Background()
+
+	hostR, hostW := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		p.pipeMessages(ctx, hostR, down, DirHostToServer)
+		close(done)
+	}()
+
+	go hostW.Write([]byte(toolsCallLine(1)))
+	waitFor(t, func() bool { return down.lineCount() == 1 })
+	go hostW.Write([]byte(toolsCallLine(2)))
+	time.Sleep(30 * time.Millisecond)
+
+	// The server needs a sampling result before it answers request 1
+	p.pipeMessages(ctx, strings.NewReader(`{"jsonrpc":"2.0","id":"s1","method":"sampling/createMessage","params":{}}`+"\n"), hostOut, DirServerToHost)
+	go hostW.Write([]byte(`{"jsonrpc":"2.0","id":"s1","result":{}}` + "\n"))
+	waitFor(t, func() bool { return down.lineCount() == 2 })
+	if got := down.String(); !strings.Contains(got, `"id":"s1"`) || strings.Contains(got, `"id":2`) {
+		t.Fatalf("expected the sampling result ahead of queued request 2, downstream got:\n%s", got)
+	}
+
+	p.pipeMessages(ctx, strings.NewReader(`{"jsonrpc":"2.0","id":1,"result":{}}`+"\n"), hostOut, DirServerToHost)
+	waitFor(t, func() bool { return down.lineCount() == 3 })
+	if got := down.String(); !strings.Contains(got, `"id":2`) {
+		t.Errorf("expected request 2 once a slot freed, downstream got:\n%s", got)
+	}
+
+	hostW.Close()
+	<-done
+}
+
+func TestProxy_MaxInflightRejects(t *testing.T) {
+	p := NewProxy(Config{Command: "test", MaxInflight: 2, RejectWhenBusy: true}, NewInterceptorChain(), testLogger())
+	down := &syncBuffer{}
+	hostOut := &syncBuffer{}
+	p.downStdin = down
+	p.hostOut = hostOut
+
+	input := toolsCallLine(1) + toolsCallLine(2) + toolsCallLine(3) +
+		`{"jsonrpc":"2.0","method":"notifications/progress"}` + "\n"
+	if err := p.pipeMessages(context.Background(), strings.NewReader(input), down, DirHostToServer); err != nil {
+		t.Fatalf("pipe: %v", err)
+	}
+
+	if n := down.lineCount(); n != 3 {
+		t.Fatalf("expected 2 requests and 1 notification forwarded, got %d lines", n)
+	}
+	if !strings.Contains(hostOut.String(), `"id":3`) || !strings.Contains(hostOut.String(), "server busy") {
+		t.Fatalf("expected busy error for request 3, got: %s", hostOut.String())
+	}
+}
+
+func TestProxy_ResponseCarriesRequestTool(t *testing.T) {
+	var tools []any
+	record := InterceptorFunc(func(_ context.Context, msg *InterceptedMessage) ([]byte, error) {
+		if msg.Direction == DirServerToHost {
+			tools = append(tools, msg.Metadata[MetaKeyRequestTool])
+		}
+		return msg.RawBytes, nil
+	})
+	p := NewProxy(Config{Command: "test"}, NewInterceptorChain(record), testLogger())
+	down := &syncBuffer{}
+	hostOut := &syncBuffer{}
+	p.downStdin = down
+	p.hostOut = hostOut
+	ctx := context.Background()
+
+	// The same ID twice, each answered before it is reused
+	p.pipeMessages(ctx, strings.NewReader(toolsCallLine(1)), down, DirHostToServer)
+	p.pipeMessages(ctx, strings.NewReader(`{"jsonrpc":"2.0","id":1,"result":{}}`+"\n"), hostOut, DirServerToHost)
+	p.pipeMessages(ctx, strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`+"\n"), down, DirHostToServer)
+	p.pipeMessages(ctx, strings.NewReader(`{"jsonrpc":"2.0","id":1,"result":{"tools":[]}}`+"\n"), hostOut, DirServerToHost)
+
+	if len(tools) != 2 || tools[0] != "read_file" || tools[1] != nil {
+		t.Errorf("request tools = %v, want [read_file <nil>]", tools)
+	}
+}
+
+func TestRequestTracker_DirectionsAreIndependent(t *testing.T) {
+	tr := newRequestTracker(1)
+	ctx := context.Background()
+
+	// Host and server both have request 1 outstanding
+	if err := tr.begin(ctx, hostKey("1"), trackedRequest{sent: time.Now()}, false); err != nil {
+		t.Fatalf("host begin: %v", err)
+	}
+	if err := tr.begin(ctx, serverKey("1"), trackedRequest{sent: time.Now()}, false); err != nil {
+		t.Fatalf("server-originated requests must not count against the limit: %v", err)
+	}
+	if tr.inflight() != 2 {
+		t.Fatalf("inflight = %d, want 2", tr.inflight())
+	}
+
+	// The host answers the server's request — the host's own request stays open
+	if _, ok := tr.finish(serverKey("1")); !ok {
+		t.Fatal("expected server request 1 to be outstanding")
+	}
+	if err := tr.begin(ctx, hostKey("2"), trackedRequest{sent: time.Now()}, false); err != errBusy {
+		t.Fatalf("host slot released by the wrong response: begin = %v", err)
+	}
+	if _, ok := tr.finish(hostKey("1")); !ok {
+		t.Fatal("expected host request 1 to be outstanding")
+	}
+}
+
+func TestCorrelationKeys(t *testing.T) {
+	req := &InterceptedMessage{Direction: DirServerToHost, Parsed: JSONRPCMessage{ID: []byte("7"), Method: "roots/list"}}
+	resp := &InterceptedMessage{Direction: DirHostToServer, Parsed: JSONRPCMessage{ID: []byte("7"), Result: []byte("{}")}}
+	if requestKey(req) != responseKey(resp) {
+		t.Fatalf("host response %v does not match server request %v", responseKey(resp), requestKey(req))
+	}
+	if requestKey(req) == hostKey("7") {
+		t.Fatal("server request collides with host request of the same ID")
+	}
+}
+
+func TestProxy_ServerRequestDoesNotReleaseHostSlot(t *testing.T) {
+	p := NewProxy(Config{Command: "test", MaxInflight: 1, RejectWhenBusy: true}, NewInterceptorChain(), testLogger())
+	down := &syncBuffer{}
+	hostOut := &syncBuffer{}
+	p.downStdin = down
+	p.hostOut = hostOut
+	ctx := context.Background()
+
+	// Host request 1 takes the only slot
+	p.pipeMessages(ctx, strings.NewReader(toolsCallLine(1)), down, DirHostToServer)
+
+	// Server asks the host for roots with the same ID, and the host answers
+	p.pipeMessages(ctx, strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"roots/list"}`+"\n"), hostOut, DirServerToHost)
+	p.pipeMessages(ctx, strings.NewReader(`{"jsonrpc":"2.0","id":1,"result":{"roots":[]}}`+"\n"), down, DirHostToServer)
+
+	if n := down.lineCount(); n != 2 {
+		t.Fatalf("expected host request and roots response forwarded, got %d lines", n)
+	}
+
+	// The host's request 1 is still outstanding, so request 2 is rejected
+	p.pipeMessages(ctx, strings.NewReader(toolsCallLine(2)), down, DirHostToServer)
+	if !strings.Contains(hostOut.String(), "server busy") {
+		t.Fatalf("host response to server request released the host's slot; host saw: %s", hostOut.String())
+	}
+
+	// The server's answer to request 1 frees it
+	p.pipeMessages(ctx, strings.NewReader(`{"jsonrpc":"2.0","id":1,"result":{}}`+"\n"), hostOut, DirServerToHost)
+	if p.tracker.inflight() != 0 {
+		t.Fatalf("inflight = %d, want 0", p.tracker.inflight())
+	}
+}
+
+func TestProxy_DuplicateOutstandingIDForwardedByDefault(t *testing.T) {
+	p := NewProxy(Config{SessionID: "test"}, NewInterceptorChain(), testLogger())
+	down := &syncBuffer{}
+	hostOut := &syncBuffer{}
+	p.downStdin = down
+	p.hostOut = hostOut
+
+	if err := p.pipeMessages(context.Background(), strings.NewReader(toolsCallLine(1)+toolsCallLine(1)), down, DirHostToServer); err != nil {
+		t.Fatalf("pipe: %v", err)
+	}
+	if n := down.lineCount(); n != 2 {
+		t.Fatalf("forwarded %d requests, want both", n)
+	}
+	if hostOut.String() != "" {
+		t.Fatalf("unexpected error to host: %s", hostOut.String())
+	}
+}
+
+func TestProxy_RejectDuplicateOutstandingID(t *testing.T) {
+	chain := NewInterceptorChain()
+	var blocked []string
+	chain.OnBlock = func(_ context.Context, msg *InterceptedMessage, err error) {
+		blocked = append(blocked, string(msg.Parsed.ID)+": "+err.Error())
+	}
+	p := NewProxy(Config{SessionID: "test", RejectDuplicateIDs: true}, chain, testLogger())
+	down := &syncBuffer{}
+	hostOut := &syncBuffer{}
+	p.downStdin = down
+	p.hostOut = hostOut
+	ctx := context.Background()
+
+	// 1 is outstanding when its duplicate arrives; 2 is a different id
+	input := toolsCallLine(1) + toolsCallLine(1) + toolsCallLine(2)
+	if err := p.pipeMessages(ctx, strings.NewReader(input), down, DirHostToServer); err != nil {
+		t.Fatalf("pipe: %v", err)
+	}
+	if n := down.lineCount(); n != 2 {
+		t.Fatalf("forwarded %d requests, want 2", n)
+	}
+	if !strings.Contains(hostOut.String(), `"id":1`) || !strings.Contains(hostOut.String(), "duplicate request id 1") {
+		t.Fatalf("expected duplicate id error for request 1, got: %s", hostOut.String())
+	}
+	if len(blocked) != 1 {
+		t.Fatalf("OnBlock called %d times, want 1: %v", len(blocked), blocked)
+	}
+
+	// Once answered, the id may be used again
+	p.pipeMessages(ctx, strings.NewReader(`{"jsonrpc":"2.0","id":1,"result":{}}`+"\n"), io.Discard, DirServerToHost)
+	p.pipeMessages(ctx, strings.NewReader(toolsCallLine(1)), down, DirHostToServer)
+	if n := down.lineCount(); n != 3 {
+		t.Fatalf("reused id after response not forwarded: %d lines", n)
+	}
+}
+
+func TestRequestTracker_ExpireAndLate(t *testing.T) {
+	tr := newRequestTracker(1)
+	ctx := context.Background()
+	start := time.Now()
+
+	tr.begin(ctx, hostKey("1"), trackedRequest{sent: start}, false)
+	tr.begin(ctx, serverKey("9"), trackedRequest{sent: start}, false)
+	if keys := tr.expire(DirHostToServer, start); len(keys) != 0 {
+		t.Fatalf("expired %v before the cutoff", keys)
+	}
+	keys := tr.expire(DirHostToServer, start.Add(time.Second))
+	if len(keys) != 1 || keys[0] != hostKey("1") {
+		t.Fatalf("expired %v, want host request 1", keys)
+	}
+	if !tr.outstanding(serverKey("9")) {
+		t.Error("server request expired with the host's")
+	}
+	// The slot is free again
+	if err := tr.begin(ctx, hostKey("2"), trackedRequest{sent: start}, false); err != nil {
+		t.Fatalf("begin after expiry: %v", err)
+	}
+
+	if !tr.late(hostKey("1")) {
+		t.Error("expected the response to request 1 to be late")
+	}
+	if tr.late(hostKey("1")) {
+		t.Error("a second response to request 1 is not late")
+	}
+
+	// A reused ID starts over
+	tr.expire(DirHostToServer, start.Add(time.Second))
+	tr.begin(ctx, hostKey("2"), trackedRequest{sent: start.Add(2 * time.Second)}, false)
+	if tr.late(hostKey("2")) {
+		t.Error("response to the reused id treated as late")
+	}
+}
+
+func TestCallCorrelator(t *testing.T) {
+	c := newCallCorrelator[string]()
+	start := time.Now()
+	msg := func(session string, dir Direction, raw string, at time.Time) *InterceptedMessage {
+		m := &InterceptedMessage{SessionID: session, Direction: dir, RawBytes: []byte(raw), Timestamp: at}
+		m.Parsed, _ = ParseMessage(m.RawBytes)
+		return m
+	}
+
+	c.remember(msg("s1", DirHostToServer, `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`, start), "first")
+	c.remember(msg("s2", DirHostToServer, `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`, start), "other session")
+
+	// A request with the same ID from the other side is not answered
+	if _, ok := c.answer(msg("s1", DirHostToServer, `{"jsonrpc":"2.0","id":1,"result":{}}`, start)); ok {
+		t.Error("host response answered a host request")
+	}
+	if got, ok := c.answer(msg("s1", DirServerToHost, `{"jsonrpc":"2.0","id":1,"result":{}}`, start)); !ok || got != "first" {
+		t.Errorf("answer = %q, %v; want first", got, ok)
+	}
+	if _, ok := c.answer(msg("s1", DirServerToHost, `{"jsonrpc":"2.0","id":1,"result":{}}`, start)); ok {
+		t.Error("request answered twice")
+	}
+
+	// Remembering a later request forgets ones past their TTL
+	c.remember(msg("s1", DirHostToServer, `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`, start.Add(pendingTTL+time.Second)), "later")
+	if c.has(hostKey("1")) || !c.has(hostKey("2")) {
+		t.Error("stale request kept or new request missing")
+	}
+	c.expire(start.Add(2*pendingTTL + 2*time.Second))
+	if c.has(hostKey("2")) {
+		t.Error("request kept after expire")
+	}
+}
@@ -0,0 +1,145 @@
+package proxy
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/contextgate/contextgate/internal/policy"
+)
+
+// EchoGuardRuleName is the rule name recorded when the echo guard acts on
+// a message.
+const EchoGuardRuleName = "echo_guard"
+
+// maxEchoSecrets bounds the secrets remembered per session; the oldest
+// are forgotten first.
+const maxEchoSecrets = 1024
+
+// EchoGuardInterceptor enforces a policy EchoGuard. It remembers hashes of
+// the secrets found in each session's server→host messages and denies,
+// holds for approval or audits host→server messages that contain one of
+// them again. Only hashes are kept, never the secrets themselves.
+//
+// It must run before scrub, which would otherwise have redacted the
+// secrets before they could be remembered.
+type EchoGuardInterceptor struct {
+	guard    policy.EchoGuard
+	scrubber *ScrubberInterceptor
+	patterns map[string]bool // nil selects the patterns labelled api_key
+
+	mu   sync.Mutex
+	seen map[string]map[[sha256.Size]byte]time.Time // session ID → secret hash → last received
+}
+
+// NewEchoGuardInterceptor creates an interceptor for guard, as loaded from
+// a policy file, finding secrets with the scrubber's patterns. It fails if
+// guard names a pattern the scrubber doesn't have.
+func NewEchoGuardInterceptor(guard policy.EchoGuard, scrubber *ScrubberInterceptor) (*EchoGuardInterceptor, error) {
+	e := &EchoGuardInterceptor{
+		guard:    guard,
+		scrubber: scrubber,
+		seen:     make(map[string]map[[sha256.Size]byte]time.Time),
+	}
+	if len(guard.Patterns) > 0 {
+		e.patterns = make(map[string]bool, len(guard.Patterns))
+		for _, name := range guard.Patterns {
+			if !scrubber.hasPattern(name) {
+				return nil, fmt.Errorf("echo_guard: unknown scrubber pattern %q", name)
+			}
+			e.patterns[name] = true
+		}
+	}
+	return e, nil
+}
+
+func (e *EchoGuardInterceptor) selects(name, label string) bool {
+	if e.patterns == nil {
+		return label == "api_key"
+	}
+	return e.patterns[name]
+}
+
+func (e *EchoGuardInterceptor) Intercept(_ context.Context, msg *InterceptedMessage) ([]byte, error) {
+	if msg.ParseErr != nil {
+		return msg.RawBytes, nil
+	}
+	matches := e.scrubber.findMatches(msg.RawBytes, e.selects)
+	if len(matches) == 0 {
+		return msg.RawBytes, nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if msg.Direction == DirServerToHost {
+		for _, m := range matches {
+			e.remember(msg.SessionID, sha256.Sum256([]byte(m.Value)), msg.Timestamp)
+		}
+		return msg.RawBytes, nil
+	}
+
+	seen := e.seen[msg.SessionID]
+	var echoed string
+	for _, m := range matches {
+		at, ok := seen[sha256.Sum256([]byte(m.Value))]
+		if ok && (e.guard.TTL == 0 || msg.Timestamp.Sub(at) < e.guard.TTL) {
+			echoed = m.Pattern
+			break
+		}
+	}
+	if echoed == "" {
+		return msg.RawBytes, nil
+	}
+
+	if msg.Metadata == nil {
+		msg.Metadata = make(map[string]any)
+	}
+	switch e.guard.Action {
+	case policy.ActionAudit:
+		msg.Metadata[MetaKeyPolicyAction] = string(policy.ActionAudit)
+		msg.Metadata[MetaKeyPolicyRule] = EchoGuardRuleName
+		msg.Metadata[MetaKeyAudit] = true
+	case policy.ActionRequireApproval:
+		requireApproval(msg, EchoGuardRuleName, e.guard.Message)
+	default:
+		msg.Metadata[MetaKeyPolicyAction] = string(policy.ActionDeny)
+		msg.Metadata[MetaKeyPolicyRule] = EchoGuardRuleName
+		if e.guard.Message != "" {
+			return nil, errors.New(e.guard.Message)
+		}
+		return nil, fmt.Errorf("blocked: the message repeats a secret (%s) the server sent earlier in this session", echoed)
+	}
+	return msg.RawBytes, nil
+}
+
+// remember records that a session received the secret with hash h,
+// forgetting the oldest secret once the session holds maxEchoSecrets.
+// Must be called with mu held.
+func (e *EchoGuardInterceptor) remember(sessionID string, h [sha256.Size]byte, at time.Time) {
+	seen := e.seen[sessionID]
+	if seen == nil {
+		seen = make(map[[sha256.Size]byte]time.Time)
+		e.seen[sessionID] = seen
+	}
+	if _, ok := seen[h]; !ok && len(seen) >= maxEchoSecrets {
+		var oldest [sha256.Size]byte
+		var oldestAt time.Time
+		for k, t := range seen {
+			if oldestAt.IsZero() || t.Before(oldestAt) {
+				oldest, oldestAt = k, t
+			}
+		}
+		delete(seen, oldest)
+	}
+	seen[h] = at
+}
+
+// Remembered returns how many secrets are remembered for a session.
+func (e *EchoGuardInterceptor) Remembered(sessionID string) int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.seen[sessionID])
+}
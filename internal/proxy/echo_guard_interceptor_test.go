@@ -0,0 +1,140 @@
+package proxy
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/contextgate/contextgate/internal/policy"
+)
+
+const echoSecret = "sk-abcdefghijklmnopqrstuvwxyz123456"
+
+func echoMsg(t *testing.T, sessionID string, dir Direction, raw string, at time.Time) *InterceptedMessage {
+	t.Helper()
+	msg := parsedMessage(t, dir, raw)
+	msg.SessionID = sessionID
+	msg.Timestamp = at
+	return msg
+}
+
+// readSecret delivers a tools/call result carrying echoSecret to a session.
+func readSecret(t *testing.T, process func(context.Context, *InterceptedMessage) ([]byte, error), sessionID string, at time.Time) []byte {
+	t.Helper()
+	out, err := process(context.Background(), echoMsg(t, sessionID, DirServerToHost,
+		`{"jsonrpc":"2.0","id":1,"result":{"content":[{"type":"text","text":"OPENAI_API_KEY=`+echoSecret+`"}]}}`, at))
+	if err != nil {
+		t.Fatalf("result blocked: %v", err)
+	}
+	return out
+}
+
+func sendBack(t *testing.T, sessionID string, at time.Time) *InterceptedMessage {
+	t.Helper()
+	return echoMsg(t, sessionID, DirHostToServer,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"http_post","arguments":{"body":"key: `+echoSecret+`"}}}`, at)
+}
+
+func newTestEchoGuard(t *testing.T, guard policy.EchoGuard) *EchoGuardInterceptor {
+	t.Helper()
+	scrubber, err := NewScrubberInterceptor(false, nil) // scrubbing off
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := NewEchoGuardInterceptor(guard, scrubber)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestEchoGuard_BlocksSecretSentBack(t *testing.T) {
+	e := newTestEchoGuard(t, policy.EchoGuard{})
+	ctx := context.Background()
+	now := time.Now()
+
+	// A secret the server never sent goes through
+	if _, err := e.Intercept(ctx, sendBack(t, "s1", now)); err != nil {
+		t.Fatalf("unseen secret blocked: %v", err)
+	}
+
+	readSecret(t, e.Intercept, "s1", now)
+	if got := e.Remembered("s1"); got != 1 {
+		t.Fatalf("remembered %d secrets, want 1", got)
+	}
+	call := sendBack(t, "s1", now.Add(time.Second))
+	if _, err := e.Intercept(ctx, call); err == nil || !strings.Contains(err.Error(), "openai_key") {
+		t.Fatalf("err = %v, want the echoed secret blocked", err)
+	}
+	if call.Metadata[MetaKeyPolicyRule] != EchoGuardRuleName || call.Metadata[MetaKeyPolicyAction] != string(policy.ActionDeny) {
+		t.Errorf("metadata = %v", call.Metadata)
+	}
+
+	// Other sessions never received it
+	if _, err := e.Intercept(ctx, sendBack(t, "s2", now)); err != nil {
+		t.Errorf("other session blocked: %v", err)
+	}
+}
+
+func TestEchoGuard_RemembersBeforeScrub(t *testing.T) {
+	scrubber, err := NewScrubberInterceptor(true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := NewEchoGuardInterceptor(policy.EchoGuard{Message: "not sending that back"}, scrubber)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain := NewInterceptorChain(e, OnlyDirection(DirServerToHost, scrubber))
+	now := time.Now()
+
+	out := readSecret(t, chain.Process, "s1", now)
+	if strings.Contains(string(out), echoSecret) {
+		t.Fatalf("secret reached the host unscrubbed: %s", out)
+	}
+	if _, err := chain.Process(context.Background(), sendBack(t, "s1", now)); err == nil || err.Error() != "not sending that back" {
+		t.Errorf("err = %v, want the custom message", err)
+	}
+}
+
+func TestEchoGuard_ApprovalAuditAndTTL(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	e := newTestEchoGuard(t, policy.EchoGuard{Action: policy.ActionRequireApproval, TTL: time.Minute})
+	readSecret(t, e.Intercept, "s1", now)
+	call := sendBack(t, "s1", now.Add(30*time.Second))
+	if _, err := e.Intercept(ctx, call); err != nil {
+		t.Fatalf("call blocked, want it held for approval: %v", err)
+	}
+	if call.Metadata[MetaKeyPolicyAction] != string(policy.ActionRequireApproval) || call.Metadata[MetaKeyPolicyRule] != EchoGuardRuleName {
+		t.Errorf("metadata = %v", call.Metadata)
+	}
+	// Forgotten once the TTL has passed
+	late := sendBack(t, "s1", now.Add(2*time.Minute))
+	if _, err := e.Intercept(ctx, late); err != nil || late.Metadata != nil {
+		t.Errorf("expired secret still flagged: err = %v, metadata = %v", err, late.Metadata)
+	}
+
+	e = newTestEchoGuard(t, policy.EchoGuard{Action: policy.ActionAudit})
+	readSecret(t, e.Intercept, "s1", now)
+	call = sendBack(t, "s1", now)
+	if _, err := e.Intercept(ctx, call); err != nil || call.Metadata[MetaKeyAudit] != true {
+		t.Errorf("err = %v, metadata = %v; want an audit", err, call.Metadata)
+	}
+}
+
+func TestEchoGuard_Patterns(t *testing.T) {
+	e := newTestEchoGuard(t, policy.EchoGuard{Patterns: []string{"email"}})
+	now := time.Now()
+	readSecret(t, e.Intercept, "s1", now)
+	if _, err := e.Intercept(context.Background(), sendBack(t, "s1", now)); err != nil {
+		t.Errorf("unselected pattern blocked: %v", err)
+	}
+
+	scrubber, _ := NewScrubberInterceptor(false, nil)
+	if _, err := NewEchoGuardInterceptor(policy.EchoGuard{Patterns: []string{"no_such_pattern"}}, scrubber); err == nil {
+		t.Error("expected an unknown pattern to be rejected")
+	}
+}
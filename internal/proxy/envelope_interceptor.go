@@ -0,0 +1,116 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// MetaKeyEnvelopeErrors lists the envelope violations found in a message.
+const MetaKeyEnvelopeErrors = "envelope_errors"
+
+// EnvelopeMode is what the envelope interceptor does with a violation.
+type EnvelopeMode string
+
+const (
+	// EnvelopeLog forwards the message unchanged and flags it for audit.
+	EnvelopeLog EnvelopeMode = "log"
+	// EnvelopeFix adds a missing jsonrpc version and flags what it can't
+	// fix, as EnvelopeLog does.
+	EnvelopeFix EnvelopeMode = "fix"
+	// EnvelopeBlock blocks the message.
+	EnvelopeBlock EnvelopeMode = "block"
+)
+
+// EnvelopeInterceptor checks the JSON-RPC 2.0 envelope beyond what parsing
+// needs: the jsonrpc member must be "2.0", and request IDs must be a
+// string or an integer. Responses may also carry a null ID, but only on
+// an error. Strict clients reject messages that break these rules, so
+// fixing or flagging them at the proxy keeps sloppy servers usable.
+type EnvelopeInterceptor struct {
+	mode EnvelopeMode
+}
+
+// NewEnvelopeInterceptor creates an envelope checker in the given mode.
+func NewEnvelopeInterceptor(mode EnvelopeMode) *EnvelopeInterceptor {
+	return &EnvelopeInterceptor{mode: mode}
+}
+
+func (e *EnvelopeInterceptor) Intercept(_ context.Context, msg *InterceptedMessage) ([]byte, error) {
+	if msg.ParseErr != nil {
+		return msg.RawBytes, nil
+	}
+
+	raw := msg.RawBytes
+	var violations []string
+	switch msg.Parsed.JSONRPC {
+	case "2.0":
+	case "":
+		if fixed, ok := addVersion(raw); e.mode == EnvelopeFix && ok {
+			raw = fixed
+			msg.RawBytes = fixed
+			msg.Parsed.JSONRPC = "2.0"
+		} else {
+			violations = append(violations, `missing jsonrpc version "2.0"`)
+		}
+	default:
+		violations = append(violations, fmt.Sprintf(`jsonrpc version %q, want "2.0"`, msg.Parsed.JSONRPC))
+	}
+	if v := idViolation(msg.Parsed); v != "" {
+		violations = append(violations, v)
+	}
+
+	if len(violations) == 0 {
+		return raw, nil
+	}
+	if e.mode == EnvelopeBlock {
+		return nil, fmt.Errorf("invalid JSON-RPC envelope: %s", strings.Join(violations, "; "))
+	}
+	if msg.Metadata == nil {
+		msg.Metadata = make(map[string]any)
+	}
+	msg.Metadata[MetaKeyEnvelopeErrors] = violations
+	msg.Metadata[MetaKeyAudit] = true
+	return raw, nil
+}
+
+// idViolation describes what is wrong with m's ID, or returns "".
+func idViolation(m JSONRPCMessage) string {
+	id := bytes.TrimSpace(m.ID)
+	if len(id) == 0 {
+		return "" // a notification
+	}
+	switch c := id[0]; {
+	case c == '"':
+		return ""
+	case c == '-' || (c >= '0' && c <= '9'):
+		if bytes.ContainsAny(id, ".eE") {
+			return fmt.Sprintf("id %s is not an integer", id)
+		}
+		return ""
+	case string(id) == "null":
+		if m.Method == "" && m.Error != nil {
+			return "" // an error answering a message whose id couldn't be read
+		}
+		return "id is null"
+	}
+	return fmt.Sprintf("id %s is not a string or integer", id)
+}
+
+// addVersion inserts "jsonrpc":"2.0" as the first member of the object in
+// raw, leaving the rest of the bytes as they are.
+func addVersion(raw []byte) ([]byte, bool) {
+	body := bytes.TrimLeft(raw, " \t")
+	if len(body) == 0 || body[0] != '{' {
+		return nil, false
+	}
+	rest := body[1:]
+	sep := ","
+	if trimmed := bytes.TrimLeft(rest, " \t"); len(trimmed) > 0 && trimmed[0] == '}' {
+		sep = ""
+	}
+	fixed := make([]byte, 0, len(body)+len(`"jsonrpc":"2.0",`))
+	fixed = append(fixed, `{"jsonrpc":"2.0"`+sep...)
+	return append(fixed, rest...), true
+}
@@ -0,0 +1,90 @@
+package proxy
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func envelopeMsg(raw string) *InterceptedMessage {
+	msg := &InterceptedMessage{Direction: DirServerToHost, RawBytes: []byte(raw)}
+	msg.Parsed, msg.ParseErr = ParseMessage(msg.RawBytes)
+	return msg
+}
+
+func TestEnvelopeInterceptor_MissingVersion(t *testing.T) {
+	raw := `{"id":1,"result":{}}`
+	tests := []struct {
+		mode    EnvelopeMode
+		want    string
+		wantErr bool
+		flagged bool
+	}{
+		{EnvelopeLog, raw, false, true},
+		{EnvelopeFix, `{"jsonrpc":"2.0","id":1,"result":{}}`, false, false},
+		{EnvelopeBlock, "", true, false},
+	}
+	for _, tt := range tests {
+		msg := envelopeMsg(raw)
+		out, err := NewEnvelopeInterceptor(tt.mode).Intercept(context.Background(), msg)
+		if tt.wantErr {
+			if err == nil || !strings.Contains(err.Error(), "missing jsonrpc version") {
+				t.Errorf("mode %s: err = %v, want a missing-version error", tt.mode, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("mode %s: unexpected error: %v", tt.mode, err)
+		}
+		if string(out) != tt.want {
+			t.Errorf("mode %s: forwarded %s, want %s", tt.mode, out, tt.want)
+		}
+		if _, flagged := msg.Metadata[MetaKeyEnvelopeErrors]; flagged != tt.flagged {
+			t.Errorf("mode %s: flagged = %v, want %v", tt.mode, flagged, tt.flagged)
+		}
+	}
+
+	if out, _ := NewEnvelopeInterceptor(EnvelopeFix).Intercept(context.Background(), envelopeMsg(`{}`)); string(out) != `{"jsonrpc":"2.0"}` {
+		t.Errorf("fixed empty object = %s", out)
+	}
+}
+
+func TestEnvelopeInterceptor_IDType(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want []string
+	}{
+		{`{"jsonrpc":"2.0","id":"a-1","method":"ping"}`, nil},
+		{`{"jsonrpc":"2.0","id":-3,"method":"ping"}`, nil},
+		{`{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"parse error"}}`, nil},
+		{`{"jsonrpc":"2.0","method":"notifications/progress"}`, nil},
+		{`{"jsonrpc":"2.0","id":1.5,"result":{}}`, []string{"id 1.5 is not an integer"}},
+		{`{"jsonrpc":"2.0","id":{"n":1},"method":"ping"}`, []string{`id {"n":1} is not a string or integer`}},
+		{`{"jsonrpc":"2.0","id":true,"method":"ping"}`, []string{"id true is not a string or integer"}},
+		{`{"jsonrpc":"2.0","id":null,"method":"ping"}`, []string{"id is null"}},
+		{`{"jsonrpc":"1.0","id":[1],"result":{}}`, []string{`jsonrpc version "1.0", want "2.0"`, "id [1] is not a string or integer"}},
+	}
+	for _, tt := range tests {
+		msg := envelopeMsg(tt.raw)
+		out, err := NewEnvelopeInterceptor(EnvelopeFix).Intercept(context.Background(), msg)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.raw, err)
+		}
+		if string(out) != tt.raw {
+			t.Errorf("%s: forwarded %s", tt.raw, out)
+		}
+		got, _ := msg.Metadata[MetaKeyEnvelopeErrors].([]string)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: violations = %q, want %q", tt.raw, got, tt.want)
+		}
+		if audit, _ := msg.Metadata[MetaKeyAudit].(bool); audit != (tt.want != nil) {
+			t.Errorf("%s: audit = %v", tt.raw, audit)
+		}
+	}
+
+	_, err := NewEnvelopeInterceptor(EnvelopeBlock).Intercept(context.Background(), envelopeMsg(`{"jsonrpc":"2.0","id":true,"method":"ping"}`))
+	if err == nil || !strings.Contains(err.Error(), "not a string or integer") {
+		t.Errorf("block mode err = %v, want an id type error", err)
+	}
+}
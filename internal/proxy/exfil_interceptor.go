@@ -0,0 +1,137 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/contextgate/contextgate/internal/policy"
+)
+
+// ExfilRuleName is the rule name recorded when the exfil limit denies a
+// call or holds it for approval.
+const ExfilRuleName = "exfil_limit"
+
+// ExfilInterceptor enforces a policy ExfilLimit. It counts the bytes of
+// tools/call results each session receives and, once a session has read
+// MaxBytes within the window, denies further calls to the limited tools
+// or marks them for approval. The result that crosses the threshold is
+// still delivered; enforcement applies to the calls after it.
+type ExfilInterceptor struct {
+	limit policy.ExfilLimit
+
+	pending *callCorrelator[struct{}] // counted calls awaiting a result
+
+	mu       sync.Mutex
+	received map[string][]exfilSample // session ID → results, oldest first
+}
+
+type exfilSample struct {
+	at    time.Time
+	bytes int64
+}
+
+// NewExfilInterceptor creates an interceptor for limit, as loaded from a
+// policy file.
+func NewExfilInterceptor(limit policy.ExfilLimit) *ExfilInterceptor {
+	return &ExfilInterceptor{
+		limit:    limit,
+		pending:  newCallCorrelator[struct{}](),
+		received: make(map[string][]exfilSample),
+	}
+}
+
+func (e *ExfilInterceptor) limits(tool string) bool {
+	if len(e.limit.Tools) == 0 {
+		return true
+	}
+	for _, t := range e.limit.Tools {
+		if t == tool {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *ExfilInterceptor) Intercept(_ context.Context, msg *InterceptedMessage) ([]byte, error) {
+	if msg.ParseErr != nil {
+		return msg.RawBytes, nil
+	}
+
+	if msg.Direction == DirServerToHost {
+		if msg.Parsed.Kind() != KindResponse {
+			return msg.RawBytes, nil
+		}
+		if _, ok := e.pending.answer(msg); ok {
+			e.mu.Lock()
+			defer e.mu.Unlock()
+			e.record(msg.SessionID, exfilSample{at: msg.Timestamp, bytes: int64(len(msg.RawBytes))})
+		}
+		return msg.RawBytes, nil
+	}
+
+	if msg.Parsed.Method != "tools/call" || msg.Parsed.ID == nil || !e.limits(extractToolNameFromParams(msg.Parsed.Params)) {
+		return msg.RawBytes, nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if used := e.usedLocked(msg.SessionID, msg.Timestamp); used >= e.limit.MaxBytes {
+		if msg.Metadata == nil {
+			msg.Metadata = make(map[string]any)
+		}
+		if e.limit.Action != policy.ActionRequireApproval {
+			msg.Metadata[MetaKeyPolicyAction] = string(policy.ActionDeny)
+			msg.Metadata[MetaKeyPolicyRule] = ExfilRuleName
+			if e.limit.Message != "" {
+				return nil, errors.New(e.limit.Message)
+			}
+			return nil, fmt.Errorf("read limit reached: this session has received %d bytes of tool results (limit %d)", used, e.limit.MaxBytes)
+		}
+		requireApproval(msg, ExfilRuleName, e.limit.Message)
+	}
+	e.pending.remember(msg, struct{}{})
+	return msg.RawBytes, nil
+}
+
+// record adds a result to a session's count. Without a window only the
+// running total matters, so it is kept as a single sample. Must be called
+// with mu held.
+func (e *ExfilInterceptor) record(sessionID string, s exfilSample) {
+	samples := e.received[sessionID]
+	if e.limit.Window == 0 && len(samples) > 0 {
+		samples[0].bytes += s.bytes
+		return
+	}
+	e.received[sessionID] = append(samples, s)
+}
+
+// usedLocked sums a session's results inside the window ending at now,
+// discarding older ones. Must be called with mu held.
+func (e *ExfilInterceptor) usedLocked(sessionID string, now time.Time) int64 {
+	samples := e.received[sessionID]
+	if e.limit.Window > 0 {
+		cutoff := now.Add(-e.limit.Window)
+		i := 0
+		for i < len(samples) && !samples[i].at.After(cutoff) {
+			i++
+		}
+		samples = samples[i:]
+		e.received[sessionID] = samples
+	}
+	var total int64
+	for _, s := range samples {
+		total += s.bytes
+	}
+	return total
+}
+
+// Received returns the bytes of tool results counted against a session
+// in the current window.
+func (e *ExfilInterceptor) Received(sessionID string) int64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.usedLocked(sessionID, time.Now())
+}
@@ -0,0 +1,125 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/contextgate/contextgate/internal/policy"
+)
+
+func exfilCall(sessionID string, id int, tool string, at time.Time) *InterceptedMessage {
+	raw := []byte(fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":"tools/call","params":{"name":%q}}`, id, tool))
+	parsed, _ := ParseMessage(raw)
+	return &InterceptedMessage{Timestamp: at, SessionID: sessionID, Direction: DirHostToServer, RawBytes: raw, Parsed: parsed}
+}
+
+// exfilResult returns a response to call id that is exactly size bytes long.
+func exfilResult(sessionID string, id, size int, at time.Time) *InterceptedMessage {
+	head := fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"result":{"text":"`, id)
+	raw := []byte(head + strings.Repeat("x", size-len(head)-3) + `"}}`)
+	parsed, _ := ParseMessage(raw)
+	return &InterceptedMessage{Timestamp: at, SessionID: sessionID, Direction: DirServerToHost, RawBytes: raw, Parsed: parsed}
+}
+
+// exfilRead makes a call and, if it is let through, answers it with size bytes.
+func exfilRead(t *testing.T, e *ExfilInterceptor, sessionID string, id int, tool string, size int, at time.Time) (*InterceptedMessage, error) {
+	t.Helper()
+	call := exfilCall(sessionID, id, tool, at)
+	if _, err := e.Intercept(context.Background(), call); err != nil {
+		return call, err
+	}
+	if _, err := e.Intercept(context.Background(), exfilResult(sessionID, id, size, at)); err != nil {
+		t.Fatalf("result %d blocked: %v", id, err)
+	}
+	return call, nil
+}
+
+func TestExfil_DeniesPastThreshold(t *testing.T) {
+	e := NewExfilInterceptor(policy.ExfilLimit{MaxBytes: 1000})
+	now := time.Now()
+
+	for i := 1; i <= 4; i++ {
+		if _, err := exfilRead(t, e, "s1", i, "read_file", 300, now); err != nil {
+			t.Fatalf("read %d: unexpected error: %v", i, err)
+		}
+	}
+	if got := e.Received("s1"); got != 1200 {
+		t.Fatalf("received = %d, want 1200", got)
+	}
+
+	call, err := exfilRead(t, e, "s1", 5, "read_file", 300, now)
+	if err == nil || !strings.Contains(err.Error(), "read limit reached") {
+		t.Fatalf("read 5: err = %v, want read limit error", err)
+	}
+	if call.Metadata[MetaKeyPolicyRule] != ExfilRuleName {
+		t.Errorf("rule = %v, want %s", call.Metadata[MetaKeyPolicyRule], ExfilRuleName)
+	}
+
+	// Other sessions have their own count
+	if _, err := exfilRead(t, e, "s2", 1, "read_file", 300, now); err != nil {
+		t.Errorf("other session blocked: %v", err)
+	}
+}
+
+func TestExfil_RequireApproval(t *testing.T) {
+	e := NewExfilInterceptor(policy.ExfilLimit{
+		MaxBytes: 500,
+		Action:   policy.ActionRequireApproval,
+		Message:  "too much reading",
+	})
+	now := time.Now()
+
+	exfilRead(t, e, "s1", 1, "read_file", 600, now)
+	call, err := exfilRead(t, e, "s1", 2, "read_file", 100, now)
+	if err != nil {
+		t.Fatalf("call blocked, want it held for approval: %v", err)
+	}
+	if call.Metadata[MetaKeyPolicyAction] != string(policy.ActionRequireApproval) ||
+		call.Metadata[MetaKeyPolicyRule] != ExfilRuleName ||
+		call.Metadata[MetaKeyPolicyMsg] != "too much reading" {
+		t.Errorf("metadata = %v", call.Metadata)
+	}
+	// Approved calls keep counting
+	if got := e.Received("s1"); got != 700 {
+		t.Errorf("received = %d, want 700", got)
+	}
+}
+
+func TestExfil_WindowAndTools(t *testing.T) {
+	e := NewExfilInterceptor(policy.ExfilLimit{
+		MaxBytes: 1000,
+		Window:   time.Minute,
+		Tools:    []string{"read_file"},
+	})
+	start := time.Now().Add(-time.Hour)
+
+	exfilRead(t, e, "s1", 1, "read_file", 1000, start)
+	if _, err := exfilRead(t, e, "s1", 2, "read_file", 100, start.Add(30*time.Second)); err == nil {
+		t.Fatal("read inside the window allowed")
+	}
+	// Unlimited tools are neither counted nor blocked
+	if _, err := exfilRead(t, e, "s1", 3, "list_dir", 5000, start.Add(30*time.Second)); err != nil {
+		t.Errorf("unlimited tool blocked: %v", err)
+	}
+	// Once the window has passed the earlier read no longer counts
+	if _, err := exfilRead(t, e, "s1", 4, "read_file", 100, start.Add(2*time.Minute)); err != nil {
+		t.Errorf("read after the window blocked: %v", err)
+	}
+}
+
+func TestExfil_UnansweredAndUnrelatedResponses(t *testing.T) {
+	e := NewExfilInterceptor(policy.ExfilLimit{MaxBytes: 100})
+	now := time.Now()
+
+	// A response to a request the interceptor didn't see isn't counted
+	e.Intercept(context.Background(), exfilResult("s1", 99, 5000, now))
+	if got := e.Received("s1"); got != 0 {
+		t.Errorf("received = %d, want 0", got)
+	}
+	if _, err := exfilRead(t, e, "s1", 1, "read_file", 50, now); err != nil {
+		t.Errorf("read blocked: %v", err)
+	}
+}
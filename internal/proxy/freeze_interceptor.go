@@ -0,0 +1,178 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/contextgate/contextgate/internal/policy"
+)
+
+// FreezeRuleName is the rule name recorded for calls blocked by a freeze.
+const FreezeRuleName = "freeze"
+
+// FreezeState describes whether mutating calls are currently blocked.
+type FreezeState struct {
+	Frozen  bool                 `json:"frozen"`
+	Manual  bool                 `json:"manual"`           // switched on at runtime
+	Window  *policy.FreezeWindow `json:"window,omitempty"` // the scheduled window in effect, if any
+	Message string               `json:"message,omitempty"`
+}
+
+// FreezeInterceptor denies mutating tools/call requests during a change
+// freeze, before the policy is consulted. A freeze is on while one of the
+// configured windows is open or while it is switched on with Set. Tools
+// matching the allow list, and with TrustReadOnlyHints those the server
+// lists with readOnlyHint, are read-only and always let through.
+type FreezeInterceptor struct {
+	cfg     policy.Freeze
+	pending *callCorrelator[struct{}] // tools/list requests awaiting a response
+
+	mu       sync.Mutex
+	manual   bool
+	message  string                     // set with the manual freeze
+	readOnly map[string]map[string]bool // session ID → tools annotated readOnlyHint
+	now      func() time.Time
+}
+
+// NewFreezeInterceptor creates an interceptor for cfg, as loaded from a
+// policy file. The zero config only freezes when switched on with Set.
+func NewFreezeInterceptor(cfg policy.Freeze) *FreezeInterceptor {
+	return &FreezeInterceptor{
+		cfg:      cfg,
+		pending:  newCallCorrelator[struct{}](),
+		readOnly: make(map[string]map[string]bool),
+		now:      time.Now,
+	}
+}
+
+// Set switches the manual freeze on or off. message, if not empty,
+// replaces the configured one while it is on.
+func (f *FreezeInterceptor) Set(on bool, message string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.manual = on
+	f.message = ""
+	if on {
+		f.message = message
+	}
+}
+
+// State reports whether a freeze is in effect now.
+func (f *FreezeInterceptor) State() FreezeState {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.stateLocked(f.now())
+}
+
+func (f *FreezeInterceptor) stateLocked(now time.Time) FreezeState {
+	st := FreezeState{Manual: f.manual, Frozen: f.manual, Message: f.cfg.Message}
+	for i, w := range f.cfg.Windows {
+		if w.Contains(now) {
+			st.Frozen = true
+			st.Window = &f.cfg.Windows[i]
+			break
+		}
+	}
+	if f.manual && f.message != "" {
+		st.Message = f.message
+	}
+	return st
+}
+
+func (f *FreezeInterceptor) Intercept(_ context.Context, msg *InterceptedMessage) ([]byte, error) {
+	if msg.ParseErr != nil {
+		return msg.RawBytes, nil
+	}
+	if f.cfg.TrustReadOnlyHints {
+		f.trackHints(msg)
+	}
+	if msg.Direction != DirHostToServer || msg.Parsed.Method != "tools/call" {
+		return msg.RawBytes, nil
+	}
+
+	tool := extractToolNameFromParams(msg.Parsed.Params)
+	f.mu.Lock()
+	st := f.stateLocked(f.now())
+	readOnly := f.readOnly[msg.SessionID][tool]
+	f.mu.Unlock()
+	if !st.Frozen || readOnly || f.allows(tool) {
+		return msg.RawBytes, nil
+	}
+
+	if msg.Metadata == nil {
+		msg.Metadata = make(map[string]any)
+	}
+	msg.Metadata[MetaKeyPolicyAction] = string(policy.ActionDeny)
+	msg.Metadata[MetaKeyPolicyRule] = FreezeRuleName
+	if st.Message != "" {
+		return nil, errors.New(st.Message)
+	}
+	if st.Window != nil {
+		return nil, fmt.Errorf("change freeze in effect until %s: %q may modify state and is blocked", st.Window.End.Format(time.RFC3339), tool)
+	}
+	return nil, fmt.Errorf("change freeze in effect: %q may modify state and is blocked", tool)
+}
+
+func (f *FreezeInterceptor) allows(tool string) bool {
+	for _, pattern := range f.cfg.Allow {
+		if pattern == tool {
+			return true
+		}
+		if ok, _ := path.Match(pattern, tool); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// trackHints records which tools each session's tools/list responses
+// annotate with readOnlyHint.
+func (f *FreezeInterceptor) trackHints(msg *InterceptedMessage) {
+	if msg.Parsed.ID == nil {
+		return
+	}
+	if msg.Direction == DirHostToServer && msg.Parsed.Method == "tools/list" {
+		f.pending.remember(msg, struct{}{})
+		return
+	}
+	if msg.Direction != DirServerToHost || msg.Parsed.Kind() != KindResponse {
+		return
+	}
+	if _, found := f.pending.answer(msg); !found || msg.Parsed.Result == nil {
+		return
+	}
+
+	list, err := parseToolsList(msg.Parsed.Result)
+	if err != nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	readOnly := f.readOnly[msg.SessionID]
+	if readOnly == nil {
+		readOnly = make(map[string]bool)
+		f.readOnly[msg.SessionID] = readOnly
+	}
+	// Each listing updates the tools it includes, so later pages add to
+	// earlier ones and a tool that drops its hint loses it
+	for _, t := range list.tools {
+		if !t.ok {
+			continue
+		}
+		var hints struct {
+			Annotations struct {
+				ReadOnlyHint bool `json:"readOnlyHint"`
+			} `json:"annotations"`
+		}
+		if json.Unmarshal(t.raw, &hints) == nil && hints.Annotations.ReadOnlyHint {
+			readOnly[t.name] = true
+		} else {
+			delete(readOnly, t.name)
+		}
+	}
+}
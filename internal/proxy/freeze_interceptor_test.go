@@ -0,0 +1,107 @@
+package proxy
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/contextgate/contextgate/internal/policy"
+)
+
+func freezeCall(t *testing.T, f *FreezeInterceptor, tool string) (*InterceptedMessage, error) {
+	t.Helper()
+	msg := parsedMessage(t, DirHostToServer, `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"`+tool+`"}}`)
+	msg.SessionID = "s1"
+	_, err := f.Intercept(context.Background(), msg)
+	return msg, err
+}
+
+func TestFreeze_ManualToggle(t *testing.T) {
+	f := NewFreezeInterceptor(policy.Freeze{Allow: []string{"read_*"}})
+
+	if _, err := freezeCall(t, f, "deploy"); err != nil {
+		t.Fatalf("call blocked without a freeze: %v", err)
+	}
+
+	f.Set(true, "incident in progress")
+	if st := f.State(); !st.Frozen || !st.Manual {
+		t.Errorf("state = %+v, want a manual freeze", st)
+	}
+	msg, err := freezeCall(t, f, "deploy")
+	if err == nil || err.Error() != "incident in progress" {
+		t.Fatalf("err = %v, want the freeze message", err)
+	}
+	if msg.Metadata[MetaKeyPolicyRule] != FreezeRuleName || msg.Metadata[MetaKeyPolicyAction] != string(policy.ActionDeny) {
+		t.Errorf("metadata = %v", msg.Metadata)
+	}
+	if _, err := freezeCall(t, f, "read_file"); err != nil {
+		t.Errorf("allowed tool blocked: %v", err)
+	}
+	list := parsedMessage(t, DirHostToServer, `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`)
+	if _, err := f.Intercept(context.Background(), list); err != nil {
+		t.Errorf("tools/list blocked: %v", err)
+	}
+
+	f.Set(false, "")
+	if _, err := freezeCall(t, f, "deploy"); err != nil {
+		t.Errorf("call blocked after the freeze was lifted: %v", err)
+	}
+}
+
+func TestFreeze_ScheduledWindow(t *testing.T) {
+	start := time.Date(2026, 12, 20, 0, 0, 0, 0, time.UTC)
+	f := NewFreezeInterceptor(policy.Freeze{Windows: []policy.FreezeWindow{{Start: start, End: start.Add(48 * time.Hour)}}})
+
+	f.now = func() time.Time { return start.Add(-time.Minute) }
+	if _, err := freezeCall(t, f, "deploy"); err != nil {
+		t.Fatalf("call blocked before the window: %v", err)
+	}
+
+	f.now = func() time.Time { return start.Add(time.Hour) }
+	if _, err := freezeCall(t, f, "deploy"); err == nil || !strings.Contains(err.Error(), "until 2026-12-22T00:00:00Z") {
+		t.Errorf("err = %v, want a freeze error naming the window's end", err)
+	}
+	// Lifting the manual freeze doesn't end the window
+	f.Set(false, "")
+	if st := f.State(); !st.Frozen || st.Window == nil {
+		t.Errorf("state = %+v, want the window in effect", st)
+	}
+
+	f.now = func() time.Time { return start.Add(48 * time.Hour) }
+	if _, err := freezeCall(t, f, "deploy"); err != nil {
+		t.Errorf("call blocked after the window: %v", err)
+	}
+}
+
+func TestFreeze_ReadOnlyHints(t *testing.T) {
+	f := NewFreezeInterceptor(policy.Freeze{TrustReadOnlyHints: true})
+	f.Set(true, "")
+	ctx := context.Background()
+
+	req := makeToolsListRequest("1")
+	req.SessionID = "s1"
+	f.Intercept(ctx, req)
+	resp := makeToolsListResponse("1", `[
+		{"name":"search","annotations":{"readOnlyHint":true}},
+		{"name":"delete_file","annotations":{"destructiveHint":true}}
+	]`)
+	resp.SessionID = "s1"
+	f.Intercept(ctx, resp)
+
+	if _, err := freezeCall(t, f, "search"); err != nil {
+		t.Errorf("read-only tool blocked: %v", err)
+	}
+	if _, err := freezeCall(t, f, "delete_file"); err == nil {
+		t.Error("mutating tool allowed during a freeze")
+	}
+
+	// Hints are ignored unless trusted
+	f = NewFreezeInterceptor(policy.Freeze{})
+	f.Set(true, "")
+	f.Intercept(ctx, req)
+	f.Intercept(ctx, resp)
+	if _, err := freezeCall(t, f, "search"); err == nil {
+		t.Error("untrusted read-only hint honoured")
+	}
+}
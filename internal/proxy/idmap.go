@@ -0,0 +1,131 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultIDPrefix is the reserved prefix for proxy-originated request IDs.
+const DefaultIDPrefix = "contextgate-"
+
+// idMapper keeps proxy-originated requests in their own ID namespace so
+// they can never collide with the host's.
+//
+// Requests the proxy sends itself get string IDs carrying the reserved
+// prefix; their responses are claimed here and never reach the host.
+// A host request whose ID happens to use the prefix is rewritten to a
+// fresh reserved ID on the way out and restored on the way back.
+type idMapper struct {
+	prefix string
+
+	mu     sync.Mutex
+	next   uint64
+	owned  map[string]chan []byte     // proxy request ID → response channel
+	hostID map[string]json.RawMessage // rewritten ID → host's original ID
+}
+
+func newIDMapper(prefix string) *idMapper {
+	if prefix == "" {
+		prefix = DefaultIDPrefix
+	}
+	return &idMapper{
+		prefix: prefix,
+		owned:  make(map[string]chan []byte),
+		hostID: make(map[string]json.RawMessage),
+	}
+}
+
+// allocLocked returns a fresh reserved ID as a JSON string. Caller holds mu.
+func (m *idMapper) allocLocked() json.RawMessage {
+	m.next++
+	return json.RawMessage(strconv.Quote(m.prefix + strconv.FormatUint(m.next, 10)))
+}
+
+// reserve allocates an ID for a proxy-originated request. The response is
+// delivered on the returned channel; call release if it is abandoned.
+func (m *idMapper) reserve() (json.RawMessage, <-chan []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := m.allocLocked()
+	ch := make(chan []byte, 1)
+	m.owned[string(id)] = ch
+	return id, ch
+}
+
+// release forgets a proxy-originated request that will not be awaited.
+func (m *idMapper) release(id json.RawMessage) {
+	m.mu.Lock()
+	delete(m.owned, string(id))
+	m.mu.Unlock()
+}
+
+// reserved reports whether id falls inside the proxy's namespace.
+func (m *idMapper) reserved(id json.RawMessage) bool {
+	var s string
+	return json.Unmarshal(id, &s) == nil && strings.HasPrefix(s, m.prefix)
+}
+
+// outbound prepares a host→server request. If its ID collides with the
+// reserved namespace it is rewritten and the mapping remembered.
+func (m *idMapper) outbound(msg *InterceptedMessage, raw []byte) ([]byte, error) {
+	if msg.Parsed.Kind() != KindRequest || !m.reserved(msg.Parsed.ID) {
+		return raw, nil
+	}
+
+	m.mu.Lock()
+	id := m.allocLocked()
+	m.hostID[string(id)] = msg.Parsed.ID
+	m.mu.Unlock()
+
+	return rewriteID(raw, id)
+}
+
+// inbound handles a server→host response. Responses to proxy-originated
+// requests are delivered to their waiter and reported as claimed; the
+// caller must not forward them. Responses to rewritten host requests get
+// their original ID back.
+func (m *idMapper) inbound(msg *InterceptedMessage, raw []byte) (out []byte, claimed bool, err error) {
+	if msg.Parsed.ID == nil || msg.Parsed.Method != "" {
+		return raw, false, nil
+	}
+	key := string(msg.Parsed.ID)
+
+	m.mu.Lock()
+	ch, isOwned := m.owned[key]
+	if isOwned {
+		delete(m.owned, key)
+	}
+	orig, isMapped := m.hostID[key]
+	if isMapped {
+		delete(m.hostID, key)
+	}
+	m.mu.Unlock()
+
+	switch {
+	case isOwned:
+		ch <- raw
+		return nil, true, nil
+	case isMapped:
+		out, err := rewriteID(raw, orig)
+		if err != nil {
+			return nil, false, err
+		}
+		msg.Parsed.ID = orig
+		return out, false, nil
+	}
+	return raw, false, nil
+}
+
+// rewriteID replaces the top-level "id" of a JSON-RPC message.
+func rewriteID(raw []byte, id json.RawMessage) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, fmt.Errorf("rewrite id: %w", err)
+	}
+	fields["id"] = id
+	return json.Marshal(fields)
+}
@@ -0,0 +1,162 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func parsedMessage(t *testing.T, dir Direction, raw string) *InterceptedMessage {
+	t.Helper()
+	parsed, err := ParseMessage([]byte(raw))
+	if err != nil {
+		t.Fatalf("parse %s: %v", raw, err)
+	}
+	return &InterceptedMessage{Direction: dir, RawBytes: []byte(raw), Parsed: parsed}
+}
+
+func TestIDMapper_ReservedIDsAreUnique(t *testing.T) {
+	m := newIDMapper("")
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		id, _ := m.reserve()
+		if seen[string(id)] {
+			t.Fatalf("duplicate reserved id %s", id)
+		}
+		seen[string(id)] = true
+		if !m.reserved(id) {
+			t.Errorf("id %s not recognized as reserved", id)
+		}
+	}
+}
+
+func TestIDMapper_ClaimsOwnResponses(t *testing.T) {
+	m := newIDMapper("cg-")
+	id, ch := m.reserve()
+
+	resp := `{"jsonrpc":"2.0","id":` + string(id) + `,"result":{}}`
+	out, claimed, err := m.inbound(parsedMessage(t, DirServerToHost, resp), []byte(resp))
+	if err != nil {
+		t.Fatalf("inbound: %v", err)
+	}
+	if !claimed || out != nil {
+		t.Fatalf("claimed = %v, out = %q; want proxy response claimed", claimed, out)
+	}
+	if got := string(<-ch); got != resp {
+		t.Errorf("delivered %q, want %q", got, resp)
+	}
+
+	// A second response with the same ID is no longer ours
+	if _, claimed, _ := m.inbound(parsedMessage(t, DirServerToHost, resp), []byte(resp)); claimed {
+		t.Error("response claimed twice")
+	}
+}
+
+func TestIDMapper_HostIDsPassThrough(t *testing.T) {
+	m := newIDMapper("cg-")
+	for _, id := range []string{`1`, `"abc"`, `"cg"`} {
+		req := `{"jsonrpc":"2.0","id":` + id + `,"method":"tools/list"}`
+		out, err := m.outbound(parsedMessage(t, DirHostToServer, req), []byte(req))
+		if err != nil {
+			t.Fatalf("outbound: %v", err)
+		}
+		if string(out) != req {
+			t.Errorf("host request %s rewritten to %s", req, out)
+		}
+
+		resp := `{"jsonrpc":"2.0","id":` + id + `,"result":{}}`
+		out, claimed, _ := m.inbound(parsedMessage(t, DirServerToHost, resp), []byte(resp))
+		if claimed || string(out) != resp {
+			t.Errorf("host response %s altered: claimed=%v out=%s", resp, claimed, out)
+		}
+	}
+}
+
+func TestIDMapper_CollidingHostIDRoundTrip(t *testing.T) {
+	m := newIDMapper("cg-")
+	ownID, ownCh := m.reserve() // "cg-1"
+
+	// The host picks the same ID the proxy is already using
+	req := `{"jsonrpc":"2.0","id":"cg-1","method":"tools/call","params":{"name":"x"}}`
+	msg := parsedMessage(t, DirHostToServer, req)
+	out, err := m.outbound(msg, []byte(req))
+	if err != nil {
+		t.Fatalf("outbound: %v", err)
+	}
+	sent, _ := ParseMessage(out)
+	if string(sent.ID) == string(ownID) || !m.reserved(sent.ID) {
+		t.Fatalf("colliding id not remapped: sent %s, proxy owns %s", sent.ID, ownID)
+	}
+	if sent.Method != "tools/call" || !strings.Contains(string(sent.Params), `"x"`) {
+		t.Errorf("request body altered: %s", out)
+	}
+
+	// Downstream answers the host's (remapped) request first
+	resp := `{"jsonrpc":"2.0","id":` + string(sent.ID) + `,"result":{"ok":true}}`
+	respMsg := parsedMessage(t, DirServerToHost, resp)
+	back, claimed, err := m.inbound(respMsg, []byte(resp))
+	if err != nil || claimed {
+		t.Fatalf("inbound: claimed=%v err=%v", claimed, err)
+	}
+	restored, _ := ParseMessage(back)
+	if string(restored.ID) != `"cg-1"` || string(respMsg.Parsed.ID) != `"cg-1"` {
+		t.Errorf("restored id = %s (parsed %s), want \"cg-1\"", restored.ID, respMsg.Parsed.ID)
+	}
+	if !strings.Contains(string(restored.Result), `"ok":true`) {
+		t.Errorf("result altered: %s", back)
+	}
+
+	// The proxy's own response is still delivered to the proxy
+	ownResp := `{"jsonrpc":"2.0","id":"cg-1","result":{}}`
+	if _, claimed, _ := m.inbound(parsedMessage(t, DirServerToHost, ownResp), []byte(ownResp)); !claimed {
+		t.Error("proxy response not claimed")
+	}
+	select {
+	case <-ownCh:
+	default:
+		t.Error("proxy response not delivered")
+	}
+}
+
+func TestProxy_RequestResponseNotForwarded(t *testing.T) {
+	p := NewProxy(Config{SessionID: "test"}, NewInterceptorChain(), testLogger())
+	down := &syncBuffer{}
+	host := &syncBuffer{}
+	p.downStdin = down
+	p.hostOut = host
+
+	type result struct {
+		msg JSONRPCMessage
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		msg, err := p.request(context.Background(), "ping", nil)
+		done <- result{msg, err}
+	}()
+
+	waitFor(t, func() bool { return down.lineCount() == 1 })
+	sent, _ := ParseMessage([]byte(strings.TrimSpace(down.String())))
+	if sent.Method != "ping" || !p.ids.reserved(sent.ID) {
+		t.Fatalf("unexpected proxy request: %s", down.String())
+	}
+
+	var id string
+	json.Unmarshal(sent.ID, &id)
+	src := strings.NewReader(`{"jsonrpc":"2.0","id":"` + id + `","result":{}}` + "\n")
+	if err := p.pipeMessages(context.Background(), src, host, DirServerToHost); err != nil {
+		t.Fatalf("pipeMessages: %v", err)
+	}
+
+	r := <-done
+	if r.err != nil {
+		t.Fatalf("request: %v", r.err)
+	}
+	if r.msg.Result == nil {
+		t.Error("request returned no result")
+	}
+	if host.String() != "" {
+		t.Errorf("proxy response leaked to host: %s", host.String())
+	}
+}
@@ -1,6 +1,10 @@
 package proxy
 
-import "context"
+import (
+	"context"
+	"errors"
+	"time"
+)
 
 // Interceptor processes an intercepted MCP message and decides whether
 // to forward, modify, or block it.
@@ -9,10 +13,26 @@ import "context"
 //   - (modifiedBytes, nil): forward the (possibly modified) message
 //   - (nil, nil): drop the message silently
 //   - (nil, err): block the message and send a JSON-RPC error back
+//   - (nil, DropBlocked(err)): block the message without a reply
+//
+// Setting MetaKeyBypass in the message's metadata forwards it without
+// running the interceptors that follow.
 type Interceptor interface {
 	Intercept(ctx context.Context, msg *InterceptedMessage) ([]byte, error)
 }
 
+// droppedError is a block answered with silence; see DropBlocked.
+type droppedError struct{ err error }
+
+func (e droppedError) Error() string { return e.err.Error() }
+func (e droppedError) Unwrap() error { return e.err }
+
+// DropBlocked wraps err so that the chain reports the message to OnBlock,
+// like any blocked message, and then drops it instead of replying.
+func DropBlocked(err error) error {
+	return droppedError{err: err}
+}
+
 // InterceptorFunc is a convenience adapter for using a function as an Interceptor.
 type InterceptorFunc func(ctx context.Context, msg *InterceptedMessage) ([]byte, error)
 
@@ -20,10 +40,43 @@ func (f InterceptorFunc) Intercept(ctx context.Context, msg *InterceptedMessage)
 	return f(ctx, msg)
 }
 
+// DirectionalInterceptor runs an interceptor only for messages travelling
+// in one direction. The chain passes other messages on without calling it.
+type DirectionalInterceptor struct {
+	Interceptor
+	Direction Direction
+}
+
+// OnlyDirection limits i to messages travelling in dir.
+func OnlyDirection(dir Direction, i Interceptor) *DirectionalInterceptor {
+	return &DirectionalInterceptor{Interceptor: i, Direction: dir}
+}
+
+// Intercept runs the wrapped interceptor for messages in d's direction
+// and forwards the rest unchanged.
+func (d *DirectionalInterceptor) Intercept(ctx context.Context, msg *InterceptedMessage) ([]byte, error) {
+	if msg.Direction != d.Direction {
+		return msg.RawBytes, nil
+	}
+	return d.Interceptor.Intercept(ctx, msg)
+}
+
 // InterceptorChain runs interceptors in order. Processing stops on the
 // first interceptor that blocks or drops a message.
 type InterceptorChain struct {
 	interceptors []Interceptor
+
+	// OnBlock is called when an interceptor blocks a message, before the
+	// error is returned to the proxy, including blocks that are dropped
+	// without a reply.
+	OnBlock func(ctx context.Context, msg *InterceptedMessage, err error)
+
+	// Latency, if set, records how long each interceptor takes.
+	Latency *InterceptorLatency
+
+	// Messages, if set, records the size of every message and the latency
+	// of every response.
+	Messages *MessageMetrics
 }
 
 func NewInterceptorChain(interceptors ...Interceptor) *InterceptorChain {
@@ -34,17 +87,39 @@ func NewInterceptorChain(interceptors ...Interceptor) *InterceptorChain {
 // be modified by each interceptor in sequence.
 func (c *InterceptorChain) Process(ctx context.Context, msg *InterceptedMessage) ([]byte, error) {
 	raw := msg.RawBytes
+	if c.Messages != nil {
+		c.Messages.Observe(msg)
+	}
 	for _, i := range c.interceptors {
+		if d, ok := i.(*DirectionalInterceptor); ok {
+			if d.Direction != msg.Direction {
+				continue
+			}
+			i = d.Interceptor // timed under its own name
+		}
 		// Update raw bytes for next interceptor (in case previous one modified them)
 		msg.RawBytes = raw
+		start := time.Now()
 		modified, err := i.Intercept(ctx, msg)
+		if c.Latency != nil {
+			c.Latency.Observe(interceptorName(i), time.Since(start))
+		}
 		if err != nil {
+			if c.OnBlock != nil {
+				c.OnBlock(ctx, msg, err)
+			}
+			if errors.As(err, new(droppedError)) {
+				return nil, nil
+			}
 			return nil, err
 		}
 		if modified == nil {
 			return nil, nil // dropped
 		}
 		raw = modified
+		if bypass, _ := msg.Metadata[MetaKeyBypass].(bool); bypass {
+			break
+		}
 	}
 	return raw, nil
 }
@@ -130,3 +130,92 @@ func TestInterceptorChain_BlockStopsChain(t *testing.T) {
 		t.Error("interceptor after blocker should not have been reached")
 	}
 }
+
+func TestInterceptorChain_OnBlock(t *testing.T) {
+	blocker := InterceptorFunc(func(_ context.Context, msg *InterceptedMessage) ([]byte, error) {
+		return nil, errors.New("blocked")
+	})
+
+	chain := NewInterceptorChain(blocker)
+	var blockedMsg *InterceptedMessage
+	var blockErr error
+	chain.OnBlock = func(_ context.Context, msg *InterceptedMessage, err error) {
+		blockedMsg = msg
+		blockErr = err
+	}
+
+	msg := &InterceptedMessage{RawBytes: []byte(`{}`)}
+	chain.Process(context.Background(), msg)
+
+	if blockedMsg != msg {
+		t.Fatal("expected OnBlock to receive the blocked message")
+	}
+	if blockErr == nil || blockErr.Error() != "blocked" {
+		t.Errorf("block error = %v, want %q", blockErr, "blocked")
+	}
+}
+
+func TestInterceptorChain_OnBlockNotCalledOnDrop(t *testing.T) {
+	dropper := InterceptorFunc(func(_ context.Context, msg *InterceptedMessage) ([]byte, error) {
+		return nil, nil
+	})
+
+	chain := NewInterceptorChain(dropper)
+	called := false
+	chain.OnBlock = func(context.Context, *InterceptedMessage, error) { called = true }
+
+	chain.Process(context.Background(), &InterceptedMessage{RawBytes: []byte(`{}`)})
+
+	if called {
+		t.Error("OnBlock should not be called for dropped messages")
+	}
+}
+
+func TestInterceptorChain_DirectionalSkipped(t *testing.T) {
+	var calls []Direction
+	record := InterceptorFunc(func(_ context.Context, msg *InterceptedMessage) ([]byte, error) {
+		calls = append(calls, msg.Direction)
+		return []byte(`{"scrubbed":true}`), nil
+	})
+	chain := NewInterceptorChain(OnlyDirection(DirServerToHost, record))
+	chain.Latency = NewInterceptorLatency()
+
+	raw := []byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call"}`)
+	out, err := chain.Process(context.Background(), &InterceptedMessage{Direction: DirHostToServer, RawBytes: raw})
+	if err != nil || string(out) != string(raw) {
+		t.Errorf("host→server: got %s, %v; want the message unchanged", out, err)
+	}
+	if len(calls) != 0 {
+		t.Errorf("server→host interceptor ran for %v", calls)
+	}
+	if snaps := chain.Latency.Snapshot(); len(snaps) != 0 {
+		t.Errorf("skipped interceptor was timed: %+v", snaps)
+	}
+
+	out, err = chain.Process(context.Background(), &InterceptedMessage{Direction: DirServerToHost, RawBytes: raw})
+	if err != nil || string(out) != `{"scrubbed":true}` {
+		t.Errorf("server→host: got %s, %v", out, err)
+	}
+	if len(calls) != 1 || calls[0] != DirServerToHost {
+		t.Errorf("calls = %v, want one server→host", calls)
+	}
+	// Timed under the wrapped interceptor's name
+	if snaps := chain.Latency.Snapshot(); len(snaps) != 1 || snaps[0].Interceptor != "InterceptorFunc" {
+		t.Errorf("latency = %+v, want one entry for InterceptorFunc", snaps)
+	}
+}
+
+func TestDirectionalInterceptor_Direct(t *testing.T) {
+	block := InterceptorFunc(func(context.Context, *InterceptedMessage) ([]byte, error) {
+		return nil, errors.New("blocked")
+	})
+	d := OnlyDirection(DirHostToServer, block)
+
+	raw := []byte(`{}`)
+	if out, err := d.Intercept(context.Background(), &InterceptedMessage{Direction: DirServerToHost, RawBytes: raw}); err != nil || string(out) != "{}" {
+		t.Errorf("other direction: got %s, %v; want it passed through", out, err)
+	}
+	if _, err := d.Intercept(context.Background(), &InterceptedMessage{Direction: DirHostToServer, RawBytes: raw}); err == nil {
+		t.Error("wrapped interceptor did not run for its direction")
+	}
+}
@@ -0,0 +1,113 @@
+package proxy
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+// LatencyBuckets are the upper bounds of the interceptor latency
+// histogram. Durations above the last bound fall in an implicit +Inf
+// bucket; approval waits usually land there.
+var LatencyBuckets = []time.Duration{
+	10 * time.Microsecond,
+	50 * time.Microsecond,
+	100 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+}
+
+// InterceptorLatency accumulates how long each interceptor takes to
+// process a message, keyed by the interceptor's type name.
+type InterceptorLatency struct {
+	mu    sync.Mutex
+	stats map[string]*latencyStat
+}
+
+type latencyStat struct {
+	count    uint64
+	sum, max time.Duration
+	buckets  []uint64 // per bucket, not cumulative; last is +Inf
+}
+
+// LatencySnapshot is one interceptor's accumulated timings.
+type LatencySnapshot struct {
+	Interceptor string
+	Count       uint64
+	Sum         time.Duration
+	Max         time.Duration
+	Buckets     []uint64 // cumulative count at or below each of LatencyBuckets
+}
+
+// Avg returns the mean processing time.
+func (s LatencySnapshot) Avg() time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Sum / time.Duration(s.Count)
+}
+
+func NewInterceptorLatency() *InterceptorLatency {
+	return &InterceptorLatency{stats: make(map[string]*latencyStat)}
+}
+
+// Observe records one Intercept call by the named interceptor.
+func (l *InterceptorLatency) Observe(name string, d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	st, ok := l.stats[name]
+	if !ok {
+		st = &latencyStat{buckets: make([]uint64, len(LatencyBuckets)+1)}
+		l.stats[name] = st
+	}
+	st.count++
+	st.sum += d
+	st.max = max(st.max, d)
+	i := sort.Search(len(LatencyBuckets), func(i int) bool { return d <= LatencyBuckets[i] })
+	st.buckets[i]++
+}
+
+// Snapshot returns the timings of every interceptor seen so far, sorted
+// by name.
+func (l *InterceptorLatency) Snapshot() []LatencySnapshot {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	out := make([]LatencySnapshot, 0, len(l.stats))
+	for name, st := range l.stats {
+		snap := LatencySnapshot{
+			Interceptor: name,
+			Count:       st.count,
+			Sum:         st.sum,
+			Max:         st.max,
+			Buckets:     make([]uint64, len(LatencyBuckets)),
+		}
+		var cum uint64
+		for i := range LatencyBuckets {
+			cum += st.buckets[i]
+			snap.Buckets[i] = cum
+		}
+		out = append(out, snap)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Interceptor < out[j].Interceptor })
+	return out
+}
+
+// interceptorName returns the type name an interceptor's timings are
+// recorded under, e.g. "PolicyInterceptor".
+func interceptorName(i Interceptor) string {
+	t := reflect.TypeOf(i)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Name()
+}
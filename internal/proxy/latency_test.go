@@ -0,0 +1,67 @@
+package proxy
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type slowInterceptor struct{ delay time.Duration }
+
+func (s *slowInterceptor) Intercept(_ context.Context, msg *InterceptedMessage) ([]byte, error) {
+	time.Sleep(s.delay)
+	return msg.RawBytes, nil
+}
+
+func TestInterceptorChain_RecordsLatency(t *testing.T) {
+	fast := InterceptorFunc(func(_ context.Context, msg *InterceptedMessage) ([]byte, error) {
+		return msg.RawBytes, nil
+	})
+	chain := NewInterceptorChain(fast, &slowInterceptor{delay: 20 * time.Millisecond})
+	chain.Latency = NewInterceptorLatency()
+
+	for i := 0; i < 3; i++ {
+		msg := &InterceptedMessage{RawBytes: []byte(`{}`)}
+		if _, err := chain.Process(context.Background(), msg); err != nil {
+			t.Fatalf("Process: %v", err)
+		}
+	}
+
+	snaps := chain.Latency.Snapshot()
+	if len(snaps) != 2 {
+		t.Fatalf("got %d interceptors, want 2: %+v", len(snaps), snaps)
+	}
+	byName := map[string]LatencySnapshot{}
+	for _, s := range snaps {
+		byName[s.Interceptor] = s
+	}
+
+	slow, ok := byName["slowInterceptor"]
+	if !ok {
+		t.Fatalf("no timings for slowInterceptor: %+v", snaps)
+	}
+	if slow.Count != 3 || slow.Avg() < 20*time.Millisecond || slow.Max < 20*time.Millisecond {
+		t.Errorf("slow timings = %+v (avg %v), want 3 calls of at least 20ms", slow, slow.Avg())
+	}
+	if f := byName["InterceptorFunc"]; f.Count != 3 || f.Avg() >= slow.Avg() {
+		t.Errorf("fast timings = %+v, want 3 calls quicker than the slow interceptor", f)
+	}
+}
+
+func TestInterceptorLatency_Buckets(t *testing.T) {
+	l := NewInterceptorLatency()
+	l.Observe("x", 5*time.Microsecond)
+	l.Observe("x", 2*time.Millisecond)
+	l.Observe("x", time.Minute)
+
+	snap := l.Snapshot()[0]
+	if snap.Buckets[0] != 1 {
+		t.Errorf("<=10us bucket = %d, want 1", snap.Buckets[0])
+	}
+	if last := snap.Buckets[len(snap.Buckets)-1]; last != 2 {
+		t.Errorf("<=5s bucket = %d, want 2 (the minute falls in +Inf)", last)
+	}
+	if snap.Count != 3 || snap.Max != time.Minute {
+		t.Errorf("count = %d, max = %v; want 3, 1m", snap.Count, snap.Max)
+	}
+}
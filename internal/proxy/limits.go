@@ -0,0 +1,14 @@
+package proxy
+
+// ChildLimits are resource limits applied to the downstream process.
+// Zero values leave the inherited limit unchanged.
+type ChildLimits struct {
+	NoFile       uint64 // max open file descriptors
+	AddressSpace uint64 // max virtual memory in bytes
+	CPUSeconds   uint64 // max CPU time in seconds
+	Nice         int    // scheduling niceness (1-19 lowers priority)
+}
+
+func (l ChildLimits) isZero() bool {
+	return l == ChildLimits{}
+}
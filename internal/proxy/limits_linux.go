@@ -0,0 +1,123 @@
+//go:build linux
+
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// limitShimEnv carries the limits to a re-executed copy of this binary.
+// Go can't run code between fork and exec, so a limited downstream is
+// started through the shim: it applies the limits to itself and then
+// execs the server, which inherits them from its first instruction.
+const limitShimEnv = "CONTEXTGATE_CHILD_LIMITS"
+
+// limitsSupported reports whether limitedCommand can apply ChildLimits.
+const limitsSupported = true
+
+func init() {
+	if spec, ok := os.LookupEnv(limitShimEnv); ok {
+		runLimitShim(spec, os.Args[1:])
+	}
+}
+
+// limitedCommand returns a command that runs name with args under l.
+func limitedCommand(ctx context.Context, name string, args []string, l ChildLimits) (*exec.Cmd, error) {
+	if l.isZero() {
+		return exec.CommandContext(ctx, name, args...), nil
+	}
+	// Resolved here so a missing command fails Start, as it does unlimited
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return nil, err
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("locate shim: %w", err)
+	}
+	cmd := exec.CommandContext(ctx, self, append([]string{path, name}, args...)...)
+	cmd.Env = append(os.Environ(), limitShimEnv+"="+encodeLimits(l))
+	return cmd, nil
+}
+
+func encodeLimits(l ChildLimits) string {
+	return fmt.Sprintf("%d,%d,%d,%d", l.NoFile, l.AddressSpace, l.CPUSeconds, l.Nice)
+}
+
+func decodeLimits(spec string) (ChildLimits, error) {
+	var l ChildLimits
+	fields := strings.Split(spec, ",")
+	if len(fields) != 4 {
+		return l, fmt.Errorf("malformed limits %q", spec)
+	}
+	var err error
+	for i, dst := range []*uint64{&l.NoFile, &l.AddressSpace, &l.CPUSeconds} {
+		if *dst, err = strconv.ParseUint(fields[i], 10, 64); err != nil {
+			return l, fmt.Errorf("malformed limits %q", spec)
+		}
+	}
+	if l.Nice, err = strconv.Atoi(fields[3]); err != nil {
+		return l, fmt.Errorf("malformed limits %q", spec)
+	}
+	return l, nil
+}
+
+// runLimitShim applies the limits in spec to this process and execs
+// args, the server's resolved path followed by its argv. It never
+// returns: a failure is reported on stderr and exits with status 126.
+func runLimitShim(spec string, args []string) {
+	// Niceness is per thread; exec from the one that set it
+	runtime.LockOSThread()
+	err := func() error {
+		if len(args) < 2 {
+			return fmt.Errorf("no command to run")
+		}
+		l, err := decodeLimits(spec)
+		if err != nil {
+			return err
+		}
+		os.Unsetenv(limitShimEnv)
+		if err := applyLimits(l); err != nil {
+			return err
+		}
+		return syscall.Exec(args[0], args[1:], os.Environ())
+	}()
+	fmt.Fprintf(os.Stderr, "contextgate: limit downstream: %v\n", err)
+	os.Exit(126)
+}
+
+// applyLimits sets resource limits and niceness on the calling process.
+// The address space limit is set last, as it may leave too little room
+// for the process to do anything but exec.
+func applyLimits(l ChildLimits) error {
+	if l.Nice != 0 {
+		if err := syscall.Setpriority(syscall.PRIO_PROCESS, 0, l.Nice); err != nil {
+			return fmt.Errorf("set niceness: %w", err)
+		}
+	}
+	limits := []struct {
+		name     string
+		resource int
+		value    uint64
+	}{
+		{"nofile", syscall.RLIMIT_NOFILE, l.NoFile},
+		{"cpu", syscall.RLIMIT_CPU, l.CPUSeconds},
+		{"as", syscall.RLIMIT_AS, l.AddressSpace},
+	}
+	for _, lim := range limits {
+		if lim.value == 0 {
+			continue
+		}
+		if err := syscall.Setrlimit(lim.resource, &syscall.Rlimit{Cur: lim.value, Max: lim.value}); err != nil {
+			return fmt.Errorf("set %s limit: %w", lim.name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+//go:build linux
+
+package proxy
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestLimitedCommand(t *testing.T) {
+	l := ChildLimits{NoFile: 64, CPUSeconds: 30, Nice: 5}
+	// cat reads the limits it started with; nothing can adjust them first
+	cmd, err := limitedCommand(context.Background(), "cat", []string{"/proc/self/limits", "/proc/self/stat"}, l)
+	if err != nil {
+		t.Fatalf("limitedCommand: %v", err)
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("run cat: %v", err)
+	}
+	data := string(out)
+
+	for _, want := range []string{"Max open files", "Max cpu time"} {
+		line := findLine(data, want)
+		fields := strings.Fields(strings.TrimPrefix(line, want))
+		if len(fields) < 2 {
+			t.Fatalf("%s not found in:\n%s", want, data)
+		}
+		expected := "64"
+		if want == "Max cpu time" {
+			expected = "30"
+		}
+		if fields[0] != expected || fields[1] != expected {
+			t.Errorf("%s = %v, want soft and hard %s", want, fields[:2], expected)
+		}
+	}
+
+	// Field 19 of /proc/self/stat; the fields after comm start at 3
+	stat := data[strings.LastIndex(data, ")")+1:]
+	if fields := strings.Fields(stat); len(fields) < 17 || fields[16] != "5" {
+		t.Errorf("stat = %q, want nice 5", stat)
+	}
+}
+
+func TestLimitedCommand_InPlaceAtExec(t *testing.T) {
+	// Too little address space to load any program: only a limit already
+	// in force at exec keeps the command from running
+	cmd, err := limitedCommand(context.Background(), "sh", []string{"-c", "echo ran"}, ChildLimits{AddressSpace: 1 << 20})
+	if err != nil {
+		t.Fatalf("limitedCommand: %v", err)
+	}
+	out, err := cmd.Output()
+	if err == nil || strings.Contains(string(out), "ran") {
+		t.Errorf("command ran under the limit: output %q, error %v", out, err)
+	}
+}
+
+func TestLimitedCommand_MissingCommand(t *testing.T) {
+	if _, err := limitedCommand(context.Background(), "contextgate-no-such-command", nil, ChildLimits{NoFile: 64}); err == nil {
+		t.Error("expected an error for a missing command")
+	}
+}
+
+func findLine(s, prefix string) string {
+	for _, line := range strings.Split(s, "\n") {
+		if strings.HasPrefix(line, prefix) {
+			return line
+		}
+	}
+	return ""
+}
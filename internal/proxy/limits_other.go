@@ -0,0 +1,17 @@
+//go:build !linux
+
+package proxy
+
+import (
+	"context"
+	"os/exec"
+)
+
+// limitsSupported reports whether limitedCommand can apply ChildLimits.
+const limitsSupported = false
+
+// limitedCommand returns a command that runs name with args. Limits are
+// only supported on Linux; elsewhere l is ignored.
+func limitedCommand(ctx context.Context, name string, args []string, l ChildLimits) (*exec.Cmd, error) {
+	return exec.CommandContext(ctx, name, args...), nil
+}
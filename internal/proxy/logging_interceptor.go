@@ -1,10 +1,19 @@
 package proxy
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"time"
+	"unicode/utf8"
 
 	"github.com/contextgate/contextgate/internal/eventbus"
+	"github.com/contextgate/contextgate/internal/policy"
 	"github.com/contextgate/contextgate/internal/store"
 )
 
@@ -21,20 +30,104 @@ func extractToolNameFromParams(params json.RawMessage) string {
 	return p.Name
 }
 
+// extractArguments returns the arguments object of a tools/call payload,
+// taken from the stored (possibly redacted) bytes rather than the original
+// message so redacted values stay out of the arguments column too.
+func extractArguments(payload []byte) json.RawMessage {
+	var m struct {
+		Params struct {
+			Arguments json.RawMessage `json:"arguments"`
+		} `json:"params"`
+	}
+	if err := json.Unmarshal(payload, &m); err != nil {
+		return nil
+	}
+	if args := bytes.TrimSpace(m.Params.Arguments); len(args) > 0 && args[0] == '{' {
+		return args
+	}
+	return nil
+}
+
 // LoggingInterceptor logs all messages to the store and publishes
 // them to the event bus for the live dashboard. It never blocks
 // or modifies messages.
 type LoggingInterceptor struct {
 	store    store.Store
 	eventBus *eventbus.EventBus
+
+	// RedactArgs maps tool names to tools/call argument keys whose values
+	// are blanked in the stored payload. Forwarded bytes are not affected.
+	RedactArgs map[string][]string
+
+	// BinaryAsBase64 stores binary payloads base64-encoded instead of as a
+	// size placeholder.
+	BinaryAsBase64 bool
+
+	// MaxPayloadBytes, if positive, truncates stored payloads longer than
+	// this and appends a marker. SizeBytes keeps the original size and
+	// forwarded bytes are not affected.
+	MaxPayloadBytes int
+
+	// Backpressure, if positive, is the longest a message is held while
+	// the store's write buffer is above HighWater, giving the writer time
+	// to catch up instead of dropping log entries. Zero disables it.
+	Backpressure time.Duration
+
+	// HighWater is the buffer fill level (0 to 1) at which backpressure
+	// engages. Zero means DefaultHighWater.
+	HighWater float64
+
+	// PayloadRules name high-volume methods whose payloads are only
+	// sampled; see policy.PayloadStorage. Other fields are always stored.
+	PayloadRules []policy.PayloadStorage
+
+	// Events, if set, receives each logged message to emit its policy
+	// decision and scrub as OpenTelemetry log records.
+	Events *SecurityEvents
+
+	seq    seqClock // for messages that reach the log without a sequence number
+	now    func() time.Time
+	random func() float64 // samples payloads; rand.Float64 outside tests
+
+	sampled *callCorrelator[*policy.PayloadStorage] // requests whose responses a rule covers
 }
 
+// DefaultHighWater is the store buffer fill level at which backpressure
+// engages unless LoggingInterceptor.HighWater says otherwise.
+const DefaultHighWater = 0.8
+
+// backpressurePoll is how often a held message rechecks the buffer.
+const backpressurePoll = 5 * time.Millisecond
+
 func NewLoggingInterceptor(s store.Store, eb *eventbus.EventBus) *LoggingInterceptor {
-	return &LoggingInterceptor{store: s, eventBus: eb}
+	return &LoggingInterceptor{
+		store:    s,
+		eventBus: eb,
+		now:      time.Now,
+		random:   rand.Float64,
+		sampled:  newCallCorrelator[*policy.PayloadStorage](),
+	}
 }
 
 func (l *LoggingInterceptor) Intercept(ctx context.Context, msg *InterceptedMessage) ([]byte, error) {
+	l.record(ctx, msg, nil)
+	return msg.RawBytes, nil
+}
+
+// LogBlocked records a message that was blocked by an earlier interceptor.
+// It is wired to InterceptorChain.OnBlock since blocked messages never
+// reach the end of the chain.
+func (l *LoggingInterceptor) LogBlocked(ctx context.Context, msg *InterceptedMessage, err error) {
+	if err == nil {
+		err = errors.New("blocked")
+	}
+	l.record(ctx, msg, err)
+}
+
+// record logs msg; blockErr is set when an interceptor blocked it.
+func (l *LoggingInterceptor) record(ctx context.Context, msg *InterceptedMessage, blockErr error) {
 	entry := &store.LogEntry{
+		Seq:       msg.Seq,
 		Timestamp: msg.Timestamp,
 		SessionID: msg.SessionID,
 		Direction: string(msg.Direction),
@@ -43,6 +136,11 @@ func (l *LoggingInterceptor) Intercept(ctx context.Context, msg *InterceptedMess
 		MsgID:     string(msg.Parsed.ID),
 		Payload:   string(msg.RawBytes),
 		SizeBytes: len(msg.RawBytes),
+		Blocked:   blockErr != nil,
+	}
+
+	if entry.Seq == 0 {
+		entry.Seq = l.seq.next(l.now())
 	}
 
 	// Read metadata annotations from earlier interceptors
@@ -59,18 +157,215 @@ func (l *LoggingInterceptor) Intercept(ctx context.Context, msg *InterceptedMess
 		if action, ok := msg.Metadata[MetaKeyPolicyAction].(string); ok {
 			entry.PolicyAction = action
 		}
+		if tokens, ok := msg.Metadata[MetaKeyTokenEstimate].(int); ok {
+			entry.TokenEstimate = tokens
+		}
+		if unrecognized, ok := msg.Metadata[MetaKeyUnrecognizedMethod].(bool); ok {
+			entry.Unrecognized = unrecognized
+		}
+		if latency, ok := msg.Metadata[MetaKeyLatencyMs].(float64); ok {
+			entry.LatencyMs = latency
+		}
+		if tool, ok := msg.Metadata[MetaKeyRequestTool].(string); ok {
+			entry.RequestTool = tool
+		}
+		if pruned, ok := msg.Metadata[MetaKeyToolsPruned].(int); ok {
+			entry.ToolsPruned = pruned
+		}
+		if stored, ok := msg.Metadata[MetaKeyStoredPayload].(string); ok {
+			entry.Payload = stored
+		}
 	}
 
 	// Extract tool name for tools/call
 	if msg.Parsed.Method == "tools/call" {
 		entry.ToolName = extractToolNameFromParams(msg.Parsed.Params)
+		if keys := l.RedactArgs[entry.ToolName]; len(keys) > 0 {
+			entry.Payload = string(redactToolArgs([]byte(entry.Payload), keys))
+		}
+		entry.Arguments = extractArguments([]byte(entry.Payload))
 	}
 
-	// Async — does not block
+	if !l.storePayload(msg, entry) {
+		entry.Payload = fmt.Sprintf("[payload not stored, %d bytes]", entry.SizeBytes)
+		entry.Arguments = nil
+	}
+
+	if safe, ok := sanitizeForStorage([]byte(entry.Payload)); !ok {
+		if l.BinaryAsBase64 {
+			safe = "base64:" + base64.StdEncoding.EncodeToString([]byte(entry.Payload))
+		}
+		entry.Payload = safe
+		entry.Arguments = nil // taken from the bytes the payload replaced
+	}
+	if l.MaxPayloadBytes > 0 {
+		entry.Payload = truncatePayload(entry.Payload, l.MaxPayloadBytes, entry.SizeBytes)
+		if len(entry.Arguments) > l.MaxPayloadBytes {
+			entry.Arguments = nil // can't be cut without breaking the JSON
+		}
+	}
+
+	// Async — only waits when backpressure is on and the buffer is filling
+	l.waitForStore(ctx)
 	l.store.LogMessage(ctx, entry)
 
 	// Publish for SSE — also non-blocking
 	l.eventBus.Publish(entry)
+	l.Events.Message(msg, entry, blockErr)
+}
 
-	return msg.RawBytes, nil
+// storePayload reports whether entry's payload belongs in the log under
+// PayloadRules. A request covered by a rule is remembered so its response
+// is sampled by the same rule, until it is answered or pendingTTL passes.
+func (l *LoggingInterceptor) storePayload(msg *InterceptedMessage, entry *store.LogEntry) bool {
+	if len(l.PayloadRules) == 0 {
+		return true
+	}
+
+	var rule *policy.PayloadStorage
+	if msg.Parsed.Method != "" {
+		rule = l.payloadRule(entry.Direction, entry.Method)
+		// A blocked request is never answered by the server
+		if rule != nil && msg.Parsed.Kind() == KindRequest && !entry.Blocked {
+			l.sampled.remember(msg, rule)
+		}
+	} else if msg.Parsed.ID != nil {
+		rule, _ = l.sampled.answer(msg)
+	}
+
+	if rule == nil || flagged(entry) {
+		return true
+	}
+	return rule.Sample > 0 && l.random() < rule.Sample
+}
+
+// payloadRule returns the first payload rule covering method in dir.
+func (l *LoggingInterceptor) payloadRule(dir, method string) *policy.PayloadStorage {
+	for i := range l.PayloadRules {
+		r := &l.PayloadRules[i]
+		if (r.Direction == "" || r.Direction == dir) && slices.Contains(r.Methods, method) {
+			return r
+		}
+	}
+	return nil
+}
+
+// flagged reports whether an earlier interceptor found the message
+// interesting enough that its payload is always kept.
+func flagged(e *store.LogEntry) bool {
+	return e.Blocked || e.Audit || e.ScrubCount > 0 || e.PolicyAction != "" ||
+		len(e.MatchedRules) > 0 || e.Unrecognized || e.ToolsPruned > 0
+}
+
+// waitForStore holds the caller, and so the message being forwarded,
+// until the store's buffer falls below the high-water mark or the
+// backpressure limit passes.
+func (l *LoggingInterceptor) waitForStore(ctx context.Context) {
+	if l.Backpressure <= 0 {
+		return
+	}
+	high := l.HighWater
+	if high <= 0 {
+		high = DefaultHighWater
+	}
+	if l.store.BufferFill() < high {
+		return
+	}
+
+	deadline := time.NewTimer(l.Backpressure)
+	defer deadline.Stop()
+	poll := time.NewTicker(backpressurePoll)
+	defer poll.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-deadline.C:
+			return
+		case <-poll.C:
+			if l.store.BufferFill() < high {
+				return
+			}
+		}
+	}
+}
+
+// redactToolArgs returns a copy of a tools/call message with the given
+// top-level argument values replaced. The original is returned unchanged
+// if it can't be parsed.
+func redactToolArgs(raw []byte, keys []string) []byte {
+	var msg map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		return raw
+	}
+	var params map[string]json.RawMessage
+	if err := json.Unmarshal(msg["params"], &params); err != nil {
+		return raw
+	}
+	var args map[string]json.RawMessage
+	if err := json.Unmarshal(params["arguments"], &args); err != nil || args == nil {
+		return raw
+	}
+
+	redacted := false
+	for _, k := range keys {
+		if _, ok := args[k]; ok {
+			args[k] = json.RawMessage(`"[REDACTED]"`)
+			redacted = true
+		}
+	}
+	if !redacted {
+		return raw
+	}
+
+	params["arguments"], _ = json.Marshal(args)
+	msg["params"], _ = json.Marshal(params)
+	out, err := json.Marshal(msg)
+	if err != nil {
+		return raw
+	}
+	return out
+}
+
+// maxControlRatio is the share of control characters above which a
+// payload is treated as binary.
+const maxControlRatio = 0.1
+
+// sanitizeForStorage returns a representation of b that is safe to store
+// and render as text. ok is false when b is binary (invalid UTF-8 or
+// dominated by control characters), in which case a placeholder is
+// returned instead.
+func sanitizeForStorage(b []byte) (string, bool) {
+	if !utf8.Valid(b) {
+		return binaryPlaceholder(len(b)), false
+	}
+
+	control := 0
+	for _, r := range string(b) {
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' || r == 0x7f {
+			control++
+		}
+	}
+	if len(b) > 0 && float64(control)/float64(utf8.RuneCount(b)) > maxControlRatio {
+		return binaryPlaceholder(len(b)), false
+	}
+	return string(b), true
+}
+
+func binaryPlaceholder(n int) string {
+	return fmt.Sprintf("[binary, %d bytes]", n)
+}
+
+// truncatePayload cuts s to at most max bytes, backing off to a rune
+// boundary, and appends a marker giving size, the message's original
+// length. s may differ from the message, as when it was redacted.
+func truncatePayload(s string, max, size int) string {
+	if len(s) <= max {
+		return s
+	}
+	cut := max
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + fmt.Sprintf("…[truncated, %d bytes]", size)
 }
@@ -0,0 +1,441 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/contextgate/contextgate/internal/eventbus"
+	"github.com/contextgate/contextgate/internal/policy"
+	"github.com/contextgate/contextgate/internal/store"
+)
+
+// mockLogStore captures logged entries.
+type mockLogStore struct {
+	store.Store // embed to satisfy interface (panics on unimplemented)
+	entries     []*store.LogEntry
+}
+
+func (m *mockLogStore) LogMessage(_ context.Context, entry *store.LogEntry) error {
+	m.entries = append(m.entries, entry)
+	return nil
+}
+
+func TestLoggingInterceptor_RedactArgs(t *testing.T) {
+	st := &mockLogStore{}
+	li := NewLoggingInterceptor(st, eventbus.New(10))
+	li.RedactArgs = map[string][]string{"auth_login": {"password", "otp"}}
+
+	raw := `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"auth_login","arguments":{"user":"alice","password":"hunter2"}}}`
+	msg := &InterceptedMessage{
+		Timestamp: time.Now(),
+		Direction: DirHostToServer,
+		RawBytes:  []byte(raw),
+	}
+	msg.Parsed, _ = ParseMessage(msg.RawBytes)
+
+	out, err := li.Intercept(context.Background(), msg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(out) != raw {
+		t.Errorf("forwarded bytes changed:\n got %s\nwant %s", out, raw)
+	}
+
+	if len(st.entries) != 1 {
+		t.Fatalf("got %d logged entries, want 1", len(st.entries))
+	}
+	stored := st.entries[0].Payload
+	if strings.Contains(stored, "hunter2") {
+		t.Errorf("stored payload not redacted: %s", stored)
+	}
+	if !strings.Contains(stored, `"password":"[REDACTED]"`) || !strings.Contains(stored, `"user":"alice"`) {
+		t.Errorf("stored payload = %s, want only password redacted", stored)
+	}
+	if st.entries[0].ToolName != "auth_login" {
+		t.Errorf("tool name = %q, want auth_login", st.entries[0].ToolName)
+	}
+}
+
+func TestLoggingInterceptor_RedactArgsOtherToolsUntouched(t *testing.T) {
+	st := &mockLogStore{}
+	li := NewLoggingInterceptor(st, eventbus.New(10))
+	li.RedactArgs = map[string][]string{"auth_login": {"password"}}
+
+	raw := `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"read_file","arguments":{"password":"not-a-secret-here"}}}`
+	msg := &InterceptedMessage{Timestamp: time.Now(), Direction: DirHostToServer, RawBytes: []byte(raw)}
+	msg.Parsed, _ = ParseMessage(msg.RawBytes)
+
+	li.Intercept(context.Background(), msg)
+	if st.entries[0].Payload != raw {
+		t.Errorf("payload for unlisted tool changed: %s", st.entries[0].Payload)
+	}
+}
+
+func TestSanitizeForStorage(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []byte
+		ok   bool
+	}{
+		{"json", []byte(`{"jsonrpc":"2.0","result":{"text":"héllo\n"}}`), true},
+		{"empty", []byte{}, true},
+		{"invalid utf8", []byte{'{', 0xff, 0xfe, 0x00, '}'}, false},
+		{"control heavy", []byte("a\x00\x01\x02\x03\x04b"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := sanitizeForStorage(tt.in)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if ok && got != string(tt.in) {
+				t.Errorf("text payload changed: %q", got)
+			}
+			if !ok && got != fmt.Sprintf("[binary, %d bytes]", len(tt.in)) {
+				t.Errorf("placeholder = %q", got)
+			}
+		})
+	}
+}
+
+func TestLoggingInterceptor_BinaryPayload(t *testing.T) {
+	raw := append([]byte(`{"jsonrpc":"2.0","id":1,"result":"`), 0xff, 0x00, 0xfe, '"', '}')
+
+	for _, asBase64 := range []bool{false, true} {
+		st := &mockLogStore{}
+		li := NewLoggingInterceptor(st, eventbus.New(10))
+		li.BinaryAsBase64 = asBase64
+
+		msg := &InterceptedMessage{Timestamp: time.Now(), Direction: DirServerToHost, RawBytes: raw}
+		out, _ := li.Intercept(context.Background(), msg)
+		if !bytes.Equal(out, raw) {
+			t.Errorf("base64=%v: forwarded bytes changed", asBase64)
+		}
+
+		stored := st.entries[0].Payload
+		if !utf8.ValidString(stored) {
+			t.Errorf("base64=%v: stored payload is not valid UTF-8: %q", asBase64, stored)
+		}
+		if asBase64 {
+			decoded, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(stored, "base64:"))
+			if err != nil || !bytes.Equal(decoded, raw) {
+				t.Errorf("base64 payload does not round-trip: %q", stored)
+			}
+		} else if stored != fmt.Sprintf("[binary, %d bytes]", len(raw)) {
+			t.Errorf("stored = %q, want placeholder", stored)
+		}
+		if st.entries[0].SizeBytes != len(raw) {
+			t.Errorf("size = %d, want %d", st.entries[0].SizeBytes, len(raw))
+		}
+	}
+}
+
+func TestSeqClock_StrictlyIncreasing(t *testing.T) {
+	var c seqClock
+	const workers, perWorker = 8, 1000
+
+	var mu sync.Mutex
+	seen := make(map[int64]bool, workers*perWorker)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			last := int64(0)
+			for i := 0; i < perWorker; i++ {
+				n := c.next(time.Now())
+				if n <= last {
+					t.Errorf("seq went backwards: %d after %d", n, last)
+				}
+				last = n
+				mu.Lock()
+				seen[n] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(seen) != workers*perWorker {
+		t.Errorf("got %d distinct seqs, want %d", len(seen), workers*perWorker)
+	}
+}
+
+func TestLoggingInterceptor_SeqFromInterception(t *testing.T) {
+	st := &mockLogStore{}
+	li := NewLoggingInterceptor(st, eventbus.New(10))
+
+	// Logged in reverse of interception order: the entry keeps the
+	// message's sequence number rather than getting a new one
+	first := &InterceptedMessage{Seq: 100, Timestamp: time.Now(), RawBytes: []byte(`{}`)}
+	second := &InterceptedMessage{Seq: 200, Timestamp: time.Now(), RawBytes: []byte(`{}`)}
+	li.Intercept(context.Background(), second)
+	li.Intercept(context.Background(), first)
+	if st.entries[0].Seq != 200 || st.entries[1].Seq != 100 {
+		t.Errorf("seqs = %d, %d, want 200, 100", st.entries[0].Seq, st.entries[1].Seq)
+	}
+
+	// Messages built without one still get a sequence number
+	li.Intercept(context.Background(), &InterceptedMessage{Timestamp: time.Now(), RawBytes: []byte(`{}`)})
+	if st.entries[2].Seq == 0 {
+		t.Error("expected a sequence number for a message without one")
+	}
+}
+
+// fillStore reports a settable buffer fill level.
+type fillStore struct {
+	mockLogStore
+	mu   sync.Mutex
+	fill float64
+}
+
+func (f *fillStore) BufferFill() float64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.fill
+}
+
+func (f *fillStore) setFill(v float64) {
+	f.mu.Lock()
+	f.fill = v
+	f.mu.Unlock()
+}
+
+func TestLoggingInterceptor_Backpressure(t *testing.T) {
+	msg := func() *InterceptedMessage {
+		raw := `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`
+		m := &InterceptedMessage{Timestamp: time.Now(), Direction: DirHostToServer, RawBytes: []byte(raw)}
+		m.Parsed, _ = ParseMessage(m.RawBytes)
+		return m
+	}
+	timed := func(li *LoggingInterceptor) time.Duration {
+		start := time.Now()
+		if _, err := li.Intercept(context.Background(), msg()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return time.Since(start)
+	}
+	const hold = 100 * time.Millisecond
+
+	t.Run("disabled", func(t *testing.T) {
+		st := &fillStore{fill: 1}
+		li := NewLoggingInterceptor(st, eventbus.New(10))
+		if d := timed(li); d >= hold {
+			t.Errorf("message held %v with backpressure off", d)
+		}
+	})
+
+	t.Run("below high water", func(t *testing.T) {
+		st := &fillStore{fill: 0.79}
+		li := NewLoggingInterceptor(st, eventbus.New(10))
+		li.Backpressure = hold
+		if d := timed(li); d >= hold {
+			t.Errorf("message held %v below the high-water mark", d)
+		}
+	})
+
+	t.Run("at high water", func(t *testing.T) {
+		st := &fillStore{fill: 0.8}
+		li := NewLoggingInterceptor(st, eventbus.New(10))
+		li.Backpressure = hold
+		if d := timed(li); d < hold {
+			t.Errorf("message held only %v at the high-water mark, want %v", d, hold)
+		}
+		if len(st.entries) != 1 {
+			t.Errorf("got %d logged entries after the hold, want 1", len(st.entries))
+		}
+	})
+
+	t.Run("custom high water", func(t *testing.T) {
+		st := &fillStore{fill: 0.6}
+		li := NewLoggingInterceptor(st, eventbus.New(10))
+		li.Backpressure = hold
+		li.HighWater = 0.5
+		if d := timed(li); d < hold {
+			t.Errorf("message held only %v above a 0.5 high-water mark", d)
+		}
+	})
+
+	t.Run("released when drained", func(t *testing.T) {
+		st := &fillStore{fill: 0.95}
+		li := NewLoggingInterceptor(st, eventbus.New(10))
+		li.Backpressure = 10 * time.Second
+		time.AfterFunc(20*time.Millisecond, func() { st.setFill(0.1) })
+		if d := timed(li); d >= time.Second {
+			t.Errorf("message held %v after the buffer drained", d)
+		}
+	})
+}
+
+func TestLoggingInterceptor_MaxPayloadBytes(t *testing.T) {
+	st := &mockLogStore{}
+	li := NewLoggingInterceptor(st, eventbus.New(10))
+	li.MaxPayloadBytes = 64
+
+	raw := `{"jsonrpc":"2.0","id":1,"result":{"content":[{"type":"text","text":"` + strings.Repeat("é", 100) + `"}]}}`
+	msg := &InterceptedMessage{Timestamp: time.Now(), Direction: DirServerToHost, RawBytes: []byte(raw)}
+	msg.Parsed, _ = ParseMessage(msg.RawBytes)
+
+	out, err := li.Intercept(context.Background(), msg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(out) != raw {
+		t.Error("forwarded bytes were truncated")
+	}
+
+	e := st.entries[0]
+	marker := fmt.Sprintf("…[truncated, %d bytes]", len(raw))
+	if !strings.HasSuffix(e.Payload, marker) {
+		t.Fatalf("stored payload = %q, want truncation marker %q", e.Payload, marker)
+	}
+	if kept := strings.TrimSuffix(e.Payload, marker); len(kept) > 64 || !strings.HasPrefix(raw, kept) {
+		t.Errorf("stored prefix = %q (%d bytes), want at most 64 bytes of the original", kept, len(kept))
+	}
+	if !utf8.ValidString(e.Payload) {
+		t.Error("truncation split a multi-byte character")
+	}
+	if e.SizeBytes != len(raw) {
+		t.Errorf("SizeBytes = %d, want original %d", e.SizeBytes, len(raw))
+	}
+
+	// Short payloads are stored whole
+	short := `{"jsonrpc":"2.0","id":2,"result":{}}`
+	msg = &InterceptedMessage{Timestamp: time.Now(), Direction: DirServerToHost, RawBytes: []byte(short)}
+	msg.Parsed, _ = ParseMessage(msg.RawBytes)
+	li.Intercept(context.Background(), msg)
+	if got := st.entries[1].Payload; got != short {
+		t.Errorf("short payload stored as %q", got)
+	}
+
+	// The marker gives the message's size, not that of the stored form
+	li.BinaryAsBase64 = true
+	binary := append([]byte(`{"jsonrpc":"2.0","id":3,"result":"`), bytes.Repeat([]byte{0xff}, 100)...)
+	li.Intercept(context.Background(), &InterceptedMessage{Timestamp: time.Now(), Direction: DirServerToHost, RawBytes: binary})
+	if got, marker := st.entries[2].Payload, fmt.Sprintf("…[truncated, %d bytes]", len(binary)); !strings.HasSuffix(got, marker) {
+		t.Errorf("base64 payload stored as %q, want truncation marker %q", got, marker)
+	}
+}
+
+func TestLoggingInterceptor_Arguments(t *testing.T) {
+	st := &mockLogStore{}
+	li := NewLoggingInterceptor(st, eventbus.New(10))
+	li.RedactArgs = map[string][]string{"read_file": {"token"}}
+
+	log := func(raw string) *store.LogEntry {
+		t.Helper()
+		msg := &InterceptedMessage{Timestamp: time.Now(), Direction: DirHostToServer, RawBytes: []byte(raw)}
+		msg.Parsed, _ = ParseMessage(msg.RawBytes)
+		if _, err := li.Intercept(context.Background(), msg); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return st.entries[len(st.entries)-1]
+	}
+
+	e := log(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"read_file","arguments":{"path":"/etc/passwd","token":"s3cret"}}}`)
+	if got, want := string(e.Arguments), `{"path":"/etc/passwd","token":"[REDACTED]"}`; got != want {
+		t.Errorf("arguments = %s, want %s", got, want)
+	}
+
+	if e := log(`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`); e.Arguments != nil {
+		t.Errorf("tools/list arguments = %s, want none", e.Arguments)
+	}
+	if e := log(`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"ping"}}`); e.Arguments != nil {
+		t.Errorf("call without arguments stored %s", e.Arguments)
+	}
+
+	// Arguments too large for the payload limit are left out, not cut
+	li.MaxPayloadBytes = 32
+	if e := log(`{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"write","arguments":{"content":"` + strings.Repeat("x", 64) + `"}}}`); e.Arguments != nil {
+		t.Errorf("oversized arguments stored as %s", e.Arguments)
+	}
+
+	// Nor are arguments of a payload stored as binary
+	li.MaxPayloadBytes = 0
+	raw := append([]byte(`{"jsonrpc":"2.0","id":5,"method":"tools/call","params":{"name":"write","arguments":{"content":"`), 0xff, 0xfe)
+	if e := log(string(append(raw, `"}}}`...))); e.Arguments != nil {
+		t.Errorf("binary arguments stored as %q", e.Arguments)
+	}
+}
+
+func TestLoggingInterceptor_PayloadRules(t *testing.T) {
+	st := &mockLogStore{}
+	li := NewLoggingInterceptor(st, eventbus.New(10))
+	li.PayloadRules = []policy.PayloadStorage{
+		{Methods: []string{"resources/read"}},
+		{Methods: []string{"notifications/progress"}, Direction: "server_to_host", Sample: 0.5},
+	}
+	li.random = func() float64 { return 0.7 } // above the 0.5 sample
+
+	log := func(dir Direction, raw string, meta map[string]any, blocked bool) *store.LogEntry {
+		t.Helper()
+		msg := &InterceptedMessage{Timestamp: time.Now(), Direction: dir, RawBytes: []byte(raw), Metadata: meta}
+		msg.Parsed, _ = ParseMessage(msg.RawBytes)
+		if blocked {
+			li.LogBlocked(context.Background(), msg, nil)
+		} else if _, err := li.Intercept(context.Background(), msg); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return st.entries[len(st.entries)-1]
+	}
+	elided := func(e *store.LogEntry) bool {
+		return e.Payload == fmt.Sprintf("[payload not stored, %d bytes]", e.SizeBytes)
+	}
+
+	read := `{"jsonrpc":"2.0","id":1,"method":"resources/read","params":{"uri":"file:///big"}}`
+	if e := log(DirHostToServer, read, nil, false); !elided(e) || e.Method != "resources/read" || e.SizeBytes != len(read) {
+		t.Errorf("resources/read stored as %+v, want metadata only", e)
+	}
+	if e := log(DirServerToHost, `{"jsonrpc":"2.0","id":1,"result":{"contents":[]}}`, nil, false); !elided(e) {
+		t.Errorf("response to resources/read stored as %q", e.Payload)
+	}
+	// Flagged messages keep their payload
+	blocked := `{"jsonrpc":"2.0","id":2,"method":"resources/read","params":{"uri":"file:///etc/shadow"}}`
+	if e := log(DirHostToServer, blocked, map[string]any{MetaKeyPolicyAction: "deny"}, true); e.Payload != blocked {
+		t.Errorf("blocked request stored as %q", e.Payload)
+	}
+	scrubbed := `{"jsonrpc":"2.0","id":3,"result":{"text":"[REDACTED:email]"}}`
+	log(DirHostToServer, `{"jsonrpc":"2.0","id":3,"method":"resources/read"}`, nil, false)
+	if e := log(DirServerToHost, scrubbed, map[string]any{MetaKeyScrubCount: 1}, false); e.Payload != scrubbed {
+		t.Errorf("scrubbed response stored as %q", e.Payload)
+	}
+
+	// Direction and sampling
+	progress := `{"jsonrpc":"2.0","method":"notifications/progress","params":{"progress":1}}`
+	if e := log(DirServerToHost, progress, nil, false); !elided(e) {
+		t.Errorf("unsampled progress stored as %q", e.Payload)
+	}
+	li.random = func() float64 { return 0.2 }
+	if e := log(DirServerToHost, progress, nil, false); e.Payload != progress {
+		t.Errorf("sampled progress stored as %q", e.Payload)
+	}
+	li.random = func() float64 { return 0.7 }
+	if e := log(DirHostToServer, progress, nil, false); e.Payload != progress {
+		t.Errorf("progress in the other direction stored as %q", e.Payload)
+	}
+	if e := log(DirHostToServer, `{"jsonrpc":"2.0","id":4,"method":"tools/list"}`, nil, false); elided(e) {
+		t.Error("unlisted method was elided")
+	}
+}
+
+func TestLoggingInterceptor_PayloadRulesForgetUnanswered(t *testing.T) {
+	li := NewLoggingInterceptor(&mockLogStore{}, eventbus.New(10))
+	li.PayloadRules = []policy.PayloadStorage{{Methods: []string{"resources/read"}}}
+
+	start := time.Now()
+	for i := range 3 {
+		raw := fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":"resources/read"}`, i)
+		msg := &InterceptedMessage{Timestamp: start.Add(time.Duration(i) * (pendingTTL + time.Second)), Direction: DirHostToServer, RawBytes: []byte(raw)}
+		msg.Parsed, _ = ParseMessage(msg.RawBytes)
+		li.Intercept(context.Background(), msg)
+	}
+	if li.sampled.has(hostKey("0")) || li.sampled.has(hostKey("1")) || !li.sampled.has(hostKey("2")) {
+		t.Error("unanswered requests kept past their TTL")
+	}
+}
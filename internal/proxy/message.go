@@ -13,6 +13,15 @@ const (
 	DirServerToHost Direction = "server_to_host"
 )
 
+// Reverse returns the opposite direction — the way a response travels
+// for a request sent in d.
+func (d Direction) Reverse() Direction {
+	if d == DirHostToServer {
+		return DirServerToHost
+	}
+	return DirHostToServer
+}
+
 // MessageKind classifies a JSON-RPC message.
 type MessageKind string
 
@@ -58,6 +67,7 @@ func (m *JSONRPCMessage) Kind() MessageKind {
 
 // InterceptedMessage wraps a raw JSON-RPC line with parsed metadata.
 type InterceptedMessage struct {
+	Seq       int64 // strictly increasing per proxy, assigned as the message is read
 	Timestamp time.Time
 	SessionID string
 	Direction Direction
@@ -65,6 +75,11 @@ type InterceptedMessage struct {
 	Parsed    JSONRPCMessage // minimal parse (may be zero-value if parse failed)
 	ParseErr  error          // non-nil if JSON parsing failed
 	Metadata  map[string]any // inter-interceptor communication (policy annotations, scrub counts, etc.)
+
+	// Cancelled is closed if the sender cancels this request with
+	// notifications/cancelled while it is still in the chain. It is nil
+	// for messages that are not requests.
+	Cancelled <-chan struct{}
 }
 
 // ParseMessage does a minimal parse of raw JSON-RPC bytes.
@@ -0,0 +1,144 @@
+package proxy
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultSizeBuckets are the upper bounds, in bytes, of the message size
+// histogram when none are configured.
+var DefaultSizeBuckets = []float64{256, 1024, 4096, 16384, 65536, 262144, 1048576, 4194304}
+
+// DefaultResponseLatencyBuckets are the upper bounds of the response
+// latency histogram when none are configured.
+var DefaultResponseLatencyBuckets = []time.Duration{
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+	30 * time.Second,
+	time.Minute,
+}
+
+// histogram counts observations into buckets with fixed upper bounds.
+type histogram struct {
+	count  uint64
+	sum    float64
+	counts []uint64 // per bucket, not cumulative; last is +Inf
+}
+
+func (h *histogram) observe(bounds []float64, v float64) {
+	if h.counts == nil {
+		h.counts = make([]uint64, len(bounds)+1)
+	}
+	h.count++
+	h.sum += v
+	h.counts[sort.SearchFloat64s(bounds, v)]++
+}
+
+func (h *histogram) snapshot(label string, bounds []float64) HistogramSnapshot {
+	snap := HistogramSnapshot{Label: label, Count: h.count, Sum: h.sum, Buckets: make([]uint64, len(bounds))}
+	var cum uint64
+	for i := range bounds {
+		if h.counts != nil {
+			cum += h.counts[i]
+		}
+		snap.Buckets[i] = cum
+	}
+	return snap
+}
+
+// HistogramSnapshot is one labelled histogram's accumulated observations.
+type HistogramSnapshot struct {
+	Label   string // the direction of the messages observed
+	Count   uint64
+	Sum     float64
+	Buckets []uint64 // cumulative count at or below each bound
+}
+
+// MessageMetrics keeps histograms of the size of every message the chain
+// processes and of the latency of every response, by direction, for
+// export in Prometheus format.
+type MessageMetrics struct {
+	sizeBounds    []float64 // bytes
+	latencyBounds []float64 // seconds
+
+	mu      sync.Mutex
+	sizes   map[Direction]*histogram
+	latency map[Direction]*histogram
+}
+
+// NewMessageMetrics creates histograms with the given bucket upper
+// bounds, which must be sorted. Nil bounds use the defaults.
+func NewMessageMetrics(sizeBuckets []float64, latencyBuckets []time.Duration) *MessageMetrics {
+	if sizeBuckets == nil {
+		sizeBuckets = DefaultSizeBuckets
+	}
+	if latencyBuckets == nil {
+		latencyBuckets = DefaultResponseLatencyBuckets
+	}
+	m := &MessageMetrics{
+		sizeBounds:    sizeBuckets,
+		latencyBounds: make([]float64, len(latencyBuckets)),
+		sizes:         make(map[Direction]*histogram),
+		latency:       make(map[Direction]*histogram),
+	}
+	for i, d := range latencyBuckets {
+		m.latencyBounds[i] = d.Seconds()
+	}
+	return m
+}
+
+// Observe records a message's size and, for a response, how long after
+// its request it arrived.
+func (m *MessageMetrics) Observe(msg *InterceptedMessage) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	h := m.sizes[msg.Direction]
+	if h == nil {
+		h = &histogram{}
+		m.sizes[msg.Direction] = h
+	}
+	h.observe(m.sizeBounds, float64(len(msg.RawBytes)))
+
+	if ms, ok := msg.Metadata[MetaKeyLatencyMs].(float64); ok {
+		h := m.latency[msg.Direction]
+		if h == nil {
+			h = &histogram{}
+			m.latency[msg.Direction] = h
+		}
+		h.observe(m.latencyBounds, ms/1000)
+	}
+}
+
+// SizeBuckets returns the size histogram's upper bounds in bytes.
+func (m *MessageMetrics) SizeBuckets() []float64 { return m.sizeBounds }
+
+// LatencyBuckets returns the latency histogram's upper bounds in seconds.
+func (m *MessageMetrics) LatencyBuckets() []float64 { return m.latencyBounds }
+
+// Sizes returns the message size histograms, sorted by direction.
+func (m *MessageMetrics) Sizes() []HistogramSnapshot {
+	return m.snapshot(m.sizes, m.sizeBounds)
+}
+
+// Latencies returns the response latency histograms, in seconds, sorted
+// by direction.
+func (m *MessageMetrics) Latencies() []HistogramSnapshot {
+	return m.snapshot(m.latency, m.latencyBounds)
+}
+
+func (m *MessageMetrics) snapshot(hists map[Direction]*histogram, bounds []float64) []HistogramSnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]HistogramSnapshot, 0, len(hists))
+	for dir, h := range hists {
+		out = append(out, h.snapshot(string(dir), bounds))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Label < out[j].Label })
+	return out
+}
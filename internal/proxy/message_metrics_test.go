@@ -0,0 +1,79 @@
+package proxy
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestMessageMetrics_Buckets(t *testing.T) {
+	m := NewMessageMetrics([]float64{100, 1000}, []time.Duration{10 * time.Millisecond, time.Second})
+
+	for _, size := range []int{10, 100, 500, 5000} {
+		m.Observe(&InterceptedMessage{Direction: DirHostToServer, RawBytes: make([]byte, size)})
+	}
+	m.Observe(&InterceptedMessage{Direction: DirServerToHost, RawBytes: make([]byte, 50),
+		Metadata: map[string]any{MetaKeyLatencyMs: float64(5)}})
+	m.Observe(&InterceptedMessage{Direction: DirServerToHost, RawBytes: make([]byte, 2000),
+		Metadata: map[string]any{MetaKeyLatencyMs: float64(250)}})
+
+	sizes := m.Sizes()
+	if len(sizes) != 2 {
+		t.Fatalf("got %d size histograms, want 2", len(sizes))
+	}
+	for _, tc := range []struct {
+		snap    HistogramSnapshot
+		label   string
+		buckets []uint64
+		count   uint64
+		sum     float64
+	}{
+		{sizes[0], string(DirHostToServer), []uint64{2, 3}, 4, 5610},
+		{sizes[1], string(DirServerToHost), []uint64{1, 1}, 2, 2050},
+	} {
+		if tc.snap.Label != tc.label || tc.snap.Count != tc.count || tc.snap.Sum != tc.sum {
+			t.Errorf("snapshot = %+v, want label %s, count %d, sum %g", tc.snap, tc.label, tc.count, tc.sum)
+		}
+		for i, want := range tc.buckets {
+			if tc.snap.Buckets[i] != want {
+				t.Errorf("%s bucket le=%g = %d, want %d", tc.label, m.SizeBuckets()[i], tc.snap.Buckets[i], want)
+			}
+		}
+	}
+
+	// Only responses carry a latency
+	lat := m.Latencies()
+	if len(lat) != 1 || lat[0].Label != string(DirServerToHost) {
+		t.Fatalf("latencies = %+v, want server→host only", lat)
+	}
+	if lat[0].Count != 2 || lat[0].Buckets[0] != 1 || lat[0].Buckets[1] != 2 || math.Abs(lat[0].Sum-0.255) > 1e-9 {
+		t.Errorf("latency snapshot = %+v, want buckets [1 2], sum 0.255s", lat[0])
+	}
+}
+
+func TestMessageMetrics_Defaults(t *testing.T) {
+	m := NewMessageMetrics(nil, nil)
+	if len(m.SizeBuckets()) != len(DefaultSizeBuckets) || len(m.LatencyBuckets()) != len(DefaultResponseLatencyBuckets) {
+		t.Errorf("bounds = %v / %v, want the defaults", m.SizeBuckets(), m.LatencyBuckets())
+	}
+	if m.LatencyBuckets()[0] != 0.005 {
+		t.Errorf("first latency bound = %g, want 0.005 seconds", m.LatencyBuckets()[0])
+	}
+}
+
+func TestInterceptorChain_ObservesMessages(t *testing.T) {
+	drop := InterceptorFunc(func(context.Context, *InterceptedMessage) ([]byte, error) { return nil, nil })
+	chain := NewInterceptorChain(drop)
+	chain.Messages = NewMessageMetrics(nil, nil)
+
+	raw := `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`
+	if _, err := chain.Process(context.Background(), parsedMessage(t, DirHostToServer, raw)); err != nil {
+		t.Fatal(err)
+	}
+	// Dropped messages are still counted, at their size on arrival
+	sizes := chain.Messages.Sizes()
+	if len(sizes) != 1 || sizes[0].Count != 1 || sizes[0].Sum != float64(len(raw)) {
+		t.Errorf("sizes = %+v, want one %d-byte message", sizes, len(raw))
+	}
+}
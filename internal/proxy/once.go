@@ -0,0 +1,61 @@
+package proxy
+
+import (
+	"sync"
+	"time"
+)
+
+// onceGrace is how long the downstream gets to exit on its own after its
+// stdin is closed in once mode before it is killed.
+const onceGrace = 2 * time.Second
+
+// onceState tracks the single exchange proxied in Config.Once mode: the
+// first host request, and then its response (or the error that answered
+// it in the server's place).
+type onceState struct {
+	mu        sync.Mutex
+	key       corrKey
+	sent      bool
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newOnceState() *onceState {
+	return &onceState{done: make(chan struct{})}
+}
+
+// request records the host request whose response ends the session.
+func (o *onceState) request(key corrKey) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.key = key
+	o.sent = true
+}
+
+// awaiting reports whether a request was sent and is still unanswered.
+func (o *onceState) awaiting() bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.sent && !o.finished()
+}
+
+// answers reports whether a response with key answers the recorded request.
+func (o *onceState) answers(key corrKey) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.sent && o.key == key
+}
+
+// finish marks the exchange complete. Safe to call more than once.
+func (o *onceState) finish() {
+	o.closeOnce.Do(func() { close(o.done) })
+}
+
+func (o *onceState) finished() bool {
+	select {
+	case <-o.done:
+		return true
+	default:
+		return false
+	}
+}
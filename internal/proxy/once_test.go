@@ -0,0 +1,127 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// runOnce runs a once-mode proxy in front of a shell-script server and
+// returns what the host received.
+func runOnce(t *testing.T, script, hostInput string, chain *InterceptorChain) string {
+	t.Helper()
+	return runOnceConfig(t, Config{}, script, hostInput, chain)
+}
+
+// runOnceConfig is runOnce with further proxy settings in cfg.
+func runOnceConfig(t *testing.T, cfg Config, script, hostInput string, chain *InterceptorChain) string {
+	t.Helper()
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not available")
+	}
+
+	cfg.Command, cfg.Args, cfg.Once = sh, []string{"-c", script}, true
+	p := NewProxy(cfg, chain, testLogger())
+	host := &syncBuffer{}
+	p.hostIn = strings.NewReader(hostInput)
+	p.hostOut = host
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := p.Run(ctx); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if ctx.Err() != nil {
+		t.Fatal("proxy did not exit after the exchange")
+	}
+	return host.String()
+}
+
+func TestProxy_OnceExitsAfterResponse(t *testing.T) {
+	// The server reads the notification and the request, answers, then
+	// echoes anything else it reads so a leaked second request would show
+	// up on the host side
+	script := `read n; read req; echo '{"jsonrpc":"2.0","id":1,"result":{"ok":true}}'; cat`
+	input := `{"jsonrpc":"2.0","method":"notifications/initialized"}` + "\n" +
+		toolsCallLine(1) + toolsCallLine(2)
+
+	out := runOnce(t, script, input, NewInterceptorChain())
+
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 1 || !strings.Contains(lines[0], `"ok":true`) {
+		t.Fatalf("host got %q, want exactly the one response", out)
+	}
+}
+
+func TestProxy_OnceBlockedRequest(t *testing.T) {
+	deny := InterceptorFunc(func(_ context.Context, msg *InterceptedMessage) ([]byte, error) {
+		if msg.Parsed.Method == "tools/call" {
+			return nil, errors.New("denied by policy")
+		}
+		return msg.RawBytes, nil
+	})
+
+	// The server never answers; the proxy's error is the response
+	out := runOnce(t, `cat >/dev/null`, toolsCallLine(7), NewInterceptorChain(deny))
+
+	if !strings.Contains(out, `"id":7`) || !strings.Contains(out, "denied by policy") {
+		t.Fatalf("host got %q, want the block error", out)
+	}
+}
+
+func TestProxy_CRLFLines(t *testing.T) {
+	var seen [][]byte
+	var mu sync.Mutex
+	record := InterceptorFunc(func(_ context.Context, msg *InterceptedMessage) ([]byte, error) {
+		mu.Lock()
+		seen = append(seen, msg.RawBytes)
+		mu.Unlock()
+		return msg.RawBytes, nil
+	})
+
+	// The server answers with a CRLF line whose result holds an escaped CR
+	script := `read req; printf '%s\r\n' '{"jsonrpc":"2.0","id":1,"result":{"text":"a\r\nb"}}'`
+	out := runOnce(t, script, strings.TrimSuffix(toolsCallLine(1), "\n")+" \r\n", NewInterceptorChain(record))
+
+	want := `{"jsonrpc":"2.0","id":1,"result":{"text":"a\r\nb"}}` + "\n"
+	if out != want {
+		t.Errorf("host got %q, want %q", out, want)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(seen) != 2 {
+		t.Fatalf("chain saw %d messages, want 2", len(seen))
+	}
+	for _, raw := range seen {
+		if last := raw[len(raw)-1]; last != '}' {
+			t.Errorf("message %q kept its line terminator", raw)
+		}
+	}
+}
+
+func TestProxy_OnMalformed(t *testing.T) {
+	// The server writes a broken line before its answer, then stays up
+	// until the proxy closes its stdin so none of its output is lost
+	script := `read req; echo '{"jsonrpc":"2.0","id":1,"result":'; echo '{"jsonrpc":"2.0","id":1,"result":{}}'; cat >/dev/null`
+	answer := `{"jsonrpc":"2.0","id":1,"result":{}}` + "\n"
+	tests := []struct {
+		mode MalformedMode
+		want string
+	}{
+		{"", `{"jsonrpc":"2.0","id":1,"result":` + "\n" + answer},
+		{MalformedForward, `{"jsonrpc":"2.0","id":1,"result":` + "\n" + answer},
+		{MalformedDrop, answer},
+		{MalformedError, `{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"invalid JSON-RPC from server: unexpected end of JSON input"}}` + "\n" + answer},
+	}
+	for _, tt := range tests {
+		out := runOnceConfig(t, Config{OnMalformed: tt.mode}, script, toolsCallLine(1), NewInterceptorChain())
+		if out != tt.want {
+			t.Errorf("mode %q: host got %q, want %q", tt.mode, out, tt.want)
+		}
+	}
+}
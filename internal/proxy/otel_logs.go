@@ -0,0 +1,314 @@
+package proxy
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/contextgate/contextgate/internal/store"
+)
+
+// Event names of the log records SecurityEvents emits.
+const (
+	EventPolicyDecision   = "contextgate.policy.decision"
+	EventScrub            = "contextgate.scrub"
+	EventApprovalResolved = "contextgate.approval.resolved"
+)
+
+// OpenTelemetry severity numbers used for security events.
+const (
+	SeverityInfo = 9
+	SeverityWarn = 13
+)
+
+// LogRecord is one OpenTelemetry log record. Attribute values are
+// strings, bools, ints, float64s or string slices.
+type LogRecord struct {
+	Time       time.Time
+	EventName  string
+	Severity   int
+	Body       string
+	Attributes map[string]any
+}
+
+// LogExporter delivers batches of log records to an observability backend.
+type LogExporter interface {
+	ExportLogs(ctx context.Context, records []LogRecord) error
+}
+
+// OTLPLogExporter sends log records to an OpenTelemetry collector over
+// OTLP/HTTP with JSON encoding.
+type OTLPLogExporter struct {
+	Webhook
+}
+
+// NewOTLPLogExporter creates an exporter for a collector's OTLP/HTTP
+// endpoint, such as http://localhost:4318. The /v1/logs path is added
+// unless the endpoint already ends with it.
+func NewOTLPLogExporter(endpoint string) *OTLPLogExporter {
+	url := strings.TrimSuffix(endpoint, "/")
+	if !strings.HasSuffix(url, "/v1/logs") {
+		url += "/v1/logs"
+	}
+	return &OTLPLogExporter{Webhook{URL: url}}
+}
+
+func (x *OTLPLogExporter) ExportLogs(ctx context.Context, records []LogRecord) error {
+	return x.Post(ctx, otlpLogsRequest(records))
+}
+
+// otlpValue encodes v as an OTLP AnyValue.
+func otlpValue(v any) map[string]any {
+	switch v := v.(type) {
+	case bool:
+		return map[string]any{"boolValue": v}
+	case int:
+		return map[string]any{"intValue": strconv.Itoa(v)}
+	case int64:
+		return map[string]any{"intValue": strconv.FormatInt(v, 10)}
+	case float64:
+		return map[string]any{"doubleValue": v}
+	case []string:
+		values := make([]map[string]any, len(v))
+		for i, s := range v {
+			values[i] = otlpValue(s)
+		}
+		return map[string]any{"arrayValue": map[string]any{"values": values}}
+	default:
+		s, _ := v.(string)
+		return map[string]any{"stringValue": s}
+	}
+}
+
+func otlpAttributes(attrs map[string]any) []map[string]any {
+	out := make([]map[string]any, 0, len(attrs))
+	for k, v := range attrs {
+		out = append(out, map[string]any{"key": k, "value": otlpValue(v)})
+	}
+	return out
+}
+
+// otlpLogsRequest builds an ExportLogsServiceRequest in OTLP's JSON form.
+func otlpLogsRequest(records []LogRecord) map[string]any {
+	logRecords := make([]map[string]any, len(records))
+	for i, r := range records {
+		logRecords[i] = map[string]any{
+			"timeUnixNano":   strconv.FormatInt(r.Time.UnixNano(), 10),
+			"severityNumber": r.Severity,
+			"severityText":   severityText(r.Severity),
+			"eventName":      r.EventName,
+			"body":           otlpValue(r.Body),
+			"attributes":     otlpAttributes(r.Attributes),
+		}
+	}
+	return map[string]any{
+		"resourceLogs": []map[string]any{{
+			"resource": map[string]any{
+				"attributes": otlpAttributes(map[string]any{"service.name": "contextgate"}),
+			},
+			"scopeLogs": []map[string]any{{
+				"scope":      map[string]any{"name": "contextgate"},
+				"logRecords": logRecords,
+			}},
+		}},
+	}
+}
+
+func severityText(n int) string {
+	if n >= SeverityWarn {
+		return "WARN"
+	}
+	return "INFO"
+}
+
+// Batching for SecurityEvents.
+const (
+	eventQueueSize     = 256
+	eventBatchSize     = 64
+	eventFlushInterval = time.Second
+	eventFlushTimeout  = 5 * time.Second
+)
+
+// SecurityEvents emits a log record for each policy decision, scrub and
+// approval resolution and exports them in batches from Run, so a slow
+// backend never holds up the proxy. Records that don't fit in the queue
+// are dropped with a warning. A nil *SecurityEvents does nothing, so the
+// hooks can be wired whether or not export is enabled.
+type SecurityEvents struct {
+	exporter LogExporter
+	logger   *slog.Logger
+	queue    chan LogRecord
+}
+
+func NewSecurityEvents(exporter LogExporter, logger *slog.Logger) *SecurityEvents {
+	return &SecurityEvents{
+		exporter: exporter,
+		logger:   logger,
+		queue:    make(chan LogRecord, eventQueueSize),
+	}
+}
+
+// Message emits the decisions recorded for a logged message: a policy
+// decision when a rule acted on it or it was blocked (blockErr set), and a
+// scrub when values were redacted.
+func (e *SecurityEvents) Message(msg *InterceptedMessage, entry *store.LogEntry, blockErr error) {
+	if e == nil {
+		return
+	}
+	attrs := map[string]any{
+		"contextgate.session.id": entry.SessionID,
+		"contextgate.direction":  entry.Direction,
+		"contextgate.method":     entry.Method,
+	}
+	if entry.ToolName != "" {
+		attrs["contextgate.tool.name"] = entry.ToolName
+	}
+	if entry.MsgID != "" {
+		attrs["contextgate.message.id"] = entry.MsgID
+	}
+
+	if entry.PolicyAction != "" || blockErr != nil {
+		action := entry.PolicyAction
+		if action == "" {
+			action = "block"
+		}
+		decision := map[string]any{
+			"contextgate.policy.action": action,
+			"contextgate.blocked":       entry.Blocked,
+		}
+		if rule, _ := msg.Metadata[MetaKeyPolicyRule].(string); rule != "" {
+			decision["contextgate.policy.rule"] = rule
+		}
+		if len(entry.MatchedRules) > 0 {
+			decision["contextgate.policy.matched_rules"] = entry.MatchedRules
+		}
+		body := "policy " + action + " " + entry.Method
+		severity := SeverityInfo
+		if blockErr != nil {
+			decision["contextgate.reason"] = blockErr.Error()
+			body = "blocked " + entry.Method + ": " + blockErr.Error()
+			severity = SeverityWarn
+		}
+		e.emit(LogRecord{
+			Time:       entry.Timestamp,
+			EventName:  EventPolicyDecision,
+			Severity:   severity,
+			Body:       body,
+			Attributes: withAttrs(attrs, decision),
+		})
+	}
+
+	if entry.ScrubCount > 0 {
+		e.emit(LogRecord{
+			Time:       entry.Timestamp,
+			EventName:  EventScrub,
+			Severity:   SeverityInfo,
+			Body:       "scrubbed " + strconv.Itoa(entry.ScrubCount) + " value(s) from " + entry.Method,
+			Attributes: withAttrs(attrs, map[string]any{"contextgate.scrub.count": entry.ScrubCount}),
+		})
+	}
+}
+
+// ApprovalResolved emits the outcome of an approval request. It is meant
+// for ApprovalManager.OnResolve.
+func (e *SecurityEvents) ApprovalResolved(req *ApprovalRequest) {
+	if e == nil {
+		return
+	}
+	at := time.Now()
+	if req.DecidedAt != nil {
+		at = *req.DecidedAt
+	}
+	severity := SeverityInfo
+	if req.Decision != DecisionApproved.String() {
+		severity = SeverityWarn
+	}
+	attrs := map[string]any{
+		"contextgate.session.id":        req.SessionID,
+		"contextgate.direction":         req.Direction,
+		"contextgate.method":            req.Method,
+		"contextgate.approval.id":       req.ID,
+		"contextgate.approval.decision": req.Decision,
+		"contextgate.approval.wait_ms":  at.Sub(req.Timestamp).Milliseconds(),
+	}
+	if req.Reason != "" {
+		attrs["contextgate.approval.reason"] = req.Reason
+	}
+	if req.ToolName != "" {
+		attrs["contextgate.tool.name"] = req.ToolName
+	}
+	if req.RuleName != "" {
+		attrs["contextgate.policy.rule"] = req.RuleName
+	}
+	e.emit(LogRecord{
+		Time:       at,
+		EventName:  EventApprovalResolved,
+		Severity:   severity,
+		Body:       "approval " + req.ID + " " + req.Decision,
+		Attributes: attrs,
+	})
+}
+
+func withAttrs(base, extra map[string]any) map[string]any {
+	out := make(map[string]any, len(base)+len(extra))
+	for k, v := range base {
+		out[k] = v
+	}
+	for k, v := range extra {
+		out[k] = v
+	}
+	return out
+}
+
+func (e *SecurityEvents) emit(r LogRecord) {
+	select {
+	case e.queue <- r:
+	default:
+		e.logger.Warn("security event queue full, dropping event", "event", r.EventName)
+	}
+}
+
+// Run exports queued records until ctx is done, then exports what is
+// left.
+func (e *SecurityEvents) Run(ctx context.Context) {
+	ticker := time.NewTicker(eventFlushInterval)
+	defer ticker.Stop()
+
+	var batch []LogRecord
+	export := func(ctx context.Context) {
+		if len(batch) == 0 {
+			return
+		}
+		if err := e.exporter.ExportLogs(ctx, batch); err != nil {
+			e.logger.Warn("security event export failed", "records", len(batch), "error", err)
+		}
+		batch = nil
+	}
+
+	for {
+		select {
+		case r := <-e.queue:
+			batch = append(batch, r)
+			if len(batch) >= eventBatchSize {
+				export(ctx)
+			}
+		case <-ticker.C:
+			export(ctx)
+		case <-ctx.Done():
+			for drained := false; !drained; {
+				select {
+				case r := <-e.queue:
+					batch = append(batch, r)
+				default:
+					drained = true
+				}
+			}
+			flushCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), eventFlushTimeout)
+			export(flushCtx)
+			cancel()
+			return
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/contextgate/contextgate/internal/eventbus"
+	"github.com/contextgate/contextgate/internal/policy"
+)
+
+// memoryLogExporter keeps exported records in memory.
+type memoryLogExporter struct {
+	mu      sync.Mutex
+	records []LogRecord
+}
+
+func (m *memoryLogExporter) ExportLogs(_ context.Context, records []LogRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.records = append(m.records, records...)
+	return nil
+}
+
+// drain stops Run and returns everything it exported.
+func drain(t *testing.T, exp *memoryLogExporter, cancel context.CancelFunc, done <-chan struct{}) []LogRecord {
+	t.Helper()
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run did not return")
+	}
+	exp.mu.Lock()
+	defer exp.mu.Unlock()
+	return exp.records
+}
+
+func startEvents(exp LogExporter) (*SecurityEvents, context.CancelFunc, <-chan struct{}) {
+	events := NewSecurityEvents(exp, testLogger())
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		events.Run(ctx)
+		close(done)
+	}()
+	return events, cancel, done
+}
+
+func TestSecurityEvents_PolicyDeny(t *testing.T) {
+	cfg, err := policy.LoadBytes([]byte(`
+rules:
+  - name: block-shell
+    action: deny
+    tools: ["run_shell"]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := &memoryLogExporter{}
+	events, cancel, done := startEvents(exp)
+
+	li := NewLoggingInterceptor(&mockLogStore{}, eventbus.New(10))
+	li.Events = events
+	chain := NewInterceptorChain(NewPolicyInterceptor(policy.NewEngine(cfg)), li)
+	chain.OnBlock = li.LogBlocked
+
+	ctx := context.Background()
+	msg := parsedMessage(t, DirHostToServer, `{"jsonrpc":"2.0","id":7,"method":"tools/call","params":{"name":"run_shell"}}`)
+	msg.SessionID = "s1"
+	if _, err := chain.Process(ctx, msg); err == nil {
+		t.Fatal("expected the call to be blocked")
+	}
+	// Forwarded without a decision: no record
+	chain.Process(ctx, parsedMessage(t, DirHostToServer, `{"jsonrpc":"2.0","id":8,"method":"tools/call","params":{"name":"read_file"}}`))
+
+	records := drain(t, exp, cancel, done)
+	if len(records) != 1 {
+		t.Fatalf("got %d records, want 1: %+v", len(records), records)
+	}
+	r := records[0]
+	if r.EventName != EventPolicyDecision || r.Severity != SeverityWarn {
+		t.Errorf("record = %+v", r)
+	}
+	for key, want := range map[string]any{
+		"contextgate.policy.rule":   "block-shell",
+		"contextgate.policy.action": "deny",
+		"contextgate.blocked":       true,
+		"contextgate.tool.name":     "run_shell",
+		"contextgate.session.id":    "s1",
+	} {
+		if got := r.Attributes[key]; got != want {
+			t.Errorf("%s = %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestSecurityEvents_ScrubAndApproval(t *testing.T) {
+	exp := &memoryLogExporter{}
+	events, cancel, done := startEvents(exp)
+
+	li := NewLoggingInterceptor(&mockLogStore{}, eventbus.New(10))
+	li.Events = events
+	msg := parsedMessage(t, DirServerToHost, `{"jsonrpc":"2.0","id":1,"result":{}}`)
+	msg.Metadata = map[string]any{MetaKeyScrubCount: 2}
+	li.Intercept(context.Background(), msg)
+
+	am := NewApprovalManager(time.Minute)
+	am.OnResolve = events.ApprovalResolved
+	am.Submit(&ApprovalRequest{Timestamp: time.Now(), SessionID: "s1", Method: "tools/call", ToolName: "deploy", RuleName: "review-deploys"})
+	if err := am.Resolve("apr-1", false); err != nil {
+		t.Fatal(err)
+	}
+
+	records := drain(t, exp, cancel, done)
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want 2: %+v", len(records), records)
+	}
+	if r := records[0]; r.EventName != EventScrub || r.Attributes["contextgate.scrub.count"] != 2 {
+		t.Errorf("scrub record = %+v", r)
+	}
+	r := records[1]
+	if r.EventName != EventApprovalResolved || r.Severity != SeverityWarn ||
+		r.Attributes["contextgate.approval.decision"] != "denied" ||
+		r.Attributes["contextgate.policy.rule"] != "review-deploys" {
+		t.Errorf("approval record = %+v", r)
+	}
+}
+
+func TestSecurityEvents_NilIsNoop(t *testing.T) {
+	var events *SecurityEvents
+	li := NewLoggingInterceptor(&mockLogStore{}, eventbus.New(10))
+	li.Events = events
+	li.LogBlocked(context.Background(), parsedMessage(t, DirHostToServer, `{"jsonrpc":"2.0","id":1,"method":"tools/call"}`), nil)
+	events.ApprovalResolved(&ApprovalRequest{})
+}
+
+func TestOTLPLogExporter(t *testing.T) {
+	got := make(chan map[string]any, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/logs" {
+			t.Errorf("path = %q", r.URL.Path)
+		}
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		got <- body
+	}))
+	defer srv.Close()
+
+	exp := NewOTLPLogExporter(srv.URL + "/")
+	err := exp.ExportLogs(context.Background(), []LogRecord{{
+		Time:       time.Unix(1, 0),
+		EventName:  EventPolicyDecision,
+		Severity:   SeverityWarn,
+		Body:       "blocked",
+		Attributes: map[string]any{"contextgate.policy.rule": "block-shell", "contextgate.scrub.count": 3},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := <-got
+	logs := body["resourceLogs"].([]any)[0].(map[string]any)["scopeLogs"].([]any)[0].(map[string]any)["logRecords"].([]any)
+	rec := logs[0].(map[string]any)
+	if rec["timeUnixNano"] != "1000000000" || rec["severityText"] != "WARN" || rec["eventName"] != EventPolicyDecision {
+		t.Errorf("record = %v", rec)
+	}
+	attrs := map[string]any{}
+	for _, a := range rec["attributes"].([]any) {
+		kv := a.(map[string]any)
+		attrs[kv["key"].(string)] = kv["value"]
+	}
+	if v := attrs["contextgate.policy.rule"].(map[string]any); v["stringValue"] != "block-shell" {
+		t.Errorf("rule attribute = %v", v)
+	}
+	if v := attrs["contextgate.scrub.count"].(map[string]any); v["intValue"] != "3" {
+		t.Errorf("count attribute = %v", v)
+	}
+}
@@ -0,0 +1,84 @@
+package proxy
+
+import (
+	"context"
+	"sync"
+)
+
+// pauseGate holds host→server messages while the proxy is paused.
+type pauseGate struct {
+	mu     sync.Mutex
+	resume chan struct{} // non-nil while paused; closed on resume
+}
+
+// pause closes the gate and reports whether it was open.
+func (g *pauseGate) pause() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.resume != nil {
+		return false
+	}
+	g.resume = make(chan struct{})
+	return true
+}
+
+// unpause opens the gate and reports whether it was closed.
+func (g *pauseGate) unpause() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.resume == nil {
+		return false
+	}
+	close(g.resume)
+	g.resume = nil
+	return true
+}
+
+func (g *pauseGate) paused() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.resume != nil
+}
+
+// wait blocks while the gate is closed.
+func (g *pauseGate) wait(ctx context.Context) error {
+	g.mu.Lock()
+	resume := g.resume
+	g.mu.Unlock()
+	if resume == nil {
+		return nil
+	}
+	select {
+	case <-resume:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Pause holds host→server messages, before they reach the interceptor
+// chain, until Resume. Server→host traffic keeps flowing so requests
+// already forwarded can complete. It reports whether the proxy was
+// running.
+func (p *Proxy) Pause() bool {
+	if !p.pause.pause() {
+		return false
+	}
+	p.logger.Info("proxy paused")
+	return true
+}
+
+// Resume releases messages held by Pause, in arrival order, and reports
+// whether the proxy was paused.
+func (p *Proxy) Resume() bool {
+	if !p.pause.unpause() {
+		return false
+	}
+	p.logger.Info("proxy resumed")
+	return true
+}
+
+// Paused reports whether host→server messages are being held.
+func (p *Proxy) Paused() bool {
+	return p.pause.paused()
+}
@@ -0,0 +1,48 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProxy_PauseHoldsHostMessages(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not available")
+	}
+
+	// The server echoes whatever it reads back to the host
+	p := NewProxy(Config{Command: sh, Args: []string{"-c", "cat"}}, NewInterceptorChain(), testLogger())
+	hostIn, hostWriter := io.Pipe()
+	host := &syncBuffer{}
+	p.hostIn = hostIn
+	p.hostOut = host
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- p.Run(ctx) }()
+
+	if !p.Pause() || p.Pause() || !p.Paused() {
+		t.Fatal("Pause should report only the first call as a change")
+	}
+	go hostWriter.Write([]byte(toolsCallLine(1)))
+	time.Sleep(100 * time.Millisecond)
+	if got := host.String(); got != "" {
+		t.Fatalf("host got %q while paused", got)
+	}
+
+	if !p.Resume() || p.Resume() || p.Paused() {
+		t.Fatal("Resume should report only the first call as a change")
+	}
+	waitFor(t, func() bool { return strings.Contains(host.String(), `"id":1`) })
+
+	hostWriter.Close()
+	if err := <-done; err != nil {
+		t.Errorf("Run: %v", err)
+	}
+}
@@ -0,0 +1,99 @@
+package proxy
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Interceptor names accepted in a pipeline config.
+const (
+	StageBypass        = "bypass"
+	StageEnvelope      = "envelope"
+	StageFreeze        = "freeze"
+	StagePolicy        = "policy"
+	StageCallBudget    = "call-budget"
+	StageExfilLimit    = "exfil-limit"
+	StageEchoGuard     = "echo-guard"
+	StageScrub         = "scrub"
+	StageApproval      = "approval"
+	StageRewrite       = "rewrite"
+	StageToolNotice    = "tool-notice"
+	StageToolAnalytics = "tool-analytics"
+	StageToolHints     = "tool-hints"
+	StageUnknownMethod = "unknown-method"
+	StageTokenEstimate = "token-estimate"
+	StageLogging       = "logging"
+)
+
+// DefaultPipeline is the interceptor order used when none is configured.
+// Bypass comes first so the methods it lets through skip everything else,
+// then envelope checks so later stages see repaired messages. A change
+// freeze comes before policy so it holds whatever the rules say.
+// Approval relies on metadata set by policy, so policy should precede it.
+// The call budget follows policy so denied calls don't use it up, and the
+// exfil limit and echo guard precede approval so they can hold calls for
+// review; the echo guard also precedes scrub to see secrets unredacted. Tool
+// hints follow tool analytics so the registry keeps the descriptions the
+// server sent.
+var DefaultPipeline = []string{
+	StageBypass,
+	StageEnvelope,
+	StageFreeze,
+	StagePolicy,
+	StageCallBudget,
+	StageExfilLimit,
+	StageEchoGuard,
+	StageScrub,
+	StageApproval,
+	StageRewrite,
+	StageToolNotice,
+	StageToolAnalytics,
+	StageToolHints,
+	StageUnknownMethod,
+	StageTokenEstimate,
+	StageLogging,
+}
+
+// BuildPipeline orders interceptors by name. order must contain only
+// known stage names, each at most once, and must end with logging so the
+// log records each message's final state. Stages listed in order but
+// missing from stages (for example policy when no rules are loaded) are
+// skipped. Stages left out of order are disabled and returned as omitted,
+// in default order, so callers can warn: an order written before a stage
+// existed would otherwise lose it without notice.
+func BuildPipeline(order []string, stages map[string]Interceptor) (interceptors []Interceptor, omitted []string, err error) {
+	if len(order) == 0 {
+		order = DefaultPipeline
+	}
+
+	known := make(map[string]bool, len(DefaultPipeline))
+	for _, name := range DefaultPipeline {
+		known[name] = true
+	}
+
+	seen := make(map[string]bool, len(order))
+	for i, name := range order {
+		if !known[name] {
+			return nil, nil, fmt.Errorf("pipeline: unknown interceptor %q (want one of %s)", name, strings.Join(DefaultPipeline, ", "))
+		}
+		if seen[name] {
+			return nil, nil, fmt.Errorf("pipeline: interceptor %q listed more than once", name)
+		}
+		seen[name] = true
+		if name == StageLogging && i != len(order)-1 {
+			return nil, nil, fmt.Errorf("pipeline: %q must be the last interceptor", StageLogging)
+		}
+		if ic, ok := stages[name]; ok && ic != nil {
+			interceptors = append(interceptors, ic)
+		}
+	}
+	if !seen[StageLogging] {
+		return nil, nil, fmt.Errorf("pipeline: %q is required", StageLogging)
+	}
+	for _, name := range DefaultPipeline {
+		if ic, ok := stages[name]; ok && ic != nil && !seen[name] {
+			omitted = append(omitted, name)
+		}
+	}
+	return interceptors, omitted, nil
+}
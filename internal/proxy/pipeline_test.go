@@ -0,0 +1,108 @@
+package proxy
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// namedInterceptor appends its name to the message metadata so tests can
+// observe the order interceptors ran in.
+type namedInterceptor string
+
+func (n namedInterceptor) Intercept(_ context.Context, msg *InterceptedMessage) ([]byte, error) {
+	if msg.Metadata == nil {
+		msg.Metadata = make(map[string]any)
+	}
+	seen, _ := msg.Metadata["order"].([]string)
+	msg.Metadata["order"] = append(seen, string(n))
+	return msg.RawBytes, nil
+}
+
+func testStages() map[string]Interceptor {
+	stages := make(map[string]Interceptor)
+	for _, name := range DefaultPipeline {
+		stages[name] = namedInterceptor(name)
+	}
+	return stages
+}
+
+func runOrder(t *testing.T, interceptors []Interceptor) string {
+	t.Helper()
+	msg := &InterceptedMessage{RawBytes: []byte(`{}`)}
+	if _, err := NewInterceptorChain(interceptors...).Process(context.Background(), msg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	order, _ := msg.Metadata["order"].([]string)
+	return strings.Join(order, ",")
+}
+
+func TestBuildPipeline(t *testing.T) {
+	tests := []struct {
+		name   string
+		order  []string
+		stages map[string]Interceptor
+		want   string
+	}{
+		{"default", nil, testStages(), strings.Join(DefaultPipeline, ",")},
+		{"reordered", []string{"scrub", "policy", "logging"}, testStages(), "scrub,policy,logging"},
+		{"missing stage skipped", []string{"policy", "scrub", "logging"},
+			map[string]Interceptor{"scrub": namedInterceptor("scrub"), "logging": namedInterceptor("logging")},
+			"scrub,logging"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			interceptors, _, err := BuildPipeline(tt.order, tt.stages)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := runOrder(t, interceptors); got != tt.want {
+				t.Errorf("ran %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildPipeline_Omitted(t *testing.T) {
+	stages := map[string]Interceptor{
+		StagePolicy:   namedInterceptor(StagePolicy),
+		StageApproval: namedInterceptor(StageApproval),
+		StageLogging:  namedInterceptor(StageLogging),
+	}
+	// An order written without the approval stage must not drop it unnoticed
+	interceptors, omitted, err := BuildPipeline([]string{"policy", "scrub", "logging"}, stages)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := runOrder(t, interceptors); got != "policy,logging" {
+		t.Errorf("ran %q, want policy,logging", got)
+	}
+	if len(omitted) != 1 || omitted[0] != StageApproval {
+		t.Errorf("omitted = %v, want [approval]", omitted)
+	}
+
+	if _, omitted, _ := BuildPipeline(nil, stages); len(omitted) != 0 {
+		t.Errorf("default order omitted %v", omitted)
+	}
+}
+
+func TestBuildPipeline_Invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		order []string
+		want  string
+	}{
+		{"unknown name", []string{"policy", "firewall", "logging"}, "unknown interceptor"},
+		{"logging not last", []string{"logging", "policy"}, "must be the last"},
+		{"logging missing", []string{"policy", "scrub"}, "is required"},
+		{"duplicate", []string{"scrub", "scrub", "logging"}, "more than once"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := BuildPipeline(tt.order, testStages())
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -2,7 +2,10 @@ package proxy
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"sync/atomic"
 
 	"github.com/contextgate/contextgate/internal/policy"
 )
@@ -11,9 +14,14 @@ import (
 const (
 	MetaKeyPolicyAction = "policy_action"
 	MetaKeyPolicyRule   = "policy_rule"
+	MetaKeyPolicyMsg    = "policy_message"
 	MetaKeyMatchedRules = "matched_rules"
 	MetaKeyAudit        = "audit"
 	MetaKeyScrubCount   = "scrub_count"
+
+	// MetaKeyStoredPayload replaces the payload written to the message
+	// log; the forwarded bytes are unaffected.
+	MetaKeyStoredPayload = "stored_payload"
 )
 
 // PolicyInterceptor evaluates policy rules against messages.
@@ -21,6 +29,17 @@ const (
 // annotate the message metadata for downstream interceptors.
 type PolicyInterceptor struct {
 	engine *policy.Engine
+
+	// Client names the host application for rules with a client field.
+	// When empty it is read from clientInfo.name in the host's
+	// initialize request.
+	Client string
+
+	// OnClientDetected, if set, is called with the client name read from
+	// initialize when Client is empty.
+	OnClientDetected func(client string)
+
+	detected atomic.Pointer[string]
 }
 
 func NewPolicyInterceptor(engine *policy.Engine) *PolicyInterceptor {
@@ -37,12 +56,18 @@ func (p *PolicyInterceptor) Intercept(_ context.Context, msg *InterceptedMessage
 		toolName = policy.ExtractToolName(msg.Parsed.Params)
 	}
 
-	result := p.engine.Evaluate(
-		string(msg.Direction),
-		msg.Parsed.Method,
-		toolName,
-		string(msg.RawBytes),
-	)
+	in := policy.Input{
+		Direction: string(msg.Direction),
+		Method:    msg.Parsed.Method,
+		ToolName:  toolName,
+		Payload:   string(msg.RawBytes),
+		Params:    msg.Parsed.Params,
+		Client:    p.client(msg),
+	}
+	if msg.Parsed.Error != nil {
+		in.ErrorCode = &msg.Parsed.Error.Code
+	}
+	result := p.engine.EvaluateInput(in)
 
 	if len(result.MatchedRules) == 0 {
 		return msg.RawBytes, nil
@@ -57,11 +82,42 @@ func (p *PolicyInterceptor) Intercept(_ context.Context, msg *InterceptedMessage
 	case policy.ActionDeny:
 		msg.Metadata[MetaKeyPolicyAction] = string(policy.ActionDeny)
 		msg.Metadata[MetaKeyPolicyRule] = result.DenyRule
-		return nil, fmt.Errorf("blocked by policy rule %q", result.DenyRule)
+		if p.engine.Config().RedactDenied {
+			if redacted, n := result.RedactPatterns(string(msg.RawBytes)); n > 0 {
+				msg.Metadata[MetaKeyStoredPayload] = redacted
+			}
+		}
+		err := fmt.Errorf("blocked by policy rule %q", result.DenyRule)
+		if result.Message != "" {
+			err = errors.New(result.Message)
+		}
+		if result.DenyMode == policy.DenyModeDrop {
+			return nil, DropBlocked(err)
+		}
+		return nil, err
+
+	case policy.ActionQuarantine:
+		if msg.Direction != DirServerToHost || msg.Parsed.Kind() != KindResponse {
+			// Nothing to withhold from a request or an error; record it
+			// like an audit
+			msg.Metadata[MetaKeyPolicyAction] = string(policy.ActionAudit)
+			msg.Metadata[MetaKeyAudit] = true
+			return msg.RawBytes, nil
+		}
+		msg.Metadata[MetaKeyPolicyAction] = string(policy.ActionQuarantine)
+		msg.Metadata[MetaKeyPolicyRule] = result.QuarantineRule
+		text := result.Message
+		if text == "" {
+			text = fmt.Sprintf("content withheld by policy: matched rule %q", result.QuarantineRule)
+		}
+		return quarantineStub(msg, text)
 
 	case policy.ActionRequireApproval:
 		msg.Metadata[MetaKeyPolicyAction] = string(policy.ActionRequireApproval)
 		msg.Metadata[MetaKeyPolicyRule] = result.ApprovalRule
+		if result.Message != "" {
+			msg.Metadata[MetaKeyPolicyMsg] = result.Message
+		}
 		return msg.RawBytes, nil
 
 	case policy.ActionAudit:
@@ -72,3 +128,65 @@ func (p *PolicyInterceptor) Intercept(_ context.Context, msg *InterceptedMessage
 
 	return msg.RawBytes, nil
 }
+
+// client returns the host application, detecting it from msg if it is
+// the host's initialize request.
+func (p *PolicyInterceptor) client(msg *InterceptedMessage) string {
+	if p.Client != "" {
+		return p.Client
+	}
+	if msg.Direction == DirHostToServer && msg.Parsed.Method == "initialize" {
+		var params struct {
+			ClientInfo struct {
+				Name string `json:"name"`
+			} `json:"clientInfo"`
+		}
+		json.Unmarshal(msg.Parsed.Params, &params)
+		if name := params.ClientInfo.Name; name != "" {
+			p.detected.Store(&name)
+			if p.OnClientDetected != nil {
+				p.OnClientDetected(name)
+			}
+			return name
+		}
+	}
+	if name := p.detected.Load(); name != nil {
+		return *name
+	}
+	return ""
+}
+
+// quarantineStub returns msg, a response, with its result replaced by a
+// tool result holding only text. The id is reused byte for byte.
+func quarantineStub(msg *InterceptedMessage, text string) ([]byte, error) {
+	stub := struct {
+		JSONRPC string          `json:"jsonrpc"`
+		ID      json.RawMessage `json:"id"`
+		Result  any             `json:"result"`
+	}{
+		JSONRPC: "2.0",
+		ID:      msg.Parsed.ID,
+		Result: map[string]any{
+			"content": []map[string]string{{"type": "text", "text": text}},
+		},
+	}
+	return json.Marshal(stub)
+}
+
+// requireApproval holds msg for approval under rule, with message given
+// to the sender if it is denied. Interceptors after the policy use it to
+// escalate a message; a rule that already asked for approval keeps its
+// name and message.
+func requireApproval(msg *InterceptedMessage, rule, message string) {
+	if msg.Metadata == nil {
+		msg.Metadata = make(map[string]any)
+	}
+	if action, _ := msg.Metadata[MetaKeyPolicyAction].(string); action == string(policy.ActionRequireApproval) {
+		return
+	}
+	msg.Metadata[MetaKeyPolicyAction] = string(policy.ActionRequireApproval)
+	msg.Metadata[MetaKeyPolicyRule] = rule
+	if message != "" {
+		msg.Metadata[MetaKeyPolicyMsg] = message
+	}
+}
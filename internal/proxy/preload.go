@@ -0,0 +1,46 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// preloadTimeout bounds the whole tools/list preload, across all pages.
+const preloadTimeout = 30 * time.Second
+
+// preloadTools lists the downstream's tools on the proxy's own behalf and
+// hands each page of results to OnToolsPreloaded. The requests use
+// reserved IDs, so neither they nor their responses reach the host.
+func (p *Proxy) preloadTools(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, preloadTimeout)
+	defer cancel()
+
+	var cursor string
+	for {
+		var params any
+		if cursor != "" {
+			params = map[string]string{"cursor": cursor}
+		}
+		resp, err := p.request(ctx, "tools/list", params)
+		if err != nil {
+			p.logger.Warn("tools preload failed", "error", err)
+			return
+		}
+		if resp.Error != nil {
+			p.logger.Warn("tools preload rejected by server", "code", resp.Error.Code, "message", resp.Error.Message)
+			return
+		}
+		if p.OnToolsPreloaded != nil {
+			p.OnToolsPreloaded(ctx, p.config.SessionID, resp.Result)
+		}
+
+		var page struct {
+			NextCursor string `json:"nextCursor"`
+		}
+		if json.Unmarshal(resp.Result, &page) != nil || page.NextCursor == "" {
+			return
+		}
+		cursor = page.NextCursor
+	}
+}
@@ -0,0 +1,118 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+const initializedLine = `{"jsonrpc":"2.0","method":"notifications/initialized"}` + "\n"
+
+// lastRequest parses the most recent line the proxy wrote to the downstream.
+func lastRequest(t *testing.T, down *syncBuffer) JSONRPCMessage {
+	t.Helper()
+	lines := strings.Split(strings.TrimSpace(down.String()), "\n")
+	msg, err := ParseMessage([]byte(lines[len(lines)-1]))
+	if err != nil {
+		t.Fatalf("parse downstream line: %v", err)
+	}
+	return msg
+}
+
+// answer feeds a server response to the proxy's own request id.
+func answer(t *testing.T, p *Proxy, host *syncBuffer, id json.RawMessage, result string) {
+	t.Helper()
+	line := `{"jsonrpc":"2.0","id":` + string(id) + `,"result":` + result + "}\n"
+	if err := p.pipeMessages(context.Background(), strings.NewReader(line), host, DirServerToHost); err != nil {
+		t.Fatalf("pipeMessages: %v", err)
+	}
+}
+
+func TestProxy_PreloadToolsRegistersWithoutHost(t *testing.T) {
+	ms := newMockToolStore()
+	ta := NewToolAnalyticsInterceptor(ms, testLogger(), PruneConfig{})
+
+	p := NewProxy(Config{SessionID: "test", PreloadTools: true}, NewInterceptorChain(ta), testLogger())
+	down := &syncBuffer{}
+	host := &syncBuffer{}
+	p.downStdin = down
+	p.hostOut = host
+
+	done := make(chan struct{})
+	p.OnToolsPreloaded = func(ctx context.Context, sessionID string, result json.RawMessage) {
+		ta.RegisterToolsList(ctx, sessionID, result)
+		close(done)
+	}
+
+	if err := p.pipeMessages(context.Background(), strings.NewReader(initializedLine), down, DirHostToServer); err != nil {
+		t.Fatalf("pipeMessages: %v", err)
+	}
+	waitFor(t, func() bool { return down.lineCount() == 2 })
+
+	req := lastRequest(t, down)
+	if req.Method != "tools/list" || !p.ids.reserved(req.ID) {
+		t.Fatalf("unexpected preload request: %s", down.String())
+	}
+	answer(t, p, host, req.ID, `{"tools":[{"name":"read_file","description":"Read a file"},{"name":"write_file"}]}`)
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("preloaded tools not delivered")
+	}
+	if len(ms.registered) != 2 || ms.registered[0].ToolName != "read_file" || ms.registered[0].SessionID != "test" {
+		t.Errorf("registered = %+v, want read_file and write_file", ms.registered)
+	}
+	if host.String() != "" {
+		t.Errorf("preload leaked to host: %s", host.String())
+	}
+}
+
+func TestProxy_PreloadToolsFollowsCursor(t *testing.T) {
+	p := NewProxy(Config{SessionID: "test", PreloadTools: true}, NewInterceptorChain(), testLogger())
+	down := &syncBuffer{}
+	host := &syncBuffer{}
+	p.downStdin = down
+	p.hostOut = host
+
+	pages := make(chan string, 2)
+	p.OnToolsPreloaded = func(_ context.Context, _ string, result json.RawMessage) {
+		pages <- string(result)
+	}
+
+	p.pipeMessages(context.Background(), strings.NewReader(initializedLine), down, DirHostToServer)
+	waitFor(t, func() bool { return down.lineCount() == 2 })
+	answer(t, p, host, lastRequest(t, down).ID, `{"tools":[{"name":"a"}],"nextCursor":"page2"}`)
+
+	waitFor(t, func() bool { return down.lineCount() == 3 })
+	second := lastRequest(t, down)
+	if !strings.Contains(string(second.Params), `"cursor":"page2"`) {
+		t.Fatalf("second request params = %s, want the cursor", second.Params)
+	}
+	answer(t, p, host, second.ID, `{"tools":[{"name":"b"}]}`)
+
+	for _, want := range []string{`"a"`, `"b"`} {
+		select {
+		case page := <-pages:
+			if !strings.Contains(page, want) {
+				t.Errorf("page = %s, want tool %s", page, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("page not delivered")
+		}
+	}
+}
+
+func TestProxy_NoPreloadByDefault(t *testing.T) {
+	p := NewProxy(Config{SessionID: "test"}, NewInterceptorChain(), testLogger())
+	down := &syncBuffer{}
+	p.downStdin = down
+
+	p.pipeMessages(context.Background(), strings.NewReader(initializedLine), down, DirHostToServer)
+	time.Sleep(50 * time.Millisecond)
+	if n := down.lineCount(); n != 1 {
+		t.Errorf("downstream got %d lines, want only the notification: %s", n, down.String())
+	}
+}
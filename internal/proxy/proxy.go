@@ -2,46 +2,163 @@ package proxy
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"os"
 	"os/exec"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 const maxMessageSize = 10 * 1024 * 1024 // 10MB
 
+// stdoutEOFGrace is how long the downstream gets to exit after closing
+// its stdout before the session is ended and it is killed.
+const stdoutEOFGrace = time.Second
+
+var errDownstreamStdoutClosed = errors.New("downstream closed stdout but kept running")
+
+// ErrSessionRejected is returned by Run when the host's initialize request
+// was blocked or dropped, ending the session before it began.
+var ErrSessionRejected = errors.New("session rejected at initialize")
+
 // Config holds configuration for a proxy instance.
 type Config struct {
 	Command   string
 	Args      []string
 	SessionID string
+
+	// WaitReady buffers host→server messages (other than initialize) until
+	// the downstream is ready. ReadySignal optionally names a server→host
+	// method to treat as the readiness signal instead of the initialize
+	// response. ReadyTimeout releases the buffer if no signal arrives.
+	WaitReady    bool
+	ReadySignal  string
+	ReadyTimeout time.Duration
+
+	// MaxInflight caps outstanding host→server requests (0 = unlimited).
+	// Excess requests wait for a slot, without holding up other messages,
+	// unless RejectWhenBusy is set, in which case they are answered with a
+	// busy error.
+	MaxInflight    int
+	RejectWhenBusy bool
+
+	// RejectDuplicateIDs answers a host request with an error when its ID
+	// matches one still awaiting a response, instead of forwarding it with
+	// a warning. Either way the responses would be ambiguous.
+	RejectDuplicateIDs bool
+
+	// RequestTimeout answers a host request with an error if the
+	// downstream has not responded within it; a response arriving later
+	// is dropped. Zero waits indefinitely.
+	RequestTimeout time.Duration
+
+	// IDPrefix is the reserved prefix for proxy-originated request IDs
+	// (default DefaultIDPrefix).
+	IDPrefix string
+
+	// Limits constrains the downstream process's resources. They are only
+	// supported on Linux; elsewhere the downstream runs without them and
+	// a warning is logged.
+	Limits ChildLimits
+
+	// Once proxies a single host request and its response, then shuts the
+	// downstream down. Host input after the first request is ignored.
+	Once bool
+
+	// PreloadTools has the proxy issue its own tools/list once the host's
+	// initialized notification reaches the downstream, so the tool registry
+	// is populated even if the host never lists tools itself.
+	PreloadTools bool
+
+	// AllowInitializeRetry keeps the session open when the interceptor
+	// chain blocks or drops the host's initialize request, so the host can
+	// try again. By default the sender gets the error and the session ends.
+	AllowInitializeRetry bool
+
+	// OnMalformed decides what happens to server output that is not valid
+	// JSON (default MalformedForward). Malformed host input is always
+	// forwarded, so the server can answer it with its own parse error.
+	OnMalformed MalformedMode
 }
 
+// MalformedMode is what the proxy does with an unparseable server message.
+type MalformedMode string
+
+const (
+	// MalformedForward passes the line to the host unchanged.
+	MalformedForward MalformedMode = "forward"
+	// MalformedDrop discards the line.
+	MalformedDrop MalformedMode = "drop"
+	// MalformedError discards the line and sends the host a JSON-RPC parse
+	// error with a null id, since the line's own id can't be read.
+	MalformedError MalformedMode = "error"
+)
+
 // Proxy is the core bidirectional MCP proxy.
 type Proxy struct {
 	config Config
 	chain  *InterceptorChain
 	logger *slog.Logger
 
-	cmd       *exec.Cmd
+	// OnToolsPreloaded receives each tools/list result fetched by
+	// Config.PreloadTools.
+	OnToolsPreloaded func(ctx context.Context, sessionID string, result json.RawMessage)
+
 	downStdin io.WriteCloser
+	hostIn    io.Reader
+	hostOut   io.Writer
+	gate      *readyGate
+	tracker   *requestTracker
+	cancels   *cancelWatch
+	ids       *idMapper
+	once      *onceState
+	pause     pauseGate
+	seq       seqClock
+	preload   sync.Once
+	restart   chan chan error
+	now       func() time.Time // timestamps intercepted messages; time.Now outside tests
+
+	// The host's handshake as forwarded, replayed after a restart
+	initParams  atomic.Pointer[json.RawMessage]
+	initialized atomic.Bool
+
+	rejected atomic.Bool // the host's initialize was refused
 }
 
 func NewProxy(cfg Config, chain *InterceptorChain, logger *slog.Logger) *Proxy {
 	if cfg.SessionID == "" {
-		cfg.SessionID = shortID()
+		cfg.SessionID = NewSessionID()
+	}
+	p := &Proxy{
+		config:  cfg,
+		chain:   chain,
+		logger:  logger.With(SessionLogKey, cfg.SessionID),
+		hostIn:  os.Stdin,
+		hostOut: os.Stdout,
+		tracker: newRequestTracker(cfg.MaxInflight),
+		cancels: newCancelWatch(),
+		ids:     newIDMapper(cfg.IDPrefix),
+		restart: make(chan chan error),
+		now:     time.Now,
+	}
+	if cfg.WaitReady {
+		p.gate = newReadyGate(cfg.ReadySignal)
 	}
-	return &Proxy{
-		config: cfg,
-		chain:  chain,
-		logger: logger,
+	if cfg.Once {
+		p.once = newOnceState()
 	}
+	return p
 }
 
 // SessionID returns the session identifier for this proxy instance.
@@ -50,120 +167,470 @@ func (p *Proxy) SessionID() string {
 }
 
 // Run starts the downstream process and begins bidirectional proxying.
-// It blocks until the context is cancelled or the downstream process exits.
+// It blocks until the context is cancelled or the downstream process
+// exits, other than when stopped by Restart.
 func (p *Proxy) Run(ctx context.Context) error {
-	p.cmd = exec.CommandContext(ctx, p.config.Command, p.config.Args...)
+	// Cancelling ends the session and kills the downstream
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-	var err error
-	p.downStdin, err = p.cmd.StdinPipe()
+	child, err := p.startDownstream(ctx)
 	if err != nil {
-		return fmt.Errorf("stdin pipe: %w", err)
+		return err
 	}
-	downStdout, err := p.cmd.StdoutPipe()
-	if err != nil {
-		return fmt.Errorf("stdout pipe: %w", err)
+	// Several goroutines write to the downstream; keep their lines whole.
+	// A restart swaps the new process in behind it.
+	downStdin := &lockedWriteCloser{w: child.stdin}
+	p.downStdin = downStdin
+	// The host's stdout too: both pipe directions write to it
+	if _, ok := p.hostOut.(serialWriter); !ok {
+		p.hostOut = &lockedWriter{w: p.hostOut}
 	}
-	p.cmd.Stderr = os.Stderr
 
-	if err := p.cmd.Start(); err != nil {
-		return fmt.Errorf("start downstream %q: %w", p.config.Command, err)
+	if p.gate != nil && p.config.ReadyTimeout > 0 {
+		timer := time.AfterFunc(p.config.ReadyTimeout, func() {
+			if n, _ := p.gate.open(p.downStdin); n > 0 {
+				p.logger.Warn("downstream readiness timed out, releasing buffered messages", "count", n)
+			}
+		})
+		defer timer.Stop()
 	}
 
-	p.logger.Info("downstream started",
-		"command", p.config.Command,
-		"args", p.config.Args,
-		"pid", p.cmd.Process.Pid,
-		"session", p.config.SessionID,
-	)
-
-	ctx, cancel := context.WithCancel(ctx)
-	defer cancel()
-
-	var wg sync.WaitGroup
 	errCh := make(chan error, 2)
 
-	// Host stdin → downstream stdin
-	wg.Add(1)
+	// Host stdin → downstream stdin. Run doesn't wait for this side: a
+	// read from the host can block indefinitely after the session ends.
 	go func() {
-		defer wg.Done()
-		if err := p.pipeMessages(ctx, os.Stdin, p.downStdin, DirHostToServer); err != nil {
+		err := p.pipeMessages(ctx, p.hostIn, p.downStdin, DirHostToServer)
+		if errors.Is(err, ErrSessionRejected) {
+			// Give the downstream the same chance to exit on its own as
+			// in once mode, then kill it
+			p.downStdin.Close()
+			select {
+			case <-time.After(onceGrace):
+				cancel()
+			case <-ctx.Done():
+			}
+			return
+		}
+		if err != nil {
 			errCh <- fmt.Errorf("host->downstream: %w", err)
 		}
+		// In once mode, keep the downstream's stdin open until it answers
+		if p.once != nil && p.once.awaiting() {
+			select {
+			case <-p.once.done:
+			case <-ctx.Done():
+			}
+		}
 		p.downStdin.Close()
 	}()
 
-	// Downstream stdout → host stdout
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		if err := p.pipeMessages(ctx, downStdout, os.Stdout, DirServerToHost); err != nil {
-			errCh <- fmt.Errorf("downstream->host: %w", err)
+	if p.config.RequestTimeout > 0 {
+		go p.watchTimeouts(ctx)
+	}
+
+	if p.once != nil {
+		go func() {
+			select {
+			case <-p.once.done:
+			case <-ctx.Done():
+				return
+			}
+			p.downStdin.Close()
+			select {
+			case <-time.After(onceGrace):
+				cancel() // kills the downstream via CommandContext
+			case <-ctx.Done():
+			}
+		}()
+	}
+
+	// restarted is answered once a restarted downstream has been
+	// handed the host's handshake
+	var restarted chan error
+	for {
+		// Downstream stdout → host stdout. It is read to the end before
+		// Wait, which closes the pipe and would lose any output still unread
+		stdoutDone := make(chan struct{})
+		var stdoutErr error
+		go func() {
+			defer close(stdoutDone)
+			if err := p.pipeMessages(ctx, child.stdout, p.hostOut, DirServerToHost); err != nil {
+				stdoutErr = fmt.Errorf("downstream->host: %w", err)
+			}
+		}()
+
+		if restarted != nil {
+			go func(child *downstream, done chan error) {
+				// Host messages wait until the new process is initialized
+				err := p.replayHandshake(ctx, child.stdin)
+				downStdin.release(child.stdin)
+				if p.config.PreloadTools && err == nil && p.initialized.Load() {
+					go p.preloadTools(ctx)
+				}
+				done <- err
+			}(child, restarted)
+			restarted = nil
 		}
-	}()
 
-	waitErr := p.cmd.Wait()
-	cancel()
-	wg.Wait()
+		killed := false
+		select {
+		case <-stdoutDone:
+		case restarted = <-p.restart:
+			downStdin.hold()
+			child.kill()
+			killed = true
+		case <-ctx.Done():
+			killed = true
+		}
+		if killed {
+			// A killed process's output ends with it, unless a process it
+			// started holds the pipe open; then Wait closes it
+			select {
+			case <-stdoutDone:
+			case <-time.After(stdoutEOFGrace):
+			}
+		}
 
-	select {
-	case err := <-errCh:
-		if waitErr != nil {
-			return waitErr
+		waited := make(chan error, 1)
+		go func() { waited <- child.cmd.Wait() }()
+
+		var waitErr error
+		stdoutClosed := false
+		if killed {
+			waitErr = <-waited
+		} else {
+			// Without stdout the session is over, even if the downstream
+			// keeps running; don't wait on it forever
+			select {
+			case waitErr = <-waited:
+			case <-time.After(stdoutEOFGrace):
+				p.logger.Warn("downstream closed stdout but is still running, terminating it")
+				stdoutClosed = true
+				cancel() // kills the downstream via CommandContext
+				waitErr = <-waited
+			}
 		}
-		return err
-	default:
+		<-stdoutDone
+
+		if restarted != nil {
+			p.answerAbandoned()
+			next, err := p.startDownstream(ctx)
+			if err != nil {
+				downStdin.release(child.stdin)
+				restarted <- err
+				return err
+			}
+			child = next
+			continue
+		}
+
+		cancel()
+
+		if p.rejected.Load() {
+			return ErrSessionRejected
+		}
+		if p.once != nil && p.once.finished() {
+			// The exchange completed; how the downstream exited doesn't matter
+			return nil
+		}
+		if stdoutClosed {
+			return errDownstreamStdoutClosed
+		}
+		if stdoutErr != nil {
+			errCh <- stdoutErr
+		}
+
+		select {
+		case err := <-errCh:
+			if waitErr != nil {
+				return waitErr
+			}
+			return err
+		default:
+		}
+		return waitErr
+	}
+}
+
+// downstream is one run of the downstream process.
+type downstream struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout io.ReadCloser
+	kill   context.CancelFunc
+}
+
+// startDownstream starts the downstream process. It is killed when ctx
+// is cancelled or kill is called.
+func (p *Proxy) startDownstream(ctx context.Context) (*downstream, error) {
+	ctx, kill := context.WithCancel(ctx)
+	if !limitsSupported && !p.config.Limits.isZero() {
+		p.logger.Warn("child resource limits are only supported on Linux, starting the server without them")
+	}
+	cmd, err := limitedCommand(ctx, p.config.Command, p.config.Args, p.config.Limits)
+	if err != nil {
+		kill()
+		return nil, fmt.Errorf("start downstream %q: %w", p.config.Command, err)
+	}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		kill()
+		return nil, fmt.Errorf("stdin pipe: %w", err)
 	}
-	return waitErr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		kill()
+		return nil, fmt.Errorf("stdout pipe: %w", err)
+	}
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Start(); err != nil {
+		kill()
+		return nil, fmt.Errorf("start downstream %q: %w", p.config.Command, err)
+	}
+
+	p.logger.Info("downstream started",
+		"command", p.config.Command,
+		"args", p.config.Args,
+		"pid", cmd.Process.Pid,
+	)
+	return &downstream{cmd: cmd, stdin: stdin, stdout: stdout, kill: kill}, nil
+}
+
+// readLine is a message read by pipeMessages, parsed ahead of processing.
+type readLine struct {
+	raw       []byte
+	parsed    JSONRPCMessage
+	parseErr  error
+	cancelled chan struct{} // set for requests; see cancelWatch
+}
+
+// readMessages scans src on its own goroutine so that a cancellation can
+// be seen while an earlier message is held in the chain. The returned
+// channel is closed at the end of input, after which *errp holds the
+// scan error. Closing stop abandons the remaining input.
+func (p *Proxy) readMessages(src io.Reader, dir Direction, stop <-chan struct{}, errp *error) <-chan readLine {
+	lines := make(chan readLine)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(src)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxMessageSize)
+		for scanner.Scan() {
+			// Servers on Windows end lines with \r\n; drop the \r with any
+			// other trailing whitespace. Inside a JSON string a CR is always
+			// escaped, so only the terminator is touched. Output lines are
+			// always written with a bare \n.
+			line := bytes.TrimRight(scanner.Bytes(), " \t\r")
+			if len(line) == 0 {
+				continue
+			}
+
+			// Copy — scanner reuses buffer
+			in := readLine{raw: make([]byte, len(line))}
+			copy(in.raw, line)
+			in.parsed, in.parseErr = ParseMessage(in.raw)
+			if in.parseErr == nil {
+				if in.parsed.Kind() == KindRequest {
+					in.cancelled = p.cancels.watch(corrKey{dir: dir, id: string(in.parsed.ID)})
+				} else if key, ok := cancelledKey(dir, in.parsed); ok {
+					// Free an in-flight slot now rather than behind a queued request
+					p.tracker.finish(key)
+					if p.cancels.cancel(key) {
+						p.logger.Debug("request cancelled while held", "id", key.id, "direction", dir)
+					}
+				}
+			}
+
+			select {
+			case lines <- in:
+			case <-stop:
+				return
+			}
+		}
+		*errp = scanner.Err()
+	}()
+	return lines
 }
 
 // pipeMessages reads newline-delimited JSON from src, runs it through
 // the interceptor chain, and writes surviving messages to dst.
 func (p *Proxy) pipeMessages(ctx context.Context, src io.Reader, dst io.Writer, dir Direction) error {
-	scanner := bufio.NewScanner(src)
-	scanner.Buffer(make([]byte, 0, 64*1024), maxMessageSize)
+	stop := make(chan struct{})
+	defer close(stop)
+	var scanErr error
+	lines := p.readMessages(src, dir, stop, &scanErr)
+
+	// The request in the chain stays cancellable until the next message
+	var held readLine
+	release := func() {
+		if held.cancelled != nil {
+			p.cancels.forget(corrKey{dir: dir, id: string(held.parsed.ID)}, held.cancelled)
+		}
+	}
+	defer release()
+
+	// Host requests that passed the chain while every in-flight slot was
+	// taken, oldest first. They wait here rather than in the loop, which
+	// has to keep reading: the server may need the host's response to a
+	// request of its own before it answers any of them.
+	var queue []queuedRequest
+
+	for {
+		var in readLine
+		var ok bool
+		if len(queue) > 0 {
+			select {
+			case in, ok = <-lines:
+			case <-p.tracker.released():
+				if done, err := p.sendQueued(ctx, dst, &queue, false); done || err != nil {
+					return err
+				}
+				continue
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			// A slot freed while this line was read, as by a cancellation,
+			// goes to the queue before the line is handled
+			if done, err := p.sendQueued(ctx, dst, &queue, false); done || err != nil {
+				return err
+			}
+		} else {
+			in, ok = <-lines
+		}
+		if !ok {
+			break
+		}
+		release()
+		held = in
 
-	for scanner.Scan() {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
 		default:
 		}
-
-		line := scanner.Bytes()
-		if len(line) == 0 {
-			continue
+		if dir == DirHostToServer {
+			if err := p.pause.wait(ctx); err != nil {
+				return err
+			}
 		}
 
-		// Copy — scanner reuses buffer
-		raw := make([]byte, len(line))
-		copy(raw, line)
-
-		parsed, parseErr := ParseMessage(raw)
+		raw, parsed, parseErr := in.raw, in.parsed, in.parseErr
 
+		now := p.now()
 		msg := &InterceptedMessage{
-			Timestamp: time.Now(),
+			Seq:       p.seq.next(now),
+			Timestamp: now,
 			SessionID: p.config.SessionID,
 			Direction: dir,
 			RawBytes:  raw,
 			Parsed:    parsed,
 			ParseErr:  parseErr,
+			Cancelled: in.cancelled,
 		}
 
 		if parseErr != nil {
-			p.logger.Warn("unparseable message, forwarding raw",
+			mode := MalformedForward
+			if dir == DirServerToHost && p.config.OnMalformed != "" {
+				mode = p.config.OnMalformed
+			}
+			p.logger.Warn("unparseable message",
 				"direction", dir,
 				"error", parseErr,
+				"action", mode,
 			)
-			// Forward unparseable messages as-is to avoid breaking the connection
-			if _, err := dst.Write(append(raw, '\n')); err != nil {
-				return fmt.Errorf("write: %w", err)
+			switch mode {
+			case MalformedDrop:
+			case MalformedError:
+				errBytes := MakeErrorResponse(json.RawMessage("null"), -32700, "invalid JSON-RPC from server: "+parseErr.Error())
+				if err := writeWithRetry(ctx, dst, append(errBytes, '\n')); err != nil {
+					return fmt.Errorf("write: %w", err)
+				}
+			default:
+				// Forward unparseable messages as-is to avoid breaking the connection
+				if err := writeWithRetry(ctx, dst, append(raw, '\n')); err != nil {
+					return fmt.Errorf("write: %w", err)
+				}
 			}
 			continue
 		}
 
+		if dir == DirServerToHost {
+			out, claimed, err := p.ids.inbound(msg, raw)
+			if err != nil {
+				p.logger.Warn("failed to restore response id", "error", err)
+			} else if claimed {
+				continue
+			} else {
+				msg.RawBytes = out
+			}
+		}
+
+		// In once mode, the response to the host's request ends the session
+		// however it is handled below
+		onceAnswer := false
+		// A cancelled request gets no response, so stop tracking it
+		if key, ok := cancelledKey(dir, parsed); ok {
+			p.tracker.finish(key)
+			queue = slices.DeleteFunc(queue, func(q queuedRequest) bool { return requestKey(q.msg) == key })
+		}
+
+		if msg.Parsed.ID != nil && msg.Parsed.Method == "" {
+			if req, ok := p.tracker.finish(responseKey(msg)); ok {
+				msg.Metadata = map[string]any{
+					MetaKeyLatencyMs: float64(now.Sub(req.sent)) / float64(time.Millisecond),
+				}
+				if req.tool != "" {
+					msg.Metadata[MetaKeyRequestTool] = req.tool
+				}
+			}
+			if dir == DirServerToHost && p.tracker.late(responseKey(msg)) {
+				p.logger.Debug("dropped response to a timed out request", "id", string(msg.Parsed.ID))
+				continue
+			}
+			onceAnswer = p.once != nil && dir == DirServerToHost && p.once.answers(responseKey(msg))
+		}
+
+		if dir == DirServerToHost && p.gate != nil && p.gate.isSignal(msg) {
+			n, err := p.gate.open(p.downStdin)
+			if err != nil {
+				p.logger.Error("failed to flush buffered messages", "error", err)
+			} else if n > 0 {
+				p.logger.Debug("downstream ready, flushed buffered messages", "count", n)
+			}
+		}
+
+		if dir == DirHostToServer && parsed.Kind() == KindRequest && p.tracker.outstanding(requestKey(msg)) {
+			p.logger.Warn("host reused the id of an outstanding request",
+				"id", string(parsed.ID),
+				"method", parsed.Method,
+				"rejected", p.config.RejectDuplicateIDs,
+			)
+			if p.config.RejectDuplicateIDs {
+				err := fmt.Errorf("duplicate request id %s: a request with this id is still awaiting its response", parsed.ID)
+				if p.chain.OnBlock != nil {
+					p.chain.OnBlock(ctx, msg, err)
+				}
+				p.sendBlockError(dir, msg, err)
+				continue
+			}
+		}
+
+		// A host request answered by the proxy itself also ends once mode
+		onceRequest := p.once != nil && dir == DirHostToServer && parsed.Kind() == KindRequest
+
 		result, chainErr := p.chain.Process(ctx, msg)
 		if chainErr != nil {
 			p.sendBlockError(dir, msg, chainErr)
+			if onceRequest || onceAnswer {
+				p.once.finish()
+				return nil
+			}
+			if p.rejectSession(dir, parsed) {
+				return ErrSessionRejected
+			}
 			continue
 		}
 		if result == nil {
@@ -171,14 +638,166 @@ func (p *Proxy) pipeMessages(ctx context.Context, src io.Reader, dst io.Writer,
 				"method", parsed.Method,
 				"direction", dir,
 			)
+			if onceRequest || onceAnswer {
+				p.once.finish()
+				return nil
+			}
+			if p.rejectSession(dir, parsed) {
+				return ErrSessionRejected
+			}
 			continue
 		}
 
-		if _, err := dst.Write(append(result, '\n')); err != nil {
-			return fmt.Errorf("write: %w", err)
+		if parsed.Kind() == KindRequest {
+			req := trackedRequest{sent: p.now()}
+			if parsed.Method == "tools/call" {
+				req.tool = extractToolNameFromParams(parsed.Params)
+			}
+			if len(queue) > 0 {
+				queue = append(queue, queuedRequest{msg: msg, result: result, req: req, once: onceRequest})
+				continue
+			}
+			if err := p.tracker.begin(ctx, requestKey(msg), req, false); err != nil {
+				if err != errBusy {
+					return err
+				}
+				if !p.config.RejectWhenBusy {
+					queue = append(queue, queuedRequest{msg: msg, result: result, req: req, once: onceRequest})
+					continue
+				}
+				p.sendBlockError(dir, msg, err)
+				if onceRequest {
+					p.once.finish()
+					return nil
+				}
+				continue
+			}
+		}
+
+		if done, err := p.forward(ctx, dst, msg, result, onceRequest, onceAnswer); done || err != nil {
+			return err
+		}
+	}
+	if _, err := p.sendQueued(ctx, dst, &queue, true); err != nil {
+		return err
+	}
+	return scanErr
+}
+
+// queuedRequest is a host request waiting for an in-flight slot, with
+// the bytes the chain passed.
+type queuedRequest struct {
+	msg    *InterceptedMessage
+	result []byte
+	req    trackedRequest
+	once   bool
+}
+
+// sendQueued forwards queued requests in order while slots are free, or
+// waits for slots until the queue is empty if wait is set. It reports
+// whether the pipe is done, as it is after the request in once mode.
+func (p *Proxy) sendQueued(ctx context.Context, dst io.Writer, queue *[]queuedRequest, wait bool) (bool, error) {
+	for len(*queue) > 0 {
+		q := (*queue)[0]
+		// Latency and timeouts count from when the request is sent
+		q.req.sent = p.now()
+		if err := p.tracker.begin(ctx, requestKey(q.msg), q.req, wait); err == errBusy {
+			return false, nil
+		} else if err != nil {
+			return false, err
+		}
+		*queue = (*queue)[1:]
+		if done, err := p.forward(ctx, dst, q.msg, q.result, q.once, false); done || err != nil {
+			return done, err
+		}
+	}
+	return false, nil
+}
+
+// forward writes the bytes the chain passed for msg to dst. It reports
+// whether the pipe is done, as it is after the request in once mode.
+func (p *Proxy) forward(ctx context.Context, dst io.Writer, msg *InterceptedMessage, result []byte, onceRequest, onceAnswer bool) (bool, error) {
+	dir, parsed := msg.Direction, msg.Parsed
+	if dir == DirHostToServer {
+		var err error
+		if result, err = p.ids.outbound(msg, result); err != nil {
+			return false, err
+		}
+	}
+
+	// Record before writing: the response may arrive before we return
+	if onceRequest {
+		p.once.request(requestKey(msg))
+	}
+
+	held := dir == DirHostToServer && p.gate != nil && p.gate.hold(msg, result)
+	if !held {
+		if err := writeWithRetry(ctx, dst, append(result, '\n')); err != nil {
+			return false, fmt.Errorf("write: %w", err)
+		}
+	}
+
+	if dir == DirHostToServer {
+		p.recordHandshake(parsed.Method, result)
+	}
+
+	if !held && dir == DirHostToServer && p.config.PreloadTools && parsed.Method == "notifications/initialized" {
+		p.preload.Do(func() { go p.preloadTools(ctx) })
+	}
+
+	if onceRequest {
+		return true, nil
+	}
+	if onceAnswer {
+		p.once.finish()
+	}
+	return false, nil
+}
+
+// rejectSession reports whether a host message the chain refused was the
+// initialize request, which ends the session unless retries are allowed.
+func (p *Proxy) rejectSession(dir Direction, parsed JSONRPCMessage) bool {
+	if dir != DirHostToServer || parsed.Method != "initialize" || parsed.Kind() != KindRequest || p.config.AllowInitializeRetry {
+		return false
+	}
+	p.logger.Warn("initialize refused, ending session")
+	p.rejected.Store(true)
+	return true
+}
+
+// request sends a proxy-originated request to the downstream and waits
+// for its response. The request uses a reserved ID, so the response is
+// never forwarded to the host.
+func (p *Proxy) request(ctx context.Context, method string, params any) (JSONRPCMessage, error) {
+	return p.requestVia(ctx, p.downStdin, method, params)
+}
+
+// requestVia is like request but writes the request to w.
+func (p *Proxy) requestVia(ctx context.Context, w io.Writer, method string, params any) (JSONRPCMessage, error) {
+	req := JSONRPCMessage{JSONRPC: "2.0", Method: method}
+	if params != nil {
+		raw, err := json.Marshal(params)
+		if err != nil {
+			return JSONRPCMessage{}, fmt.Errorf("marshal params: %w", err)
 		}
+		req.Params = raw
+	}
+
+	var ch <-chan []byte
+	req.ID, ch = p.ids.reserve()
+	data, _ := json.Marshal(req)
+	if err := writeWithRetry(ctx, w, append(data, '\n')); err != nil {
+		p.ids.release(req.ID)
+		return JSONRPCMessage{}, fmt.Errorf("write: %w", err)
+	}
+
+	select {
+	case raw := <-ch:
+		return ParseMessage(raw)
+	case <-ctx.Done():
+		p.ids.release(req.ID)
+		return JSONRPCMessage{}, ctx.Err()
 	}
-	return scanner.Err()
 }
 
 // sendBlockError sends a JSON-RPC error back to the message's sender.
@@ -194,7 +813,7 @@ func (p *Proxy) sendBlockError(dir Direction, msg *InterceptedMessage, chainErr
 	// server_to_host blocked → respond on downstream stdin (back to server)
 	var target io.Writer
 	if dir == DirHostToServer {
-		target = os.Stdout
+		target = p.hostOut
 	} else {
 		target = p.downStdin
 	}
@@ -210,8 +829,43 @@ func (p *Proxy) sendBlockError(dir Direction, msg *InterceptedMessage, chainErr
 	)
 }
 
-func shortID() string {
+// seqClock hands out strictly increasing sequence numbers that track the
+// wall clock in nanoseconds, so messages from proxies sharing a database
+// still order sensibly against each other.
+type seqClock struct {
+	last atomic.Int64
+}
+
+// next returns a sequence number for a message read at t.
+func (c *seqClock) next(t time.Time) int64 {
+	for {
+		last := c.last.Load()
+		n := t.UnixNano()
+		if n <= last {
+			n = last + 1
+		}
+		if c.last.CompareAndSwap(last, n) {
+			return n
+		}
+	}
+}
+
+// NewSessionID returns a random session identifier, the kind NewProxy
+// assigns when Config.SessionID is empty.
+func NewSessionID() string {
 	b := make([]byte, 4)
 	rand.Read(b)
 	return hex.EncodeToString(b)
 }
+
+// StableSessionID derives a session identifier from the wrapped command,
+// its arguments and working directory, so restarts of the same server
+// share one session.
+func StableSessionID(command string, args []string, dir string) string {
+	h := sha256.New()
+	for _, part := range append([]string{command, dir}, args...) {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
@@ -0,0 +1,167 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/contextgate/contextgate/internal/policy"
+)
+
+func TestProxy_DownstreamClosesStdout(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not available")
+	}
+
+	// The server closes its stdout but stays alive; the host stays connected
+	script := `exec 1>&-; exec sleep 30`
+	p := NewProxy(Config{Command: sh, Args: []string{"-c", script}}, NewInterceptorChain(), testLogger())
+	hostIn, hostWriter := io.Pipe()
+	defer hostWriter.Close()
+	p.hostIn = hostIn
+	p.hostOut = &syncBuffer{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	start := time.Now()
+	err = p.Run(ctx)
+	if ctx.Err() != nil {
+		t.Fatal("proxy did not shut down after the downstream closed stdout")
+	}
+	if !errors.Is(err, errDownstreamStdoutClosed) {
+		t.Errorf("Run = %v, want %v", err, errDownstreamStdoutClosed)
+	}
+	if elapsed := time.Since(start); elapsed > stdoutEOFGrace+3*time.Second {
+		t.Errorf("shutdown took %v", elapsed)
+	}
+}
+
+func TestProxy_DeniedInitializeEndsSession(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not available")
+	}
+	initLine := `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"clientInfo":{"name":"Cursor","version":"0.9.1"}}}` + "\n"
+
+	for _, allowRetry := range []bool{false, true} {
+		pi := newTestPolicyInterceptor(policy.Rule{
+			Name:    "refuse-old-cursor",
+			Action:  policy.ActionDeny,
+			Client:  "cursor",
+			Methods: []string{"initialize"},
+			Args:    []policy.ArgMatch{{Pointer: "/clientInfo/version", Pattern: `^0\.`}},
+		})
+		// The server exits once its stdin closes
+		p := NewProxy(Config{Command: sh, Args: []string{"-c", `cat >/dev/null`}, AllowInitializeRetry: allowRetry},
+			NewInterceptorChain(pi), testLogger())
+		hostIn, hostWriter := io.Pipe()
+		host := &syncBuffer{}
+		p.hostIn = hostIn
+		p.hostOut = host
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		go func() {
+			hostWriter.Write([]byte(initLine))
+			if allowRetry {
+				for host.String() == "" && ctx.Err() == nil {
+					time.Sleep(5 * time.Millisecond)
+				}
+				hostWriter.Close() // the host gives up; the session ends normally
+			}
+		}()
+		err := p.Run(ctx)
+		if ctx.Err() != nil {
+			t.Fatalf("allowRetry=%v: proxy did not end the session", allowRetry)
+		}
+		cancel()
+		hostWriter.Close()
+
+		if !strings.Contains(host.String(), `"id":1`) || !strings.Contains(host.String(), "refuse-old-cursor") {
+			t.Errorf("allowRetry=%v: host got %q, want the deny error", allowRetry, host.String())
+		}
+		if allowRetry && err != nil {
+			t.Errorf("allowRetry=true: Run = %v, want nil", err)
+		}
+		if !allowRetry && !errors.Is(err, ErrSessionRejected) {
+			t.Errorf("allowRetry=false: Run = %v, want %v", err, ErrSessionRejected)
+		}
+	}
+}
+
+func TestStableSessionID(t *testing.T) {
+	id := StableSessionID("npx", []string{"-y", "server-fs", "/tmp"}, "/home/me")
+	if again := StableSessionID("npx", []string{"-y", "server-fs", "/tmp"}, "/home/me"); again != id {
+		t.Errorf("same command gave %q then %q", id, again)
+	}
+	for _, other := range []string{
+		StableSessionID("npx", []string{"-y", "server-fs", "/var"}, "/home/me"),
+		StableSessionID("npx", []string{"-y", "server-fs", "/tmp"}, "/home/you"),
+		StableSessionID("npx", []string{"-y server-fs", "/tmp"}, "/home/me"),
+	} {
+		if other == id {
+			t.Errorf("different invocation reused session ID %q", id)
+		}
+	}
+	if len(id) != 16 {
+		t.Errorf("id %q, want 16 hex characters", id)
+	}
+}
+
+// unsyncBuffer is a bytes.Buffer with no locking of its own.
+type unsyncBuffer struct{ bytes.Buffer }
+
+func TestProxy_HostStdoutWritersSerialized(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not available")
+	}
+	const n = 200
+
+	// The server streams notifications while the host's denied calls are
+	// answered on the same stdout from the other pipe goroutine.
+	script := fmt.Sprintf(`i=0; while [ $i -lt %d ]; do echo '{"jsonrpc":"2.0","method":"notifications/message","params":{"level":"info","data":"tick"}}'; i=$((i+1)); done; cat >/dev/null`, n)
+	pi := newTestPolicyInterceptor(policy.Rule{Name: "no-calls", Action: policy.ActionDeny, Methods: []string{"tools/call"}})
+	p := NewProxy(Config{Command: sh, Args: []string{"-c", script}}, NewInterceptorChain(pi), testLogger())
+	var input strings.Builder
+	for i := range n {
+		input.WriteString(toolsCallLine(i + 1))
+	}
+	host := &unsyncBuffer{}
+	p.hostIn = strings.NewReader(input.String())
+	p.hostOut = host
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := p.Run(ctx); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	var out string
+	p.hostOut.(serialWriter).locked(func(io.Writer) error {
+		out = host.String()
+		return nil
+	})
+	var notes, errs int
+	for _, line := range strings.Split(strings.TrimSuffix(out, "\n"), "\n") {
+		var msg JSONRPCMessage
+		if err := json.Unmarshal([]byte(line), &msg); err != nil {
+			t.Fatalf("corrupted line %q: %v", line, err)
+		}
+		if msg.Error != nil {
+			errs++
+		} else {
+			notes++
+		}
+	}
+	if notes != n || errs != n {
+		t.Errorf("got %d notifications and %d errors, want %d of each", notes, errs, n)
+	}
+}
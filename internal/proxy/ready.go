@@ -0,0 +1,74 @@
+package proxy
+
+import (
+	"io"
+	"sync"
+)
+
+// readyGate holds host→server messages until the downstream signals that
+// it is ready, then flushes them in arrival order. The initialize request
+// itself is always let through so the handshake can complete.
+//
+// Readiness is signalled by the response to the host's initialize request,
+// or — when signal is set — by a server→host message with that method.
+type readyGate struct {
+	signal string
+
+	mu      sync.Mutex
+	ready   bool
+	initID  string
+	pending [][]byte
+}
+
+func newReadyGate(signal string) *readyGate {
+	return &readyGate{signal: signal}
+}
+
+// hold buffers raw if the downstream is not ready yet and reports whether
+// it did. The caller must not forward held messages itself.
+func (g *readyGate) hold(msg *InterceptedMessage, raw []byte) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.ready {
+		return false
+	}
+	if msg.Parsed.Method == "initialize" {
+		g.initID = string(msg.Parsed.ID)
+		return false
+	}
+	g.pending = append(g.pending, raw)
+	return true
+}
+
+// isSignal reports whether a server→host message marks the downstream as ready.
+func (g *readyGate) isSignal(msg *InterceptedMessage) bool {
+	if g.signal != "" {
+		return msg.Parsed.Method == g.signal
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.initID != "" && msg.Parsed.Method == "" && string(msg.Parsed.ID) == g.initID
+}
+
+// open marks the downstream ready and writes any held messages to w.
+// Writes happen under the lock so later messages cannot overtake them.
+func (g *readyGate) open(w io.Writer) (int, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.ready {
+		return 0, nil
+	}
+	g.ready = true
+
+	pending := g.pending
+	g.pending = nil
+	for i, raw := range pending {
+		if _, err := w.Write(append(raw, '\n')); err != nil {
+			return i, err
+		}
+	}
+	return len(pending), nil
+}
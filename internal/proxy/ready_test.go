@@ -0,0 +1,117 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// bufferCloser is an io.WriteCloser backed by a bytes.Buffer.
+type bufferCloser struct {
+	bytes.Buffer
+}
+
+func (b *bufferCloser) Close() error { return nil }
+
+func newGatedProxy(signal string) (*Proxy, *bufferCloser) {
+	p := NewProxy(Config{Command: "test", WaitReady: true, ReadySignal: signal}, NewInterceptorChain(), testLogger())
+	down := &bufferCloser{}
+	p.downStdin = down
+	return p, down
+}
+
+func TestReadyGate_BuffersUntilInitializeResponse(t *testing.T) {
+	p, down := newGatedProxy("")
+	ctx := context.Background()
+
+	host := strings.Join([]string{
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`,
+	}, "\n") + "\n"
+	if err := p.pipeMessages(ctx, strings.NewReader(host), down, DirHostToServer); err != nil {
+		t.Fatalf("pipe host: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(down.String()), "\n")
+	if len(lines) != 1 || !strings.Contains(lines[0], `"initialize"`) {
+		t.Fatalf("expected only initialize forwarded before readiness, got: %q", down.String())
+	}
+
+	var hostOut bytes.Buffer
+	server := `{"jsonrpc":"2.0","id":1,"result":{"protocolVersion":"2025-03-26"}}` + "\n"
+	if err := p.pipeMessages(ctx, strings.NewReader(server), &hostOut, DirServerToHost); err != nil {
+		t.Fatalf("pipe server: %v", err)
+	}
+
+	lines = strings.Split(strings.TrimSpace(down.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected buffered messages flushed, got %d lines: %q", len(lines), down.String())
+	}
+	if !strings.Contains(lines[1], "notifications/initialized") || !strings.Contains(lines[2], "tools/list") {
+		t.Fatalf("expected buffered messages in arrival order, got: %q", down.String())
+	}
+	if !strings.Contains(hostOut.String(), "protocolVersion") {
+		t.Fatalf("expected initialize response forwarded to host, got: %q", hostOut.String())
+	}
+
+	// Once ready, messages pass straight through
+	if err := p.pipeMessages(ctx, strings.NewReader(`{"jsonrpc":"2.0","id":3,"method":"ping"}`+"\n"), down, DirHostToServer); err != nil {
+		t.Fatalf("pipe host: %v", err)
+	}
+	if !strings.Contains(down.String(), `"ping"`) {
+		t.Fatal("expected message forwarded after readiness")
+	}
+}
+
+func TestReadyGate_CustomSignal(t *testing.T) {
+	p, down := newGatedProxy("notifications/ready")
+	ctx := context.Background()
+
+	host := `{"jsonrpc":"2.0","id":1,"method":"initialize"}` + "\n" + `{"jsonrpc":"2.0","id":2,"method":"tools/list"}` + "\n"
+	p.pipeMessages(ctx, strings.NewReader(host), down, DirHostToServer)
+
+	// The initialize response alone is not enough with a custom signal
+	var hostOut bytes.Buffer
+	p.pipeMessages(ctx, strings.NewReader(`{"jsonrpc":"2.0","id":1,"result":{}}`+"\n"), &hostOut, DirServerToHost)
+	if strings.Contains(down.String(), "tools/list") {
+		t.Fatal("expected tools/list to stay buffered until the custom signal")
+	}
+
+	p.pipeMessages(ctx, strings.NewReader(`{"jsonrpc":"2.0","method":"notifications/ready"}`+"\n"), &hostOut, DirServerToHost)
+	if !strings.Contains(down.String(), "tools/list") {
+		t.Fatal("expected tools/list flushed after the custom signal")
+	}
+}
+
+func TestReadyGate_Disabled(t *testing.T) {
+	p := NewProxy(Config{Command: "test"}, NewInterceptorChain(), testLogger())
+	var down bytes.Buffer
+
+	p.pipeMessages(context.Background(), strings.NewReader(`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`+"\n"), &down, DirHostToServer)
+	if !strings.Contains(down.String(), "tools/list") {
+		t.Fatal("expected messages forwarded immediately without a ready gate")
+	}
+}
+
+func TestReadyGate_OpenIsIdempotent(t *testing.T) {
+	g := newReadyGate("")
+	msg := &InterceptedMessage{Timestamp: time.Now()}
+	msg.Parsed.Method = "tools/list"
+	if !g.hold(msg, []byte(`{"a":1}`)) {
+		t.Fatal("expected message held before readiness")
+	}
+
+	var out bytes.Buffer
+	if n, err := g.open(&out); err != nil || n != 1 {
+		t.Fatalf("open = %d, %v; want 1, nil", n, err)
+	}
+	if n, _ := g.open(&out); n != 0 {
+		t.Fatalf("second open flushed %d messages, want 0", n)
+	}
+	if g.hold(msg, []byte(`{"a":2}`)) {
+		t.Fatal("expected no holding after readiness")
+	}
+}
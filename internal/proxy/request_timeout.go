@@ -0,0 +1,45 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// codeRequestTimeout is the JSON-RPC error code of the response the proxy
+// sends in place of one that didn't arrive in time, as used by the MCP
+// SDKs for request timeouts.
+const codeRequestTimeout = -32001
+
+// watchTimeouts answers host requests that have waited longer than
+// Config.RequestTimeout, until ctx is done.
+func (p *Proxy) watchTimeouts(ctx context.Context) {
+	timeout := p.config.RequestTimeout
+	ticker := time.NewTicker(min(max(timeout/10, 10*time.Millisecond), time.Second))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.answerTimedOut(p.now().Add(-timeout))
+		}
+	}
+}
+
+// answerTimedOut sends the host an error for each of its requests
+// forwarded before cutoff and still unanswered, and stops tracking them.
+func (p *Proxy) answerTimedOut(cutoff time.Time) {
+	for _, key := range p.tracker.expire(DirHostToServer, cutoff) {
+		p.logger.Warn("request timed out", "id", key.id, "timeout", p.config.RequestTimeout)
+		msg := fmt.Sprintf("request timed out: no response from the server within %s", p.config.RequestTimeout)
+		resp := MakeErrorResponse(json.RawMessage(key.id), codeRequestTimeout, msg)
+		if _, err := p.hostOut.Write(append(resp, '\n')); err != nil {
+			p.logger.Error("failed to answer timed out request", "error", err)
+		}
+		if p.once != nil && p.once.answers(key) {
+			p.once.finish()
+		}
+	}
+}
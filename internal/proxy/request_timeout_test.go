@@ -0,0 +1,78 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProxy_RequestTimeout(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not available")
+	}
+	// The server reads every request and never answers
+	p := NewProxy(Config{Command: sh, Args: []string{"-c", "cat >/dev/null"}, RequestTimeout: 50 * time.Millisecond},
+		NewInterceptorChain(), testLogger())
+	hostR, hostW := io.Pipe()
+	host := &syncBuffer{}
+	p.hostIn = hostR
+	p.hostOut = host
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- p.Run(ctx) }()
+
+	hostW.Write([]byte(toolsCallLine(1)))
+	waitFor(t, func() bool { return host.lineCount() == 1 })
+	hostW.Close()
+	if err := <-done; err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	var resp JSONRPCMessage
+	if err := json.Unmarshal([]byte(strings.TrimSpace(host.String())), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if string(resp.ID) != "1" || resp.Error == nil || resp.Error.Code != codeRequestTimeout || !strings.Contains(resp.Error.Message, "timed out") {
+		t.Errorf("response = %s", host.String())
+	}
+	if n := p.tracker.inflight(); n != 0 {
+		t.Errorf("%d requests still tracked", n)
+	}
+}
+
+func TestProxy_RequestTimeoutDropsLateResponse(t *testing.T) {
+	p := NewProxy(Config{Command: "test", RequestTimeout: time.Minute}, NewInterceptorChain(), testLogger())
+	host := &syncBuffer{}
+	p.downStdin = &syncBuffer{}
+	p.hostOut = host
+	ctx := context.Background()
+
+	if err := p.pipeMessages(ctx, strings.NewReader(toolsCallLine(1)+toolsCallLine(2)), p.downStdin, DirHostToServer); err != nil {
+		t.Fatal(err)
+	}
+	p.answerTimedOut(time.Now().Add(time.Second))
+	if n := host.lineCount(); n != 2 {
+		t.Fatalf("host got %d timeout errors, want 2", n)
+	}
+
+	// Request 1's response arrives late and is dropped; a request made
+	// after the timeout with the same id is answered normally
+	if err := p.pipeMessages(ctx, strings.NewReader(`{"jsonrpc":"2.0","id":1,"result":{}}`+"\n"), host, DirServerToHost); err != nil {
+		t.Fatal(err)
+	}
+	if n := host.lineCount(); n != 2 {
+		t.Fatalf("late response forwarded: %s", host.String())
+	}
+	p.pipeMessages(ctx, strings.NewReader(toolsCallLine(2)), p.downStdin, DirHostToServer)
+	p.pipeMessages(ctx, strings.NewReader(`{"jsonrpc":"2.0","id":2,"result":{}}`+"\n"), host, DirServerToHost)
+	if got := host.String(); host.lineCount() != 3 || !strings.HasSuffix(got, `{"jsonrpc":"2.0","id":2,"result":{}}`+"\n") {
+		t.Errorf("response to the reused id not forwarded: %s", got)
+	}
+}
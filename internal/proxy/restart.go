@@ -0,0 +1,95 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// restartHandshakeTimeout bounds replaying the host's handshake to a
+// restarted downstream.
+const restartHandshakeTimeout = 30 * time.Second
+
+// errDownstreamRestarted answers host requests the downstream had not
+// responded to when it was restarted.
+var errDownstreamRestarted = errors.New("downstream restarted before responding")
+
+// Restart stops the downstream process and starts it again without
+// disconnecting the host. The new process is sent the host's initialize
+// request and initialized notification, so the session carries on, and
+// host messages wait until it has answered. Host requests still awaiting
+// a response are answered with an error. Restart returns once the new
+// process is initialized. It is not supported in once mode.
+func (p *Proxy) Restart(ctx context.Context) error {
+	if p.once != nil {
+		return errors.New("restart is not supported in once mode")
+	}
+	done := make(chan error, 1)
+	select {
+	case p.restart <- done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// recordHandshake remembers the host's handshake messages as forwarded.
+func (p *Proxy) recordHandshake(method string, raw []byte) {
+	switch method {
+	case "initialize":
+		if msg, err := ParseMessage(raw); err == nil {
+			p.initParams.Store(&msg.Params)
+		}
+	case "notifications/initialized":
+		p.initialized.Store(true)
+	}
+}
+
+// replayHandshake initializes a restarted downstream the way the host
+// initialized the previous one. The initialize request uses a reserved
+// ID, so its response never reaches the host. Before the host has sent
+// initialize there is nothing to replay.
+func (p *Proxy) replayHandshake(ctx context.Context, w io.Writer) error {
+	saved := p.initParams.Load()
+	if saved == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, restartHandshakeTimeout)
+	defer cancel()
+
+	var params any
+	if len(*saved) > 0 {
+		params = *saved
+	}
+	resp, err := p.requestVia(ctx, w, "initialize", params)
+	if err != nil {
+		return fmt.Errorf("replay initialize: %w", err)
+	}
+	if resp.Error != nil {
+		return fmt.Errorf("replay initialize: server returned %d: %s", resp.Error.Code, resp.Error.Message)
+	}
+	if !p.initialized.Load() {
+		return nil
+	}
+	note, _ := json.Marshal(JSONRPCMessage{JSONRPC: "2.0", Method: "notifications/initialized"})
+	return writeWithRetry(ctx, w, append(note, '\n'))
+}
+
+// answerAbandoned answers the host requests a stopped downstream never
+// responded to.
+func (p *Proxy) answerAbandoned() {
+	for _, key := range p.tracker.abandon(DirHostToServer) {
+		resp := MakeErrorResponse(json.RawMessage(key.id), -32603, errDownstreamRestarted.Error())
+		if _, err := p.hostOut.Write(append(resp, '\n')); err != nil {
+			p.logger.Error("failed to answer abandoned request", "error", err)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// restartServer answers every request with its own PID and logs what it
+// reads, prefixed with the PID, to the file named by $1. Requests for
+// "slow" are never answered.
+const restartServer = `
+while IFS= read -r line; do
+	echo "$$ $line" >> "$1"
+	case "$line" in
+	*'"method":"slow"'*) ;;
+	*'"id":'*)
+		id=$(printf '%s\n' "$line" | sed 's/.*"id":\([^,}]*\).*/\1/')
+		printf '{"jsonrpc":"2.0","id":%s,"result":{"pid":%s}}\n' "$id" "$$"
+		;;
+	esac
+done`
+
+func TestProxy_Restart(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not available")
+	}
+	logPath := filepath.Join(t.TempDir(), "received.log")
+	p := NewProxy(Config{Command: sh, Args: []string{"-c", restartServer, "sh", logPath}}, NewInterceptorChain(), testLogger())
+	hostIn, hostWriter := io.Pipe()
+	p.hostIn = hostIn
+	host := &syncBuffer{}
+	p.hostOut = host
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	runErr := make(chan error, 1)
+	go func() { runErr <- p.Run(ctx) }()
+
+	send := func(line string) {
+		t.Helper()
+		if _, err := io.WriteString(hostWriter, line+"\n"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	received := func() string {
+		data, _ := os.ReadFile(logPath)
+		return string(data)
+	}
+	pidOf := func(id string) string {
+		t.Helper()
+		for _, line := range strings.Split(host.String(), "\n") {
+			var resp struct {
+				ID     json.RawMessage
+				Result struct{ PID json.Number }
+			}
+			if json.Unmarshal([]byte(line), &resp) == nil && string(resp.ID) == id {
+				return resp.Result.PID.String()
+			}
+		}
+		return ""
+	}
+
+	send(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26"}}`)
+	send(`{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	send(`{"jsonrpc":"2.0","id":2,"method":"slow"}`)
+	waitFor(t, func() bool { return pidOf("1") != "" && strings.Contains(received(), `"slow"`) })
+	first := pidOf("1")
+
+	if err := p.Restart(ctx); err != nil {
+		t.Fatalf("Restart: %v", err)
+	}
+
+	// The request the old server never answered gets an error
+	if !strings.Contains(host.String(), `"id":2,"error"`) {
+		t.Errorf("abandoned request not answered:\n%s", host.String())
+	}
+
+	send(`{"jsonrpc":"2.0","id":3,"method":"tools/list"}`)
+	waitFor(t, func() bool { return pidOf("3") != "" })
+	second := pidOf("3")
+	if second == first {
+		t.Fatalf("tools/list answered by the old server (pid %s)", first)
+	}
+
+	// The new server saw the host's handshake before the host's next request
+	var got []string
+	for _, line := range strings.Split(strings.TrimSpace(received()), "\n") {
+		if pid, msg, _ := strings.Cut(line, " "); pid == second {
+			got = append(got, msg)
+		}
+	}
+	if len(got) != 3 || !strings.Contains(got[0], `"method":"initialize"`) || !strings.Contains(got[0], `"protocolVersion":"2025-03-26"`) ||
+		!strings.Contains(got[1], "notifications/initialized") || !strings.Contains(got[2], "tools/list") {
+		t.Errorf("new server received:\n%s", strings.Join(got, "\n"))
+	}
+	// The replayed initialize is answered to the proxy, not the host
+	if strings.Count(host.String(), `"pid"`) != 2 {
+		t.Errorf("host received:\n%s", host.String())
+	}
+
+	hostWriter.Close()
+	select {
+	case err := <-runErr:
+		if err != nil {
+			t.Errorf("Run = %v", err)
+		}
+	case <-ctx.Done():
+		t.Fatal("proxy did not exit after the host disconnected")
+	}
+}
+
+func TestProxy_RestartOnceMode(t *testing.T) {
+	p := NewProxy(Config{Command: "test", Once: true}, NewInterceptorChain(), testLogger())
+	if err := p.Restart(context.Background()); err == nil {
+		t.Error("Restart in once mode succeeded")
+	}
+}
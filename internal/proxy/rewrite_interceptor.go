@@ -0,0 +1,135 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/contextgate/contextgate/internal/policy"
+)
+
+// MetaKeyRewrites holds the names of the rewrite rules applied to a message.
+const MetaKeyRewrites = "rewrites"
+
+// RewriteInterceptor applies the policy's JSON Patch rewrite rules to
+// server→host messages. Responses are matched by the method and tool of
+// the request they answer, so requests are remembered on the way out. A
+// patch that fails, or that would change the message's id or jsonrpc
+// version, leaves the message as the server sent it.
+type RewriteInterceptor struct {
+	rules   []policy.RewriteRule
+	logger  *slog.Logger
+	pending *callCorrelator[rewriteCall] // matching requests awaiting a response
+}
+
+type rewriteCall struct {
+	method string
+	tool   string
+}
+
+// NewRewriteInterceptor creates an interceptor for compiled rewrite rules,
+// as loaded from a policy file.
+func NewRewriteInterceptor(rules []policy.RewriteRule, logger *slog.Logger) *RewriteInterceptor {
+	return &RewriteInterceptor{
+		rules:   rules,
+		logger:  logger,
+		pending: newCallCorrelator[rewriteCall](),
+	}
+}
+
+func (rw *RewriteInterceptor) matching(method, tool string) []*policy.RewriteRule {
+	var out []*policy.RewriteRule
+	for i := range rw.rules {
+		if rw.rules[i].Matches(method, tool) {
+			out = append(out, &rw.rules[i])
+		}
+	}
+	return out
+}
+
+func (rw *RewriteInterceptor) Intercept(_ context.Context, msg *InterceptedMessage) ([]byte, error) {
+	if msg.ParseErr != nil {
+		return msg.RawBytes, nil
+	}
+
+	if msg.Direction == DirHostToServer {
+		if msg.Parsed.ID == nil || msg.Parsed.Method == "" {
+			return msg.RawBytes, nil
+		}
+		call := rewriteCall{method: msg.Parsed.Method}
+		if call.method == "tools/call" {
+			call.tool = extractToolNameFromParams(msg.Parsed.Params)
+		}
+		if len(rw.matching(call.method, call.tool)) > 0 {
+			rw.pending.remember(msg, call)
+		}
+		return msg.RawBytes, nil
+	}
+
+	var method, tool string
+	if msg.Parsed.Kind() == KindResponse {
+		call, found := rw.pending.answer(msg)
+		if !found {
+			return msg.RawBytes, nil
+		}
+		method, tool = call.method, call.tool
+	} else {
+		method = msg.Parsed.Method
+	}
+
+	rules := rw.matching(method, tool)
+	if len(rules) == 0 {
+		return msg.RawBytes, nil
+	}
+	out, applied, err := rewrite(msg.RawBytes, rules)
+	if err != nil {
+		rw.logger.Warn("rewrite failed, forwarding original message", "method", method, "tool", tool, "error", err)
+		return msg.RawBytes, nil
+	}
+	if msg.Metadata == nil {
+		msg.Metadata = make(map[string]any)
+	}
+	msg.Metadata[MetaKeyRewrites] = applied
+	return out, nil
+}
+
+// rewrite applies every rule's patch to raw in order and returns the new
+// encoding with the names of the rules applied.
+func rewrite(raw []byte, rules []*policy.RewriteRule) ([]byte, []string, error) {
+	var orig, doc map[string]any
+	if err := policy.DecodeJSON(raw, &orig); err != nil {
+		return nil, nil, err
+	}
+	if err := policy.DecodeJSON(raw, &doc); err != nil {
+		return nil, nil, err
+	}
+
+	var patched any = doc
+	var applied []string
+	for _, rule := range rules {
+		var err error
+		if patched, err = policy.ApplyPatch(patched, rule.Patch); err != nil {
+			return nil, nil, fmt.Errorf("rule %q: %w", rule.Name, err)
+		}
+		applied = append(applied, rule.Name)
+	}
+
+	out, ok := patched.(map[string]any)
+	if !ok {
+		return nil, nil, fmt.Errorf("patched message is not a JSON object")
+	}
+	for _, field := range []string{"jsonrpc", "id"} {
+		before, _ := json.Marshal(orig[field])
+		after, _ := json.Marshal(out[field])
+		if !bytes.Equal(before, after) {
+			return nil, nil, fmt.Errorf("patch changed the message %s", field)
+		}
+	}
+	data, err := json.Marshal(out)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, applied, nil
+}
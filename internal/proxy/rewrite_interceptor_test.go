@@ -0,0 +1,137 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/contextgate/contextgate/internal/policy"
+)
+
+func newTestRewriteInterceptor(t *testing.T, src string) *RewriteInterceptor {
+	t.Helper()
+	cfg, err := policy.LoadBytes([]byte(src))
+	if err != nil {
+		t.Fatalf("LoadBytes: %v", err)
+	}
+	return NewRewriteInterceptor(cfg.Rewrites, testLogger())
+}
+
+// rewriteExchange sends a tools/call for tool through rw and returns what
+// becomes of the server's response.
+func rewriteExchange(t *testing.T, rw *RewriteInterceptor, tool, response string) (*InterceptedMessage, []byte) {
+	t.Helper()
+	ctx := context.Background()
+	call := parsedMessage(t, DirHostToServer, `{"jsonrpc":"2.0","id":7,"method":"tools/call","params":{"name":"`+tool+`"}}`)
+	if _, err := rw.Intercept(ctx, call); err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	resp := parsedMessage(t, DirServerToHost, response)
+	out, err := rw.Intercept(ctx, resp)
+	if err != nil {
+		t.Fatalf("response: %v", err)
+	}
+	return resp, out
+}
+
+const rewritePolicy = `
+version: "1"
+rewrites:
+  - name: slim-results
+    methods: ["tools/call"]
+    tools: ["search"]
+    patch:
+      - op: remove
+        path: /result/metadata
+      - op: replace
+        path: /result/content/0/text
+        value: "[trimmed]"
+`
+
+func TestRewrite_RemoveAndReplace(t *testing.T) {
+	rw := newTestRewriteInterceptor(t, rewritePolicy)
+	msg, out := rewriteExchange(t, rw, "search",
+		`{"jsonrpc":"2.0","id":7,"result":{"content":[{"type":"text","text":"long output"}],"metadata":{"trace":"abc"}}}`)
+
+	var got map[string]any
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, out)
+	}
+	want := map[string]any{
+		"jsonrpc": "2.0",
+		"id":      float64(7),
+		"result": map[string]any{
+			"content": []any{map[string]any{"type": "text", "text": "[trimmed]"}},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %s", out)
+	}
+	if applied, _ := msg.Metadata[MetaKeyRewrites].([]string); !reflect.DeepEqual(applied, []string{"slim-results"}) {
+		t.Errorf("metadata = %v, want [slim-results]", msg.Metadata[MetaKeyRewrites])
+	}
+}
+
+func TestRewrite_FallsBackOnError(t *testing.T) {
+	rw := newTestRewriteInterceptor(t, rewritePolicy)
+	// No metadata field, so the remove fails and nothing is changed
+	orig := `{"jsonrpc":"2.0","id":7,"result":{"content":[{"type":"text","text":"long output"}]}}`
+	msg, out := rewriteExchange(t, rw, "search", orig)
+	if string(out) != orig {
+		t.Errorf("got %s, want the original", out)
+	}
+	if _, ok := msg.Metadata[MetaKeyRewrites]; ok {
+		t.Error("failed rewrite should not be recorded")
+	}
+}
+
+func TestRewrite_RejectsIDChange(t *testing.T) {
+	rw := newTestRewriteInterceptor(t, `
+version: "1"
+rewrites:
+  - name: bad
+    patch:
+      - op: replace
+        path: /id
+        value: 99
+`)
+	orig := `{"jsonrpc":"2.0","id":7,"result":{}}`
+	if _, out := rewriteExchange(t, rw, "search", orig); string(out) != orig {
+		t.Errorf("got %s, want the original", out)
+	}
+}
+
+func TestRewrite_OnlyMatchingCalls(t *testing.T) {
+	rw := newTestRewriteInterceptor(t, rewritePolicy)
+	orig := `{"jsonrpc":"2.0","id":7,"result":{"content":[],"metadata":{}}}`
+	if _, out := rewriteExchange(t, rw, "fetch", orig); string(out) != orig {
+		t.Errorf("other tool rewritten: %s", out)
+	}
+
+	// A response nobody asked for through this interceptor is untouched
+	out, err := rw.Intercept(context.Background(), parsedMessage(t, DirServerToHost, orig))
+	if err != nil || string(out) != orig {
+		t.Errorf("uncorrelated response rewritten: %s, %v", out, err)
+	}
+}
+
+func TestRewrite_Notification(t *testing.T) {
+	rw := newTestRewriteInterceptor(t, `
+version: "1"
+rewrites:
+  - name: quiet-progress
+    methods: ["notifications/progress"]
+    patch:
+      - op: remove
+        path: /params/message
+`)
+	out, err := rw.Intercept(context.Background(), parsedMessage(t, DirServerToHost,
+		`{"jsonrpc":"2.0","method":"notifications/progress","params":{"progress":1,"message":"working"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"jsonrpc":"2.0","method":"notifications/progress","params":{"progress":1}}`; string(out) != want {
+		t.Errorf("got %s, want %s", out, want)
+	}
+}
@@ -0,0 +1,71 @@
+package proxy
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// ScrubProfile accumulates how long each scrubber pattern spends
+// searching string values, to find patterns worth disabling on
+// high-volume servers. Timing every pattern has a cost of its own, so it
+// is only collected when a profile is set on the scrubber.
+type ScrubProfile struct {
+	mu    sync.Mutex
+	stats map[string]*PatternTiming
+}
+
+// PatternTiming is one pattern's accumulated scrub work.
+type PatternTiming struct {
+	Pattern  string
+	Calls    uint64 // strings searched
+	Bytes    uint64 // bytes searched
+	Matches  uint64 // values redacted
+	Duration time.Duration
+}
+
+// Throughput returns the bytes searched per second, or 0 before any
+// timing is recorded.
+func (t PatternTiming) Throughput() float64 {
+	if t.Duration <= 0 {
+		return 0
+	}
+	return float64(t.Bytes) / t.Duration.Seconds()
+}
+
+func NewScrubProfile() *ScrubProfile {
+	return &ScrubProfile{stats: make(map[string]*PatternTiming)}
+}
+
+// Observe records one pass of the named pattern over n bytes.
+func (p *ScrubProfile) Observe(pattern string, d time.Duration, n, matches int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	st, ok := p.stats[pattern]
+	if !ok {
+		st = &PatternTiming{Pattern: pattern}
+		p.stats[pattern] = st
+	}
+	st.Calls++
+	st.Bytes += uint64(n)
+	st.Matches += uint64(matches)
+	st.Duration += d
+}
+
+// Snapshot returns the timings of every pattern used so far, slowest
+// (most total time) first.
+func (p *ScrubProfile) Snapshot() []PatternTiming {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := make([]PatternTiming, 0, len(p.stats))
+	for _, st := range p.stats {
+		out = append(out, *st)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Duration != out[j].Duration {
+			return out[i].Duration > out[j].Duration
+		}
+		return out[i].Pattern < out[j].Pattern
+	})
+	return out
+}
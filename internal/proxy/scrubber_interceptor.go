@@ -3,17 +3,80 @@ package proxy
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
 	"regexp"
+	"slices"
+	"strings"
+	"sync"
 	"sync/atomic"
+	"time"
+	"unicode/utf8"
 
 	"github.com/contextgate/contextgate/internal/policy"
 )
 
+// DefaultContextWindow is how many characters either side of a match are
+// searched for context keywords when a pattern doesn't set a window.
+const DefaultContextWindow = 32
+
 // piiPattern represents a named PII detection pattern.
 type piiPattern struct {
 	Name  string
 	Regex *regexp.Regexp
 	Label string // replacement label, e.g. "api_key" → [REDACTED:api_key]
+
+	// Context, if set, lists keywords one of which must appear within
+	// Window characters of a match for it to be redacted.
+	Context []string
+	Window  int
+}
+
+// hasContext reports whether the match at input[start:end] should be
+// redacted: always without context keywords, otherwise only when one of
+// them appears as a whole word near the match.
+func (p *piiPattern) hasContext(input string, start, end int) bool {
+	if len(p.Context) == 0 {
+		return true
+	}
+	window := p.Window
+	if window <= 0 {
+		window = DefaultContextWindow
+	}
+	before := strings.ToLower(input[max(0, start-window):start])
+	after := strings.ToLower(input[end:min(len(input), end+window)])
+	for _, hint := range p.Context {
+		if containsWord(before, hint) || containsWord(after, hint) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsWord reports whether word occurs in s, lowercase, not flanked
+// by letters or digits, so "ip" matches "IP:" but not "zip".
+func containsWord(s, word string) bool {
+	for i := 0; word != ""; {
+		j := strings.Index(s[i:], word)
+		if j < 0 {
+			return false
+		}
+		start, end := i+j, i+j+len(word)
+		if !isWordByte(s, start-1) && !isWordByte(s, end) {
+			return true
+		}
+		i = start + 1
+	}
+	return false
+}
+
+func isWordByte(s string, i int) bool {
+	if i < 0 || i >= len(s) {
+		return false
+	}
+	c := s[i]
+	return c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '_' || c >= utf8.RuneSelf
 }
 
 // default PII patterns
@@ -30,42 +93,138 @@ var defaultPIIPatterns = []piiPattern{
 	{Name: "ipv4", Regex: regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`), Label: "ip_address"},
 }
 
-// ScrubberInterceptor redacts PII from server-to-host messages.
+// DefaultScrubMaxDepth is how deeply nested a JSON value the scrubber
+// walks by default. MCP messages rarely nest more than a dozen levels.
+const DefaultScrubMaxDepth = 64
+
+// ScrubberInterceptor redacts PII from the messages it is given. The
+// pipeline limits it to server-to-host messages with OnlyDirection. Its
+// pattern set, and whether it scrubs at all, can be changed while
+// messages are being scrubbed.
 type ScrubberInterceptor struct {
-	patterns      []piiPattern
-	enabled       bool
+	patterns      atomic.Pointer[[]piiPattern]
+	patternsMu    sync.Mutex // serializes pattern updates
+	enabled       atomic.Bool
 	totalScrubbed atomic.Int64
+
+	// PreserveLength replaces each matched character with Fill instead of
+	// a [REDACTED:label] marker, so redacted strings keep their length.
+	PreserveLength bool
+	Fill           rune // defaults to '*'
+
+	// MaxDepth bounds the recursive walk of a message (default
+	// DefaultScrubMaxDepth). Subtrees nested deeper are scrubbed as
+	// serialized JSON text instead, with a warning to Logger, if set.
+	MaxDepth int
+	Logger   *slog.Logger
+
+	// Profile, if set, records the time each pattern spends on every
+	// string it searches.
+	Profile *ScrubProfile
 }
 
 // NewScrubberInterceptor creates a scrubber with default + custom patterns.
-func NewScrubberInterceptor(enabled bool, customPatterns []policy.CustomPattern) *ScrubberInterceptor {
-	s := &ScrubberInterceptor{
-		patterns: append([]piiPattern{}, defaultPIIPatterns...),
-		enabled:  enabled,
+// It fails if any custom pattern is not a valid regex, rather than
+// silently scrubbing less than configured.
+func NewScrubberInterceptor(enabled bool, customPatterns []policy.CustomPattern) (*ScrubberInterceptor, error) {
+	patterns, err := buildPatterns(customPatterns, nil)
+	if err != nil {
+		return nil, err
 	}
+	s := &ScrubberInterceptor{}
+	s.enabled.Store(enabled)
+	s.patterns.Store(&patterns)
+	return s, nil
+}
 
+// SetEnabled turns scrubbing of forwarded traffic on or off, as on a
+// policy reload. Redact and Matches are not affected.
+func (s *ScrubberInterceptor) SetEnabled(enabled bool) {
+	s.enabled.Store(enabled)
+}
+
+// SetPatterns replaces the custom patterns and the context keywords of
+// all patterns in one step, as on a policy reload. Messages already being
+// scrubbed finish with the previous set. On error nothing changes.
+func (s *ScrubberInterceptor) SetPatterns(customPatterns []policy.CustomPattern, contexts map[string]policy.PatternContext) error {
+	patterns, err := buildPatterns(customPatterns, contexts)
+	if err != nil {
+		return err
+	}
+	s.patternsMu.Lock()
+	defer s.patternsMu.Unlock()
+	s.patterns.Store(&patterns)
+	return nil
+}
+
+// buildPatterns returns the built-in patterns followed by the custom
+// ones, with contexts applied by pattern name.
+func buildPatterns(customPatterns []policy.CustomPattern, contexts map[string]policy.PatternContext) ([]piiPattern, error) {
+	patterns := append([]piiPattern{}, defaultPIIPatterns...)
+
+	var errs []error
 	for _, cp := range customPatterns {
-		re, err := regexp.Compile(cp.Pattern)
+		re, err := cp.Regexp()
 		if err != nil {
+			errs = append(errs, fmt.Errorf("custom pattern %q: %w", cp.Name, err))
 			continue
 		}
-		s.patterns = append(s.patterns, piiPattern{
-			Name:  cp.Name,
-			Regex: re,
-			Label: cp.Label,
+		patterns = append(patterns, piiPattern{
+			Name:    cp.Name,
+			Regex:   re,
+			Label:   cp.Label,
+			Context: lowerAll(cp.RequireContext),
+			Window:  cp.ContextWindow,
 		})
 	}
+	for name, pc := range contexts {
+		if err := setContext(patterns, name, pc); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return patterns, nil
+}
 
-	return s
+// RequireContext restricts the named pattern, built-in or custom, to
+// matches near one of the given keywords. An empty list removes the
+// restriction.
+func (s *ScrubberInterceptor) RequireContext(name string, pc policy.PatternContext) error {
+	s.patternsMu.Lock()
+	defer s.patternsMu.Unlock()
+	patterns := slices.Clone(*s.patterns.Load())
+	if err := setContext(patterns, name, pc); err != nil {
+		return err
+	}
+	s.patterns.Store(&patterns)
+	return nil
 }
 
-func (s *ScrubberInterceptor) Intercept(_ context.Context, msg *InterceptedMessage) ([]byte, error) {
-	if !s.enabled {
-		return msg.RawBytes, nil
+func setContext(patterns []piiPattern, name string, pc policy.PatternContext) error {
+	for i := range patterns {
+		if patterns[i].Name == name {
+			patterns[i].Context = lowerAll(pc.RequireContext)
+			patterns[i].Window = pc.ContextWindow
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown scrubber pattern %q", name)
+}
+
+func lowerAll(words []string) []string {
+	var out []string
+	for _, w := range words {
+		if w = strings.ToLower(strings.TrimSpace(w)); w != "" {
+			out = append(out, w)
+		}
 	}
+	return out
+}
 
-	// Only scrub server→host traffic
-	if msg.Direction != DirServerToHost {
+func (s *ScrubberInterceptor) Intercept(_ context.Context, msg *InterceptedMessage) ([]byte, error) {
+	if !s.enabled.Load() {
 		return msg.RawBytes, nil
 	}
 
@@ -82,6 +241,72 @@ func (s *ScrubberInterceptor) Intercept(_ context.Context, msg *InterceptedMessa
 	return scrubbed, nil
 }
 
+// Redact applies the scrubber's patterns to a payload regardless of whether
+// scrubbing of forwarded traffic is enabled. It is used for copies of a
+// message that are shown to humans (e.g. approval prompts).
+func (s *ScrubberInterceptor) Redact(raw []byte) ([]byte, int) {
+	return s.scrubJSON(raw)
+}
+
+// scrubMatch is a value a pattern would redact.
+type scrubMatch struct {
+	Pattern string
+	Value   string
+}
+
+// hasPattern reports whether a built-in or custom pattern is named name.
+func (s *ScrubberInterceptor) hasPattern(name string) bool {
+	for _, p := range *s.patterns.Load() {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// findMatches returns the values in raw's strings that the patterns
+// selected by keep would redact, whether or not scrubbing is enabled.
+// Nothing is changed.
+func (s *ScrubberInterceptor) findMatches(raw []byte, keep func(name, label string) bool) []scrubMatch {
+	var strs []string
+	var parsed any
+	if err := json.Unmarshal(raw, &parsed); err != nil {
+		strs = []string{string(raw)}
+	} else {
+		// Walked with a stack rather than recursion, however deep it nests
+		stack := []any{parsed}
+		for len(stack) > 0 {
+			v := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			switch val := v.(type) {
+			case string:
+				strs = append(strs, val)
+			case map[string]any:
+				for _, v := range val {
+					stack = append(stack, v)
+				}
+			case []any:
+				stack = append(stack, val...)
+			}
+		}
+	}
+
+	var out []scrubMatch
+	for _, p := range *s.patterns.Load() {
+		if !keep(p.Name, p.Label) {
+			continue
+		}
+		for _, str := range strs {
+			for _, m := range p.Regex.FindAllStringIndex(str, -1) {
+				if p.hasContext(str, m[0], m[1]) {
+					out = append(out, scrubMatch{Pattern: p.Name, Value: str[m[0]:m[1]]})
+				}
+			}
+		}
+	}
+	return out
+}
+
 // scrubJSON parses JSON, walks string values, applies PII regexes,
 // and re-serializes. JSON structure keys are not modified.
 func (s *ScrubberInterceptor) scrubJSON(raw []byte) ([]byte, int) {
@@ -91,33 +316,56 @@ func (s *ScrubberInterceptor) scrubJSON(raw []byte) ([]byte, int) {
 		return []byte(result), count
 	}
 
-	count := 0
-	scrubbed := s.walkAndScrub(parsed, &count)
+	// One pattern set for the whole message, even if it is swapped meanwhile
+	w := scrubWalk{patterns: *s.patterns.Load(), maxDepth: s.MaxDepth}
+	if w.maxDepth <= 0 {
+		w.maxDepth = DefaultScrubMaxDepth
+	}
+	scrubbed := s.walkAndScrub(parsed, 0, &w)
+	if w.tooDeep && s.Logger != nil {
+		s.Logger.Warn("scrubber max depth exceeded, scrubbed nested values as text", "max_depth", w.maxDepth)
+	}
 
 	result, err := json.Marshal(scrubbed)
 	if err != nil {
 		return raw, 0
 	}
-	return result, count
+	return result, w.count
+}
+
+// scrubWalk holds the state of one walkAndScrub pass.
+type scrubWalk struct {
+	patterns []piiPattern
+	maxDepth int
+	count    int
+	tooDeep  bool // a subtree was nested deeper than maxDepth
 }
 
-// walkAndScrub recursively walks a parsed JSON value and scrubs string values.
-func (s *ScrubberInterceptor) walkAndScrub(v any, count *int) any {
+// walkAndScrub recursively walks a parsed JSON value and scrubs string
+// values. Below the maximum depth the rest of the subtree is scrubbed as
+// one serialized string rather than recursed into.
+func (s *ScrubberInterceptor) walkAndScrub(v any, depth int, w *scrubWalk) any {
 	switch val := v.(type) {
 	case string:
-		scrubbed, c := s.scrubString(val)
-		*count += c
+		scrubbed, c := s.scrubWith(w.patterns, val)
+		w.count += c
 		return scrubbed
 	case map[string]any:
+		if depth >= w.maxDepth {
+			return s.scrubSubtree(val, w)
+		}
 		result := make(map[string]any, len(val))
 		for k, v := range val {
-			result[k] = s.walkAndScrub(v, count)
+			result[k] = s.walkAndScrub(v, depth+1, w)
 		}
 		return result
 	case []any:
+		if depth >= w.maxDepth {
+			return s.scrubSubtree(val, w)
+		}
 		result := make([]any, len(val))
 		for i, v := range val {
-			result[i] = s.walkAndScrub(v, count)
+			result[i] = s.walkAndScrub(v, depth+1, w)
 		}
 		return result
 	default:
@@ -125,21 +373,81 @@ func (s *ScrubberInterceptor) walkAndScrub(v any, count *int) any {
 	}
 }
 
+// scrubSubtree scrubs a container as serialized JSON text. The result is
+// kept as JSON if redaction left it valid, and as a string otherwise.
+func (s *ScrubberInterceptor) scrubSubtree(v any, w *scrubWalk) any {
+	w.tooDeep = true
+	text, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	scrubbed, c := s.scrubWith(w.patterns, string(text))
+	w.count += c
+	if json.Valid([]byte(scrubbed)) {
+		return json.RawMessage(scrubbed)
+	}
+	return scrubbed
+}
+
 // scrubString applies all PII patterns to a string.
 func (s *ScrubberInterceptor) scrubString(input string) (string, int) {
+	return s.scrubWith(*s.patterns.Load(), input)
+}
+
+// scrubWith applies the given patterns to a string.
+func (s *ScrubberInterceptor) scrubWith(patterns []piiPattern, input string) (string, int) {
 	count := 0
 	result := input
-	for _, p := range s.patterns {
+	for i := range patterns {
+		p := &patterns[i]
+		var start time.Time
+		if s.Profile != nil {
+			start = time.Now()
+		}
+		searched := len(result)
 		matches := p.Regex.FindAllStringIndex(result, -1)
-		if len(matches) > 0 {
-			count += len(matches)
-			replacement := "[REDACTED:" + p.Label + "]"
-			result = p.Regex.ReplaceAllString(result, replacement)
+		if len(matches) == 0 {
+			if s.Profile != nil {
+				s.Profile.Observe(p.Name, time.Since(start), searched, 0)
+			}
+			continue
+		}
+		var b strings.Builder
+		last, redacted := 0, 0
+		for _, m := range matches {
+			if !p.hasContext(result, m[0], m[1]) {
+				continue
+			}
+			b.WriteString(result[last:m[0]])
+			if s.PreserveLength {
+				b.WriteString(s.fillMatch(result[m[0]:m[1]]))
+			} else {
+				b.WriteString("[REDACTED:" + p.Label + "]")
+			}
+			last = m[1]
+			redacted++
+		}
+		if redacted > 0 {
+			count += redacted
+			b.WriteString(result[last:])
+			result = b.String()
+		}
+		if s.Profile != nil {
+			s.Profile.Observe(p.Name, time.Since(start), searched, redacted)
 		}
 	}
 	return result, count
 }
 
+// fillMatch returns a run of fill characters as long as match.
+func (s *ScrubberInterceptor) fillMatch(match string) string {
+	fill := s.Fill
+	if fill == 0 {
+		fill = '*'
+	}
+	return strings.Repeat(string(fill), utf8.RuneCountInString(match))
+}
+
 // TotalScrubbed returns the total number of PII items scrubbed.
 func (s *ScrubberInterceptor) TotalScrubbed() int64 {
 	return s.totalScrubbed.Load()
@@ -1,8 +1,12 @@
 package proxy
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"log/slog"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -10,7 +14,8 @@ import (
 )
 
 func newTestScrubber(enabled bool) *ScrubberInterceptor {
-	return NewScrubberInterceptor(enabled, nil)
+	s, _ := NewScrubberInterceptor(enabled, nil)
+	return s
 }
 
 func scrubMsg(t *testing.T, s *ScrubberInterceptor, dir Direction, payload string) (string, *InterceptedMessage) {
@@ -110,6 +115,72 @@ func TestScrubber_NestedJSON(t *testing.T) {
 	}
 }
 
+// nestedPayload wraps a value in depth levels of alternating objects
+// and arrays.
+func nestedPayload(depth int, inner string) string {
+	open, close := "", ""
+	for i := range depth {
+		if i%2 == 0 {
+			open += `{"a":`
+			close = "}" + close
+		} else {
+			open += "["
+			close = "]" + close
+		}
+	}
+	return open + inner + close
+}
+
+func TestScrubber_MaxDepth(t *testing.T) {
+	var logs bytes.Buffer
+	s := newTestScrubber(true)
+	s.MaxDepth = 8
+	s.Logger = slog.New(slog.NewTextHandler(&logs, nil))
+
+	// Deep enough to blow a naive walk's budget, still scrubbed
+	payload := nestedPayload(5000, `"mail admin@example.com"`)
+	result, msg := scrubMsg(t, s, DirServerToHost, payload)
+	if strings.Contains(result, "admin@example.com") {
+		t.Fatal("email below the max depth was not scrubbed")
+	}
+	if !json.Valid([]byte(result)) || !strings.HasPrefix(result, nestedPayload(8, "")[:20]) {
+		t.Errorf("scrubbed payload lost its structure: %.80s", result)
+	}
+	if msg.Metadata[MetaKeyScrubCount] != 1 {
+		t.Errorf("scrub count = %v, want 1", msg.Metadata[MetaKeyScrubCount])
+	}
+	if !strings.Contains(logs.String(), "max depth exceeded") {
+		t.Errorf("no warning logged: %q", logs.String())
+	}
+
+	// Within the limit nothing is logged
+	logs.Reset()
+	scrubMsg(t, s, DirServerToHost, nestedPayload(6, `"admin@example.com"`))
+	if logs.Len() != 0 {
+		t.Errorf("unexpected warning: %q", logs.String())
+	}
+}
+
+func TestScrubber_MaxDepthInvalidAfterRedaction(t *testing.T) {
+	// A custom pattern spanning JSON syntax leaves invalid text; the
+	// subtree is kept as a string rather than dropped
+	s, err := NewScrubberInterceptor(true, []policy.CustomPattern{{Name: "quoted", Pattern: `"x":"[^"]*"`, Label: "quoted"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.MaxDepth = 2
+	result, _ := scrubMsg(t, s, DirServerToHost, `{"result":{"deep":{"x":"secret"}}}`)
+	var doc struct {
+		Result map[string]any
+	}
+	if err := json.Unmarshal([]byte(result), &doc); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, result)
+	}
+	if deep, ok := doc.Result["deep"].(string); !ok || strings.Contains(deep, "secret") {
+		t.Errorf("deep = %#v, want the scrubbed text", doc.Result["deep"])
+	}
+}
+
 func TestScrubber_MultiplePII(t *testing.T) {
 	s := newTestScrubber(true)
 	result, msg := scrubMsg(t, s, DirServerToHost, `{"result":"key sk-aaaabbbbccccddddeeeefffff and email test@test.com"}`)
@@ -123,10 +194,14 @@ func TestScrubber_MultiplePII(t *testing.T) {
 }
 
 func TestScrubber_HostToServer_Ignored(t *testing.T) {
-	s := newTestScrubber(true)
+	chain := NewInterceptorChain(OnlyDirection(DirServerToHost, newTestScrubber(true)))
 	payload := `{"params":{"key":"sk-abcdefghijklmnopqrstuvwxyz1234567890"}}`
-	result, _ := scrubMsg(t, s, DirHostToServer, payload)
-	if result != payload {
+	msg := &InterceptedMessage{Direction: DirHostToServer, RawBytes: []byte(payload)}
+	result, err := chain.Process(context.Background(), msg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(result) != payload {
 		t.Fatalf("expected host_to_server to pass through unchanged, got: %s", result)
 	}
 }
@@ -141,9 +216,12 @@ func TestScrubber_Disabled(t *testing.T) {
 }
 
 func TestScrubber_CustomPatterns(t *testing.T) {
-	s := NewScrubberInterceptor(true, []policy.CustomPattern{
+	s, err := NewScrubberInterceptor(true, []policy.CustomPattern{
 		{Name: "custom-token", Pattern: `tok_[a-zA-Z0-9]{16}`, Label: "custom_token"},
 	})
+	if err != nil {
+		t.Fatal(err)
+	}
 	result, _ := scrubMsg(t, s, DirServerToHost, `{"result":"token tok_abcdef1234567890"}`)
 	if strings.Contains(result, "tok_") {
 		t.Fatalf("expected custom token to be scrubbed, got: %s", result)
@@ -153,6 +231,19 @@ func TestScrubber_CustomPatterns(t *testing.T) {
 	}
 }
 
+func TestScrubber_InvalidCustomPattern(t *testing.T) {
+	_, err := NewScrubberInterceptor(true, []policy.CustomPattern{
+		{Name: "good", Pattern: `tok_[a-z]+`, Label: "token"},
+		{Name: "typo", Pattern: `tok_[a-z+`, Label: "token"},
+	})
+	if err == nil {
+		t.Fatal("expected an error for the invalid pattern")
+	}
+	if !strings.Contains(err.Error(), `"typo"`) {
+		t.Errorf("error %q does not name the bad pattern", err)
+	}
+}
+
 func TestScrubber_TotalCount(t *testing.T) {
 	s := newTestScrubber(true)
 	scrubMsg(t, s, DirServerToHost, `{"result":"sk-abcdefghijklmnopqrstuvwxyz1234567890"}`)
@@ -162,3 +253,257 @@ func TestScrubber_TotalCount(t *testing.T) {
 		t.Fatalf("expected total scrubbed >= 2, got %d", s.TotalScrubbed())
 	}
 }
+
+func TestScrubber_PreserveLength(t *testing.T) {
+	s := newTestScrubber(true)
+	s.PreserveLength = true
+
+	inputs := []string{
+		"key sk-abcdefghijklmnopqrstuvwxyz1234",
+		"mail alice@example.com and bob@example.org",
+		"ssn 123-45-6789, ip 10.0.0.1",
+		"no pii here",
+	}
+	for _, in := range inputs {
+		out, count := s.scrubString(in)
+		if len(out) != len(in) {
+			t.Errorf("length changed for %q: %d → %d (%q)", in, len(in), len(out), out)
+		}
+		if strings.Contains(out, "REDACTED") {
+			t.Errorf("marker inserted in preserve-length mode: %q", out)
+		}
+		if count > 0 && out == in {
+			t.Errorf("matched %d items but nothing was masked: %q", count, out)
+		}
+	}
+
+	out, _ := s.scrubString("ssn 123-45-6789")
+	if out != "ssn ***********" {
+		t.Errorf("got %q, want matched region filled with '*'", out)
+	}
+}
+
+func TestScrubber_PreserveLengthCustomFill(t *testing.T) {
+	s := newTestScrubber(true)
+	s.PreserveLength = true
+	s.Fill = '#'
+
+	result, _ := scrubMsg(t, s, DirServerToHost, `{"result":{"text":"call 123-45-6789"}}`)
+	if !strings.Contains(result, `"call ###########"`) {
+		t.Errorf("expected fixed-width mask, got %s", result)
+	}
+}
+
+func TestScrubber_RequireContext(t *testing.T) {
+	s := newTestScrubber(true)
+	if err := s.RequireContext("ipv4", policy.PatternContext{RequireContext: []string{"IP", "address"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RequireContext("ssn", policy.PatternContext{RequireContext: []string{"ssn", "social"}, ContextWindow: 10}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		input  string
+		redact bool
+	}{
+		{"ip with hint", "client IP: 10.0.0.1", true},
+		{"ip hint after", "10.0.0.1 is the address", true},
+		{"version string", "upgraded to 10.0.0.1 today", false},
+		{"hint inside a word", "zip 10.0.0.1", false},
+		{"ssn with hint", "Social: 123-45-6789", true},
+		{"ssn hint outside window", "ssn is not what follows: 123-45-6789", false},
+		{"part number", "part 123-45-6789 in stock", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, count := s.scrubString(tt.input)
+			if redacted := count > 0; redacted != tt.redact {
+				t.Errorf("scrubString(%q) = %q, want redacted=%v", tt.input, result, tt.redact)
+			}
+		})
+	}
+}
+
+func TestScrubber_CustomPatternContext(t *testing.T) {
+	s, err := NewScrubberInterceptor(true, []policy.CustomPattern{{
+		Name: "phone", Pattern: `\b\d{3}-\d{3}-\d{4}\b`, Label: "phone",
+		PatternContext: policy.PatternContext{RequireContext: []string{"phone", "tel"}, ContextWindow: 8},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, _ := scrubMsg(t, s, DirServerToHost, `{"result":"tel 555-123-4567, order 555-123-4567"}`)
+	if want := `tel [REDACTED:phone], order 555-123-4567`; !strings.Contains(result, want) {
+		t.Errorf("got %s, want %q", result, want)
+	}
+}
+
+func TestScrubber_RequireContextUnknownPattern(t *testing.T) {
+	s := newTestScrubber(true)
+	if err := s.RequireContext("nope", policy.PatternContext{RequireContext: []string{"x"}}); err == nil {
+		t.Error("expected an error for an unknown pattern")
+	}
+}
+
+func TestScrubber_SetPatterns(t *testing.T) {
+	s, err := NewScrubberInterceptor(true, []policy.CustomPattern{
+		{Name: "ticket", Pattern: `TICKET-\d+`, Label: "ticket"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := `{"jsonrpc":"2.0","id":1,"result":{"text":"TICKET-42 for PROJ-7"}}`
+
+	out, _ := scrubMsg(t, s, DirServerToHost, payload)
+	if !strings.Contains(out, "[REDACTED:ticket]") || !strings.Contains(out, "PROJ-7") {
+		t.Fatalf("before swap: %s", out)
+	}
+
+	err = s.SetPatterns([]policy.CustomPattern{
+		{Name: "project", Pattern: `PROJ-\d+`, Label: "project"},
+	}, map[string]policy.PatternContext{"project": {RequireContext: []string{"for"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, _ = scrubMsg(t, s, DirServerToHost, payload)
+	if !strings.Contains(out, "TICKET-42") || !strings.Contains(out, "[REDACTED:project]") {
+		t.Errorf("after swap: %s", out)
+	}
+
+	// A bad update is rejected whole
+	err = s.SetPatterns([]policy.CustomPattern{{Name: "ticket", Pattern: `TICKET-\d+`, Label: "ticket"}},
+		map[string]policy.PatternContext{"nonexistent": {}})
+	if err == nil {
+		t.Fatal("SetPatterns accepted a context for an unknown pattern")
+	}
+	out, _ = scrubMsg(t, s, DirServerToHost, payload)
+	if !strings.Contains(out, "TICKET-42") || !strings.Contains(out, "[REDACTED:project]") {
+		t.Errorf("failed update changed the patterns: %s", out)
+	}
+}
+
+func TestScrubber_SetEnabled(t *testing.T) {
+	s := newTestScrubber(false)
+	payload := `{"jsonrpc":"2.0","id":1,"result":{"text":"mail alice@example.com"}}`
+
+	if out, _ := scrubMsg(t, s, DirServerToHost, payload); out != payload {
+		t.Fatalf("disabled scrubber changed the message: %s", out)
+	}
+	s.SetEnabled(true)
+	if out, _ := scrubMsg(t, s, DirServerToHost, payload); strings.Contains(out, "alice@example.com") {
+		t.Errorf("enabled scrubber left the email: %s", out)
+	}
+	s.SetEnabled(false)
+	if out, _ := scrubMsg(t, s, DirServerToHost, payload); out != payload {
+		t.Errorf("scrubber still scrubbing after being disabled: %s", out)
+	}
+}
+
+func TestScrubber_SetPatternsConcurrent(t *testing.T) {
+	s := newTestScrubber(true)
+	sets := [][]policy.CustomPattern{
+		{{Name: "a", Pattern: `AAA\d+`, Label: "a"}},
+		{{Name: "b", Pattern: `BBB\d+`, Label: "b"}},
+	}
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				msg := &InterceptedMessage{Direction: DirServerToHost, RawBytes: []byte(`{"result":{"text":"AAA1 BBB2 a@b.io"}}`)}
+				out, err := s.Intercept(context.Background(), msg)
+				if err != nil || !strings.Contains(string(out), "[REDACTED:email]") {
+					t.Errorf("scrub during swap: %s, %v", out, err)
+					return
+				}
+			}
+		}()
+	}
+	for i := range 200 {
+		if err := s.SetPatterns(sets[i%2], nil); err != nil {
+			t.Fatal(err)
+		}
+		s.RequireContext("ipv4", policy.PatternContext{RequireContext: []string{"ip"}})
+	}
+	close(stop)
+	wg.Wait()
+}
+
+func TestScrubber_Profile(t *testing.T) {
+	s := newTestScrubber(true)
+	s.Profile = NewScrubProfile()
+	scrubMsg(t, s, DirServerToHost, `{"result":{"a":"mail bob@example.com","b":"nothing here"}}`)
+
+	snaps := s.Profile.Snapshot()
+	if len(snaps) != len(defaultPIIPatterns) {
+		t.Fatalf("timed %d patterns, want %d", len(snaps), len(defaultPIIPatterns))
+	}
+	byName := make(map[string]PatternTiming)
+	for i, snap := range snaps {
+		byName[snap.Pattern] = snap
+		if i > 0 && snap.Duration > snaps[i-1].Duration {
+			t.Errorf("snapshot not sorted slowest first: %v", snaps)
+		}
+	}
+	email := byName["email"]
+	if email.Calls != 2 || email.Matches != 1 || email.Bytes != uint64(len("mail bob@example.com")+len("nothing here")) {
+		t.Errorf("email timing = %+v", email)
+	}
+	if ssn := byName["ssn"]; ssn.Calls != 2 || ssn.Matches != 0 {
+		t.Errorf("ssn timing = %+v", ssn)
+	}
+}
+
+// benchPayload is a tools/call result shaped like a file listing with a
+// little PII mixed in.
+func benchPayload() []byte {
+	var sb strings.Builder
+	sb.WriteString(`{"jsonrpc":"2.0","id":1,"result":{"content":[`)
+	for i := range 200 {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		text := "src/module/file.go: func handler(w http.ResponseWriter, r *http.Request) { log.Printf(\"request from 10.0.0.1\") }"
+		if i%20 == 0 {
+			text = "contact alice@example.com or use key sk-abcdefghijklmnopqrstuvwxyz123456 for access"
+		}
+		b, _ := json.Marshal(map[string]string{"type": "text", "text": text})
+		sb.Write(b)
+	}
+	sb.WriteString(`]}}`)
+	return []byte(sb.String())
+}
+
+func BenchmarkScrubber_Intercept(b *testing.B) {
+	payload := benchPayload()
+	for _, bc := range []struct {
+		name    string
+		profile bool
+	}{
+		{"default", false},
+		{"profiled", true},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			s := newTestScrubber(true)
+			if bc.profile {
+				s.Profile = NewScrubProfile()
+			}
+			ctx := context.Background()
+			b.SetBytes(int64(len(payload)))
+			b.ReportAllocs()
+			for b.Loop() {
+				s.Intercept(ctx, &InterceptedMessage{Direction: DirServerToHost, RawBytes: payload})
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package proxy
+
+import (
+	"context"
+	"log/slog"
+)
+
+// SessionLogKey is the log attribute that carries a session ID. Records
+// with it are filtered at that session's level by SessionLevelHandler.
+const SessionLogKey = "session"
+
+// SessionLevelHandler wraps a slog.Handler so that each session can log
+// at its own level. A record belongs to a session when it, or the logger
+// it came from (via With), has a top-level SessionLogKey attribute.
+// Records without one use the base level.
+//
+// The wrapped handler's own level is bypassed, so it sees every record
+// this handler lets through.
+type SessionLevelHandler struct {
+	inner   slog.Handler
+	base    slog.Level
+	levels  map[string]slog.Level
+	min     slog.Level // lowest level any record could be logged at
+	session string     // set by WithAttrs
+	grouped bool       // attributes added after WithGroup aren't top-level
+}
+
+// NewSessionLevelHandler creates a handler that logs at base, except for
+// the sessions in levels. levels must not be modified afterwards.
+func NewSessionLevelHandler(inner slog.Handler, base slog.Level, levels map[string]slog.Level) *SessionLevelHandler {
+	h := &SessionLevelHandler{inner: inner, base: base, levels: levels, min: base}
+	for _, l := range levels {
+		h.min = min(h.min, l)
+	}
+	return h
+}
+
+func (h *SessionLevelHandler) levelFor(session string) slog.Level {
+	if l, ok := h.levels[session]; ok {
+		return l
+	}
+	return h.base
+}
+
+func (h *SessionLevelHandler) Enabled(_ context.Context, level slog.Level) bool {
+	if h.session != "" {
+		return level >= h.levelFor(h.session)
+	}
+	// The session may still come from the record's own attributes
+	return level >= h.min
+}
+
+func (h *SessionLevelHandler) Handle(ctx context.Context, r slog.Record) error {
+	session := h.session
+	if session == "" {
+		r.Attrs(func(a slog.Attr) bool {
+			if a.Key == SessionLogKey {
+				session = a.Value.String()
+				return false
+			}
+			return true
+		})
+	}
+	if r.Level < h.levelFor(session) {
+		return nil
+	}
+	return h.inner.Handle(ctx, r)
+}
+
+func (h *SessionLevelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	c := *h
+	c.inner = h.inner.WithAttrs(attrs)
+	if !h.grouped {
+		for _, a := range attrs {
+			if a.Key == SessionLogKey {
+				c.session = a.Value.String()
+			}
+		}
+	}
+	return &c
+}
+
+func (h *SessionLevelHandler) WithGroup(name string) slog.Handler {
+	c := *h
+	c.inner = h.inner.WithGroup(name)
+	c.grouped = true
+	return &c
+}
@@ -0,0 +1,87 @@
+package proxy
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func newSessionLogger(buf *bytes.Buffer, levels map[string]slog.Level) *slog.Logger {
+	inner := slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelError})
+	return slog.New(NewSessionLevelHandler(inner, slog.LevelInfo, levels))
+}
+
+func TestSessionLevelHandler_ScopedLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := newSessionLogger(&buf, map[string]slog.Level{"flaky": slog.LevelDebug})
+
+	logger.With(SessionLogKey, "flaky").Debug("debug from flaky")
+	logger.With(SessionLogKey, "steady").Debug("debug from steady")
+	logger.Debug("debug without session")
+	logger.With(SessionLogKey, "steady").Info("info from steady")
+
+	out := buf.String()
+	if !strings.Contains(out, "debug from flaky") {
+		t.Errorf("targeted session's debug line missing:\n%s", out)
+	}
+	if strings.Contains(out, "debug from steady") || strings.Contains(out, "debug without session") {
+		t.Errorf("debug leaked from other sessions:\n%s", out)
+	}
+	if !strings.Contains(out, "info from steady") {
+		t.Errorf("base level not applied to other sessions:\n%s", out)
+	}
+}
+
+func TestSessionLevelHandler_RecordAttr(t *testing.T) {
+	var buf bytes.Buffer
+	logger := newSessionLogger(&buf, map[string]slog.Level{"flaky": slog.LevelDebug})
+
+	logger.Debug("call", SessionLogKey, "flaky")
+	logger.Debug("call", SessionLogKey, "steady")
+
+	if n := strings.Count(buf.String(), "msg=call"); n != 1 {
+		t.Fatalf("got %d debug lines, want only the targeted session's:\n%s", n, buf.String())
+	}
+	if !strings.Contains(buf.String(), "session=flaky") {
+		t.Errorf("wrong session logged:\n%s", buf.String())
+	}
+}
+
+func TestSessionLevelHandler_QuieterSession(t *testing.T) {
+	var buf bytes.Buffer
+	logger := newSessionLogger(&buf, map[string]slog.Level{"noisy": slog.LevelError})
+
+	logger.With(SessionLogKey, "noisy").Warn("warn from noisy")
+	logger.With(SessionLogKey, "other").Warn("warn from other")
+
+	out := buf.String()
+	if strings.Contains(out, "warn from noisy") || !strings.Contains(out, "warn from other") {
+		t.Errorf("override didn't raise the level for its session only:\n%s", out)
+	}
+}
+
+func TestSessionLevelHandler_GroupedAttrIgnored(t *testing.T) {
+	var buf bytes.Buffer
+	logger := newSessionLogger(&buf, map[string]slog.Level{"flaky": slog.LevelDebug})
+
+	// A session attribute inside a group isn't the record's session
+	logger.WithGroup("peer").With(SessionLogKey, "flaky").Debug("grouped")
+	if buf.Len() != 0 {
+		t.Errorf("grouped session attribute was honoured:\n%s", buf.String())
+	}
+}
+
+func TestProxy_LoggerScopedToSession(t *testing.T) {
+	var buf bytes.Buffer
+	levels := map[string]slog.Level{"target": slog.LevelDebug}
+	logger := newSessionLogger(&buf, levels)
+
+	NewProxy(Config{SessionID: "target"}, NewInterceptorChain(), logger).logger.Debug("from target")
+	NewProxy(Config{SessionID: "other"}, NewInterceptorChain(), logger).logger.Debug("from other")
+
+	out := buf.String()
+	if !strings.Contains(out, "from target") || strings.Contains(out, "from other") {
+		t.Errorf("proxy logger not scoped to its session:\n%s", out)
+	}
+}
@@ -0,0 +1,55 @@
+package proxy
+
+import "context"
+
+// MetaKeyTokenEstimate holds the approximate token count of a message.
+const MetaKeyTokenEstimate = "token_estimate"
+
+// Tokenizer counts the tokens in a payload. Implementations must be safe
+// for concurrent use.
+type Tokenizer interface {
+	CountTokens(b []byte) int
+}
+
+// defaultBytesPerToken is a rough average for English text and JSON
+// under common BPE vocabularies.
+const defaultBytesPerToken = 4
+
+// ByteHeuristic estimates tokens as len(payload) / BytesPerToken, rounded
+// up. It needs no vocabulary and is good enough for relative comparisons.
+type ByteHeuristic struct {
+	BytesPerToken int // defaults to 4
+}
+
+func (h ByteHeuristic) CountTokens(b []byte) int {
+	per := h.BytesPerToken
+	if per <= 0 {
+		per = defaultBytesPerToken
+	}
+	return (len(b) + per - 1) / per
+}
+
+// TokenEstimateInterceptor annotates each message with an approximate
+// token count for the logging interceptor. It never blocks or modifies
+// messages. Place it after any interceptor that rewrites payloads so the
+// estimate reflects what is actually forwarded.
+type TokenEstimateInterceptor struct {
+	tokenizer Tokenizer
+}
+
+// NewTokenEstimateInterceptor creates an estimator. A nil tokenizer uses
+// ByteHeuristic.
+func NewTokenEstimateInterceptor(t Tokenizer) *TokenEstimateInterceptor {
+	if t == nil {
+		t = ByteHeuristic{}
+	}
+	return &TokenEstimateInterceptor{tokenizer: t}
+}
+
+func (e *TokenEstimateInterceptor) Intercept(_ context.Context, msg *InterceptedMessage) ([]byte, error) {
+	if msg.Metadata == nil {
+		msg.Metadata = make(map[string]any)
+	}
+	msg.Metadata[MetaKeyTokenEstimate] = e.tokenizer.CountTokens(msg.RawBytes)
+	return msg.RawBytes, nil
+}
@@ -0,0 +1,88 @@
+package proxy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/contextgate/contextgate/internal/eventbus"
+)
+
+func TestByteHeuristic(t *testing.T) {
+	tests := []struct {
+		per  int
+		in   string
+		want int
+	}{
+		{0, "", 0},
+		{0, "abc", 1},
+		{0, "abcd", 1},
+		{0, "abcde", 2},
+		{2, "abcde", 3},
+	}
+	for _, tt := range tests {
+		if got := (ByteHeuristic{BytesPerToken: tt.per}).CountTokens([]byte(tt.in)); got != tt.want {
+			t.Errorf("ByteHeuristic{%d}.CountTokens(%q) = %d, want %d", tt.per, tt.in, got, tt.want)
+		}
+	}
+}
+
+// wordTokenizer counts space-separated words, standing in for a real tokenizer.
+type wordTokenizer struct{}
+
+func (wordTokenizer) CountTokens(b []byte) int {
+	n := 0
+	inWord := false
+	for _, c := range b {
+		if c == ' ' {
+			inWord = false
+		} else if !inWord {
+			inWord = true
+			n++
+		}
+	}
+	return n
+}
+
+func TestTokenEstimateInterceptor_Persisted(t *testing.T) {
+	raw := `{"jsonrpc":"2.0","id":1,"result":{"text":"one two three"}}`
+
+	for _, tt := range []struct {
+		name      string
+		tokenizer Tokenizer
+		want      int
+	}{
+		{"default heuristic", nil, (len(raw) + 3) / 4},
+		{"custom tokenizer", wordTokenizer{}, 3},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			st := &mockLogStore{}
+			li := NewLoggingInterceptor(st, eventbus.New(10))
+			chain := NewInterceptorChain(NewTokenEstimateInterceptor(tt.tokenizer), li)
+
+			msg := &InterceptedMessage{
+				Timestamp: time.Now(),
+				Direction: DirServerToHost,
+				RawBytes:  []byte(raw),
+			}
+			msg.Parsed, _ = ParseMessage(msg.RawBytes)
+
+			out, err := chain.Process(context.Background(), msg)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(out) != raw {
+				t.Errorf("forwarded bytes changed: %s", out)
+			}
+			if got := msg.Metadata[MetaKeyTokenEstimate]; got != tt.want {
+				t.Errorf("metadata token estimate = %v, want %d", got, tt.want)
+			}
+			if len(st.entries) != 1 {
+				t.Fatalf("got %d logged entries, want 1", len(st.entries))
+			}
+			if st.entries[0].TokenEstimate != tt.want {
+				t.Errorf("logged token estimate = %d, want %d", st.entries[0].TokenEstimate, tt.want)
+			}
+		})
+	}
+}
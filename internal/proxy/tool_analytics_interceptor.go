@@ -2,8 +2,10 @@ package proxy
 
 import (
 	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"log/slog"
+	"path"
 	"sort"
 	"sync"
 	"time"
@@ -14,21 +16,33 @@ import (
 // MetaKeyToolsPruned is set when tools are pruned from a tools/list response.
 const MetaKeyToolsPruned = "tools_pruned"
 
+// MetaKeyDuplicateTools lists tool names that appear more than once in a
+// tools/list response.
+const MetaKeyDuplicateTools = "duplicate_tools"
+
 // PruneConfig controls tool pruning behavior.
 type PruneConfig struct {
 	UnusedSessions int      // prune tools with 0 calls in last N sessions (0=disabled)
 	KeepTopK       int      // keep only top K most-used tools (0=disabled)
-	AlwaysKeep     []string // tool names that should never be pruned
+	AlwaysKeep     []string // tool names or globs (e.g. "mcp__fs__*") that should never be pruned
 }
 
 func (c PruneConfig) enabled() bool {
 	return c.UnusedSessions > 0 || c.KeepTopK > 0
 }
 
-// pendingRequest tracks a tools/list request waiting for its response.
-type pendingRequest struct {
-	sessionID string
-	timestamp time.Time
+// keeps reports whether a tool matches an AlwaysKeep entry, either by
+// exact name or as a path.Match glob.
+func (c PruneConfig) keeps(name string) bool {
+	for _, pattern := range c.AlwaysKeep {
+		if pattern == name {
+			return true
+		}
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
 }
 
 // ToolAnalyticsInterceptor tracks tool availability and usage,
@@ -38,8 +52,27 @@ type ToolAnalyticsInterceptor struct {
 	logger      *slog.Logger
 	pruneConfig PruneConfig
 
-	mu         sync.Mutex
-	pendingIDs map[string]*pendingRequest
+	// DedupeTools drops every tool after the first of the same name from
+	// forwarded tools/list responses. Duplicates are recorded either way.
+	DedupeTools bool
+
+	// TrackChanges remembers each session's tool definitions and marks
+	// tools that are added or modified after the first complete listing,
+	// for ToolChanged.
+	TrackChanges bool
+
+	pending *callCorrelator[struct{}] // tools/list requests awaiting a response
+
+	mu       sync.Mutex
+	toolSets map[string]*toolSet // by session
+	now      func() time.Time
+}
+
+// toolSet is what a session's server has listed so far.
+type toolSet struct {
+	defs     map[string][32]byte // tool name → hash of its definition, when tracking changes
+	complete bool                // a listing has ended without a nextCursor
+	changed  map[string]bool
 }
 
 // NewToolAnalyticsInterceptor creates a tool analytics interceptor.
@@ -48,7 +81,14 @@ func NewToolAnalyticsInterceptor(s store.Store, logger *slog.Logger, cfg PruneCo
 		store:       s,
 		logger:      logger,
 		pruneConfig: cfg,
-		pendingIDs:  make(map[string]*pendingRequest),
+		pending:     newCallCorrelator[struct{}](),
+		toolSets:    make(map[string]*toolSet),
+		now:         time.Now,
+	}
+	for _, pattern := range cfg.AlwaysKeep {
+		if _, err := path.Match(pattern, ""); err != nil {
+			logger.Warn("invalid always-keep pattern, matching exact name only", "pattern", pattern, "error", err)
+		}
 	}
 	go ta.cleanupLoop()
 	return ta
@@ -62,105 +102,122 @@ func (ta *ToolAnalyticsInterceptor) Intercept(ctx context.Context, msg *Intercep
 	// Track outgoing tools/list requests
 	if msg.Direction == DirHostToServer && msg.Parsed.Method == "tools/list" {
 		if msg.Parsed.ID != nil {
-			idStr := string(msg.Parsed.ID)
-			ta.mu.Lock()
-			ta.pendingIDs[idStr] = &pendingRequest{
-				sessionID: msg.SessionID,
-				timestamp: msg.Timestamp,
-			}
-			ta.mu.Unlock()
+			ta.pending.remember(msg, struct{}{})
 		}
 		return msg.RawBytes, nil
 	}
 
 	// Check if this is a tools/list response
 	if msg.Direction == DirServerToHost && msg.Parsed.Kind() == KindResponse && msg.Parsed.ID != nil {
-		idStr := string(msg.Parsed.ID)
-		ta.mu.Lock()
-		pending, found := ta.pendingIDs[idStr]
-		if found {
-			delete(ta.pendingIDs, idStr)
-		}
-		ta.mu.Unlock()
-
-		if found {
-			return ta.handleToolsListResponse(ctx, msg, pending)
+		if _, found := ta.pending.answer(msg); found {
+			return ta.handleToolsListResponse(ctx, msg)
 		}
 	}
 
 	return msg.RawBytes, nil
 }
 
-// toolsListResult represents the result field of a tools/list response.
-type toolsListResult struct {
-	Tools []json.RawMessage `json:"tools"`
+// listedTool is one entry of a tools/list result, decoded once and shared
+// by duplicate detection, registration and pruning. raw is forwarded as
+// the server sent it.
+type listedTool struct {
+	raw         json.RawMessage
+	name        string
+	description string
+	inputProps  []string // inputSchema property names; nil without a schema
+	ok          bool     // false if the entry isn't a tool object
+}
+
+// toolsList is a decoded tools/list result. fields holds every member of
+// the result so a rebuilt response keeps the ones it doesn't touch, such
+// as nextCursor.
+type toolsList struct {
+	fields map[string]json.RawMessage
+	tools  []listedTool
 }
 
-// toolNameOnly extracts just the name from a raw tool JSON object.
-type toolNameOnly struct {
-	Name string `json:"name"`
+func parseToolsList(result json.RawMessage) (*toolsList, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(result, &fields); err != nil {
+		return nil, err
+	}
+	var raws []json.RawMessage
+	if t, ok := fields["tools"]; ok {
+		if err := json.Unmarshal(t, &raws); err != nil {
+			return nil, err
+		}
+	}
+	list := &toolsList{fields: fields, tools: make([]listedTool, len(raws))}
+	for i, raw := range raws {
+		var t struct {
+			Name        string `json:"name"`
+			Description string `json:"description"`
+			InputSchema *struct {
+				Properties map[string]json.RawMessage `json:"properties"`
+			} `json:"inputSchema"`
+		}
+		err := json.Unmarshal(raw, &t)
+		list.tools[i] = listedTool{raw: raw, name: t.Name, description: t.Description, ok: err == nil}
+		if t.InputSchema != nil {
+			props := make([]string, 0, len(t.InputSchema.Properties))
+			for name := range t.InputSchema.Properties {
+				props = append(props, name)
+			}
+			sort.Strings(props)
+			list.tools[i].inputProps = props
+		}
+	}
+	return list, nil
 }
 
 func (ta *ToolAnalyticsInterceptor) handleToolsListResponse(
 	ctx context.Context,
 	msg *InterceptedMessage,
-	pending *pendingRequest,
 ) ([]byte, error) {
 	if msg.Parsed.Result == nil {
 		return msg.RawBytes, nil
 	}
 
-	// Parse the result to extract tools
-	var result toolsListResult
-	if err := json.Unmarshal(msg.Parsed.Result, &result); err != nil {
+	list, err := parseToolsList(msg.Parsed.Result)
+	if err != nil {
 		ta.logger.Debug("failed to parse tools/list result", "error", err)
 		return msg.RawBytes, nil
 	}
 
-	// Extract tool names and descriptions for registration
-	var records []store.ToolRecord
-	for _, toolRaw := range result.Tools {
-		var t struct {
-			Name        string `json:"name"`
-			Description string `json:"description"`
-		}
-		if err := json.Unmarshal(toolRaw, &t); err != nil {
-			continue
-		}
-		records = append(records, store.ToolRecord{
-			SessionID:   pending.sessionID,
-			ToolName:    t.Name,
-			Description: t.Description,
-		})
+	// Flag tool names listed more than once; only the first of each is
+	// registered, and forwarded too when deduplicating
+	unique, dupes := ta.checkDuplicates(ctx, msg, msg.SessionID, list.tools)
+	later := ta.listed(msg.SessionID, list)
+	ta.registerTools(ctx, msg.SessionID, unique, later)
+	ta.trackChanges(msg.SessionID, unique, later)
+	tools := list.tools
+	deduped := dupes && ta.DedupeTools
+	if deduped {
+		tools = unique
 	}
-
-	ta.logger.Info("tools/list response",
-		"session", pending.sessionID,
-		"tool_count", len(records),
-	)
-
-	if len(records) > 0 {
-		if err := ta.store.RegisterTools(ctx, pending.sessionID, records); err != nil {
-			ta.logger.Error("failed to register tools", "error", err)
+	unpruned := func() ([]byte, error) {
+		if deduped {
+			return rebuildToolsList(msg, list, tools)
 		}
+		return msg.RawBytes, nil
 	}
 
 	// If pruning is not configured, pass through unchanged
 	if !ta.pruneConfig.enabled() {
-		return msg.RawBytes, nil
+		return unpruned()
 	}
 
 	// Get historical usage counts for pruning decisions
 	usageCounts, err := ta.store.GetToolUsageCounts(ctx, ta.pruneConfig.UnusedSessions)
 	if err != nil {
 		ta.logger.Error("failed to get usage counts for pruning", "error", err)
-		return msg.RawBytes, nil
+		return unpruned()
 	}
 
 	// Determine which tools to keep
-	kept, pruned := ta.applyPruning(result.Tools, usageCounts)
+	kept, pruned := ta.applyPruning(tools, usageCounts)
 	if len(pruned) == 0 {
-		return msg.RawBytes, nil
+		return unpruned()
 	}
 
 	if msg.Metadata == nil {
@@ -173,37 +230,215 @@ func (ta *ToolAnalyticsInterceptor) handleToolsListResponse(
 		"pruned", len(pruned),
 	)
 
-	return ta.rebuildResponse(msg, kept)
+	return rebuildToolsList(msg, list, kept)
 }
 
-func (ta *ToolAnalyticsInterceptor) applyPruning(
-	tools []json.RawMessage,
-	usageCounts map[string]int,
-) (kept, pruned []json.RawMessage) {
-	alwaysKeep := make(map[string]bool)
-	for _, name := range ta.pruneConfig.AlwaysKeep {
-		alwaysKeep[name] = true
+// RegisterToolsList records the tools in a tools/list result that was
+// fetched outside the chain, such as by Proxy's tools preload. Nothing is
+// pruned since the result is never forwarded.
+func (ta *ToolAnalyticsInterceptor) RegisterToolsList(ctx context.Context, sessionID string, raw json.RawMessage) {
+	list, err := parseToolsList(raw)
+	if err != nil {
+		ta.logger.Debug("failed to parse tools/list result", "error", err)
+		return
 	}
+	unique, _ := ta.checkDuplicates(ctx, nil, sessionID, list.tools)
+	later := ta.listed(sessionID, list)
+	ta.registerTools(ctx, sessionID, unique, later)
+	ta.trackChanges(sessionID, unique, later)
+}
 
-	// Parse tool names
+// listed notes a tools/list result for the session and reports whether
+// it came after the session's first complete listing, which ends with
+// the first page without a nextCursor.
+func (ta *ToolAnalyticsInterceptor) listed(sessionID string, list *toolsList) bool {
+	ta.mu.Lock()
+	defer ta.mu.Unlock()
+	set, ok := ta.toolSets[sessionID]
+	if !ok {
+		set = &toolSet{defs: make(map[string][32]byte), changed: make(map[string]bool)}
+		ta.toolSets[sessionID] = set
+	}
+	later := set.complete
+	if cursor, ok := list.fields["nextCursor"]; !ok || string(cursor) == "null" {
+		set.complete = true
+	}
+	return later
+}
+
+// trackChanges compares listed tools with the definitions the session has
+// seen and, in a listing after the first complete one, marks new or
+// modified ones. Pages of the first listing only build up the baseline.
+func (ta *ToolAnalyticsInterceptor) trackChanges(sessionID string, tools []listedTool, later bool) {
+	if !ta.TrackChanges {
+		return
+	}
+
+	ta.mu.Lock()
+	defer ta.mu.Unlock()
+	set := ta.toolSets[sessionID]
+
+	var changed []string
+	for _, t := range tools {
+		if !t.ok || t.name == "" {
+			continue
+		}
+		sum := definitionHash(t.raw)
+		prev, seen := set.defs[t.name]
+		set.defs[t.name] = sum
+		if later && (!seen || prev != sum) {
+			set.changed[t.name] = true
+			changed = append(changed, t.name)
+		}
+	}
+
+	if len(changed) > 0 {
+		ta.logger.Warn("server changed its tools mid-session",
+			"session", sessionID,
+			"tools", changed,
+		)
+	}
+}
+
+// definitionHash hashes a tool definition independently of its key order
+// and whitespace.
+func definitionHash(raw json.RawMessage) [32]byte {
+	var v any
+	if err := json.Unmarshal(raw, &v); err == nil {
+		if canonical, err := json.Marshal(v); err == nil {
+			raw = canonical
+		}
+	}
+	return sha256.Sum256(raw)
+}
+
+// ToolChanged reports whether the session's server added or modified the
+// tool after first listing its tools, and the change hasn't been
+// acknowledged since.
+func (ta *ToolAnalyticsInterceptor) ToolChanged(sessionID, tool string) bool {
+	ta.mu.Lock()
+	defer ta.mu.Unlock()
+	set, ok := ta.toolSets[sessionID]
+	return ok && set.changed[tool]
+}
+
+// AcknowledgeTool clears the tool's change mark, once a call to it has
+// been approved.
+func (ta *ToolAnalyticsInterceptor) AcknowledgeTool(sessionID, tool string) {
+	ta.mu.Lock()
+	defer ta.mu.Unlock()
+	if set, ok := ta.toolSets[sessionID]; ok {
+		delete(set.changed, tool)
+	}
+}
+
+// checkDuplicates finds tool names listed more than once, records them
+// and annotates msg (if any). It returns the tools with only the first of
+// each name, and whether there were duplicates.
+func (ta *ToolAnalyticsInterceptor) checkDuplicates(
+	ctx context.Context,
+	msg *InterceptedMessage,
+	sessionID string,
+	tools []listedTool,
+) ([]listedTool, bool) {
+	unique := make([]listedTool, 0, len(tools))
+	copies := make(map[string]int)
+	for _, t := range tools {
+		if !t.ok || t.name == "" {
+			unique = append(unique, t)
+			continue
+		}
+		copies[t.name]++
+		if copies[t.name] == 1 {
+			unique = append(unique, t)
+		}
+	}
+
+	var conflicts []store.ToolConflict
+	var names []string
+	for name, n := range copies {
+		if n > 1 {
+			conflicts = append(conflicts, store.ToolConflict{SessionID: sessionID, ToolName: name, Copies: n})
+			names = append(names, name)
+		}
+	}
+	if len(conflicts) == 0 {
+		return tools, false
+	}
+	sort.Strings(names)
+
+	ta.logger.Warn("tools/list has duplicate tool names",
+		"session", sessionID,
+		"tools", names,
+		"deduplicated", ta.DedupeTools && msg != nil,
+	)
+	if err := ta.store.RecordToolConflicts(ctx, sessionID, conflicts); err != nil {
+		ta.logger.Error("failed to record tool conflicts", "error", err)
+	}
+	if msg != nil {
+		if msg.Metadata == nil {
+			msg.Metadata = make(map[string]any)
+		}
+		msg.Metadata[MetaKeyDuplicateTools] = names
+		msg.Metadata[MetaKeyAudit] = true
+	}
+	return unique, true
+}
+
+// registerTools stores tool names and descriptions in the session's
+// registry. later marks tools first listed after the session's first
+// complete listing as added mid-session.
+func (ta *ToolAnalyticsInterceptor) registerTools(ctx context.Context, sessionID string, tools []listedTool, later bool) {
+	var records []store.ToolRecord
+	for _, t := range tools {
+		if !t.ok {
+			continue
+		}
+		records = append(records, store.ToolRecord{
+			SessionID:       sessionID,
+			ToolName:        t.name,
+			Description:     t.description,
+			InputProperties: t.inputProps,
+			MidSession:      later,
+		})
+	}
+
+	ta.logger.Info("tools/list response",
+		"session", sessionID,
+		"tool_count", len(records),
+	)
+
+	if len(records) > 0 {
+		if err := ta.store.RegisterTools(ctx, sessionID, records); err != nil {
+			ta.logger.Error("failed to register tools", "error", err)
+		}
+	}
+}
+
+func (ta *ToolAnalyticsInterceptor) applyPruning(
+	tools []listedTool,
+	usageCounts map[string]int,
+) (kept, pruned []listedTool) {
 	type toolWithUsage struct {
-		raw   json.RawMessage
-		name  string
+		listedTool
 		count int
 	}
 	var toolInfos []toolWithUsage
-	for _, raw := range tools {
-		var t toolNameOnly
-		if err := json.Unmarshal(raw, &t); err != nil {
+	for _, t := range tools {
+		if !t.ok {
 			// Can't parse — keep it
-			kept = append(kept, raw)
+			kept = append(kept, t)
 			continue
 		}
-		toolInfos = append(toolInfos, toolWithUsage{
-			raw:   raw,
-			name:  t.Name,
-			count: usageCounts[t.Name],
-		})
+		toolInfos = append(toolInfos, toolWithUsage{listedTool: t, count: usageCounts[t.name]})
+	}
+
+	// Resolve always-keep names and globs against the listed tools
+	alwaysKeep := make(map[string]bool)
+	for _, ti := range toolInfos {
+		if ta.pruneConfig.keeps(ti.name) {
+			alwaysKeep[ti.name] = true
+		}
 	}
 
 	keepSet := make(map[string]bool)
@@ -256,32 +491,33 @@ func (ta *ToolAnalyticsInterceptor) applyPruning(
 
 	for _, ti := range toolInfos {
 		if keepSet[ti.name] {
-			kept = append(kept, ti.raw)
+			kept = append(kept, ti.listedTool)
 		} else {
-			pruned = append(pruned, ti.raw)
+			pruned = append(pruned, ti.listedTool)
 		}
 	}
 
 	return kept, pruned
 }
 
-func (ta *ToolAnalyticsInterceptor) rebuildResponse(
+// rebuildToolsList re-encodes msg with its tools replaced by keptTools,
+// reusing the result fields decoded by parseToolsList.
+func rebuildToolsList(
 	msg *InterceptedMessage,
-	keptTools []json.RawMessage,
+	list *toolsList,
+	keptTools []listedTool,
 ) ([]byte, error) {
-	// Parse the full result as a generic map to preserve extra fields (cursor, etc.)
-	var fullResult map[string]json.RawMessage
-	if err := json.Unmarshal(msg.Parsed.Result, &fullResult); err != nil {
-		return msg.RawBytes, nil
+	raws := make([]json.RawMessage, len(keptTools))
+	for i, t := range keptTools {
+		raws[i] = t.raw
 	}
-
-	toolsJSON, err := json.Marshal(keptTools)
+	toolsJSON, err := json.Marshal(raws)
 	if err != nil {
 		return msg.RawBytes, nil
 	}
-	fullResult["tools"] = toolsJSON
+	list.fields["tools"] = toolsJSON
 
-	newResult, err := json.Marshal(fullResult)
+	newResult, err := json.Marshal(list.fields)
 	if err != nil {
 		return msg.RawBytes, nil
 	}
@@ -303,13 +539,11 @@ func (ta *ToolAnalyticsInterceptor) cleanupLoop() {
 	ticker := time.NewTicker(60 * time.Second)
 	defer ticker.Stop()
 	for range ticker.C {
-		ta.mu.Lock()
-		cutoff := time.Now().Add(-5 * time.Minute)
-		for id, p := range ta.pendingIDs {
-			if p.timestamp.Before(cutoff) {
-				delete(ta.pendingIDs, id)
-			}
-		}
-		ta.mu.Unlock()
+		ta.expirePending()
 	}
 }
+
+// expirePending drops pending requests older than pendingTTL.
+func (ta *ToolAnalyticsInterceptor) expirePending() {
+	ta.pending.expire(ta.now())
+}
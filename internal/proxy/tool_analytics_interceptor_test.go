@@ -3,8 +3,10 @@ package proxy
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"log/slog"
 	"os"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -16,6 +18,7 @@ import (
 type mockToolStore struct {
 	store.Store // embed to satisfy interface (panics on unimplemented)
 	registered  []store.ToolRecord
+	conflicts   []store.ToolConflict
 	usageCounts map[string]int
 }
 
@@ -31,6 +34,11 @@ func (m *mockToolStore) RegisterTools(_ context.Context, sessionID string, tools
 	return nil
 }
 
+func (m *mockToolStore) RecordToolConflicts(_ context.Context, _ string, conflicts []store.ToolConflict) error {
+	m.conflicts = append(m.conflicts, conflicts...)
+	return nil
+}
+
 func (m *mockToolStore) GetToolAnalytics(_ context.Context, _ string) (*store.ToolAnalyticsSummary, error) {
 	return &store.ToolAnalyticsSummary{}, nil
 }
@@ -80,14 +88,39 @@ func TestToolAnalytics_TracksRequest(t *testing.T) {
 		t.Fatal("expected pass through")
 	}
 
-	ta.mu.Lock()
-	_, exists := ta.pendingIDs["1"]
-	ta.mu.Unlock()
+	exists := ta.pending.has(corrKey{DirHostToServer, "1"})
 	if !exists {
 		t.Fatal("expected pending ID to be tracked")
 	}
 }
 
+func TestToolAnalytics_RegistersInputProperties(t *testing.T) {
+	ms := newMockToolStore()
+	ta := NewToolAnalyticsInterceptor(ms, testLogger(), PruneConfig{})
+	ctx := context.Background()
+
+	ta.Intercept(ctx, makeToolsListRequest("1"))
+	tools := `[
+		{"name":"read_file","inputSchema":{"type":"object","properties":{"path":{"type":"string"},"encoding":{"type":"string"}}}},
+		{"name":"ping","inputSchema":{"type":"object"}},
+		{"name":"legacy"}
+	]`
+	ta.Intercept(ctx, makeToolsListResponse("1", tools))
+
+	if len(ms.registered) != 3 {
+		t.Fatalf("registered %d tools, want 3", len(ms.registered))
+	}
+	if got := ms.registered[0].InputProperties; !slices.Equal(got, []string{"encoding", "path"}) {
+		t.Errorf("read_file properties = %v, want [encoding path]", got)
+	}
+	if got := ms.registered[1].InputProperties; got == nil || len(got) != 0 {
+		t.Errorf("ping properties = %#v, want empty but declared", got)
+	}
+	if got := ms.registered[2].InputProperties; got != nil {
+		t.Errorf("legacy properties = %v, want nil without a schema", got)
+	}
+}
+
 func TestToolAnalytics_CorrelatesResponse(t *testing.T) {
 	ms := newMockToolStore()
 	ta := NewToolAnalyticsInterceptor(ms, testLogger(), PruneConfig{})
@@ -119,9 +152,7 @@ func TestToolAnalytics_CorrelatesResponse(t *testing.T) {
 	}
 
 	// Pending ID should be cleaned up
-	ta.mu.Lock()
-	_, exists := ta.pendingIDs["1"]
-	ta.mu.Unlock()
+	exists := ta.pending.has(corrKey{DirHostToServer, "1"})
 	if exists {
 		t.Fatal("expected pending ID to be removed after correlation")
 	}
@@ -219,6 +250,37 @@ func TestToolAnalytics_AlwaysKeep(t *testing.T) {
 	}
 }
 
+func TestToolAnalytics_AlwaysKeepGlob(t *testing.T) {
+	ms := newMockToolStore()
+	ms.usageCounts = map[string]int{"mcp__git__log": 4, "mcp__web__fetch": 2}
+
+	ta := NewToolAnalyticsInterceptor(ms, testLogger(), PruneConfig{
+		KeepTopK:   1,
+		AlwaysKeep: []string{"mcp__fs__*"},
+	})
+	ctx := context.Background()
+
+	ta.Intercept(ctx, makeToolsListRequest("1"))
+
+	tools := `[{"name":"mcp__fs__read"},{"name":"mcp__fs__write"},{"name":"mcp__git__log"},{"name":"mcp__web__fetch"},{"name":"mcp__fsx__other"}]`
+	result, err := ta.Intercept(ctx, makeToolsListResponse("1", tools))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	resultStr := string(result)
+	for _, name := range []string{"mcp__fs__read", "mcp__fs__write", "mcp__git__log"} {
+		if !strings.Contains(resultStr, `"`+name+`"`) {
+			t.Errorf("expected %s to be kept", name)
+		}
+	}
+	for _, name := range []string{"mcp__web__fetch", "mcp__fsx__other"} {
+		if strings.Contains(resultStr, `"`+name+`"`) {
+			t.Errorf("expected %s to be pruned", name)
+		}
+	}
+}
+
 func TestToolAnalytics_KeepTopK(t *testing.T) {
 	ms := newMockToolStore()
 	ms.usageCounts = map[string]int{"a": 10, "b": 5, "c": 3, "d": 1}
@@ -241,7 +303,9 @@ func TestToolAnalytics_KeepTopK(t *testing.T) {
 	// Parse the result to check which tools remain
 	var parsed JSONRPCMessage
 	json.Unmarshal(result, &parsed)
-	var res toolsListResult
+	var res struct {
+		Tools []json.RawMessage `json:"tools"`
+	}
 	json.Unmarshal(parsed.Result, &res)
 
 	if len(res.Tools) != 2 {
@@ -340,3 +404,242 @@ func TestToolAnalytics_PreservesInputSchema(t *testing.T) {
 		t.Fatal("expected inputSchema properties to be preserved")
 	}
 }
+
+func TestToolAnalytics_IgnoresHostResponseWithSameID(t *testing.T) {
+	ms := newMockToolStore()
+	ta := NewToolAnalyticsInterceptor(ms, testLogger(), PruneConfig{})
+	ctx := context.Background()
+
+	ta.Intercept(ctx, makeToolsListRequest("1"))
+
+	// The host answering a server request with ID 1 must not be taken as
+	// the tools/list response
+	hostResp := &InterceptedMessage{
+		Direction: DirHostToServer,
+		RawBytes:  []byte(`{"jsonrpc":"2.0","id":1,"result":{"roots":[]}}`),
+	}
+	hostResp.Parsed, _ = ParseMessage(hostResp.RawBytes)
+	ta.Intercept(ctx, hostResp)
+
+	exists := ta.pending.has(corrKey{DirHostToServer, "1"})
+	if !exists {
+		t.Fatal("tools/list request correlated with a host response")
+	}
+}
+
+const duplicateTools = `[{"name":"search","description":"first"},{"name":"read"},{"name":"search","description":"second"},{"name":"search","description":"third"}]`
+
+func TestToolAnalytics_FlagsDuplicateNames(t *testing.T) {
+	ms := newMockToolStore()
+	ta := NewToolAnalyticsInterceptor(ms, testLogger(), PruneConfig{})
+	ctx := context.Background()
+
+	ta.Intercept(ctx, makeToolsListRequest("1"))
+	resp := makeToolsListResponse("1", duplicateTools)
+	out, err := ta.Intercept(ctx, resp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Without -dedupe-tools the response is forwarded as is
+	if string(out) != string(resp.RawBytes) {
+		t.Errorf("response changed: %s", out)
+	}
+	if names, _ := resp.Metadata[MetaKeyDuplicateTools].([]string); len(names) != 1 || names[0] != "search" {
+		t.Errorf("metadata = %v, want search flagged", resp.Metadata)
+	}
+	if resp.Metadata[MetaKeyAudit] != true {
+		t.Error("duplicate response not marked for audit")
+	}
+	if len(ms.conflicts) != 1 || ms.conflicts[0].ToolName != "search" || ms.conflicts[0].Copies != 3 {
+		t.Errorf("recorded conflicts = %+v, want search x3", ms.conflicts)
+	}
+	if len(ms.registered) != 2 {
+		t.Errorf("registered %d tools, want each name once", len(ms.registered))
+	}
+}
+
+func TestToolAnalytics_DedupeKeepsFirst(t *testing.T) {
+	ms := newMockToolStore()
+	ta := NewToolAnalyticsInterceptor(ms, testLogger(), PruneConfig{})
+	ta.DedupeTools = true
+	ctx := context.Background()
+
+	ta.Intercept(ctx, makeToolsListRequest("1"))
+	out, err := ta.Intercept(ctx, makeToolsListResponse("1", duplicateTools))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var resp struct {
+		ID     int `json:"id"`
+		Result struct {
+			Tools []struct {
+				Name        string `json:"name"`
+				Description string `json:"description"`
+			} `json:"tools"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(out, &resp); err != nil {
+		t.Fatal(err)
+	}
+	tools := resp.Result.Tools
+	if resp.ID != 1 || len(tools) != 2 || tools[0].Name != "search" || tools[0].Description != "first" || tools[1].Name != "read" {
+		t.Errorf("got %s, want the first search and read", out)
+	}
+}
+
+func TestToolAnalytics_NoDuplicatesNoConflicts(t *testing.T) {
+	ms := newMockToolStore()
+	ta := NewToolAnalyticsInterceptor(ms, testLogger(), PruneConfig{})
+	ta.DedupeTools = true
+	ctx := context.Background()
+
+	ta.Intercept(ctx, makeToolsListRequest("1"))
+	resp := makeToolsListResponse("1", `[{"name":"a"},{"name":"b"}]`)
+	out, _ := ta.Intercept(ctx, resp)
+	if string(out) != string(resp.RawBytes) || resp.Metadata != nil || ms.conflicts != nil {
+		t.Errorf("clean list was touched: %s %v %v", out, resp.Metadata, ms.conflicts)
+	}
+}
+
+// TestToolAnalytics_RebuiltResponse pins the exact bytes forwarded when a
+// tools/list response is rewritten: tool order, untouched tool JSON, extra
+// result fields, and entries that aren't tool objects.
+func TestToolAnalytics_RebuiltResponse(t *testing.T) {
+	ms := newMockToolStore()
+	ms.usageCounts = map[string]int{"a": 4, "c": 9}
+	ta := NewToolAnalyticsInterceptor(ms, testLogger(), PruneConfig{UnusedSessions: 5, AlwaysKeep: []string{"keep_*"}})
+	ta.DedupeTools = true
+	ctx := context.Background()
+
+	ta.Intercept(ctx, makeToolsListRequest("1"))
+	raw := []byte(`{"jsonrpc":"2.0","id":1,"result":{"nextCursor":"p2","tools":[` +
+		`{"name":"a","description":"first a","inputSchema":{"type":"object"}},` +
+		`{"name":"b"},` +
+		`"not-a-tool",` +
+		`{"name":"keep_me"},` +
+		`{"name":"a","description":"second a"},` +
+		`{"description":"nameless"},` +
+		`{"name":"c"}]}}`)
+	parsed, _ := ParseMessage(raw)
+	msg := &InterceptedMessage{Timestamp: time.Now(), SessionID: "test-session", Direction: DirServerToHost, RawBytes: raw, Parsed: parsed}
+
+	out, err := ta.Intercept(ctx, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"jsonrpc":"2.0","id":1,"result":{"nextCursor":"p2","tools":[` +
+		`"not-a-tool",` +
+		`{"name":"a","description":"first a","inputSchema":{"type":"object"}},` +
+		`{"name":"keep_me"},` +
+		`{"name":"c"}]}}`
+	if string(out) != want {
+		t.Errorf("forwarded\n%s\nwant\n%s", out, want)
+	}
+	if msg.Metadata[MetaKeyToolsPruned] != 2 {
+		t.Errorf("pruned = %v, want 2 (b and the nameless tool)", msg.Metadata[MetaKeyToolsPruned])
+	}
+
+	var names []string
+	for _, r := range ms.registered {
+		names = append(names, r.ToolName+"="+r.Description)
+	}
+	if got := strings.Join(names, ","); got != "a=first a,b=,keep_me=,=nameless,c=" {
+		t.Errorf("registered %s", got)
+	}
+}
+
+func BenchmarkToolAnalytics_ToolsList(b *testing.B) {
+	var sb strings.Builder
+	sb.WriteString("[")
+	for i := range 500 {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		fmt.Fprintf(&sb, `{"name":"tool_%d","description":"Does thing %d with care","inputSchema":{"type":"object","properties":{"path":{"type":"string"},"limit":{"type":"integer"}}}}`, i, i)
+	}
+	sb.WriteString("]")
+	tools := sb.String()
+
+	for _, bc := range []struct {
+		name string
+		cfg  PruneConfig
+	}{
+		{"no-pruning", PruneConfig{}},
+		{"keep-top-50", PruneConfig{KeepTopK: 50}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			ms := newMockToolStore()
+			for i := range 100 {
+				ms.usageCounts[fmt.Sprintf("tool_%d", i)] = i
+			}
+			ta := NewToolAnalyticsInterceptor(ms, testLogger(), bc.cfg)
+			ctx := context.Background()
+			b.ReportAllocs()
+			for b.Loop() {
+				ms.registered = ms.registered[:0]
+				ta.Intercept(ctx, makeToolsListRequest("1"))
+				if _, err := ta.Intercept(ctx, makeToolsListResponse("1", tools)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestToolAnalytics_TrackChangesAfterFullListing(t *testing.T) {
+	ta := NewToolAnalyticsInterceptor(newMockToolStore(), testLogger(), PruneConfig{})
+	ta.TrackChanges = true
+	respond := func(id, result string) {
+		ta.Intercept(context.Background(), makeToolsListRequest(id))
+		raw := []byte(`{"jsonrpc":"2.0","id":` + id + `,"result":` + result + `}`)
+		msg := &InterceptedMessage{SessionID: "test-session", Direction: DirServerToHost, RawBytes: raw}
+		msg.Parsed, _ = ParseMessage(raw)
+		ta.Intercept(context.Background(), msg)
+	}
+
+	// The second page of the first listing is part of the baseline
+	respond("1", `{"tools":[{"name":"a"}],"nextCursor":"p2"}`)
+	respond("2", `{"tools":[{"name":"b"}]}`)
+	if ta.ToolChanged("test-session", "b") {
+		t.Fatal("tool on a later page of the first listing marked as changed")
+	}
+
+	respond("3", `{"tools":[{"name":"a","description":"new"},{"name":"b"}]}`)
+	if !ta.ToolChanged("test-session", "a") || ta.ToolChanged("test-session", "b") {
+		t.Error("want only the modified tool a marked")
+	}
+	if ta.ToolChanged("other-session", "a") {
+		t.Error("change leaked into another session")
+	}
+	ta.AcknowledgeTool("test-session", "a")
+	if ta.ToolChanged("test-session", "a") {
+		t.Error("acknowledged tool still marked")
+	}
+}
+
+func TestToolAnalytics_MarksToolsAddedMidSession(t *testing.T) {
+	ms := newMockToolStore()
+	ta := NewToolAnalyticsInterceptor(ms, testLogger(), PruneConfig{})
+	respond := func(id, result string) {
+		ta.Intercept(context.Background(), makeToolsListRequest(id))
+		raw := []byte(`{"jsonrpc":"2.0","id":` + id + `,"result":` + result + `}`)
+		msg := &InterceptedMessage{SessionID: "test-session", Direction: DirServerToHost, RawBytes: raw}
+		msg.Parsed, _ = ParseMessage(raw)
+		ta.Intercept(context.Background(), msg)
+	}
+
+	// Both pages of the first listing are initial, without TrackChanges too
+	respond("1", `{"tools":[{"name":"a"}],"nextCursor":"p2"}`)
+	respond("2", `{"tools":[{"name":"b"}]}`)
+	respond("3", `{"tools":[{"name":"a"},{"name":"c"}]}`)
+
+	var got []string
+	for _, r := range ms.registered {
+		got = append(got, fmt.Sprintf("%s:%v", r.ToolName, r.MidSession))
+	}
+	if want := []string{"a:false", "b:false", "a:true", "c:true"}; !slices.Equal(got, want) {
+		t.Errorf("registered %v, want %v", got, want)
+	}
+}
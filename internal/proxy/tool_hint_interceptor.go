@@ -0,0 +1,202 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/contextgate/contextgate/internal/policy"
+	"github.com/contextgate/contextgate/internal/store"
+)
+
+// DefaultToolHint is the hint template used when none is configured.
+const DefaultToolHint = `{{if .Calls}}Used {{.Calls}}× across {{.Sessions}} session(s).{{end}}` +
+	`{{if eq .Policy "require_approval"}} Calls require human approval.{{end}}` +
+	`{{if eq .Policy "deny"}} Calls are blocked by policy.{{end}}`
+
+// DefaultToolHintTTL is how long usage stats are cached between lookups.
+const DefaultToolHintTTL = time.Minute
+
+// ToolHint is the data a hint template is executed with.
+type ToolHint struct {
+	Name         string
+	Calls        int     // calls recorded across all sessions
+	Sessions     int     // sessions the tool was listed in
+	LastUsed     string  // timestamp of the latest call, if any
+	AvgLatencyMs float64 // zero when no latency was recorded
+	// Policy is the action the policy takes on a call to the tool with no
+	// particular arguments: "deny", "require_approval", "audit" or "".
+	Policy string
+}
+
+// ToolStats supplies the usage stats for hints. It is implemented by
+// store.Store.
+type ToolStats interface {
+	GetToolAnalytics(ctx context.Context, sessionID string) (*store.ToolAnalyticsSummary, error)
+}
+
+// ToolHintInterceptor appends a hint to each tool description in
+// tools/list responses, rendered from a template with the tool's usage
+// stats and policy, so the agent can favour tools that work and knows
+// which calls will wait for a reviewer. Nothing else in a tool definition
+// is changed, and tools whose hint renders empty are left alone.
+//
+// It must run after tool-analytics so the registry records the
+// descriptions the server sent.
+type ToolHintInterceptor struct {
+	stats  ToolStats
+	tmpl   *template.Template
+	logger *slog.Logger
+
+	// Engine, if set, supplies ToolHint.Policy.
+	Engine *policy.Engine
+
+	// TTL is how long usage stats are reused before they are looked up
+	// again. Zero means DefaultToolHintTTL.
+	TTL time.Duration
+
+	pending *callCorrelator[struct{}] // tools/list requests awaiting a response
+
+	mu       sync.Mutex
+	usage    map[string]store.ToolAnalytics
+	loadedAt time.Time
+	now      func() time.Time
+}
+
+// NewToolHintInterceptor creates an interceptor rendering hints with tmpl,
+// a text/template over ToolHint. An empty tmpl uses DefaultToolHint.
+func NewToolHintInterceptor(stats ToolStats, tmpl string, logger *slog.Logger) (*ToolHintInterceptor, error) {
+	if tmpl == "" {
+		tmpl = DefaultToolHint
+	}
+	t, err := template.New("tool-hint").Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return nil, err
+	}
+	return &ToolHintInterceptor{
+		stats:   stats,
+		tmpl:    t,
+		logger:  logger,
+		pending: newCallCorrelator[struct{}](),
+		now:     time.Now,
+	}, nil
+}
+
+func (h *ToolHintInterceptor) Intercept(ctx context.Context, msg *InterceptedMessage) ([]byte, error) {
+	if msg.ParseErr != nil || msg.Parsed.ID == nil {
+		return msg.RawBytes, nil
+	}
+
+	if msg.Direction == DirHostToServer && msg.Parsed.Method == "tools/list" {
+		h.pending.remember(msg, struct{}{})
+		return msg.RawBytes, nil
+	}
+
+	if msg.Direction != DirServerToHost || msg.Parsed.Kind() != KindResponse {
+		return msg.RawBytes, nil
+	}
+	if _, found := h.pending.answer(msg); !found || msg.Parsed.Result == nil {
+		return msg.RawBytes, nil
+	}
+
+	list, err := parseToolsList(msg.Parsed.Result)
+	if err != nil {
+		return msg.RawBytes, nil
+	}
+	usage := h.lookupUsage(ctx)
+	changed := false
+	for i, t := range list.tools {
+		if !t.ok || t.name == "" {
+			continue
+		}
+		hint := h.render(t.name, usage[t.name])
+		if hint == "" {
+			continue
+		}
+		if raw, ok := setDescription(t.raw, appendHint(t.description, hint)); ok {
+			list.tools[i].raw = raw
+			changed = true
+		}
+	}
+	if !changed {
+		return msg.RawBytes, nil
+	}
+	return rebuildToolsList(msg, list, list.tools)
+}
+
+// lookupUsage returns usage stats by tool name, from the cache when it is
+// fresh. On a failed lookup the previous stats are kept.
+func (h *ToolHintInterceptor) lookupUsage(ctx context.Context) map[string]store.ToolAnalytics {
+	ttl := h.TTL
+	if ttl <= 0 {
+		ttl = DefaultToolHintTTL
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.usage != nil && h.now().Sub(h.loadedAt) < ttl {
+		return h.usage
+	}
+
+	summary, err := h.stats.GetToolAnalytics(ctx, "")
+	if err != nil {
+		h.logger.Warn("failed to load tool usage for hints", "error", err)
+		return h.usage
+	}
+	h.usage = make(map[string]store.ToolAnalytics, len(summary.Tools))
+	for _, t := range summary.Tools {
+		h.usage[t.ToolName] = t
+	}
+	h.loadedAt = h.now()
+	return h.usage
+}
+
+func (h *ToolHintInterceptor) render(name string, usage store.ToolAnalytics) string {
+	data := ToolHint{
+		Name:         name,
+		Calls:        usage.CallCount,
+		Sessions:     usage.SessionsSeen,
+		LastUsed:     usage.LastUsed,
+		AvgLatencyMs: usage.AvgLatencyMs,
+	}
+	if h.Engine != nil {
+		payload, _ := json.Marshal(map[string]any{
+			"jsonrpc": "2.0",
+			"method":  "tools/call",
+			"params":  map[string]string{"name": name},
+		})
+		data.Policy = string(h.Engine.Evaluate(string(DirHostToServer), "tools/call", name, string(payload)).Action)
+	}
+	var buf bytes.Buffer
+	if err := h.tmpl.Execute(&buf, data); err != nil {
+		h.logger.Warn("tool hint template failed", "tool", name, "error", err)
+		return ""
+	}
+	return strings.TrimSpace(buf.String())
+}
+
+func appendHint(description, hint string) string {
+	if description == "" {
+		return hint
+	}
+	return description + "\n\n" + hint
+}
+
+// setDescription returns the tool definition raw with its description
+// replaced, keeping every other member as it was.
+func setDescription(raw json.RawMessage, description string) (json.RawMessage, bool) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, false
+	}
+	fields["description"], _ = json.Marshal(description)
+	out, err := json.Marshal(fields)
+	if err != nil {
+		return nil, false
+	}
+	return out, true
+}
@@ -0,0 +1,116 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/contextgate/contextgate/internal/policy"
+	"github.com/contextgate/contextgate/internal/store"
+)
+
+type statsStub struct {
+	tools   []store.ToolAnalytics
+	lookups int
+}
+
+func (s *statsStub) GetToolAnalytics(context.Context, string) (*store.ToolAnalyticsSummary, error) {
+	s.lookups++
+	return &store.ToolAnalyticsSummary{Tools: s.tools}, nil
+}
+
+func hintedTools(t *testing.T, h *ToolHintInterceptor, id, tools string) []map[string]any {
+	t.Helper()
+	ctx := context.Background()
+	h.Intercept(ctx, makeToolsListRequest(id))
+	out, err := h.Intercept(ctx, makeToolsListResponse(id, tools))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var resp struct {
+		ID     json.RawMessage `json:"id"`
+		Result struct {
+			Tools []map[string]any `json:"tools"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(out, &resp); err != nil {
+		t.Fatalf("invalid response %s: %v", out, err)
+	}
+	if string(resp.ID) != id {
+		t.Errorf("id = %s, want %s", resp.ID, id)
+	}
+	return resp.Result.Tools
+}
+
+func TestToolHints_EnrichesDescriptions(t *testing.T) {
+	cfg, err := policy.LoadBytes([]byte(`
+rules:
+  - name: review-writes
+    action: require_approval
+    tools: ["write_file"]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := &statsStub{tools: []store.ToolAnalytics{
+		{ToolName: "read_file", CallCount: 12, SessionsSeen: 4},
+	}}
+	h, err := NewToolHintInterceptor(stats, "", testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Engine = policy.NewEngine(cfg)
+
+	schema := map[string]any{"type": "object", "properties": map[string]any{"path": map[string]any{"type": "string"}}}
+	tools := hintedTools(t, h, "1", `[
+		{"name":"read_file","description":"Read a file.","inputSchema":{"type":"object","properties":{"path":{"type":"string"}}}},
+		{"name":"write_file","inputSchema":{"type":"object","properties":{"path":{"type":"string"}}}},
+		{"name":"list_dir","description":"List a directory."}
+	]`)
+	if len(tools) != 3 {
+		t.Fatalf("got %d tools", len(tools))
+	}
+
+	if got, want := tools[0]["description"], "Read a file.\n\nUsed 12× across 4 session(s)."; got != want {
+		t.Errorf("read_file description = %q, want %q", got, want)
+	}
+	if got, want := tools[1]["description"], "Calls require human approval."; got != want {
+		t.Errorf("write_file description = %q, want %q", got, want)
+	}
+	if got := tools[2]["description"]; got != "List a directory." {
+		t.Errorf("unused tool description changed to %q", got)
+	}
+	for _, tool := range tools[:2] {
+		if !reflect.DeepEqual(tool["inputSchema"], schema) {
+			t.Errorf("%s schema = %v", tool["name"], tool["inputSchema"])
+		}
+	}
+}
+
+func TestToolHints_CustomTemplateAndCache(t *testing.T) {
+	stats := &statsStub{tools: []store.ToolAnalytics{{ToolName: "search", CallCount: 3}}}
+	h, err := NewToolHintInterceptor(stats, "[{{.Name}}: {{.Calls}} calls]", testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tools := hintedTools(t, h, "1", `[{"name":"search","description":"Search."}]`)
+	if got := tools[0]["description"]; got != "Search.\n\n[search: 3 calls]" {
+		t.Errorf("description = %q", got)
+	}
+	hintedTools(t, h, "2", `[{"name":"search","description":"Search."}]`)
+	if stats.lookups != 1 {
+		t.Errorf("stats looked up %d times, want 1", stats.lookups)
+	}
+
+	// Responses to other requests are untouched
+	resp := makeToolsListResponse("3", `[{"name":"search","description":"Search."}]`)
+	if out, _ := h.Intercept(context.Background(), resp); string(out) != string(resp.RawBytes) {
+		t.Errorf("uncorrelated response rewritten: %s", out)
+	}
+
+	if _, err := NewToolHintInterceptor(stats, "{{.Calls", testLogger()); err == nil {
+		t.Error("expected an invalid template to be rejected")
+	}
+}
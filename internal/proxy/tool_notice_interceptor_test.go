@@ -0,0 +1,103 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// noticeExchange sends a tools/call for tool through n and returns what
+// becomes of the server's result.
+func noticeExchange(t *testing.T, n *ToolNoticeInterceptor, tool, result string) []byte {
+	t.Helper()
+	ctx := context.Background()
+	call := parsedMessage(t, DirHostToServer, `{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"`+tool+`"}}`)
+	if _, err := n.Intercept(ctx, call); err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	out, err := n.Intercept(ctx, parsedMessage(t, DirServerToHost, `{"jsonrpc":"2.0","id":3,"result":`+result+`}`))
+	if err != nil {
+		t.Fatalf("response: %v", err)
+	}
+	return out
+}
+
+type noticeResult struct {
+	ID     int `json:"id"`
+	Result struct {
+		Content []struct {
+			Type     string `json:"type"`
+			Text     string `json:"text"`
+			MimeType string `json:"mimeType"`
+		} `json:"content"`
+		IsError bool           `json:"isError"`
+		Meta    map[string]any `json:"_meta"`
+	} `json:"result"`
+}
+
+func TestToolNotice_PrependsBlock(t *testing.T) {
+	n := NewToolNoticeInterceptor("UNTRUSTED:", "fetch")
+	out := noticeExchange(t, n, "fetch",
+		`{"content":[{"type":"text","text":"page body"},{"type":"image","data":"AA==","mimeType":"image/png"}],"isError":false,"_meta":{"k":"v"}}`)
+
+	var got noticeResult
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, out)
+	}
+	c := got.Result.Content
+	if len(c) != 3 || c[0].Type != "text" || c[0].Text != "UNTRUSTED:" {
+		t.Fatalf("content = %+v, want the notice first", c)
+	}
+	if c[1].Text != "page body" || c[2].Type != "image" || c[2].MimeType != "image/png" {
+		t.Errorf("original blocks changed: %+v", c[1:])
+	}
+	if got.ID != 3 || got.Result.Meta["k"] != "v" {
+		t.Errorf("surrounding structure lost: %s", out)
+	}
+}
+
+func TestToolNotice_Footer(t *testing.T) {
+	n := NewToolNoticeInterceptor("", "mcp__web__*")
+	n.Footer = "END"
+	out := noticeExchange(t, n, "mcp__web__search", `{"content":[{"type":"text","text":"hits"}]}`)
+
+	var got noticeResult
+	json.Unmarshal(out, &got)
+	c := got.Result.Content
+	if len(c) != 3 || c[0].Text != DefaultToolNotice || c[1].Text != "hits" || c[2].Text != "END" {
+		t.Errorf("content = %+v, want default notice, body, footer", c)
+	}
+}
+
+func TestToolNotice_OtherToolsUntouched(t *testing.T) {
+	n := NewToolNoticeInterceptor("UNTRUSTED:", "fetch")
+	result := `{"content":[{"type":"text","text":"file body"}]}`
+	out := noticeExchange(t, n, "read_file", result)
+
+	if want := `{"jsonrpc":"2.0","id":3,"result":` + result + `}`; string(out) != want {
+		t.Errorf("got %s, want %s", out, want)
+	}
+}
+
+func TestToolNotice_NoContentArray(t *testing.T) {
+	n := NewToolNoticeInterceptor("UNTRUSTED:")
+	raw := `{"jsonrpc":"2.0","id":3,"result":{"structuredContent":{"x":1}}}`
+	out := noticeExchange(t, n, "fetch", `{"structuredContent":{"x":1}}`)
+	if string(out) != raw {
+		t.Errorf("got %s, want it unchanged", out)
+	}
+}
+
+func TestToolNotice_ExpiresUnansweredCalls(t *testing.T) {
+	n := NewToolNoticeInterceptor("UNTRUSTED:")
+	start := time.Now()
+	for i, id := range []string{"1", "2"} {
+		call := parsedMessage(t, DirHostToServer, `{"jsonrpc":"2.0","id":`+id+`,"method":"tools/call","params":{"name":"fetch"}}`)
+		call.Timestamp = start.Add(time.Duration(i) * (pendingTTL + time.Second))
+		n.Intercept(context.Background(), call)
+	}
+	if got := len(n.pending.pending); got != 1 {
+		t.Errorf("pending = %d, want the stale call dropped", got)
+	}
+}
@@ -0,0 +1,70 @@
+package proxy
+
+import "context"
+
+// MetaKeyUnrecognizedMethod is set on messages whose method is outside the
+// known set.
+const MetaKeyUnrecognizedMethod = "unrecognized_method"
+
+// DefaultKnownMethods are the requests and notifications defined by the
+// MCP specification.
+var DefaultKnownMethods = []string{
+	"initialize",
+	"ping",
+	"tools/list",
+	"tools/call",
+	"resources/list",
+	"resources/templates/list",
+	"resources/read",
+	"resources/subscribe",
+	"resources/unsubscribe",
+	"prompts/list",
+	"prompts/get",
+	"completion/complete",
+	"logging/setLevel",
+	"roots/list",
+	"sampling/createMessage",
+	"elicitation/create",
+	"notifications/initialized",
+	"notifications/cancelled",
+	"notifications/progress",
+	"notifications/message",
+	"notifications/roots/list_changed",
+	"notifications/resources/list_changed",
+	"notifications/resources/updated",
+	"notifications/prompts/list_changed",
+	"notifications/tools/list_changed",
+}
+
+// UnknownMethodInterceptor flags requests and notifications whose method
+// is not in a known set. Flagged messages are still forwarded; they are
+// marked for audit so new or nonstandard methods can be reviewed.
+type UnknownMethodInterceptor struct {
+	known map[string]bool
+}
+
+// NewUnknownMethodInterceptor creates an interceptor that treats the
+// DefaultKnownMethods plus extra as known.
+func NewUnknownMethodInterceptor(extra ...string) *UnknownMethodInterceptor {
+	known := make(map[string]bool, len(DefaultKnownMethods)+len(extra))
+	for _, m := range DefaultKnownMethods {
+		known[m] = true
+	}
+	for _, m := range extra {
+		known[m] = true
+	}
+	return &UnknownMethodInterceptor{known: known}
+}
+
+func (u *UnknownMethodInterceptor) Intercept(_ context.Context, msg *InterceptedMessage) ([]byte, error) {
+	if msg.ParseErr != nil || msg.Parsed.Method == "" || u.known[msg.Parsed.Method] {
+		return msg.RawBytes, nil
+	}
+
+	if msg.Metadata == nil {
+		msg.Metadata = make(map[string]any)
+	}
+	msg.Metadata[MetaKeyUnrecognizedMethod] = true
+	msg.Metadata[MetaKeyAudit] = true
+	return msg.RawBytes, nil
+}
@@ -0,0 +1,53 @@
+package proxy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/contextgate/contextgate/internal/eventbus"
+)
+
+func TestUnknownMethod_FlagsMadeUpMethod(t *testing.T) {
+	u := NewUnknownMethodInterceptor()
+	msg := parsedMessage(t, DirHostToServer, `{"jsonrpc":"2.0","id":1,"method":"tools/teleport"}`)
+
+	out, err := u.Intercept(context.Background(), msg)
+	if err != nil || out == nil {
+		t.Fatalf("expected the message to be forwarded, got %q, %v", out, err)
+	}
+	if msg.Metadata[MetaKeyUnrecognizedMethod] != true || msg.Metadata[MetaKeyAudit] != true {
+		t.Errorf("metadata = %v, want unrecognized and audit flags", msg.Metadata)
+	}
+}
+
+func TestUnknownMethod_KnownMethodsPass(t *testing.T) {
+	u := NewUnknownMethodInterceptor("x-vendor/sync")
+
+	for _, raw := range []string{
+		`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"read_file"}}`,
+		`{"jsonrpc":"2.0","method":"notifications/tools/list_changed"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"x-vendor/sync"}`,
+		`{"jsonrpc":"2.0","id":1,"result":{}}`, // responses carry no method
+	} {
+		msg := parsedMessage(t, DirHostToServer, raw)
+		if _, err := u.Intercept(context.Background(), msg); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if msg.Metadata != nil {
+			t.Errorf("%s flagged: %v", raw, msg.Metadata)
+		}
+	}
+}
+
+func TestLoggingInterceptor_RecordsUnrecognized(t *testing.T) {
+	st := &mockLogStore{}
+	chain := NewInterceptorChain(NewUnknownMethodInterceptor(), NewLoggingInterceptor(st, eventbus.New(10)))
+
+	msg := parsedMessage(t, DirServerToHost, `{"jsonrpc":"2.0","method":"notifications/made_up"}`)
+	if _, err := chain.Process(context.Background(), msg); err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if len(st.entries) != 1 || !st.entries[0].Unrecognized || !st.entries[0].Audit {
+		t.Errorf("entries = %+v, want one unrecognized audited entry", st.entries)
+	}
+}
@@ -0,0 +1,129 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	writeRetries     = 5
+	writeBackoffBase = 5 * time.Millisecond
+)
+
+// isRetryableWrite reports whether a write error is transient. EOF and
+// broken pipes mean the peer is gone and are never retried.
+func isRetryableWrite(err error) bool {
+	return errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.EINTR)
+}
+
+// writeWithRetry writes b to w, retrying transient failures with jittered
+// exponential backoff. Partial writes resume from where they stopped so
+// a message is never duplicated on the wire. A serialized writer is held
+// for all attempts, so no other line lands between a partial write and
+// its retry.
+func writeWithRetry(ctx context.Context, w io.Writer, b []byte) error {
+	if sw, ok := w.(serialWriter); ok {
+		return sw.locked(func(w io.Writer) error {
+			return writeRetrying(ctx, w, b)
+		})
+	}
+	return writeRetrying(ctx, w, b)
+}
+
+func writeRetrying(ctx context.Context, w io.Writer, b []byte) error {
+	backoff := writeBackoffBase
+	for attempt := 0; ; attempt++ {
+		n, err := w.Write(b)
+		if err == nil {
+			return nil
+		}
+		if !isRetryableWrite(err) || attempt >= writeRetries {
+			return err
+		}
+		b = b[n:]
+
+		// Full jitter: sleep a random duration in [backoff/2, backoff)
+		sleep := backoff/2 + rand.N(backoff/2)
+		select {
+		case <-time.After(sleep):
+		case <-ctx.Done():
+			return err
+		}
+		backoff *= 2
+	}
+}
+
+// serialWriter is a writer shared by several goroutines that can be held
+// across more than one write.
+type serialWriter interface {
+	locked(fn func(w io.Writer) error) error
+}
+
+// lockedWriter serializes writes so concurrent writers cannot interleave
+// partial lines. The host's stdout is written by both pipe directions: the
+// server's messages on one side, and errors answering blocked or busy host
+// requests on the other.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
+
+func (l *lockedWriter) locked(fn func(w io.Writer) error) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return fn(l.w)
+}
+
+// lockedWriteCloser serializes writes so concurrent writers cannot
+// interleave partial lines. The writer behind it can be replaced with
+// hold and release.
+type lockedWriteCloser struct {
+	mu     sync.Mutex
+	w      io.WriteCloser
+	closed bool
+}
+
+func (l *lockedWriteCloser) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
+
+func (l *lockedWriteCloser) locked(fn func(w io.Writer) error) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return fn(l.w)
+}
+
+func (l *lockedWriteCloser) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.closed = true
+	return l.w.Close()
+}
+
+// hold blocks writers until release.
+func (l *lockedWriteCloser) hold() {
+	l.mu.Lock()
+}
+
+// release lets writers through again, now writing to w. If the writer
+// was closed while held, w is closed too.
+func (l *lockedWriteCloser) release(w io.WriteCloser) {
+	defer l.mu.Unlock()
+	l.w = w
+	if l.closed {
+		w.Close()
+	}
+}
@@ -0,0 +1,146 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+)
+
+// flakyWriter fails the first len(errs) writes with the given errors,
+// accepting n bytes of each failed write first.
+type flakyWriter struct {
+	buf   bytes.Buffer
+	errs  []error
+	n     int
+	calls int
+}
+
+func (w *flakyWriter) Write(p []byte) (int, error) {
+	w.calls++
+	if len(w.errs) > 0 {
+		err := w.errs[0]
+		w.errs = w.errs[1:]
+		n := min(w.n, len(p))
+		w.buf.Write(p[:n])
+		return n, err
+	}
+	return w.buf.Write(p)
+}
+
+func TestWriteWithRetry_TransientThenSuccess(t *testing.T) {
+	for _, errno := range []error{syscall.EAGAIN, syscall.EINTR} {
+		w := &flakyWriter{errs: []error{errno}}
+		if err := writeWithRetry(context.Background(), w, []byte("hello\n")); err != nil {
+			t.Fatalf("%v: unexpected error: %v", errno, err)
+		}
+		if w.calls != 2 {
+			t.Errorf("%v: calls = %d, want 2", errno, w.calls)
+		}
+		if w.buf.String() != "hello\n" {
+			t.Errorf("%v: wrote %q, want %q", errno, w.buf.String(), "hello\n")
+		}
+	}
+}
+
+func TestWriteWithRetry_ResumesPartialWrite(t *testing.T) {
+	w := &flakyWriter{errs: []error{syscall.EAGAIN}, n: 3}
+	if err := writeWithRetry(context.Background(), w, []byte("hello\n")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if w.buf.String() != "hello\n" {
+		t.Errorf("wrote %q, want %q (no duplicated bytes)", w.buf.String(), "hello\n")
+	}
+}
+
+func TestWriteWithRetry_FatalErrorsNotRetried(t *testing.T) {
+	for _, fatal := range []error{io.EOF, syscall.EPIPE, io.ErrClosedPipe} {
+		w := &flakyWriter{errs: []error{fatal}}
+		if err := writeWithRetry(context.Background(), w, []byte("x\n")); err != fatal {
+			t.Errorf("err = %v, want %v", err, fatal)
+		}
+		if w.calls != 1 {
+			t.Errorf("%v: calls = %d, want 1", fatal, w.calls)
+		}
+	}
+}
+
+func TestWriteWithRetry_GivesUp(t *testing.T) {
+	errs := make([]error, writeRetries+5)
+	for i := range errs {
+		errs[i] = syscall.EAGAIN
+	}
+	w := &flakyWriter{errs: errs}
+	if err := writeWithRetry(context.Background(), w, []byte("x\n")); err != syscall.EAGAIN {
+		t.Fatalf("err = %v, want EAGAIN", err)
+	}
+	if w.calls != writeRetries+1 {
+		t.Errorf("calls = %d, want %d", w.calls, writeRetries+1)
+	}
+}
+
+func TestPipeMessages_RetriesTransientWrite(t *testing.T) {
+	p := NewProxy(Config{SessionID: "test"}, NewInterceptorChain(), testLogger())
+	dst := &flakyWriter{errs: []error{syscall.EAGAIN}}
+
+	src := strings.NewReader(toolsCallLine(1) + toolsCallLine(2))
+	if err := p.pipeMessages(context.Background(), src, dst, DirHostToServer); err != nil {
+		t.Fatalf("pipeMessages: %v", err)
+	}
+	if got := strings.Count(dst.buf.String(), "\n"); got != 2 {
+		t.Errorf("forwarded %d messages, want 2: %q", got, dst.buf.String())
+	}
+}
+
+// splitWriter accepts half of every other write and fails it with EAGAIN,
+// so each line takes two writes. It is not safe for concurrent use: the
+// race detector flags writers that reach it unserialized.
+type splitWriter struct {
+	buf   bytes.Buffer
+	split bool
+}
+
+func (w *splitWriter) Write(p []byte) (int, error) {
+	w.split = !w.split
+	if w.split && len(p) > 1 {
+		n, _ := w.buf.Write(p[:len(p)/2])
+		return n, syscall.EAGAIN
+	}
+	return w.buf.Write(p)
+}
+
+func TestLockedWriter_KeepsRetriedLinesWhole(t *testing.T) {
+	inner := &splitWriter{}
+	w := &lockedWriter{w: inner}
+
+	const writers, lines = 4, 10
+	var wg sync.WaitGroup
+	for g := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range lines {
+				line := fmt.Sprintf(`{"jsonrpc":"2.0","method":"notifications/progress","params":{"writer":%d,"n":%d}}`, g, i)
+				if err := writeWithRetry(context.Background(), w, []byte(line+"\n")); err != nil {
+					t.Errorf("write: %v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	out := strings.Split(strings.TrimSuffix(inner.buf.String(), "\n"), "\n")
+	if len(out) != writers*lines {
+		t.Fatalf("got %d lines, want %d", len(out), writers*lines)
+	}
+	for _, line := range out {
+		if !json.Valid([]byte(line)) {
+			t.Errorf("interleaved line %q", line)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package store
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// PayloadKeyEnv names the environment variable holding the payload
+// encryption key when no key file is given.
+const PayloadKeyEnv = "CONTEXTGATE_DB_KEY"
+
+// encryptedPrefix marks a stored value as ciphertext, so databases
+// holding both encrypted and plaintext rows can be read.
+const encryptedPrefix = "enc:v1:"
+
+// encryptedPlaceholder stands in for a payload that can't be decrypted.
+const encryptedPlaceholder = "[encrypted payload; key not available]"
+
+// PayloadCipher encrypts stored payloads with AES-256-GCM. Each session
+// has its own key, derived from the master key with HKDF, and its ID is
+// bound to every ciphertext, so a row moved to another session won't
+// decrypt.
+type PayloadCipher struct {
+	master []byte
+
+	mu    sync.Mutex
+	aeads map[string]cipher.AEAD // session ID → AEAD under its derived key
+}
+
+// NewPayloadCipher creates a cipher from a 32-byte master key.
+func NewPayloadCipher(key []byte) (*PayloadCipher, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("payload key must be 32 bytes, got %d", len(key))
+	}
+	return &PayloadCipher{master: key, aeads: make(map[string]cipher.AEAD)}, nil
+}
+
+// LoadPayloadKey reads a master key, base64 or hex encoded, from file or,
+// if file is empty, from the PayloadKeyEnv environment variable. It
+// returns nil if neither is set.
+func LoadPayloadKey(file string) ([]byte, error) {
+	text := os.Getenv(PayloadKeyEnv)
+	source := PayloadKeyEnv
+	if file != "" {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("read payload key: %w", err)
+		}
+		text, source = string(b), file
+	}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		if file != "" {
+			return nil, fmt.Errorf("payload key file %s is empty", file)
+		}
+		return nil, nil
+	}
+	if key, err := base64.StdEncoding.DecodeString(text); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	if key, err := hex.DecodeString(text); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	return nil, fmt.Errorf("%s: want a 32-byte key, base64 or hex encoded", source)
+}
+
+func (c *PayloadCipher) aead(sessionID string) (cipher.AEAD, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if a, ok := c.aeads[sessionID]; ok {
+		return a, nil
+	}
+	key, err := hkdf.Key(sha256.New, c.master, nil, "contextgate payload "+sessionID, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	a, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	c.aeads[sessionID] = a
+	return a, nil
+}
+
+// Seal encrypts a session's payload for storage.
+func (c *PayloadCipher) Seal(sessionID, plaintext string) (string, error) {
+	a, err := c.aead(sessionID)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, a.NonceSize(), a.NonceSize()+len(plaintext)+a.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := a.Seal(nonce, nonce, []byte(plaintext), []byte(sessionID))
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a stored value. Values without the encrypted marker are
+// plaintext from before encryption was enabled and are returned as is.
+func (c *PayloadCipher) Open(sessionID, stored string) (string, error) {
+	data, ok := strings.CutPrefix(stored, encryptedPrefix)
+	if !ok {
+		return stored, nil
+	}
+	if c == nil {
+		return "", errors.New("payload is encrypted and no key is configured")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return "", fmt.Errorf("decode encrypted payload: %w", err)
+	}
+	a, err := c.aead(sessionID)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < a.NonceSize() {
+		return "", errors.New("encrypted payload is truncated")
+	}
+	plain, err := a.Open(nil, sealed[:a.NonceSize()], sealed[a.NonceSize():], []byte(sessionID))
+	if err != nil {
+		return "", fmt.Errorf("decrypt payload: %w", err)
+	}
+	return string(plain), nil
+}
+
+// seal encrypts a value for storage if the store has a cipher.
+func (s *SQLiteStore) seal(sessionID, value string) (string, error) {
+	if s.Cipher == nil {
+		return value, nil
+	}
+	return s.Cipher.Seal(sessionID, value)
+}
+
+// open decrypts a stored value. One that can't be decrypted, because the
+// key is missing or wrong, reads as a placeholder rather than failing
+// the whole query.
+func (s *SQLiteStore) open(sessionID, stored string) string {
+	plain, err := s.Cipher.Open(sessionID, stored)
+	if err != nil {
+		s.logger.Warn("cannot decrypt stored payload", "session", sessionID, "error", err)
+		return encryptedPlaceholder
+	}
+	return plain
+}
@@ -0,0 +1,150 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testCipher(t *testing.T, seed byte) *PayloadCipher {
+	t.Helper()
+	c, err := NewPayloadCipher(bytes.Repeat([]byte{seed}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestPayloadCipher_RoundTrip(t *testing.T) {
+	c := testCipher(t, 1)
+	sealed, err := c.Seal("s1", `{"secret":"hunter2"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(sealed, encryptedPrefix) || strings.Contains(sealed, "hunter2") {
+		t.Fatalf("sealed = %q, want marked ciphertext", sealed)
+	}
+	if got, err := c.Open("s1", sealed); err != nil || got != `{"secret":"hunter2"}` {
+		t.Fatalf("Open = %q, %v", got, err)
+	}
+	if _, err := c.Open("s2", sealed); err == nil {
+		t.Error("payload opened under another session's key")
+	}
+	if _, err := testCipher(t, 2).Open("s1", sealed); err == nil {
+		t.Error("payload opened with the wrong key")
+	}
+	if got, err := c.Open("s1", "plain"); err != nil || got != "plain" {
+		t.Errorf("Open(plaintext) = %q, %v", got, err)
+	}
+}
+
+func TestNewPayloadCipher_KeyLength(t *testing.T) {
+	if _, err := NewPayloadCipher(make([]byte, 16)); err == nil {
+		t.Error("expected an error for a 16-byte key")
+	}
+}
+
+func TestLoadPayloadKey(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+
+	t.Setenv(PayloadKeyEnv, "")
+	if got, err := LoadPayloadKey(""); err != nil || got != nil {
+		t.Fatalf("no key configured: got %v, %v", got, err)
+	}
+
+	t.Setenv(PayloadKeyEnv, base64.StdEncoding.EncodeToString(key))
+	if got, err := LoadPayloadKey(""); err != nil || !bytes.Equal(got, key) {
+		t.Fatalf("env key: got %v, %v", got, err)
+	}
+
+	// A key file takes precedence over the environment
+	file := filepath.Join(t.TempDir(), "db.key")
+	os.WriteFile(file, []byte(strings.Repeat("07", 32)+"\n"), 0600)
+	t.Setenv(PayloadKeyEnv, "garbage")
+	if got, err := LoadPayloadKey(file); err != nil || !bytes.Equal(got, key) {
+		t.Fatalf("hex key file: got %v, %v", got, err)
+	}
+
+	if _, err := LoadPayloadKey(""); err == nil {
+		t.Error("expected an error for a malformed key")
+	}
+}
+
+func TestEncryptedPayloads(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	// One row from before encryption was turned on
+	s.LogMessage(ctx, &LogEntry{
+		Timestamp: time.Now(), SessionID: "s1", Direction: "host_to_server", Kind: "request",
+		Method: "tools/call", MsgID: "1", Seq: 1, Payload: `{"plain":true}`,
+	})
+	s.Flush()
+
+	s.Cipher = testCipher(t, 1)
+	payload := `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"login","arguments":{"password":"hunter2"}}}`
+	s.LogMessage(ctx, &LogEntry{
+		Timestamp: time.Now(), SessionID: "s1", Direction: "host_to_server", Kind: "request",
+		Method: "tools/call", MsgID: "2", Seq: 2, ToolName: "login", Payload: payload,
+		Arguments: []byte(`{"password":"hunter2"}`),
+	})
+	s.Flush()
+	s.LogApproval(ctx, &ApprovalRecord{
+		ID: "a1", Timestamp: time.Now(), SessionID: "s1", Direction: "host_to_server",
+		RuleName: "r", Payload: payload, Decision: "approved",
+	})
+
+	// Nothing readable reaches the database
+	rows, err := s.db.Query(`SELECT payload, COALESCE(arguments, '') FROM messages WHERE msg_id = '2'
+		UNION ALL SELECT payload, '' FROM approvals`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for rows.Next() {
+		var raw, args string
+		if err := rows.Scan(&raw, &args); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(raw, encryptedPrefix) || strings.Contains(raw+args, "hunter2") {
+			t.Errorf("stored payload %q, arguments %q: want ciphertext only", raw, args)
+		}
+		n++
+	}
+	rows.Close()
+	if n != 2 {
+		t.Fatalf("got %d encrypted rows, want 2", n)
+	}
+
+	// Newest first
+	entries, err := s.Query(ctx, QueryFilter{SessionID: "s1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Payload != payload || entries[1].Payload != `{"plain":true}` {
+		t.Fatalf("Query payloads = %+v", entries)
+	}
+	got, err := s.GetMessage(ctx, entries[0].ID)
+	if err != nil || got.Payload != payload {
+		t.Fatalf("GetMessage = %q, %v", got.Payload, err)
+	}
+	approvals, err := s.GetApprovals(ctx, "s1")
+	if err != nil || len(approvals) != 1 || approvals[0].Payload != payload {
+		t.Fatalf("GetApprovals = %+v, %v", approvals, err)
+	}
+
+	// Without the key, encrypted rows read as a placeholder
+	s.Cipher = nil
+	entries, err = s.Query(ctx, QueryFilter{SessionID: "s1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries[0].Payload != encryptedPlaceholder || entries[1].Payload != `{"plain":true}` {
+		t.Errorf("payloads without key = %q, %q", entries[0].Payload, entries[1].Payload)
+	}
+}
@@ -0,0 +1,210 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// CheckIntegrity runs SQLite's integrity check on the database at path.
+// It returns the problems reported, or nil if the database is healthy.
+// An error means the check could not run at all, which usually also
+// indicates a damaged file.
+func CheckIntegrity(path string) ([]string, error) {
+	db, err := openExisting(path)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query("PRAGMA integrity_check")
+	if err != nil {
+		return nil, fmt.Errorf("integrity check: %w", err)
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return nil, fmt.Errorf("integrity check: %w", err)
+		}
+		if line != "ok" {
+			problems = append(problems, line)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("integrity check: %w", err)
+	}
+	return problems, nil
+}
+
+// TableRecovery reports how much of one table Repair salvaged.
+type TableRecovery struct {
+	Name string
+	Rows int
+	Err  error // set when reading stopped early; Rows were still recovered
+}
+
+// RepairResult describes a completed Repair.
+type RepairResult struct {
+	Backup   string // where the original database was moved
+	Tables   []TableRecovery
+	Warnings []string // schema objects that could not be recreated
+}
+
+// Repair rebuilds the database at path by copying every readable row into
+// a fresh file, then swaps it in. The original (with any WAL files) is
+// kept alongside with a ".corrupt-<time>" suffix. No proxy may be using
+// the database while it runs.
+func Repair(path string) (*RepairResult, error) {
+	src, err := openExisting(path)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	tmp := path + ".repair"
+	removeDBFiles(tmp)
+	dst, err := sql.Open("sqlite", "file:"+tmp)
+	if err != nil {
+		return nil, fmt.Errorf("open repair target: %w", err)
+	}
+	res, err := copyDatabase(src, dst)
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		removeDBFiles(tmp)
+		return nil, err
+	}
+	src.Close()
+
+	res.Backup = path + ".corrupt-" + time.Now().Format("20060102-150405")
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		if err := os.Rename(path+suffix, res.Backup+suffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("back up original: %w", err)
+		}
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return nil, fmt.Errorf("install repaired database: %w", err)
+	}
+	return res, nil
+}
+
+// copyDatabase recreates src's schema in dst and copies each table row by
+// row, keeping whatever can be read before a damaged page stops a table.
+func copyDatabase(src, dst *sql.DB) (*RepairResult, error) {
+	rows, err := src.Query(`
+		SELECT type, name, sql FROM sqlite_master
+		WHERE sql IS NOT NULL AND name NOT LIKE 'sqlite_%'
+		ORDER BY type != 'table'
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("read schema: %w", err)
+	}
+	type object struct{ typ, name, sql string }
+	var objects []object
+	for rows.Next() {
+		var o object
+		if err := rows.Scan(&o.typ, &o.name, &o.sql); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("read schema: %w", err)
+		}
+		objects = append(objects, o)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("read schema: %w", err)
+	}
+
+	res := &RepairResult{}
+	for _, o := range objects {
+		if o.typ != "table" {
+			// Indexes go in after the data so a damaged one can't block it
+			if _, err := dst.Exec(o.sql); err != nil {
+				res.Warnings = append(res.Warnings, fmt.Sprintf("%s %s: %v", o.typ, o.name, err))
+			}
+			continue
+		}
+		if _, err := dst.Exec(o.sql); err != nil {
+			return nil, fmt.Errorf("create table %s: %w", o.name, err)
+		}
+		n, err := copyTable(src, dst, o.name)
+		res.Tables = append(res.Tables, TableRecovery{Name: o.name, Rows: n, Err: err})
+	}
+	return res, nil
+}
+
+func copyTable(src, dst *sql.DB, table string) (int, error) {
+	quoted := `"` + strings.ReplaceAll(table, `"`, `""`) + `"`
+	rows, err := src.Query("SELECT * FROM " + quoted)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := dst.Begin()
+	if err != nil {
+		return 0, err
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(cols)), ", ")
+	stmt, err := tx.Prepare("INSERT INTO " + quoted + " VALUES (" + placeholders + ")")
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	defer stmt.Close()
+
+	n := 0
+	vals := make([]any, len(cols))
+	ptrs := make([]any, len(cols))
+	for i := range vals {
+		ptrs[i] = &vals[i]
+	}
+	var readErr error
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			readErr = err
+			break
+		}
+		if _, err := stmt.Exec(vals...); err != nil {
+			readErr = err
+			break
+		}
+		n++
+	}
+	if readErr == nil {
+		readErr = rows.Err()
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return n, readErr
+}
+
+// openExisting opens a database that must already exist, rather than
+// letting the driver create an empty one.
+func openExisting(path string) (*sql.DB, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+	db, err := sql.Open("sqlite", "file:"+path)
+	if err != nil {
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+	return db, nil
+}
+
+func removeDBFiles(path string) {
+	for _, suffix := range []string{"", "-wal", "-shm", "-journal"} {
+		os.Remove(path + suffix)
+	}
+}
@@ -0,0 +1,108 @@
+package store
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var quietLogger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+
+// seededDB creates a closed database at a temp path holding n messages.
+func seededDB(t *testing.T, n int) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.db")
+	s, err := NewSQLiteStore(path, quietLogger)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	ctx := context.Background()
+	s.CreateSession(ctx, &Session{ID: "s1", StartedAt: time.Now(), Command: "test"})
+	for i := 0; i < n; i++ {
+		s.LogMessage(ctx, &LogEntry{Timestamp: time.Now(), SessionID: "s1", Direction: "host_to_server",
+			Kind: "request", Method: "tools/call", Payload: `{"jsonrpc":"2.0"}`, Seq: int64(i + 1)})
+	}
+	s.Close()
+	return path
+}
+
+func TestCheckIntegrity_Healthy(t *testing.T) {
+	problems, err := CheckIntegrity(seededDB(t, 10))
+	if err != nil {
+		t.Fatalf("CheckIntegrity: %v", err)
+	}
+	if len(problems) != 0 {
+		t.Errorf("problems = %v, want none", problems)
+	}
+}
+
+func TestCheckIntegrity_MissingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.db")
+	if _, err := CheckIntegrity(path); err == nil {
+		t.Fatal("expected an error for a missing database")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("check created the missing database")
+	}
+}
+
+func TestCheckIntegrity_Corrupted(t *testing.T) {
+	path := seededDB(t, 500)
+
+	// Clobber a page in the middle of the file, past the header and schema
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, _ := f.Stat()
+	garbage := make([]byte, 4096)
+	for i := range garbage {
+		garbage[i] = 0xA5
+	}
+	f.WriteAt(garbage, info.Size()/2/4096*4096)
+	f.Close()
+
+	problems, err := CheckIntegrity(path)
+	if err == nil && len(problems) == 0 {
+		t.Fatal("corruption went undetected")
+	}
+}
+
+func TestRepair(t *testing.T) {
+	path := seededDB(t, 25)
+
+	res, err := Repair(path)
+	if err != nil {
+		t.Fatalf("Repair: %v", err)
+	}
+	if _, err := os.Stat(res.Backup); err != nil {
+		t.Errorf("backup missing: %v", err)
+	}
+	recovered := map[string]int{}
+	for _, tr := range res.Tables {
+		if tr.Err != nil {
+			t.Errorf("table %s: %v", tr.Name, tr.Err)
+		}
+		recovered[tr.Name] = tr.Rows
+	}
+	if recovered["messages"] != 25 || recovered["sessions"] != 1 {
+		t.Errorf("recovered %v, want 25 messages and 1 session", recovered)
+	}
+
+	// The rebuilt database opens normally and keeps its data
+	s, err := NewSQLiteStore(path, quietLogger)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer s.Close()
+	msgs, err := s.Query(context.Background(), QueryFilter{SessionID: "s1"})
+	if err != nil || len(msgs) != 25 {
+		t.Fatalf("got %d messages (%v), want 25", len(msgs), err)
+	}
+	if problems, err := CheckIntegrity(path); err != nil || len(problems) != 0 {
+		t.Errorf("repaired database unhealthy: %v %v", problems, err)
+	}
+}
@@ -0,0 +1,225 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// migration is one numbered schema change. schema.sql always holds the
+// latest schema, so a new database is created complete and every
+// migration is recorded as applied; migrations only run against
+// databases created by older versions. Append new migrations with the
+// next version and never edit or renumber one that has shipped.
+type migration struct {
+	version int
+	name    string
+	up      func(tx *sql.Tx) error
+}
+
+// migrations brings older databases up to schema.sql, in version order.
+var migrations = []migration{
+	{1, "policy columns", addColumns("messages",
+		"audit INTEGER NOT NULL DEFAULT 0",
+		"scrub_count INTEGER NOT NULL DEFAULT 0",
+		"matched_rules TEXT",
+		"tool_name TEXT",
+		"policy_action TEXT",
+	)},
+	{2, "token estimates", addColumns("messages", "token_estimate INTEGER NOT NULL DEFAULT 0")},
+	{3, "message sequence numbers", execAll(
+		addColumns("messages", "seq INTEGER NOT NULL DEFAULT 0"),
+		execSQL("CREATE INDEX IF NOT EXISTS idx_messages_seq ON messages(seq)"),
+	)},
+	{4, "unrecognized methods", addColumns("messages", "unrecognized INTEGER NOT NULL DEFAULT 0")},
+	{5, "response latency", addColumns("messages", "latency_ms REAL")},
+	{6, "tool registry", execSQL(
+		`CREATE TABLE IF NOT EXISTS tool_registry (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			session_id TEXT NOT NULL,
+			tool_name TEXT NOT NULL,
+			description TEXT NOT NULL DEFAULT '',
+			first_seen TEXT NOT NULL,
+			UNIQUE(session_id, tool_name)
+		)`,
+		"CREATE INDEX IF NOT EXISTS idx_tool_registry_session ON tool_registry(session_id)",
+		"CREATE INDEX IF NOT EXISTS idx_tool_registry_tool ON tool_registry(tool_name)",
+	)},
+	{7, "tool conflicts", execSQL(
+		`CREATE TABLE IF NOT EXISTS tool_conflicts (
+			session_id TEXT NOT NULL,
+			tool_name TEXT NOT NULL,
+			copies INTEGER NOT NULL,
+			last_seen TEXT NOT NULL,
+			UNIQUE(session_id, tool_name)
+		)`,
+	)},
+	{8, "message annotations", addColumns("messages", "tags TEXT", "note TEXT")},
+	{9, "tool last seen", execAll(
+		addColumns("tool_registry", "last_seen TEXT NOT NULL DEFAULT ''"),
+		execSQL("UPDATE tool_registry SET last_seen = first_seen WHERE last_seen = ''"),
+	)},
+	{10, "session client", addColumns("sessions", "client TEXT NOT NULL DEFAULT ''")},
+	{11, "tool call arguments", execAll(
+		addColumns("messages", "arguments TEXT"),
+		execSQL("CREATE INDEX IF NOT EXISTS idx_messages_arguments ON messages(tool_name) WHERE arguments IS NOT NULL"),
+	)},
+	{12, "pruned tool counts", addColumns("messages", "tools_pruned INTEGER NOT NULL DEFAULT 0")},
+	// Databases older than the approvals table get it, complete, from
+	// schema.sql after migrating
+	{13, "approval reasons", func(tx *sql.Tx) error {
+		var n int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'approvals'`).Scan(&n); err != nil || n == 0 {
+			return err
+		}
+		return addColumns("approvals", "reason TEXT NOT NULL DEFAULT ''")(tx)
+	}},
+	{14, "tool input properties", addColumns("tool_registry", "input_properties TEXT")},
+	// Earlier responses get the tool of the closest request before them
+	// with their ID, which is the one they answered even if IDs repeat
+	{15, "response tool names", execAll(
+		addColumns("messages", "request_tool TEXT"),
+		execSQL("CREATE INDEX IF NOT EXISTS idx_messages_msg_id_tmp ON messages(session_id, msg_id)"),
+		execSQL(`UPDATE messages SET request_tool = (
+			SELECT req.tool_name FROM messages req
+			WHERE req.session_id = messages.session_id AND req.msg_id = messages.msg_id
+				AND req.direction = 'host_to_server' AND req.kind = 'request' AND req.id < messages.id
+			ORDER BY req.id DESC LIMIT 1
+		) WHERE direction = 'server_to_host' AND kind IN ('response', 'error')`),
+		execSQL("UPDATE messages SET request_tool = NULL WHERE request_tool = ''"),
+		execSQL("DROP INDEX idx_messages_msg_id_tmp"),
+		execSQL("CREATE INDEX IF NOT EXISTS idx_messages_request_tool ON messages(request_tool) WHERE request_tool IS NOT NULL"),
+	)},
+	// Earlier tools count as added mid-session if they weren't registered
+	// with the session's first batch, as ToolTimeline used to tell
+	{16, "tools added mid-session", execAll(
+		addColumns("tool_registry", "mid_session INTEGER NOT NULL DEFAULT 0"),
+		execSQL(`UPDATE tool_registry SET mid_session = first_seen != (
+			SELECT b.first_seen FROM tool_registry b
+			WHERE b.session_id = tool_registry.session_id
+			ORDER BY b.id LIMIT 1
+		)`),
+	)},
+}
+
+// SchemaVersion is the version of the latest migration.
+func SchemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// initSchema creates a new database from schema.sql or migrates an
+// existing one.
+func initSchema(db *sql.DB) error {
+	var tables int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'messages'`).Scan(&tables); err != nil {
+		return fmt.Errorf("inspect schema: %w", err)
+	}
+	fresh := tables == 0
+
+	if fresh {
+		if _, err := db.Exec(schemaSQL); err != nil {
+			return fmt.Errorf("init schema: %w", err)
+		}
+		return migrate(db, migrations, true)
+	}
+	if err := migrate(db, migrations, false); err != nil {
+		return err
+	}
+	// Tables added to schema.sql without a migration of their own are
+	// created here; existing ones are left alone
+	if _, err := db.Exec(schemaSQL); err != nil {
+		return fmt.Errorf("init schema: %w", err)
+	}
+	return nil
+}
+
+// migrate applies the migrations not yet recorded in schema_migrations,
+// each in its own transaction. With fresh, the database already has the
+// latest schema and the migrations are only recorded.
+func migrate(db *sql.DB, list []migration, fresh bool) error {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		name       TEXT NOT NULL,
+		applied_at TEXT NOT NULL
+	)`); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	var current int
+	if err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("read schema version: %w", err)
+	}
+
+	for _, m := range list {
+		if m.version <= current {
+			continue
+		}
+		tx, err := db.Begin()
+		if err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.version, m.name, err)
+		}
+		if !fresh {
+			if err := m.up(tx); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("migration %d (%s): %w", m.version, m.name, err)
+			}
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`,
+			m.version, m.name, time.Now().UTC().Format(time.RFC3339Nano)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d (%s): %w", m.version, m.name, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.version, m.name, err)
+		}
+	}
+	return nil
+}
+
+// execSQL returns a migration step that runs statements in order.
+func execSQL(stmts ...string) func(*sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		for _, stmt := range stmts {
+			if _, err := tx.Exec(stmt); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// execAll returns a migration step that runs steps in order.
+func execAll(steps ...func(*sql.Tx) error) func(*sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		for _, step := range steps {
+			if err := step(tx); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// addColumns returns a migration step that adds column definitions to
+// table, skipping columns that already exist. Databases that predate
+// schema_migrations may have any subset of them from the old ad-hoc
+// migrations.
+func addColumns(table string, defs ...string) func(*sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		for _, def := range defs {
+			var name string
+			fmt.Sscan(def, &name)
+			var n int
+			if err := tx.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, name).Scan(&n); err != nil {
+				return err
+			}
+			if n > 0 {
+				continue
+			}
+			if _, err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", table, def)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,258 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func appliedVersions(t *testing.T, db *sql.DB) []int {
+	t.Helper()
+	rows, err := db.Query(`SELECT version FROM schema_migrations ORDER BY version`)
+	if err != nil {
+		t.Fatalf("read schema_migrations: %v", err)
+	}
+	defer rows.Close()
+	var out []int
+	for rows.Next() {
+		var v int
+		rows.Scan(&v)
+		out = append(out, v)
+	}
+	return out
+}
+
+func columnNames(t *testing.T, db *sql.DB, table string) map[string]bool {
+	t.Helper()
+	rows, err := db.Query(`SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	out := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		rows.Scan(&name)
+		out[name] = true
+	}
+	return out
+}
+
+func wantAllVersions(t *testing.T, got []int) {
+	t.Helper()
+	if len(got) != len(migrations) || got[len(got)-1] != SchemaVersion() {
+		t.Errorf("applied versions = %v, want 1..%d", got, SchemaVersion())
+	}
+}
+
+func TestMigrate_FreshDatabase(t *testing.T) {
+	s := newTestStore(t)
+
+	wantAllVersions(t, appliedVersions(t, s.db))
+	cols := columnNames(t, s.db, "messages")
+	for _, c := range []string{"audit", "token_estimate", "seq", "unrecognized", "latency_ms"} {
+		if !cols[c] {
+			t.Errorf("messages is missing column %s", c)
+		}
+	}
+}
+
+func TestMigrate_OldSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "old.db")
+	db, err := sql.Open("sqlite", "file:"+path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The original schema, before any of the migrations, with some data
+	for _, stmt := range []string{
+		`CREATE TABLE messages (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			timestamp TEXT NOT NULL,
+			session_id TEXT NOT NULL,
+			direction TEXT NOT NULL,
+			kind TEXT NOT NULL,
+			method TEXT,
+			msg_id TEXT,
+			payload TEXT NOT NULL,
+			size_bytes INTEGER NOT NULL,
+			blocked INTEGER NOT NULL DEFAULT 0
+		)`,
+		`CREATE TABLE sessions (id TEXT PRIMARY KEY, started_at TEXT NOT NULL, ended_at TEXT, command TEXT NOT NULL, args TEXT)`,
+		`INSERT INTO sessions (id, started_at, command) VALUES ('old', '2024-01-01T00:00:00Z', 'server')`,
+		`INSERT INTO messages (timestamp, session_id, direction, kind, method, msg_id, payload, size_bytes)
+			VALUES ('2024-01-01T00:00:00Z', 'old', 'host_to_server', 'request', 'tools/list', '1', '{}', 2)`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("seed old schema: %v", err)
+		}
+	}
+	db.Close()
+
+	s, err := NewSQLiteStore(path, quietLogger)
+	if err != nil {
+		t.Fatalf("open old database: %v", err)
+	}
+	defer s.Close()
+
+	wantAllVersions(t, appliedVersions(t, s.db))
+	if !columnNames(t, s.db, "messages")["latency_ms"] || !columnNames(t, s.db, "tool_registry")["last_seen"] {
+		t.Error("old database was not brought up to the latest schema")
+	}
+
+	// Old rows are still readable and new ones can be written
+	ctx := context.Background()
+	s.LogMessage(ctx, &LogEntry{Timestamp: time.Now(), SessionID: "old", Direction: "server_to_host",
+		Kind: "response", MsgID: "1", Payload: `{}`, LatencyMs: 3})
+	s.Flush()
+	entries, err := s.Query(ctx, QueryFilter{SessionID: "old"})
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("Query = %d entries, %v; want 2", len(entries), err)
+	}
+	if err := s.RegisterTools(ctx, "old", []ToolRecord{{ToolName: "search"}}); err != nil {
+		t.Errorf("RegisterTools on migrated database: %v", err)
+	}
+}
+
+func TestMigrate_BackfillsRequestTool(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "v14.db")
+	s, err := NewSQLiteStore(path, quietLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.db.Exec(`DELETE FROM schema_migrations WHERE version >= 15`)
+	s.db.Exec(`DROP INDEX idx_messages_request_tool`)
+	s.db.Exec(`ALTER TABLE messages DROP COLUMN request_tool`)
+	// The same ID twice: each response answers the call just before it
+	for _, stmt := range []string{
+		`INSERT INTO messages (timestamp, session_id, direction, kind, method, msg_id, payload, size_bytes, tool_name)
+			VALUES ('t1', 's1', 'host_to_server', 'request', 'tools/call', '1', '{}', 2, 'read_file')`,
+		`INSERT INTO messages (timestamp, session_id, direction, kind, msg_id, payload, size_bytes)
+			VALUES ('t2', 's1', 'server_to_host', 'response', '1', '{}', 2)`,
+		`INSERT INTO messages (timestamp, session_id, direction, kind, method, msg_id, payload, size_bytes, tool_name)
+			VALUES ('t3', 's1', 'host_to_server', 'request', 'tools/call', '1', '{}', 2, 'write_file')`,
+		`INSERT INTO messages (timestamp, session_id, direction, kind, msg_id, payload, size_bytes)
+			VALUES ('t4', 's1', 'server_to_host', 'error', '1', '{}', 2)`,
+		`INSERT INTO messages (timestamp, session_id, direction, kind, method, msg_id, payload, size_bytes)
+			VALUES ('t5', 's1', 'host_to_server', 'request', 'tools/list', '2', '{}', 2)`,
+		`INSERT INTO messages (timestamp, session_id, direction, kind, msg_id, payload, size_bytes)
+			VALUES ('t6', 's1', 'server_to_host', 'response', '2', '{}', 2)`,
+	} {
+		if _, err := s.db.Exec(stmt); err != nil {
+			t.Fatalf("seed: %v", err)
+		}
+	}
+	s.Close()
+
+	s, err = NewSQLiteStore(path, quietLogger)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer s.Close()
+	rows, err := s.db.Query(`SELECT COALESCE(request_tool, '-') FROM messages WHERE direction = 'server_to_host' ORDER BY id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var tool string
+		rows.Scan(&tool)
+		got = append(got, tool)
+	}
+	if want := []string{"read_file", "write_file", "-"}; !slices.Equal(got, want) {
+		t.Errorf("backfilled request tools = %v, want %v", got, want)
+	}
+}
+
+func TestMigrate_BackfillsMidSession(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "v15.db")
+	s, err := NewSQLiteStore(path, quietLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.db.Exec(`DELETE FROM schema_migrations WHERE version = 16`)
+	s.db.Exec(`ALTER TABLE tool_registry DROP COLUMN mid_session`)
+	for _, stmt := range []string{
+		`INSERT INTO tool_registry (session_id, tool_name, first_seen, last_seen) VALUES ('s1', 'read_file', 't1', 't1')`,
+		`INSERT INTO tool_registry (session_id, tool_name, first_seen, last_seen) VALUES ('s1', 'write_file', 't1', 't1')`,
+		`INSERT INTO tool_registry (session_id, tool_name, first_seen, last_seen) VALUES ('s1', 'exfiltrate', 't2', 't2')`,
+		`INSERT INTO tool_registry (session_id, tool_name, first_seen, last_seen) VALUES ('s2', 'exfiltrate', 't3', 't3')`,
+	} {
+		if _, err := s.db.Exec(stmt); err != nil {
+			t.Fatalf("seed: %v", err)
+		}
+	}
+	s.Close()
+
+	s, err = NewSQLiteStore(path, quietLogger)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer s.Close()
+	timeline, err := s.ToolTimeline(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []bool
+	for _, e := range timeline {
+		got = append(got, e.AddedMidSession)
+	}
+	if want := []bool{false, false, true, false}; !slices.Equal(got, want) {
+		t.Errorf("backfilled mid-session flags = %v, want %v", got, want)
+	}
+}
+
+func TestMigrate_PartiallyMigratedDatabase(t *testing.T) {
+	// A database from before schema_migrations that ran some of the old
+	// ad-hoc migrations already has part of what the numbered ones add
+	path := filepath.Join(t.TempDir(), "partial.db")
+	s, err := NewSQLiteStore(path, quietLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.db.Exec(`DROP TABLE schema_migrations`)
+	s.db.Exec(`ALTER TABLE messages DROP COLUMN latency_ms`)
+	s.Close()
+
+	s, err = NewSQLiteStore(path, quietLogger)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer s.Close()
+	wantAllVersions(t, appliedVersions(t, s.db))
+	if !columnNames(t, s.db, "messages")["latency_ms"] {
+		t.Error("latency_ms not restored")
+	}
+}
+
+func TestMigrate_FailedMigrationRollsBack(t *testing.T) {
+	s := newTestStore(t)
+	next := SchemaVersion() + 1
+	list := append(migrations[:len(migrations):len(migrations)],
+		migration{next, "add column", addColumns("messages", "extra TEXT")},
+		migration{next + 1, "broken", execAll(
+			execSQL("CREATE TABLE half_done (id INTEGER)"),
+			func(*sql.Tx) error { return errors.New("boom") },
+		)},
+	)
+
+	err := migrate(s.db, list, false)
+	if err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Fatalf("err = %v, want the broken migration's error", err)
+	}
+	got := appliedVersions(t, s.db)
+	if got[len(got)-1] != next {
+		t.Errorf("latest applied = %d, want %d", got[len(got)-1], next)
+	}
+	if !columnNames(t, s.db, "messages")["extra"] {
+		t.Error("migration before the failure was not kept")
+	}
+	if len(columnNames(t, s.db, "half_done")) != 0 {
+		t.Error("failed migration was not rolled back")
+	}
+}
@@ -1,24 +1,36 @@
 package store
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // LogEntry represents a logged MCP message.
 type LogEntry struct {
-	ID           int64     `json:"id"`
-	Timestamp    time.Time `json:"timestamp"`
-	SessionID    string    `json:"session_id"`
-	Direction    string    `json:"direction"`
-	Kind         string    `json:"kind"`
-	Method       string    `json:"method"`
-	MsgID        string    `json:"msg_id"`
-	Payload      string    `json:"payload"`
-	SizeBytes    int       `json:"size_bytes"`
-	Blocked      bool      `json:"blocked"`
-	Audit        bool      `json:"audit"`
-	ScrubCount   int       `json:"scrub_count"`
-	MatchedRules []string  `json:"matched_rules,omitempty"`
-	ToolName     string    `json:"tool_name,omitempty"`
-	PolicyAction string    `json:"policy_action,omitempty"`
+	ID            int64           `json:"id"`
+	Seq           int64           `json:"seq"` // interception order; Query sorts by it
+	Timestamp     time.Time       `json:"timestamp"`
+	SessionID     string          `json:"session_id"`
+	Direction     string          `json:"direction"`
+	Kind          string          `json:"kind"`
+	Method        string          `json:"method"`
+	MsgID         string          `json:"msg_id"`
+	Payload       string          `json:"payload"`
+	SizeBytes     int             `json:"size_bytes"`
+	Blocked       bool            `json:"blocked"`
+	Audit         bool            `json:"audit"`
+	ScrubCount    int             `json:"scrub_count"`
+	MatchedRules  []string        `json:"matched_rules,omitempty"`
+	ToolName      string          `json:"tool_name,omitempty"`
+	PolicyAction  string          `json:"policy_action,omitempty"`
+	TokenEstimate int             `json:"token_estimate"`         // approximate tokens in the forwarded payload
+	Unrecognized  bool            `json:"unrecognized,omitempty"` // method outside the known set
+	LatencyMs     float64         `json:"latency_ms,omitempty"`   // for responses: time since the request was forwarded
+	RequestTool   string          `json:"request_tool,omitempty"` // for responses: the tool their tools/call request called
+	Tags          []string        `json:"tags,omitempty"`         // triage labels added from the dashboard
+	Note          string          `json:"note,omitempty"`         // triage note added from the dashboard
+	Arguments     json.RawMessage `json:"arguments,omitempty"`    // tools/call arguments as stored, for filtering by value
+	ToolsPruned   int             `json:"tools_pruned,omitempty"` // tools removed from a tools/list response by pruning
 }
 
 // Session represents an MCP proxy session.
@@ -28,6 +40,7 @@ type Session struct {
 	EndedAt   *time.Time `json:"ended_at,omitempty"`
 	Command   string     `json:"command"`
 	Args      []string   `json:"args"`
+	Client    string     `json:"client,omitempty"` // host application, from -client-id or the initialize request
 }
 
 // QueryFilter specifies filters for querying messages.
@@ -36,9 +49,15 @@ type QueryFilter struct {
 	Direction string
 	Method    string
 	Kind      string
-	Since     *time.Time
-	Limit     int
-	Offset    int
+	MsgID     string
+	Tag       string // messages annotated with this tag
+	// Args matches tools/call arguments: keys are argument names (dotted
+	// for nested objects), values must match exactly, or as a prefix when
+	// they end in "*".
+	Args   map[string]string
+	Since  *time.Time
+	Limit  int
+	Offset int
 }
 
 // Stats holds aggregate statistics.
@@ -53,7 +72,9 @@ type Stats struct {
 	TotalBytes        int64          `json:"total_bytes"`
 	ScrubCount        int            `json:"scrub_count"`
 	AuditCount        int            `json:"audit_count"`
+	TotalTokens       int64          `json:"total_tokens"`
 	ApprovalPending   int            `json:"approval_pending"`
+	LiveDropped       uint64         `json:"live_dropped"`
 }
 
 // ApprovalRecord represents an approval decision for audit trail.
@@ -68,6 +89,9 @@ type ApprovalRecord struct {
 	Payload   string     `json:"payload"`
 	Decision  string     `json:"decision"`
 	DecidedAt *time.Time `json:"decided_at,omitempty"`
+	// Reason records how the decision was reached: manual, timeout,
+	// withdrawn or host_cancelled. Empty for records from older versions.
+	Reason string `json:"reason,omitempty"`
 }
 
 // ApprovalEvent is published when a new approval is requested or resolved.
@@ -81,16 +105,80 @@ type ToolRecord struct {
 	SessionID   string `json:"session_id"`
 	ToolName    string `json:"tool_name"`
 	Description string `json:"description"`
+	// InputProperties names the arguments declared in the tool's
+	// inputSchema; nil if it declares no schema.
+	InputProperties []string `json:"input_properties,omitempty"`
+	// MidSession marks a tool listed after the session's first complete
+	// tools/list, which may have spanned several pages. It only applies
+	// when the tool is first registered.
+	MidSession bool `json:"mid_session,omitempty"`
+}
+
+// ToolArgUsage compares the arguments a tool declares with those sent in
+// its tools/call requests, to catch schema drift.
+type ToolArgUsage struct {
+	ToolName string `json:"tool_name"`
+	// Declared lists the inputSchema properties from the tool's latest
+	// registration; nil if no schema was recorded.
+	Declared   []string   `json:"declared"`
+	DeclaredAt *time.Time `json:"declared_at,omitempty"` // when that registration was last listed
+	// Calls counts the logged calls whose arguments were stored.
+	Calls int           `json:"calls"`
+	Args  []ArgKeyUsage `json:"args"`
+	// Undocumented lists keys sent but not declared, and Unused keys
+	// declared but never sent. Both are empty without a schema.
+	Undocumented []string `json:"undocumented"`
+	Unused       []string `json:"unused"`
+}
+
+// ArgKeyUsage counts the calls that sent one top-level argument key.
+type ArgKeyUsage struct {
+	Key      string `json:"key"`
+	Calls    int    `json:"calls"`
+	Declared bool   `json:"declared"`
+}
+
+// compare fills in Undocumented, Unused and each key's Declared flag.
+func (u *ToolArgUsage) compare() {
+	u.Undocumented, u.Unused = []string{}, []string{}
+	if u.Declared == nil {
+		return
+	}
+	declared := make(map[string]bool, len(u.Declared))
+	for _, k := range u.Declared {
+		declared[k] = true
+	}
+	sent := make(map[string]bool, len(u.Args))
+	for i := range u.Args {
+		a := &u.Args[i]
+		sent[a.Key] = true
+		a.Declared = declared[a.Key]
+		if !a.Declared {
+			u.Undocumented = append(u.Undocumented, a.Key)
+		}
+	}
+	for _, k := range u.Declared {
+		if !sent[k] {
+			u.Unused = append(u.Unused, k)
+		}
+	}
 }
 
 // ToolAnalytics represents computed analytics for a single tool.
 type ToolAnalytics struct {
-	ToolName    string `json:"tool_name"`
-	Description string `json:"description"`
-	CallCount   int    `json:"call_count"`
-	SessionsSeen int   `json:"sessions_seen"`
-	LastUsed    string `json:"last_used,omitempty"`
-	IsPruned    bool   `json:"is_pruned"`
+	ToolName     string `json:"tool_name"`
+	Description  string `json:"description"`
+	CallCount    int    `json:"call_count"`
+	SessionsSeen int    `json:"sessions_seen"`
+	LastUsed     string `json:"last_used,omitempty"`
+	IsPruned     bool   `json:"is_pruned"`
+	// TokenEstimate sums the estimated tokens of the tool's calls and
+	// their responses.
+	TokenEstimate int64 `json:"token_estimate"`
+	// AvgLatencyMs and P95LatencyMs summarize how long the tool's calls
+	// took to be answered; zero when no response latency was recorded.
+	AvgLatencyMs float64 `json:"avg_latency_ms,omitempty"`
+	P95LatencyMs float64 `json:"p95_latency_ms,omitempty"`
 }
 
 // ToolAnalyticsSummary is the full analytics response.
@@ -100,3 +188,60 @@ type ToolAnalyticsSummary struct {
 	TotalPruned    int             `json:"total_pruned"`
 	Tools          []ToolAnalytics `json:"tools"`
 }
+
+// ActionCounts is a per-tool or per-method breakdown of policy outcomes.
+// Each request is counted once, so the outcomes add up to Total: Denied
+// if it was blocked, else RequireApproval if it was approved, else
+// Audited if a rule audited it, else Allowed.
+type ActionCounts struct {
+	Name            string `json:"name"`
+	Total           int    `json:"total"`
+	Allowed         int    `json:"allowed"`
+	Denied          int    `json:"denied"`
+	RequireApproval int    `json:"require_approval"`
+	Audited         int    `json:"audited"`
+}
+
+// BlockedStat counts blocked messages for one tool and the rule that
+// blocked them (the first matched rule).
+type BlockedStat struct {
+	ToolName string `json:"tool_name"`
+	Rule     string `json:"rule"`
+	Count    int    `json:"count"`
+}
+
+// MethodStat counts messages using one method in one direction.
+type MethodStat struct {
+	Method    string    `json:"method"`
+	Direction string    `json:"direction"`
+	Count     int       `json:"count"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// ToolConflict is a tool name that a server listed more than once.
+type ToolConflict struct {
+	SessionID string    `json:"session_id"`
+	ToolName  string    `json:"tool_name"`
+	Copies    int       `json:"copies"` // times the name appeared in one tools/list
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// ToolTimelineEntry records when a tool first and last appeared in a
+// session's tools/list responses.
+// AddedMidSession is set for tools missing from the session's first
+// complete tools/list, over all its pages, i.e. added dynamically later.
+type ToolTimelineEntry struct {
+	SessionID       string    `json:"session_id"`
+	ToolName        string    `json:"tool_name"`
+	Description     string    `json:"description"`
+	FirstSeen       time.Time `json:"first_seen"`
+	LastSeen        time.Time `json:"last_seen"`
+	AddedMidSession bool      `json:"added_mid_session"`
+}
+
+// SessionReport summarizes policy outcomes for host→server requests in a session.
+type SessionReport struct {
+	SessionID string         `json:"session_id"`
+	Tools     []ActionCounts `json:"tools"`
+	Methods   []ActionCounts `json:"methods"`
+}
@@ -0,0 +1,497 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrReadOnly is returned by the write methods of a MultiStore.
+var ErrReadOnly = errors.New("store is read-only")
+
+// MultiStore combines several databases, such as per-session or rotated
+// files, into one read-only view. Counts and analytics are merged across
+// them; a session is assumed to live in a single database.
+//
+// Message IDs are only unique within a database, so MultiStore rewrites
+// them: the message with ID n in the i-th database has ID n*len+i in the
+// combined view, where len is the number of databases.
+type MultiStore struct {
+	stores []*SQLiteStore
+}
+
+// OpenMulti opens the databases at paths read-only. Where they disagree,
+// such as on a tool's description, earlier paths win, so list the newest
+// first.
+func OpenMulti(paths []string, logger *slog.Logger) (*MultiStore, error) {
+	m := &MultiStore{}
+	for _, path := range paths {
+		s, err := openReadOnly(path, logger)
+		if err != nil {
+			m.Close()
+			return nil, err
+		}
+		m.stores = append(m.stores, s)
+	}
+	return m, nil
+}
+
+// globalID maps the ID of a message in the i-th database to its ID in
+// the combined view.
+func (m *MultiStore) globalID(i int, id int64) int64 {
+	return id*int64(len(m.stores)) + int64(i)
+}
+
+// LogMessage is not supported; the store is read-only.
+func (m *MultiStore) LogMessage(context.Context, *LogEntry) error { return ErrReadOnly }
+
+// BufferFill is always 0, as nothing is written.
+func (m *MultiStore) BufferFill() float64 { return 0 }
+
+// Query merges the matching messages of every database in sequence order.
+func (m *MultiStore) Query(ctx context.Context, f QueryFilter) ([]LogEntry, error) {
+	limit := f.Limit
+	if limit <= 0 {
+		limit = 200
+	}
+	// Each database supplies enough rows to fill the page on its own
+	each := f
+	each.Limit, each.Offset = f.Offset+limit, 0
+
+	var all []LogEntry
+	for i, s := range m.stores {
+		entries, err := s.Query(ctx, each)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			e.ID = m.globalID(i, e.ID)
+			all = append(all, e)
+		}
+	}
+	slices.SortStableFunc(all, func(a, b LogEntry) int {
+		return cmp.Or(cmp.Compare(b.Seq, a.Seq), b.Timestamp.Compare(a.Timestamp), cmp.Compare(b.ID, a.ID))
+	})
+
+	if f.Offset >= len(all) {
+		return nil, nil
+	}
+	all = all[f.Offset:]
+	return all[:min(limit, len(all))], nil
+}
+
+// GetMessage retrieves a message by its combined-view ID.
+func (m *MultiStore) GetMessage(ctx context.Context, id int64) (*LogEntry, error) {
+	n := int64(len(m.stores))
+	if n == 0 || id < 0 {
+		return nil, ErrNotFound
+	}
+	i := int(id % n)
+	e, err := m.stores[i].GetMessage(ctx, id/n)
+	if err != nil {
+		return nil, err
+	}
+	e.ID = id
+	return e, nil
+}
+
+// AnnotateMessage is not supported; the store is read-only.
+func (m *MultiStore) AnnotateMessage(context.Context, int64, string, []string) error {
+	return ErrReadOnly
+}
+
+// Stats sums the statistics of every database. MethodCounts keeps the 20
+// most used methods, as a single database does.
+func (m *MultiStore) Stats(ctx context.Context, sessionID string) (*Stats, error) {
+	total := &Stats{MethodCounts: make(map[string]int)}
+	for _, s := range m.stores {
+		st, err := s.Stats(ctx, sessionID)
+		if err != nil {
+			return nil, err
+		}
+		total.TotalMessages += st.TotalMessages
+		total.RequestCount += st.RequestCount
+		total.ResponseCount += st.ResponseCount
+		total.NotificationCount += st.NotificationCount
+		total.ErrorCount += st.ErrorCount
+		total.BlockedCount += st.BlockedCount
+		total.TotalBytes += st.TotalBytes
+		total.ScrubCount += st.ScrubCount
+		total.AuditCount += st.AuditCount
+		total.TotalTokens += st.TotalTokens
+		total.ApprovalPending += st.ApprovalPending
+		total.LiveDropped += st.LiveDropped
+		for method, count := range st.MethodCounts {
+			total.MethodCounts[method] += count
+		}
+	}
+
+	if len(total.MethodCounts) > 20 {
+		methods := make([]string, 0, len(total.MethodCounts))
+		for method := range total.MethodCounts {
+			methods = append(methods, method)
+		}
+		slices.SortFunc(methods, func(a, b string) int {
+			return cmp.Or(cmp.Compare(total.MethodCounts[b], total.MethodCounts[a]), strings.Compare(a, b))
+		})
+		for _, method := range methods[20:] {
+			delete(total.MethodCounts, method)
+		}
+	}
+	return total, nil
+}
+
+// CreateSession is not supported; the store is read-only.
+func (m *MultiStore) CreateSession(context.Context, *Session) error { return ErrReadOnly }
+
+// SetSessionClient is not supported; the store is read-only.
+func (m *MultiStore) SetSessionClient(context.Context, string, string) error { return ErrReadOnly }
+
+// EndSession is not supported; the store is read-only.
+func (m *MultiStore) EndSession(context.Context, string) error { return ErrReadOnly }
+
+// GetSession returns the session from the first database that has it.
+func (m *MultiStore) GetSession(ctx context.Context, sessionID string) (*Session, error) {
+	for _, s := range m.stores {
+		session, err := s.GetSession(ctx, sessionID)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		return session, err
+	}
+	return nil, ErrNotFound
+}
+
+// LogApproval is not supported; the store is read-only.
+func (m *MultiStore) LogApproval(context.Context, *ApprovalRecord) error { return ErrReadOnly }
+
+// GetApprovals merges approval records, newest first, keeping the 100
+// most recent as a single database does.
+func (m *MultiStore) GetApprovals(ctx context.Context, sessionID string) ([]ApprovalRecord, error) {
+	var all []ApprovalRecord
+	for _, s := range m.stores {
+		records, err := s.GetApprovals(ctx, sessionID)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, records...)
+	}
+	slices.SortStableFunc(all, func(a, b ApprovalRecord) int { return b.Timestamp.Compare(a.Timestamp) })
+	return all[:min(100, len(all))], nil
+}
+
+// RegisterTools is not supported; the store is read-only.
+func (m *MultiStore) RegisterTools(context.Context, string, []ToolRecord) error { return ErrReadOnly }
+
+// ToolTimeline merges the timelines of every database in order of first
+// appearance.
+func (m *MultiStore) ToolTimeline(ctx context.Context, sessionID string) ([]ToolTimelineEntry, error) {
+	var all []ToolTimelineEntry
+	for _, s := range m.stores {
+		entries, err := s.ToolTimeline(ctx, sessionID)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, entries...)
+	}
+	slices.SortStableFunc(all, func(a, b ToolTimelineEntry) int { return a.FirstSeen.Compare(b.FirstSeen) })
+	return all, nil
+}
+
+// GetToolAnalytics merges per-tool analytics. Latencies are recomputed
+// from every database's recorded responses.
+func (m *MultiStore) GetToolAnalytics(ctx context.Context, sessionID string) (*ToolAnalyticsSummary, error) {
+	byTool := make(map[string]*ToolAnalytics)
+	var order []string
+	latencies := make(map[string][]float64)
+	for _, s := range m.stores {
+		summary, err := s.GetToolAnalytics(ctx, sessionID)
+		if err != nil {
+			return nil, err
+		}
+		for _, ta := range summary.Tools {
+			merged, ok := byTool[ta.ToolName]
+			if !ok {
+				copied := ta
+				byTool[ta.ToolName] = &copied
+				order = append(order, ta.ToolName)
+				continue
+			}
+			merged.CallCount += ta.CallCount
+			merged.SessionsSeen += ta.SessionsSeen
+			merged.TokenEstimate += ta.TokenEstimate
+			merged.LastUsed = max(merged.LastUsed, ta.LastUsed)
+			merged.IsPruned = merged.IsPruned || ta.IsPruned
+		}
+
+		lat, err := s.toolLatencies(sessionID)
+		if err != nil {
+			return nil, err
+		}
+		for tool, ms := range lat {
+			latencies[tool] = append(latencies[tool], ms...)
+		}
+	}
+
+	summary := &ToolAnalyticsSummary{}
+	for _, name := range order {
+		ta := byTool[name]
+		ms := latencies[name]
+		slices.Sort(ms)
+		ta.AvgLatencyMs, ta.P95LatencyMs = latencyStats(ms)
+		summary.Tools = append(summary.Tools, *ta)
+		summary.TotalAvailable++
+		if ta.CallCount > 0 {
+			summary.TotalUsed++
+		}
+		if ta.IsPruned {
+			summary.TotalPruned++
+		}
+	}
+	slices.SortStableFunc(summary.Tools, func(a, b ToolAnalytics) int {
+		return cmp.Or(cmp.Compare(b.CallCount, a.CallCount), strings.Compare(a.ToolName, b.ToolName))
+	})
+	return summary, nil
+}
+
+// GetToolUsageCounts sums per-tool call counts. With lastNSessions, the
+// most recent sessions are chosen across all databases.
+func (m *MultiStore) GetToolUsageCounts(ctx context.Context, lastNSessions int) (map[string]int, error) {
+	if lastNSessions <= 0 {
+		counts := make(map[string]int)
+		for _, s := range m.stores {
+			c, err := s.GetToolUsageCounts(ctx, 0)
+			if err != nil {
+				return nil, err
+			}
+			for tool, n := range c {
+				counts[tool] += n
+			}
+		}
+		return counts, nil
+	}
+
+	type started struct {
+		store int
+		id    string
+		at    time.Time
+	}
+	var sessions []started
+	for i, s := range m.stores {
+		rows, err := s.db.QueryContext(ctx, `SELECT id, started_at FROM sessions`)
+		if err != nil {
+			return nil, fmt.Errorf("query sessions: %w", err)
+		}
+		for rows.Next() {
+			var id, at string
+			if err := rows.Scan(&id, &at); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("scan session: %w", err)
+			}
+			t, _ := time.Parse(time.RFC3339Nano, at)
+			sessions = append(sessions, started{store: i, id: id, at: t})
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	slices.SortStableFunc(sessions, func(a, b started) int { return b.at.Compare(a.at) })
+	sessions = sessions[:min(lastNSessions, len(sessions))]
+
+	counts := make(map[string]int)
+	for _, sess := range sessions {
+		rows, err := m.stores[sess.store].db.QueryContext(ctx, `
+			SELECT tool_name, COUNT(*) FROM messages
+			WHERE tool_name IS NOT NULL AND tool_name != '' AND session_id = ?
+			GROUP BY tool_name
+		`, sess.id)
+		if err != nil {
+			return nil, fmt.Errorf("query tool usage: %w", err)
+		}
+		for rows.Next() {
+			var name string
+			var n int
+			if err := rows.Scan(&name, &n); err != nil {
+				continue
+			}
+			counts[name] += n
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return counts, nil
+}
+
+// SessionReport merges the session's outcome counts from every database.
+func (m *MultiStore) SessionReport(ctx context.Context, sessionID string) (*SessionReport, error) {
+	var tools, methods []ActionCounts
+	for _, s := range m.stores {
+		r, err := s.SessionReport(ctx, sessionID)
+		if err != nil {
+			return nil, err
+		}
+		tools = append(tools, r.Tools...)
+		methods = append(methods, r.Methods...)
+	}
+	return &SessionReport{
+		SessionID: sessionID,
+		Tools:     mergeActionCounts(tools),
+		Methods:   mergeActionCounts(methods),
+	}, nil
+}
+
+func mergeActionCounts(counts []ActionCounts) []ActionCounts {
+	var out []ActionCounts
+	index := make(map[string]int)
+	for _, c := range counts {
+		i, ok := index[c.Name]
+		if !ok {
+			index[c.Name] = len(out)
+			out = append(out, c)
+			continue
+		}
+		out[i].Total += c.Total
+		out[i].Allowed += c.Allowed
+		out[i].Denied += c.Denied
+		out[i].RequireApproval += c.RequireApproval
+		out[i].Audited += c.Audited
+	}
+	slices.SortStableFunc(out, func(a, b ActionCounts) int {
+		return cmp.Or(cmp.Compare(b.Total, a.Total), strings.Compare(a.Name, b.Name))
+	})
+	return out
+}
+
+// BlockedLeaderboard sums blocked counts by tool and rule.
+func (m *MultiStore) BlockedLeaderboard(ctx context.Context, sessionID string) ([]BlockedStat, error) {
+	type key struct{ tool, rule string }
+	var out []BlockedStat
+	index := make(map[key]int)
+	for _, s := range m.stores {
+		stats, err := s.BlockedLeaderboard(ctx, sessionID)
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range stats {
+			k := key{b.ToolName, b.Rule}
+			if i, ok := index[k]; ok {
+				out[i].Count += b.Count
+				continue
+			}
+			index[k] = len(out)
+			out = append(out, b)
+		}
+	}
+	slices.SortStableFunc(out, func(a, b BlockedStat) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), strings.Compare(a.ToolName, b.ToolName), strings.Compare(a.Rule, b.Rule))
+	})
+	return out, nil
+}
+
+// UnrecognizedMethods sums flagged method counts by method and direction.
+func (m *MultiStore) UnrecognizedMethods(ctx context.Context, sessionID string) ([]MethodStat, error) {
+	type key struct{ method, direction string }
+	var out []MethodStat
+	index := make(map[key]int)
+	for _, s := range m.stores {
+		stats, err := s.UnrecognizedMethods(ctx, sessionID)
+		if err != nil {
+			return nil, err
+		}
+		for _, ms := range stats {
+			k := key{ms.Method, ms.Direction}
+			if i, ok := index[k]; ok {
+				out[i].Count += ms.Count
+				if ms.LastSeen.After(out[i].LastSeen) {
+					out[i].LastSeen = ms.LastSeen
+				}
+				continue
+			}
+			index[k] = len(out)
+			out = append(out, ms)
+		}
+	}
+	slices.SortStableFunc(out, func(a, b MethodStat) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), strings.Compare(a.Method, b.Method))
+	})
+	return out, nil
+}
+
+// GetToolArgUsage sums argument key counts across databases. The
+// declared arguments come from the latest registration in any of them.
+func (m *MultiStore) GetToolArgUsage(ctx context.Context, sessionID, tool string) (*ToolArgUsage, error) {
+	out := &ToolArgUsage{ToolName: tool, Args: []ArgKeyUsage{}}
+	index := make(map[string]int)
+	for _, s := range m.stores {
+		usage, err := s.GetToolArgUsage(ctx, sessionID, tool)
+		if err != nil {
+			return nil, err
+		}
+		if usage.Declared != nil && (out.Declared == nil || declaredLater(usage.DeclaredAt, out.DeclaredAt)) {
+			out.Declared, out.DeclaredAt = usage.Declared, usage.DeclaredAt
+		}
+		out.Calls += usage.Calls
+		for _, a := range usage.Args {
+			if i, ok := index[a.Key]; ok {
+				out.Args[i].Calls += a.Calls
+				continue
+			}
+			index[a.Key] = len(out.Args)
+			out.Args = append(out.Args, a)
+		}
+	}
+	slices.SortFunc(out.Args, func(a, b ArgKeyUsage) int { return strings.Compare(a.Key, b.Key) })
+	out.compare()
+	return out, nil
+}
+
+// declaredLater reports whether a was registered after b. An unknown
+// time counts as earliest.
+func declaredLater(a, b *time.Time) bool {
+	return a != nil && (b == nil || a.After(*b))
+}
+
+// RecordToolConflicts is not supported; the store is read-only.
+func (m *MultiStore) RecordToolConflicts(context.Context, string, []ToolConflict) error {
+	return ErrReadOnly
+}
+
+// ToolConflicts merges recorded conflicts, most recent first.
+func (m *MultiStore) ToolConflicts(ctx context.Context, sessionID string) ([]ToolConflict, error) {
+	var all []ToolConflict
+	for _, s := range m.stores {
+		conflicts, err := s.ToolConflicts(ctx, sessionID)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, conflicts...)
+	}
+	slices.SortStableFunc(all, func(a, b ToolConflict) int {
+		return cmp.Or(b.LastSeen.Compare(a.LastSeen), strings.Compare(a.ToolName, b.ToolName))
+	})
+	return all, nil
+}
+
+// ClearMessages is not supported; the store is read-only.
+func (m *MultiStore) ClearMessages(context.Context) (int64, error) { return 0, ErrReadOnly }
+
+// ClearToolRegistry is not supported; the store is read-only.
+func (m *MultiStore) ClearToolRegistry(context.Context) (int64, error) { return 0, ErrReadOnly }
+
+// Close closes every database.
+func (m *MultiStore) Close() error {
+	var errs []error
+	for _, s := range m.stores {
+		errs = append(errs, s.Close())
+	}
+	m.stores = nil
+	return errors.Join(errs...)
+}
@@ -0,0 +1,161 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// populate writes a session with the given tools/call requests to a new
+// database at path. Every call to "blocked_tool" is blocked.
+func populate(t *testing.T, path, sessionID string, started time.Time, tools ...string) {
+	t.Helper()
+	s, err := NewSQLiteStore(path, quietLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	s.CreateSession(ctx, &Session{ID: sessionID, StartedAt: started, Command: "test"})
+	s.RegisterTools(ctx, sessionID, []ToolRecord{{ToolName: "read_file"}, {ToolName: "blocked_tool"}})
+	for i, tool := range tools {
+		s.LogMessage(ctx, &LogEntry{
+			Timestamp: started.Add(time.Duration(i) * time.Second),
+			Seq:       started.Add(time.Duration(i) * time.Second).UnixNano(),
+			SessionID: sessionID, Direction: "host_to_server", Kind: "request",
+			Method: "tools/call", ToolName: tool, Payload: "{}", SizeBytes: 10,
+			Blocked: tool == "blocked_tool", MatchedRules: []string{"no-" + tool},
+		})
+	}
+	s.Close()
+}
+
+func TestMultiStore_MergesStats(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	a, b := filepath.Join(dir, "a.db"), filepath.Join(dir, "b.db")
+	populate(t, a, "sess-a", base, "read_file", "read_file", "blocked_tool")
+	populate(t, b, "sess-b", base.Add(time.Hour), "read_file", "blocked_tool")
+
+	m, err := OpenMulti([]string{b, a}, quietLogger)
+	if err != nil {
+		t.Fatalf("OpenMulti: %v", err)
+	}
+	defer m.Close()
+	ctx := context.Background()
+
+	stats, err := m.Stats(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.TotalMessages != 5 || stats.RequestCount != 5 || stats.BlockedCount != 2 || stats.TotalBytes != 50 {
+		t.Errorf("stats = %+v, want 5 requests, 2 blocked, 50 bytes", stats)
+	}
+	if stats.MethodCounts["tools/call"] != 5 {
+		t.Errorf("method counts = %v", stats.MethodCounts)
+	}
+	if only, _ := m.Stats(ctx, "sess-b"); only.TotalMessages != 2 {
+		t.Errorf("sess-b stats = %+v, want 2 messages", only)
+	}
+
+	analytics, err := m.GetToolAnalytics(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(analytics.Tools) != 2 || analytics.Tools[0].ToolName != "read_file" || analytics.Tools[0].CallCount != 3 ||
+		analytics.Tools[0].SessionsSeen != 2 || analytics.TotalAvailable != 2 {
+		t.Errorf("analytics = %+v, want read_file with 3 calls in 2 sessions first", analytics)
+	}
+
+	board, err := m.BlockedLeaderboard(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(board) != 1 || board[0].Count != 2 || board[0].Rule != "no-blocked_tool" {
+		t.Errorf("leaderboard = %+v, want blocked_tool twice", board)
+	}
+
+	if counts, _ := m.GetToolUsageCounts(ctx, 1); counts["read_file"] != 1 || counts["blocked_tool"] != 1 {
+		t.Errorf("usage in the latest session = %v, want sess-b's calls", counts)
+	}
+}
+
+func TestMultiStore_MessageIDs(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	a, b := filepath.Join(dir, "a.db"), filepath.Join(dir, "b.db")
+	populate(t, a, "sess-a", base, "read_file", "blocked_tool")
+	populate(t, b, "sess-b", base.Add(time.Hour), "read_file")
+
+	m, err := OpenMulti([]string{b, a}, quietLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	ctx := context.Background()
+
+	msgs, err := m.Query(ctx, QueryFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 3 || msgs[0].SessionID != "sess-b" {
+		t.Fatalf("query = %+v, want 3 messages, newest from sess-b", msgs)
+	}
+	seen := make(map[int64]bool)
+	for _, e := range msgs {
+		if seen[e.ID] {
+			t.Errorf("ID %d returned twice", e.ID)
+		}
+		seen[e.ID] = true
+		got, err := m.GetMessage(ctx, e.ID)
+		if err != nil || got.SessionID != e.SessionID || got.ToolName != e.ToolName {
+			t.Errorf("GetMessage(%d) = %+v, %v, want %+v", e.ID, got, err, e)
+		}
+	}
+
+	page, _ := m.Query(ctx, QueryFilter{Limit: 1, Offset: 1})
+	if len(page) != 1 || page[0].ID != msgs[1].ID {
+		t.Errorf("second page = %+v, want %+v", page, msgs[1])
+	}
+
+	if err := m.LogMessage(ctx, &LogEntry{}); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("LogMessage err = %v, want ErrReadOnly", err)
+	}
+}
+
+func TestMultiStore_ToolArgUsageLatestDeclaration(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	register := func(path, sessionID string, at time.Time, props ...string) {
+		t.Helper()
+		s, err := NewSQLiteStore(path, quietLogger)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.now = func() time.Time { return at }
+		s.RegisterTools(context.Background(), sessionID, []ToolRecord{{ToolName: "read_file", InputProperties: props}})
+		s.Close()
+	}
+	older, newer := filepath.Join(dir, "older.db"), filepath.Join(dir, "newer.db")
+	register(older, "sess-a", base, "path")
+	register(newer, "sess-b", base.Add(time.Hour), "path", "limit")
+
+	// The older database comes first
+	m, err := OpenMulti([]string{older, newer}, quietLogger)
+	if err != nil {
+		t.Fatalf("OpenMulti: %v", err)
+	}
+	defer m.Close()
+	usage, err := m.GetToolArgUsage(context.Background(), "", "read_file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(usage.Declared, []string{"path", "limit"}) {
+		t.Errorf("declared = %v, want the newer registration", usage.Declared)
+	}
+	if usage, _ := m.GetToolArgUsage(context.Background(), "sess-a", "read_file"); !slices.Equal(usage.Declared, []string{"path"}) {
+		t.Errorf("sess-a declared = %v, want its own registration", usage.Declared)
+	}
+}
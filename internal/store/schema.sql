@@ -13,19 +13,32 @@ CREATE TABLE IF NOT EXISTS messages (
     scrub_count   INTEGER NOT NULL DEFAULT 0,
     matched_rules TEXT,
     tool_name     TEXT,
-    policy_action TEXT
+    policy_action TEXT,
+    token_estimate INTEGER NOT NULL DEFAULT 0,
+    seq           INTEGER NOT NULL DEFAULT 0,
+    unrecognized  INTEGER NOT NULL DEFAULT 0,
+    latency_ms    REAL,
+    tags          TEXT,
+    note          TEXT,
+    arguments     TEXT,
+    tools_pruned  INTEGER NOT NULL DEFAULT 0,
+    request_tool  TEXT
 );
 
 CREATE INDEX IF NOT EXISTS idx_messages_session   ON messages(session_id);
 CREATE INDEX IF NOT EXISTS idx_messages_timestamp ON messages(timestamp);
 CREATE INDEX IF NOT EXISTS idx_messages_method    ON messages(method);
+CREATE INDEX IF NOT EXISTS idx_messages_seq       ON messages(seq);
+CREATE INDEX IF NOT EXISTS idx_messages_arguments ON messages(tool_name) WHERE arguments IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_messages_request_tool ON messages(request_tool) WHERE request_tool IS NOT NULL;
 
 CREATE TABLE IF NOT EXISTS sessions (
     id         TEXT PRIMARY KEY,
     started_at TEXT NOT NULL,
     ended_at   TEXT,
     command    TEXT NOT NULL,
-    args       TEXT
+    args       TEXT,
+    client     TEXT NOT NULL DEFAULT ''
 );
 
 CREATE TABLE IF NOT EXISTS approvals (
@@ -38,7 +51,8 @@ CREATE TABLE IF NOT EXISTS approvals (
     rule_name  TEXT NOT NULL,
     payload    TEXT NOT NULL,
     decision   TEXT NOT NULL,
-    decided_at TEXT
+    decided_at TEXT,
+    reason     TEXT NOT NULL DEFAULT ''
 );
 CREATE INDEX IF NOT EXISTS idx_approvals_session ON approvals(session_id);
 
@@ -48,7 +62,18 @@ CREATE TABLE IF NOT EXISTS tool_registry (
     tool_name   TEXT    NOT NULL,
     description TEXT    NOT NULL DEFAULT '',
     first_seen  TEXT    NOT NULL,
+    last_seen   TEXT    NOT NULL DEFAULT '',
+    input_properties TEXT,
+    mid_session INTEGER NOT NULL DEFAULT 0,
     UNIQUE(session_id, tool_name)
 );
 CREATE INDEX IF NOT EXISTS idx_tool_registry_session ON tool_registry(session_id);
 CREATE INDEX IF NOT EXISTS idx_tool_registry_tool    ON tool_registry(tool_name);
+
+CREATE TABLE IF NOT EXISTS tool_conflicts (
+    session_id  TEXT    NOT NULL,
+    tool_name   TEXT    NOT NULL,
+    copies      INTEGER NOT NULL,
+    last_seen   TEXT    NOT NULL,
+    UNIQUE(session_id, tool_name)
+);
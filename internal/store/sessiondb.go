@@ -0,0 +1,151 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sessionDBExt is the file extension of per-session databases.
+const sessionDBExt = ".db"
+
+// ErrUnknownSessionDB is returned when no database exists for a session.
+var ErrUnknownSessionDB = errors.New("no database for session")
+
+// SessionDBPath returns the path of a session's own database in dir.
+func SessionDBPath(dir, sessionID string) string {
+	return filepath.Join(dir, sessionID+sessionDBExt)
+}
+
+// SessionDB describes one per-session database file.
+type SessionDB struct {
+	SessionID string    `json:"session_id"`
+	Path      string    `json:"path"`
+	SizeBytes int64     `json:"size_bytes"`
+	Modified  time.Time `json:"modified"`
+}
+
+// SessionDBs discovers the per-session databases in a directory and
+// opens them on demand. Opened stores are kept until Close.
+type SessionDBs struct {
+	// Cipher, if set, is given to every store opened, so encrypted
+	// payloads are decrypted on read.
+	Cipher *PayloadCipher
+
+	dir    string
+	logger *slog.Logger
+
+	mu     sync.Mutex
+	stores map[string]*SQLiteStore
+}
+
+// NewSessionDBs creates a discovery layer over the databases in dir.
+func NewSessionDBs(dir string, logger *slog.Logger) *SessionDBs {
+	return &SessionDBs{dir: dir, logger: logger, stores: make(map[string]*SQLiteStore)}
+}
+
+// Dir returns the directory the databases are read from.
+func (d *SessionDBs) Dir() string {
+	return d.dir
+}
+
+// List returns the session databases in the directory, most recently
+// modified first. A missing directory has no databases.
+func (d *SessionDBs) List() ([]SessionDB, error) {
+	entries, err := os.ReadDir(d.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("list session databases: %w", err)
+	}
+
+	var dbs []SessionDB
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), sessionDBExt)
+		if !ok || id == "" || !e.Type().IsRegular() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue // removed since ReadDir
+		}
+		dbs = append(dbs, SessionDB{
+			SessionID: id,
+			Path:      filepath.Join(d.dir, e.Name()),
+			SizeBytes: info.Size(),
+			Modified:  info.ModTime(),
+		})
+	}
+	sort.Slice(dbs, func(i, j int) bool { return dbs[i].Modified.After(dbs[j].Modified) })
+	return dbs, nil
+}
+
+// Open returns the store for a session's database. It never creates one:
+// ErrUnknownSessionDB is returned if the session has no database.
+func (d *SessionDBs) Open(sessionID string) (*SQLiteStore, error) {
+	if sessionID == "" || sessionID != filepath.Base(sessionID) || strings.HasPrefix(sessionID, ".") {
+		return nil, fmt.Errorf("%w %q", ErrUnknownSessionDB, sessionID)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if s, ok := d.stores[sessionID]; ok {
+		return s, nil
+	}
+
+	path := SessionDBPath(d.dir, sessionID)
+	if _, err := os.Stat(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w %q", ErrUnknownSessionDB, sessionID)
+		}
+		return nil, err
+	}
+	s, err := NewSQLiteStore(path, d.logger)
+	if err != nil {
+		return nil, err
+	}
+	s.Cipher = d.Cipher
+	d.stores[sessionID] = s
+	return s, nil
+}
+
+// OpenAll opens every session database read-only as one combined view,
+// newest first. Databases that can't be read, such as ones at an older
+// schema version, are left out with a warning. The caller closes the
+// result.
+func (d *SessionDBs) OpenAll() (*MultiStore, error) {
+	dbs, err := d.List()
+	if err != nil {
+		return nil, err
+	}
+	m := &MultiStore{}
+	for _, db := range dbs {
+		s, err := openReadOnly(db.Path, d.logger)
+		if err != nil {
+			d.logger.Warn("skipping session database", "path", db.Path, "error", err)
+			continue
+		}
+		s.Cipher = d.Cipher
+		m.stores = append(m.stores, s)
+	}
+	return m, nil
+}
+
+// Close closes every store opened through d.
+func (d *SessionDBs) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var errs []error
+	for id, s := range d.stores {
+		errs = append(errs, s.Close())
+		delete(d.stores, id)
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,93 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeSessionDB creates a session's own database holding one message
+// whose method names the session.
+func writeSessionDB(t *testing.T, dir, sessionID string) {
+	t.Helper()
+	s, err := NewSQLiteStore(SessionDBPath(dir, sessionID), quietLogger)
+	if err != nil {
+		t.Fatalf("create %s: %v", sessionID, err)
+	}
+	ctx := context.Background()
+	s.CreateSession(ctx, &Session{ID: sessionID, StartedAt: time.Now(), Command: "test"})
+	s.LogMessage(ctx, &LogEntry{Timestamp: time.Now(), SessionID: sessionID, Direction: "host_to_server",
+		Kind: "request", Method: "from/" + sessionID, Payload: "{}", Seq: 1})
+	s.Close()
+}
+
+func TestSessionDBs_MessagesLandInOwnFile(t *testing.T) {
+	dir := t.TempDir()
+	writeSessionDB(t, dir, "aaaa1111")
+	writeSessionDB(t, dir, "bbbb2222")
+
+	dbs := NewSessionDBs(dir, quietLogger)
+	defer dbs.Close()
+
+	for _, id := range []string{"aaaa1111", "bbbb2222"} {
+		if _, err := os.Stat(filepath.Join(dir, id+".db")); err != nil {
+			t.Fatalf("database for %s not created: %v", id, err)
+		}
+		s, err := dbs.Open(id)
+		if err != nil {
+			t.Fatalf("Open(%s): %v", id, err)
+		}
+		msgs, err := s.Query(context.Background(), QueryFilter{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(msgs) != 1 || msgs[0].Method != "from/"+id || msgs[0].SessionID != id {
+			t.Errorf("%s holds %+v, want only its own message", id, msgs)
+		}
+	}
+}
+
+func TestSessionDBs_List(t *testing.T) {
+	dir := t.TempDir()
+	writeSessionDB(t, dir, "older")
+	writeSessionDB(t, dir, "newer")
+	past := time.Now().Add(-time.Hour)
+	os.Chtimes(SessionDBPath(dir, "older"), past, past)
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("x"), 0644)
+
+	got, err := NewSessionDBs(dir, quietLogger).List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(got) != 2 || got[0].SessionID != "newer" || got[1].SessionID != "older" {
+		t.Fatalf("List = %+v, want newer then older", got)
+	}
+	if got[0].SizeBytes == 0 || got[0].Path != SessionDBPath(dir, "newer") {
+		t.Errorf("incomplete entry: %+v", got[0])
+	}
+}
+
+func TestSessionDBs_ListMissingDir(t *testing.T) {
+	got, err := NewSessionDBs(filepath.Join(t.TempDir(), "absent"), quietLogger).List()
+	if err != nil || len(got) != 0 {
+		t.Errorf("List = %v, %v; want nothing", got, err)
+	}
+}
+
+func TestSessionDBs_OpenUnknown(t *testing.T) {
+	dir := t.TempDir()
+	dbs := NewSessionDBs(dir, quietLogger)
+	defer dbs.Close()
+
+	for _, id := range []string{"missing", "", "../contextgate", ".hidden"} {
+		if _, err := dbs.Open(id); !errors.Is(err, ErrUnknownSessionDB) {
+			t.Errorf("Open(%q) = %v, want ErrUnknownSessionDB", id, err)
+		}
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("Open created files: %v", entries)
+	}
+}
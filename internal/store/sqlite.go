@@ -7,6 +7,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"maps"
+	"math"
+	"os"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -28,7 +32,24 @@ type SQLiteStore struct {
 	db      *sql.DB
 	logger  *slog.Logger
 	writeCh chan *LogEntry
+	flushCh chan chan struct{}
 	wg      sync.WaitGroup
+	now     func() time.Time // stamps session ends and tool registrations
+
+	// MaxMessagesPerSession, if positive, caps how many messages a
+	// session keeps. After each write batch, the oldest messages of the
+	// sessions it touched are deleted down to the cap, except flagged ones
+	// (blocked, audited, scrubbed, matched by a policy rule, unrecognized
+	// or with tools pruned) and those with tags or a note, which are always
+	// kept. Set it before logging any messages.
+	MaxMessagesPerSession int
+
+	// Cipher, if set, encrypts message and approval payloads before they
+	// are written and decrypts them on read. Rows written without it are
+	// still read as plaintext. Tool call arguments aren't stored while
+	// it's set, so argument filters and usage don't see those calls. Set
+	// it before logging any messages.
+	Cipher *PayloadCipher
 }
 
 // NewSQLiteStore opens (or creates) a SQLite database and starts the
@@ -43,42 +64,17 @@ func NewSQLiteStore(dbPath string, logger *slog.Logger) (*SQLiteStore, error) {
 	db.SetMaxOpenConns(2) // one for writer, one for readers
 	db.SetMaxIdleConns(2)
 
-	if _, err := db.Exec(schemaSQL); err != nil {
+	if err := initSchema(db); err != nil {
 		db.Close()
-		return nil, fmt.Errorf("init schema: %w", err)
-	}
-
-	// Idempotent migrations for Phase 2 columns (existing databases)
-	for _, m := range []string{
-		"ALTER TABLE messages ADD COLUMN audit INTEGER NOT NULL DEFAULT 0",
-		"ALTER TABLE messages ADD COLUMN scrub_count INTEGER NOT NULL DEFAULT 0",
-		"ALTER TABLE messages ADD COLUMN matched_rules TEXT",
-		"ALTER TABLE messages ADD COLUMN tool_name TEXT",
-		"ALTER TABLE messages ADD COLUMN policy_action TEXT",
-	} {
-		db.Exec(m) // ignore "duplicate column" errors
-	}
-
-	// Phase 3 migrations (tool_registry table for existing databases)
-	for _, m := range []string{
-		`CREATE TABLE IF NOT EXISTS tool_registry (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			session_id TEXT NOT NULL,
-			tool_name TEXT NOT NULL,
-			description TEXT NOT NULL DEFAULT '',
-			first_seen TEXT NOT NULL,
-			UNIQUE(session_id, tool_name)
-		)`,
-		"CREATE INDEX IF NOT EXISTS idx_tool_registry_session ON tool_registry(session_id)",
-		"CREATE INDEX IF NOT EXISTS idx_tool_registry_tool ON tool_registry(tool_name)",
-	} {
-		db.Exec(m)
+		return nil, err
 	}
 
 	s := &SQLiteStore{
 		db:      db,
 		logger:  logger,
 		writeCh: make(chan *LogEntry, bufferSize),
+		flushCh: make(chan chan struct{}),
+		now:     time.Now,
 	}
 
 	s.wg.Add(1)
@@ -87,6 +83,39 @@ func NewSQLiteStore(dbPath string, logger *slog.Logger) (*SQLiteStore, error) {
 	return s, nil
 }
 
+// openReadOnly opens an existing database for the read methods only, so
+// it can be read while a proxy is still writing to it. It can't be
+// migrated, so it must already be at the current schema version.
+func openReadOnly(dbPath string, logger *slog.Logger) (*SQLiteStore, error) {
+	if _, err := os.Stat(dbPath); err != nil {
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+	dsn := fmt.Sprintf("file:%s?mode=ro&_busy_timeout=5000", dbPath)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open sqlite: %w", err)
+	}
+
+	var version int
+	if err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: read schema version: %w", dbPath, err)
+	}
+	if version < SchemaVersion() {
+		db.Close()
+		return nil, fmt.Errorf("%s: schema version %d is older than %d; open it with contextgate once to upgrade it", dbPath, version, SchemaVersion())
+	}
+
+	// No write consumer: nothing may be logged to a read-only store
+	return &SQLiteStore{
+		db:      db,
+		logger:  logger,
+		writeCh: make(chan *LogEntry),
+		flushCh: make(chan chan struct{}),
+		now:     time.Now,
+	}, nil
+}
+
 // LogMessage enqueues a message for async persistence.
 func (s *SQLiteStore) LogMessage(_ context.Context, entry *LogEntry) error {
 	select {
@@ -98,6 +127,11 @@ func (s *SQLiteStore) LogMessage(_ context.Context, entry *LogEntry) error {
 	}
 }
 
+// BufferFill reports the share of the write buffer in use.
+func (s *SQLiteStore) BufferFill() float64 {
+	return float64(len(s.writeCh)) / float64(cap(s.writeCh))
+}
+
 func (s *SQLiteStore) consumeWrites() {
 	defer s.wg.Done()
 
@@ -125,10 +159,38 @@ func (s *SQLiteStore) consumeWrites() {
 				s.flushBatch(batch)
 				batch = batch[:0]
 			}
+
+		case done := <-s.flushCh:
+			// Drain whatever is buffered so callers see all prior writes
+			for drained := false; !drained; {
+				select {
+				case entry, ok := <-s.writeCh:
+					if !ok {
+						drained = true
+						break
+					}
+					batch = append(batch, entry)
+				default:
+					drained = true
+				}
+			}
+			if len(batch) > 0 {
+				s.flushBatch(batch)
+				batch = batch[:0]
+			}
+			close(done)
 		}
 	}
 }
 
+// Flush blocks until all messages enqueued before the call are persisted.
+// It must not be called after Close.
+func (s *SQLiteStore) Flush() {
+	done := make(chan struct{})
+	s.flushCh <- done
+	<-done
+}
+
 func (s *SQLiteStore) flushBatch(batch []*LogEntry) {
 	tx, err := s.db.Begin()
 	if err != nil {
@@ -137,8 +199,8 @@ func (s *SQLiteStore) flushBatch(batch []*LogEntry) {
 	}
 
 	stmt, err := tx.Prepare(`
-		INSERT INTO messages (timestamp, session_id, direction, kind, method, msg_id, payload, size_bytes, blocked, audit, scrub_count, matched_rules, tool_name, policy_action)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO messages (timestamp, session_id, direction, kind, method, msg_id, payload, size_bytes, blocked, audit, scrub_count, matched_rules, tool_name, policy_action, token_estimate, seq, unrecognized, latency_ms, arguments, tools_pruned, request_tool)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`)
 	if err != nil {
 		tx.Rollback()
@@ -156,12 +218,29 @@ func (s *SQLiteStore) flushBatch(batch []*LogEntry) {
 		if e.Audit {
 			audit = 1
 		}
+		unrecognized := 0
+		if e.Unrecognized {
+			unrecognized = 1
+		}
+		var latency *float64
+		if e.LatencyMs > 0 {
+			latency = &e.LatencyMs
+		}
 		var matchedRules *string
 		if len(e.MatchedRules) > 0 {
 			j, _ := json.Marshal(e.MatchedRules)
 			s := string(j)
 			matchedRules = &s
 		}
+		payload, arguments := e.Payload, string(e.Arguments)
+		if s.Cipher != nil {
+			sealed, err := s.Cipher.Seal(e.SessionID, e.Payload)
+			if err != nil {
+				s.logger.Error("encrypt payload", "error", err, "method", e.Method)
+				continue
+			}
+			payload, arguments = sealed, ""
+		}
 		_, err := stmt.Exec(
 			e.Timestamp.Format(time.RFC3339Nano),
 			e.SessionID,
@@ -169,7 +248,7 @@ func (s *SQLiteStore) flushBatch(batch []*LogEntry) {
 			e.Kind,
 			e.Method,
 			e.MsgID,
-			e.Payload,
+			payload,
 			e.SizeBytes,
 			blocked,
 			audit,
@@ -177,6 +256,13 @@ func (s *SQLiteStore) flushBatch(batch []*LogEntry) {
 			matchedRules,
 			nilIfEmpty(e.ToolName),
 			nilIfEmpty(e.PolicyAction),
+			e.TokenEstimate,
+			e.Seq,
+			unrecognized,
+			latency,
+			nilIfEmpty(arguments),
+			e.ToolsPruned,
+			nilIfEmpty(e.RequestTool),
 		)
 		if err != nil {
 			s.logger.Error("insert message", "error", err, "method", e.Method)
@@ -185,6 +271,38 @@ func (s *SQLiteStore) flushBatch(batch []*LogEntry) {
 
 	if err := tx.Commit(); err != nil {
 		s.logger.Error("commit batch", "error", err)
+		return
+	}
+
+	if s.MaxMessagesPerSession > 0 {
+		sessions := make(map[string]bool)
+		for _, e := range batch {
+			if !sessions[e.SessionID] {
+				sessions[e.SessionID] = true
+				s.trimSession(e.SessionID)
+			}
+		}
+	}
+}
+
+// trimSession deletes a session's unflagged, unannotated messages older
+// than its newest MaxMessagesPerSession. The kept set matches what the
+// logging interceptor treats as flagged.
+func (s *SQLiteStore) trimSession(sessionID string) {
+	res, err := s.db.Exec(`
+		DELETE FROM messages
+		WHERE session_id = ?1 AND blocked = 0 AND audit = 0 AND scrub_count = 0
+		  AND policy_action IS NULL AND matched_rules IS NULL
+		  AND unrecognized = 0 AND tools_pruned = 0
+		  AND tags IS NULL AND note IS NULL
+		  AND id < (SELECT id FROM messages WHERE session_id = ?1 ORDER BY id DESC LIMIT 1 OFFSET ?2)`,
+		sessionID, s.MaxMessagesPerSession-1)
+	if err != nil {
+		s.logger.Error("trim session", "error", err, "session", sessionID)
+		return
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		s.logger.Debug("trimmed session messages", "session", sessionID, "deleted", n)
 	}
 }
 
@@ -209,16 +327,40 @@ func (s *SQLiteStore) Query(_ context.Context, f QueryFilter) ([]LogEntry, error
 		conditions = append(conditions, "kind = ?")
 		args = append(args, f.Kind)
 	}
+	if f.MsgID != "" {
+		conditions = append(conditions, "msg_id = ?")
+		args = append(args, f.MsgID)
+	}
+	if f.Tag != "" {
+		conditions = append(conditions, "EXISTS (SELECT 1 FROM json_each(messages.tags) WHERE value = ?)")
+		args = append(args, f.Tag)
+	}
+	if len(f.Args) > 0 {
+		// Matches the partial index on tool calls with arguments
+		conditions = append(conditions, "arguments IS NOT NULL")
+		for _, key := range slices.Sorted(maps.Keys(f.Args)) {
+			value := f.Args[key]
+			if prefix, ok := strings.CutSuffix(value, "*"); ok {
+				// instr, unlike LIKE, is case-sensitive and has no wildcards
+				conditions = append(conditions, "instr(CAST(json_extract(arguments, ?) AS TEXT), ?) = 1")
+				args = append(args, argumentPath(key), prefix)
+			} else {
+				conditions = append(conditions, "CAST(json_extract(arguments, ?) AS TEXT) = ?")
+				args = append(args, argumentPath(key), value)
+			}
+		}
+	}
 	if f.Since != nil {
 		conditions = append(conditions, "timestamp >= ?")
 		args = append(args, f.Since.Format(time.RFC3339Nano))
 	}
 
-	query := "SELECT id, timestamp, session_id, direction, kind, method, msg_id, payload, size_bytes, blocked, audit, scrub_count, matched_rules, tool_name, policy_action FROM messages"
+	query := "SELECT id, timestamp, session_id, direction, kind, method, msg_id, payload, size_bytes, blocked, audit, scrub_count, matched_rules, tool_name, policy_action, token_estimate, seq, unrecognized, latency_ms, tags, note, arguments, tools_pruned, request_tool FROM messages"
 	if len(conditions) > 0 {
 		query += " WHERE " + strings.Join(conditions, " AND ")
 	}
-	query += " ORDER BY id DESC"
+	// Rows written before seq existed have seq 0 and sort as oldest
+	query += " ORDER BY seq DESC, id DESC"
 
 	limit := f.Limit
 	if limit <= 0 {
@@ -237,7 +379,7 @@ func (s *SQLiteStore) Query(_ context.Context, f QueryFilter) ([]LogEntry, error
 
 	var entries []LogEntry
 	for rows.Next() {
-		e, err := scanLogEntry(rows)
+		e, err := s.scanLogEntry(rows)
 		if err != nil {
 			return nil, fmt.Errorf("scan message: %w", err)
 		}
@@ -249,16 +391,41 @@ func (s *SQLiteStore) Query(_ context.Context, f QueryFilter) ([]LogEntry, error
 // GetMessage retrieves a single message by ID.
 func (s *SQLiteStore) GetMessage(_ context.Context, id int64) (*LogEntry, error) {
 	row := s.db.QueryRow(
-		"SELECT id, timestamp, session_id, direction, kind, method, msg_id, payload, size_bytes, blocked, audit, scrub_count, matched_rules, tool_name, policy_action FROM messages WHERE id = ?",
+		"SELECT id, timestamp, session_id, direction, kind, method, msg_id, payload, size_bytes, blocked, audit, scrub_count, matched_rules, tool_name, policy_action, token_estimate, seq, unrecognized, latency_ms, tags, note, arguments, tools_pruned, request_tool FROM messages WHERE id = ?",
 		id,
 	)
-	e, err := scanLogEntryRow(row)
+	e, err := s.scanLogEntryRow(row)
 	if err != nil {
 		return nil, fmt.Errorf("get message: %w", err)
 	}
 	return &e, nil
 }
 
+// AnnotateMessage replaces a message's triage note and tags. Tags are
+// trimmed and deduplicated; empty ones are dropped.
+func (s *SQLiteStore) AnnotateMessage(_ context.Context, id int64, note string, tags []string) error {
+	var clean []string
+	for _, t := range tags {
+		if t = strings.TrimSpace(t); t != "" && !slices.Contains(clean, t) {
+			clean = append(clean, t)
+		}
+	}
+	var tagsJSON *string
+	if len(clean) > 0 {
+		j, _ := json.Marshal(clean)
+		s := string(j)
+		tagsJSON = &s
+	}
+	res, err := s.db.Exec("UPDATE messages SET note = ?, tags = ? WHERE id = ?", nilIfEmpty(strings.TrimSpace(note)), tagsJSON, id)
+	if err != nil {
+		return fmt.Errorf("annotate message: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
 // Stats returns aggregate statistics.
 func (s *SQLiteStore) Stats(_ context.Context, sessionID string) (*Stats, error) {
 	st := &Stats{
@@ -274,9 +441,9 @@ func (s *SQLiteStore) Stats(_ context.Context, sessionID string) (*Stats, error)
 
 	// Totals
 	err := s.db.QueryRow(
-		"SELECT COUNT(*), COALESCE(SUM(size_bytes), 0), COALESCE(SUM(blocked), 0), COALESCE(SUM(scrub_count), 0), COALESCE(SUM(audit), 0) FROM messages"+whereClause,
+		"SELECT COUNT(*), COALESCE(SUM(size_bytes), 0), COALESCE(SUM(blocked), 0), COALESCE(SUM(scrub_count), 0), COALESCE(SUM(audit), 0), COALESCE(SUM(token_estimate), 0) FROM messages"+whereClause,
 		args...,
-	).Scan(&st.TotalMessages, &st.TotalBytes, &st.BlockedCount, &st.ScrubCount, &st.AuditCount)
+	).Scan(&st.TotalMessages, &st.TotalBytes, &st.BlockedCount, &st.ScrubCount, &st.AuditCount, &st.TotalTokens)
 	if err != nil {
 		return nil, fmt.Errorf("stats totals: %w", err)
 	}
@@ -332,25 +499,61 @@ func (s *SQLiteStore) Stats(_ context.Context, sessionID string) (*Stats, error)
 func (s *SQLiteStore) CreateSession(_ context.Context, session *Session) error {
 	argsJSON, _ := json.Marshal(session.Args)
 	_, err := s.db.Exec(
-		"INSERT INTO sessions (id, started_at, command, args) VALUES (?, ?, ?, ?)",
+		`INSERT INTO sessions (id, started_at, command, args, client) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET ended_at = NULL, command = excluded.command, args = excluded.args, client = excluded.client`,
 		session.ID,
 		session.StartedAt.Format(time.RFC3339Nano),
 		session.Command,
 		string(argsJSON),
+		session.Client,
 	)
 	return err
 }
 
+// SetSessionClient records the host application of a session once it is
+// known.
+func (s *SQLiteStore) SetSessionClient(_ context.Context, sessionID, client string) error {
+	_, err := s.db.Exec("UPDATE sessions SET client = ? WHERE id = ?", client, sessionID)
+	return err
+}
+
 // EndSession marks a session as ended.
 func (s *SQLiteStore) EndSession(_ context.Context, sessionID string) error {
 	_, err := s.db.Exec(
 		"UPDATE sessions SET ended_at = ? WHERE id = ?",
-		time.Now().Format(time.RFC3339Nano),
+		s.now().Format(time.RFC3339Nano),
 		sessionID,
 	)
 	return err
 }
 
+// GetSession retrieves a session by ID.
+func (s *SQLiteStore) GetSession(_ context.Context, sessionID string) (*Session, error) {
+	var session Session
+	var startedAt string
+	var endedAt, argsJSON sql.NullString
+	err := s.db.QueryRow(
+		"SELECT id, started_at, ended_at, command, args, client FROM sessions WHERE id = ?",
+		sessionID,
+	).Scan(&session.ID, &startedAt, &endedAt, &session.Command, &argsJSON, &session.Client)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get session: %w", err)
+	}
+
+	session.StartedAt, _ = time.Parse(time.RFC3339Nano, startedAt)
+	if endedAt.Valid {
+		t, _ := time.Parse(time.RFC3339Nano, endedAt.String)
+		session.EndedAt = &t
+	}
+	if argsJSON.Valid {
+		json.Unmarshal([]byte(argsJSON.String), &session.Args)
+	}
+	return &session, nil
+}
+
 // LogApproval records an approval decision.
 func (s *SQLiteStore) LogApproval(_ context.Context, record *ApprovalRecord) error {
 	var decidedAt *string
@@ -358,8 +561,12 @@ func (s *SQLiteStore) LogApproval(_ context.Context, record *ApprovalRecord) err
 		s := record.DecidedAt.Format(time.RFC3339Nano)
 		decidedAt = &s
 	}
-	_, err := s.db.Exec(
-		"INSERT OR REPLACE INTO approvals (id, timestamp, session_id, direction, method, tool_name, rule_name, payload, decision, decided_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+	payload, err := s.seal(record.SessionID, record.Payload)
+	if err != nil {
+		return fmt.Errorf("encrypt approval payload: %w", err)
+	}
+	_, err = s.db.Exec(
+		"INSERT OR REPLACE INTO approvals (id, timestamp, session_id, direction, method, tool_name, rule_name, payload, decision, decided_at, reason) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
 		record.ID,
 		record.Timestamp.Format(time.RFC3339Nano),
 		record.SessionID,
@@ -367,16 +574,17 @@ func (s *SQLiteStore) LogApproval(_ context.Context, record *ApprovalRecord) err
 		record.Method,
 		record.ToolName,
 		record.RuleName,
-		record.Payload,
+		payload,
 		record.Decision,
 		decidedAt,
+		record.Reason,
 	)
 	return err
 }
 
 // GetApprovals retrieves approval records.
 func (s *SQLiteStore) GetApprovals(_ context.Context, sessionID string) ([]ApprovalRecord, error) {
-	query := "SELECT id, timestamp, session_id, direction, method, tool_name, rule_name, payload, decision, decided_at FROM approvals"
+	query := "SELECT id, timestamp, session_id, direction, method, tool_name, rule_name, payload, decision, decided_at, reason FROM approvals"
 	var args []any
 	if sessionID != "" {
 		query += " WHERE session_id = ?"
@@ -396,10 +604,11 @@ func (s *SQLiteStore) GetApprovals(_ context.Context, sessionID string) ([]Appro
 		var ts string
 		var method, toolName sql.NullString
 		var decidedAt sql.NullString
-		if err := rows.Scan(&r.ID, &ts, &r.SessionID, &r.Direction, &method, &toolName, &r.RuleName, &r.Payload, &r.Decision, &decidedAt); err != nil {
+		if err := rows.Scan(&r.ID, &ts, &r.SessionID, &r.Direction, &method, &toolName, &r.RuleName, &r.Payload, &r.Decision, &decidedAt, &r.Reason); err != nil {
 			return nil, fmt.Errorf("scan approval: %w", err)
 		}
 		r.Timestamp, _ = time.Parse(time.RFC3339Nano, ts)
+		r.Payload = s.open(r.SessionID, r.Payload)
 		r.Method = method.String
 		r.ToolName = toolName.String
 		if decidedAt.Valid {
@@ -419,8 +628,12 @@ func (s *SQLiteStore) RegisterTools(_ context.Context, sessionID string, tools [
 	}
 
 	stmt, err := tx.Prepare(
-		`INSERT OR IGNORE INTO tool_registry (session_id, tool_name, description, first_seen)
-		 VALUES (?, ?, ?, ?)`,
+		`INSERT INTO tool_registry (session_id, tool_name, description, first_seen, last_seen, input_properties, mid_session)
+		 VALUES (?, ?, ?, ?, ?, ?, ?)
+		 ON CONFLICT(session_id, tool_name) DO UPDATE SET
+			description = excluded.description,
+			last_seen = excluded.last_seen,
+			input_properties = excluded.input_properties`,
 	)
 	if err != nil {
 		tx.Rollback()
@@ -428,9 +641,14 @@ func (s *SQLiteStore) RegisterTools(_ context.Context, sessionID string, tools [
 	}
 	defer stmt.Close()
 
-	now := time.Now().Format(time.RFC3339Nano)
+	now := s.now().Format(time.RFC3339Nano)
 	for _, t := range tools {
-		if _, err := stmt.Exec(sessionID, t.ToolName, t.Description, now); err != nil {
+		var props *string
+		if t.InputProperties != nil {
+			b, _ := json.Marshal(t.InputProperties)
+			props = nilIfEmpty(string(b))
+		}
+		if _, err := stmt.Exec(sessionID, t.ToolName, t.Description, now, now, props, t.MidSession); err != nil {
 			s.logger.Error("insert tool", "error", err, "tool", t.ToolName)
 		}
 	}
@@ -438,13 +656,118 @@ func (s *SQLiteStore) RegisterTools(_ context.Context, sessionID string, tools [
 	return tx.Commit()
 }
 
+// ToolTimeline lists tools in registration order, flagging those first
+// registered as MidSession.
+func (s *SQLiteStore) ToolTimeline(_ context.Context, sessionID string) ([]ToolTimelineEntry, error) {
+	var whereClause string
+	var args []any
+	if sessionID != "" {
+		whereClause = " WHERE tr.session_id = ?"
+		args = append(args, sessionID)
+	}
+
+	query := `
+		SELECT
+			tr.session_id,
+			tr.tool_name,
+			tr.description,
+			tr.first_seen,
+			tr.last_seen,
+			tr.mid_session
+		FROM tool_registry tr` + whereClause + `
+		ORDER BY tr.id ASC
+	`
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query tool timeline: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []ToolTimelineEntry
+	for rows.Next() {
+		var e ToolTimelineEntry
+		var firstSeen, lastSeen string
+		if err := rows.Scan(&e.SessionID, &e.ToolName, &e.Description, &firstSeen, &lastSeen, &e.AddedMidSession); err != nil {
+			return nil, fmt.Errorf("scan tool timeline: %w", err)
+		}
+		e.FirstSeen, _ = time.Parse(time.RFC3339Nano, firstSeen)
+		e.LastSeen, _ = time.Parse(time.RFC3339Nano, lastSeen)
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// GetToolArgUsage compares the tool's inputSchema properties, as last
+// registered, with the top-level keys of the arguments stored for its
+// calls. Empty sessionID covers all sessions. Calls whose payload wasn't
+// stored aren't counted.
+func (s *SQLiteStore) GetToolArgUsage(_ context.Context, sessionID, tool string) (*ToolArgUsage, error) {
+	usage := &ToolArgUsage{ToolName: tool, Args: []ArgKeyUsage{}}
+
+	var sessionClause string
+	args := []any{tool}
+	if sessionID != "" {
+		sessionClause = " AND session_id = ?"
+		args = append(args, sessionID)
+	}
+
+	var props, lastSeen string
+	err := s.db.QueryRow(
+		`SELECT input_properties, last_seen FROM tool_registry
+		 WHERE tool_name = ? AND input_properties IS NOT NULL`+sessionClause+`
+		 ORDER BY last_seen DESC, id DESC LIMIT 1`, args...,
+	).Scan(&props, &lastSeen)
+	switch {
+	case err == sql.ErrNoRows:
+	case err != nil:
+		return nil, fmt.Errorf("query declared arguments: %w", err)
+	default:
+		if err := json.Unmarshal([]byte(props), &usage.Declared); err != nil {
+			return nil, fmt.Errorf("decode declared arguments: %w", err)
+		}
+		if t, err := time.Parse(time.RFC3339Nano, lastSeen); err == nil {
+			usage.DeclaredAt = &t
+		}
+	}
+
+	// Matches the partial index on tool calls with arguments
+	if err := s.db.QueryRow(
+		`SELECT COUNT(*) FROM messages WHERE tool_name = ? AND arguments IS NOT NULL`+sessionClause, args...,
+	).Scan(&usage.Calls); err != nil {
+		return nil, fmt.Errorf("count tool calls: %w", err)
+	}
+	rows, err := s.db.Query(
+		`SELECT a.key, COUNT(*) FROM messages m, json_each(m.arguments) a
+		 WHERE m.tool_name = ? AND m.arguments IS NOT NULL`+sessionClause+`
+		 GROUP BY a.key ORDER BY a.key`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query argument keys: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var a ArgKeyUsage
+		if err := rows.Scan(&a.Key, &a.Calls); err != nil {
+			return nil, fmt.Errorf("scan argument key: %w", err)
+		}
+		usage.Args = append(usage.Args, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	usage.compare()
+	return usage, nil
+}
+
 // GetToolAnalytics computes tool analytics across sessions.
 func (s *SQLiteStore) GetToolAnalytics(_ context.Context, sessionID string) (*ToolAnalyticsSummary, error) {
-	var whereClause string
+	var whereClause, tokenClause string
 	var args []any
 	if sessionID != "" {
 		whereClause = " WHERE session_id = ?"
-		args = append(args, sessionID)
+		tokenClause = " AND session_id = ?"
+		args = append(args, sessionID, sessionID)
 	}
 
 	query := `
@@ -453,10 +776,15 @@ func (s *SQLiteStore) GetToolAnalytics(_ context.Context, sessionID string) (*To
 			tr.description,
 			COALESCE(u.call_count, 0) AS call_count,
 			COALESCE(u.sessions_used, 0) AS sessions_used,
-			COALESCE(u.last_used, '') AS last_used
+			COALESCE(u.last_used, '') AS last_used,
+			COALESCE(t.tokens, 0) AS tokens
 		FROM (
-			SELECT DISTINCT tool_name, description
-			FROM tool_registry` + whereClause + `
+			-- One row per tool, with the description it was last listed with
+			SELECT tool_name, description FROM (
+				SELECT tool_name, description,
+					ROW_NUMBER() OVER (PARTITION BY tool_name ORDER BY last_seen DESC, id DESC) AS rn
+				FROM tool_registry` + whereClause + `
+			) WHERE rn = 1
 		) tr
 		LEFT JOIN (
 			SELECT
@@ -468,6 +796,16 @@ func (s *SQLiteStore) GetToolAnalytics(_ context.Context, sessionID string) (*To
 			WHERE tool_name IS NOT NULL AND tool_name != ''
 			GROUP BY tool_name
 		) u ON tr.tool_name = u.tool_name
+		LEFT JOIN (
+			-- Calls and the responses to them, which carry the tool called
+			SELECT
+				CASE WHEN kind = 'request' THEN tool_name ELSE request_tool END AS tool_name,
+				SUM(token_estimate) AS tokens
+			FROM messages
+			WHERE ((kind = 'request' AND tool_name IS NOT NULL AND tool_name != '')
+				OR request_tool IS NOT NULL)` + tokenClause + `
+			GROUP BY 1
+		) t ON tr.tool_name = t.tool_name
 		ORDER BY call_count DESC, tr.tool_name ASC
 	`
 
@@ -480,7 +818,7 @@ func (s *SQLiteStore) GetToolAnalytics(_ context.Context, sessionID string) (*To
 	summary := &ToolAnalyticsSummary{}
 	for rows.Next() {
 		var ta ToolAnalytics
-		if err := rows.Scan(&ta.ToolName, &ta.Description, &ta.CallCount, &ta.SessionsSeen, &ta.LastUsed); err != nil {
+		if err := rows.Scan(&ta.ToolName, &ta.Description, &ta.CallCount, &ta.SessionsSeen, &ta.LastUsed, &ta.TokenEstimate); err != nil {
 			return nil, fmt.Errorf("scan tool analytics: %w", err)
 		}
 		summary.Tools = append(summary.Tools, ta)
@@ -489,8 +827,62 @@ func (s *SQLiteStore) GetToolAnalytics(_ context.Context, sessionID string) (*To
 			summary.TotalUsed++
 		}
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-	return summary, rows.Err()
+	latencies, err := s.toolLatencies(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range summary.Tools {
+		ta := &summary.Tools[i]
+		ta.AvgLatencyMs, ta.P95LatencyMs = latencyStats(latencies[ta.ToolName])
+	}
+	return summary, nil
+}
+
+// toolLatencies returns the recorded response latencies of each tool's
+// calls, sorted ascending, for one session or, if sessionID is empty, all
+// of them. Responses carry the tool of the call they answer.
+func (s *SQLiteStore) toolLatencies(sessionID string) (map[string][]float64, error) {
+	query := `SELECT request_tool, latency_ms FROM messages
+		WHERE request_tool IS NOT NULL AND latency_ms IS NOT NULL`
+	var args []any
+	if sessionID != "" {
+		query += " AND session_id = ?"
+		args = append(args, sessionID)
+	}
+	rows, err := s.db.Query(query+" ORDER BY request_tool, latency_ms", args...)
+	if err != nil {
+		return nil, fmt.Errorf("query tool latencies: %w", err)
+	}
+	defer rows.Close()
+
+	latencies := make(map[string][]float64)
+	for rows.Next() {
+		var tool string
+		var ms float64
+		if err := rows.Scan(&tool, &ms); err != nil {
+			return nil, fmt.Errorf("scan tool latencies: %w", err)
+		}
+		latencies[tool] = append(latencies[tool], ms)
+	}
+	return latencies, rows.Err()
+}
+
+// latencyStats returns the mean and the nearest-rank 95th percentile of
+// sorted latencies.
+func latencyStats(sorted []float64) (avg, p95 float64) {
+	if len(sorted) == 0 {
+		return 0, 0
+	}
+	var sum float64
+	for _, v := range sorted {
+		sum += v
+	}
+	rank := int(math.Ceil(0.95 * float64(len(sorted))))
+	return sum / float64(len(sorted)), sorted[rank-1]
 }
 
 // GetToolUsageCounts returns per-tool call counts, optionally scoped to recent sessions.
@@ -529,6 +921,231 @@ func (s *SQLiteStore) GetToolUsageCounts(_ context.Context, lastNSessions int) (
 	return counts, rows.Err()
 }
 
+// SessionReport returns per-tool and per-method policy outcome counts
+// for host→server requests in a session.
+func (s *SQLiteStore) SessionReport(_ context.Context, sessionID string) (*SessionReport, error) {
+	report := &SessionReport{SessionID: sessionID}
+
+	var err error
+	report.Tools, err = s.actionCounts("tool_name", sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("report tools: %w", err)
+	}
+	report.Methods, err = s.actionCounts("method", sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("report methods: %w", err)
+	}
+	return report, nil
+}
+
+// actionCounts groups host→server requests by column and counts policy
+// outcomes, each request under one; see ActionCounts. column must be a
+// trusted identifier, never user input.
+func (s *SQLiteStore) actionCounts(column, sessionID string) ([]ActionCounts, error) {
+	query := fmt.Sprintf(`
+		SELECT
+			%[1]s,
+			COUNT(*),
+			COALESCE(SUM(CASE WHEN blocked = 0 AND COALESCE(policy_action, '') != 'require_approval' AND audit = 0 THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(blocked), 0),
+			COALESCE(SUM(CASE WHEN blocked = 0 AND policy_action = 'require_approval' THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN blocked = 0 AND COALESCE(policy_action, '') != 'require_approval' AND audit = 1 THEN 1 ELSE 0 END), 0)
+		FROM messages
+		WHERE session_id = ? AND direction = 'host_to_server' AND kind = 'request'
+			AND %[1]s IS NOT NULL AND %[1]s != ''
+		GROUP BY %[1]s
+		ORDER BY COUNT(*) DESC, %[1]s ASC
+	`, column)
+
+	rows, err := s.db.Query(query, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []ActionCounts
+	for rows.Next() {
+		var c ActionCounts
+		if err := rows.Scan(&c.Name, &c.Total, &c.Allowed, &c.Denied, &c.RequireApproval, &c.Audited); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
+
+// BlockedLeaderboard ranks blocked messages by tool and the first rule that
+// matched them, optionally filtered by session.
+func (s *SQLiteStore) BlockedLeaderboard(_ context.Context, sessionID string) ([]BlockedStat, error) {
+	var sessionClause string
+	var args []any
+	if sessionID != "" {
+		sessionClause = " AND session_id = ?"
+		args = append(args, sessionID)
+	}
+
+	query := `
+		SELECT
+			COALESCE(tool_name, '') AS tool,
+			COALESCE(json_extract(matched_rules, '$[0]'), '') AS rule,
+			COUNT(*) AS blocked_count
+		FROM messages
+		WHERE blocked = 1` + sessionClause + `
+		GROUP BY tool, rule
+		ORDER BY blocked_count DESC, tool ASC, rule ASC
+	`
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query blocked leaderboard: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []BlockedStat
+	for rows.Next() {
+		var b BlockedStat
+		if err := rows.Scan(&b.ToolName, &b.Rule, &b.Count); err != nil {
+			return nil, fmt.Errorf("scan blocked leaderboard: %w", err)
+		}
+		stats = append(stats, b)
+	}
+	return stats, rows.Err()
+}
+
+// UnrecognizedMethods counts messages whose method was flagged as outside
+// the known set, optionally filtered by session.
+func (s *SQLiteStore) UnrecognizedMethods(_ context.Context, sessionID string) ([]MethodStat, error) {
+	var sessionClause string
+	var args []any
+	if sessionID != "" {
+		sessionClause = " AND session_id = ?"
+		args = append(args, sessionID)
+	}
+
+	query := `
+		SELECT method, direction, COUNT(*), MAX(timestamp)
+		FROM messages
+		WHERE unrecognized = 1` + sessionClause + `
+		GROUP BY method, direction
+		ORDER BY COUNT(*) DESC, method ASC
+	`
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query unrecognized methods: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []MethodStat
+	for rows.Next() {
+		var m MethodStat
+		var lastSeen string
+		if err := rows.Scan(&m.Method, &m.Direction, &m.Count, &lastSeen); err != nil {
+			return nil, fmt.Errorf("scan unrecognized methods: %w", err)
+		}
+		m.LastSeen, _ = time.Parse(time.RFC3339Nano, lastSeen)
+		stats = append(stats, m)
+	}
+	return stats, rows.Err()
+}
+
+// RecordToolConflicts upserts duplicate tool names for a session, keeping
+// the copy count from the latest tools/list.
+func (s *SQLiteStore) RecordToolConflicts(_ context.Context, sessionID string, conflicts []ToolConflict) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+
+	stmt, err := tx.Prepare(
+		`INSERT INTO tool_conflicts (session_id, tool_name, copies, last_seen)
+		 VALUES (?, ?, ?, ?)
+		 ON CONFLICT(session_id, tool_name) DO UPDATE SET copies = excluded.copies, last_seen = excluded.last_seen`,
+	)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("prepare: %w", err)
+	}
+	defer stmt.Close()
+
+	now := s.now().Format(time.RFC3339Nano)
+	for _, c := range conflicts {
+		if _, err := stmt.Exec(sessionID, c.ToolName, c.Copies, now); err != nil {
+			s.logger.Error("insert tool conflict", "error", err, "tool", c.ToolName)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ToolConflicts lists duplicate tool names, most recent first, optionally
+// filtered by session.
+func (s *SQLiteStore) ToolConflicts(_ context.Context, sessionID string) ([]ToolConflict, error) {
+	var whereClause string
+	var args []any
+	if sessionID != "" {
+		whereClause = " WHERE session_id = ?"
+		args = append(args, sessionID)
+	}
+
+	rows, err := s.db.Query(`
+		SELECT session_id, tool_name, copies, last_seen
+		FROM tool_conflicts`+whereClause+`
+		ORDER BY last_seen DESC, tool_name ASC
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query tool conflicts: %w", err)
+	}
+	defer rows.Close()
+
+	var conflicts []ToolConflict
+	for rows.Next() {
+		var c ToolConflict
+		var lastSeen string
+		if err := rows.Scan(&c.SessionID, &c.ToolName, &c.Copies, &lastSeen); err != nil {
+			return nil, fmt.Errorf("scan tool conflicts: %w", err)
+		}
+		c.LastSeen, _ = time.Parse(time.RFC3339Nano, lastSeen)
+		conflicts = append(conflicts, c)
+	}
+	return conflicts, rows.Err()
+}
+
+// ClearMessages deletes every logged message and approval record,
+// including any still buffered, and returns how many messages were
+// deleted. Sessions are kept.
+func (s *SQLiteStore) ClearMessages(_ context.Context) (int64, error) {
+	s.Flush()
+	return s.clearTables("messages", "approvals")
+}
+
+// ClearToolRegistry deletes every registered tool and recorded tool
+// conflict and returns how many tools were deleted.
+func (s *SQLiteStore) ClearToolRegistry(_ context.Context) (int64, error) {
+	return s.clearTables("tool_registry", "tool_conflicts")
+}
+
+// clearTables empties tables in one transaction and returns the number
+// of rows deleted from the first.
+func (s *SQLiteStore) clearTables(tables ...string) (int64, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("begin tx: %w", err)
+	}
+	var deleted int64
+	for i, table := range tables {
+		res, err := tx.Exec("DELETE FROM " + table)
+		if err != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("clear %s: %w", table, err)
+		}
+		if i == 0 {
+			deleted, _ = res.RowsAffected()
+		}
+	}
+	return deleted, tx.Commit()
+}
+
 // Close flushes pending writes and closes the database.
 func (s *SQLiteStore) Close() error {
 	close(s.writeCh)
@@ -545,11 +1162,13 @@ func scanLogEntryFromScanner(sc scanner) (LogEntry, error) {
 	var e LogEntry
 	var ts string
 	var method, msgID, matchedRulesJSON, toolName, policyAction sql.NullString
-	var blocked, audit, scrubCount int
+	var blocked, audit, scrubCount, unrecognized int
+	var latency sql.NullFloat64
+	var tagsJSON, note, arguments, requestTool sql.NullString
 
 	err := sc.Scan(&e.ID, &ts, &e.SessionID, &e.Direction, &e.Kind,
 		&method, &msgID, &e.Payload, &e.SizeBytes, &blocked,
-		&audit, &scrubCount, &matchedRulesJSON, &toolName, &policyAction)
+		&audit, &scrubCount, &matchedRulesJSON, &toolName, &policyAction, &e.TokenEstimate, &e.Seq, &unrecognized, &latency, &tagsJSON, &note, &arguments, &e.ToolsPruned, &requestTool)
 	if err != nil {
 		return e, err
 	}
@@ -559,23 +1178,54 @@ func scanLogEntryFromScanner(sc scanner) (LogEntry, error) {
 	e.MsgID = msgID.String
 	e.Blocked = blocked != 0
 	e.Audit = audit != 0
+	e.Unrecognized = unrecognized != 0
+	e.LatencyMs = latency.Float64
+	e.RequestTool = requestTool.String
 	e.ScrubCount = scrubCount
 	e.ToolName = toolName.String
 	e.PolicyAction = policyAction.String
 	if matchedRulesJSON.Valid {
 		json.Unmarshal([]byte(matchedRulesJSON.String), &e.MatchedRules)
 	}
+	if tagsJSON.Valid {
+		json.Unmarshal([]byte(tagsJSON.String), &e.Tags)
+	}
+	e.Note = note.String
+	if arguments.Valid {
+		e.Arguments = json.RawMessage(arguments.String)
+	}
 	return e, nil
 }
 
-// scanLogEntry scans a LogEntry from a *sql.Rows.
-func scanLogEntry(rows *sql.Rows) (LogEntry, error) {
-	return scanLogEntryFromScanner(rows)
+// scanLogEntry scans a LogEntry from a *sql.Rows, decrypting its payload.
+func (s *SQLiteStore) scanLogEntry(rows *sql.Rows) (LogEntry, error) {
+	e, err := scanLogEntryFromScanner(rows)
+	if err == nil {
+		e.Payload = s.open(e.SessionID, e.Payload)
+	}
+	return e, err
+}
+
+// scanLogEntryRow scans a LogEntry from a *sql.Row, decrypting its payload.
+func (s *SQLiteStore) scanLogEntryRow(row *sql.Row) (LogEntry, error) {
+	e, err := scanLogEntryFromScanner(row)
+	if err == nil {
+		e.Payload = s.open(e.SessionID, e.Payload)
+	}
+	return e, err
 }
 
-// scanLogEntryRow scans a LogEntry from a *sql.Row.
-func scanLogEntryRow(row *sql.Row) (LogEntry, error) {
-	return scanLogEntryFromScanner(row)
+// argumentPath turns a dotted argument name into a JSON path, quoting
+// each segment so names with other punctuation match literally.
+func argumentPath(key string) string {
+	var b strings.Builder
+	b.WriteString("$")
+	for _, seg := range strings.Split(key, ".") {
+		b.WriteString(`."`)
+		b.WriteString(strings.ReplaceAll(seg, `"`, `\"`))
+		b.WriteString(`"`)
+	}
+	return b.String()
 }
 
 func nilIfEmpty(s string) *string {
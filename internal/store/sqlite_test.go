@@ -2,9 +2,13 @@ package store
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
 	"testing"
 	"time"
 )
@@ -40,8 +44,7 @@ func TestLogAndQuery(t *testing.T) {
 		t.Fatalf("LogMessage failed: %v", err)
 	}
 
-	// Wait for flush
-	time.Sleep(700 * time.Millisecond)
+	s.Flush()
 
 	entries, err := s.Query(ctx, QueryFilter{SessionID: "test-session"})
 	if err != nil {
@@ -71,7 +74,7 @@ func TestBatchWrite(t *testing.T) {
 		})
 	}
 
-	time.Sleep(700 * time.Millisecond)
+	s.Flush()
 
 	entries, err := s.Query(ctx, QueryFilter{SessionID: "batch-test", Limit: 100})
 	if err != nil {
@@ -96,7 +99,7 @@ func TestStats(t *testing.T) {
 		s.LogMessage(ctx, e)
 	}
 
-	time.Sleep(700 * time.Millisecond)
+	s.Flush()
 
 	stats, err := s.Stats(ctx, "s1")
 	if err != nil {
@@ -134,7 +137,7 @@ func TestGetMessage(t *testing.T) {
 		SizeBytes: 45,
 	})
 
-	time.Sleep(700 * time.Millisecond)
+	s.Flush()
 
 	entry, err := s.GetMessage(ctx, 1)
 	if err != nil {
@@ -165,6 +168,25 @@ func TestSession(t *testing.T) {
 	}
 }
 
+func TestSessionClient(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	s.CreateSession(ctx, &Session{ID: "s1", StartedAt: time.Now(), Command: "server", Client: "claude-code"})
+	got, err := s.GetSession(ctx, "s1")
+	if err != nil || got.Client != "claude-code" {
+		t.Fatalf("GetSession = %+v, %v; want client claude-code", got, err)
+	}
+
+	s.CreateSession(ctx, &Session{ID: "s2", StartedAt: time.Now(), Command: "server"})
+	if err := s.SetSessionClient(ctx, "s2", "cursor"); err != nil {
+		t.Fatalf("SetSessionClient: %v", err)
+	}
+	if got, _ := s.GetSession(ctx, "s2"); got.Client != "cursor" {
+		t.Errorf("detected client = %q, want cursor", got.Client)
+	}
+}
+
 func TestRegisterTools(t *testing.T) {
 	s := newTestStore(t)
 	ctx := context.Background()
@@ -196,6 +218,58 @@ func TestRegisterTools(t *testing.T) {
 	}
 }
 
+func TestRegisterTools_UpdatesDescription(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	first := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := first
+	s.now = func() time.Time { return clock }
+
+	s.RegisterTools(ctx, "s1", []ToolRecord{
+		{ToolName: "search", Description: "Search the web"},
+		{ToolName: "fetch", Description: "Fetch a URL"},
+	})
+	clock = clock.Add(time.Minute)
+	s.RegisterTools(ctx, "s1", []ToolRecord{{ToolName: "search", Description: "Search the web and the intranet"}})
+
+	timeline, err := s.ToolTimeline(ctx, "s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(timeline) != 2 || timeline[0].ToolName != "search" {
+		t.Fatalf("timeline = %+v, want search then fetch", timeline)
+	}
+	search := timeline[0]
+	if search.Description != "Search the web and the intranet" {
+		t.Errorf("description = %q, want the latest", search.Description)
+	}
+	if !search.FirstSeen.Equal(first) || !search.LastSeen.Equal(clock) {
+		t.Errorf("first_seen = %v, last_seen = %v; want %v and %v", search.FirstSeen, search.LastSeen, first, clock)
+	}
+	if search.AddedMidSession {
+		t.Error("re-listed tool flagged as added mid-session")
+	}
+	if !timeline[1].LastSeen.Equal(first) {
+		t.Errorf("fetch last_seen = %v, want %v", timeline[1].LastSeen, first)
+	}
+
+	// Analytics list each tool once, with the description it was last listed with
+	clock = clock.Add(time.Minute)
+	s.RegisterTools(ctx, "s2", []ToolRecord{{ToolName: "search", Description: "Search v3"}})
+	analytics, err := s.GetToolAnalytics(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if analytics.TotalAvailable != 2 {
+		t.Fatalf("tools = %+v, want search and fetch once each", analytics.Tools)
+	}
+	for _, ta := range analytics.Tools {
+		if ta.ToolName == "search" && ta.Description != "Search v3" {
+			t.Errorf("search description = %q, want Search v3", ta.Description)
+		}
+	}
+}
+
 func TestToolAnalyticsWithUsage(t *testing.T) {
 	s := newTestStore(t)
 	ctx := context.Background()
@@ -226,7 +300,7 @@ func TestToolAnalyticsWithUsage(t *testing.T) {
 		})
 	}
 
-	time.Sleep(700 * time.Millisecond)
+	s.Flush()
 
 	analytics, err := s.GetToolAnalytics(ctx, "s1")
 	if err != nil {
@@ -251,6 +325,69 @@ func TestToolAnalyticsWithUsage(t *testing.T) {
 	}
 }
 
+func TestToolAnalyticsLatency(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	s.CreateSession(ctx, &Session{ID: "s1", StartedAt: time.Now(), Command: "test"})
+	s.RegisterTools(ctx, "s1", []ToolRecord{{ToolName: "search"}, {ToolName: "read_file"}})
+
+	// search answers in 1..20 ms; read_file's call is never answered
+	call := func(id, tool string) {
+		s.LogMessage(ctx, &LogEntry{Timestamp: time.Now(), SessionID: "s1", Direction: "host_to_server",
+			Kind: "request", Method: "tools/call", MsgID: id, ToolName: tool, Payload: `{}`})
+	}
+	for i := 1; i <= 20; i++ {
+		id := fmt.Sprint(i)
+		call(id, "search")
+		s.LogMessage(ctx, &LogEntry{Timestamp: time.Now(), SessionID: "s1", Direction: "server_to_host",
+			Kind: "response", MsgID: id, Payload: `{}`, LatencyMs: float64(i), RequestTool: "search"})
+	}
+	call("21", "read_file")
+	// Another session's calls don't count toward s1's latencies
+	s.LogMessage(ctx, &LogEntry{Timestamp: time.Now(), SessionID: "s2", Direction: "server_to_host",
+		Kind: "response", MsgID: "1", Payload: `{}`, LatencyMs: 5000, RequestTool: "search"})
+	s.Flush()
+
+	analytics, err := s.GetToolAnalytics(ctx, "s1")
+	if err != nil {
+		t.Fatalf("GetToolAnalytics failed: %v", err)
+	}
+	byName := make(map[string]ToolAnalytics)
+	for _, ta := range analytics.Tools {
+		byName[ta.ToolName] = ta
+	}
+	if got := byName["search"]; got.AvgLatencyMs != 10.5 || got.P95LatencyMs != 19 {
+		t.Errorf("search latency avg=%v p95=%v, want 10.5 and 19", got.AvgLatencyMs, got.P95LatencyMs)
+	}
+	if got := byName["read_file"]; got.AvgLatencyMs != 0 || got.P95LatencyMs != 0 {
+		t.Errorf("read_file latency avg=%v p95=%v, want none", got.AvgLatencyMs, got.P95LatencyMs)
+	}
+
+	entries, _ := s.Query(ctx, QueryFilter{SessionID: "s1", Direction: "server_to_host", Limit: 1})
+	if len(entries) != 1 || entries[0].LatencyMs == 0 {
+		t.Errorf("queried response = %+v, want its latency", entries)
+	}
+}
+
+func TestLatencyStats(t *testing.T) {
+	tests := []struct {
+		sorted   []float64
+		avg, p95 float64
+	}{
+		{nil, 0, 0},
+		{[]float64{7}, 7, 7},
+		{[]float64{1, 2, 3, 100}, 26.5, 100},
+		{[]float64{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 50}, 70.0 / 21, 1},
+	}
+	for _, tt := range tests {
+		avg, p95 := latencyStats(tt.sorted)
+		if avg != tt.avg || p95 != tt.p95 {
+			t.Errorf("latencyStats(%v) = %v, %v; want %v, %v", tt.sorted, avg, p95, tt.avg, tt.p95)
+		}
+	}
+}
+
 func TestGetToolUsageCounts(t *testing.T) {
 	s := newTestStore(t)
 	ctx := context.Background()
@@ -272,7 +409,7 @@ func TestGetToolUsageCounts(t *testing.T) {
 		})
 	}
 
-	time.Sleep(700 * time.Millisecond)
+	s.Flush()
 
 	counts, err := s.GetToolUsageCounts(ctx, 0) // all sessions
 	if err != nil {
@@ -294,3 +431,750 @@ func TestGetToolUsageCounts(t *testing.T) {
 		t.Errorf("scoped read_file count = %d, want 2", counts["read_file"])
 	}
 }
+
+func TestSessionReport(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	entries := []*LogEntry{
+		{Timestamp: time.Now(), SessionID: "s1", Direction: "host_to_server", Kind: "request", Method: "tools/call", ToolName: "read_file", Payload: `{}`, Audit: true, PolicyAction: "audit"},
+		{Timestamp: time.Now(), SessionID: "s1", Direction: "host_to_server", Kind: "request", Method: "tools/call", ToolName: "read_file", Payload: `{}`},
+		{Timestamp: time.Now(), SessionID: "s1", Direction: "host_to_server", Kind: "request", Method: "tools/call", ToolName: "run_shell", Payload: `{}`, Blocked: true, PolicyAction: "deny"},
+		{Timestamp: time.Now(), SessionID: "s1", Direction: "host_to_server", Kind: "request", Method: "tools/call", ToolName: "delete_file", Payload: `{}`, PolicyAction: "require_approval"},
+		{Timestamp: time.Now(), SessionID: "s1", Direction: "host_to_server", Kind: "request", Method: "tools/call", ToolName: "delete_file", Payload: `{}`, Blocked: true, PolicyAction: "require_approval"},
+		{Timestamp: time.Now(), SessionID: "s1", Direction: "host_to_server", Kind: "request", Method: "tools/list", Payload: `{}`},
+		{Timestamp: time.Now(), SessionID: "s1", Direction: "server_to_host", Kind: "response", Payload: `{}`},
+		{Timestamp: time.Now(), SessionID: "s2", Direction: "host_to_server", Kind: "request", Method: "tools/call", ToolName: "read_file", Payload: `{}`},
+	}
+	for _, e := range entries {
+		s.LogMessage(ctx, e)
+	}
+	s.Flush()
+
+	report, err := s.SessionReport(ctx, "s1")
+	if err != nil {
+		t.Fatalf("SessionReport failed: %v", err)
+	}
+
+	tools := make(map[string]ActionCounts)
+	for _, c := range report.Tools {
+		tools[c.Name] = c
+	}
+	if len(tools) != 3 {
+		t.Fatalf("expected 3 tools, got %d", len(tools))
+	}
+	if c := tools["read_file"]; c.Total != 2 || c.Allowed != 1 || c.Audited != 1 || c.Denied != 0 {
+		t.Errorf("read_file = %+v", c)
+	}
+	if c := tools["run_shell"]; c.Total != 1 || c.Denied != 1 || c.Allowed != 0 {
+		t.Errorf("run_shell = %+v", c)
+	}
+	// Denied by the reviewer counts as denied only
+	if c := tools["delete_file"]; c.Total != 2 || c.RequireApproval != 1 || c.Allowed != 0 || c.Denied != 1 {
+		t.Errorf("delete_file = %+v", c)
+	}
+
+	methods := make(map[string]ActionCounts)
+	for _, c := range report.Methods {
+		methods[c.Name] = c
+	}
+	if c := methods["tools/call"]; c.Total != 5 || c.Denied != 2 || c.RequireApproval != 1 || c.Audited != 1 || c.Allowed != 1 {
+		t.Errorf("tools/call = %+v", c)
+	}
+	for _, c := range append(report.Tools, report.Methods...) {
+		if c.Allowed+c.Denied+c.RequireApproval+c.Audited != c.Total {
+			t.Errorf("%s outcomes don't add up to its total: %+v", c.Name, c)
+		}
+	}
+	if methods["tools/list"].Total != 1 {
+		t.Errorf("tools/list = %+v", methods["tools/list"])
+	}
+}
+
+func TestGetSession(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	started := time.Now().UTC().Truncate(time.Millisecond)
+	if err := s.CreateSession(ctx, &Session{ID: "sess-1", StartedAt: started, Command: "node", Args: []string{"server.js"}}); err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+
+	got, err := s.GetSession(ctx, "sess-1")
+	if err != nil {
+		t.Fatalf("GetSession failed: %v", err)
+	}
+	if got.Command != "node" || len(got.Args) != 1 || got.Args[0] != "server.js" {
+		t.Errorf("session = %+v, want command node with args [server.js]", got)
+	}
+	if !got.StartedAt.Equal(started) {
+		t.Errorf("started_at = %v, want %v", got.StartedAt, started)
+	}
+	if got.EndedAt != nil {
+		t.Errorf("ended_at = %v, want nil", got.EndedAt)
+	}
+
+	if _, err := s.GetSession(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetSession(missing) err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestBlockedLeaderboard(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	blocked := func(session, tool string, rules ...string) *LogEntry {
+		return &LogEntry{Timestamp: time.Now(), SessionID: session, Direction: "host_to_server", Kind: "request",
+			Method: "tools/call", ToolName: tool, Payload: `{}`, Blocked: true, MatchedRules: rules}
+	}
+	entries := []*LogEntry{
+		blocked("s1", "run_shell", "block-shell", "audit-all"),
+		blocked("s1", "run_shell", "block-shell"),
+		blocked("s1", "run_shell", "block-shell"),
+		blocked("s1", "delete_file", "approve-deletions"),
+		blocked("s1", "delete_file", "block-writes", "approve-deletions"),
+		blocked("s2", "run_shell", "block-shell"),
+		// Not blocked — must not be counted
+		{Timestamp: time.Now(), SessionID: "s1", Direction: "host_to_server", Kind: "request", Method: "tools/call", ToolName: "run_shell", Payload: `{}`, MatchedRules: []string{"block-shell"}},
+	}
+	for _, e := range entries {
+		s.LogMessage(ctx, e)
+	}
+	s.Flush()
+
+	stats, err := s.BlockedLeaderboard(ctx, "s1")
+	if err != nil {
+		t.Fatalf("BlockedLeaderboard failed: %v", err)
+	}
+	want := []BlockedStat{
+		{ToolName: "run_shell", Rule: "block-shell", Count: 3},
+		{ToolName: "delete_file", Rule: "approve-deletions", Count: 1},
+		{ToolName: "delete_file", Rule: "block-writes", Count: 1},
+	}
+	if len(stats) != len(want) {
+		t.Fatalf("got %d rows, want %d: %+v", len(stats), len(want), stats)
+	}
+	for i := range want {
+		if stats[i] != want[i] {
+			t.Errorf("row %d = %+v, want %+v", i, stats[i], want[i])
+		}
+	}
+
+	all, err := s.BlockedLeaderboard(ctx, "")
+	if err != nil {
+		t.Fatalf("BlockedLeaderboard (all sessions) failed: %v", err)
+	}
+	if all[0].ToolName != "run_shell" || all[0].Count != 4 {
+		t.Errorf("top across sessions = %+v, want run_shell x4", all[0])
+	}
+}
+
+func TestTokenEstimates(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	s.CreateSession(ctx, &Session{ID: "s1", StartedAt: time.Now(), Command: "test"})
+	s.RegisterTools(ctx, "s1", []ToolRecord{
+		{ToolName: "read_file", Description: "Read a file"},
+		{ToolName: "write_file", Description: "Write a file"},
+	})
+
+	for _, e := range []*LogEntry{
+		{Direction: "host_to_server", Kind: "request", Method: "tools/call", MsgID: "1", ToolName: "read_file", TokenEstimate: 10},
+		{Direction: "server_to_host", Kind: "response", MsgID: "1", RequestTool: "read_file", TokenEstimate: 200},
+		{Direction: "host_to_server", Kind: "request", Method: "tools/call", MsgID: "2", ToolName: "read_file", TokenEstimate: 12},
+		{Direction: "server_to_host", Kind: "error", MsgID: "2", RequestTool: "read_file", TokenEstimate: 8},
+		{Direction: "host_to_server", Kind: "request", Method: "tools/list", MsgID: "3", TokenEstimate: 5},
+		// An ID used again, as after a restart, must not pair with the first call
+		{Direction: "host_to_server", Kind: "request", Method: "tools/call", MsgID: "1", ToolName: "write_file", TokenEstimate: 3},
+		{Direction: "server_to_host", Kind: "response", MsgID: "1", RequestTool: "write_file", TokenEstimate: 30},
+	} {
+		e.Timestamp = time.Now()
+		e.SessionID = "s1"
+		e.Payload = "{}"
+		s.LogMessage(ctx, e)
+	}
+	s.Flush()
+
+	stats, err := s.Stats(ctx, "s1")
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	if stats.TotalTokens != 268 {
+		t.Errorf("total tokens = %d, want 268", stats.TotalTokens)
+	}
+
+	msgs, err := s.Query(ctx, QueryFilter{SessionID: "s1", MsgID: "2", Kind: "error"})
+	if err != nil || len(msgs) != 1 {
+		t.Fatalf("Query: got %d messages, err %v", len(msgs), err)
+	}
+	if msgs[0].TokenEstimate != 8 || msgs[0].RequestTool != "read_file" {
+		t.Errorf("stored token estimate = %d, tool %q; want 8, read_file", msgs[0].TokenEstimate, msgs[0].RequestTool)
+	}
+
+	analytics, err := s.GetToolAnalytics(ctx, "s1")
+	if err != nil {
+		t.Fatalf("GetToolAnalytics failed: %v", err)
+	}
+	got := make(map[string]int64)
+	for _, ta := range analytics.Tools {
+		got[ta.ToolName] = ta.TokenEstimate
+	}
+	if got["read_file"] != 230 {
+		t.Errorf("read_file tokens = %d, want 230 (calls plus responses)", got["read_file"])
+	}
+	if got["write_file"] != 33 {
+		t.Errorf("write_file tokens = %d, want 33", got["write_file"])
+	}
+}
+
+func TestTokenEstimates_PerSession(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	for i, session := range []string{"s1", "s2"} {
+		s.CreateSession(ctx, &Session{ID: session, StartedAt: time.Now(), Command: "test"})
+		s.RegisterTools(ctx, session, []ToolRecord{{ToolName: "read_file", Description: "Read a file"}})
+		tokens := 10 * (i + 1)
+		s.LogMessage(ctx, &LogEntry{Timestamp: time.Now(), SessionID: session, Direction: "host_to_server", Kind: "request",
+			Method: "tools/call", MsgID: "1", ToolName: "read_file", Payload: "{}", TokenEstimate: tokens})
+		s.LogMessage(ctx, &LogEntry{Timestamp: time.Now(), SessionID: session, Direction: "server_to_host", Kind: "response",
+			MsgID: "1", RequestTool: "read_file", Payload: "{}", TokenEstimate: 10 * tokens})
+	}
+	s.Flush()
+
+	for session, want := range map[string]int64{"s1": 110, "s2": 220, "": 330} {
+		analytics, err := s.GetToolAnalytics(ctx, session)
+		if err != nil {
+			t.Fatalf("GetToolAnalytics(%q) failed: %v", session, err)
+		}
+		if len(analytics.Tools) != 1 || analytics.Tools[0].TokenEstimate != want {
+			t.Errorf("GetToolAnalytics(%q) tools = %+v, want read_file with %d tokens", session, analytics.Tools, want)
+		}
+	}
+}
+
+func TestToolTimeline(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	clock := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return clock }
+
+	s.CreateSession(ctx, &Session{ID: "s1", StartedAt: time.Now(), Command: "test"})
+	// The first listing comes in two pages
+	s.RegisterTools(ctx, "s1", []ToolRecord{{ToolName: "read_file", Description: "Read a file"}})
+	clock = clock.Add(time.Second)
+	s.RegisterTools(ctx, "s1", []ToolRecord{{ToolName: "write_file", Description: "Write a file"}})
+
+	// A later tools/list (after list_changed) adds a tool and repeats an
+	// old one, which keeps its initial flag
+	clock = clock.Add(time.Minute)
+	s.RegisterTools(ctx, "s1", []ToolRecord{
+		{ToolName: "read_file", Description: "Read a file", MidSession: true},
+		{ToolName: "exfiltrate", Description: "Totally harmless", MidSession: true},
+	})
+
+	// Another session's initial list must not be flagged
+	s.RegisterTools(ctx, "s2", []ToolRecord{{ToolName: "exfiltrate"}})
+
+	timeline, err := s.ToolTimeline(ctx, "s1")
+	if err != nil {
+		t.Fatalf("ToolTimeline failed: %v", err)
+	}
+	if len(timeline) != 3 {
+		t.Fatalf("got %d entries, want 3: %+v", len(timeline), timeline)
+	}
+
+	want := []struct {
+		name string
+		mid  bool
+	}{{"read_file", false}, {"write_file", false}, {"exfiltrate", true}}
+	for i, w := range want {
+		if timeline[i].ToolName != w.name || timeline[i].AddedMidSession != w.mid {
+			t.Errorf("entry %d = %s (mid-session %v), want %s (mid-session %v)",
+				i, timeline[i].ToolName, timeline[i].AddedMidSession, w.name, w.mid)
+		}
+	}
+	if !timeline[2].FirstSeen.After(timeline[0].FirstSeen) {
+		t.Errorf("late tool first_seen %v not after initial %v", timeline[2].FirstSeen, timeline[0].FirstSeen)
+	}
+
+	all, err := s.ToolTimeline(ctx, "")
+	if err != nil {
+		t.Fatalf("ToolTimeline(all) failed: %v", err)
+	}
+	if len(all) != 4 || all[3].SessionID != "s2" || all[3].AddedMidSession {
+		t.Errorf("all-session timeline = %+v, want s2's initial tool last and unflagged", all)
+	}
+}
+
+func TestQueryOrdersBySeq(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	// Same timestamp, logged (and inserted) out of interception order, as
+	// happens when both directions log concurrently
+	ts := time.Now()
+	for _, seq := range []int64{3, 1, 4, 2} {
+		s.LogMessage(ctx, &LogEntry{Seq: seq, Timestamp: ts, SessionID: "s1", Direction: "host_to_server",
+			Kind: "request", Method: "ping", Payload: `{}`})
+	}
+	s.Flush()
+
+	entries, err := s.Query(ctx, QueryFilter{SessionID: "s1"})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	var got []int64
+	for _, e := range entries {
+		got = append(got, e.Seq)
+	}
+	if len(got) != 4 || got[0] != 4 || got[1] != 3 || got[2] != 2 || got[3] != 1 {
+		t.Errorf("seq order = %v, want [4 3 2 1]", got)
+	}
+}
+
+func TestUnrecognizedMethods(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	t0 := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	msg := func(session, dir, method string, at time.Time, unrecognized bool) *LogEntry {
+		return &LogEntry{Timestamp: at, SessionID: session, Direction: dir, Kind: "request",
+			Method: method, Payload: `{}`, Unrecognized: unrecognized}
+	}
+	for _, e := range []*LogEntry{
+		msg("s1", "host_to_server", "tools/teleport", t0, true),
+		msg("s1", "host_to_server", "tools/teleport", t0.Add(time.Minute), true),
+		msg("s1", "server_to_host", "x/ping", t0, true),
+		msg("s1", "host_to_server", "tools/call", t0, false),
+		msg("s2", "host_to_server", "x/other", t0, true),
+	} {
+		s.LogMessage(ctx, e)
+	}
+	s.Flush()
+
+	stats, err := s.UnrecognizedMethods(ctx, "s1")
+	if err != nil {
+		t.Fatalf("UnrecognizedMethods failed: %v", err)
+	}
+	if len(stats) != 2 {
+		t.Fatalf("got %d rows, want 2: %+v", len(stats), stats)
+	}
+	if stats[0].Method != "tools/teleport" || stats[0].Count != 2 || !stats[0].LastSeen.Equal(t0.Add(time.Minute)) {
+		t.Errorf("row 0 = %+v, want tools/teleport twice, last at %v", stats[0], t0.Add(time.Minute))
+	}
+	if stats[1].Method != "x/ping" || stats[1].Direction != "server_to_host" {
+		t.Errorf("row 1 = %+v, want x/ping from the server", stats[1])
+	}
+
+	all, _ := s.UnrecognizedMethods(ctx, "")
+	if len(all) != 3 {
+		t.Errorf("got %d rows across sessions, want 3", len(all))
+	}
+
+	logged, _ := s.Query(ctx, QueryFilter{SessionID: "s1", Method: "x/ping"})
+	if len(logged) != 1 || !logged[0].Unrecognized {
+		t.Errorf("queried entry = %+v, want the unrecognized flag", logged)
+	}
+}
+
+func TestToolConflicts(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	s.RecordToolConflicts(ctx, "s1", []ToolConflict{{ToolName: "search", Copies: 2}})
+	s.RecordToolConflicts(ctx, "s1", []ToolConflict{{ToolName: "search", Copies: 3}})
+	s.RecordToolConflicts(ctx, "s2", []ToolConflict{{ToolName: "read", Copies: 2}})
+
+	got, err := s.ToolConflicts(ctx, "s1")
+	if err != nil {
+		t.Fatalf("ToolConflicts failed: %v", err)
+	}
+	if len(got) != 1 || got[0].ToolName != "search" || got[0].Copies != 3 || got[0].SessionID != "s1" || got[0].LastSeen.IsZero() {
+		t.Errorf("s1 conflicts = %+v, want search with the latest count", got)
+	}
+
+	all, _ := s.ToolConflicts(ctx, "")
+	if len(all) != 2 {
+		t.Errorf("got %d conflicts across sessions, want 2", len(all))
+	}
+}
+
+func TestCreateSession_Resume(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	first := time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+
+	s.CreateSession(ctx, &Session{ID: "stable", StartedAt: first, Command: "server", Args: []string{"-v"}})
+	s.LogMessage(ctx, &LogEntry{Timestamp: first, SessionID: "stable", Direction: "host_to_server", Kind: "request", Method: "initialize", MsgID: "1", Payload: `{}`})
+	s.Flush()
+	s.EndSession(ctx, "stable")
+
+	// The server restarts under the same ID
+	if err := s.CreateSession(ctx, &Session{ID: "stable", StartedAt: time.Now(), Command: "server", Args: []string{"-v", "-x"}}); err != nil {
+		t.Fatalf("resuming session: %v", err)
+	}
+	s.LogMessage(ctx, &LogEntry{Timestamp: time.Now(), SessionID: "stable", Direction: "host_to_server", Kind: "request", Method: "initialize", MsgID: "1", Payload: `{}`})
+	s.Flush()
+
+	sess, err := s.GetSession(ctx, "stable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sess.StartedAt.Equal(first) || sess.EndedAt != nil || len(sess.Args) != 2 {
+		t.Errorf("session = %+v, want the first start time, running, with the new args", sess)
+	}
+	entries, _ := s.Query(ctx, QueryFilter{SessionID: "stable"})
+	if len(entries) != 2 {
+		t.Errorf("session has %d messages, want both runs' messages", len(entries))
+	}
+}
+
+func TestAnnotateMessage(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	for i := range 3 {
+		s.LogMessage(ctx, &LogEntry{Timestamp: time.Now(), SessionID: "s1", Direction: "host_to_server", Kind: "request",
+			Method: "tools/call", MsgID: fmt.Sprint(i), Payload: `{}`})
+	}
+	s.Flush()
+
+	if err := s.AnnotateMessage(ctx, 2, " looks like exfil ", []string{"suspicious", " review", "suspicious", ""}); err != nil {
+		t.Fatalf("AnnotateMessage: %v", err)
+	}
+	s.AnnotateMessage(ctx, 3, "", []string{"review"})
+
+	entry, _ := s.GetMessage(ctx, 2)
+	if entry.Note != "looks like exfil" || !slices.Equal(entry.Tags, []string{"suspicious", "review"}) {
+		t.Errorf("note = %q, tags = %v", entry.Note, entry.Tags)
+	}
+
+	tagged, err := s.Query(ctx, QueryFilter{Tag: "review"})
+	if err != nil || len(tagged) != 2 {
+		t.Fatalf("Query(tag=review) = %d entries, %v; want 2", len(tagged), err)
+	}
+	if tagged, _ := s.Query(ctx, QueryFilter{Tag: "suspicious"}); len(tagged) != 1 || tagged[0].ID != 2 {
+		t.Errorf("Query(tag=suspicious) = %+v, want message 2", tagged)
+	}
+
+	// Clearing removes both
+	s.AnnotateMessage(ctx, 2, "", nil)
+	if entry, _ := s.GetMessage(ctx, 2); entry.Note != "" || entry.Tags != nil {
+		t.Errorf("after clearing: note = %q, tags = %v", entry.Note, entry.Tags)
+	}
+
+	if err := s.AnnotateMessage(ctx, 99, "x", nil); !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing message: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestApprovalReason(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	decided := time.Now()
+	s.LogApproval(ctx, &ApprovalRecord{ID: "a1", Timestamp: decided.Add(-time.Second), SessionID: "s1", Decision: "timeout", DecidedAt: &decided, Reason: "timeout"})
+	s.LogApproval(ctx, &ApprovalRecord{ID: "a2", Timestamp: decided, SessionID: "s1", Decision: "approved"})
+
+	approvals, err := s.GetApprovals(ctx, "s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reasons := map[string]string{}
+	for _, a := range approvals {
+		reasons[a.ID] = a.Reason
+	}
+	if reasons["a1"] != "timeout" || reasons["a2"] != "" {
+		t.Errorf("reasons = %v", reasons)
+	}
+}
+
+func TestClearMessagesAndTools(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	s.CreateSession(ctx, &Session{ID: "s1", StartedAt: time.Now(), Command: "server"})
+	for i := range 3 {
+		s.LogMessage(ctx, &LogEntry{Timestamp: time.Now(), SessionID: "s1", Direction: "host_to_server", Kind: "request",
+			Method: "tools/call", ToolName: "search", MsgID: fmt.Sprint(i), Payload: `{}`})
+	}
+	s.LogApproval(ctx, &ApprovalRecord{ID: "a1", Timestamp: time.Now(), SessionID: "s1", Decision: "approved"})
+	s.RegisterTools(ctx, "s1", []ToolRecord{{ToolName: "search"}, {ToolName: "fetch"}})
+	s.RecordToolConflicts(ctx, "s1", []ToolConflict{{ToolName: "search", Copies: 2}})
+
+	// Buffered messages are cleared too
+	n, err := s.ClearMessages(ctx)
+	if err != nil || n != 3 {
+		t.Fatalf("ClearMessages = %d, %v; want 3", n, err)
+	}
+	if entries, _ := s.Query(ctx, QueryFilter{}); len(entries) != 0 {
+		t.Errorf("%d messages left", len(entries))
+	}
+	if approvals, _ := s.GetApprovals(ctx, ""); len(approvals) != 0 {
+		t.Errorf("%d approvals left", len(approvals))
+	}
+	if stats, _ := s.Stats(ctx, ""); stats.TotalMessages != 0 {
+		t.Errorf("stats total = %d, want 0", stats.TotalMessages)
+	}
+	if analytics, _ := s.GetToolAnalytics(ctx, ""); analytics.TotalAvailable != 2 {
+		t.Errorf("tool registry cleared with the messages")
+	}
+	if _, err := s.GetSession(ctx, "s1"); err != nil {
+		t.Errorf("session removed: %v", err)
+	}
+
+	n, err = s.ClearToolRegistry(ctx)
+	if err != nil || n != 2 {
+		t.Fatalf("ClearToolRegistry = %d, %v; want 2", n, err)
+	}
+	if analytics, _ := s.GetToolAnalytics(ctx, ""); analytics.TotalAvailable != 0 {
+		t.Errorf("%d tools left", analytics.TotalAvailable)
+	}
+	if conflicts, _ := s.ToolConflicts(ctx, ""); len(conflicts) != 0 {
+		t.Errorf("%d conflicts left", len(conflicts))
+	}
+}
+
+func TestMaxMessagesPerSession(t *testing.T) {
+	s := newTestStore(t)
+	s.MaxMessagesPerSession = 3
+	ctx := context.Background()
+	log := func(session string, i int, e LogEntry) {
+		e.Timestamp, e.SessionID, e.Direction, e.Kind, e.Method, e.MsgID, e.Payload =
+			time.Now(), session, "host_to_server", "request", "tools/call", fmt.Sprint(i), `{}`
+		s.LogMessage(ctx, &e)
+	}
+	// Old flagged messages survive the cap
+	log("s1", 0, LogEntry{Blocked: true})
+	log("s1", 1, LogEntry{Audit: true})
+	log("s1", 2, LogEntry{ScrubCount: 1})
+	for i := 3; i < 10; i++ {
+		log("s1", i, LogEntry{})
+	}
+	log("s2", 0, LogEntry{})
+	s.Flush()
+	// Trimming also runs on later batches
+	log("s1", 10, LogEntry{})
+	s.Flush()
+
+	entries, err := s.Query(ctx, QueryFilter{SessionID: "s1", Limit: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, e := range entries {
+		ids = append(ids, e.MsgID)
+	}
+	slices.Sort(ids)
+	if want := []string{"0", "1", "10", "2", "8", "9"}; !slices.Equal(ids, want) {
+		t.Errorf("kept %v, want %v", ids, want)
+	}
+	if entries, _ := s.Query(ctx, QueryFilter{SessionID: "s2"}); len(entries) != 1 {
+		t.Errorf("s2 has %d messages, want 1", len(entries))
+	}
+
+	// Annotated messages survive too
+	byMsgID := make(map[string]int64)
+	for _, e := range entries {
+		byMsgID[e.MsgID] = e.ID
+	}
+	if err := s.AnnotateMessage(ctx, byMsgID["8"], "", []string{"suspicious"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AnnotateMessage(ctx, byMsgID["9"], "check this", nil); err != nil {
+		t.Fatal(err)
+	}
+	for i := 11; i < 14; i++ {
+		log("s1", i, LogEntry{})
+	}
+	s.Flush()
+	entries, err = s.Query(ctx, QueryFilter{SessionID: "s1", Limit: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids = nil
+	for _, e := range entries {
+		ids = append(ids, e.MsgID)
+	}
+	slices.Sort(ids)
+	if want := []string{"0", "1", "11", "12", "13", "2", "8", "9"}; !slices.Equal(ids, want) {
+		t.Errorf("after annotating, kept %v, want %v", ids, want)
+	}
+}
+
+func TestMaxMessagesPerSession_KeepsPolicyFlagged(t *testing.T) {
+	s := newTestStore(t)
+	s.MaxMessagesPerSession = 3
+	ctx := context.Background()
+	log := func(i int, e LogEntry) {
+		e.Timestamp, e.SessionID, e.Direction, e.Kind, e.Method, e.MsgID, e.Payload =
+			time.Now(), "s1", "host_to_server", "request", "tools/call", fmt.Sprint(i), `{}`
+		s.LogMessage(ctx, &e)
+	}
+	// A quarantined message is neither blocked nor audited
+	log(0, LogEntry{PolicyAction: "quarantine"})
+	log(1, LogEntry{MatchedRules: []string{"watch-shell"}})
+	log(2, LogEntry{Unrecognized: true})
+	log(3, LogEntry{ToolsPruned: 2})
+	for i := 4; i < 10; i++ {
+		log(i, LogEntry{})
+	}
+	s.Flush()
+
+	entries, err := s.Query(ctx, QueryFilter{SessionID: "s1", Limit: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, e := range entries {
+		ids = append(ids, e.MsgID)
+	}
+	slices.Sort(ids)
+	if want := []string{"0", "1", "2", "3", "7", "8", "9"}; !slices.Equal(ids, want) {
+		t.Errorf("kept %v, want %v", ids, want)
+	}
+}
+
+func TestBufferFill(t *testing.T) {
+	// No writer draining the buffer
+	s := &SQLiteStore{writeCh: make(chan *LogEntry, 4)}
+	if fill := s.BufferFill(); fill != 0 {
+		t.Errorf("empty buffer fill = %v, want 0", fill)
+	}
+	s.LogMessage(context.Background(), &LogEntry{})
+	s.LogMessage(context.Background(), &LogEntry{})
+	if fill := s.BufferFill(); fill != 0.5 {
+		t.Errorf("fill = %v, want 0.5", fill)
+	}
+}
+
+func TestQueryByArgument(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	for i, args := range []string{
+		`{"path":"/etc/passwd","opts":{"mode":"r"}}`,
+		`{"path":"/etc/hosts","opts":{"mode":"w"}}`,
+		`{"path":"/home/alice/.etc","limit":5}`,
+		``,
+	} {
+		s.LogMessage(ctx, &LogEntry{Timestamp: time.Now(), SessionID: "s1", Direction: "host_to_server", Kind: "request",
+			Method: "tools/call", ToolName: "read_file", MsgID: fmt.Sprint(i), Payload: `{}`, Arguments: json.RawMessage(args)})
+	}
+	s.Flush()
+
+	ids := func(args map[string]string) []string {
+		t.Helper()
+		entries, err := s.Query(ctx, QueryFilter{Args: args})
+		if err != nil {
+			t.Fatalf("Query(%v): %v", args, err)
+		}
+		var out []string
+		for _, e := range entries {
+			out = append(out, e.MsgID)
+		}
+		slices.Sort(out)
+		return out
+	}
+
+	tests := []struct {
+		args map[string]string
+		want []string
+	}{
+		{map[string]string{"path": "/etc/*"}, []string{"0", "1"}},
+		{map[string]string{"path": "/etc/hosts"}, []string{"1"}},
+		{map[string]string{"path": "/ETC/*"}, nil},
+		{map[string]string{"path": "/etc/*", "opts.mode": "w"}, []string{"1"}},
+		{map[string]string{"limit": "5"}, []string{"2"}},
+		{map[string]string{"path": "*"}, []string{"0", "1", "2"}},
+		{map[string]string{"missing": "*"}, nil},
+	}
+	for _, tt := range tests {
+		if got := ids(tt.args); !slices.Equal(got, tt.want) {
+			t.Errorf("Query(%v) = %v, want %v", tt.args, got, tt.want)
+		}
+	}
+
+	e, _ := s.GetMessage(ctx, 1)
+	if string(e.Arguments) != `{"path":"/etc/passwd","opts":{"mode":"r"}}` {
+		t.Errorf("stored arguments = %s", e.Arguments)
+	}
+	if e, _ := s.GetMessage(ctx, 4); e.Arguments != nil {
+		t.Errorf("message without arguments has %s", e.Arguments)
+	}
+}
+
+func TestGetToolArgUsage(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	clock := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return clock }
+
+	// Without a registered schema, keys are counted but not compared
+	s.LogMessage(ctx, &LogEntry{Timestamp: clock, SessionID: "s1", Direction: "host_to_server", Kind: "request",
+		Method: "tools/call", ToolName: "read_file", MsgID: "0", Payload: `{}`, Arguments: json.RawMessage(`{"path":"/a"}`)})
+	s.Flush()
+	usage, err := s.GetToolArgUsage(ctx, "", "read_file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.Declared != nil || usage.Calls != 1 || len(usage.Args) != 1 || len(usage.Undocumented) != 0 {
+		t.Errorf("usage = %+v, want one call sending path and nothing to compare", usage)
+	}
+
+	s.RegisterTools(ctx, "s1", []ToolRecord{{ToolName: "read_file", InputProperties: []string{"path", "encoding"}}})
+	clock = clock.Add(time.Minute)
+	// The latest registration wins
+	s.RegisterTools(ctx, "s2", []ToolRecord{{ToolName: "read_file", InputProperties: []string{"path", "encoding", "limit"}}})
+	for i, args := range []string{
+		`{"path":"/b","limit":5}`,
+		`{"path":"/c","follow_symlinks":true}`,
+		`{"path":"/d","limit":1,"follow_symlinks":false}`,
+		``, // payload not stored
+	} {
+		s.LogMessage(ctx, &LogEntry{Timestamp: clock, SessionID: "s2", Direction: "host_to_server", Kind: "request",
+			Method: "tools/call", ToolName: "read_file", MsgID: fmt.Sprint(i + 1), Payload: `{}`, Arguments: json.RawMessage(args)})
+	}
+	s.LogMessage(ctx, &LogEntry{Timestamp: clock, SessionID: "s2", Direction: "host_to_server", Kind: "request",
+		Method: "tools/call", ToolName: "write_file", MsgID: "9", Payload: `{}`, Arguments: json.RawMessage(`{"content":"x"}`)})
+	s.Flush()
+
+	usage, err = s.GetToolArgUsage(ctx, "", "read_file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ArgKeyUsage{
+		{Key: "follow_symlinks", Calls: 2},
+		{Key: "limit", Calls: 2, Declared: true},
+		{Key: "path", Calls: 4, Declared: true},
+	}
+	if !slices.Equal(usage.Args, want) {
+		t.Errorf("args = %+v, want %+v", usage.Args, want)
+	}
+	if usage.Calls != 4 {
+		t.Errorf("calls = %d, want 4", usage.Calls)
+	}
+	if !slices.Equal(usage.Declared, []string{"path", "encoding", "limit"}) {
+		t.Errorf("declared = %v", usage.Declared)
+	}
+	if !slices.Equal(usage.Undocumented, []string{"follow_symlinks"}) || !slices.Equal(usage.Unused, []string{"encoding"}) {
+		t.Errorf("undocumented = %v, unused = %v; want [follow_symlinks] and [encoding]", usage.Undocumented, usage.Unused)
+	}
+
+	if usage.DeclaredAt == nil || !usage.DeclaredAt.Equal(clock) {
+		t.Errorf("declared at %v, want %v", usage.DeclaredAt, clock)
+	}
+
+	// One session's calls, against that session's declaration
+	usage, err = s.GetToolArgUsage(ctx, "s1", "read_file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(usage.Declared, []string{"path", "encoding"}) || usage.Calls != 1 ||
+		!slices.Equal(usage.Args, []ArgKeyUsage{{Key: "path", Calls: 1, Declared: true}}) {
+		t.Errorf("s1 usage = %+v, want one call against s1's schema", usage)
+	}
+
+	usage, err = s.GetToolArgUsage(ctx, "", "unknown_tool")
+	if err != nil || usage.Calls != 0 || len(usage.Args) != 0 {
+		t.Errorf("unknown tool: %+v, %v", usage, err)
+	}
+}
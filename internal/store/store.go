@@ -1,27 +1,50 @@
 package store
 
-import "context"
+import (
+	"context"
+	"errors"
+)
+
+// ErrNotFound is returned when a requested record does not exist.
+var ErrNotFound = errors.New("not found")
 
 // Store is the persistence interface for MCP message logging.
 type Store interface {
 	// LogMessage persists a message asynchronously (buffered).
 	LogMessage(ctx context.Context, entry *LogEntry) error
 
+	// BufferFill reports how full the LogMessage buffer is, from 0 to 1.
+	// Messages are dropped once it reaches 1.
+	BufferFill() float64
+
 	// Query retrieves messages matching the filter, ordered by timestamp desc.
 	Query(ctx context.Context, filter QueryFilter) ([]LogEntry, error)
 
 	// GetMessage retrieves a single message by ID.
 	GetMessage(ctx context.Context, id int64) (*LogEntry, error)
 
+	// AnnotateMessage sets a message's triage note and tags, replacing
+	// earlier ones. Returns ErrNotFound if the message doesn't exist.
+	AnnotateMessage(ctx context.Context, id int64, note string, tags []string) error
+
 	// Stats returns aggregate statistics, optionally filtered by session.
 	Stats(ctx context.Context, sessionID string) (*Stats, error)
 
-	// CreateSession records a new proxy session.
+	// CreateSession records a proxy session. Reusing the ID of an
+	// earlier session resumes it: its start time is kept and it is
+	// marked as running again.
 	CreateSession(ctx context.Context, session *Session) error
 
+	// SetSessionClient records the host application of a session, for
+	// clients identified only once they send initialize.
+	SetSessionClient(ctx context.Context, sessionID, client string) error
+
 	// EndSession marks a session as ended.
 	EndSession(ctx context.Context, sessionID string) error
 
+	// GetSession retrieves a session by ID. Returns ErrNotFound if it doesn't exist.
+	GetSession(ctx context.Context, sessionID string) (*Session, error)
+
 	// LogApproval records an approval decision.
 	LogApproval(ctx context.Context, record *ApprovalRecord) error
 
@@ -29,14 +52,49 @@ type Store interface {
 	GetApprovals(ctx context.Context, sessionID string) ([]ApprovalRecord, error)
 
 	// RegisterTools records tools from a tools/list response for a session.
+	// A tool listed again keeps its first_seen and takes the new description.
 	RegisterTools(ctx context.Context, sessionID string, tools []ToolRecord) error
 
+	// ToolTimeline lists tools in the order they first appeared, flagging
+	// those added after the initial tool list. Empty sessionID covers all sessions.
+	ToolTimeline(ctx context.Context, sessionID string) ([]ToolTimelineEntry, error)
+
+	// GetToolArgUsage compares a tool's declared arguments with the keys
+	// sent in its logged calls. Empty sessionID covers all sessions.
+	GetToolArgUsage(ctx context.Context, sessionID, tool string) (*ToolArgUsage, error)
+
 	// GetToolAnalytics computes tool analytics across sessions.
 	GetToolAnalytics(ctx context.Context, sessionID string) (*ToolAnalyticsSummary, error)
 
 	// GetToolUsageCounts returns per-tool call counts within recent sessions.
 	GetToolUsageCounts(ctx context.Context, lastNSessions int) (map[string]int, error)
 
+	// SessionReport returns per-tool and per-method policy outcome counts for a session.
+	SessionReport(ctx context.Context, sessionID string) (*SessionReport, error)
+
+	// BlockedLeaderboard ranks blocked messages by tool and blocking rule,
+	// optionally filtered by session.
+	BlockedLeaderboard(ctx context.Context, sessionID string) ([]BlockedStat, error)
+
+	// UnrecognizedMethods counts messages flagged as using a method outside
+	// the known set, optionally filtered by session.
+	UnrecognizedMethods(ctx context.Context, sessionID string) ([]MethodStat, error)
+
+	// RecordToolConflicts records tool names listed more than once in a
+	// session's tools/list response.
+	RecordToolConflicts(ctx context.Context, sessionID string, conflicts []ToolConflict) error
+
+	// ToolConflicts lists recorded duplicate tool names, optionally filtered by session.
+	ToolConflicts(ctx context.Context, sessionID string) ([]ToolConflict, error)
+
+	// ClearMessages deletes all logged messages and approval records and
+	// returns how many messages were deleted.
+	ClearMessages(ctx context.Context) (int64, error)
+
+	// ClearToolRegistry deletes all registered tools and tool conflicts and
+	// returns how many tools were deleted.
+	ClearToolRegistry(ctx context.Context) (int64, error)
+
 	// Close flushes pending writes and closes the store.
 	Close() error
 }
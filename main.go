@@ -2,19 +2,26 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/contextgate/contextgate/internal/cli"
+	"github.com/contextgate/contextgate/internal/control"
 	"github.com/contextgate/contextgate/internal/dashboard"
 	"github.com/contextgate/contextgate/internal/eventbus"
+	"github.com/contextgate/contextgate/internal/inspect"
 	"github.com/contextgate/contextgate/internal/policy"
 	"github.com/contextgate/contextgate/internal/proxy"
 	"github.com/contextgate/contextgate/internal/store"
@@ -24,10 +31,15 @@ var version = "dev"
 
 func main() {
 	// Check for subcommands before flag parsing
+	inspectMode := false
 	if len(os.Args) > 1 {
 		switch os.Args[1] {
+		case "inspect":
+			// Proxy mode with a terminal UI; the remaining args are parsed as usual
+			inspectMode = true
+			os.Args = append([]string{os.Args[0]}, os.Args[2:]...)
 		case "setup":
-			if err := cli.RunSetup(); err != nil {
+			if err := cli.RunSetup(os.Args[2:]); err != nil {
 				fmt.Fprintf(os.Stderr, "error: %v\n", err)
 				os.Exit(1)
 			}
@@ -38,6 +50,24 @@ func main() {
 				os.Exit(1)
 			}
 			return
+		case "policy":
+			if err := cli.RunPolicy(os.Args[2:], defaultPolicyPath()); err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		case "db":
+			if err := cli.RunDB(os.Args[2:], defaultDBPath()); err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		case "golden":
+			if err := cli.RunGolden(os.Args[2:], defaultDBPath()); err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(1)
+			}
+			return
 		case "version":
 			fmt.Fprintf(os.Stderr, "contextgate %s\n", version)
 			return
@@ -51,14 +81,80 @@ func main() {
 	proxyFlags := flag.NewFlagSet("proxy", flag.ExitOnError)
 	dashAddr := proxyFlags.String("dashboard", ":9000", "dashboard listen address (empty to disable)")
 	dbPath := proxyFlags.String("db", defaultDBPath(), "SQLite database path")
+	dbPerSession := proxyFlags.Bool("db-per-session", false, "store each session in its own database under a sessions/ directory next to -db")
+	dbKeyFile := proxyFlags.String("db-encryption-key-file", "", "file holding a 32-byte key (base64 or hex) to encrypt stored payloads with; defaults to $"+store.PayloadKeyEnv)
+	maxSessionMessages := proxyFlags.Int("max-messages-per-session", 0, "keep only the newest N messages of each session, plus any flagged or annotated ones (0 = unlimited)")
 	logLevel := proxyFlags.String("log-level", "info", "log level (debug, info, warn, error)")
+	sessionLogLevel := proxyFlags.String("session-log-level", "", "per-session log levels as key=level pairs, where key is a session ID or the server command name (e.g. flaky-server=debug)")
 	noBrowser := proxyFlags.Bool("no-browser", false, "don't auto-open the dashboard in a browser")
+	sseHeartbeat := proxyFlags.Duration("sse-heartbeat", dashboard.DefaultSSEHeartbeat, "interval between keep-alive comments on the dashboard live stream (0 = off)")
+	logBackpressure := proxyFlags.Duration("log-backpressure", 0, "longest to hold a message while the log write buffer is above -log-high-water, instead of dropping log entries (0 disables)")
+	logHighWater := proxyFlags.Float64("log-high-water", proxy.DefaultHighWater, "log write buffer fill level (0-1) at which -log-backpressure engages")
+	logMaxPayload := proxyFlags.Int("log-max-payload-bytes", 0, "truncate logged payloads beyond this many bytes; forwarded messages are unchanged (0 = no limit)")
+	logBinary := proxyFlags.String("log-binary", "placeholder", "how binary payloads are logged: placeholder or base64")
+	dashTLSCert := proxyFlags.String("dashboard-tls-cert", "", "TLS certificate file for serving the dashboard over HTTPS")
+	dashTLSKey := proxyFlags.String("dashboard-tls-key", "", "TLS private key file for serving the dashboard over HTTPS")
+	metricsSizeBuckets := proxyFlags.String("metrics-size-buckets", "", "comma-separated upper bounds in bytes for the /metrics message size histogram")
+	metricsLatencyBuckets := proxyFlags.String("metrics-latency-buckets", "", "comma-separated upper bounds (e.g. 10ms,1s) for the /metrics response latency histogram")
+	dashAdminToken := proxyFlags.String("dashboard-admin-token", "", "bearer token enabling the dashboard's destructive /api/admin/ endpoints (disabled when empty)")
+	controlSocket := proxyFlags.String("control-socket", "", "path of a Unix socket serving a JSON control API (stats, approvals, pause/resume)")
+	dashCORSOrigin := proxyFlags.String("dashboard-cors-origin", "", "comma-separated origins allowed to call the dashboard's /api/ endpoints from a browser (* for any)")
+	dashAssets := proxyFlags.String("dashboard-assets", "", "directory of static files and templates overriding the dashboard's built-in ones")
+	dashTLSSelfSigned := proxyFlags.Bool("dashboard-tls-selfsigned", false, "serve the dashboard over HTTPS with a generated self-signed certificate")
 	policyPath := proxyFlags.String("policy", "", "path to security policy YAML file")
+	policyInline := proxyFlags.String("policy-inline", "", "security policy YAML given directly on the command line")
+	policyCSV := proxyFlags.String("policy-csv", "", "path to a CSV/TSV file of policy rules (name,action,methods,tools,pattern)")
+	denyTools := proxyFlags.String("deny-tools", "", "comma-separated tools whose calls are always denied, merged into the policy")
+	requireApprovalTools := proxyFlags.String("require-approval-tools", "", "comma-separated tools whose calls need approval, merged into the policy")
+	auditTools := proxyFlags.String("audit-tools", "", "comma-separated tools whose calls are flagged for audit, merged into the policy")
+	blocklistURL := proxyFlags.String("blocklist-url", "", "URL of a tool blocklist merged into the policy as deny rules")
+	blocklistFile := proxyFlags.String("blocklist-file", "", "path to a tool blocklist merged into the policy as deny rules")
+	blocklistRefresh := proxyFlags.Duration("blocklist-refresh", policy.DefaultBlocklistRefresh, "how often the blocklist is re-fetched")
 	scrubPII := proxyFlags.Bool("scrub-pii", false, "enable PII scrubbing in responses")
+	scrubProfile := proxyFlags.Bool("scrub-profile", false, "time each scrubber pattern and report the slowest at exit and on /api/debug/scrubber")
 	approvalTimeout := proxyFlags.Duration("approval-timeout", 60*time.Second, "timeout for approval requests")
+	blockAlert := proxyFlags.String("block-alert", "", "send an alert for every blocked message to stderr, a file path, or an http(s) webhook URL")
+	otelLogs := proxyFlags.String("otel-logs", "", "export policy decisions, scrubs and approval outcomes as OpenTelemetry log records to this OTLP/HTTP endpoint")
+	approvalRedact := proxyFlags.Bool("approval-redact", false, "scrub PII from payloads shown in approval requests")
+	approveChanged := proxyFlags.Bool("approve-changed-tools", false, "require approval for the next call to a tool the server added or modified mid-session")
+	approvalShutdownGrace := proxyFlags.Duration("approval-shutdown-grace", 0, "on SIGINT/SIGTERM, hold new host messages and wait this long for pending approvals before denying them")
+	approvalCancel := proxyFlags.String("approval-cancel", "error", "how a request cancelled from the dashboard is answered: error or drop")
+	toolNotice := proxyFlags.String("tool-notice", "", "notice prepended to tool results as an untrusted-content warning (empty uses a default when -tool-notice-tools is set)")
+	toolNoticeTools := proxyFlags.String("tool-notice-tools", "", "comma-separated tool names or globs whose results get the notice (default: all tools when -tool-notice is set)")
+	toolNoticeFooter := proxyFlags.String("tool-notice-footer", "", "text appended after noticed tool results, closing the wrapped content")
+	toolHints := proxyFlags.Bool("tool-hints", false, "append usage stats and policy notes to tool descriptions in tools/list responses")
+	toolHintTemplate := proxyFlags.String("tool-hint-template", "", "Go template for tool description hints, over .Name, .Calls, .Sessions, .LastUsed, .AvgLatencyMs and .Policy (implies -tool-hints)")
+	flagUnknown := proxyFlags.Bool("flag-unknown-methods", false, "audit messages whose method is not a standard MCP method")
+	envelope := proxyFlags.String("envelope", "", "check JSON-RPC envelopes (version, id type): log, fix (add a missing version) or block (default: off)")
+	knownMethods := proxyFlags.String("known-methods", "", "comma-separated methods to treat as known in addition to the standard MCP set (implies -flag-unknown-methods)")
 	pruneUnused := proxyFlags.Int("prune-unused", 0, "prune tools unused in the last N sessions (0 = disabled)")
 	pruneKeepTop := proxyFlags.Int("prune-keep-top", 0, "keep only the top K most-used tools (0 = disabled)")
-	pruneKeep := proxyFlags.String("prune-keep", "", "comma-separated tool names that should never be pruned")
+	pruneKeep := proxyFlags.String("prune-keep", "", "comma-separated tool names or globs (e.g. mcp__fs__*) that should never be pruned")
+	dedupeTools := proxyFlags.Bool("dedupe-tools", false, "drop repeated tool names from tools/list responses, keeping the first")
+	waitReady := proxyFlags.Bool("wait-ready", false, "buffer host messages until the downstream answers initialize")
+	readySignal := proxyFlags.String("ready-signal", "", "server notification method that signals readiness (default: initialize response)")
+	readyTimeout := proxyFlags.Duration("ready-timeout", 10*time.Second, "release buffered messages if the downstream isn't ready in time")
+	maxInflight := proxyFlags.Int("max-inflight", 0, "max concurrent host requests awaiting a server response (0 = unlimited)")
+	requestTimeout := proxyFlags.Duration("request-timeout", 0, "answer host requests the server hasn't responded to within this long with an error (0 = wait indefinitely)")
+	maxCalls := proxyFlags.Int("max-calls-per-session", 0, "block tools/call requests after this many in a session (0 = unlimited)")
+	once := proxyFlags.Bool("once", false, "proxy a single request and its response, then exit")
+	restartOnHup := proxyFlags.Bool("restart-on-hup", false, "on SIGHUP, also restart the server process and replay the host's initialize handshake to it")
+	onMalformed := proxyFlags.String("on-malformed", "forward", "what to do with server output that is not valid JSON: forward, drop or error")
+	allowInitRetry := proxyFlags.Bool("allow-initialize-retry", false, "keep the session open when policy refuses the host's initialize request, instead of ending it")
+	preloadTools := proxyFlags.Bool("preload-tools", false, "list the server's tools at session start so they are registered even if the host never asks")
+	rejectBusy := proxyFlags.Bool("reject-busy", false, "reject requests over -max-inflight with a busy error instead of queueing")
+	rejectDupIDs := proxyFlags.Bool("reject-duplicate-ids", false, "reject host requests that reuse the id of one still awaiting a response (default: warn and forward)")
+	rlimitNoFile := proxyFlags.Uint64("child-rlimit-nofile", 0, "max open files for the server process (0 = inherit, Linux only)")
+	rlimitAS := proxyFlags.Uint64("child-rlimit-as", 0, "max virtual memory in bytes for the server process (0 = inherit, Linux only)")
+	rlimitCPU := proxyFlags.Uint64("child-rlimit-cpu", 0, "max CPU seconds for the server process (0 = inherit, Linux only)")
+	childNice := proxyFlags.Int("child-nice", 0, "scheduling niceness for the server process (Linux only)")
+	bypassMethods := proxyFlags.String("bypass-methods", "", "comma-separated methods forwarded without running policy or any other interceptor (e.g. ping,notifications/progress)")
+	bypassLog := proxyFlags.Bool("bypass-log", true, "still log messages forwarded by -bypass-methods")
+	pipeline := proxyFlags.String("pipeline", "", "comma-separated interceptor order, overriding the policy's pipeline (logging must be last)")
+	sessionIDFlag := proxyFlags.String("session-id", "", "session ID to record under instead of a random one; reusing an ID resumes that session")
+	stableSession := proxyFlags.Bool("stable-session", false, "derive the session ID from the command, arguments and working directory, so restarts of a server share one session")
+	clientID := proxyFlags.String("client-id", "", "host application name for policy rules with a client field (default: clientInfo.name from initialize)")
+	idPrefix := proxyFlags.String("id-prefix", proxy.DefaultIDPrefix, "reserved ID prefix for requests originated by the proxy")
 	showVersion := proxyFlags.Bool("version", false, "print version and exit")
 	proxyFlags.Parse(os.Args[1:])
 
@@ -83,41 +179,204 @@ func main() {
 		os.Exit(2)
 	}
 
+	// The inspector draws on the controlling terminal, since stdin and
+	// stdout carry MCP traffic, and shows the logs in its own pane
+	var tty *os.File
+	var inspectModel *inspect.Model
+	var logOut io.Writer = os.Stderr
+	if inspectMode {
+		var err error
+		if tty, err = os.OpenFile("/dev/tty", os.O_RDWR, 0); err != nil {
+			fmt.Fprintf(os.Stderr, "error: contextgate inspect needs a terminal: %v\n", err)
+			os.Exit(2)
+		}
+		defer tty.Close()
+		inspectModel = inspect.NewModel(0)
+		logOut = inspectModel.LogWriter()
+	}
+
 	// Logger — all output goes to stderr (stdout is for MCP JSON-RPC)
 	level := parseLogLevel(*logLevel)
-	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+	if *restartOnHup && *once {
+		fmt.Fprintln(os.Stderr, "error: -restart-on-hup and -once are mutually exclusive")
+		os.Exit(2)
+	}
 
-	// Context with signal handling
-	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	sessionID := proxy.NewSessionID()
+	switch {
+	case *sessionIDFlag != "" && *stableSession:
+		fmt.Fprintln(os.Stderr, "error: -session-id and -stable-session are mutually exclusive")
+		os.Exit(2)
+	case *sessionIDFlag != "":
+		// The ID may name a per-session database file
+		if *sessionIDFlag != filepath.Base(*sessionIDFlag) || strings.HasPrefix(*sessionIDFlag, ".") {
+			fmt.Fprintf(os.Stderr, "error: invalid -session-id %q\n", *sessionIDFlag)
+			os.Exit(2)
+		}
+		sessionID = *sessionIDFlag
+	case *stableSession:
+		cwd, _ := os.Getwd()
+		sessionID = proxy.StableSessionID(cmdArgs[0], cmdArgs[1:], cwd)
+	}
+	var handler slog.Handler = slog.NewTextHandler(logOut, &slog.HandlerOptions{Level: level})
+	if *sessionLogLevel != "" {
+		levels, err := parseSessionLogLevels(*sessionLogLevel, filepath.Base(cmdArgs[0]), sessionID)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: -session-log-level: %v\n", err)
+			os.Exit(2)
+		}
+		handler = proxy.NewSessionLevelHandler(handler, level, levels)
+	}
+	logger := slog.New(handler)
+
+	// Context with signal handling. A signal ends the session once
+	// pending approvals are settled; see the shutdown handler below
+	sigCtx, stopSignals := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stopSignals()
+	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// Payload encryption, if a key is configured
+	var payloadCipher *store.PayloadCipher
+	if key, err := store.LoadPayloadKey(*dbKeyFile); err != nil {
+		logger.Error("failed to load payload encryption key", "error", err)
+		os.Exit(1)
+	} else if key != nil {
+		if payloadCipher, err = store.NewPayloadCipher(key); err != nil {
+			logger.Error("invalid payload encryption key", "error", err)
+			os.Exit(1)
+		}
+	}
+
 	// Initialize store
+	var sessionDBs *store.SessionDBs
+	if *dbPerSession {
+		dir := filepath.Join(filepath.Dir(*dbPath), "sessions")
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			logger.Error("failed to create session database directory", "error", err)
+			os.Exit(1)
+		}
+		sessionDBs = store.NewSessionDBs(dir, logger)
+		sessionDBs.Cipher = payloadCipher
+		defer sessionDBs.Close()
+		*dbPath = store.SessionDBPath(dir, sessionID)
+	}
 	sqliteStore, err := store.NewSQLiteStore(*dbPath, logger)
 	if err != nil {
 		logger.Error("failed to initialize store", "error", err)
 		os.Exit(1)
 	}
 	defer sqliteStore.Close()
+	sqliteStore.MaxMessagesPerSession = *maxSessionMessages
+	sqliteStore.Cipher = payloadCipher
 
 	// Initialize event bus
 	eb := eventbus.New(256)
 
-	// Build interceptor chain
-	var interceptors []proxy.Interceptor
+	// Build interceptors; the pipeline order decides which run and when
+	stages := make(map[string]proxy.Interceptor)
 
-	// Policy interceptor (optional — only if --policy is set)
+	// Policy interceptor (optional — only if a policy is given or installed)
 	var policyEngine *policy.Engine
 	var policyCfg *policy.Config
-	if *policyPath != "" {
+	if countSet(*policyPath, *policyInline, *policyCSV) == 0 {
+		if _, err := os.Stat(defaultPolicyPath()); err == nil {
+			*policyPath = defaultPolicyPath()
+			logger.Info("using installed policy", "path", *policyPath)
+		}
+	}
+	// Rules from -deny-tools and friends, merged into whatever policy loads
+	flagRules := policy.ToolRules(splitList(*denyTools), splitList(*requireApprovalTools), splitList(*auditTools))
+	var policyReloader *policy.Reloader
+	if *policyPath != "" || *policyInline != "" || *policyCSV != "" {
+		source := *policyPath
+		loadPolicy := func() (*policy.Config, error) { return policy.Load(*policyPath) }
 		var err error
-		policyCfg, err = policy.Load(*policyPath)
+		switch {
+		case countSet(*policyPath, *policyInline, *policyCSV) > 1:
+			err = fmt.Errorf("-policy, -policy-inline and -policy-csv are mutually exclusive")
+		case *policyPath == "-":
+			// Stdin is the host's MCP stream; consuming it here would leave
+			// nothing for the proxy
+			err = fmt.Errorf("reading policy from stdin is not supported in stdio mode; use -policy-inline")
+		case *policyInline != "":
+			source = "inline"
+			loadPolicy = func() (*policy.Config, error) { return policy.LoadBytes([]byte(*policyInline)) }
+		case *policyCSV != "":
+			source = *policyCSV
+			loadPolicy = func() (*policy.Config, error) { return policy.LoadCSV(*policyCSV) }
+		}
+		if len(flagRules) > 0 {
+			loadFile := loadPolicy
+			loadPolicy = func() (*policy.Config, error) {
+				cfg, err := loadFile()
+				if err != nil {
+					return nil, err
+				}
+				return policy.Merge(cfg, flagRules), nil
+			}
+		}
+		if err == nil {
+			policyCfg, err = loadPolicy()
+		}
 		if err != nil {
-			logger.Error("failed to load policy", "path", *policyPath, "error", err)
+			logger.Error("failed to load policy", "path", source, "error", err)
 			os.Exit(1)
 		}
 		policyEngine = policy.NewEngine(policyCfg)
-		interceptors = append(interceptors, proxy.NewPolicyInterceptor(policyEngine))
-		logger.Info("policy loaded", "path", *policyPath, "rules", len(policyCfg.Rules))
+		policyReloader = policy.NewReloader(policyEngine, policyCfg, loadPolicy, logger)
+		stages[proxy.StagePolicy] = proxy.NewPolicyInterceptor(policyEngine)
+		logger.Info("policy loaded", "path", source, "rules", len(policyCfg.Rules))
+	} else if len(flagRules) > 0 {
+		policyEngine = policy.NewEngine(&policy.Config{Version: "1", Rules: flagRules})
+		stages[proxy.StagePolicy] = proxy.NewPolicyInterceptor(policyEngine)
+	}
+
+	// External blocklist, merged into the policy and refreshed in the background
+	if *blocklistURL != "" || *blocklistFile != "" {
+		if *blocklistURL != "" && *blocklistFile != "" {
+			logger.Error("-blocklist-url and -blocklist-file are mutually exclusive")
+			os.Exit(1)
+		}
+		base := policyCfg
+		if base == nil {
+			base = &policy.Config{Version: "1", Rules: flagRules}
+		}
+		if policyEngine == nil {
+			policyEngine = policy.NewEngine(base)
+			stages[proxy.StagePolicy] = proxy.NewPolicyInterceptor(policyEngine)
+		}
+		blocklist := policy.NewBlocklist(*blocklistURL, *blocklistFile, logger)
+		blocklist.Interval = *blocklistRefresh
+		if err := blocklist.Apply(ctx, policyEngine, base); err != nil {
+			logger.Warn("initial blocklist fetch failed, retrying in background", "error", err)
+		} else {
+			logger.Info("blocklist loaded", "rules", len(policyEngine.Config().Rules)-len(base.Rules))
+		}
+		apply := func(rules []policy.Rule) { policyEngine.Swap(policy.Merge(base, rules)) }
+		if policyReloader != nil {
+			policyReloader.Blocklist = blocklist
+			apply = policyReloader.SetBlocklist
+		}
+		go blocklist.Run(ctx, apply)
+	}
+
+	// Per-session tool call budget
+	if *maxCalls > 0 {
+		stages[proxy.StageCallBudget] = proxy.NewCallBudgetInterceptor(*maxCalls)
+	}
+
+	// Change freeze: scheduled in the policy, switched from the dashboard
+	var freezeCfg policy.Freeze
+	if policyCfg != nil && policyCfg.Freeze != nil {
+		freezeCfg = *policyCfg.Freeze
+	}
+	freeze := proxy.NewFreezeInterceptor(freezeCfg)
+	stages[proxy.StageFreeze] = freeze
+
+	// Policy-defined cap on the volume of tool results a session may read
+	if policyCfg != nil && policyCfg.ExfilLimit != nil {
+		stages[proxy.StageExfilLimit] = proxy.NewExfilInterceptor(*policyCfg.ExfilLimit)
 	}
 
 	// Scrubber interceptor
@@ -127,51 +386,245 @@ func main() {
 		scrubEnabled = true
 		customPatterns = policyCfg.Scrubber.CustomPatterns
 	}
-	scrubber := proxy.NewScrubberInterceptor(scrubEnabled, customPatterns)
-	interceptors = append(interceptors, scrubber)
+	scrubber, err := proxy.NewScrubberInterceptor(scrubEnabled, customPatterns)
+	if err != nil {
+		logger.Error("invalid scrubber pattern", "error", err)
+		os.Exit(1)
+	}
+	if policyCfg != nil {
+		for name, pc := range policyCfg.Scrubber.Context {
+			if err := scrubber.RequireContext(name, pc); err != nil {
+				logger.Error("invalid scrubber context", "error", err)
+				os.Exit(1)
+			}
+		}
+	}
+	if policyCfg != nil && policyCfg.Scrubber.PreserveLength {
+		scrubber.PreserveLength = true
+		if fill := []rune(policyCfg.Scrubber.FillChar); len(fill) > 0 {
+			scrubber.Fill = fill[0]
+		}
+	}
+	scrubber.Logger = logger
+	if *scrubProfile {
+		scrubber.Profile = proxy.NewScrubProfile()
+	}
+	if policyCfg != nil {
+		scrubber.MaxDepth = policyCfg.Scrubber.MaxDepth
+	}
+	if policyReloader != nil {
+		policyReloader.OnReload = func(cfg *policy.Config) error {
+			var custom []policy.CustomPattern
+			if cfg.Scrubber.Enabled {
+				custom = cfg.Scrubber.CustomPatterns
+			}
+			if err := scrubber.SetPatterns(custom, cfg.Scrubber.Context); err != nil {
+				return err
+			}
+			scrubber.SetEnabled(*scrubPII || cfg.Scrubber.Enabled)
+			return nil
+		}
+	}
+	stages[proxy.StageScrub] = proxy.OnlyDirection(proxy.DirServerToHost, scrubber)
+
+	// Policy-defined check for secrets sent back to the server they came from
+	if policyCfg != nil && policyCfg.EchoGuard != nil {
+		echoGuard, err := proxy.NewEchoGuardInterceptor(*policyCfg.EchoGuard, scrubber)
+		if err != nil {
+			logger.Error("invalid echo guard", "error", err)
+			os.Exit(1)
+		}
+		stages[proxy.StageEchoGuard] = echoGuard
+	}
 
 	// Approval interceptor
 	approvalMgr := proxy.NewApprovalManager(*approvalTimeout)
 	approvalMgr.OnRequest = func(req *proxy.ApprovalRequest) {
-		eb.PublishApproval(&store.ApprovalEvent{
-			Type: "requested",
-			Request: &store.ApprovalRecord{
-				ID:        req.ID,
-				Timestamp: req.Timestamp,
-				SessionID: req.SessionID,
-				Direction: req.Direction,
-				Method:    req.Method,
-				ToolName:  req.ToolName,
-				RuleName:  req.RuleName,
-				Payload:   req.Payload,
-				Decision:  req.Decision,
-			},
-		})
-	}
-	interceptors = append(interceptors, proxy.NewApprovalInterceptor(approvalMgr))
-
-	// Tool analytics interceptor (tracks tools/list, optional pruning)
-	var alwaysKeep []string
-	if *pruneKeep != "" {
-		for _, name := range strings.Split(*pruneKeep, ",") {
-			name = strings.TrimSpace(name)
-			if name != "" {
-				alwaysKeep = append(alwaysKeep, name)
-			}
+		eb.PublishApproval(&store.ApprovalEvent{Type: "requested", Request: approvalRecord(req)})
+	}
+	// Set below when -otel-logs is given; nil-safe until then.
+	var events *proxy.SecurityEvents
+	approvalMgr.OnResolve = func(req *proxy.ApprovalRequest) {
+		record := approvalRecord(req)
+		if err := sqliteStore.LogApproval(context.Background(), record); err != nil {
+			logger.Warn("failed to record approval", "id", req.ID, "error", err)
 		}
+		eb.PublishApproval(&store.ApprovalEvent{Type: "resolved", Request: record})
+		events.ApprovalResolved(req)
 	}
-	toolAnalytics := proxy.NewToolAnalyticsInterceptor(sqliteStore, logger, proxy.PruneConfig{
+	approvalInterceptor := proxy.NewApprovalInterceptor(approvalMgr)
+	if *approvalRedact || (policyCfg != nil && policyCfg.Scrubber.RedactApprovals) {
+		approvalInterceptor.Redactor = scrubber
+	}
+	if policyCfg != nil {
+		approvalInterceptor.RedactArgs = policyCfg.LogRedaction
+	}
+	switch mode := policy.DenyMode(*approvalCancel); mode {
+	case policy.DenyModeError, policy.DenyModeDrop:
+		approvalInterceptor.CancelMode = mode
+	default:
+		logger.Error("invalid -approval-cancel value (want error or drop)", "value", *approvalCancel)
+		os.Exit(1)
+	}
+	stages[proxy.StageApproval] = approvalInterceptor
+
+	// Policy-defined JSON Patch rewrites of server messages
+	if policyCfg != nil && len(policyCfg.Rewrites) > 0 {
+		stages[proxy.StageRewrite] = proxy.NewRewriteInterceptor(policyCfg.Rewrites, logger)
+	}
+
+	// Untrusted-content notice on tool results
+	if *toolNotice != "" || *toolNoticeTools != "" {
+		notice := proxy.NewToolNoticeInterceptor(*toolNotice, splitList(*toolNoticeTools)...)
+		notice.Footer = *toolNoticeFooter
+		stages[proxy.StageToolNotice] = notice
+	}
+
+	// Tool analytics interceptor (tracks tools/list, optional pruning)
+	pruneCfg := proxy.PruneConfig{
 		UnusedSessions: *pruneUnused,
 		KeepTopK:       *pruneKeepTop,
-		AlwaysKeep:     alwaysKeep,
-	})
-	interceptors = append(interceptors, toolAnalytics)
+		AlwaysKeep:     splitList(*pruneKeep),
+	}
+	if policyCfg != nil {
+		// The policy's pruning defaults apply where no flag was given
+		if pruneCfg.UnusedSessions == 0 {
+			pruneCfg.UnusedSessions = policyCfg.Prune.UnusedSessions
+		}
+		if pruneCfg.KeepTopK == 0 {
+			pruneCfg.KeepTopK = policyCfg.Prune.KeepTop
+		}
+		if len(pruneCfg.AlwaysKeep) == 0 {
+			pruneCfg.AlwaysKeep = policyCfg.Prune.AlwaysKeep
+		}
+	}
+	toolAnalytics := proxy.NewToolAnalyticsInterceptor(sqliteStore, logger, pruneCfg)
+	toolAnalytics.DedupeTools = *dedupeTools
+	if *approveChanged {
+		toolAnalytics.TrackChanges = true
+		approvalInterceptor.ChangedTools = toolAnalytics
+	}
+	stages[proxy.StageToolAnalytics] = toolAnalytics
+
+	if *toolHints || *toolHintTemplate != "" {
+		hints, err := proxy.NewToolHintInterceptor(sqliteStore, *toolHintTemplate, logger)
+		if err != nil {
+			logger.Error("invalid -tool-hint-template", "error", err)
+			os.Exit(1)
+		}
+		hints.Engine = policyEngine
+		stages[proxy.StageToolHints] = hints
+	}
+
+	// Unknown method flagging (annotates only, never blocks)
+	switch mode := proxy.EnvelopeMode(*envelope); mode {
+	case "":
+	case proxy.EnvelopeLog, proxy.EnvelopeFix, proxy.EnvelopeBlock:
+		stages[proxy.StageEnvelope] = proxy.NewEnvelopeInterceptor(mode)
+	default:
+		logger.Error("invalid -envelope value (want log, fix or block)", "value", *envelope)
+		os.Exit(1)
+	}
+	if *flagUnknown || *knownMethods != "" {
+		stages[proxy.StageUnknownMethod] = proxy.NewUnknownMethodInterceptor(splitList(*knownMethods)...)
+	}
+
+	// Token estimation (after any interceptor that rewrites payloads)
+	stages[proxy.StageTokenEstimate] = proxy.NewTokenEstimateInterceptor(nil)
 
 	// Logging interceptor (always last — records final enriched state)
 	loggingInterceptor := proxy.NewLoggingInterceptor(sqliteStore, eb)
-	interceptors = append(interceptors, loggingInterceptor)
+	if policyCfg != nil {
+		loggingInterceptor.RedactArgs = policyCfg.LogRedaction
+		loggingInterceptor.PayloadRules = policyCfg.StorePayload
+	}
+	if *logHighWater <= 0 || *logHighWater > 1 {
+		logger.Error("invalid -log-high-water value (want a fill level above 0 and at most 1)", "value", *logHighWater)
+		os.Exit(1)
+	}
+	if *logMaxPayload < 0 {
+		logger.Error("invalid -log-max-payload-bytes value (want 0 or more)", "value", *logMaxPayload)
+		os.Exit(1)
+	}
+	loggingInterceptor.MaxPayloadBytes = *logMaxPayload
+	loggingInterceptor.Backpressure = *logBackpressure
+	loggingInterceptor.HighWater = *logHighWater
+	switch *logBinary {
+	case "placeholder":
+	case "base64":
+		loggingInterceptor.BinaryAsBase64 = true
+	default:
+		logger.Error("invalid -log-binary value (want placeholder or base64)", "value", *logBinary)
+		os.Exit(1)
+	}
+	switch proxy.MalformedMode(*onMalformed) {
+	case proxy.MalformedForward, proxy.MalformedDrop, proxy.MalformedError:
+	default:
+		logger.Error("invalid -on-malformed value (want forward, drop or error)", "value", *onMalformed)
+		os.Exit(1)
+	}
+	stages[proxy.StageLogging] = loggingInterceptor
+
+	if *bypassMethods != "" {
+		bypass := proxy.NewBypassInterceptor(splitList(*bypassMethods)...)
+		if *bypassLog {
+			bypass.Log = loggingInterceptor
+		}
+		stages[proxy.StageBypass] = bypass
+	}
+
+	var order []string
+	if policyCfg != nil {
+		order = policyCfg.Pipeline
+	}
+	if *pipeline != "" {
+		order = splitList(*pipeline)
+	}
+	interceptors, omitted, err := proxy.BuildPipeline(order, stages)
+	if err != nil {
+		logger.Error("invalid interceptor pipeline", "error", err)
+		os.Exit(1)
+	}
+	for _, name := range omitted {
+		logger.Warn("interceptor left out of the pipeline is disabled", "interceptor", name)
+	}
 
 	chain := proxy.NewInterceptorChain(interceptors...)
+	chain.OnBlock = loggingInterceptor.LogBlocked
+	// Alerts and security events still queued when the proxy stops are
+	// delivered before exiting
+	var exporters sync.WaitGroup
+	if *blockAlert != "" {
+		sink, closer, err := proxy.OpenAlertSink(*blockAlert)
+		if err != nil {
+			logger.Error("invalid -block-alert", "error", err)
+			os.Exit(1)
+		}
+		defer closer.Close()
+		alerter := proxy.NewBlockAlerter(sink, logger)
+		exporters.Go(func() { alerter.Run(ctx) })
+		chain.OnBlock = func(ctx context.Context, msg *proxy.InterceptedMessage, err error) {
+			loggingInterceptor.LogBlocked(ctx, msg, err)
+			alerter.OnBlock(ctx, msg, err)
+		}
+	}
+	if *otelLogs != "" {
+		events = proxy.NewSecurityEvents(proxy.NewOTLPLogExporter(*otelLogs), logger)
+		exporters.Go(func() { events.Run(ctx) })
+		loggingInterceptor.Events = events
+	}
+	chain.Latency = proxy.NewInterceptorLatency()
+	sizeBuckets, err := parseSizeBuckets(*metricsSizeBuckets)
+	if err != nil {
+		logger.Error("invalid -metrics-size-buckets", "error", err)
+		os.Exit(1)
+	}
+	latencyBuckets, err := parseLatencyBuckets(*metricsLatencyBuckets)
+	if err != nil {
+		logger.Error("invalid -metrics-latency-buckets", "error", err)
+		os.Exit(1)
+	}
+	chain.Messages = proxy.NewMessageMetrics(sizeBuckets, latencyBuckets)
 
 	// Start dashboard in background
 	if *dashAddr != "" {
@@ -180,6 +633,30 @@ func main() {
 			logger.Error("failed to initialize dashboard", "error", err)
 			os.Exit(1)
 		}
+		if (*dashTLSCert == "") != (*dashTLSKey == "") {
+			logger.Error("-dashboard-tls-cert and -dashboard-tls-key must be set together")
+			os.Exit(1)
+		}
+		dash.TLSCertFile = *dashTLSCert
+		dash.TLSKeyFile = *dashTLSKey
+		dash.TLSSelfSigned = *dashTLSSelfSigned
+		if *dashAssets != "" {
+			if err := dash.SetAssetsDir(*dashAssets); err != nil {
+				logger.Error("invalid -dashboard-assets", "error", err)
+				os.Exit(1)
+			}
+		}
+		dash.SSEHeartbeat = *sseHeartbeat
+		dash.CORSOrigins = splitList(*dashCORSOrigin)
+		dash.AdminToken = *dashAdminToken
+		dash.Policy = policyEngine
+		// Only offer the switch when the freeze stage actually enforces it
+		if !slices.Contains(omitted, proxy.StageFreeze) {
+			dash.Freeze = freeze
+		}
+		dash.Latency = chain.Latency
+		dash.Messages = chain.Messages
+		dash.SessionDBs = sessionDBs
 		go func() {
 			if err := dash.Start(ctx); err != nil {
 				logger.Error("dashboard error", "error", err)
@@ -187,8 +664,8 @@ func main() {
 		}()
 
 		// Auto-open browser
-		if !*noBrowser {
-			dashURL := fmt.Sprintf("http://localhost%s", *dashAddr)
+		if !*noBrowser && !*once && !inspectMode {
+			dashURL := dash.URL()
 			go func() {
 				// Small delay to let the server start
 				time.Sleep(300 * time.Millisecond)
@@ -201,10 +678,39 @@ func main() {
 
 	// Create and run proxy
 	cfg := proxy.Config{
-		Command: cmdArgs[0],
-		Args:    cmdArgs[1:],
+		Command:              cmdArgs[0],
+		Args:                 cmdArgs[1:],
+		SessionID:            sessionID,
+		WaitReady:            *waitReady,
+		ReadySignal:          *readySignal,
+		ReadyTimeout:         *readyTimeout,
+		MaxInflight:          *maxInflight,
+		RequestTimeout:       *requestTimeout,
+		RejectWhenBusy:       *rejectBusy,
+		RejectDuplicateIDs:   *rejectDupIDs,
+		Once:                 *once,
+		PreloadTools:         *preloadTools,
+		AllowInitializeRetry: *allowInitRetry,
+		OnMalformed:          proxy.MalformedMode(*onMalformed),
+		IDPrefix:             *idPrefix,
+		Limits: proxy.ChildLimits{
+			NoFile:       *rlimitNoFile,
+			AddressSpace: *rlimitAS,
+			CPUSeconds:   *rlimitCPU,
+			Nice:         *childNice,
+		},
 	}
 	p := proxy.NewProxy(cfg, chain, logger)
+	p.OnToolsPreloaded = toolAnalytics.RegisterToolsList
+
+	// Client-scoped rules match on -client-id, or on the name the host
+	// gives in initialize
+	if pi, ok := stages[proxy.StagePolicy].(*proxy.PolicyInterceptor); ok {
+		pi.Client = *clientID
+		pi.OnClientDetected = func(client string) {
+			sqliteStore.SetSessionClient(context.Background(), p.SessionID(), client)
+		}
+	}
 
 	// Record session
 	sqliteStore.CreateSession(ctx, &store.Session{
@@ -212,47 +718,258 @@ func main() {
 		StartedAt: time.Now(),
 		Command:   cfg.Command,
 		Args:      cfg.Args,
+		Client:    *clientID,
 	})
 	defer sqliteStore.EndSession(context.Background(), p.SessionID())
 
+	if *controlSocket != "" {
+		ctl := control.NewServer(*controlSocket, sqliteStore, p.SessionID(), logger)
+		ctl.Approvals = approvalMgr
+		ctl.Proxy = p
+		go func() {
+			if err := ctl.Start(ctx); err != nil {
+				logger.Error("control socket error", "error", err)
+			}
+		}()
+	}
+
+	// SIGHUP reloads the policy's rules and, with -restart-on-hup,
+	// restarts the downstream
+	if policyReloader != nil {
+		reload := make(chan os.Signal, 1)
+		signal.Notify(reload, syscall.SIGHUP)
+		go policyReloader.Run(ctx, reload)
+	}
+	if *restartOnHup {
+		restart := make(chan os.Signal, 1)
+		signal.Notify(restart, syscall.SIGHUP)
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-restart:
+				}
+				if err := p.Restart(ctx); err != nil {
+					logger.Error("downstream restart failed", "error", err)
+					continue
+				}
+				logger.Info("downstream restarted")
+			}
+		}()
+	}
+
+	// On a signal, stop taking host messages and give reviewers the grace
+	// period to settle pending approvals; the rest are denied so the host
+	// gets an answer. A second signal exits at once
+	go func() {
+		select {
+		case <-sigCtx.Done():
+		case <-ctx.Done():
+			return
+		}
+		stopSignals()
+		if n := approvalMgr.PendingCount(); n > 0 {
+			p.Pause()
+			logger.Info("shutting down, waiting for pending approvals", "pending", n, "grace", *approvalShutdownGrace)
+		}
+		for _, req := range approvalMgr.Drain(*approvalShutdownGrace) {
+			logger.Warn("approval denied at shutdown", "id", req.ID, "tool", req.ToolName, "rule", req.RuleName, "reason", proxy.ReasonShutdown)
+		}
+		cancel()
+	}()
+
 	// Run proxy — blocks until downstream exits
-	if err := p.Run(ctx); err != nil {
-		logger.Error("proxy exited", "error", err)
+	if inspectMode {
+		insp := &inspect.Inspector{Model: inspectModel, Bus: eb, Approvals: approvalMgr, In: tty, Out: tty}
+		go func() {
+			insp.Run(ctx)
+			cancel() // quitting the inspector stops the proxy
+		}()
+	}
+
+	runErr := p.Run(ctx)
+	cancel()
+	exporters.Wait()
+
+	sqliteStore.Flush()
+	logSessionReport(sqliteStore, p.SessionID(), logger)
+	if scrubber.Profile != nil {
+		logScrubProfile(scrubber.Profile, logger)
+	}
+
+	if errors.Is(runErr, proxy.ErrSessionRejected) {
+		logger.Warn("session ended: policy refused the host's initialize request")
+		return
+	}
+	if runErr != nil {
+		logger.Error("proxy exited", "error", runErr)
 		os.Exit(1)
 	}
 }
 
+// logSessionReport writes a per-tool and per-method summary of policy
+// outcomes to the log.
+func logSessionReport(s store.Store, sessionID string, logger *slog.Logger) {
+	report, err := s.SessionReport(context.Background(), sessionID)
+	if err != nil {
+		logger.Error("failed to build session report", "error", err)
+		return
+	}
+	log := func(key string, c store.ActionCounts) {
+		logger.Info("session report",
+			"session", sessionID,
+			key, c.Name,
+			"total", c.Total,
+			"allowed", c.Allowed,
+			"denied", c.Denied,
+			"require_approval", c.RequireApproval,
+			"audited", c.Audited,
+		)
+	}
+	for _, c := range report.Tools {
+		log("tool", c)
+	}
+	for _, c := range report.Methods {
+		log("method", c)
+	}
+}
+
+// approvalRecord converts an approval request to its stored form.
+func approvalRecord(req *proxy.ApprovalRequest) *store.ApprovalRecord {
+	return &store.ApprovalRecord{
+		ID:        req.ID,
+		Timestamp: req.Timestamp,
+		SessionID: req.SessionID,
+		Direction: req.Direction,
+		Method:    req.Method,
+		ToolName:  req.ToolName,
+		RuleName:  req.RuleName,
+		Payload:   req.Payload,
+		Decision:  req.Decision,
+		DecidedAt: req.DecidedAt,
+		Reason:    req.Reason,
+	}
+}
+
+// logScrubProfile writes the time each scrubber pattern took, slowest
+// first, to the log.
+func logScrubProfile(profile *proxy.ScrubProfile, logger *slog.Logger) {
+	for _, t := range profile.Snapshot() {
+		logger.Info("scrubber profile",
+			"pattern", t.Pattern,
+			"calls", t.Calls,
+			"matches", t.Matches,
+			"total", t.Duration,
+			"mb_per_sec", fmt.Sprintf("%.1f", t.Throughput()/1e6),
+		)
+	}
+}
+
 func printUsage() {
 	fmt.Fprintln(os.Stderr, "ContextGate — MCP Proxy & Inspector")
 	fmt.Fprintln(os.Stderr, "")
 	fmt.Fprintln(os.Stderr, "Usage:")
 	fmt.Fprintln(os.Stderr, "  contextgate [options] -- <command> [args...]   Proxy an MCP server")
+	fmt.Fprintln(os.Stderr, "  contextgate inspect [options] -- <command>     Proxy with a live terminal view")
 	fmt.Fprintln(os.Stderr, "  contextgate setup                              Interactive setup wizard")
 	fmt.Fprintln(os.Stderr, "  contextgate wrap <name> -- <command> [args...] Register in Claude Code")
+	fmt.Fprintln(os.Stderr, "  contextgate policy export|import               Share a policy bundle")
+	fmt.Fprintln(os.Stderr, "  contextgate policy test <spec.yaml>            Check policy decisions against a spec")
+	fmt.Fprintln(os.Stderr, "  contextgate policy diff <old> <new>            Compare two policies rule by rule")
+	fmt.Fprintln(os.Stderr, "  contextgate db check|repair [--db path]        Check or rebuild the message database")
+	fmt.Fprintln(os.Stderr, "  contextgate golden record|compare              Replay a recorded session against a server and diff")
 	fmt.Fprintln(os.Stderr, "  contextgate version                            Print version")
 	fmt.Fprintln(os.Stderr, "  contextgate help                               Show this help")
 	fmt.Fprintln(os.Stderr, "")
 	fmt.Fprintln(os.Stderr, "Proxy options:")
 	fmt.Fprintln(os.Stderr, "  -dashboard string       Dashboard listen address (default \":9000\", \"\" to disable)")
 	fmt.Fprintln(os.Stderr, "  -db string              SQLite database path (default \"~/.contextgate/contextgate.db\")")
+	fmt.Fprintln(os.Stderr, "  -db-per-session         Store each session in its own database under sessions/ beside -db")
+	fmt.Fprintln(os.Stderr, "  -db-encryption-key-file f  Encrypt stored payloads with the key in f (default $CONTEXTGATE_DB_KEY)")
+	fmt.Fprintln(os.Stderr, "  -max-messages-per-session n  Keep only the newest n messages per session, plus flagged ones")
 	fmt.Fprintln(os.Stderr, "  -log-level string       Log level: debug, info, warn, error (default \"info\")")
+	fmt.Fprintln(os.Stderr, "  -session-log-level string  Per-session levels as key=level, keyed by session ID or server command name")
 	fmt.Fprintln(os.Stderr, "  -no-browser             Don't auto-open the dashboard in a browser")
+	fmt.Fprintln(os.Stderr, "  -sse-heartbeat dur      Keep-alive interval for the dashboard live stream (default \"15s\")")
+	fmt.Fprintln(os.Stderr, "  -log-backpressure dur   Hold messages up to this long while the log buffer is nearly full (default off)")
+	fmt.Fprintln(os.Stderr, "  -log-high-water float   Log buffer fill level at which backpressure engages (default 0.8)")
+	fmt.Fprintln(os.Stderr, "  -log-max-payload-bytes n  Truncate logged payloads beyond n bytes (default no limit)")
+	fmt.Fprintln(os.Stderr, "  -log-binary string      Log binary payloads as placeholder or base64 (default \"placeholder\")")
+	fmt.Fprintln(os.Stderr, "  -dashboard-tls-cert string  TLS certificate file for an HTTPS dashboard")
+	fmt.Fprintln(os.Stderr, "  -dashboard-tls-key string   TLS private key file for an HTTPS dashboard")
+	fmt.Fprintln(os.Stderr, "  -dashboard-tls-selfsigned   Serve the dashboard over HTTPS with a self-signed certificate")
+	fmt.Fprintln(os.Stderr, "  -dashboard-assets dir   Serve dashboard static files and templates from dir, falling back to the built-in ones")
+	fmt.Fprintln(os.Stderr, "  -dashboard-cors-origin list Origins allowed to call /api/ from a browser (* for any)")
+	fmt.Fprintln(os.Stderr, "  -dashboard-admin-token string Enable /api/admin/ endpoints for this bearer token")
+	fmt.Fprintln(os.Stderr, "  -metrics-size-buckets list    Message size histogram bounds in bytes for /metrics")
+	fmt.Fprintln(os.Stderr, "  -metrics-latency-buckets list Response latency histogram bounds (e.g. 10ms,1s) for /metrics")
+	fmt.Fprintln(os.Stderr, "  -control-socket path    Serve a JSON control API (stats, approvals, pause/resume) on a Unix socket")
+	fmt.Fprintln(os.Stderr, "  -wait-ready             Buffer host messages until the server answers initialize")
+	fmt.Fprintln(os.Stderr, "  -ready-signal string    Server notification method that signals readiness instead")
+	fmt.Fprintln(os.Stderr, "  -ready-timeout dur      Release buffered messages after this long (default \"10s\")")
+	fmt.Fprintln(os.Stderr, "  -max-inflight int       Max concurrent requests awaiting a server response (0 = unlimited)")
+	fmt.Fprintln(os.Stderr, "  -reject-busy            Reject requests over the limit instead of queueing them")
+	fmt.Fprintln(os.Stderr, "  -request-timeout dur    Answer requests the server hasn't responded to in time with an error (0 = wait)")
+	fmt.Fprintln(os.Stderr, "  -reject-duplicate-ids   Reject requests reusing the id of one still in flight")
+	fmt.Fprintln(os.Stderr, "  -once                   Proxy a single request and its response, then exit")
+	fmt.Fprintln(os.Stderr, "  -restart-on-hup         On SIGHUP, also restart the server and replay the host's handshake")
+	fmt.Fprintln(os.Stderr, "  -on-malformed string    Server output that is not valid JSON: forward, drop or error (default \"forward\")")
+	fmt.Fprintln(os.Stderr, "  -allow-initialize-retry Keep the session open when policy refuses initialize")
+	fmt.Fprintln(os.Stderr, "  -preload-tools          List the server's tools at session start, without the host seeing it")
+	fmt.Fprintln(os.Stderr, "  -child-rlimit-nofile n  Max open files for the server process (Linux only)")
+	fmt.Fprintln(os.Stderr, "  -child-rlimit-as n      Max virtual memory in bytes for the server process (Linux only)")
+	fmt.Fprintln(os.Stderr, "  -child-rlimit-cpu n     Max CPU seconds for the server process (Linux only)")
+	fmt.Fprintln(os.Stderr, "  -child-nice n           Scheduling niceness for the server process (Linux only)")
+	fmt.Fprintln(os.Stderr, "  -bypass-methods string  Comma-separated methods forwarded without running any interceptor")
+	fmt.Fprintln(os.Stderr, "  -bypass-log             Still log bypassed messages (default true)")
+	fmt.Fprintln(os.Stderr, "  -pipeline string        Comma-separated interceptor order; logging must be last")
+	fmt.Fprintln(os.Stderr, "  -session-id string      Record under this session ID; reusing one resumes that session")
+	fmt.Fprintln(os.Stderr, "  -stable-session         Derive the session ID from the command, args and working directory")
+	fmt.Fprintln(os.Stderr, "  -client-id string       Host application name matched by policy rules' client field")
+	fmt.Fprintln(os.Stderr, "  -id-prefix string       Reserved ID prefix for proxy-originated requests (default \"contextgate-\")")
 	fmt.Fprintln(os.Stderr, "")
 	fmt.Fprintln(os.Stderr, "Security options:")
 	fmt.Fprintln(os.Stderr, "  -policy string          Path to security policy YAML file")
+	fmt.Fprintln(os.Stderr, "  -policy-inline string   Security policy YAML given directly on the command line")
+	fmt.Fprintln(os.Stderr, "  -policy-csv string      CSV/TSV of rules: name,action,methods,tools,pattern[,message]")
+	fmt.Fprintln(os.Stderr, "  -deny-tools list        Tools whose calls are denied, merged into the policy")
+	fmt.Fprintln(os.Stderr, "  -require-approval-tools list  Tools whose calls need approval, merged into the policy")
+	fmt.Fprintln(os.Stderr, "  -audit-tools list       Tools whose calls are flagged for audit, merged into the policy")
+	fmt.Fprintln(os.Stderr, "  -blocklist-url string   URL of a tool blocklist merged in as deny rules")
+	fmt.Fprintln(os.Stderr, "  -blocklist-file string  File of a tool blocklist merged in as deny rules")
+	fmt.Fprintln(os.Stderr, "  -blocklist-refresh dur  How often the blocklist is re-fetched (default \"5m\")")
 	fmt.Fprintln(os.Stderr, "  -scrub-pii              Enable PII scrubbing in server responses")
+	fmt.Fprintln(os.Stderr, "  -scrub-profile          Time each scrubber pattern and log the slowest at exit")
 	fmt.Fprintln(os.Stderr, "  -approval-timeout dur   Timeout for approval requests (default \"60s\")")
+	fmt.Fprintln(os.Stderr, "  -approval-shutdown-grace dur  On shutdown, wait this long for pending approvals before denying them")
+	fmt.Fprintln(os.Stderr, "  -max-calls-per-session n Block tools/call requests after n in a session (0 = unlimited)")
+	fmt.Fprintln(os.Stderr, "  -approval-redact        Scrub PII from payloads shown in approval requests")
+	fmt.Fprintln(os.Stderr, "  -approve-changed-tools  Require approval for the next call to a tool changed mid-session")
+	fmt.Fprintln(os.Stderr, "  -approval-cancel mode   Answer to a request cancelled from the dashboard: error or drop (default \"error\")")
+	fmt.Fprintln(os.Stderr, "  -block-alert target     Alert on every blocked message: stderr, a file, or a webhook URL")
+	fmt.Fprintln(os.Stderr, "  -otel-logs url          Export decisions as OpenTelemetry log records (OTLP/HTTP)")
+	fmt.Fprintln(os.Stderr, "  -flag-unknown-methods   Audit messages whose method is not a standard MCP method")
+	fmt.Fprintln(os.Stderr, "  -known-methods string   Extra methods to treat as known (comma-separated)")
+	fmt.Fprintln(os.Stderr, "  -envelope string        Check JSON-RPC envelopes: log, fix or block (default: off)")
+	fmt.Fprintln(os.Stderr, "  -tool-notice string     Untrusted-content notice placed before tool results")
+	fmt.Fprintln(os.Stderr, "  -tool-notice-tools string  Tools or globs whose results get the notice (default: all)")
+	fmt.Fprintln(os.Stderr, "  -tool-notice-footer string  Text placed after noticed tool results")
+	fmt.Fprintln(os.Stderr, "  -tool-hints             Append usage stats and policy notes to tool descriptions")
+	fmt.Fprintln(os.Stderr, "  -tool-hint-template string  Go template for the hints (implies -tool-hints)")
 	fmt.Fprintln(os.Stderr, "")
 	fmt.Fprintln(os.Stderr, "Context optimization:")
 	fmt.Fprintln(os.Stderr, "  -prune-unused int       Prune tools unused in the last N sessions (0 = disabled)")
 	fmt.Fprintln(os.Stderr, "  -prune-keep-top int     Keep only the top K most-used tools (0 = disabled)")
-	fmt.Fprintln(os.Stderr, "  -prune-keep string      Comma-separated tools that should never be pruned")
+	fmt.Fprintln(os.Stderr, "  -prune-keep string      Comma-separated tools or globs that should never be pruned")
+	fmt.Fprintln(os.Stderr, "  -dedupe-tools           Drop repeated tool names from tools/list, keeping the first")
 	fmt.Fprintln(os.Stderr, "")
 	fmt.Fprintln(os.Stderr, "Examples:")
 	fmt.Fprintln(os.Stderr, "  contextgate -- npx -y @modelcontextprotocol/server-filesystem /tmp")
 	fmt.Fprintln(os.Stderr, "  contextgate --policy policy.yaml -- npx -y @modelcontextprotocol/server-filesystem /tmp")
 	fmt.Fprintln(os.Stderr, "  contextgate --scrub-pii -- npx -y @modelcontextprotocol/server-filesystem /tmp")
 	fmt.Fprintln(os.Stderr, "  contextgate --prune-unused 3 -- npx -y @modelcontextprotocol/server-filesystem /tmp")
+	fmt.Fprintln(os.Stderr, "  echo '{\"jsonrpc\":\"2.0\",\"id\":1,\"method\":\"tools/list\"}' | contextgate -once -dashboard \"\" -- ./server")
 	fmt.Fprintln(os.Stderr, "  contextgate setup")
 	fmt.Fprintln(os.Stderr, "  contextgate wrap my-fs -- npx -y @modelcontextprotocol/server-filesystem /tmp")
 }
@@ -264,6 +981,12 @@ func defaultDBPath() string {
 	return filepath.Join(dir, "contextgate.db")
 }
 
+// defaultPolicyPath is where 'contextgate policy import' installs a
+// policy, used when no policy flag is given.
+func defaultPolicyPath() string {
+	return filepath.Join(filepath.Dir(defaultDBPath()), "policy.yaml")
+}
+
 func parseLogLevel(s string) slog.Level {
 	switch s {
 	case "debug":
@@ -276,3 +999,82 @@ func parseLogLevel(s string) slog.Level {
 		return slog.LevelInfo
 	}
 }
+
+// parseSessionLogLevels parses key=level pairs for -session-log-level.
+// A key naming the server command applies to this proxy's own session.
+func parseSessionLogLevels(v, command, sessionID string) (map[string]slog.Level, error) {
+	levels := make(map[string]slog.Level)
+	for _, pair := range splitList(v) {
+		key, name, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("%q: want key=level", pair)
+		}
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(strings.TrimSpace(name))); err != nil {
+			return nil, fmt.Errorf("%q: %v", pair, err)
+		}
+		if key == command {
+			key = sessionID
+		}
+		levels[key] = level
+	}
+	return levels, nil
+}
+
+// parseSizeBuckets parses -metrics-size-buckets. An empty value selects
+// the default buckets.
+func parseSizeBuckets(v string) ([]float64, error) {
+	var bounds []float64
+	for _, item := range splitList(v) {
+		n, err := strconv.ParseFloat(item, 64)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("%q: want a positive number of bytes", item)
+		}
+		if len(bounds) > 0 && n <= bounds[len(bounds)-1] {
+			return nil, fmt.Errorf("%q: bounds must be increasing", item)
+		}
+		bounds = append(bounds, n)
+	}
+	return bounds, nil
+}
+
+// parseLatencyBuckets parses -metrics-latency-buckets. An empty value
+// selects the default buckets.
+func parseLatencyBuckets(v string) ([]time.Duration, error) {
+	var bounds []time.Duration
+	for _, item := range splitList(v) {
+		d, err := time.ParseDuration(item)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("%q: want a positive duration", item)
+		}
+		if len(bounds) > 0 && d <= bounds[len(bounds)-1] {
+			return nil, fmt.Errorf("%q: bounds must be increasing", item)
+		}
+		bounds = append(bounds, d)
+	}
+	return bounds, nil
+}
+
+// countSet returns how many of the given flag values are non-empty.
+func countSet(values ...string) int {
+	n := 0
+	for _, v := range values {
+		if v != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// splitList splits a comma-separated flag value, trimming spaces and
+// dropping empty entries.
+func splitList(v string) []string {
+	var out []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}